@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github/bromq-dev/bromq/internal/storage"
+
+	"github.com/bherbruck/configlib"
+)
+
+// Config holds migrate tool configuration (same DB_* settings as the server)
+type Config struct {
+	Database storage.DatabaseConfig `desc:"Database connection settings"`
+	Up       bool                   `flag:"up" desc:"Apply pending migrations (default: only show status)"`
+}
+
+func main() {
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+
+	var cfg Config
+	if err := configlib.Parse(&cfg); err != nil {
+		slog.Error("Failed to parse configuration", "error", err)
+		os.Exit(1)
+	}
+
+	if err := cfg.Database.PostParse(); err != nil {
+		slog.Error("Invalid database configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// Open without applying migrations so status reflects the real state
+	cfg.Database.DisableAutoMigrate = true
+	db, err := storage.Open(&cfg.Database)
+	if err != nil {
+		slog.Error("Failed to open database", "error", err)
+		os.Exit(1)
+	}
+	defer func() { _ = db.Close() }()
+
+	if cfg.Up {
+		applied, err := db.Migrate()
+		if err != nil {
+			slog.Error("Migration failed", "applied", applied, "error", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Applied %d migration(s)\n\n", applied)
+	}
+
+	if err := printStatus(db); err != nil {
+		slog.Error("Failed to read migration status", "error", err)
+		os.Exit(1)
+	}
+}
+
+// printStatus writes a table of known migrations and whether they are applied
+func printStatus(db *storage.DB) error {
+	status, err := db.MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range status {
+		state, appliedAt := "pending", "-"
+		if s.Applied {
+			state = "applied"
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	return w.Flush()
+}
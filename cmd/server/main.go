@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -12,16 +13,22 @@ import (
 
 	"github/bromq-dev/bromq/hooks/auth"
 	"github/bromq-dev/bromq/hooks/bridge"
+	eventshook "github/bromq-dev/bromq/hooks/events"
 	"github/bromq-dev/bromq/hooks/metrics"
+	"github/bromq-dev/bromq/hooks/recording"
 	"github/bromq-dev/bromq/hooks/retained"
+	"github/bromq-dev/bromq/hooks/routes"
 	scripthook "github/bromq-dev/bromq/hooks/script"
+	"github/bromq-dev/bromq/hooks/sessions"
 	"github/bromq-dev/bromq/hooks/tracking"
 	"github/bromq-dev/bromq/internal/api"
 	"github/bromq-dev/bromq/internal/appconfig"
 	"github/bromq-dev/bromq/internal/badgerstore"
 	"github/bromq-dev/bromq/internal/config"
+	"github/bromq-dev/bromq/internal/events"
 	"github/bromq-dev/bromq/internal/mqtt"
 	"github/bromq-dev/bromq/internal/provisioning"
+	"github/bromq-dev/bromq/internal/redact"
 	"github/bromq-dev/bromq/internal/script"
 	"github/bromq-dev/bromq/internal/storage"
 	"github/bromq-dev/bromq/web"
@@ -52,6 +59,11 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Handle dry-run config validation (e.g. in CI)
+	if cfg.ValidateOnly {
+		os.Exit(validateConfigFile(cfg.ConfigFile))
+	}
+
 	slog.Info("Starting BroMQ", "version", version)
 
 	// Initialize database
@@ -80,6 +92,9 @@ func main() {
 	defer func() { _ = badgerStore.Close() }()
 
 	// Load and provision configuration if provided
+	var defaultACL []storage.StaticACLRule
+	var provisioned storage.ProvisionedSet
+	var startupRetained []mqtt.RetainedSeed
 	if cfg.ConfigFile != "" {
 		slog.Info("Loading configuration file", "path", cfg.ConfigFile)
 		provCfg, err := config.Load(cfg.ConfigFile)
@@ -92,20 +107,43 @@ func main() {
 			slog.Error("Failed to provision configuration", "error", err)
 			os.Exit(1)
 		}
+
+		provisioned = provisioning.ProvisionedSet(provCfg)
+		for _, rule := range provCfg.DefaultACL {
+			defaultACL = append(defaultACL, storage.StaticACLRule{Topic: rule.Topic, Permission: rule.Permission, Retain: rule.Retain})
+		}
+		for _, msg := range provCfg.Retained {
+			startupRetained = append(startupRetained, mqtt.RetainedSeed{Topic: msg.Topic, Payload: []byte(msg.Payload), QoS: byte(msg.QoS)})
+		}
 	}
 
 	// Create MQTT server
-	if cfg.MQTT.AllowAnonymous {
+	if cfg.MQTT.AnonymousListeners != "" {
+		slog.Warn("Anonymous MQTT connections are ENABLED on selected listeners", "listeners", cfg.MQTT.AnonymousListeners)
+	} else if cfg.MQTT.AllowAnonymous {
 		slog.Warn("Anonymous MQTT connections are ENABLED - this is insecure for production use")
 	} else {
 		slog.Info("Anonymous MQTT connections are DISABLED (secure default)")
 	}
 
 	mqttServer := mqtt.New(&cfg.MQTT)
+	mqttServer.SetStartupRetained(startupRetained)
+
+	// Payload redaction for events, recordings, the last value cache and script logs
+	redactor := redact.Parse(cfg.MQTT.RedactTopics)
+	if redactor != nil {
+		slog.Info("Payload redaction enabled", "topics", redactor.Filters())
+	}
 
 	// Add metrics tracking hook with Prometheus (create first so we can pass to other hooks)
 	promMetrics := mqtt.NewPrometheusMetrics()
 	metricsHook := metrics.NewMetricsHook(promMetrics)
+	if cfg.Metrics.LastValueCache {
+		lastValues := metrics.NewLastValueCache(cfg.Metrics)
+		lastValues.SetRedactor(redactor)
+		metricsHook.SetLastValues(lastValues)
+		slog.Info("Last value cache enabled", "max_topics", cfg.Metrics.LastValueMaxTopics, "ttl", cfg.Metrics.LastValueTTL)
+	}
 	if err := mqttServer.AddHook(metricsHook, nil); err != nil {
 		slog.Error("Failed to add metrics hook", "error", err)
 		os.Exit(1)
@@ -115,6 +153,13 @@ func main() {
 	// Add authentication hook with metrics
 	authHook := auth.NewAuthHook(db, cfg.MQTT.AllowAnonymous)
 	authHook.SetMetrics(promMetrics)
+	authHook.SetAnonymousListeners(cfg.MQTT.AnonymousPolicy())
+	ipFilter, err := auth.ParseIPFilter(auth.SplitCIDRs(cfg.MQTT.AllowedCIDRs), auth.SplitCIDRs(cfg.MQTT.DeniedCIDRs))
+	if err != nil {
+		slog.Error("Invalid MQTT source address filter", "error", err)
+		os.Exit(1)
+	}
+	authHook.SetIPFilter(ipFilter)
 	if err := mqttServer.AddAuthHook(authHook); err != nil {
 		slog.Error("Failed to add auth hook", "error", err)
 		os.Exit(1)
@@ -124,31 +169,198 @@ func main() {
 	// Add ACL hook with metrics
 	aclHook := auth.NewACLHook(db)
 	aclHook.SetMetrics(promMetrics)
+	if cfg.MQTT.AnonymousACL != "" {
+		anonymousACL, err := storage.ParseStaticACL(cfg.MQTT.AnonymousACL)
+		if err != nil {
+			slog.Error("Invalid MQTT_ANONYMOUS_ACL", "error", err)
+			os.Exit(1)
+		}
+		aclHook.SetAnonymousACL(anonymousACL)
+		slog.Info("Anonymous ACL configured", "rules", len(anonymousACL.Rules()))
+	}
+	if cfg.MQTT.RetainRequiresPermission {
+		aclHook.SetRetainPermission(true)
+		slog.Info("Retained publishes require ACL retain permission")
+	}
+	if cfg.MQTT.LogACLDenials {
+		aclHook.SetDenialLogging(cfg.MQTT.ACLDenialLogInterval)
+	}
+	if cfg.MQTT.ACLAuditUnmatched {
+		aclHook.SetUnmatchedTracking(auth.NewUnmatchedTracker(cfg.MQTT.ACLAuditSize))
+		slog.Info("Unmatched ACL audit enabled", "per_user", cfg.MQTT.ACLAuditSize)
+	}
+	var deadLetter *mqtt.DeadLetter
+	if cfg.MQTT.DeadLetterTopic != "" {
+		deadLetter = mqtt.NewDeadLetter(mqttServer.Server, cfg.MQTT.DeadLetterTopic)
+		aclHook.SetPublishRejectionHandler(deadLetter)
+		slog.Info("Dead-letter topic enabled", "topic", cfg.MQTT.DeadLetterTopic)
+	}
 	if err := mqttServer.AddACLHook(aclHook); err != nil {
 		slog.Error("Failed to add ACL hook", "error", err)
 		os.Exit(1)
 	}
 	slog.Info("ACL hook registered")
 
-	// Add retained message persistence hook (uses BadgerDB for high-write performance)
+	// Add per-topic payload size limits (before hooks that persist or forward publishes)
+	if cfg.MQTT.TopicSizeLimits != "" {
+		limits, err := mqtt.ParseTopicSizeLimits(cfg.MQTT.TopicSizeLimits)
+		if err != nil {
+			slog.Error("Invalid topic size limits", "error", err)
+			os.Exit(1)
+		}
+		payloadLimitsHook := mqtt.NewPayloadLimitsHook(limits)
+		payloadLimitsHook.SetMetrics(promMetrics)
+		if deadLetter != nil {
+			payloadLimitsHook.SetDeadLetter(deadLetter)
+		}
+		if err := mqttServer.AddHook(payloadLimitsHook, nil); err != nil {
+			slog.Error("Failed to add payload limits hook", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Payload limits hook registered", "limits", len(limits))
+	}
+
+	// Refuse connections over the client cap
+	if cfg.MQTT.MaxClients > 0 {
+		maxClientsHook := mqtt.NewMaxClientsHook(mqttServer.Server, &cfg.MQTT)
+		maxClientsHook.SetMetrics(promMetrics)
+		if err := mqttServer.AddHook(maxClientsHook, nil); err != nil {
+			slog.Error("Failed to add max clients hook", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Max clients hook registered", "max_clients", cfg.MQTT.MaxClients)
+	}
+
+	// Throttle clients stuck in a reconnect loop
+	if cfg.MQTT.ReconnectLimit > 0 {
+		reconnectHook, err := mqtt.NewReconnectLimitHook(mqttServer.Server, &cfg.MQTT)
+		if err != nil {
+			slog.Error("Invalid MQTT_RECONNECT_LIMIT/MQTT_RECONNECT_WINDOW", "error", err)
+			os.Exit(1)
+		}
+		reconnectHook.SetMetrics(promMetrics)
+		mqttServer.AddConnectCheck(reconnectHook)
+		slog.Info("Reconnect limit registered", "limit", cfg.MQTT.ReconnectLimit, "window", cfg.MQTT.ReconnectWindow)
+	}
+
+	// Strip or refuse last wills the client may not publish
+	if cfg.MQTT.WillDenyTopics != "" || cfg.MQTT.WillCheckACL {
+		willHook, err := mqtt.NewWillPolicyHook(mqttServer.Server, &cfg.MQTT)
+		if err != nil {
+			slog.Error("Invalid MQTT_WILL_POLICY", "error", err)
+			os.Exit(1)
+		}
+		if cfg.MQTT.WillCheckACL {
+			willHook.SetACL(aclHook)
+		}
+		willHook.SetMetrics(promMetrics)
+		mqttServer.AddConnectCheck(willHook)
+		slog.Info("Will policy registered", "deny_topics", cfg.MQTT.WillDenyTopics, "check_acl", cfg.MQTT.WillCheckACL, "policy", cfg.MQTT.WillPolicy)
+	}
+
+	// Add topic length/depth limits
+	if cfg.MQTT.MaxTopicLength > 0 || cfg.MQTT.MaxTopicLevels > 0 {
+		topicLimitsHook := mqtt.NewTopicLimitsHook(&cfg.MQTT)
+		topicLimitsHook.SetMetrics(promMetrics)
+		if err := mqttServer.AddHook(topicLimitsHook, nil); err != nil {
+			slog.Error("Failed to add topic limits hook", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Topic limits hook registered", "max_length", cfg.MQTT.MaxTopicLength, "max_levels", cfg.MQTT.MaxTopicLevels)
+	}
+
+	// Add retained message persistence hook (BadgerDB by default for high-write performance)
 	// The hook will automatically load retained messages on startup via StoredRetainedMessages()
-	retainedHook := retained.NewRetainedHook(badgerStore)
+	var retainedStore retained.RetainedStore
+	switch cfg.MQTT.RetainedBackend {
+	case retained.BackendBadger, "":
+		retainedStore = badgerStore
+	case retained.BackendSQL:
+		retainedStore = retained.NewSQLStore(db)
+	default:
+		slog.Error("Invalid MQTT_RETAINED_BACKEND (expected badger or sql)", "backend", cfg.MQTT.RetainedBackend)
+		os.Exit(1)
+	}
+	retainedHook := retained.NewRetainedHook(retainedStore)
+	retainedHook.SetMetrics(promMetrics)
 	if err := mqttServer.AddHook(retainedHook, nil); err != nil {
 		slog.Error("Failed to add retained hook", "error", err)
 		os.Exit(1)
 	}
-	slog.Info("Retained message hook registered")
+	slog.Info("Retained message hook registered", "backend", cfg.MQTT.RetainedBackend)
+
+	// Add session persistence hook; stored sessions are restored when the broker starts
+	if cfg.MQTT.DurableSessions {
+		sessionHook := sessions.NewSessionHook(badgerStore)
+		if err := mqttServer.AddHook(sessionHook, nil); err != nil {
+			slog.Error("Failed to add session hook", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Durable sessions enabled")
+	}
 
 	// Add client tracking hook
 	trackingHook := tracking.NewTrackingHook(db)
+	trackingHook.SetBatchWindow(cfg.Tracking.BatchWindow)
+	if cfg.Tracking.InactiveRetention > 0 {
+		trackingHook.SetInactiveRetention(cfg.Tracking.InactiveRetention)
+		slog.Info("Inactive client cleanup enabled", "retention", cfg.Tracking.InactiveRetention)
+	}
+	if cfg.Tracking.GeoIPDB != "" {
+		// Geo enrichment is optional; a missing database only disables it
+		geo, err := tracking.OpenMaxMindResolver(cfg.Tracking.GeoIPDB)
+		if err != nil {
+			slog.Warn("GeoIP enrichment disabled", "path", cfg.Tracking.GeoIPDB, "error", err)
+		} else {
+			defer func() { _ = geo.Close() }()
+			trackingHook.SetGeoResolver(geo)
+			slog.Info("GeoIP enrichment enabled", "path", cfg.Tracking.GeoIPDB)
+		}
+	}
 	if err := mqttServer.AddHook(trackingHook, nil); err != nil {
 		slog.Error("Failed to add tracking hook", "error", err)
 		os.Exit(1)
 	}
 	slog.Info("Client tracking hook registered")
 
+	// Add live event hook (feeds the API event stream)
+	eventBus := events.NewBus()
+	db.SetEventBus(eventBus)
+
+	// Apply the client ID policy to clients that have authenticated
+	clientIDHook, err := mqtt.NewClientIDPolicyHook(mqttServer.Server, &cfg.MQTT)
+	if err != nil {
+		slog.Error("Invalid MQTT_CLIENT_ID_POLICY", "error", err)
+		os.Exit(1)
+	}
+	clientIDHook.SetEvents(eventBus)
+	mqttServer.AddConnectCheck(clientIDHook)
+	slog.Info("Client ID policy registered", "policy", cfg.MQTT.ClientIDPolicy)
+
+	eventsHook := eventshook.NewEventsHook(eventBus)
+	eventsHook.SetRedactor(redactor)
+	if err := mqttServer.AddHook(eventsHook, nil); err != nil {
+		slog.Error("Failed to add events hook", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Events hook registered")
+
+	// Add message recording hook (optional, for debugging and replay)
+	if cfg.Recording.Enabled {
+		recordingHook := recording.NewRecordingHook(badgerStore, &cfg.Recording)
+		recordingHook.SetRedactor(redactor)
+		if err := mqttServer.AddHook(recordingHook, nil); err != nil {
+			slog.Error("Failed to add recording hook", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Recording hook registered", "topics", cfg.Recording.Topics, "max_messages", cfg.Recording.MaxMessages)
+	}
+
 	// Initialize bridge manager and hook
 	bridgeManager := bridge.NewManager(db, mqttServer.Server)
+	bridgeManager.SetEventBus(eventBus)
+	bridgeManager.SetMetrics(bridge.NewMetrics())
+	bridgeManager.SetLoopMarker(cfg.Bridge.LoopMarker)
 	bridgeHook := bridge.NewBridgeHook(bridgeManager)
 	if err := mqttServer.AddHook(bridgeHook, nil); err != nil {
 		slog.Error("Failed to add bridge hook", "error", err)
@@ -156,8 +368,27 @@ func main() {
 	}
 	slog.Info("Bridge hook registered")
 
+	// Republish messages between local topics (topic routes)
+	routeHook := routes.NewRouteHook(mqttServer.Server, db)
+	if err := routeHook.Reload(); err != nil {
+		slog.Error("Failed to load topic routes", "error", err)
+	}
+	if err := mqttServer.AddHook(routeHook, nil); err != nil {
+		slog.Error("Failed to add topic route hook", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Topic route hook registered")
+
 	// Initialize script engine and hook
 	scriptEngine := script.NewEngine(db, badgerStore, mqttServer.Server)
+	scriptEngine.SetMetrics(script.NewMetrics())
+	scriptEngine.SetRedactor(redactor)
+	scriptEngine.SetSubscribeTopics(cfg.Script.SubscribeTopics)
+	if disabled, err := db.Settings().GetBool(storage.SettingScriptsDisabled, false); err != nil {
+		slog.Warn("Failed to load script kill switch", "error", err)
+	} else {
+		scriptEngine.SetScriptsDisabled(disabled)
+	}
 	scriptEngine.Start()
 	scriptHookInstance := scripthook.NewScriptHook(scriptEngine)
 	if err := mqttServer.AddHook(scriptHookInstance, nil); err != nil {
@@ -181,7 +412,22 @@ func main() {
 	}
 
 	// Start HTTP API server in a goroutine
-	apiServer := api.NewServer(cfg.API.HTTPAddr, db, mqttServer, web.FS, scriptEngine, &cfg.API)
+	apiServer := api.NewServer(cfg.API.HTTPAddr, db, badgerStore, mqttServer, web.FS, scriptEngine, &cfg.API)
+	// Retained endpoints write through the hook so its byte accounting sees their changes
+	apiServer.SetRetainedStore(retainedHook)
+	apiServer.SetRecentTopicSource(metricsHook.Topics())
+	apiServer.SetThroughputSource(metricsHook.Throughput())
+	apiServer.SetBridgeStatusSource(bridgeManager)
+	apiServer.SetTopicRouteReloader(routeHook)
+	if lastValues := metricsHook.LastValues(); lastValues != nil {
+		apiServer.SetLastValueSource(lastValues)
+	}
+	apiServer.SetEventBus(eventBus)
+	apiServer.SetDefaultACL(defaultACL)
+	apiServer.SetProvisionedSet(provisioned)
+	if tracker := aclHook.Unmatched(); tracker != nil {
+		apiServer.SetUnmatchedACLSource(tracker)
+	}
 	go func() {
 		if err := apiServer.Start(); err != nil {
 			slog.Error("Failed to start HTTP server", "error", err)
@@ -193,7 +439,7 @@ func main() {
 	slog.Info("BroMQ is running")
 	slog.Info("  MQTT TCP", "address", cfg.MQTT.TCPAddr)
 	slog.Info("  MQTT WebSocket", "address", cfg.MQTT.WSAddr)
-	slog.Info("  HTTP API", "address", cfg.API.HTTPAddr)
+	slog.Info("  HTTP API", "address", cfg.API.HTTPAddr, "tls", cfg.API.TLSEnabled())
 	if cfg.Database.Type == "sqlite" {
 		slog.Info("  Database", "type", cfg.Database.Type, "path", cfg.Database.FilePath)
 	} else {
@@ -214,29 +460,35 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// 1. Stop MQTT server (no new connections)
+	// 1. Stop HTTP API server (drain in-flight requests)
+	slog.Info("Stopping HTTP API server...")
+	if err := apiServer.Shutdown(ctx); err != nil {
+		slog.Error("Error shutting down HTTP API server", "error", err)
+	}
+
+	// 2. Stop MQTT server (no new connections)
 	slog.Info("Stopping MQTT server...")
 	if err := mqttServer.Close(); err != nil {
 		slog.Error("Error closing MQTT server", "error", err)
 	}
 
-	// 2. Stop bridge connections
+	// 3. Stop bridge connections
 	slog.Info("Stopping bridges...")
 	bridgeManager.Stop()
 
-	// 3. Shutdown script engine (state is now in BadgerDB, no flush needed)
+	// 4. Shutdown script engine (state is now in BadgerDB, no flush needed)
 	slog.Info("Shutting down script engine...")
 	if err := scriptEngine.Shutdown(ctx); err != nil {
 		slog.Error("Error shutting down script engine", "error", err)
 	}
 
-	// 4. Close BadgerDB (flushes any pending writes)
+	// 5. Close BadgerDB (flushes any pending writes)
 	slog.Info("Closing BadgerDB...")
 	if err := badgerStore.Close(); err != nil {
 		slog.Error("Error closing BadgerDB", "error", err)
 	}
 
-	// 5. Close database
+	// 6. Close database
 	slog.Info("Closing database...")
 	if err := db.Close(); err != nil {
 		slog.Error("Error closing database", "error", err)
@@ -245,6 +497,31 @@ func main() {
 	slog.Info("Shutdown complete")
 }
 
+// validateConfigFile checks the config file without opening the database or
+// starting listeners, printing every problem found, and returns the exit code
+func validateConfigFile(path string) int {
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "--validate-config needs a configuration file (--config or CONFIG_FILE)")
+		return 2
+	}
+
+	if err := provisioning.ValidateConfig(path); err != nil {
+		var problems config.ValidationErrors
+		if !errors.As(err, &problems) {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem)
+		}
+		fmt.Fprintf(os.Stderr, "%s: %d problem(s) found\n", path, len(problems))
+		return 1
+	}
+
+	fmt.Printf("%s: configuration is valid\n", path)
+	return 0
+}
+
 // setupBasicLogging configures a basic logger before config parsing
 // This ensures we can log config parsing errors
 func setupBasicLogging() {
@@ -3,19 +3,29 @@ package auth
 import (
 	"bytes"
 	"log/slog"
+	"sync"
+	"time"
 
 	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
 )
 
 // ACLHook implements MQTT ACL (Access Control List) using a database
 type ACLHook struct {
 	mqtt.HookBase
-	checker ACLChecker
-	metrics ACLMetrics
+	checker   ACLChecker
+	anonymous ACLChecker // Rules for clients without credentials (nil = use checker)
+	metrics   ACLMetrics
+	denials   *denialLogger           // nil = denials are not logged
+	unmatched *UnmatchedTracker       // nil = unmatched denials are not tracked
+	rejected  PublishRejectionHandler // nil = denied publishes are not reported
+
+	// checkRetain requires the "retain" action for retained publishes
+	checkRetain bool
 }
 
 // ACLChecker interface for checking ACL permissions
-// Supports dynamic placeholders: ${username} and ${clientid}
+// Supports dynamic placeholders: ${username}, ${clientid} and the UTC date (${year}, ${month}, ${day})
 type ACLChecker interface {
 	CheckACL(username, clientID, topic, action string) (bool, error)
 }
@@ -23,7 +33,19 @@ type ACLChecker interface {
 // ACLMetrics interface for recording ACL metrics
 type ACLMetrics interface {
 	RecordACLCheck(username, action, result string)
-	RecordACLDenied(username, action, topic string)
+	RecordACLDenied(username, action string)
+}
+
+// Reasons passed to PublishRejectionHandler
+const (
+	RejectReasonACLDenied = "acl_denied"
+	RejectReasonACLError  = "acl_error"
+)
+
+// PublishRejectionHandler is notified of publishes the ACL rejected
+// (e.g. to republish them to a dead-letter topic)
+type PublishRejectionHandler interface {
+	RejectPublish(cl *mqtt.Client, topic, reason string)
 }
 
 // NewACLHook creates a new ACL hook
@@ -38,6 +60,42 @@ func (h *ACLHook) SetMetrics(metrics ACLMetrics) {
 	h.metrics = metrics
 }
 
+// SetAnonymousACL sets the rules applied to clients that connected without a
+// username, instead of looking them up as the "anonymous" user
+func (h *ACLHook) SetAnonymousACL(checker ACLChecker) {
+	h.anonymous = checker
+}
+
+// SetDenialLogging enables sampled logging of ACL denials
+// At most one denial is logged per interval; the rest are counted and
+// reported with the next logged denial. An interval <= 0 logs every denial
+func (h *ACLHook) SetDenialLogging(interval time.Duration) {
+	h.denials = &denialLogger{interval: interval}
+}
+
+// SetUnmatchedTracking records denials where no ACL rule matched the topic,
+// so admins can see which rules are missing
+// Only checkers implementing ACLRuleMatcher can tell these denials apart
+func (h *ACLHook) SetUnmatchedTracking(tracker *UnmatchedTracker) {
+	h.unmatched = tracker
+}
+
+// SetPublishRejectionHandler reports every denied publish to handler
+func (h *ACLHook) SetPublishRejectionHandler(handler PublishRejectionHandler) {
+	h.rejected = handler
+}
+
+// SetRetainPermission makes retained publishes need the "retain" action on
+// their topic. Publishes without it are still delivered, minus the retain flag
+func (h *ACLHook) SetRetainPermission(enabled bool) {
+	h.checkRetain = enabled
+}
+
+// Unmatched returns the unmatched attempt tracker (nil when disabled)
+func (h *ACLHook) Unmatched() *UnmatchedTracker {
+	return h.unmatched
+}
+
 // ID returns the hook identifier
 func (h *ACLHook) ID() string {
 	return "database-acl"
@@ -45,6 +103,9 @@ func (h *ACLHook) ID() string {
 
 // Provides indicates which hook methods this hook provides
 func (h *ACLHook) Provides(b byte) bool {
+	if b == mqtt.OnPublish {
+		return h.checkRetain // Only needed to enforce retain permission
+	}
 	return bytes.Contains([]byte{
 		mqtt.OnACLCheck,
 	}, []byte{b})
@@ -52,14 +113,7 @@ func (h *ACLHook) Provides(b byte) bool {
 
 // OnACLCheck is called when a client attempts to publish or subscribe
 func (h *ACLHook) OnACLCheck(cl *mqtt.Client, topic string, write bool) bool {
-	// Get username from client properties
-	username := string(cl.Properties.Username)
-	if username == "" {
-		username = "anonymous"
-	}
-
-	// Get client ID
-	clientID := cl.ID
+	username, clientID := aclUsername(cl), cl.ID
 
 	// Determine action (publish or subscribe)
 	action := "sub"
@@ -67,13 +121,16 @@ func (h *ACLHook) OnACLCheck(cl *mqtt.Client, topic string, write bool) bool {
 		action = "pub"
 	}
 
-	// Check ACL with placeholder support
-	allowed, err := h.checker.CheckACL(username, clientID, topic, action)
+	checker := h.checkerFor(cl)
+	allowed, err := h.allows(cl, topic, action)
 	if err != nil {
 		slog.Error("ACL check error", "username", username, "clientid", clientID, "topic", topic, "action", action, "error", err)
 		if h.metrics != nil {
 			h.metrics.RecordACLCheck(username, action, "error")
 		}
+		if write && h.rejected != nil {
+			h.rejected.RejectPublish(cl, topic, RejectReasonACLError)
+		}
 		return false
 	}
 
@@ -83,10 +140,110 @@ func (h *ACLHook) OnACLCheck(cl *mqtt.Client, topic string, write bool) bool {
 			h.metrics.RecordACLCheck(username, action, "allowed")
 		} else {
 			h.metrics.RecordACLCheck(username, action, "denied")
-			h.metrics.RecordACLDenied(username, action, topic)
-			slog.Warn("ACL denied", "username", username, "clientid", clientID, "topic", topic, "action", action)
+			h.metrics.RecordACLDenied(username, action)
 		}
 	}
+	if !allowed && h.denials != nil {
+		h.denials.log(username, clientID, topic, action)
+	}
+	if !allowed && h.unmatched != nil {
+		h.recordUnmatched(checker, username, clientID, topic, action)
+	}
+	if !allowed && write && h.rejected != nil {
+		h.rejected.RejectPublish(cl, topic, RejectReasonACLDenied)
+	}
 
 	return allowed
 }
+
+// Allows reports whether the client's rules grant action (pub, sub or retain)
+// on topic. Unlike OnACLCheck it has no side effects: nothing is counted,
+// logged, tracked or dead-lettered, so it is safe to call speculatively
+func (h *ACLHook) Allows(cl *mqtt.Client, topic, action string) (bool, error) {
+	return h.allows(cl, topic, action)
+}
+
+// aclUsername returns the username rules are looked up by ("anonymous" for
+// clients that connected without one)
+func aclUsername(cl *mqtt.Client) string {
+	if username := string(cl.Properties.Username); username != "" {
+		return username
+	}
+	return "anonymous"
+}
+
+// checkerFor returns the checker holding the client's rules
+func (h *ACLHook) checkerFor(cl *mqtt.Client) ACLChecker {
+	if len(cl.Properties.Username) == 0 && h.anonymous != nil {
+		return h.anonymous
+	}
+	return h.checker
+}
+
+// allows checks the client's rules for action on topic, with placeholder support
+func (h *ACLHook) allows(cl *mqtt.Client, topic, action string) (bool, error) {
+	return h.checkerFor(cl).CheckACL(aclUsername(cl), cl.ID, topic, action)
+}
+
+// OnPublish strips the retain flag from a publish when retain permission is
+// enforced and the client lacks it. The publish itself already passed
+// OnACLCheck; this runs after topic aliases are resolved
+func (h *ACLHook) OnPublish(cl *mqtt.Client, pk packets.Packet) (packets.Packet, error) {
+	if !h.checkRetain || !pk.FixedHeader.Retain || cl.Net.Inline {
+		return pk, nil
+	}
+
+	username := aclUsername(cl)
+	allowed, err := h.allows(cl, pk.TopicName, "retain")
+	if err != nil {
+		slog.Error("ACL retain check error", "username", username, "clientid", cl.ID, "topic", pk.TopicName, "error", err)
+	}
+	if !allowed {
+		slog.Debug("Retain flag stripped - no retain permission", "username", username, "clientid", cl.ID, "topic", pk.TopicName)
+		pk.FixedHeader.Retain = false
+	}
+	return pk, nil
+}
+
+// recordUnmatched tracks a denial if no rule of the checker matched the topic
+func (h *ACLHook) recordUnmatched(checker ACLChecker, username, clientID, topic, action string) {
+	matcher, ok := checker.(ACLRuleMatcher)
+	if !ok {
+		return
+	}
+
+	matched, err := matcher.HasMatchingACLRule(username, clientID, topic)
+	if err != nil {
+		slog.Error("ACL rule match error", "username", username, "topic", topic, "error", err)
+		return
+	}
+	if !matched {
+		h.unmatched.Record(username, clientID, topic, action)
+	}
+}
+
+// denialLogger rate-limits ACL denial log lines so a misbehaving client
+// can't flood the logs
+type denialLogger struct {
+	interval   time.Duration
+	mu         sync.Mutex
+	last       time.Time
+	suppressed int
+}
+
+// log writes the denial unless one was logged within the interval
+func (d *denialLogger) log(username, clientID, topic, action string) {
+	d.mu.Lock()
+	now := time.Now()
+	if d.interval > 0 && !d.last.IsZero() && now.Sub(d.last) < d.interval {
+		d.suppressed++
+		d.mu.Unlock()
+		return
+	}
+	suppressed := d.suppressed
+	d.last = now
+	d.suppressed = 0
+	d.mu.Unlock()
+
+	slog.Warn("ACL denied", "username", username, "clientid", clientID, "topic", topic, "action", action, "suppressed", suppressed)
+}
@@ -1,9 +1,17 @@
 package auth
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	bromqmqtt "github/bromq-dev/bromq/internal/mqtt"
+	"github/bromq-dev/bromq/internal/storage"
 )
 
 // MockACLChecker implements the ACLChecker interface for testing
@@ -259,3 +267,236 @@ func TestACLHook_OnACLCheck_Anonymous(t *testing.T) {
 		})
 	}
 }
+
+func TestACLHook_DenialMetrics(t *testing.T) {
+	checker := NewMockACLChecker()
+	checker.AddRule("sensor", "sensors/1", "pub", true)
+
+	reg := prometheus.NewRegistry()
+	hook := NewACLHook(checker)
+	hook.SetMetrics(bromqmqtt.NewPrometheusMetricsWithRegistry(reg))
+
+	cl := &mqtt.Client{ID: "device-1", Properties: mqtt.ClientProperties{Username: []byte("sensor")}}
+	if !hook.OnACLCheck(cl, "sensors/1", true) {
+		t.Fatal("allowed publish was denied")
+	}
+	for _, topic := range []string{"admin/reset", "admin/reboot"} {
+		if hook.OnACLCheck(cl, topic, true) {
+			t.Fatalf("publish to %s without a rule was allowed", topic)
+		}
+	}
+
+	// Denials to different topics share one series per user and action
+	expected := `
+# HELP mqtt_acl_denials_total Total number of ACL denials by action (pub, sub)
+# TYPE mqtt_acl_denials_total counter
+mqtt_acl_denials_total{action="pub"} 2
+# HELP mqtt_acl_denied_total Total number of ACL denials by username (security monitoring; topics are left out to bound cardinality)
+# TYPE mqtt_acl_denied_total counter
+mqtt_acl_denied_total{action="pub",username="sensor"} 2
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "mqtt_acl_denials_total", "mqtt_acl_denied_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDenialLogger_Samples(t *testing.T) {
+	d := &denialLogger{interval: time.Hour}
+
+	d.log("sensor", "device-1", "admin/reset", "pub")
+	d.log("sensor", "device-1", "admin/reset", "pub")
+	d.log("sensor", "device-1", "admin/reset", "pub")
+	if d.suppressed != 2 {
+		t.Errorf("suppressed = %d, want 2 within the interval", d.suppressed)
+	}
+
+	// Once the interval has passed the next denial is logged and the count resets
+	d.last = time.Now().Add(-2 * time.Hour)
+	d.log("sensor", "device-1", "admin/reset", "pub")
+	if d.suppressed != 0 {
+		t.Errorf("suppressed = %d, want 0 after logging", d.suppressed)
+	}
+}
+
+func TestACLHook_AnonymousACL(t *testing.T) {
+	checker := NewMockACLChecker()
+	checker.AddRule("sensor", "private/data", "sub", true)
+
+	anonymous, err := storage.ParseStaticACL("public/#:sub,devices/${clientid}/#:pub")
+	if err != nil {
+		t.Fatalf("ParseStaticACL() error = %v", err)
+	}
+
+	hook := NewACLHook(checker)
+	hook.SetAnonymousACL(anonymous)
+
+	anon := &mqtt.Client{ID: "dev-1"}
+	tests := []struct {
+		name  string
+		topic string
+		write bool
+		want  bool
+	}{
+		{"subscribe to public topic", "public/news", false, true},
+		{"publish to own device topic", "devices/dev-1/temp", true, true},
+		{"publish to another device", "devices/dev-2/temp", true, false},
+		{"publish to public topic", "public/news", true, false},
+		{"subscribe to private topic", "private/data", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hook.OnACLCheck(anon, tt.topic, tt.write); got != tt.want {
+				t.Errorf("OnACLCheck(%q, write=%v) = %v, want %v", tt.topic, tt.write, got, tt.want)
+			}
+		})
+	}
+
+	// Authenticated clients still use the regular checker
+	user := &mqtt.Client{ID: "dev-1", Properties: mqtt.ClientProperties{Username: []byte("sensor")}}
+	if !hook.OnACLCheck(user, "private/data", false) {
+		t.Error("authenticated client denied a topic its rules allow")
+	}
+	if hook.OnACLCheck(user, "public/news", false) {
+		t.Error("authenticated client granted a topic only the anonymous ACL allows")
+	}
+}
+
+func TestACLHook_UnmatchedAudit(t *testing.T) {
+	checker, err := storage.ParseStaticACL("sensors/#:sub")
+	if err != nil {
+		t.Fatalf("ParseStaticACL() error = %v", err)
+	}
+
+	hook := NewACLHook(checker)
+	tracker := NewUnmatchedTracker(10)
+	hook.SetUnmatchedTracking(tracker)
+
+	cl := &mqtt.Client{ID: "dev-1", Properties: mqtt.ClientProperties{Username: []byte("sensor")}}
+
+	// No rule matches: recorded
+	if hook.OnACLCheck(cl, "actuators/valve", true) {
+		t.Fatal("OnACLCheck() allowed a publish no rule matches")
+	}
+	hook.OnACLCheck(cl, "actuators/valve", true)
+
+	// A rule matches but grants another action: not recorded
+	if hook.OnACLCheck(cl, "sensors/temp", true) {
+		t.Fatal("OnACLCheck() allowed a publish to a subscribe-only topic")
+	}
+
+	attempts := tracker.Unmatched()["sensor"]
+	if len(attempts) != 1 {
+		t.Fatalf("unmatched attempts = %+v, want 1 entry", attempts)
+	}
+	got := attempts[0]
+	if got.Topic != "actuators/valve" || got.Action != "pub" || got.ClientID != "dev-1" || got.Count != 2 {
+		t.Errorf("unmatched attempt = %+v, want actuators/valve pub by dev-1 twice", got)
+	}
+}
+
+// recordingRejectionHandler collects rejected publishes
+type recordingRejectionHandler struct {
+	rejected []string
+}
+
+func (r *recordingRejectionHandler) RejectPublish(cl *mqtt.Client, topic, reason string) {
+	r.rejected = append(r.rejected, cl.ID+"|"+topic+"|"+reason)
+}
+
+func TestACLHook_PublishRejectionHandler(t *testing.T) {
+	checker, err := storage.ParseStaticACL("sensors/#:pubsub")
+	if err != nil {
+		t.Fatalf("ParseStaticACL() error = %v", err)
+	}
+
+	hook := NewACLHook(checker)
+	handler := &recordingRejectionHandler{}
+	hook.SetPublishRejectionHandler(handler)
+
+	cl := &mqtt.Client{ID: "dev-1", Properties: mqtt.ClientProperties{Username: []byte("sensor")}}
+
+	hook.OnACLCheck(cl, "sensors/temp", true)     // allowed
+	hook.OnACLCheck(cl, "actuators/valve", false) // denied subscribe: not a publish
+	hook.OnACLCheck(cl, "actuators/valve", true)  // denied publish
+
+	want := []string{"dev-1|actuators/valve|" + RejectReasonACLDenied}
+	if len(handler.rejected) != len(want) || handler.rejected[0] != want[0] {
+		t.Errorf("rejected = %v, want %v", handler.rejected, want)
+	}
+}
+
+func TestACLHook_Allows_NoSideEffects(t *testing.T) {
+	checker, err := storage.ParseStaticACL("sensors/#:pubsub")
+	if err != nil {
+		t.Fatalf("ParseStaticACL() error = %v", err)
+	}
+
+	reg := prometheus.NewRegistry()
+	hook := NewACLHook(checker)
+	hook.SetMetrics(bromqmqtt.NewPrometheusMetricsWithRegistry(reg))
+	handler := &recordingRejectionHandler{}
+	hook.SetPublishRejectionHandler(handler)
+	hook.SetUnmatchedTracking(NewUnmatchedTracker(10))
+
+	cl := &mqtt.Client{ID: "dev-1", Properties: mqtt.ClientProperties{Username: []byte("sensor")}}
+	if allowed, err := hook.Allows(cl, "sensors/temp", "pub"); err != nil || !allowed {
+		t.Errorf("Allows(sensors/temp) = %v, %v; want true", allowed, err)
+	}
+	if allowed, err := hook.Allows(cl, "$SYS/broker/uptime", "pub"); err != nil || allowed {
+		t.Errorf("Allows($SYS/broker/uptime) = %v, %v; want false", allowed, err)
+	}
+
+	if len(handler.rejected) != 0 {
+		t.Errorf("Allows() dead-lettered %v", handler.rejected)
+	}
+	if n, err := testutil.GatherAndCount(reg, "mqtt_acl_checks_total", "mqtt_acl_denied_total", "mqtt_acl_denials_total"); err != nil || n != 0 {
+		t.Errorf("Allows() recorded %d ACL metric series (err %v), want none", n, err)
+	}
+	if got := hook.Unmatched().Unmatched(); len(got) != 0 {
+		t.Errorf("Allows() tracked unmatched attempts %+v", got)
+	}
+}
+
+func TestACLHook_RetainPermission(t *testing.T) {
+	checker, err := storage.NewStaticACL([]storage.StaticACLRule{
+		{Topic: "status/#", Permission: "pub", Retain: true},
+		{Topic: "sensors/#", Permission: "pub"},
+	})
+	if err != nil {
+		t.Fatalf("NewStaticACL() error = %v", err)
+	}
+
+	hook := NewACLHook(checker)
+	cl := &mqtt.Client{ID: "dev-1", Properties: mqtt.ClientProperties{Username: []byte("sensor")}}
+	publish := func(topic string) packets.Packet {
+		pk := packets.Packet{FixedHeader: packets.FixedHeader{Type: packets.Publish, Retain: true}, TopicName: topic}
+		pk, err := hook.OnPublish(cl, pk)
+		if err != nil {
+			t.Fatalf("OnPublish(%s) error = %v", topic, err)
+		}
+		return pk
+	}
+
+	// Not enforced by default
+	if hook.Provides(mqtt.OnPublish) {
+		t.Error("Provides(OnPublish) = true without retain permission enabled")
+	}
+	if pk := publish("sensors/temp"); !pk.FixedHeader.Retain {
+		t.Error("retain flag stripped while retain permission is not enforced")
+	}
+
+	hook.SetRetainPermission(true)
+	if !hook.Provides(mqtt.OnPublish) {
+		t.Error("Provides(OnPublish) = false with retain permission enabled")
+	}
+	if pk := publish("status/online"); !pk.FixedHeader.Retain {
+		t.Error("retain flag stripped on a topic with retain permission")
+	}
+	pk := publish("sensors/temp")
+	if pk.FixedHeader.Retain {
+		t.Error("retain flag kept on a topic without retain permission")
+	}
+	if pk.TopicName != "sensors/temp" {
+		t.Errorf("TopicName = %q, want the publish to go through", pk.TopicName)
+	}
+}
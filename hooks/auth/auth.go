@@ -2,6 +2,7 @@ package auth
 
 import (
 	"bytes"
+	"errors"
 	"log/slog"
 
 	mqtt "github.com/mochi-mqtt/server/v2"
@@ -14,6 +15,8 @@ type AuthHook struct {
 	authenticator  Authenticator
 	metrics        AuthMetrics
 	allowAnonymous bool
+	listenerPolicy map[string]bool // Listener ID -> anonymous allowed (overrides allowAnonymous)
+	ipFilter       *IPFilter       // Applies to every client (nil = any address)
 }
 
 // Authenticator interface for user authentication
@@ -24,7 +27,36 @@ type Authenticator interface {
 // AuthMetrics interface for recording authentication metrics
 type AuthMetrics interface {
 	RecordAuthAttempt(username, result string)
-	RecordAuthFailure(username string)
+	RecordAuthFailure(username, reason string)
+}
+
+// Authentication failure reasons, used as metric labels
+const (
+	ReasonBadPassword       = "bad_password"
+	ReasonUnknownUser       = "unknown_user"
+	ReasonAnonymousDisabled = "anonymous_disabled"
+	ReasonIPDenied          = "ip_denied"
+	ReasonRateLimited       = "rate_limited" // Recorded by the broker's reconnect throttle (MQTT_RECONNECT_LIMIT)
+	ReasonError             = "error"        // The authenticator itself failed, e.g. the database is unreachable
+
+	// ReasonDisabledUser is reserved: MQTT users can't be disabled yet, so nothing emits it
+	ReasonDisabledUser = "disabled_user"
+)
+
+// FailureReasoner is implemented by authenticator errors that know why
+// authentication failed (one of the Reason constants)
+type FailureReasoner interface {
+	AuthFailureReason() string
+}
+
+// failureReason classifies an authentication error
+// Errors that don't say why the credentials were rejected are authenticator failures
+func failureReason(err error) string {
+	var reasoner FailureReasoner
+	if errors.As(err, &reasoner) {
+		return reasoner.AuthFailureReason()
+	}
+	return ReasonError
 }
 
 // NewAuthHook creates a new authentication hook
@@ -40,6 +72,26 @@ func (h *AuthHook) SetMetrics(metrics AuthMetrics) {
 	h.metrics = metrics
 }
 
+// SetAnonymousListeners sets which listeners accept anonymous clients
+// Listeners missing from the policy fall back to the allowAnonymous setting
+func (h *AuthHook) SetAnonymousListeners(policy map[string]bool) {
+	h.listenerPolicy = policy
+}
+
+// SetIPFilter restricts which source addresses may connect at all
+// Users implementing IPRestricted are additionally checked against their own lists
+func (h *AuthHook) SetIPFilter(filter *IPFilter) {
+	h.ipFilter = filter
+}
+
+// anonymousAllowed reports whether anonymous clients may connect on a listener
+func (h *AuthHook) anonymousAllowed(listener string) bool {
+	if allowed, ok := h.listenerPolicy[listener]; ok {
+		return allowed
+	}
+	return h.allowAnonymous
+}
+
 // ID returns the hook identifier
 func (h *AuthHook) ID() string {
 	return "database-auth"
@@ -58,17 +110,20 @@ func (h *AuthHook) OnConnectAuthenticate(cl *mqtt.Client, pk packets.Packet) boo
 	username := string(pk.Connect.Username)
 	password := string(pk.Connect.Password)
 
+	if !ipAllowed(h.ipFilter, cl.Net.Remote) {
+		slog.Warn("Connection rejected - source address not allowed", "client_id", cl.ID, "remote", cl.Net.Remote)
+		h.recordFailure(username, ReasonIPDenied)
+		return false
+	}
+
 	// Check anonymous connections
 	if username == "" {
-		if !h.allowAnonymous {
-			slog.Warn("Anonymous connection rejected - anonymous access disabled", "client_id", cl.ID)
-			if h.metrics != nil {
-				h.metrics.RecordAuthAttempt("anonymous", "failure")
-				h.metrics.RecordAuthFailure("anonymous")
-			}
+		if !h.anonymousAllowed(cl.Net.Listener) {
+			slog.Warn("Anonymous connection rejected - anonymous access disabled", "client_id", cl.ID, "listener", cl.Net.Listener)
+			h.recordFailure(username, ReasonAnonymousDisabled)
 			return false
 		}
-		slog.Debug("Client connecting anonymously", "client_id", cl.ID)
+		slog.Debug("Client connecting anonymously", "client_id", cl.ID, "listener", cl.Net.Listener)
 		if h.metrics != nil {
 			h.metrics.RecordAuthAttempt("anonymous", "success")
 		}
@@ -78,23 +133,33 @@ func (h *AuthHook) OnConnectAuthenticate(cl *mqtt.Client, pk packets.Packet) boo
 	// Authenticate user
 	user, err := h.authenticator.AuthenticateUser(username, password)
 	if err != nil {
-		slog.Warn("Authentication failed", "username", username, "error", err)
-		if h.metrics != nil {
-			h.metrics.RecordAuthAttempt(username, "failure")
-			h.metrics.RecordAuthFailure(username)
-		}
+		reason := failureReason(err)
+		slog.Warn("Authentication failed", "username", username, "reason", reason, "error", err)
+		h.recordFailure(username, reason)
 		return false
 	}
 
 	if user == nil {
 		slog.Warn("Authentication failed - user not found", "username", username)
-		if h.metrics != nil {
-			h.metrics.RecordAuthAttempt(username, "failure")
-			h.metrics.RecordAuthFailure(username)
-		}
+		h.recordFailure(username, ReasonUnknownUser)
 		return false
 	}
 
+	if restricted, ok := user.(IPRestricted); ok {
+		filter, err := userIPFilter(restricted)
+		if err != nil {
+			// Fail closed rather than ignore a list we can't read
+			slog.Error("Invalid IP filter on MQTT user", "username", username, "error", err)
+			h.recordFailure(username, ReasonIPDenied)
+			return false
+		}
+		if !ipAllowed(filter, cl.Net.Remote) {
+			slog.Warn("Authentication failed - source address not allowed for user", "username", username, "remote", cl.Net.Remote)
+			h.recordFailure(username, ReasonIPDenied)
+			return false
+		}
+	}
+
 	// Username is already stored in cl.Properties.Username by mochi-mqtt
 	slog.Info("Client authenticated", "client_id", cl.ID, "username", username)
 	if h.metrics != nil {
@@ -103,6 +168,28 @@ func (h *AuthHook) OnConnectAuthenticate(cl *mqtt.Client, pk packets.Packet) boo
 	return true
 }
 
+// recordFailure records a failed authentication attempt
+func (h *AuthHook) recordFailure(username, reason string) {
+	if h.metrics == nil {
+		return
+	}
+	if username == "" {
+		username = "anonymous"
+	}
+	h.metrics.RecordAuthAttempt(username, "failure")
+	h.metrics.RecordAuthFailure(username, reason)
+}
+
+// ipAllowed reports whether a client's remote address passes filter
+// Addresses that can't be parsed only pass when there is no filter
+func ipAllowed(filter *IPFilter, remote string) bool {
+	if filter == nil {
+		return true
+	}
+	addr, ok := remoteAddr(remote)
+	return ok && filter.Allows(addr)
+}
+
 // OnConnect is called when a client successfully connects
 func (h *AuthHook) OnConnect(cl *mqtt.Client, pk packets.Packet) error {
 	username := string(pk.Connect.Username)
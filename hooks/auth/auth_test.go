@@ -1,10 +1,17 @@
 package auth
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	mqtt "github.com/mochi-mqtt/server/v2"
 	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	bromqmqtt "github/bromq-dev/bromq/internal/mqtt"
+	"github/bromq-dev/bromq/internal/storage"
 )
 
 // MockAuthenticator implements the Authenticator interface for testing
@@ -146,6 +153,35 @@ func TestAuthHook_OnConnectAuthenticate(t *testing.T) {
 	}
 }
 
+func TestAuthHook_AnonymousListeners(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       bromqmqtt.Config
+		listener  string
+		wantAllow bool
+	}{
+		{name: "ws allowed", cfg: bromqmqtt.Config{AnonymousListeners: "ws"}, listener: "ws", wantAllow: true},
+		{name: "tcp requires auth", cfg: bromqmqtt.Config{AnonymousListeners: "ws"}, listener: "tcp", wantAllow: false},
+		{name: "tcp allowed", cfg: bromqmqtt.Config{AnonymousListeners: "tcp"}, listener: "tcp", wantAllow: true},
+		{name: "ws requires auth", cfg: bromqmqtt.Config{AnonymousListeners: "tcp"}, listener: "ws", wantAllow: false},
+		{name: "listeners override global allow", cfg: bromqmqtt.Config{AllowAnonymous: true, AnonymousListeners: "ws"}, listener: "tcp", wantAllow: false},
+		{name: "global allow without listeners", cfg: bromqmqtt.Config{AllowAnonymous: true}, listener: "tcp", wantAllow: true},
+		{name: "global deny without listeners", cfg: bromqmqtt.Config{}, listener: "ws", wantAllow: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := NewAuthHook(NewMockAuthenticator(), tt.cfg.AllowAnonymous)
+			hook.SetAnonymousListeners(tt.cfg.AnonymousPolicy())
+
+			cl := &mqtt.Client{ID: "anon-client", Net: mqtt.ClientConnection{Listener: tt.listener}}
+			if got := hook.OnConnectAuthenticate(cl, packets.Packet{}); got != tt.wantAllow {
+				t.Errorf("OnConnectAuthenticate() on %s = %v, want %v", tt.listener, got, tt.wantAllow)
+			}
+		})
+	}
+}
+
 func TestAuthHook_OnConnect(t *testing.T) {
 	auth := NewMockAuthenticator()
 	hook := NewAuthHook(auth, true) // Allow anonymous for this test
@@ -186,3 +222,142 @@ func TestAuthHook_OnConnect(t *testing.T) {
 		})
 	}
 }
+
+// storageAuthenticator fails the way storage.DB.AuthenticateUser does
+type storageAuthenticator struct {
+	users map[string]string
+}
+
+func (a *storageAuthenticator) AuthenticateUser(username, password string) (interface{}, error) {
+	if username == "down" {
+		return nil, errors.New("database is unreachable")
+	}
+	stored, ok := a.users[username]
+	if !ok {
+		return nil, storage.ErrUnknownMQTTUser
+	}
+	if stored != password {
+		return nil, storage.ErrBadPassword
+	}
+	return username, nil
+}
+
+func TestAuthHook_FailureReasonMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	metrics := bromqmqtt.NewPrometheusMetricsWithRegistry(reg)
+	hook := NewAuthHook(&storageAuthenticator{users: map[string]string{"sensor": "secret"}}, false)
+	hook.SetMetrics(metrics)
+
+	connect := func(username, password string) {
+		pk := packets.Packet{Connect: packets.ConnectParams{Username: []byte(username), Password: []byte(password)}}
+		hook.OnConnectAuthenticate(&mqtt.Client{ID: "test-client"}, pk)
+	}
+
+	connect("sensor", "wrong")
+	connect("sensor", "wrong")
+	connect("ghost", "secret")
+	connect("down", "secret")
+	connect("", "")
+	connect("sensor", "secret")
+
+	expected := `
+# HELP mqtt_auth_failure_reasons_total Total number of authentication failures by reason (bad_password, unknown_user, anonymous_disabled, ip_denied, rate_limited, error)
+# TYPE mqtt_auth_failure_reasons_total counter
+mqtt_auth_failure_reasons_total{reason="anonymous_disabled"} 1
+mqtt_auth_failure_reasons_total{reason="bad_password"} 2
+mqtt_auth_failure_reasons_total{reason="error"} 1
+mqtt_auth_failure_reasons_total{reason="unknown_user"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "mqtt_auth_failure_reasons_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+// restrictedUser is an authenticated user carrying its own IP lists
+type restrictedUser struct {
+	allow, deny []string
+}
+
+func (u restrictedUser) IPRanges() (allow, deny []string) { return u.allow, u.deny }
+
+// restrictedAuthenticator authenticates every username to a fixed restrictedUser
+type restrictedAuthenticator struct {
+	user restrictedUser
+}
+
+func (a *restrictedAuthenticator) AuthenticateUser(username, password string) (interface{}, error) {
+	return a.user, nil
+}
+
+func TestAuthHook_IPFilter(t *testing.T) {
+	global, err := ParseIPFilter([]string{"10.0.0.0/8", "192.168.1.0/24"}, []string{"10.0.99.0/24"})
+	if err != nil {
+		t.Fatalf("ParseIPFilter() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		user      restrictedUser
+		remote    string
+		wantAllow bool
+	}{
+		{name: "allowed CIDR passes", remote: "10.1.2.3:51234", wantAllow: true},
+		{name: "address outside global allowlist denied", remote: "203.0.113.7:51234", wantAllow: false},
+		{name: "global denylist wins", remote: "10.0.99.5:51234", wantAllow: false},
+		{name: "unparseable remote denied", remote: "pipe", wantAllow: false},
+		{name: "user allowlist passes", user: restrictedUser{allow: []string{"10.1.0.0/16"}}, remote: "10.1.2.3:51234", wantAllow: true},
+		{name: "user allowlist narrows global", user: restrictedUser{allow: []string{"10.1.0.0/16"}}, remote: "10.2.0.1:51234", wantAllow: false},
+		{name: "user denylist", user: restrictedUser{deny: []string{"192.168.1.50"}}, remote: "192.168.1.50:1883", wantAllow: false},
+		{name: "ipv6 mapped ipv4", user: restrictedUser{allow: []string{"192.168.1.0/24"}}, remote: "[::ffff:192.168.1.9]:1883", wantAllow: true},
+		{name: "invalid user list fails closed", user: restrictedUser{allow: []string{"not-a-cidr"}}, remote: "10.1.2.3:51234", wantAllow: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := NewAuthHook(&restrictedAuthenticator{user: tt.user}, false)
+			hook.SetIPFilter(global)
+
+			cl := &mqtt.Client{ID: "device-1", Net: mqtt.ClientConnection{Remote: tt.remote}}
+			pk := packets.Packet{Connect: packets.ConnectParams{Username: []byte("sensor"), Password: []byte("secret")}}
+			if got := hook.OnConnectAuthenticate(cl, pk); got != tt.wantAllow {
+				t.Errorf("OnConnectAuthenticate() from %s = %v, want %v", tt.remote, got, tt.wantAllow)
+			}
+		})
+	}
+}
+
+// cachedUserAuthenticator authenticates every username to the same user
+type cachedUserAuthenticator struct {
+	user *storage.MQTTUser
+}
+
+func (a *cachedUserAuthenticator) AuthenticateUser(username, password string) (interface{}, error) {
+	return a.user, nil
+}
+
+func TestAuthHook_IPFilterCachedWithUser(t *testing.T) {
+	user := &storage.MQTTUser{Username: "sensor", AllowedCIDRs: []string{"10.1.0.0/16"}}
+	hook := NewAuthHook(&cachedUserAuthenticator{user: user}, false)
+
+	connect := func(remote string) bool {
+		cl := &mqtt.Client{ID: "device-1", Net: mqtt.ClientConnection{Remote: remote}}
+		pk := packets.Packet{Connect: packets.ConnectParams{Username: []byte("sensor"), Password: []byte("secret")}}
+		return hook.OnConnectAuthenticate(cl, pk)
+	}
+
+	if !connect("10.1.2.3:51234") {
+		t.Fatal("OnConnectAuthenticate() = false, want true from the allowed network")
+	}
+	if _, ok := user.LoadIPFilter(); !ok {
+		t.Fatal("parsed IP filter not kept with the user")
+	}
+
+	// The held filter is used as is; the lists are not parsed again
+	user.AllowedCIDRs = []string{"not-a-cidr"}
+	if !connect("10.1.2.3:51234") {
+		t.Error("OnConnectAuthenticate() = false, want the held filter to allow 10.1.2.3")
+	}
+	if connect("10.2.0.1:51234") {
+		t.Error("OnConnectAuthenticate() = true, want the held filter to deny 10.2.0.1")
+	}
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// IPFilter restricts connections to source addresses in allowed networks
+// A deny entry always wins; an empty allow list allows every address not denied
+type IPFilter struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// IPRestricted is implemented by authenticated users that carry their own
+// IP allow/deny lists (CIDRs or single addresses)
+type IPRestricted interface {
+	IPRanges() (allow, deny []string)
+}
+
+// IPFilterCache is implemented by IPRestricted users that can keep the filter
+// parsed from their lists, so a cached user's lists are parsed only once
+type IPFilterCache interface {
+	LoadIPFilter() (filter any, ok bool)
+	StoreIPFilter(filter any)
+}
+
+// userIPFilter returns the filter for a user's own lists, reusing the one the
+// user holds if it implements IPFilterCache
+func userIPFilter(user IPRestricted) (*IPFilter, error) {
+	cache, canCache := user.(IPFilterCache)
+	if canCache {
+		if filter, ok := cache.LoadIPFilter(); ok {
+			return filter.(*IPFilter), nil
+		}
+	}
+
+	filter, err := ParseIPFilter(user.IPRanges())
+	if err != nil {
+		return nil, err
+	}
+	if canCache {
+		cache.StoreIPFilter(filter)
+	}
+	return filter, nil
+}
+
+// ParseIPFilter builds a filter from CIDRs (10.0.0.0/8) or single addresses
+// (192.168.1.5). It returns nil when both lists are empty
+func ParseIPFilter(allow, deny []string) (*IPFilter, error) {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, nil
+	}
+
+	f := &IPFilter{}
+	var err error
+	if f.allow, err = parsePrefixes(allow); err != nil {
+		return nil, err
+	}
+	if f.deny, err = parsePrefixes(deny); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// SplitCIDRs splits a comma-separated list, dropping empty entries
+func SplitCIDRs(list string) []string {
+	var cidrs []string
+	for _, cidr := range strings.Split(list, ",") {
+		if cidr = strings.TrimSpace(cidr); cidr != "" {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	return cidrs
+}
+
+// parsePrefixes parses each entry as a CIDR, or as a single-address prefix
+func parsePrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR or IP address: %s", entry)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// Allows reports whether addr may connect. A nil filter allows everything
+func (f *IPFilter) Allows(addr netip.Addr) bool {
+	if f == nil {
+		return true
+	}
+	addr = addr.Unmap() // IPv4 clients on a dual-stack listener show up as ::ffff:a.b.c.d
+	for _, prefix := range f.deny {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, prefix := range f.allow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteAddr extracts the IP address from a client's remote address (host:port)
+func remoteAddr(remote string) (netip.Addr, bool) {
+	host := remote
+	if h, _, err := net.SplitHostPort(remote); err == nil {
+		host = h
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr, true
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestParseIPFilter(t *testing.T) {
+	if f, err := ParseIPFilter(nil, nil); f != nil || err != nil {
+		t.Errorf("ParseIPFilter(nil, nil) = %v, %v; want nil, nil", f, err)
+	}
+	if _, err := ParseIPFilter([]string{"10.0.0.0/33"}, nil); err == nil {
+		t.Error("ParseIPFilter() should reject an invalid prefix length")
+	}
+	if _, err := ParseIPFilter(nil, []string{"example.com"}); err == nil {
+		t.Error("ParseIPFilter() should reject a hostname")
+	}
+}
+
+func TestIPFilter_Allows(t *testing.T) {
+	f, err := ParseIPFilter([]string{" 10.0.0.1/8 ", "2001:db8::/32", "192.168.1.5"}, []string{"10.0.99.0/24"})
+	if err != nil {
+		t.Fatalf("ParseIPFilter() error = %v", err)
+	}
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"10.0.99.1", false}, // Denied even though 10.0.0.0/8 allows it
+		{"192.168.1.5", true},
+		{"192.168.1.6", false},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+		{"::ffff:10.1.2.3", true},
+	}
+	for _, tt := range tests {
+		if got := f.Allows(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("Allows(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+
+	denyOnly, _ := ParseIPFilter(nil, []string{"203.0.113.0/24"})
+	if !denyOnly.Allows(netip.MustParseAddr("198.51.100.1")) {
+		t.Error("a filter without an allowlist should allow addresses it doesn't deny")
+	}
+
+	var none *IPFilter
+	if !none.Allows(netip.MustParseAddr("203.0.113.1")) {
+		t.Error("a nil filter should allow everything")
+	}
+}
+
+func TestSplitCIDRs(t *testing.T) {
+	got := SplitCIDRs(" 10.0.0.0/8, ,192.168.1.5 ,")
+	if len(got) != 2 || got[0] != "10.0.0.0/8" || got[1] != "192.168.1.5" {
+		t.Errorf("SplitCIDRs() = %q", got)
+	}
+}
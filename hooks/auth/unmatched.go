@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultUnmatchedPerUser is how many distinct unmatched attempts are kept per user
+const DefaultUnmatchedPerUser = 50
+
+// ACLRuleMatcher is an optional extension of ACLChecker that reports whether
+// any rule matches a topic regardless of permission, so denials caused by a
+// missing rule can be told apart from rules granting a different action
+type ACLRuleMatcher interface {
+	HasMatchingACLRule(username, clientID, topic string) (bool, error)
+}
+
+// UnmatchedAttempt is a publish or subscribe denied because no ACL rule
+// matched the topic. Repeated attempts on the same topic and action are
+// folded into one entry
+type UnmatchedAttempt struct {
+	Topic     string    `json:"topic"`
+	Action    string    `json:"action"`
+	ClientID  string    `json:"client_id"` // Client of the most recent attempt
+	Count     int64     `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// UnmatchedTracker keeps recent unmatched ACL attempts per user in memory
+// Each user's buffer is capped; the least recently seen entry is evicted first
+type UnmatchedTracker struct {
+	mu      sync.Mutex
+	users   map[string][]*UnmatchedAttempt
+	perUser int
+}
+
+// NewUnmatchedTracker creates a tracker keeping up to perUser entries per user
+func NewUnmatchedTracker(perUser int) *UnmatchedTracker {
+	if perUser <= 0 {
+		perUser = DefaultUnmatchedPerUser
+	}
+	return &UnmatchedTracker{
+		users:   make(map[string][]*UnmatchedAttempt),
+		perUser: perUser,
+	}
+}
+
+// Record adds an unmatched attempt for username
+func (t *UnmatchedTracker) Record(username, clientID, topic, action string) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Entries are kept least recently seen first
+	entries := t.users[username]
+	for i, entry := range entries {
+		if entry.Topic == topic && entry.Action == action {
+			entry.ClientID = clientID
+			entry.Count++
+			entry.LastSeen = now
+			t.users[username] = append(append(entries[:i], entries[i+1:]...), entry)
+			return
+		}
+	}
+
+	if len(entries) >= t.perUser {
+		entries = append(entries[:0], entries[1:]...)
+	}
+
+	t.users[username] = append(entries, &UnmatchedAttempt{
+		Topic:     topic,
+		Action:    action,
+		ClientID:  clientID,
+		Count:     1,
+		FirstSeen: now,
+		LastSeen:  now,
+	})
+}
+
+// Unmatched returns a copy of the recorded attempts keyed by username,
+// most recently seen first
+func (t *UnmatchedTracker) Unmatched() map[string][]UnmatchedAttempt {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make(map[string][]UnmatchedAttempt, len(t.users))
+	for username, entries := range t.users {
+		attempts := make([]UnmatchedAttempt, len(entries))
+		for i, entry := range entries {
+			attempts[len(entries)-1-i] = *entry
+		}
+		result[username] = attempts
+	}
+	return result
+}
@@ -0,0 +1,25 @@
+package auth
+
+import "testing"
+
+func TestUnmatchedTracker_Cap(t *testing.T) {
+	tracker := NewUnmatchedTracker(2)
+
+	tracker.Record("alice", "c1", "a", "pub")
+	tracker.Record("alice", "c1", "b", "pub")
+	tracker.Record("alice", "c1", "a", "pub") // refreshes a, so b is now the oldest
+	tracker.Record("alice", "c1", "c", "sub")
+	tracker.Record("bob", "c2", "a", "pub")
+
+	unmatched := tracker.Unmatched()
+	alice := unmatched["alice"]
+	if len(alice) != 2 {
+		t.Fatalf("alice attempts = %+v, want 2", alice)
+	}
+	if alice[0].Topic != "c" || alice[1].Topic != "a" || alice[1].Count != 2 {
+		t.Errorf("alice attempts = %+v, want c then a (count 2)", alice)
+	}
+	if len(unmatched["bob"]) != 1 {
+		t.Errorf("bob attempts = %+v, want 1", unmatched["bob"])
+	}
+}
@@ -30,6 +30,7 @@ func (h *BridgeHook) ID() string {
 func (h *BridgeHook) Provides(b byte) bool {
 	return bytes.Contains([]byte{
 		mqtt.OnPublish,
+		mqtt.OnPacketEncode,
 	}, []byte{b})
 }
 
@@ -38,11 +39,25 @@ func (h *BridgeHook) Provides(b byte) bool {
 func (h *BridgeHook) OnPublish(cl *mqtt.Client, pk packets.Packet) (packets.Packet, error) {
 	// Loop prevention: Skip forwarding if message originated from a bridge connection
 	// Bridge client IDs are prefixed with "bridge-"
-	if strings.HasPrefix(cl.ID, "bridge-") {
+	if isBridgeClient(cl) {
 		// Message came from a remote broker via bridge, don't forward back
 		return pk, nil
 	}
 
+	if !cl.Net.Inline {
+		// Ordinary clients may not set the loop marker (see loop.go)
+		pk = h.manager.withoutMarker(pk)
+	} else if h.manager.isBridged(pk) {
+		// Skip messages tagged with the loop marker, e.g. bridged messages a
+		// topic route republished
+		return pk, nil
+	}
+
+	// Skip broker stats, published every $SYS interval by the broker itself
+	if cl.Net.Inline && strings.HasPrefix(pk.TopicName, "$SYS/") {
+		return pk, nil
+	}
+
 	// Forward message to bridge manager for outbound routing
 	h.manager.HandleOutboundMessage(
 		pk.TopicName,
@@ -51,6 +66,15 @@ func (h *BridgeHook) OnPublish(cl *mqtt.Client, pk packets.Packet) (packets.Pack
 		pk.FixedHeader.Qos,
 	)
 
-	// Return unchanged packet to continue normal local delivery
+	// Continue normal local delivery
 	return pk, nil
 }
+
+// OnPacketEncode removes the loop marker from messages delivered to clients
+// other than bridges, to which it means nothing
+func (h *BridgeHook) OnPacketEncode(cl *mqtt.Client, pk packets.Packet) packets.Packet {
+	if pk.FixedHeader.Type != packets.Publish || isBridgeClient(cl) {
+		return pk
+	}
+	return h.manager.withoutMarker(pk)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/url"
 	"sync"
 	"time"
@@ -16,19 +17,29 @@ import (
 )
 
 // MessageHandler is called when a message is received from remote broker
-type MessageHandler func(topic string, payload []byte, qos byte, retained bool)
+// userProperties is nil for MQTT v3 bridges
+type MessageHandler func(topic string, payload []byte, qos byte, retained bool, userProperties map[string]string)
+
+// ConnectionHandler is called when the connection to the remote broker comes up
+// (including reconnects) or goes down. err is the cause of a drop, if known
+type ConnectionHandler func(connected bool, err error)
 
 // BridgeClient abstracts MQTT v3 and v5 clients behind a common interface
 type BridgeClient interface {
 	Connect() error
 	Disconnect() error
 	Subscribe(topic string, qos byte, handler MessageHandler) error
-	Publish(topic string, qos byte, retained bool, payload []byte) error
+	Publish(topic string, qos byte, retained bool, payload []byte, userProperties map[string]string) error
 	IsConnected() bool
 }
 
 // NewBridgeClient creates appropriate client based on MQTT version
-func NewBridgeClient(ctx context.Context, bridge *storage.Bridge, clientID string) (BridgeClient, error) {
+// onConnection (optional) is told about every connect and connection loss
+func NewBridgeClient(ctx context.Context, bridge *storage.Bridge, clientID string, onConnection ConnectionHandler) (BridgeClient, error) {
+	if onConnection == nil {
+		onConnection = func(bool, error) {}
+	}
+
 	version := bridge.MQTTVersion
 	if version == "" {
 		version = "5" // Default
@@ -36,9 +47,9 @@ func NewBridgeClient(ctx context.Context, bridge *storage.Bridge, clientID strin
 
 	switch version {
 	case "5":
-		return newV5Client(ctx, bridge, clientID)
+		return newV5Client(ctx, bridge, clientID, onConnection)
 	case "3":
-		return newV3Client(bridge, clientID)
+		return newV3Client(bridge, clientID, onConnection)
 	default:
 		return nil, fmt.Errorf("unsupported MQTT version: %s", version)
 	}
@@ -54,7 +65,7 @@ type v3Client struct {
 	mu        sync.RWMutex
 }
 
-func newV3Client(bridge *storage.Bridge, clientID string) (*v3Client, error) {
+func newV3Client(bridge *storage.Bridge, clientID string, onConnection ConnectionHandler) (*v3Client, error) {
 	opts := pahoV3.NewClientOptions()
 	opts.AddBroker(fmt.Sprintf("tcp://%s:%d", bridge.Host, bridge.Port))
 	opts.SetClientID(clientID)
@@ -78,6 +89,7 @@ func newV3Client(bridge *storage.Bridge, clientID string) (*v3Client, error) {
 		v3c.connected = true
 		v3c.mu.Unlock()
 		slog.Info("MQTT v3 bridge connected", "client_id", clientID)
+		onConnection(true, nil)
 	})
 
 	opts.SetConnectionLostHandler(func(c pahoV3.Client, err error) {
@@ -85,6 +97,7 @@ func newV3Client(bridge *storage.Bridge, clientID string) (*v3Client, error) {
 		v3c.connected = false
 		v3c.mu.Unlock()
 		slog.Warn("MQTT v3 bridge connection lost", "client_id", clientID, "error", err)
+		onConnection(false, err)
 	})
 
 	return v3c, nil
@@ -105,13 +118,14 @@ func (c *v3Client) Disconnect() error {
 
 func (c *v3Client) Subscribe(topic string, qos byte, handler MessageHandler) error {
 	token := c.client.Subscribe(topic, qos, func(client pahoV3.Client, msg pahoV3.Message) {
-		handler(msg.Topic(), msg.Payload(), msg.Qos(), msg.Retained())
+		handler(msg.Topic(), msg.Payload(), msg.Qos(), msg.Retained(), nil)
 	})
 	token.Wait()
 	return token.Error()
 }
 
-func (c *v3Client) Publish(topic string, qos byte, retained bool, payload []byte) error {
+// Publish ignores userProperties (MQTT v3 has no user properties)
+func (c *v3Client) Publish(topic string, qos byte, retained bool, payload []byte, userProperties map[string]string) error {
 	token := c.client.Publish(topic, qos, retained, payload)
 	token.Wait()
 	return token.Error()
@@ -131,11 +145,22 @@ type v5Client struct {
 	cm            *pahoV5.ConnectionManager
 	ctx           context.Context
 	clientID      string
-	subscriptions map[string]MessageHandler // topic -> handler
+	persistent    bool                      // CleanStart=false: the remote broker keeps our session
+	subscriptions map[string]v5Subscription // topic -> subscription
 	mu            sync.RWMutex
 }
 
-func newV5Client(ctx context.Context, bridge *storage.Bridge, clientID string) (*v5Client, error) {
+// v5Subscription is a subscription to restore when the remote broker has no session for us
+type v5Subscription struct {
+	qos     byte
+	handler MessageHandler
+}
+
+// persistentSessionExpiry keeps a persistent bridge session on the remote
+// broker until it is explicitly cleaned, matching MQTT v3 behaviour
+const persistentSessionExpiry = math.MaxUint32
+
+func newV5Client(ctx context.Context, bridge *storage.Bridge, clientID string, onConnection ConnectionHandler) (*v5Client, error) {
 	serverURL, err := url.Parse(fmt.Sprintf("mqtt://%s:%d", bridge.Host, bridge.Port))
 	if err != nil {
 		return nil, fmt.Errorf("invalid broker URL: %w", err)
@@ -144,7 +169,8 @@ func newV5Client(ctx context.Context, bridge *storage.Bridge, clientID string) (
 	v5c := &v5Client{
 		ctx:           ctx,
 		clientID:      clientID,
-		subscriptions: make(map[string]MessageHandler),
+		persistent:    !bridge.CleanSession,
+		subscriptions: make(map[string]v5Subscription),
 	}
 
 	// Validate KeepAlive fits in uint16 (MQTT v5 spec)
@@ -165,12 +191,31 @@ func newV5Client(ctx context.Context, bridge *storage.Bridge, clientID string) (
 			p.ClientID = clientID
 			p.CleanStart = bridge.CleanSession
 			p.KeepAlive = uint16(keepAlive) // #nosec G115 - validated above
+			if !bridge.CleanSession {
+				// Without an expiry interval the remote broker ends the session on disconnect
+				if p.Properties == nil {
+					p.Properties = &pahoV5Client.ConnectProperties{}
+				}
+				expiry := uint32(persistentSessionExpiry)
+				p.Properties.SessionExpiryInterval = &expiry
+			}
 			return p, nil
 		},
 
 		OnConnectionUp: func(cm *pahoV5.ConnectionManager, connack *pahoV5Client.Connack) {
 			slog.Info("MQTT v5 bridge connected", "client_id", clientID, "session_present", connack.SessionPresent)
-			// autopaho with SetResumeSubs handles resubscription automatically
+			// A resumed session still holds our subscriptions (and any queued
+			// messages); otherwise subscribe again so a reconnect keeps routing
+			if !connack.SessionPresent {
+				v5c.resubscribe()
+			}
+			onConnection(true, nil)
+		},
+
+		OnConnectionDown: func() bool {
+			slog.Warn("MQTT v5 bridge connection lost", "client_id", clientID)
+			onConnection(false, nil)
+			return true // Keep reconnecting
 		},
 
 		OnConnectError: func(err error) {
@@ -184,11 +229,11 @@ func newV5Client(ctx context.Context, bridge *storage.Bridge, clientID string) (
 					v5c.mu.RLock()
 					defer v5c.mu.RUnlock()
 
-					for subTopic, handler := range v5c.subscriptions {
+					for subTopic, sub := range v5c.subscriptions {
 						// TODO: Proper topic matching with wildcards
 						// For now, use exact match or prefix check
 						if matchTopic(subTopic, pr.Packet.Topic) {
-							handler(pr.Packet.Topic, pr.Packet.Payload, pr.Packet.QoS, pr.Packet.Retain)
+							sub.handler(pr.Packet.Topic, pr.Packet.Payload, pr.Packet.QoS, pr.Packet.Retain, userPropertiesMap(pr.Packet.Properties))
 							return true, nil // Acknowledge
 						}
 					}
@@ -226,18 +271,27 @@ func (c *v5Client) Disconnect() error {
 
 func (c *v5Client) Subscribe(topic string, qos byte, handler MessageHandler) error {
 	c.mu.Lock()
-	c.subscriptions[topic] = handler
+	c.subscriptions[topic] = v5Subscription{qos: qos, handler: handler}
 	c.mu.Unlock()
 
+	return c.subscribe(topic, qos)
+}
+
+// subscribe sends a SUBSCRIBE for one topic to the remote broker
+func (c *v5Client) subscribe(topic string, qos byte) error {
+	opts := pahoV5Client.SubscribeOptions{
+		Topic:   topic,
+		QoS:     qos,
+		NoLocal: true, // KEY FEATURE: Prevents message loops!
+	}
+	if c.persistent {
+		// Re-subscribing to a resumed session shouldn't replay retained messages
+		opts.RetainHandling = 1
+	}
+
 	// Subscribe with NoLocal to prevent receiving own messages (loop prevention!)
 	_, err := c.cm.Subscribe(context.Background(), &pahoV5Client.Subscribe{
-		Subscriptions: []pahoV5Client.SubscribeOptions{
-			{
-				Topic:   topic,
-				QoS:     qos,
-				NoLocal: true, // KEY FEATURE: Prevents message loops!
-			},
-		},
+		Subscriptions: []pahoV5Client.SubscribeOptions{opts},
 	})
 
 	if err != nil {
@@ -248,16 +302,53 @@ func (c *v5Client) Subscribe(topic string, qos byte, handler MessageHandler) err
 	return nil
 }
 
-func (c *v5Client) Publish(topic string, qos byte, retained bool, payload []byte) error {
+// resubscribe restores all subscriptions after connecting without a session
+func (c *v5Client) resubscribe() {
+	c.mu.RLock()
+	subs := make(map[string]byte, len(c.subscriptions))
+	for topic, sub := range c.subscriptions {
+		subs[topic] = sub.qos
+	}
+	c.mu.RUnlock()
+
+	for topic, qos := range subs {
+		if err := c.subscribe(topic, qos); err != nil {
+			slog.Error("MQTT v5 bridge resubscribe failed", "client_id", c.clientID, "topic", topic, "error", err)
+		}
+	}
+}
+
+func (c *v5Client) Publish(topic string, qos byte, retained bool, payload []byte, userProperties map[string]string) error {
+	var props *pahoV5Client.PublishProperties
+	if len(userProperties) > 0 {
+		props = &pahoV5Client.PublishProperties{}
+		for key, value := range userProperties {
+			props.User.Add(key, value)
+		}
+	}
+
 	_, err := c.cm.Publish(context.Background(), &pahoV5Client.Publish{
-		Topic:   topic,
-		QoS:     qos,
-		Retain:  retained,
-		Payload: payload,
+		Topic:      topic,
+		QoS:        qos,
+		Retain:     retained,
+		Payload:    payload,
+		Properties: props,
 	})
 	return err
 }
 
+// userPropertiesMap converts received publish properties to a map (last value wins)
+func userPropertiesMap(props *pahoV5Client.PublishProperties) map[string]string {
+	if props == nil || len(props.User) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(props.User))
+	for _, prop := range props.User {
+		m[prop.Key] = prop.Value
+	}
+	return m
+}
+
 func (c *v5Client) IsConnected() bool {
 	// autopaho manages connection state internally
 	// We'll use AwaitConnection with a short timeout to check
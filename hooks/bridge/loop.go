@@ -0,0 +1,82 @@
+package bridge
+
+import (
+	"strings"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// DefaultLoopMarker is the MQTT 5 user property that tags bridged messages
+const DefaultLoopMarker = "bromq-bridge-origin"
+
+// Config holds settings shared by all bridges
+type Config struct {
+	LoopMarker string `env:"BRIDGE_LOOP_MARKER" flag:"bridge-loop-marker" default:"bromq-bridge-origin" desc:"MQTT 5 user property used to tag bridged messages so ones that loop back are dropped"`
+}
+
+// Loop prevention works in two places:
+//   - Outbound messages are published with the loop marker set to this broker's
+//     instance ID, and inbound messages carrying our own ID are dropped because
+//     they left this broker through a bridge and are coming back
+//   - Inbound messages are injected locally with the marker set, and the bridge
+//     hook never forwards a local message that carries it
+//
+// The marker is internal: it is stripped from messages published by ordinary
+// clients, so they can't stop a message being forwarded or have it dropped
+// remotely, and from messages delivered to them. Only bridge clients (IDs
+// prefixed "bridge-") send and receive it.
+//
+// Markers are only carried over MQTT 5 bridges; v3 bridges rely on the
+// bridge client ID check in BridgeHook alone
+
+// SetLoopMarker changes the user property used for loop detection
+// An empty name keeps the current marker
+func (m *Manager) SetLoopMarker(name string) {
+	if name != "" {
+		m.loopMarker = name
+	}
+}
+
+// isLooped reports whether an inbound message was originally forwarded by this broker
+func (m *Manager) isLooped(userProperties map[string]string) bool {
+	origin, ok := userProperties[m.loopMarker]
+	return ok && origin == m.instanceID
+}
+
+// isBridged reports whether a local packet was injected by a bridge
+func (m *Manager) isBridged(pk packets.Packet) bool {
+	for _, prop := range pk.Properties.User {
+		if prop.Key == m.loopMarker {
+			return true
+		}
+	}
+	return false
+}
+
+// isBridgeClient reports whether cl is a bridge, here or on a remote broker
+// bridged to this one, so it may send and receive the loop marker
+func isBridgeClient(cl *mqtt.Client) bool {
+	return strings.HasPrefix(cl.ID, "bridge-")
+}
+
+// withoutMarker returns pk without the loop marker user property. The
+// properties are copied, as a published packet is shared by its subscribers
+func (m *Manager) withoutMarker(pk packets.Packet) packets.Packet {
+	if !m.isBridged(pk) {
+		return pk
+	}
+	user := make([]packets.UserProperty, 0, len(pk.Properties.User)-1)
+	for _, prop := range pk.Properties.User {
+		if prop.Key != m.loopMarker {
+			user = append(user, prop)
+		}
+	}
+	pk.Properties.User = user
+	return pk
+}
+
+// outboundProperties returns the user properties attached to forwarded messages
+func (m *Manager) outboundProperties() map[string]string {
+	return map[string]string{m.loopMarker: m.instanceID}
+}
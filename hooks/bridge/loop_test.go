@@ -0,0 +1,203 @@
+package bridge
+
+import (
+	"bytes"
+	"testing"
+
+	mqttServer "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+// fakePublish is a message sent through fakeClient
+type fakePublish struct {
+	topic          string
+	qos            byte
+	retained       bool
+	userProperties map[string]string
+}
+
+// fakeClient records publishes instead of sending them to a remote broker
+type fakeClient struct {
+	published []fakePublish
+}
+
+func (c *fakeClient) Connect() error                               { return nil }
+func (c *fakeClient) Disconnect() error                            { return nil }
+func (c *fakeClient) Subscribe(string, byte, MessageHandler) error { return nil }
+func (c *fakeClient) IsConnected() bool                            { return true }
+func (c *fakeClient) Publish(topic string, qos byte, retained bool, _ []byte, props map[string]string) error {
+	c.published = append(c.published, fakePublish{topic: topic, qos: qos, retained: retained, userProperties: props})
+	return nil
+}
+
+// captureHook records packets published on the local server
+type captureHook struct {
+	mqttServer.HookBase
+	packets []packets.Packet
+}
+
+func (h *captureHook) ID() string { return "capture" }
+
+func (h *captureHook) Provides(b byte) bool {
+	return bytes.Contains([]byte{mqttServer.OnPublish}, []byte{b})
+}
+
+func (h *captureHook) OnPublish(_ *mqttServer.Client, pk packets.Packet) (packets.Packet, error) {
+	h.packets = append(h.packets, pk)
+	return pk, nil
+}
+
+// newLoopTestManager builds a manager with one "both" direction bridge backed by a fake client
+func newLoopTestManager(t *testing.T) (*Manager, *BridgeConnection, *fakeClient, *captureHook) {
+	t.Helper()
+
+	server := mqttServer.New(nil)
+	capture := &captureHook{}
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.AddHook(capture, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager(nil, server)
+	fake := &fakeClient{}
+	inlineClient := server.NewClient(nil, "bridge", "bridge-test", true)
+	server.Clients.Add(inlineClient)
+
+	bc := &BridgeConnection{
+		bridge: &storage.Bridge{
+			ID:   1,
+			Name: "test",
+			Topics: []storage.BridgeTopic{
+				{Local: "local/#", Remote: "remote/#", Direction: "both", QoS: 1},
+			},
+		},
+		client:       fake,
+		inlineClient: inlineClient,
+		clientID:     "bridge-test",
+		manager:      m,
+	}
+	m.bridges[1] = bc
+
+	return m, bc, fake, capture
+}
+
+func TestLoopPrevention_ForwardedMessageReentering(t *testing.T) {
+	m, bc, fake, capture := newLoopTestManager(t)
+	hook := NewBridgeHook(m)
+
+	// A local publish is forwarded out with this broker's marker
+	cl := m.server.NewClient(nil, "tcp", "device", false)
+	pk := packets.Packet{
+		FixedHeader: packets.FixedHeader{Type: packets.Publish, Qos: 1},
+		TopicName:   "local/temp",
+		Payload:     []byte("21.5"),
+	}
+	if _, err := hook.OnPublish(cl, pk); err != nil {
+		t.Fatalf("OnPublish() error = %v", err)
+	}
+	if len(fake.published) != 1 {
+		t.Fatalf("published %d messages, want 1", len(fake.published))
+	}
+	props := fake.published[0].userProperties
+	if props[DefaultLoopMarker] != m.instanceID {
+		t.Fatalf("outbound marker = %q, want instance ID %q", props[DefaultLoopMarker], m.instanceID)
+	}
+
+	// The same message coming back through the bridge is dropped
+	bc.handleInboundMessage("remote/temp", pk.Payload, 1, false, props, bc.bridge.Topics[0])
+	if len(capture.packets) != 0 {
+		t.Fatalf("looped message was injected locally: %+v", capture.packets)
+	}
+}
+
+func TestLoopPrevention_ForeignMessageInjectedWithMarker(t *testing.T) {
+	m, bc, fake, capture := newLoopTestManager(t)
+	hook := NewBridgeHook(m)
+
+	bc.handleInboundMessage("remote/temp", []byte("21.5"), 0, false, map[string]string{DefaultLoopMarker: "other-broker"}, bc.bridge.Topics[0])
+	if len(capture.packets) != 1 {
+		t.Fatalf("injected %d messages, want 1", len(capture.packets))
+	}
+
+	injected := capture.packets[0]
+	if injected.TopicName != "local/temp" {
+		t.Errorf("injected topic = %q, want %q", injected.TopicName, "local/temp")
+	}
+	if len(injected.Properties.User) != 1 || injected.Properties.User[0].Val != "other-broker" {
+		t.Errorf("injected user properties = %+v, want original marker preserved", injected.Properties.User)
+	}
+
+	// A marked message published by a bridge client (e.g. a bridge to self
+	// arriving over TCP) is never forwarded out again
+	cl := m.server.NewClient(nil, "tcp", "bridge-self", false)
+	if _, err := hook.OnPublish(cl, injected); err != nil {
+		t.Fatalf("OnPublish() error = %v", err)
+	}
+	if len(fake.published) != 0 {
+		t.Errorf("marked message was forwarded %d times, want 0", len(fake.published))
+	}
+
+	// Subscribers other than bridges don't see the marker
+	device := m.server.NewClient(nil, "tcp", "device", false)
+	if delivered := hook.OnPacketEncode(device, injected); len(delivered.Properties.User) != 0 {
+		t.Errorf("delivered user properties = %+v, want the marker stripped", delivered.Properties.User)
+	}
+	if delivered := hook.OnPacketEncode(cl, injected); len(delivered.Properties.User) != 1 {
+		t.Errorf("user properties delivered to a bridge = %+v, want the marker kept", delivered.Properties.User)
+	}
+	if len(injected.Properties.User) != 1 {
+		t.Errorf("stripping the marker changed the published packet: %+v", injected.Properties.User)
+	}
+}
+
+func TestLoopPrevention_ClientMarkerStripped(t *testing.T) {
+	m, _, fake, _ := newLoopTestManager(t)
+	hook := NewBridgeHook(m)
+
+	// An ordinary client can't keep its message local by setting the marker
+	cl := m.server.NewClient(nil, "tcp", "device", false)
+	pk := packets.Packet{
+		FixedHeader: packets.FixedHeader{Type: packets.Publish},
+		TopicName:   "local/temp",
+		Payload:     []byte("21.5"),
+	}
+	pk.Properties.User = []packets.UserProperty{
+		{Key: DefaultLoopMarker, Val: "spoofed"},
+		{Key: "unit", Val: "C"},
+	}
+	published, err := hook.OnPublish(cl, pk)
+	if err != nil {
+		t.Fatalf("OnPublish() error = %v", err)
+	}
+	if len(published.Properties.User) != 1 || published.Properties.User[0].Key != "unit" {
+		t.Errorf("published user properties = %+v, want only unit", published.Properties.User)
+	}
+	if len(fake.published) != 1 {
+		t.Fatalf("forwarded %d messages, want 1", len(fake.published))
+	}
+	if origin := fake.published[0].userProperties[DefaultLoopMarker]; origin != m.instanceID {
+		t.Errorf("outbound marker = %q, want instance ID %q", origin, m.instanceID)
+	}
+}
+
+func TestSetLoopMarker(t *testing.T) {
+	m := NewManager(nil, nil)
+
+	m.SetLoopMarker("")
+	if m.loopMarker != DefaultLoopMarker {
+		t.Errorf("loopMarker = %q, want default %q", m.loopMarker, DefaultLoopMarker)
+	}
+
+	m.SetLoopMarker("x-origin")
+	if !m.isLooped(map[string]string{"x-origin": m.instanceID}) {
+		t.Error("isLooped() = false for custom marker carrying this instance ID")
+	}
+	if m.isLooped(map[string]string{DefaultLoopMarker: m.instanceID}) {
+		t.Error("isLooped() = true for the default marker after it was changed")
+	}
+}
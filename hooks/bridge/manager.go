@@ -6,9 +6,12 @@ import (
 	"encoding/hex"
 	"fmt"
 	"log/slog"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github/bromq-dev/bromq/internal/events"
 	"github/bromq-dev/bromq/internal/storage"
 
 	mqttServer "github.com/mochi-mqtt/server/v2"
@@ -22,7 +25,12 @@ type Manager struct {
 	bridges map[uint]*BridgeConnection // bridge ID -> connection
 	ctx     context.Context            // Context for lifecycle management
 	cancel  context.CancelFunc         // Cancel function for shutdown
+	events  *events.Bus                // Optional bus for bridge status events
+	metrics *Metrics                   // Optional Prometheus metrics
 	mu      sync.RWMutex
+
+	loopMarker string // User property tagging bridged messages (see loop.go)
+	instanceID string // Identifies this broker in loop markers
 }
 
 // BridgeConnection represents an active bridge connection
@@ -32,6 +40,21 @@ type BridgeConnection struct {
 	inlineClient *mqttServer.Client // Inline client on local server for inbound messages
 	clientID     string             // MQTT client ID for this bridge connection
 	manager      *Manager
+	connected    atomic.Bool // Last reported state of the remote connection
+}
+
+// setConnected reports a change of the connection to the remote broker in the
+// metrics and as a bridge.status event. The client reports every connect and
+// drop, including reconnects; repeats of the current state are ignored
+func (bc *BridgeConnection) setConnected(connected bool, err error) {
+	if bc.connected.Swap(connected) == connected {
+		return
+	}
+	status := EventDisconnected
+	if connected {
+		status = EventConnected
+	}
+	bc.manager.publishStatus(bc.bridge, status, err)
 }
 
 // NewManager creates a new bridge manager
@@ -43,9 +66,40 @@ func NewManager(db *storage.DB, server *mqttServer.Server) *Manager {
 		bridges: make(map[uint]*BridgeConnection),
 		ctx:     ctx,
 		cancel:  cancel,
+
+		loopMarker: DefaultLoopMarker,
+		instanceID: generateShortID(),
 	}
 }
 
+// SetEventBus sets the event bus used to publish bridge status changes
+func (m *Manager) SetEventBus(bus *events.Bus) {
+	m.events = bus
+}
+
+// SetMetrics sets the Prometheus metrics for forwarded messages and connection events
+func (m *Manager) SetMetrics(metrics *Metrics) {
+	m.metrics = metrics
+}
+
+// publishStatus emits a bridge.status event and records it in the metrics
+func (m *Manager) publishStatus(bridge *storage.Bridge, status string, err error) {
+	m.metrics.RecordConnectionEvent(bridge.Name, status)
+	if status != EventError {
+		m.metrics.SetConnectionStatus(bridge.Name, bridge.Host, status == EventConnected)
+	}
+
+	data := map[string]interface{}{
+		"bridge_id": bridge.ID,
+		"name":      bridge.Name,
+		"status":    status,
+	}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	m.events.Publish(events.BridgeStatus, data)
+}
+
 // generateShortID generates a random 8-character hex ID for uniqueness
 func generateShortID() string {
 	b := make([]byte, 4)
@@ -56,6 +110,25 @@ func generateShortID() string {
 	return hex.EncodeToString(b)
 }
 
+// bridgeClientID returns the MQTT client ID a bridge connects with
+// IDs always carry the "bridge-" prefix for loop prevention. Clean-session
+// bridges without a configured ID get a random one; persistent-session
+// bridges must present the same ID on every connect for the remote broker
+// to resume their session, so they fall back to one derived from the name
+func bridgeClientID(bridge *storage.Bridge) string {
+	clientID := bridge.ClientID
+	if clientID == "" {
+		if !bridge.CleanSession {
+			return fmt.Sprintf("bridge-%s", strings.ReplaceAll(bridge.Name, " ", "-"))
+		}
+		return fmt.Sprintf("bridge-%s", generateShortID())
+	}
+	if !strings.HasPrefix(clientID, "bridge-") {
+		clientID = fmt.Sprintf("bridge-%s", clientID)
+	}
+	return clientID
+}
+
 // Start loads all bridges from database and connects them
 func (m *Manager) Start() error {
 	bridges, err := m.db.ListBridges()
@@ -86,52 +159,48 @@ func (m *Manager) connectBridge(bridge *storage.Bridge) error {
 		return fmt.Errorf("bridge %s already connected", bridge.Name)
 	}
 
-	// Ensure bridge client ID has identifying prefix for loop prevention
-	// Each bridge gets a unique random ID to prevent conflicts
-	clientID := bridge.ClientID
-	if clientID == "" {
-		clientID = fmt.Sprintf("bridge-%s", generateShortID())
-	} else if len(clientID) < 7 || clientID[:7] != "bridge-" {
-		clientID = fmt.Sprintf("bridge-%s", clientID)
+	clientID := bridgeClientID(bridge)
+	bc := &BridgeConnection{
+		bridge:   bridge,
+		clientID: clientID,
+		manager:  m,
 	}
 
 	// Create abstracted client (v3 or v5 based on bridge.MQTTVersion)
-	client, err := NewBridgeClient(m.ctx, bridge, clientID)
+	client, err := NewBridgeClient(m.ctx, bridge, clientID, bc.setConnected)
 	if err != nil {
 		return fmt.Errorf("failed to create bridge client: %w", err)
 	}
+	bc.client = client
 
 	// Create inline client on local server to represent bridge for inbound messages
 	// This allows InjectPacket to work with proper client ID for loop prevention
-	inlineClient := m.server.NewClient(nil, "bridge", clientID, true)
-	m.server.Clients.Add(inlineClient)
-
-	bc := &BridgeConnection{
-		bridge:       bridge,
-		client:       client,
-		clientID:     clientID,
-		inlineClient: inlineClient,
-		manager:      m,
-	}
+	bc.inlineClient = m.server.NewClient(nil, "bridge", clientID, true)
+	m.server.Clients.Add(bc.inlineClient)
 
 	// Store connection
 	m.bridges[bridge.ID] = bc
 
 	// Connect to remote broker
 	slog.Info("Connecting bridge", "name", bridge.Name, "remote", fmt.Sprintf("%s:%d", bridge.Host, bridge.Port), "mqtt_version", bridge.MQTTVersion)
+	m.metrics.RecordConnectionAttempt(bridge.Name, bridge.Host)
 	if err := client.Connect(); err != nil {
+		m.metrics.RecordConnectionFailure(bridge.Name, bridge.Host, "connect")
+		m.publishStatus(bridge, EventError, err)
 		return fmt.Errorf("connection failed: %w", err)
 	}
+	bc.setConnected(true, nil)
 
 	// Subscribe to topics for inbound direction
 	for _, topic := range bridge.Topics {
 		if topic.Direction == "in" || topic.Direction == "both" {
-			if err := client.Subscribe(topic.Remote, topic.QoS, func(topicName string, payload []byte, qos byte, retained bool) {
-				bc.handleInboundMessage(topicName, payload, qos, retained, topic)
+			qos := bc.capQoS(topic.QoS)
+			if err := client.Subscribe(topic.Remote, qos, func(topicName string, payload []byte, qos byte, retained bool, userProperties map[string]string) {
+				bc.handleInboundMessage(topicName, payload, qos, retained, userProperties, topic)
 			}); err != nil {
 				slog.Error("Failed to subscribe to topic", "bridge", bridge.Name, "topic", topic.Remote, "error", err)
 			} else {
-				slog.Info("Bridge subscribed", "bridge", bridge.Name, "topic", topic.Remote, "qos", topic.QoS)
+				slog.Info("Bridge subscribed", "bridge", bridge.Name, "topic", topic.Remote, "qos", qos)
 			}
 		}
 	}
@@ -139,8 +208,31 @@ func (m *Manager) connectBridge(bridge *storage.Bridge) error {
 	return nil
 }
 
+// capQoS clamps a forwarded message or subscription QoS to the bridge's MaxQoS
+func (bc *BridgeConnection) capQoS(qos byte) byte {
+	if bc.bridge.MaxQoS != nil && qos > *bc.bridge.MaxQoS {
+		return *bc.bridge.MaxQoS
+	}
+	return qos
+}
+
+// forwardRetain applies the bridge's retain policy to a forwarded message
+func (bc *BridgeConnection) forwardRetain(retained bool) bool {
+	if bc.bridge.RetainPolicy == storage.RetainPolicyClear {
+		return false
+	}
+	return retained
+}
+
 // handleInboundMessage processes messages received from remote broker
-func (bc *BridgeConnection) handleInboundMessage(remoteTopic string, payload []byte, qos byte, retained bool, topicMapping storage.BridgeTopic) {
+func (bc *BridgeConnection) handleInboundMessage(remoteTopic string, payload []byte, qos byte, retained bool, userProperties map[string]string, topicMapping storage.BridgeTopic) {
+	// Drop messages this broker forwarded out that have come back through another path
+	if bc.manager.isLooped(userProperties) {
+		slog.Debug("Dropping looped bridge message", "bridge", bc.bridge.Name, "remote_topic", remoteTopic)
+		bc.manager.metrics.RecordMessageDropped(bc.bridge.Name, "in", "loop")
+		return
+	}
+
 	// Transform topic from remote pattern to local pattern
 	localTopic := TransformTopic(remoteTopic, topicMapping.Remote, topicMapping.Local)
 
@@ -153,13 +245,21 @@ func (bc *BridgeConnection) handleInboundMessage(remoteTopic string, payload []b
 	pk := packets.Packet{
 		FixedHeader: packets.FixedHeader{
 			Type:   packets.Publish,
-			Qos:    qos,
-			Retain: retained,
+			Qos:    bc.capQoS(qos),
+			Retain: bc.forwardRetain(retained),
 		},
 		TopicName: localTopic,
 		Payload:   payload,
 	}
 
+	// Tag the message so the bridge hook won't forward it out again, keeping
+	// the original sender's marker if it came from another bridged broker
+	origin := bc.manager.instanceID
+	if value, ok := userProperties[bc.manager.loopMarker]; ok {
+		origin = value
+	}
+	pk.Properties.User = append(pk.Properties.User, packets.UserProperty{Key: bc.manager.loopMarker, Val: origin})
+
 	// Inject packet using bridge's inline client for proper loop prevention
 	err := bc.manager.server.InjectPacket(bc.inlineClient, pk)
 	if err != nil {
@@ -167,7 +267,10 @@ func (bc *BridgeConnection) handleInboundMessage(remoteTopic string, payload []b
 			"bridge", bc.bridge.Name,
 			"topic", localTopic,
 			"error", err)
+		bc.manager.metrics.RecordMessageDropped(bc.bridge.Name, "in", "inject_failed")
+		return
 	}
+	bc.manager.metrics.RecordMessageForwarded(bc.bridge.Name, "in")
 }
 
 // HandleOutboundMessage forwards a message from local broker to remote brokers
@@ -195,17 +298,34 @@ func (m *Manager) HandleOutboundMessage(topic string, payload []byte, retained b
 					"remote_topic", remoteTopic)
 
 				// Publish to remote broker
-				if err := bc.client.Publish(remoteTopic, topicMapping.QoS, retained, payload); err != nil {
+				if err := bc.client.Publish(remoteTopic, bc.capQoS(topicMapping.QoS), bc.forwardRetain(retained), payload, m.outboundProperties()); err != nil {
 					slog.Error("Failed to publish outbound message",
 						"bridge", bc.bridge.Name,
 						"topic", remoteTopic,
 						"error", err)
+					m.metrics.RecordMessageDropped(bc.bridge.Name, "out", "publish_failed")
+					continue
 				}
+				m.metrics.RecordMessageForwarded(bc.bridge.Name, "out")
 			}
 		}
 	}
 }
 
+// ConnectedCount returns how many bridges are currently connected to their remote broker
+func (m *Manager) ConnectedCount() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	connected := 0
+	for _, bc := range m.bridges {
+		if bc.client.IsConnected() {
+			connected++
+		}
+	}
+	return connected
+}
+
 // Stop disconnects all bridge connections
 func (m *Manager) Stop() {
 	m.mu.Lock()
@@ -223,6 +343,7 @@ func (m *Manager) Stop() {
 			slog.Error("Error disconnecting bridge", "name", bc.bridge.Name, "error", err)
 		}
 		m.server.Clients.Delete(bc.clientID) // Remove inline client
+		bc.setConnected(false, nil)
 		slog.Info("Bridge disconnected", "name", bc.bridge.Name)
 	}
 
@@ -0,0 +1,70 @@
+package bridge
+
+import (
+	"testing"
+
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+func TestDeliveryPolicy_MaxQoSDowngradesOutbound(t *testing.T) {
+	m, bc, fake, _ := newLoopTestManager(t)
+	maxQoS := byte(1)
+	bc.bridge.MaxQoS = &maxQoS
+	bc.bridge.Topics[0].QoS = 2
+
+	m.HandleOutboundMessage("local/temp", []byte("21.5"), true, 2)
+
+	if len(fake.published) != 1 {
+		t.Fatalf("published %d messages, want 1", len(fake.published))
+	}
+	if got := fake.published[0].qos; got != 1 {
+		t.Errorf("forwarded QoS = %d, want 1", got)
+	}
+	if !fake.published[0].retained {
+		t.Error("retain flag dropped with the default preserve policy")
+	}
+}
+
+func TestDeliveryPolicy_Inbound(t *testing.T) {
+	_, bc, _, capture := newLoopTestManager(t)
+	maxQoS := byte(0)
+	bc.bridge.MaxQoS = &maxQoS
+	bc.bridge.RetainPolicy = storage.RetainPolicyClear
+
+	bc.handleInboundMessage("remote/temp", []byte("21.5"), 2, true, nil, bc.bridge.Topics[0])
+
+	if len(capture.packets) != 1 {
+		t.Fatalf("injected %d messages, want 1", len(capture.packets))
+	}
+	pk := capture.packets[0]
+	if pk.FixedHeader.Qos != 0 {
+		t.Errorf("injected QoS = %d, want 0", pk.FixedHeader.Qos)
+	}
+	if pk.FixedHeader.Retain {
+		t.Error("injected message retained with the clear policy")
+	}
+}
+
+func TestCapQoS(t *testing.T) {
+	one := byte(1)
+	tests := []struct {
+		name   string
+		maxQoS *byte
+		qos    byte
+		want   byte
+	}{
+		{"no cap", nil, 2, 2},
+		{"above cap", &one, 2, 1},
+		{"at cap", &one, 1, 1},
+		{"below cap", &one, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bc := &BridgeConnection{bridge: &storage.Bridge{MaxQoS: tt.maxQoS}}
+			if got := bc.capQoS(tt.qos); got != tt.want {
+				t.Errorf("capQoS(%d) = %d, want %d", tt.qos, got, tt.want)
+			}
+		})
+	}
+}
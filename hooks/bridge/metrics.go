@@ -5,11 +5,21 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// Connection events counted in bridge_connection_events_total, matching the
+// status of the bridge.status live event
+const (
+	EventConnected    = "connected"
+	EventDisconnected = "disconnected"
+	EventError        = "error"
+)
+
 // Metrics holds Prometheus metric collectors for bridge connections
+// A nil Metrics records nothing, so the manager works without one
 type Metrics struct {
 	connectionStatus     *prometheus.GaugeVec
 	connectionAttempts   *prometheus.CounterVec
 	connectionFailures   *prometheus.CounterVec
+	connectionEvents     *prometheus.CounterVec
 	messagesForwarded    *prometheus.CounterVec
 	messagesDropped      *prometheus.CounterVec
 	reconnectAttempts    *prometheus.CounterVec
@@ -20,64 +30,77 @@ type Metrics struct {
 
 // NewMetrics creates a new bridge metrics collector
 func NewMetrics() *Metrics {
+	return NewMetricsWithRegistry(prometheus.DefaultRegisterer)
+}
+
+// NewMetricsWithRegistry creates a bridge metrics collector registered with reg
+func NewMetricsWithRegistry(reg prometheus.Registerer) *Metrics {
+	factory := promauto.With(reg)
 	return &Metrics{
-		connectionStatus: promauto.NewGaugeVec(
+		connectionStatus: factory.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "bridge_connection_status",
 				Help: "Bridge connection status (1 = connected, 0 = disconnected)",
 			},
 			[]string{"bridge_name", "remote_host"},
 		),
-		connectionAttempts: promauto.NewCounterVec(
+		connectionAttempts: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "bridge_connection_attempts_total",
 				Help: "Total number of bridge connection attempts",
 			},
 			[]string{"bridge_name", "remote_host"},
 		),
-		connectionFailures: promauto.NewCounterVec(
+		connectionFailures: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "bridge_connection_failures_total",
 				Help: "Total number of bridge connection failures",
 			},
 			[]string{"bridge_name", "remote_host", "error_type"},
 		),
-		messagesForwarded: promauto.NewCounterVec(
+		connectionEvents: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "bridge_connection_events_total",
+				Help: "Total number of bridge connection events by type (connected, disconnected, error)",
+			},
+			[]string{"bridge_name", "event"},
+		),
+		messagesForwarded: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "bridge_messages_forwarded_total",
 				Help: "Total number of messages forwarded through bridge",
 			},
 			[]string{"bridge_name", "direction"}, // direction: in, out
 		),
-		messagesDropped: promauto.NewCounterVec(
+		messagesDropped: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "bridge_messages_dropped_total",
 				Help: "Total number of messages dropped by bridge",
 			},
 			[]string{"bridge_name", "direction", "reason"},
 		),
-		reconnectAttempts: promauto.NewCounterVec(
+		reconnectAttempts: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "bridge_reconnect_attempts_total",
 				Help: "Total number of bridge reconnection attempts",
 			},
 			[]string{"bridge_name"},
 		),
-		currentBackoff: promauto.NewGaugeVec(
+		currentBackoff: factory.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "bridge_current_backoff_seconds",
 				Help: "Current exponential backoff delay in seconds",
 			},
 			[]string{"bridge_name"},
 		),
-		lastConnectedTime: promauto.NewGaugeVec(
+		lastConnectedTime: factory.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "bridge_last_connected_timestamp_seconds",
 				Help: "Unix timestamp when bridge last connected",
 			},
 			[]string{"bridge_name"},
 		),
-		lastDisconnectedTime: promauto.NewGaugeVec(
+		lastDisconnectedTime: factory.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "bridge_last_disconnected_timestamp_seconds",
 				Help: "Unix timestamp when bridge last disconnected",
@@ -89,6 +112,9 @@ func NewMetrics() *Metrics {
 
 // SetConnectionStatus sets the connection status for a bridge
 func (m *Metrics) SetConnectionStatus(bridgeName, remoteHost string, connected bool) {
+	if m == nil {
+		return
+	}
 	var status float64
 	if connected {
 		status = 1
@@ -102,30 +128,56 @@ func (m *Metrics) SetConnectionStatus(bridgeName, remoteHost string, connected b
 
 // RecordConnectionAttempt records a connection attempt
 func (m *Metrics) RecordConnectionAttempt(bridgeName, remoteHost string) {
+	if m == nil {
+		return
+	}
 	m.connectionAttempts.WithLabelValues(bridgeName, remoteHost).Inc()
 }
 
+// RecordConnectionEvent records a connect, disconnect or connection error
+func (m *Metrics) RecordConnectionEvent(bridgeName, event string) {
+	if m == nil {
+		return
+	}
+	m.connectionEvents.WithLabelValues(bridgeName, event).Inc()
+}
+
 // RecordConnectionFailure records a connection failure
 func (m *Metrics) RecordConnectionFailure(bridgeName, remoteHost, errorType string) {
+	if m == nil {
+		return
+	}
 	m.connectionFailures.WithLabelValues(bridgeName, remoteHost, errorType).Inc()
 }
 
 // RecordMessageForwarded records a forwarded message
 func (m *Metrics) RecordMessageForwarded(bridgeName, direction string) {
+	if m == nil {
+		return
+	}
 	m.messagesForwarded.WithLabelValues(bridgeName, direction).Inc()
 }
 
 // RecordMessageDropped records a dropped message
 func (m *Metrics) RecordMessageDropped(bridgeName, direction, reason string) {
+	if m == nil {
+		return
+	}
 	m.messagesDropped.WithLabelValues(bridgeName, direction, reason).Inc()
 }
 
 // RecordReconnectAttempt records a reconnection attempt
 func (m *Metrics) RecordReconnectAttempt(bridgeName string) {
+	if m == nil {
+		return
+	}
 	m.reconnectAttempts.WithLabelValues(bridgeName).Inc()
 }
 
 // SetCurrentBackoff sets the current backoff delay
 func (m *Metrics) SetCurrentBackoff(bridgeName string, backoffSeconds float64) {
+	if m == nil {
+		return
+	}
 	m.currentBackoff.WithLabelValues(bridgeName).Set(backoffSeconds)
 }
@@ -0,0 +1,73 @@
+package bridge
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics_ForwardedMessages(t *testing.T) {
+	m, bc, fake, _ := newLoopTestManager(t)
+	metrics := NewMetricsWithRegistry(prometheus.NewRegistry())
+	m.SetMetrics(metrics)
+
+	m.HandleOutboundMessage("local/temp", []byte("21.5"), false, 1)
+	m.HandleOutboundMessage("other/topic", []byte("ignored"), false, 1) // Matches no mapping
+	if len(fake.published) != 1 {
+		t.Fatalf("published %d messages, want 1", len(fake.published))
+	}
+	if got := testutil.ToFloat64(metrics.messagesForwarded.WithLabelValues("test", "out")); got != 1 {
+		t.Errorf("bridge_messages_forwarded_total{direction=out} = %v, want 1", got)
+	}
+
+	mapping := bc.bridge.Topics[0]
+	bc.handleInboundMessage("remote/humidity", []byte("40"), 0, false, nil, mapping)
+	if got := testutil.ToFloat64(metrics.messagesForwarded.WithLabelValues("test", "in")); got != 1 {
+		t.Errorf("bridge_messages_forwarded_total{direction=in} = %v, want 1", got)
+	}
+
+	// A message this broker sent out coming back is dropped, not forwarded
+	bc.handleInboundMessage("remote/temp", []byte("21.5"), 0, false, m.outboundProperties(), mapping)
+	if got := testutil.ToFloat64(metrics.messagesForwarded.WithLabelValues("test", "in")); got != 1 {
+		t.Errorf("bridge_messages_forwarded_total{direction=in} after a looped message = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.messagesDropped.WithLabelValues("test", "in", "loop")); got != 1 {
+		t.Errorf("bridge_messages_dropped_total{reason=loop} = %v, want 1", got)
+	}
+}
+
+func TestMetrics_ConnectionEvents(t *testing.T) {
+	m, bc, _, _ := newLoopTestManager(t)
+	metrics := NewMetricsWithRegistry(prometheus.NewRegistry())
+	m.SetMetrics(metrics)
+	bc.bridge.Host = "mqtt.example.com"
+
+	bc.setConnected(true, nil)
+	bc.setConnected(true, nil) // Reported by both Connect and the client's callback
+	if got := testutil.ToFloat64(metrics.connectionStatus.WithLabelValues("test", "mqtt.example.com")); got != 1 {
+		t.Errorf("bridge_connection_status after connect = %v, want 1", got)
+	}
+
+	// The client reports a drop and a reconnect
+	bc.setConnected(false, errors.New("connection reset"))
+	if got := testutil.ToFloat64(metrics.connectionStatus.WithLabelValues("test", "mqtt.example.com")); got != 0 {
+		t.Errorf("bridge_connection_status after a drop = %v, want 0", got)
+	}
+	bc.setConnected(true, nil)
+	if got := testutil.ToFloat64(metrics.connectionEvents.WithLabelValues("test", EventConnected)); got != 2 {
+		t.Errorf("bridge_connection_events_total{event=connected} after reconnecting = %v, want 2", got)
+	}
+
+	m.Stop()
+	if got := testutil.ToFloat64(metrics.connectionEvents.WithLabelValues("test", EventDisconnected)); got != 2 {
+		t.Errorf("bridge_connection_events_total{event=disconnected} = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(metrics.connectionStatus.WithLabelValues("test", "mqtt.example.com")); got != 0 {
+		t.Errorf("bridge_connection_status after stop = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(metrics.connectionEvents.WithLabelValues("test", EventConnected)); got != 2 {
+		t.Errorf("bridge_connection_events_total{event=connected} = %v, want 2", got)
+	}
+}
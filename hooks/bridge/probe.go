@@ -0,0 +1,103 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github/bromq-dev/bromq/internal/storage"
+
+	pahoV5Client "github.com/eclipse/paho.golang/paho"
+	pahoV3 "github.com/eclipse/paho.mqtt.golang"
+)
+
+// DefaultProbeTimeout bounds a connection test when the context has no deadline
+const DefaultProbeTimeout = 10 * time.Second
+
+// Probe connects to the bridge's remote broker, waits for the CONNACK and
+// disconnects again, returning the connection error if any
+// The probe always uses a clean session and its own client ID, so it never
+// takes over or alters the session of a running bridge
+func Probe(ctx context.Context, bridge *storage.Bridge) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultProbeTimeout)
+		defer cancel()
+	}
+
+	port := bridge.Port
+	if port == 0 {
+		port = 1883
+	}
+	addr := net.JoinHostPort(bridge.Host, strconv.Itoa(port))
+	clientID := fmt.Sprintf("bridge-probe-%s", generateShortID())
+
+	switch bridge.MQTTVersion {
+	case "", "5":
+		return probeV5(ctx, addr, clientID, bridge)
+	case "3":
+		return probeV3(ctx, addr, clientID, bridge)
+	default:
+		return fmt.Errorf("unsupported MQTT version: %s", bridge.MQTTVersion)
+	}
+}
+
+func probeV3(ctx context.Context, addr, clientID string, bridge *storage.Bridge) error {
+	deadline, _ := ctx.Deadline()
+	timeout := time.Until(deadline)
+
+	opts := pahoV3.NewClientOptions()
+	opts.AddBroker("tcp://" + addr)
+	opts.SetClientID(clientID)
+	opts.SetUsername(bridge.Username)
+	opts.SetPassword(bridge.Password)
+	opts.SetCleanSession(true)
+	opts.SetConnectTimeout(timeout)
+	opts.SetAutoReconnect(false)
+
+	client := pahoV3.NewClient(opts)
+	token := client.Connect()
+	if !token.WaitTimeout(timeout) {
+		return fmt.Errorf("connection timeout after %s", timeout.Round(time.Millisecond))
+	}
+	if err := token.Error(); err != nil {
+		return err
+	}
+	client.Disconnect(0)
+	return nil
+}
+
+func probeV5(ctx context.Context, addr, clientID string, bridge *storage.Bridge) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	client := pahoV5Client.NewClient(pahoV5Client.ClientConfig{
+		ClientID: clientID,
+		Conn:     conn,
+	})
+
+	connect := &pahoV5Client.Connect{
+		ClientID:   clientID,
+		CleanStart: true,
+		KeepAlive:  30,
+	}
+	if bridge.Username != "" {
+		connect.UsernameFlag = true
+		connect.Username = bridge.Username
+	}
+	if bridge.Password != "" {
+		connect.PasswordFlag = true
+		connect.Password = []byte(bridge.Password)
+	}
+
+	if _, err := client.Connect(ctx, connect); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	return client.Disconnect(&pahoV5Client.Disconnect{ReasonCode: 0})
+}
@@ -0,0 +1,59 @@
+package bridge
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+func TestProbe(t *testing.T) {
+	_, recorder, port := startRemoteBroker(t)
+
+	for _, version := range []string{"3", "5"} {
+		t.Run("v"+version, func(t *testing.T) {
+			err := Probe(context.Background(), &storage.Bridge{Host: "127.0.0.1", Port: port, MQTTVersion: version})
+			if err != nil {
+				t.Fatalf("Probe() error = %v, want nil", err)
+			}
+		})
+	}
+
+	ids := recorder.clientIDs()
+	if len(ids) != 2 {
+		t.Fatalf("remote broker saw %d connections, want 2", len(ids))
+	}
+	for _, id := range ids {
+		if !strings.HasPrefix(id, "bridge-probe-") {
+			t.Errorf("probe connected as %q, want a bridge-probe- client ID", id)
+		}
+	}
+}
+
+func TestProbe_ClosedPort(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	_ = ln.Close()
+
+	for _, version := range []string{"3", "5"} {
+		t.Run("v"+version, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			start := time.Now()
+			err := Probe(ctx, &storage.Bridge{Host: "127.0.0.1", Port: port, MQTTVersion: version})
+			if err == nil {
+				t.Fatal("Probe() error = nil, want connection error")
+			}
+			if elapsed := time.Since(start); elapsed > 3*time.Second {
+				t.Errorf("Probe() took %v, want it to respect the 2s timeout", elapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package bridge
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mqttServer "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+// connectRecorder records the client IDs connecting to a broker
+type connectRecorder struct {
+	mqttServer.HookBase
+	mu  sync.Mutex
+	ids []string
+}
+
+func (h *connectRecorder) ID() string { return "connect-recorder" }
+
+func (h *connectRecorder) Provides(b byte) bool {
+	return bytes.Contains([]byte{mqttServer.OnConnect}, []byte{b})
+}
+
+func (h *connectRecorder) OnConnect(cl *mqttServer.Client, _ packets.Packet) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ids = append(h.ids, cl.ID)
+	return nil
+}
+
+func (h *connectRecorder) clientIDs() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.ids...)
+}
+
+// startRemoteBroker runs a broker on a free local port to act as a bridge's remote end
+func startRemoteBroker(t *testing.T) (*mqttServer.Server, *connectRecorder, int) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	_ = ln.Close()
+
+	server := mqttServer.New(nil)
+	recorder := &connectRecorder{}
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.AddHook(recorder, nil); err != nil {
+		t.Fatal(err)
+	}
+	tcp := listeners.NewTCP(listeners.Config{ID: "remote", Address: ln.Addr().String()})
+	if err := server.AddListener(tcp); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+
+	return server, recorder, port
+}
+
+func TestPersistentSessionBridge_ReusesClientID(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping bridge connection test in short mode")
+	}
+
+	remote, recorder, port := startRemoteBroker(t)
+	bridge := &storage.Bridge{
+		ID:                1,
+		Name:              "persistent",
+		Host:              "127.0.0.1",
+		Port:              port,
+		MQTTVersion:       "5",
+		CleanSession:      false,
+		KeepAlive:         30,
+		ConnectionTimeout: 5,
+		Topics: []storage.BridgeTopic{
+			{Local: "local/#", Remote: "remote/#", Direction: "in", QoS: 1},
+		},
+	}
+
+	// Connect, then shut down the manager as happens on a broker restart
+	first := NewManager(nil, mqttServer.New(nil))
+	if err := first.connectBridge(bridge); err != nil {
+		t.Fatalf("connectBridge() error = %v", err)
+	}
+	first.Stop()
+
+	clientID := "bridge-persistent"
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if cl, ok := remote.Clients.Get(clientID); ok && cl.Closed() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("remote broker dropped the session for %s after disconnect", clientID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A new manager resumes the same remote session
+	second := NewManager(nil, mqttServer.New(nil))
+	if err := second.connectBridge(bridge); err != nil {
+		t.Fatalf("connectBridge() error = %v", err)
+	}
+	defer second.Stop()
+
+	ids := recorder.clientIDs()
+	if len(ids) != 2 || ids[0] != clientID || ids[1] != clientID {
+		t.Fatalf("remote saw client IDs %v, want %s twice", ids, clientID)
+	}
+
+	cl, ok := remote.Clients.Get(clientID)
+	if !ok {
+		t.Fatal("remote session missing after reconnect")
+	}
+	if _, ok := cl.State.Subscriptions.Get("remote/#"); !ok {
+		t.Error("resumed session lost its subscription to remote/#")
+	}
+}
+
+func TestBridgeClientID(t *testing.T) {
+	tests := []struct {
+		name   string
+		bridge storage.Bridge
+		want   string
+	}{
+		{"configured ID gets prefix", storage.Bridge{Name: "cloud", ClientID: "edge-1", CleanSession: true}, "bridge-edge-1"},
+		{"prefixed ID kept", storage.Bridge{Name: "cloud", ClientID: "bridge-edge-1"}, "bridge-edge-1"},
+		{"persistent session without ID uses name", storage.Bridge{Name: "cloud sync", CleanSession: false}, "bridge-cloud-sync"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bridgeClientID(&tt.bridge); got != tt.want {
+				t.Errorf("bridgeClientID() = %q, want %q", got, tt.want)
+			}
+			if got := bridgeClientID(&tt.bridge); got != tt.want {
+				t.Errorf("second bridgeClientID() = %q, want stable %q", got, tt.want)
+			}
+		})
+	}
+
+	clean := storage.Bridge{Name: "cloud", CleanSession: true}
+	a, b := bridgeClientID(&clean), bridgeClientID(&clean)
+	if !strings.HasPrefix(a, "bridge-") || a == b {
+		t.Errorf("clean-session IDs %q and %q should be random and prefixed", a, b)
+	}
+}
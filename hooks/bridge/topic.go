@@ -10,6 +10,11 @@ import (
 //   - "sensors/+/temp" matches "sensors/kitchen/temp" but not "sensors/kitchen/hum"
 //   - "sensors/#" matches "sensors/kitchen/temp" and "sensors/living/hum/value"
 func MatchTopic(topic, pattern string) bool {
+	// Leading wildcards never match $-prefixed topics such as $SYS
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(pattern, "#") || strings.HasPrefix(pattern, "+")) {
+		return false
+	}
+
 	topicParts := strings.Split(topic, "/")
 	patternParts := strings.Split(pattern, "/")
 
@@ -22,6 +22,10 @@ func TestMatchTopic(t *testing.T) {
 		{"single wildcard at end", "sensor/temp", "sensor/+", true},
 		{"single wildcard at start", "sensor/temp", "+/temp", true},
 
+		// $-prefixed topics
+		{"leading wildcard skips $SYS", "$SYS/broker/uptime", "#", false},
+		{"explicit $SYS pattern", "$SYS/broker/uptime", "$SYS/#", true},
+
 		// Multi-level wildcard (#)
 		{"multi-level match all", "sensor/kitchen/temp/value", "sensor/#", true},
 		{"multi-level match one", "sensor/temp", "sensor/#", true},
@@ -0,0 +1,102 @@
+package events
+
+import (
+	"bytes"
+	"strings"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github/bromq-dev/bromq/internal/events"
+	"github/bromq-dev/bromq/internal/redact"
+)
+
+// maxEventPayload is how much of a payload message.published events include
+const maxEventPayload = 1024
+
+// EventPublisher interface for emitting live broker events
+type EventPublisher interface {
+	Publish(eventType string, data map[string]interface{})
+}
+
+// EventsHook publishes client and message activity to the event bus
+type EventsHook struct {
+	mqtt.HookBase
+	publisher EventPublisher
+	redactor  *redact.Redactor
+}
+
+// NewEventsHook creates a new event publishing hook
+func NewEventsHook(publisher EventPublisher) *EventsHook {
+	return &EventsHook{
+		publisher: publisher,
+	}
+}
+
+// SetRedactor masks the payloads of redacted topics in message.published events
+func (h *EventsHook) SetRedactor(redactor *redact.Redactor) {
+	h.redactor = redactor
+}
+
+// ID returns the hook identifier
+func (h *EventsHook) ID() string {
+	return "event-publisher"
+}
+
+// Provides indicates which hook methods this hook provides
+func (h *EventsHook) Provides(b byte) bool {
+	return bytes.Contains([]byte{
+		mqtt.OnConnect,
+		mqtt.OnDisconnect,
+		mqtt.OnPublish,
+	}, []byte{b})
+}
+
+// OnConnect emits a client.connected event
+func (h *EventsHook) OnConnect(cl *mqtt.Client, pk packets.Packet) error {
+	h.publisher.Publish(events.ClientConnected, map[string]interface{}{
+		"client_id":        cl.ID,
+		"username":         string(pk.Connect.Username),
+		"remote":           cl.Net.Remote,
+		"protocol_version": pk.ProtocolVersion,
+	})
+	return nil
+}
+
+// OnDisconnect emits a client.disconnected event
+func (h *EventsHook) OnDisconnect(cl *mqtt.Client, err error, expire bool) {
+	data := map[string]interface{}{
+		"client_id": cl.ID,
+		"username":  string(cl.Properties.Username),
+	}
+	if err != nil {
+		data["reason"] = err.Error()
+	}
+	h.publisher.Publish(events.ClientDisconnected, data)
+}
+
+// OnPublish emits a message.published summary with the start of the payload
+// (the first maxEventPayload bytes, masked on redacted topics)
+func (h *EventsHook) OnPublish(cl *mqtt.Client, pk packets.Packet) (packets.Packet, error) {
+	// Skip broker stats to keep the stream focused on client traffic
+	if strings.HasPrefix(pk.TopicName, "$SYS/") {
+		return pk, nil
+	}
+
+	data := map[string]interface{}{
+		"client_id": cl.ID,
+		"topic":     pk.TopicName,
+		"qos":       pk.FixedHeader.Qos,
+		"retain":    pk.FixedHeader.Retain,
+		"size":      len(pk.Payload),
+	}
+	payload := h.redactor.Payload(pk.TopicName, pk.Payload)
+	if len(payload) > maxEventPayload {
+		payload = payload[:maxEventPayload]
+		data["payload_truncated"] = true
+	}
+	data["payload"] = string(payload)
+
+	h.publisher.Publish(events.MessagePublished, data)
+	return pk, nil
+}
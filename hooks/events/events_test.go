@@ -0,0 +1,97 @@
+package events
+
+import (
+	"testing"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github/bromq-dev/bromq/internal/events"
+	"github/bromq-dev/bromq/internal/redact"
+)
+
+// MockEventPublisher records published events for testing
+type MockEventPublisher struct {
+	events []string
+	data   []map[string]interface{}
+}
+
+func (m *MockEventPublisher) Publish(eventType string, data map[string]interface{}) {
+	m.events = append(m.events, eventType)
+	m.data = append(m.data, data)
+}
+
+func TestEventsHook_ID(t *testing.T) {
+	hook := NewEventsHook(&MockEventPublisher{})
+
+	if hook.ID() != "event-publisher" {
+		t.Errorf("EventsHook.ID() = %v, want event-publisher", hook.ID())
+	}
+}
+
+func TestEventsHook_Events(t *testing.T) {
+	publisher := &MockEventPublisher{}
+	hook := NewEventsHook(publisher)
+
+	client := &mqtt.Client{ID: "device-1"}
+
+	_ = hook.OnConnect(client, packets.Packet{Connect: packets.ConnectParams{Username: []byte("sensor")}})
+	_, _ = hook.OnPublish(client, packets.Packet{TopicName: "sensors/temp", Payload: []byte("21.5")})
+	_, _ = hook.OnPublish(client, packets.Packet{TopicName: "$SYS/broker/uptime", Payload: []byte("10")})
+	hook.OnDisconnect(client, nil, false)
+
+	want := []string{events.ClientConnected, events.MessagePublished, events.ClientDisconnected}
+	if len(publisher.events) != len(want) {
+		t.Fatalf("got events %v, want %v", publisher.events, want)
+	}
+	for i, eventType := range want {
+		if publisher.events[i] != eventType {
+			t.Errorf("event[%d] = %s, want %s", i, publisher.events[i], eventType)
+		}
+	}
+
+	if publisher.data[0]["username"] != "sensor" {
+		t.Errorf("connect username = %v, want sensor", publisher.data[0]["username"])
+	}
+	if publisher.data[1]["topic"] != "sensors/temp" || publisher.data[1]["size"] != 4 {
+		t.Errorf("publish summary = %v", publisher.data[1])
+	}
+}
+
+func TestEventsHook_RedactsPayloads(t *testing.T) {
+	publisher := &MockEventPublisher{}
+	hook := NewEventsHook(publisher)
+	hook.SetRedactor(redact.Parse("secrets/#, +/credentials"))
+
+	client := &mqtt.Client{ID: "device-1"}
+	for _, topic := range []string{"secrets/api-key", "sensors/temp", "device-1/credentials"} {
+		_, _ = hook.OnPublish(client, packets.Packet{TopicName: topic, Payload: []byte("21.5")})
+	}
+
+	want := []string{redact.Mask, "21.5", redact.Mask}
+	if len(publisher.data) != len(want) {
+		t.Fatalf("got %d events, want %d", len(publisher.data), len(want))
+	}
+	for i, payload := range want {
+		if publisher.data[i]["payload"] != payload {
+			t.Errorf("%s payload = %v, want %q", publisher.data[i]["topic"], publisher.data[i]["payload"], payload)
+		}
+		if publisher.data[i]["size"] != 4 {
+			t.Errorf("%s size = %v, want the original size 4", publisher.data[i]["topic"], publisher.data[i]["size"])
+		}
+	}
+}
+
+func TestEventsHook_TruncatesPayloads(t *testing.T) {
+	publisher := &MockEventPublisher{}
+	hook := NewEventsHook(publisher)
+
+	_, _ = hook.OnPublish(&mqtt.Client{ID: "device-1"}, packets.Packet{TopicName: "firmware", Payload: make([]byte, maxEventPayload+1)})
+
+	if payload, _ := publisher.data[0]["payload"].(string); len(payload) != maxEventPayload {
+		t.Errorf("payload length = %d, want %d", len(payload), maxEventPayload)
+	}
+	if publisher.data[0]["payload_truncated"] != true {
+		t.Error("payload_truncated not set for an oversized payload")
+	}
+}
@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github/bromq-dev/bromq/internal/redact"
+)
+
+// Config holds metrics hook settings
+type Config struct {
+	LastValueCache      bool          `env:"LAST_VALUE_CACHE" flag:"last-value-cache" desc:"Keep the most recent payload of every published topic in memory (GET /api/topics/last)"`
+	LastValueMaxTopics  int           `env:"LAST_VALUE_MAX_TOPICS" flag:"last-value-max-topics" default:"10000" desc:"Maximum topics in the last value cache (least recently published are evicted)"`
+	LastValueTTL        time.Duration `env:"LAST_VALUE_TTL" flag:"last-value-ttl" default:"1h" desc:"Drop cached values not updated within this period (0 = keep until evicted)"`
+	LastValueMaxPayload int           `env:"LAST_VALUE_MAX_PAYLOAD" flag:"last-value-max-payload" default:"65536" desc:"Payloads larger than this many bytes are not cached"`
+}
+
+// Defaults used when the corresponding Config value is not positive
+const (
+	DefaultLastValueMaxTopics  = 10000
+	DefaultLastValueMaxPayload = 64 * 1024
+)
+
+// LastValue is the most recent message published to a topic
+type LastValue struct {
+	Topic      string    `json:"topic"`
+	Payload    []byte    `json:"payload"`
+	QoS        byte      `json:"qos"`
+	Retain     bool      `json:"retain"`
+	ClientID   string    `json:"client_id"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// LastValueCache keeps the latest message per topic in memory, whether or not
+// it was retained. The least recently published topic is evicted when full
+type LastValueCache struct {
+	mu         sync.Mutex
+	entries    map[string]*list.Element // Topic -> element holding *LastValue
+	order      *list.List               // Most recently published first
+	maxTopics  int
+	ttl        time.Duration
+	maxPayload int
+	redactor   *redact.Redactor
+}
+
+// NewLastValueCache creates a cache from cfg
+func NewLastValueCache(cfg Config) *LastValueCache {
+	if cfg.LastValueMaxTopics <= 0 {
+		cfg.LastValueMaxTopics = DefaultLastValueMaxTopics
+	}
+	if cfg.LastValueMaxPayload <= 0 {
+		cfg.LastValueMaxPayload = DefaultLastValueMaxPayload
+	}
+
+	return &LastValueCache{
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		maxTopics:  cfg.LastValueMaxTopics,
+		ttl:        cfg.LastValueTTL,
+		maxPayload: cfg.LastValueMaxPayload,
+	}
+}
+
+// SetRedactor masks the cached payloads of redacted topics
+func (c *LastValueCache) SetRedactor(redactor *redact.Redactor) {
+	c.redactor = redactor
+}
+
+// Record stores msg as the latest value of its topic
+// An oversized payload removes the previous value rather than leaving it stale
+func (c *LastValueCache) Record(msg LastValue) {
+	if msg.Topic == "" {
+		return
+	}
+
+	msg.Payload = c.redactor.Payload(msg.Topic, msg.Payload)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(msg.Payload) > c.maxPayload {
+		if elem, ok := c.entries[msg.Topic]; ok {
+			c.order.Remove(elem)
+			delete(c.entries, msg.Topic)
+		}
+		return
+	}
+
+	// The hook's packet buffer may be reused, so keep a copy
+	msg.Payload = append([]byte(nil), msg.Payload...)
+	if msg.ReceivedAt.IsZero() {
+		msg.ReceivedAt = time.Now()
+	}
+
+	if elem, ok := c.entries[msg.Topic]; ok {
+		elem.Value = &msg
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.dropExpired()
+	if c.order.Len() >= c.maxTopics {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*LastValue).Topic)
+	}
+	c.entries[msg.Topic] = c.order.PushFront(&msg)
+}
+
+// dropExpired removes expired values from the least recently published end
+// (caller must hold the lock)
+func (c *LastValueCache) dropExpired() {
+	if c.ttl <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-c.ttl)
+	for elem := c.order.Back(); elem != nil && elem.Value.(*LastValue).ReceivedAt.Before(cutoff); elem = c.order.Back() {
+		c.order.Remove(elem)
+		delete(c.entries, elem.Value.(*LastValue).Topic)
+	}
+}
+
+// Get returns the latest value published to topic, if cached and not expired
+func (c *LastValueCache) Get(topic string) (*LastValue, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[topic]
+	if !ok {
+		return nil, false
+	}
+	value := elem.Value.(*LastValue)
+	if c.ttl > 0 && time.Since(value.ReceivedAt) > c.ttl {
+		c.order.Remove(elem)
+		delete(c.entries, topic)
+		return nil, false
+	}
+	return value, true
+}
+
+// Len returns the number of cached topics
+func (c *LastValueCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+func TestLastValueCache_RecordAndGet(t *testing.T) {
+	cache := NewLastValueCache(Config{LastValueCache: true})
+
+	payload := []byte("21.5")
+	cache.Record(LastValue{Topic: "sensors/temp", Payload: payload, QoS: 1, ClientID: "c1"})
+	payload[0] = 'X' // The cache must keep its own copy
+
+	value, ok := cache.Get("sensors/temp")
+	if !ok {
+		t.Fatal("Get() found no value")
+	}
+	if string(value.Payload) != "21.5" || value.QoS != 1 || value.ClientID != "c1" {
+		t.Errorf("Get() = %+v", value)
+	}
+	if value.ReceivedAt.IsZero() {
+		t.Error("ReceivedAt should be set")
+	}
+
+	cache.Record(LastValue{Topic: "sensors/temp", Payload: []byte("22.0")})
+	if value, _ := cache.Get("sensors/temp"); string(value.Payload) != "22.0" {
+		t.Errorf("payload after update = %s, want 22.0", value.Payload)
+	}
+	if _, ok := cache.Get("sensors/humidity"); ok {
+		t.Error("Get() found a value for an unpublished topic")
+	}
+}
+
+func TestLastValueCache_EvictsLeastRecentlyPublished(t *testing.T) {
+	cache := NewLastValueCache(Config{LastValueMaxTopics: 2})
+
+	cache.Record(LastValue{Topic: "a", Payload: []byte("1")})
+	cache.Record(LastValue{Topic: "b", Payload: []byte("2")})
+	cache.Record(LastValue{Topic: "a", Payload: []byte("3")}) // a is now the most recent
+	cache.Record(LastValue{Topic: "c", Payload: []byte("4")})
+
+	if cache.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", cache.Len())
+	}
+	if _, ok := cache.Get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	for _, topic := range []string{"a", "c"} {
+		if _, ok := cache.Get(topic); !ok {
+			t.Errorf("%s should still be cached", topic)
+		}
+	}
+}
+
+func TestLastValueCache_TTL(t *testing.T) {
+	cache := NewLastValueCache(Config{LastValueTTL: time.Minute})
+
+	cache.Record(LastValue{Topic: "old", Payload: []byte("1"), ReceivedAt: time.Now().Add(-2 * time.Minute)})
+	cache.Record(LastValue{Topic: "new", Payload: []byte("2")})
+
+	if _, ok := cache.Get("old"); ok {
+		t.Error("expired value should not be returned")
+	}
+	if _, ok := cache.Get("new"); !ok {
+		t.Error("fresh value should be returned")
+	}
+	if cache.Len() != 1 {
+		t.Errorf("Len() = %d, want 1 after the expired value is dropped", cache.Len())
+	}
+}
+
+func TestLastValueCache_MaxPayload(t *testing.T) {
+	cache := NewLastValueCache(Config{LastValueMaxPayload: 8})
+
+	cache.Record(LastValue{Topic: "camera/frame", Payload: []byte("small")})
+	cache.Record(LastValue{Topic: "camera/frame", Payload: []byte(strings.Repeat("x", 9))})
+
+	if _, ok := cache.Get("camera/frame"); ok {
+		t.Error("an oversized payload should remove the previous value")
+	}
+}
+
+func TestMetricsHook_OnPublished(t *testing.T) {
+	hook := NewMetricsHook(NewMockMetricsRecorder())
+	cl := &mqtt.Client{ID: "client-1"}
+	pk := packets.Packet{
+		TopicName:   "home/door",
+		Payload:     []byte("open"),
+		FixedHeader: packets.FixedHeader{Qos: 1, Retain: true},
+	}
+
+	// Disabled by default
+	hook.OnPublished(cl, pk)
+	if hook.LastValues() != nil {
+		t.Fatal("LastValues() should be nil until enabled")
+	}
+
+	hook.SetLastValues(NewLastValueCache(Config{LastValueCache: true}))
+	hook.OnPublished(cl, pk)
+
+	value, ok := hook.LastValues().Get("home/door")
+	if !ok {
+		t.Fatal("published message was not recorded")
+	}
+	if string(value.Payload) != "open" || value.QoS != 1 || !value.Retain || value.ClientID != "client-1" {
+		t.Errorf("last value = %+v", value)
+	}
+}
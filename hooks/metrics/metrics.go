@@ -20,16 +20,41 @@ type MetricsRecorder interface {
 // MetricsHook implements MQTT hooks for metrics tracking
 type MetricsHook struct {
 	mqtt.HookBase
-	recorder MetricsRecorder
+	recorder   MetricsRecorder
+	topics     *TopicTracker
+	throughput *ThroughputTracker
+	lastValues *LastValueCache // nil = last values are not kept
 }
 
 // NewMetricsHook creates a new metrics hook
 func NewMetricsHook(recorder MetricsRecorder) *MetricsHook {
 	return &MetricsHook{
-		recorder: recorder,
+		recorder:   recorder,
+		topics:     NewTopicTracker(DefaultRecentTopicsWindow, DefaultMaxRecentTopics),
+		throughput: NewThroughputTracker(),
 	}
 }
 
+// Topics returns the tracker of recently published topics
+func (h *MetricsHook) Topics() *TopicTracker {
+	return h.topics
+}
+
+// Throughput returns the rolling history of message and byte rates
+func (h *MetricsHook) Throughput() *ThroughputTracker {
+	return h.throughput
+}
+
+// SetLastValues enables keeping the latest message of every published topic
+func (h *MetricsHook) SetLastValues(cache *LastValueCache) {
+	h.lastValues = cache
+}
+
+// LastValues returns the last value cache, or nil if it is disabled
+func (h *MetricsHook) LastValues() *LastValueCache {
+	return h.lastValues
+}
+
 // ID returns the hook identifier
 func (h *MetricsHook) ID() string {
 	return "metrics-tracker"
@@ -42,6 +67,7 @@ func (h *MetricsHook) Provides(b byte) bool {
 		mqtt.OnDisconnect,
 		mqtt.OnPacketRead,
 		mqtt.OnPacketSent,
+		mqtt.OnPublished,
 	}, []byte{b})
 }
 
@@ -65,6 +91,8 @@ func (h *MetricsHook) OnPacketRead(cl *mqtt.Client, pk packets.Packet) (packets.
 	// Count PUBLISH packets as messages (type 3 = PUBLISH)
 	if pk.FixedHeader.Type == 3 {
 		h.recorder.RecordMessageReceived(cl.ID, size)
+		h.topics.Record(pk.TopicName)
+		h.throughput.RecordIn(size)
 	}
 
 	return pk, nil
@@ -78,5 +106,22 @@ func (h *MetricsHook) OnPacketSent(cl *mqtt.Client, pk packets.Packet, b []byte)
 	// Count PUBLISH packets as messages (type 3 = PUBLISH)
 	if pk.FixedHeader.Type == 3 {
 		h.recorder.RecordMessageSent(cl.ID, size)
+		h.throughput.RecordOut(size)
+	}
+}
+
+// OnPublished records the message in the last value cache, if enabled
+// Unlike OnPacketRead this only sees publishes that passed the ACL, and also
+// those injected by bridges and scripts
+func (h *MetricsHook) OnPublished(cl *mqtt.Client, pk packets.Packet) {
+	if h.lastValues == nil {
+		return
 	}
+	h.lastValues.Record(LastValue{
+		Topic:    pk.TopicName,
+		Payload:  pk.Payload,
+		QoS:      pk.FixedHeader.Qos,
+		Retain:   pk.FixedHeader.Retain,
+		ClientID: cl.ID,
+	})
 }
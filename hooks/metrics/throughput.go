@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// ThroughputBucketSize is the granularity of the throughput history
+	ThroughputBucketSize = 10 * time.Second
+
+	// ThroughputRetention is how far back the throughput history goes
+	ThroughputRetention = time.Hour
+)
+
+// ThroughputBucket holds the PUBLISH traffic of one bucket
+type ThroughputBucket struct {
+	Start       time.Time `json:"start"`
+	MessagesIn  int64     `json:"messages_in"`
+	MessagesOut int64     `json:"messages_out"`
+	BytesIn     int64     `json:"bytes_in"`
+	BytesOut    int64     `json:"bytes_out"`
+}
+
+// ThroughputStats is the throughput over a window, with per-second rates
+// averaged over the complete buckets in it
+type ThroughputStats struct {
+	BucketSeconds     int                `json:"bucket_seconds"`
+	Buckets           []ThroughputBucket `json:"buckets"` // Oldest first; the last one is still filling
+	MessagesInPerSec  float64            `json:"messages_in_per_sec"`
+	MessagesOutPerSec float64            `json:"messages_out_per_sec"`
+	BytesInPerSec     float64            `json:"bytes_in_per_sec"`
+	BytesOutPerSec    float64            `json:"bytes_out_per_sec"`
+}
+
+// ThroughputTracker keeps a rolling history of message and byte counts in
+// fixed-size buckets, enough to draw rate sparklines without Prometheus
+type ThroughputTracker struct {
+	mu      sync.Mutex
+	buckets []ThroughputBucket // Ring indexed by bucket number
+	now     func() time.Time
+}
+
+// NewThroughputTracker creates an empty tracker
+func NewThroughputTracker() *ThroughputTracker {
+	return &ThroughputTracker{
+		buckets: make([]ThroughputBucket, ThroughputRetention/ThroughputBucketSize+1), // +1 for the bucket still filling
+		now:     time.Now,
+	}
+}
+
+// RecordIn counts a PUBLISH received from a client
+func (t *ThroughputTracker) RecordIn(bytes int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	bucket := t.bucket(t.now())
+	bucket.MessagesIn++
+	bucket.BytesIn += bytes
+}
+
+// RecordOut counts a PUBLISH sent to a client
+func (t *ThroughputTracker) RecordOut(bytes int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	bucket := t.bucket(t.now())
+	bucket.MessagesOut++
+	bucket.BytesOut += bytes
+}
+
+// bucket returns the ring slot for at, clearing it if it still holds an
+// older bucket (caller must hold the lock)
+func (t *ThroughputTracker) bucket(at time.Time) *ThroughputBucket {
+	start := at.Truncate(ThroughputBucketSize)
+	bucket := &t.buckets[int(start.Unix()/int64(ThroughputBucketSize/time.Second))%len(t.buckets)]
+	if !bucket.Start.Equal(start) {
+		*bucket = ThroughputBucket{Start: start}
+	}
+	return bucket
+}
+
+// Throughput returns the buckets covering window, rounded up to whole
+// buckets and capped at ThroughputRetention
+func (t *ThroughputTracker) Throughput(window time.Duration) ThroughputStats {
+	complete := int((window + ThroughputBucketSize - 1) / ThroughputBucketSize)
+	complete = max(1, min(complete, int(ThroughputRetention/ThroughputBucketSize)))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	current := t.now().Truncate(ThroughputBucketSize)
+	stats := ThroughputStats{
+		BucketSeconds: int(ThroughputBucketSize / time.Second),
+		Buckets:       make([]ThroughputBucket, 0, complete+1),
+	}
+	var total ThroughputBucket
+	for i := complete; i >= 0; i-- {
+		start := current.Add(-time.Duration(i) * ThroughputBucketSize)
+		bucket := t.buckets[int(start.Unix()/int64(ThroughputBucketSize/time.Second))%len(t.buckets)]
+		if !bucket.Start.Equal(start) {
+			bucket = ThroughputBucket{Start: start} // Nothing recorded in this bucket
+		}
+		stats.Buckets = append(stats.Buckets, bucket)
+
+		if i > 0 {
+			total.MessagesIn += bucket.MessagesIn
+			total.MessagesOut += bucket.MessagesOut
+			total.BytesIn += bucket.BytesIn
+			total.BytesOut += bucket.BytesOut
+		}
+	}
+
+	seconds := float64(complete) * ThroughputBucketSize.Seconds()
+	stats.MessagesInPerSec = float64(total.MessagesIn) / seconds
+	stats.MessagesOutPerSec = float64(total.MessagesOut) / seconds
+	stats.BytesInPerSec = float64(total.BytesIn) / seconds
+	stats.BytesOutPerSec = float64(total.BytesOut) / seconds
+	return stats
+}
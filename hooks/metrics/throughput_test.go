@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+func TestThroughputTracker_Rates(t *testing.T) {
+	tracker := NewThroughputTracker()
+	now := time.Unix(1_700_000_000, 0) // On a bucket boundary
+	tracker.now = func() time.Time { return now }
+
+	// 60 publishes of 100 bytes in each of the last three buckets
+	for bucket := 0; bucket < 3; bucket++ {
+		for i := 0; i < 60; i++ {
+			tracker.RecordIn(100)
+		}
+		tracker.RecordOut(50)
+		now = now.Add(ThroughputBucketSize)
+	}
+	tracker.RecordIn(100) // Current bucket, not part of the rates
+
+	stats := tracker.Throughput(30 * time.Second)
+	if stats.BucketSeconds != 10 {
+		t.Errorf("BucketSeconds = %d, want 10", stats.BucketSeconds)
+	}
+	if len(stats.Buckets) != 4 {
+		t.Fatalf("len(Buckets) = %d, want 3 complete + 1 current", len(stats.Buckets))
+	}
+	if last := stats.Buckets[3]; !last.Start.Equal(now) || last.MessagesIn != 1 {
+		t.Errorf("current bucket = %+v, want 1 message starting %v", last, now)
+	}
+	if stats.MessagesInPerSec != 6 || stats.BytesInPerSec != 600 {
+		t.Errorf("in rate = %v msg/s, %v B/s; want 6 and 600", stats.MessagesInPerSec, stats.BytesInPerSec)
+	}
+	if stats.MessagesOutPerSec != 0.1 || stats.BytesOutPerSec != 5 {
+		t.Errorf("out rate = %v msg/s, %v B/s; want 0.1 and 5", stats.MessagesOutPerSec, stats.BytesOutPerSec)
+	}
+
+	// A wider window averages in the idle buckets before the traffic
+	if stats := tracker.Throughput(time.Minute); stats.MessagesInPerSec != 3 {
+		t.Errorf("1m in rate = %v msg/s, want 3", stats.MessagesInPerSec)
+	}
+
+	// Buckets older than the retention are no longer reported
+	now = now.Add(ThroughputRetention + ThroughputBucketSize)
+	if stats := tracker.Throughput(ThroughputRetention); stats.MessagesInPerSec != 0 {
+		t.Errorf("rate after retention = %v msg/s, want 0", stats.MessagesInPerSec)
+	}
+}
+
+func TestMetricsHook_Throughput(t *testing.T) {
+	hook := NewMetricsHook(NewMockMetricsRecorder())
+	cl := &mqtt.Client{ID: "c1"}
+
+	for i := 0; i < 5; i++ {
+		_, _ = hook.OnPacketRead(cl, packets.Packet{
+			FixedHeader: packets.FixedHeader{Type: packets.Publish, Remaining: 8},
+			TopicName:   "sensors/temp",
+		})
+	}
+	_, _ = hook.OnPacketRead(cl, packets.Packet{FixedHeader: packets.FixedHeader{Type: packets.Pingreq}})
+
+	stats := hook.Throughput().Throughput(time.Minute)
+	current := stats.Buckets[len(stats.Buckets)-1]
+	if current.MessagesIn != 5 || current.BytesIn != 50 {
+		t.Errorf("current bucket = %+v, want 5 messages and 50 bytes in", current)
+	}
+}
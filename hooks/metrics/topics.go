@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRecentTopicsWindow is how long a topic stays in the recent set after its last publish
+	DefaultRecentTopicsWindow = 5 * time.Minute
+
+	// DefaultMaxRecentTopics bounds memory usage of the tracker
+	DefaultMaxRecentTopics = 1000
+)
+
+// topicStats holds per-topic counters for the recent topic tracker
+type topicStats struct {
+	topic    string
+	count    int64
+	lastSeen time.Time
+}
+
+// TopicTracker keeps an in-memory record of recently published topics
+// Topics not seen within the window are dropped, and the least recently seen topic is
+// evicted when the tracker is full
+type TopicTracker struct {
+	mu        sync.Mutex
+	topics    map[string]*list.Element // Topic -> element holding *topicStats
+	order     *list.List               // Most recently seen first
+	window    time.Duration
+	maxTopics int
+}
+
+// NewTopicTracker creates a new recent topic tracker
+func NewTopicTracker(window time.Duration, maxTopics int) *TopicTracker {
+	if window <= 0 {
+		window = DefaultRecentTopicsWindow
+	}
+	if maxTopics <= 0 {
+		maxTopics = DefaultMaxRecentTopics
+	}
+
+	return &TopicTracker{
+		topics:    make(map[string]*list.Element),
+		order:     list.New(),
+		window:    window,
+		maxTopics: maxTopics,
+	}
+}
+
+// Record counts a message published to topic
+func (t *TopicTracker) Record(topic string) {
+	if topic == "" {
+		return
+	}
+
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if elem, ok := t.topics[topic]; ok {
+		stats := elem.Value.(*topicStats)
+		stats.count++
+		stats.lastSeen = now
+		t.order.MoveToFront(elem)
+		return
+	}
+
+	if t.order.Len() >= t.maxTopics {
+		oldest := t.order.Back()
+		t.order.Remove(oldest)
+		delete(t.topics, oldest.Value.(*topicStats).topic)
+	}
+
+	t.topics[topic] = t.order.PushFront(&topicStats{topic: topic, count: 1, lastSeen: now})
+}
+
+// RecentTopics returns message counts for topics seen within the window
+func (t *TopicTracker) RecentTopics() map[string]int64 {
+	cutoff := time.Now().Add(-t.window)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Expired topics sit at the least recently seen end
+	for elem := t.order.Back(); elem != nil && elem.Value.(*topicStats).lastSeen.Before(cutoff); elem = t.order.Back() {
+		t.order.Remove(elem)
+		delete(t.topics, elem.Value.(*topicStats).topic)
+	}
+
+	result := make(map[string]int64, len(t.topics))
+	for topic, elem := range t.topics {
+		result[topic] = elem.Value.(*topicStats).count
+	}
+
+	return result
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTopicTracker_Record(t *testing.T) {
+	tracker := NewTopicTracker(time.Minute, 10)
+
+	tracker.Record("sensors/temp")
+	tracker.Record("sensors/temp")
+	tracker.Record("sensors/humidity")
+	tracker.Record("") // Ignored
+
+	topics := tracker.RecentTopics()
+	if len(topics) != 2 {
+		t.Fatalf("Expected 2 topics, got %d", len(topics))
+	}
+	if topics["sensors/temp"] != 2 {
+		t.Errorf("Expected 2 messages for sensors/temp, got %d", topics["sensors/temp"])
+	}
+	if topics["sensors/humidity"] != 1 {
+		t.Errorf("Expected 1 message for sensors/humidity, got %d", topics["sensors/humidity"])
+	}
+}
+
+func TestTopicTracker_EvictsOldest(t *testing.T) {
+	tracker := NewTopicTracker(time.Minute, 3)
+
+	for i := 0; i < 4; i++ {
+		tracker.Record(fmt.Sprintf("topic/%d", i))
+		time.Sleep(time.Millisecond) // Ensure distinct last-seen times
+	}
+
+	topics := tracker.RecentTopics()
+	if len(topics) != 3 {
+		t.Fatalf("Expected 3 topics, got %d", len(topics))
+	}
+	if _, ok := topics["topic/0"]; ok {
+		t.Error("Expected oldest topic to be evicted")
+	}
+}
+
+func TestTopicTracker_EvictsLeastRecentlySeen(t *testing.T) {
+	tracker := NewTopicTracker(time.Minute, 3)
+
+	tracker.Record("topic/0")
+	tracker.Record("topic/1")
+	tracker.Record("topic/2")
+	tracker.Record("topic/0") // Seen again, so topic/1 is now the oldest
+	tracker.Record("topic/3")
+
+	topics := tracker.RecentTopics()
+	if _, ok := topics["topic/1"]; ok {
+		t.Error("Expected least recently seen topic to be evicted")
+	}
+	if topics["topic/0"] != 2 {
+		t.Errorf("Expected 2 messages for topic/0, got %d", topics["topic/0"])
+	}
+}
+
+func TestTopicTracker_Window(t *testing.T) {
+	tracker := NewTopicTracker(10*time.Millisecond, 10)
+
+	tracker.Record("old/topic")
+	time.Sleep(20 * time.Millisecond)
+	tracker.Record("new/topic")
+
+	topics := tracker.RecentTopics()
+	if _, ok := topics["old/topic"]; ok {
+		t.Error("Expected topic outside window to be dropped")
+	}
+	if _, ok := topics["new/topic"]; !ok {
+		t.Error("Expected recent topic to be present")
+	}
+}
@@ -0,0 +1,216 @@
+package recording
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github/bromq-dev/bromq/internal/badgerstore"
+	"github/bromq-dev/bromq/internal/redact"
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+// Config holds message recording configuration
+type Config struct {
+	Enabled     bool   `env:"RECORDING_ENABLED" flag:"recording" desc:"Record published messages for debugging and replay"`
+	Topics      string `env:"RECORDING_TOPICS" flag:"recording-topics" default:"#" desc:"Comma-separated topic filters to record (MQTT wildcards allowed)"`
+	MaxMessages int    `env:"RECORDING_MAX_MESSAGES" flag:"recording-max-messages" default:"1000" desc:"Maximum recorded messages to keep (oldest are dropped)"`
+}
+
+// DefaultMaxMessages is used when Config.MaxMessages is not positive
+const DefaultMaxMessages = 1000
+
+// flushInterval is how often buffered recordings are written
+const flushInterval = time.Second
+
+// RecordingStore interface for persisting recorded messages
+type RecordingStore interface {
+	SaveRecordedMessages(msgs []*badgerstore.RecordedMessage) error
+	CountRecordedMessages() (int, error)
+	TrimRecordedMessages(max int) (int, error)
+}
+
+// RecordingHook stores published messages matching the configured filters
+// in a capped ring, so they can be inspected and replayed from the API
+// OnPublish only buffers the message; a background loop writes the buffer and
+// trims the ring once per flush, keeping storage off the publish path
+type RecordingHook struct {
+	mqtt.HookBase
+	store       RecordingStore
+	filters     []string
+	maxMessages int
+	redactor    *redact.Redactor
+
+	mu      sync.Mutex
+	pending []*badgerstore.RecordedMessage // Awaiting the next flush, oldest first
+
+	flushMu sync.Mutex // Serializes flushes; guards count
+	count   int        // Stored recordings
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewRecordingHook creates a new message recording hook
+func NewRecordingHook(store RecordingStore, cfg *Config) *RecordingHook {
+	maxMessages := cfg.MaxMessages
+	if maxMessages <= 0 {
+		maxMessages = DefaultMaxMessages
+	}
+
+	var filters []string
+	for _, filter := range strings.Split(cfg.Topics, ",") {
+		if filter = strings.TrimSpace(filter); filter != "" {
+			filters = append(filters, filter)
+		}
+	}
+
+	// Pick up recordings left over from a previous run so the cap holds across restarts
+	count, err := store.CountRecordedMessages()
+	if err != nil {
+		slog.Warn("Failed to count existing recordings", "error", err)
+	}
+
+	return &RecordingHook{
+		store:       store,
+		filters:     filters,
+		maxMessages: maxMessages,
+		count:       count,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// SetRedactor masks the payloads of redacted topics in recordings
+// Such recordings are flagged Redacted and cannot be replayed
+func (h *RecordingHook) SetRedactor(redactor *redact.Redactor) {
+	h.redactor = redactor
+}
+
+// ID returns the hook identifier
+func (h *RecordingHook) ID() string {
+	return "message-recording"
+}
+
+// Provides indicates which hook methods this hook provides
+func (h *RecordingHook) Provides(b byte) bool {
+	return bytes.Contains([]byte{
+		mqtt.OnPublish,
+	}, []byte{b})
+}
+
+// Init starts the flush loop (called by the server when the hook is added)
+func (h *RecordingHook) Init(config any) error {
+	go h.flushLoop()
+	return nil
+}
+
+// Stop writes any buffered recordings (called by the server on shutdown)
+func (h *RecordingHook) Stop() error {
+	h.stopOnce.Do(func() { close(h.stop) })
+	<-h.done
+	return nil
+}
+
+// flushLoop writes buffered recordings every flushInterval until stopped
+func (h *RecordingHook) flushLoop() {
+	defer close(h.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.Flush()
+		case <-h.stop:
+			h.Flush()
+			return
+		}
+	}
+}
+
+// Flush writes the buffered recordings in one batch, then trims the ring to the cap
+func (h *RecordingHook) Flush() {
+	h.flushMu.Lock()
+	defer h.flushMu.Unlock()
+
+	h.mu.Lock()
+	batch := h.pending
+	h.pending = nil
+	h.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	if err := h.store.SaveRecordedMessages(batch); err != nil {
+		slog.Error("Failed to record messages", "count", len(batch), "error", err)
+		return
+	}
+
+	h.count += len(batch)
+	if h.count > h.maxMessages {
+		if _, err := h.store.TrimRecordedMessages(h.maxMessages); err != nil {
+			slog.Error("Failed to trim recorded messages", "error", err)
+			return
+		}
+		h.count = h.maxMessages
+	}
+}
+
+// OnPublish records messages whose topic matches one of the filters
+func (h *RecordingHook) OnPublish(cl *mqtt.Client, pk packets.Packet) (packets.Packet, error) {
+	// Skip broker-originated messages ($SYS stats, script output, replays)
+	// so replaying a recording does not record it again
+	if cl.Net.Inline && cl.ID == mqtt.InlineClientId {
+		return pk, nil
+	}
+
+	if !h.matches(pk.TopicName) {
+		return pk, nil
+	}
+
+	redacted := h.redactor.Redacts(pk.TopicName)
+	payload := pk.Payload
+	if redacted {
+		payload = []byte(redact.Mask)
+	}
+
+	msg := &badgerstore.RecordedMessage{
+		ClientID:   cl.ID,
+		Topic:      pk.TopicName,
+		Payload:    payload,
+		QoS:        pk.FixedHeader.Qos,
+		Retain:     pk.FixedHeader.Retain,
+		Redacted:   redacted,
+		RecordedAt: time.Now(),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Older buffered messages would be trimmed right after the flush anyway
+	if len(h.pending) >= h.maxMessages {
+		h.pending = h.pending[1:]
+	}
+	h.pending = append(h.pending, msg)
+
+	return pk, nil
+}
+
+// matches reports whether topic matches any configured filter
+func (h *RecordingHook) matches(topic string) bool {
+	for _, filter := range h.filters {
+		if storage.MatchTopic(filter, topic) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package recording
+
+import (
+	"testing"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github/bromq-dev/bromq/internal/badgerstore"
+)
+
+func publishPacket(topic, payload string) packets.Packet {
+	return packets.Packet{
+		FixedHeader: packets.FixedHeader{Type: packets.Publish, Qos: 1},
+		TopicName:   topic,
+		Payload:     []byte(payload),
+	}
+}
+
+func TestRecordingHook_ID(t *testing.T) {
+	hook := NewRecordingHook(badgerstore.OpenInMemory(t), &Config{Topics: "#"})
+
+	if hook.ID() != "message-recording" {
+		t.Errorf("RecordingHook.ID() = %v, want message-recording", hook.ID())
+	}
+}
+
+func TestRecordingHook_OnPublish(t *testing.T) {
+	store := badgerstore.OpenInMemory(t)
+	hook := NewRecordingHook(store, &Config{Topics: "sensors/#, alerts/+", MaxMessages: 10})
+
+	client := &mqtt.Client{ID: "device-1"}
+	inline := &mqtt.Client{ID: mqtt.InlineClientId, Net: mqtt.ClientConnection{Inline: true}}
+
+	_, _ = hook.OnPublish(client, publishPacket("sensors/temp", "21.5"))
+	_, _ = hook.OnPublish(client, publishPacket("alerts/fire", "true"))
+	_, _ = hook.OnPublish(client, publishPacket("other/topic", "ignored"))
+	_, _ = hook.OnPublish(inline, publishPacket("sensors/replayed", "ignored"))
+	hook.Flush()
+
+	msgs, total, err := store.ListRecordedMessages(1, 25)
+	if err != nil {
+		t.Fatalf("ListRecordedMessages() error = %v", err)
+	}
+	if total != 2 {
+		t.Fatalf("recorded %d messages, want 2", total)
+	}
+
+	// Newest first
+	if msgs[0].Topic != "alerts/fire" || msgs[1].Topic != "sensors/temp" {
+		t.Errorf("recorded topics = [%s %s], want [alerts/fire sensors/temp]", msgs[0].Topic, msgs[1].Topic)
+	}
+	if string(msgs[1].Payload) != "21.5" || msgs[1].ClientID != "device-1" || msgs[1].QoS != 1 {
+		t.Errorf("recorded message = %+v", msgs[1])
+	}
+}
+
+func TestRecordingHook_RetentionCap(t *testing.T) {
+	store := badgerstore.OpenInMemory(t)
+	hook := NewRecordingHook(store, &Config{Topics: "#", MaxMessages: 3})
+
+	// The ring is trimmed once per flush
+	client := &mqtt.Client{ID: "device-1"}
+	for _, payload := range []string{"1", "2"} {
+		_, _ = hook.OnPublish(client, publishPacket("counter", payload))
+	}
+	hook.Flush()
+	for _, payload := range []string{"3", "4", "5"} {
+		_, _ = hook.OnPublish(client, publishPacket("counter", payload))
+	}
+	hook.Flush()
+
+	msgs, total, err := store.ListRecordedMessages(1, 25)
+	if err != nil {
+		t.Fatalf("ListRecordedMessages() error = %v", err)
+	}
+	if total != 3 {
+		t.Fatalf("recorded %d messages, want 3", total)
+	}
+
+	// Oldest messages are dropped first
+	for i, want := range []string{"5", "4", "3"} {
+		if string(msgs[i].Payload) != want {
+			t.Errorf("msgs[%d].Payload = %s, want %s", i, msgs[i].Payload, want)
+		}
+	}
+}
+
+func TestRecordingHook_BufferCap(t *testing.T) {
+	store := badgerstore.OpenInMemory(t)
+	hook := NewRecordingHook(store, &Config{Topics: "#", MaxMessages: 3})
+
+	// Nothing is written until the flush, and the buffer keeps only the newest messages
+	client := &mqtt.Client{ID: "device-1"}
+	for _, payload := range []string{"1", "2", "3", "4", "5"} {
+		_, _ = hook.OnPublish(client, publishPacket("counter", payload))
+	}
+	if _, total, _ := store.ListRecordedMessages(1, 25); total != 0 {
+		t.Fatalf("recorded %d messages before the flush, want 0", total)
+	}
+	hook.Flush()
+
+	msgs, total, err := store.ListRecordedMessages(1, 25)
+	if err != nil {
+		t.Fatalf("ListRecordedMessages() error = %v", err)
+	}
+	if total != 3 {
+		t.Fatalf("recorded %d messages, want 3", total)
+	}
+	for i, want := range []string{"5", "4", "3"} {
+		if string(msgs[i].Payload) != want {
+			t.Errorf("msgs[%d].Payload = %s, want %s", i, msgs[i].Payload, want)
+		}
+	}
+
+	// IDs are unique even when messages share a timestamp
+	seen := make(map[string]bool)
+	for _, msg := range msgs {
+		if seen[msg.ID] {
+			t.Errorf("duplicate recording ID %s", msg.ID)
+		}
+		seen[msg.ID] = true
+	}
+}
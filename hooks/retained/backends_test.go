@@ -0,0 +1,109 @@
+package retained
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github/bromq-dev/bromq/internal/badgerstore"
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+// retainedBackends returns a fresh store per backend, so the same tests run against each
+func retainedBackends(t *testing.T) map[string]func(t *testing.T) RetainedStore {
+	return map[string]func(t *testing.T) RetainedStore{
+		"mock": func(t *testing.T) RetainedStore {
+			return NewMockRetainedStore()
+		},
+		BackendBadger: func(t *testing.T) RetainedStore {
+			return badgerstore.OpenInMemory(t)
+		},
+		BackendSQL: func(t *testing.T) RetainedStore {
+			config := storage.DefaultSQLiteConfig(filepath.Join(t.TempDir(), "retained.db"))
+			db, err := storage.OpenWithCache(config, storage.NewCacheWithRegistry(prometheus.NewRegistry()))
+			if err != nil {
+				t.Fatalf("failed to open test database: %v", err)
+			}
+			t.Cleanup(func() { _ = db.Close() })
+			return NewSQLStore(db)
+		},
+	}
+}
+
+func TestRetainedBackends(t *testing.T) {
+	for name, open := range retainedBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			t.Run("save, update and get", func(t *testing.T) {
+				store := open(t)
+				hook := NewRetainedHook(store)
+				client := &mqtt.Client{ID: "test-client"}
+
+				hook.OnRetainMessage(client, packets.Packet{TopicName: "sensor/temp", Payload: []byte("22.5"), FixedHeader: packets.FixedHeader{Qos: 1}}, 1)
+				hook.OnRetainMessage(client, packets.Packet{TopicName: "sensor/temp", Payload: []byte("23.0"), FixedHeader: packets.FixedHeader{Qos: 2}}, 1)
+
+				msg, err := store.GetRetainedMessage("sensor/temp")
+				if err != nil || msg == nil {
+					t.Fatalf("GetRetainedMessage() = %v, %v; want the message", msg, err)
+				}
+				if string(msg.Payload) != "23.0" || msg.QoS != 2 {
+					t.Errorf("stored message = %s (QoS %d), want 23.0 (QoS 2)", msg.Payload, msg.QoS)
+				}
+
+				if msg, err := store.GetRetainedMessage("sensor/none"); err != nil || msg != nil {
+					t.Errorf("GetRetainedMessage() for a missing topic = %v, %v; want nil, nil", msg, err)
+				}
+			})
+
+			t.Run("delete and expire", func(t *testing.T) {
+				store := open(t)
+				hook := NewRetainedHook(store)
+				_ = store.SaveRetainedMessage("a", []byte("1"), 0)
+				_ = store.SaveRetainedMessage("b", []byte("2"), 0)
+
+				hook.OnRetainMessage(&mqtt.Client{ID: "test-client"}, packets.Packet{TopicName: "a"}, -1)
+				hook.OnRetainedExpired("b")
+
+				all, err := store.GetAllRetainedMessages()
+				if err != nil || len(all) != 0 {
+					t.Errorf("GetAllRetainedMessages() = %d messages, %v; want none", len(all), err)
+				}
+				if deleted, err := store.DeleteRetainedMessage("a"); err != nil || deleted != 0 {
+					t.Errorf("DeleteRetainedMessage() of a missing topic = %d, %v; want 0", deleted, err)
+				}
+			})
+
+			t.Run("stored messages restore and iterate", func(t *testing.T) {
+				store := open(t)
+				hook := NewRetainedHook(store)
+				for i := 0; i < 3; i++ {
+					_ = store.SaveRetainedMessage(fmt.Sprintf("device/%d/status", i), []byte("online"), 1)
+				}
+
+				restored, err := hook.StoredRetainedMessages()
+				if err != nil || len(restored) != 3 {
+					t.Fatalf("StoredRetainedMessages() = %d messages, %v; want 3", len(restored), err)
+				}
+				for _, msg := range restored {
+					if !msg.FixedHeader.Retain || string(msg.Payload) != "online" {
+						t.Errorf("restored message %s = %s (retain %v), want online retained", msg.TopicName, msg.Payload, msg.FixedHeader.Retain)
+					}
+				}
+
+				var topics []string
+				err = store.ForEachRetainedMessage(func(msg *badgerstore.RetainedMessage) error {
+					topics = append(topics, msg.Topic)
+					return nil
+				})
+				sort.Strings(topics)
+				if err != nil || len(topics) != 3 || topics[0] != "device/0/status" {
+					t.Errorf("ForEachRetainedMessage() visited %v, %v; want the 3 topics", topics, err)
+				}
+			})
+		})
+	}
+}
@@ -3,6 +3,7 @@ package retained
 import (
 	"bytes"
 	"log/slog"
+	"sync"
 	"time"
 
 	mqtt "github.com/mochi-mqtt/server/v2"
@@ -13,26 +14,55 @@ import (
 )
 
 // RetainedStore interface for storing retained messages
+// Implemented by BadgerStore (default) and SQLStore, see MQTT_RETAINED_BACKEND
 type RetainedStore interface {
 	SaveRetainedMessage(topic string, payload []byte, qos byte) error
-	DeleteRetainedMessage(topic string) error
+	DeleteRetainedMessage(topic string) (int64, error)
 	GetRetainedMessage(topic string) (*badgerstore.RetainedMessage, error)
 	GetAllRetainedMessages() ([]*badgerstore.RetainedMessage, error)
+	ForEachRetainedMessage(fn func(*badgerstore.RetainedMessage) error) error
+	CountRetainedMessages() (int64, error)
+}
+
+// RetainedMetrics receives the total size of retained payloads (see mqtt.PrometheusMetrics)
+type RetainedMetrics interface {
+	SetRetainedBytes(bytes int64)
 }
 
 // RetainedHook implements MQTT hook for persisting retained messages
+// It is also a RetainedStore that accounts the payload bytes held in storage;
+// the API writes through it so its deletes and imports are counted too
 type RetainedHook struct {
 	mqtt.HookBase
-	store RetainedStore
+	store   RetainedStore
+	metrics RetainedMetrics
+
+	mu    sync.Mutex
+	sizes map[string]int // topic -> stored payload size
+	bytes int64
 }
 
 // NewRetainedHook creates a new retained message persistence hook
 func NewRetainedHook(store RetainedStore) *RetainedHook {
 	return &RetainedHook{
 		store: store,
+		sizes: make(map[string]int),
 	}
 }
 
+// SetMetrics reports the accounted payload bytes in mqtt_retained_bytes
+func (h *RetainedHook) SetMetrics(metrics RetainedMetrics) {
+	h.metrics = metrics
+	metrics.SetRetainedBytes(h.RetainedBytes())
+}
+
+// RetainedBytes returns the total payload size of the stored retained messages
+func (h *RetainedHook) RetainedBytes() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.bytes
+}
+
 // ID returns the hook identifier
 func (h *RetainedHook) ID() string {
 	return "retained-persistence"
@@ -53,7 +83,7 @@ func (h *RetainedHook) OnRetainMessage(cl *mqtt.Client, pk packets.Packet, r int
 
 	// r == -1 means delete the retained message (empty payload)
 	if r == -1 {
-		if err := h.store.DeleteRetainedMessage(topic); err != nil {
+		if _, err := h.DeleteRetainedMessage(topic); err != nil {
 			slog.Error("Failed to delete retained message", "topic", topic, "error", err)
 		}
 		return
@@ -61,7 +91,7 @@ func (h *RetainedHook) OnRetainMessage(cl *mqtt.Client, pk packets.Packet, r int
 
 	// Save retained message (upsert)
 	qos := pk.FixedHeader.Qos
-	if err := h.store.SaveRetainedMessage(topic, pk.Payload, qos); err != nil {
+	if err := h.SaveRetainedMessage(topic, pk.Payload, qos); err != nil {
 		slog.Error("Failed to save retained message", "topic", topic, "error", err)
 	}
 }
@@ -69,7 +99,7 @@ func (h *RetainedHook) OnRetainMessage(cl *mqtt.Client, pk packets.Packet, r int
 // StoredRetainedMessages returns all stored retained messages from the database
 // This is called by mochi-mqtt on startup to load retained messages into memory
 func (h *RetainedHook) StoredRetainedMessages() ([]storage.Message, error) {
-	dbMessages, err := h.store.GetAllRetainedMessages()
+	dbMessages, err := h.Recount()
 	if err != nil {
 		slog.Error("Failed to load retained messages from database", "error", err)
 		return nil, err
@@ -97,11 +127,99 @@ func (h *RetainedHook) StoredRetainedMessages() ([]storage.Message, error) {
 
 // OnRetainedExpired is called when a retained message expires
 func (h *RetainedHook) OnRetainedExpired(filter string) {
-	if err := h.store.DeleteRetainedMessage(filter); err != nil {
+	if _, err := h.DeleteRetainedMessage(filter); err != nil {
 		slog.Error("Failed to delete expired retained message", "filter", filter, "error", err)
 	}
 }
 
+// SaveRetainedMessage stores the message and accounts its payload in place of
+// any message it replaces
+func (h *RetainedHook) SaveRetainedMessage(topic string, payload []byte, qos byte) error {
+	return h.write(func() error {
+		if err := h.store.SaveRetainedMessage(topic, payload, qos); err != nil {
+			return err
+		}
+		h.bytes += int64(len(payload) - h.sizes[topic])
+		h.sizes[topic] = len(payload)
+		return nil
+	})
+}
+
+// DeleteRetainedMessage removes the message and its payload from the total
+func (h *RetainedHook) DeleteRetainedMessage(topic string) (int64, error) {
+	var deleted int64
+	err := h.write(func() error {
+		var err error
+		if deleted, err = h.store.DeleteRetainedMessage(topic); err != nil {
+			return err
+		}
+		h.bytes -= int64(h.sizes[topic])
+		delete(h.sizes, topic)
+		return nil
+	})
+	return deleted, err
+}
+
+// Recount reads every stored message and accounts them from scratch, which
+// corrects the total after the store was changed directly. It runs on startup
+// (StoredRetainedMessages) and on POST /api/admin/retained/reload, and returns
+// the messages read
+func (h *RetainedHook) Recount() ([]*badgerstore.RetainedMessage, error) {
+	var messages []*badgerstore.RetainedMessage
+	err := h.write(func() error {
+		var err error
+		if messages, err = h.store.GetAllRetainedMessages(); err != nil {
+			return err
+		}
+		h.sizes = make(map[string]int, len(messages))
+		h.bytes = 0
+		for _, msg := range messages {
+			h.sizes[msg.Topic] = len(msg.Payload)
+			h.bytes += int64(len(msg.Payload))
+		}
+		return nil
+	})
+	return messages, err
+}
+
+// GetRetainedMessage returns the stored message for topic
+func (h *RetainedHook) GetRetainedMessage(topic string) (*badgerstore.RetainedMessage, error) {
+	return h.store.GetRetainedMessage(topic)
+}
+
+// GetAllRetainedMessages returns every stored message
+func (h *RetainedHook) GetAllRetainedMessages() ([]*badgerstore.RetainedMessage, error) {
+	return h.store.GetAllRetainedMessages()
+}
+
+// ForEachRetainedMessage calls fn for each stored message
+func (h *RetainedHook) ForEachRetainedMessage(fn func(*badgerstore.RetainedMessage) error) error {
+	return h.store.ForEachRetainedMessage(fn)
+}
+
+// CountRetainedMessages returns how many messages are stored
+func (h *RetainedHook) CountRetainedMessages() (int64, error) {
+	return h.store.CountRetainedMessages()
+}
+
+// write runs op, a store write and its accounting, under the lock so
+// concurrent writes to a topic are accounted in the order they were stored,
+// then reports the new total
+func (h *RetainedHook) write(op func() error) error {
+	h.mu.Lock()
+	err := op()
+	total := h.bytes
+	h.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if h.metrics != nil {
+		h.metrics.SetRetainedBytes(total)
+	}
+	return nil
+}
+
 // retainedKey generates a unique key for a retained message
 func retainedKey(topic string) string {
 	return storage.RetainedKey + ":" + topic
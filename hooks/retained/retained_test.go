@@ -1,7 +1,6 @@
 package retained
 
 import (
-	"fmt"
 	"testing"
 
 	mqtt "github.com/mochi-mqtt/server/v2"
@@ -30,12 +29,12 @@ func (m *MockRetainedStore) SaveRetainedMessage(topic string, payload []byte, qo
 	return nil
 }
 
-func (m *MockRetainedStore) DeleteRetainedMessage(topic string) error {
+func (m *MockRetainedStore) DeleteRetainedMessage(topic string) (int64, error) {
 	if _, exists := m.messages[topic]; !exists {
-		return fmt.Errorf("message not found")
+		return 0, nil
 	}
 	delete(m.messages, topic)
-	return nil
+	return 1, nil
 }
 
 func (m *MockRetainedStore) GetRetainedMessage(topic string) (*badgerstore.RetainedMessage, error) {
@@ -54,6 +53,19 @@ func (m *MockRetainedStore) GetAllRetainedMessages() ([]*badgerstore.RetainedMes
 	return messages, nil
 }
 
+func (m *MockRetainedStore) ForEachRetainedMessage(fn func(*badgerstore.RetainedMessage) error) error {
+	for _, msg := range m.messages {
+		if err := fn(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockRetainedStore) CountRetainedMessages() (int64, error) {
+	return int64(len(m.messages)), nil
+}
+
 func TestRetainedHook_ID(t *testing.T) {
 	store := NewMockRetainedStore()
 	hook := NewRetainedHook(store)
@@ -314,3 +326,81 @@ func TestRetainedHook_OnRetainMessage_UpdateExisting(t *testing.T) {
 		t.Errorf("QoS = %d, want 2", msg.QoS)
 	}
 }
+
+// gaugeRecorder records the last retained bytes reported to it
+type gaugeRecorder struct {
+	bytes int64
+}
+
+func (g *gaugeRecorder) SetRetainedBytes(bytes int64) {
+	g.bytes = bytes
+}
+
+func TestRetainedHook_AccountsBytes(t *testing.T) {
+	store := NewMockRetainedStore()
+	_ = store.SaveRetainedMessage("stored/before-start", []byte("1234567890"), 0)
+
+	hook := NewRetainedHook(store)
+	gauge := &gaugeRecorder{}
+	hook.SetMetrics(gauge)
+
+	client := &mqtt.Client{ID: "test-client"}
+	retain := func(topic, payload string) {
+		r := int64(1)
+		if payload == "" {
+			r = -1
+		}
+		hook.OnRetainMessage(client, packets.Packet{TopicName: topic, Payload: []byte(payload)}, r)
+	}
+	check := func(step string, want int64) {
+		t.Helper()
+		if got := hook.RetainedBytes(); got != want {
+			t.Errorf("%s: RetainedBytes() = %d, want %d", step, got, want)
+		}
+		if gauge.bytes != want {
+			t.Errorf("%s: mqtt_retained_bytes = %d, want %d", step, gauge.bytes, want)
+		}
+	}
+
+	if _, err := hook.StoredRetainedMessages(); err != nil {
+		t.Fatalf("StoredRetainedMessages() error = %v", err)
+	}
+	check("load on startup", 10)
+
+	retain("sensor/temp", "22.5")
+	retain("sensor/humidity", "45")
+	check("save", 16)
+
+	retain("sensor/temp", "22.75")
+	check("replace", 17)
+
+	retain("sensor/temp", "")
+	check("delete", 12)
+
+	retain("sensor/unknown", "")
+	check("delete of an unstored topic", 12)
+
+	hook.OnRetainedExpired("sensor/humidity")
+	check("expiry", 10)
+
+	// Deletes and imports through the store API are counted too
+	if _, err := hook.DeleteRetainedMessage("stored/before-start"); err != nil {
+		t.Fatalf("DeleteRetainedMessage() error = %v", err)
+	}
+	if err := hook.SaveRetainedMessage("imported", []byte("abc"), 1); err != nil {
+		t.Fatalf("SaveRetainedMessage() error = %v", err)
+	}
+	check("store API", 3)
+
+	// Listing the messages leaves the total alone; a recount corrects it after
+	// the store was changed behind the hook's back
+	_ = store.SaveRetainedMessage("direct", []byte("12345"), 0)
+	if _, err := hook.GetAllRetainedMessages(); err != nil {
+		t.Fatalf("GetAllRetainedMessages() error = %v", err)
+	}
+	check("list", 3)
+	if _, err := hook.Recount(); err != nil {
+		t.Fatalf("Recount() error = %v", err)
+	}
+	check("recount", 8)
+}
@@ -0,0 +1,72 @@
+package retained
+
+import (
+	"github/bromq-dev/bromq/internal/badgerstore"
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+// Backends selectable with MQTT_RETAINED_BACKEND
+const (
+	BackendBadger = "badger" // Default: BadgerDB, built for high write throughput
+	BackendSQL    = "sql"    // The main SQL database, e.g. to share retained messages through Postgres
+)
+
+// SQLStore keeps retained messages in the SQL database
+type SQLStore struct {
+	db *storage.DB
+}
+
+// NewSQLStore creates a retained store backed by the SQL database
+func NewSQLStore(db *storage.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// SaveRetainedMessage stores or replaces the retained message for a topic
+func (s *SQLStore) SaveRetainedMessage(topic string, payload []byte, qos byte) error {
+	return s.db.SaveRetainedMessage(topic, payload, qos)
+}
+
+// DeleteRetainedMessage removes the retained message for a topic
+func (s *SQLStore) DeleteRetainedMessage(topic string) (int64, error) {
+	return s.db.DeleteRetainedMessage(topic)
+}
+
+// GetRetainedMessage returns the retained message for a topic, or nil if there is none
+func (s *SQLStore) GetRetainedMessage(topic string) (*badgerstore.RetainedMessage, error) {
+	msg, err := s.db.GetRetainedMessage(topic)
+	if err != nil || msg == nil {
+		return nil, err
+	}
+	return toRetainedMessage(msg), nil
+}
+
+// GetAllRetainedMessages returns every retained message
+func (s *SQLStore) GetAllRetainedMessages() ([]*badgerstore.RetainedMessage, error) {
+	var messages []*badgerstore.RetainedMessage
+	err := s.ForEachRetainedMessage(func(msg *badgerstore.RetainedMessage) error {
+		messages = append(messages, msg)
+		return nil
+	})
+	return messages, err
+}
+
+// ForEachRetainedMessage calls fn for every retained message, stopping at the first error
+func (s *SQLStore) ForEachRetainedMessage(fn func(*badgerstore.RetainedMessage) error) error {
+	return s.db.ForEachRetainedMessage(func(msg *storage.RetainedMessage) error {
+		return fn(toRetainedMessage(msg))
+	})
+}
+
+// CountRetainedMessages returns how many topics have a retained message
+func (s *SQLStore) CountRetainedMessages() (int64, error) {
+	return s.db.CountRetainedMessages()
+}
+
+func toRetainedMessage(msg *storage.RetainedMessage) *badgerstore.RetainedMessage {
+	return &badgerstore.RetainedMessage{
+		Topic:     msg.Topic,
+		Payload:   msg.Payload,
+		QoS:       msg.QoS,
+		CreatedAt: msg.CreatedAt,
+	}
+}
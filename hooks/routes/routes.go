@@ -0,0 +1,172 @@
+// Package routes republishes messages between local topics according to the
+// topic routes stored in the database, e.g. mirroring legacy/# to v2/# while
+// devices migrate to a new topic layout
+package routes
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+// ClientID is the inline client routed messages are published from
+const ClientID = "topic-routes"
+
+// RouteStore loads the configured topic routes
+type RouteStore interface {
+	ListTopicRoutes() ([]storage.TopicRoute, error)
+}
+
+// RouteHook republishes each accepted publish to the targets of the enabled
+// routes whose source matches its topic. Routed messages are never routed
+// again, so routes can't loop (legacy/# -> v2/# alongside v2/# -> legacy/#
+// republishes each message once)
+type RouteHook struct {
+	mqtt.HookBase
+	server *mqtt.Server
+	store  RouteStore
+	client *mqtt.Client
+
+	mu     sync.RWMutex
+	routes []storage.TopicRoute // Enabled routes only
+}
+
+// NewRouteHook creates a topic route hook publishing from an inline client on server
+// Call Reload to load the routes
+func NewRouteHook(server *mqtt.Server, store RouteStore) *RouteHook {
+	client := server.NewClient(nil, "local", ClientID, true)
+	server.Clients.Add(client)
+	return &RouteHook{
+		server: server,
+		store:  store,
+		client: client,
+	}
+}
+
+// ID returns the hook identifier
+func (h *RouteHook) ID() string {
+	return "topic-routes"
+}
+
+// Provides indicates which hook methods this hook provides
+func (h *RouteHook) Provides(b byte) bool {
+	return bytes.Contains([]byte{
+		mqtt.OnPublished,
+	}, []byte{b})
+}
+
+// Reload replaces the active routes with the enabled routes in the store
+func (h *RouteHook) Reload() error {
+	all, err := h.store.ListTopicRoutes()
+	if err != nil {
+		return err
+	}
+
+	var enabled []storage.TopicRoute
+	for _, route := range all {
+		if route.Enabled {
+			enabled = append(enabled, route)
+		}
+	}
+
+	h.mu.Lock()
+	h.routes = enabled
+	h.mu.Unlock()
+	slog.Debug("Topic routes loaded", "enabled", len(enabled), "total", len(all))
+	return nil
+}
+
+// OnPublished republishes a delivered message to the target of every matching route
+func (h *RouteHook) OnPublished(cl *mqtt.Client, pk packets.Packet) {
+	if cl.ID == ClientID || pk.Ignore {
+		return // Routed messages are not routed again
+	}
+	if cl.Net.Inline && strings.HasPrefix(pk.TopicName, "$SYS/") {
+		return // Broker stats
+	}
+
+	h.mu.RLock()
+	routes := h.routes
+	h.mu.RUnlock()
+
+	for _, route := range routes {
+		if !storage.MatchTopic(route.Source, pk.TopicName) {
+			continue
+		}
+		target := RouteTopic(pk.TopicName, route.Source, route.Target)
+		if target == pk.TopicName {
+			continue
+		}
+
+		routed := packets.Packet{
+			FixedHeader: packets.FixedHeader{
+				Type:   packets.Publish,
+				Qos:    pk.FixedHeader.Qos,
+				Retain: pk.FixedHeader.Retain,
+			},
+			PacketID:  uint16(pk.FixedHeader.Qos), // Inline publishes aren't acked, but QoS > 0 needs an ID to pass validation
+			TopicName: target,
+			Payload:   pk.Payload,
+			Properties: packets.Properties{
+				PayloadFormat:         pk.Properties.PayloadFormat,
+				PayloadFormatFlag:     pk.Properties.PayloadFormatFlag,
+				ContentType:           pk.Properties.ContentType,
+				MessageExpiryInterval: pk.Properties.MessageExpiryInterval,
+				User:                  pk.Properties.User,
+			},
+		}
+		if err := h.server.InjectPacket(h.client, routed); err != nil {
+			slog.Error("Failed to republish routed message",
+				"route_id", route.ID,
+				"topic", pk.TopicName,
+				"target", target,
+				"error", err)
+		}
+	}
+}
+
+// RouteTopic maps topic, which matches the source filter, onto the target
+// pattern: each + in the target takes the level matched by the next + in the
+// source, and a trailing # takes the levels the source's # matched
+// e.g. topic "legacy/kitchen/temp", source "legacy/#", target "v2/#" -> "v2/kitchen/temp"
+func RouteTopic(topic, source, target string) string {
+	topicLevels := strings.Split(topic, "/")
+	sourceLevels := strings.Split(source, "/")
+
+	var captured []string
+	var rest []string
+	for i, level := range sourceLevels {
+		if level == "#" {
+			if i < len(topicLevels) {
+				rest = topicLevels[i:]
+			}
+			break
+		}
+		if level == "+" && i < len(topicLevels) {
+			captured = append(captured, topicLevels[i])
+		}
+	}
+
+	targetLevels := strings.Split(target, "/")
+	result := make([]string, 0, len(targetLevels)+len(rest))
+	for _, level := range targetLevels {
+		switch level {
+		case "+":
+			if len(captured) > 0 {
+				level, captured = captured[0], captured[1:]
+			}
+		case "#":
+			// "legacy/#" also matches "legacy" itself, which maps to "v2"
+			result = append(result, rest...)
+			continue
+		}
+		result = append(result, level)
+	}
+	return strings.Join(result, "/")
+}
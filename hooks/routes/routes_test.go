@@ -0,0 +1,145 @@
+package routes
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+// staticRoutes is a RouteStore holding a fixed set of routes
+type staticRoutes []storage.TopicRoute
+
+func (s staticRoutes) ListTopicRoutes() ([]storage.TopicRoute, error) { return s, nil }
+
+// publishCapture records the topics of delivered messages
+type publishCapture struct {
+	mqtt.HookBase
+	mu     sync.Mutex
+	topics []string
+}
+
+func (c *publishCapture) ID() string { return "capture" }
+
+func (c *publishCapture) Provides(b byte) bool {
+	return bytes.Contains([]byte{mqtt.OnPublished}, []byte{b})
+}
+
+func (c *publishCapture) OnPublished(_ *mqtt.Client, pk packets.Packet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.topics = append(c.topics, pk.TopicName)
+}
+
+// newRouteTestServer starts a server with the given routes and returns it with
+// the capture hook and an inline client to publish from
+func newRouteTestServer(t *testing.T, routes staticRoutes) (*mqtt.Server, *publishCapture, *mqtt.Client) {
+	t.Helper()
+
+	server := mqtt.New(nil)
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	hook := NewRouteHook(server, routes)
+	if err := hook.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if err := server.AddHook(hook, nil); err != nil {
+		t.Fatal(err)
+	}
+	capture := &publishCapture{}
+	if err := server.AddHook(capture, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	device := server.NewClient(nil, "test", "device", true)
+	server.Clients.Add(device)
+	return server, capture, device
+}
+
+func publish(t *testing.T, server *mqtt.Server, cl *mqtt.Client, topic string) {
+	t.Helper()
+	pk := packets.Packet{
+		FixedHeader: packets.FixedHeader{Type: packets.Publish, Qos: 1},
+		PacketID:    1,
+		TopicName:   topic,
+		Payload:     []byte("21.5"),
+	}
+	if err := server.InjectPacket(cl, pk); err != nil {
+		t.Fatalf("InjectPacket() error = %v", err)
+	}
+}
+
+func TestRouteHook_Republishes(t *testing.T) {
+	server, capture, device := newRouteTestServer(t, staticRoutes{
+		{ID: 1, Source: "legacy/#", Target: "v2/#", Enabled: true},
+		{ID: 2, Source: "legacy/#", Target: "audit/#", Enabled: false},
+	})
+
+	publish(t, server, device, "legacy/x")
+
+	want := []string{"v2/x", "legacy/x"} // The routed message is delivered from within the original's OnPublished
+	if len(capture.topics) != len(want) || capture.topics[0] != want[0] || capture.topics[1] != want[1] {
+		t.Errorf("delivered topics = %v, want %v", capture.topics, want)
+	}
+
+	capture.topics = nil
+	publish(t, server, device, "other/x")
+	if len(capture.topics) != 1 {
+		t.Errorf("delivered topics for an unrouted publish = %v, want only the original", capture.topics)
+	}
+}
+
+func TestRouteHook_NoLoops(t *testing.T) {
+	server, capture, device := newRouteTestServer(t, staticRoutes{
+		{ID: 1, Source: "legacy/#", Target: "v2/#", Enabled: true},
+		{ID: 2, Source: "v2/#", Target: "legacy/#", Enabled: true},
+	})
+
+	publish(t, server, device, "legacy/x")
+
+	counts := map[string]int{}
+	for _, topic := range capture.topics {
+		counts[topic]++
+	}
+	if counts["legacy/x"] != 1 || counts["v2/x"] != 1 || len(capture.topics) != 2 {
+		t.Errorf("delivered topics = %v, want legacy/x and v2/x once each", capture.topics)
+	}
+}
+
+func TestRouteHook_SkipsSysTopics(t *testing.T) {
+	server, capture, device := newRouteTestServer(t, staticRoutes{
+		{ID: 1, Source: "$SYS/broker/#", Target: "stats/#", Enabled: true},
+	})
+
+	// Broker stats are published by an inline client
+	publish(t, server, device, "$SYS/broker/uptime")
+	if len(capture.topics) != 1 || capture.topics[0] != "$SYS/broker/uptime" {
+		t.Errorf("delivered topics = %v, want only $SYS/broker/uptime", capture.topics)
+	}
+}
+
+func TestRouteTopic(t *testing.T) {
+	tests := []struct {
+		topic, source, target string
+		want                  string
+	}{
+		{"legacy/x", "legacy/#", "v2/#", "v2/x"},
+		{"legacy/kitchen/temp", "legacy/#", "v2/#", "v2/kitchen/temp"},
+		{"legacy", "legacy/#", "v2/#", "v2"},
+		{"sensors/kitchen/temp", "sensors/+/temp", "rooms/+/temperature", "rooms/kitchen/temperature"},
+		{"a/1/b/2", "a/+/b/+", "x/+/+", "x/1/2"},
+		{"site/1/dev/7/status", "site/+/#", "sites/+/#", "sites/1/dev/7/status"},
+		{"alerts/fire", "alerts/fire", "notify/all", "notify/all"},
+	}
+	for _, tt := range tests {
+		if got := RouteTopic(tt.topic, tt.source, tt.target); got != tt.want {
+			t.Errorf("RouteTopic(%q, %q, %q) = %q, want %q", tt.topic, tt.source, tt.target, got, tt.want)
+		}
+	}
+}
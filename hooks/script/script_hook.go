@@ -2,6 +2,7 @@ package script
 
 import (
 	"bytes"
+	"strings"
 
 	mqtt "github.com/mochi-mqtt/server/v2"
 	"github.com/mochi-mqtt/server/v2/packets"
@@ -40,14 +41,22 @@ func (h *ScriptHook) Provides(b byte) bool {
 
 // OnPublish is called when a message is published
 func (h *ScriptHook) OnPublish(cl *mqtt.Client, pk packets.Packet) (packets.Packet, error) {
+	// Skip broker stats, published every $SYS interval by the broker itself
+	if cl.Net.Inline && strings.HasPrefix(pk.TopicName, "$SYS/") {
+		return pk, nil
+	}
+
 	message := &internalscript.Message{
-		Type:     "publish",
-		Topic:    pk.TopicName,
-		Payload:  string(pk.Payload),
-		ClientID: cl.ID,
-		Username: string(cl.Properties.Username),
-		QoS:      pk.FixedHeader.Qos,
-		Retain:   pk.FixedHeader.Retain,
+		Type:           "publish",
+		Topic:          pk.TopicName,
+		Payload:        string(pk.Payload),
+		ClientID:       cl.ID,
+		Username:       string(cl.Properties.Username),
+		QoS:            pk.FixedHeader.Qos,
+		Retain:         pk.FixedHeader.Retain,
+		UserProperties: internalscript.UserPropertiesMap(pk.Properties.User),
+		ContentType:    pk.Properties.ContentType,
+		PayloadFormat:  pk.Properties.PayloadFormat,
 	}
 
 	// Check if this message was published by a script (to prevent self-triggering)
@@ -66,10 +75,11 @@ func (h *ScriptHook) OnPublish(cl *mqtt.Client, pk packets.Packet) (packets.Pack
 // OnConnect is called when a client connects
 func (h *ScriptHook) OnConnect(cl *mqtt.Client, pk packets.Packet) error {
 	message := &internalscript.Message{
-		Type:         "connect",
-		ClientID:     cl.ID,
-		Username:     string(cl.Properties.Username),
-		CleanSession: pk.Connect.Clean,
+		Type:           "connect",
+		ClientID:       cl.ID,
+		Username:       string(cl.Properties.Username),
+		CleanSession:   pk.Connect.Clean,
+		UserProperties: internalscript.UserPropertiesMap(pk.Properties.User),
 	}
 
 	// Execute matching scripts asynchronously
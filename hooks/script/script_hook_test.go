@@ -154,6 +154,53 @@ func TestScriptHookOnPublish(t *testing.T) {
 	}
 }
 
+func TestScriptHookOnPublishUserProperties(t *testing.T) {
+	db, badger, hook, mqttServer := setupTestHook(t)
+	defer mqttServer.Close()
+
+	// Script branches on MQTT 5 user properties
+	script, _ := db.CreateScript("user-props", "", `
+		if (msg.userProperties.tenant === "acme") {
+			log.info("tenant=" + msg.userProperties.tenant + " region=" + msg.userProperties.region);
+		}
+	`, true, []byte("{}"), []storage.ScriptTrigger{
+		{Type: "on_publish", Topic: "test/#", Priority: 100, Enabled: true},
+	})
+
+	hook.ReloadScripts()
+
+	cl := &mqtt.Client{ID: "test-client"}
+	pk := packets.Packet{
+		FixedHeader: packets.FixedHeader{Type: packets.Publish},
+		TopicName:   "test/props",
+		Payload:     []byte("hello"),
+		Properties: packets.Properties{
+			User: []packets.UserProperty{
+				{Key: "tenant", Val: "acme"},
+				{Key: "region", Val: "eu-west"},
+			},
+		},
+	}
+
+	if _, err := hook.OnPublish(cl, pk); err != nil {
+		t.Fatalf("OnPublish returned error: %v", err)
+	}
+
+	// Give script time to execute
+	time.Sleep(100 * time.Millisecond)
+
+	logs, _, _ := badger.ListScriptLogs(script.ID, 1, 10, "info")
+	found := false
+	for _, entry := range logs {
+		if entry.Message == "tenant=acme region=eu-west" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected script to log user properties, got %+v", logs)
+	}
+}
+
 func TestScriptHookOnConnect(t *testing.T) {
 	db, badger, hook, mqttServer := setupTestHook(t)
 	defer mqttServer.Close()
@@ -0,0 +1,241 @@
+package sessions
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/storage"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// SessionStore interface for storing persistent MQTT sessions
+type SessionStore interface {
+	SaveSessionClient(client *storage.Client) error
+	SaveSessionSubscription(sub *storage.Subscription) error
+	DeleteSessionSubscription(clientID, filter string) error
+	SaveInflightMessage(msg *storage.Message) error
+	DeleteInflightMessage(clientID string, packetID uint16) error
+	DeleteSession(clientID string) error
+	GetSessionClients() ([]storage.Client, error)
+	GetSessionSubscriptions() ([]storage.Subscription, error)
+	GetInflightMessages() ([]storage.Message, error)
+}
+
+// SessionHook persists the sessions of clients that connect without a clean
+// session: the client, its subscriptions, and the QoS 1/2 messages queued for
+// it while offline or awaiting acknowledgement. The broker reloads them on
+// startup, so queued messages are delivered after a restart. Clients that
+// connect with a clean session (clean start under MQTT 5) are not stored
+type SessionHook struct {
+	mqtt.HookBase
+	store SessionStore
+}
+
+// NewSessionHook creates a new session persistence hook
+func NewSessionHook(store SessionStore) *SessionHook {
+	return &SessionHook{
+		store: store,
+	}
+}
+
+// ID returns the hook identifier
+func (h *SessionHook) ID() string {
+	return "session-persistence"
+}
+
+// Provides indicates which hook methods this hook provides
+func (h *SessionHook) Provides(b byte) bool {
+	return bytes.Contains([]byte{
+		mqtt.OnSessionEstablished,
+		mqtt.OnDisconnect,
+		mqtt.OnSubscribed,
+		mqtt.OnUnsubscribed,
+		mqtt.OnQosPublish,
+		mqtt.OnQosComplete,
+		mqtt.OnQosDropped,
+		mqtt.OnClientExpired,
+		mqtt.StoredClients,
+		mqtt.StoredSubscriptions,
+		mqtt.StoredInflightMessages,
+	}, []byte{b})
+}
+
+// OnSessionEstablished stores the client. A clean start discards whatever
+// was stored for the client ID before, matching the broker's in-memory state
+func (h *SessionHook) OnSessionEstablished(cl *mqtt.Client, pk packets.Packet) {
+	if pk.Connect.Clean {
+		if err := h.store.DeleteSession(cl.ID); err != nil {
+			slog.Error("Failed to clear stored session", "client_id", cl.ID, "error", err)
+		}
+		return
+	}
+	h.saveClient(cl)
+}
+
+// OnDisconnect keeps the session if it outlives the connection, otherwise removes it
+func (h *SessionHook) OnDisconnect(cl *mqtt.Client, err error, expire bool) {
+	if cl.Properties.Clean {
+		return // Nothing was stored
+	}
+	if !expire {
+		h.saveClient(cl)
+		return
+	}
+	// The new connection owns the session now
+	if errors.Is(cl.StopCause(), packets.ErrSessionTakenOver) {
+		return
+	}
+	h.deleteSession(cl.ID)
+}
+
+// OnClientExpired removes a session whose expiry interval has passed
+func (h *SessionHook) OnClientExpired(cl *mqtt.Client) {
+	h.deleteSession(cl.ID)
+}
+
+// OnSubscribed stores the granted subscriptions
+func (h *SessionHook) OnSubscribed(cl *mqtt.Client, pk packets.Packet, reasonCodes []byte) {
+	if cl.Properties.Clean {
+		return
+	}
+	for i, filter := range pk.Filters {
+		if i >= len(reasonCodes) || reasonCodes[i] >= packets.ErrUnspecifiedError.Code {
+			continue // Not granted
+		}
+		sub := &storage.Subscription{
+			ID:                storage.SubscriptionKey + "_" + cl.ID + ":" + filter.Filter,
+			T:                 storage.SubscriptionKey,
+			Client:            cl.ID,
+			Qos:               reasonCodes[i],
+			Filter:            filter.Filter,
+			Identifier:        filter.Identifier,
+			NoLocal:           filter.NoLocal,
+			RetainHandling:    filter.RetainHandling,
+			RetainAsPublished: filter.RetainAsPublished,
+		}
+		if err := h.store.SaveSessionSubscription(sub); err != nil {
+			slog.Error("Failed to store subscription", "client_id", cl.ID, "filter", filter.Filter, "error", err)
+		}
+	}
+}
+
+// OnUnsubscribed removes the subscriptions from the stored session
+func (h *SessionHook) OnUnsubscribed(cl *mqtt.Client, pk packets.Packet) {
+	if cl.Properties.Clean {
+		return
+	}
+	for _, filter := range pk.Filters {
+		if err := h.store.DeleteSessionSubscription(cl.ID, filter.Filter); err != nil {
+			slog.Error("Failed to delete stored subscription", "client_id", cl.ID, "filter", filter.Filter, "error", err)
+		}
+	}
+}
+
+// OnQosPublish stores a QoS 1/2 message sent (or queued) to a client
+func (h *SessionHook) OnQosPublish(cl *mqtt.Client, pk packets.Packet, sent int64, resends int) {
+	if cl.Properties.Clean {
+		return
+	}
+	props := pk.Properties.Copy(false)
+	msg := &storage.Message{
+		ID:          storage.InflightKey + "_" + cl.ID + ":" + pk.FormatID(),
+		T:           storage.InflightKey,
+		Client:      cl.ID,
+		Origin:      pk.Origin,
+		PacketID:    pk.PacketID,
+		FixedHeader: pk.FixedHeader,
+		TopicName:   pk.TopicName,
+		Payload:     pk.Payload,
+		Sent:        sent,
+		Created:     pk.Created,
+		Properties: storage.MessageProperties{
+			PayloadFormat:          props.PayloadFormat,
+			MessageExpiryInterval:  props.MessageExpiryInterval,
+			ContentType:            props.ContentType,
+			ResponseTopic:          props.ResponseTopic,
+			CorrelationData:        props.CorrelationData,
+			SubscriptionIdentifier: props.SubscriptionIdentifier,
+			TopicAlias:             props.TopicAlias,
+			User:                   props.User,
+		},
+	}
+	if err := h.store.SaveInflightMessage(msg); err != nil {
+		slog.Error("Failed to store inflight message", "client_id", cl.ID, "topic", pk.TopicName, "error", err)
+	}
+}
+
+// OnQosComplete removes a message once the client has acknowledged it
+func (h *SessionHook) OnQosComplete(cl *mqtt.Client, pk packets.Packet) {
+	if cl.Properties.Clean {
+		return
+	}
+	if err := h.store.DeleteInflightMessage(cl.ID, pk.PacketID); err != nil {
+		slog.Error("Failed to delete inflight message", "client_id", cl.ID, "packet_id", pk.PacketID, "error", err)
+	}
+}
+
+// OnQosDropped removes a message the broker gave up on (e.g. expired)
+func (h *SessionHook) OnQosDropped(cl *mqtt.Client, pk packets.Packet) {
+	h.OnQosComplete(cl, pk)
+}
+
+// StoredClients returns the stored sessions, loaded by mochi-mqtt on startup
+func (h *SessionHook) StoredClients() ([]storage.Client, error) {
+	clients, err := h.store.GetSessionClients()
+	if err != nil {
+		slog.Error("Failed to load stored sessions", "error", err)
+		return nil, err
+	}
+	slog.Info("Loaded persistent sessions", "count", len(clients))
+	return clients, nil
+}
+
+// StoredSubscriptions returns the subscriptions of the stored sessions
+func (h *SessionHook) StoredSubscriptions() ([]storage.Subscription, error) {
+	return h.store.GetSessionSubscriptions()
+}
+
+// StoredInflightMessages returns the undelivered messages of the stored sessions
+func (h *SessionHook) StoredInflightMessages() ([]storage.Message, error) {
+	return h.store.GetInflightMessages()
+}
+
+// saveClient stores the client's connection properties and will
+func (h *SessionHook) saveClient(cl *mqtt.Client) {
+	props := cl.Properties.Props.Copy(false)
+	client := &storage.Client{
+		ID:              cl.ID,
+		T:               storage.ClientKey,
+		Remote:          cl.Net.Remote,
+		Listener:        cl.Net.Listener,
+		Username:        cl.Properties.Username,
+		Clean:           cl.Properties.Clean,
+		ProtocolVersion: cl.Properties.ProtocolVersion,
+		Properties: storage.ClientProperties{
+			SessionExpiryInterval:     props.SessionExpiryInterval,
+			SessionExpiryIntervalFlag: props.SessionExpiryIntervalFlag,
+			AuthenticationMethod:      props.AuthenticationMethod,
+			AuthenticationData:        props.AuthenticationData,
+			RequestProblemInfoFlag:    props.RequestProblemInfoFlag,
+			RequestProblemInfo:        props.RequestProblemInfo,
+			RequestResponseInfo:       props.RequestResponseInfo,
+			ReceiveMaximum:            props.ReceiveMaximum,
+			TopicAliasMaximum:         props.TopicAliasMaximum,
+			User:                      props.User,
+			MaximumPacketSize:         props.MaximumPacketSize,
+		},
+		Will: storage.ClientWill(cl.Properties.Will),
+	}
+	if err := h.store.SaveSessionClient(client); err != nil {
+		slog.Error("Failed to store session", "client_id", cl.ID, "error", err)
+	}
+}
+
+// deleteSession removes everything stored for a client
+func (h *SessionHook) deleteSession(clientID string) {
+	if err := h.store.DeleteSession(clientID); err != nil {
+		slog.Error("Failed to delete stored session", "client_id", clientID, "error", err)
+	}
+}
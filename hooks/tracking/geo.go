@@ -0,0 +1,80 @@
+package tracking
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoCountryKey is the client metadata key holding the resolved country code
+const GeoCountryKey = "geo_country"
+
+// GeoResolver maps a remote IP to a coarse location
+type GeoResolver interface {
+	// Country returns the ISO 3166-1 alpha-2 country code, or "" if unknown
+	Country(ip net.IP) (string, error)
+}
+
+// MetadataMergeTracker is implemented by trackers that can set individual
+// client metadata keys without replacing the rest
+type MetadataMergeTracker interface {
+	MergeMQTTClientMetadata(clientID string, values map[string]interface{}) error
+}
+
+// MaxMindResolver resolves countries from a MaxMind GeoIP2/GeoLite2 Country or City database
+type MaxMindResolver struct {
+	reader *maxminddb.Reader
+}
+
+// OpenMaxMindResolver opens the MaxMind database at path
+func OpenMaxMindResolver(path string) (*MaxMindResolver, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database: %w", err)
+	}
+	return &MaxMindResolver{reader: reader}, nil
+}
+
+// Country looks up the country code of ip
+func (r *MaxMindResolver) Country(ip net.IP) (string, error) {
+	var record struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	if err := r.reader.Lookup(ip, &record); err != nil {
+		return "", err
+	}
+	return record.Country.ISOCode, nil
+}
+
+// Close releases the database
+func (r *MaxMindResolver) Close() error {
+	return r.reader.Close()
+}
+
+// SetGeoResolver enables adding the client's country to its metadata on connect
+// Lookups fail open: errors and unknown addresses leave the metadata untouched
+func (h *TrackingHook) SetGeoResolver(resolver GeoResolver) {
+	h.geo = resolver
+}
+
+// geoMetadata resolves the metadata keys to merge for a client's remote IP
+// Returns nil when nothing could be resolved
+func (h *TrackingHook) geoMetadata(remoteIP string) map[string]interface{} {
+	if h.geo == nil {
+		return nil
+	}
+
+	ip := net.ParseIP(remoteIP)
+	if ip == nil {
+		return nil // e.g. in-process or unix socket clients
+	}
+
+	country, err := h.geo.Country(ip)
+	if err != nil || country == "" {
+		return nil
+	}
+	return map[string]interface{}{GeoCountryKey: country}
+}
@@ -0,0 +1,100 @@
+package tracking
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// stubResolver resolves IPs from a fixed table
+type stubResolver map[string]string
+
+func (r stubResolver) Country(ip net.IP) (string, error) {
+	country, ok := r[ip.String()]
+	if !ok {
+		return "", errors.New("address not found")
+	}
+	return country, nil
+}
+
+// MockMergeTracker records merged metadata on top of MockClientTracker
+type MockMergeTracker struct {
+	*MockClientTracker
+	metadata map[string]map[string]interface{}
+}
+
+func (m *MockMergeTracker) MergeMQTTClientMetadata(clientID string, values map[string]interface{}) error {
+	if m.metadata[clientID] == nil {
+		m.metadata[clientID] = map[string]interface{}{}
+	}
+	for k, v := range values {
+		m.metadata[clientID][k] = v
+	}
+	return nil
+}
+
+func TestTrackingHook_GeoResolver(t *testing.T) {
+	tracker := &MockMergeTracker{MockClientTracker: NewMockClientTracker(), metadata: map[string]map[string]interface{}{}}
+	tracker.AddUser("sensor", 7)
+	hook := NewTrackingHook(tracker)
+	hook.SetGeoResolver(stubResolver{"192.0.2.10": "NZ"})
+
+	connect := packets.Packet{Connect: packets.ConnectParams{Username: []byte("sensor")}}
+
+	known := &mqtt.Client{ID: "known"}
+	known.Net.Remote = "192.0.2.10:40000"
+	unknown := &mqtt.Client{ID: "unknown"}
+	unknown.Net.Remote = "198.51.100.1:40000"
+	inline := &mqtt.Client{ID: "inline"}
+	inline.Net.Remote = "local"
+
+	for _, cl := range []*mqtt.Client{known, unknown, inline} {
+		if err := hook.OnConnect(cl, connect); err != nil {
+			t.Fatalf("OnConnect(%s) error = %v", cl.ID, err)
+		}
+		if !tracker.clients[cl.ID].IsActive {
+			t.Errorf("client %s not tracked; lookups must fail open", cl.ID)
+		}
+	}
+
+	if got := tracker.metadata["known"][GeoCountryKey]; got != "NZ" {
+		t.Errorf("%s = %v, want NZ", GeoCountryKey, got)
+	}
+	for _, id := range []string{"unknown", "inline"} {
+		if _, ok := tracker.metadata[id]; ok {
+			t.Errorf("metadata written for %s, want untouched on failed lookup", id)
+		}
+	}
+}
+
+func TestTrackingHook_GeoResolverBatched(t *testing.T) {
+	tracker := &MockBatchTracker{MockClientTracker: NewMockClientTracker()}
+	tracker.AddUser("sensor", 7)
+	hook := NewTrackingHook(tracker)
+	hook.SetGeoResolver(stubResolver{"192.0.2.10": "NZ"})
+	hook.SetBatchWindow(time.Hour) // Flush manually
+	defer func() { _ = hook.Stop() }()
+
+	cl := &mqtt.Client{ID: "known"}
+	cl.Net.Remote = "192.0.2.10:40000"
+	_ = hook.OnConnect(cl, packets.Packet{Connect: packets.ConnectParams{Username: []byte("sensor")}})
+	hook.OnDisconnect(cl, nil, false)
+	hook.Flush()
+
+	if len(tracker.batches) != 1 || len(tracker.batches[0]) != 1 {
+		t.Fatalf("batches = %+v, want one update", tracker.batches)
+	}
+	if got := tracker.batches[0][0].Metadata[GeoCountryKey]; got != "NZ" {
+		t.Errorf("batched %s = %v, want NZ", GeoCountryKey, got)
+	}
+}
+
+func TestOpenMaxMindResolver_Missing(t *testing.T) {
+	if _, err := OpenMaxMindResolver(t.TempDir() + "/missing.mmdb"); err == nil {
+		t.Error("OpenMaxMindResolver() error = nil, want error for a missing database")
+	}
+}
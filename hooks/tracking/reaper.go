@@ -0,0 +1,81 @@
+package tracking
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// InactiveClientReaper is implemented by trackers that can delete the records
+// of clients that have been disconnected for a long time
+type InactiveClientReaper interface {
+	DeleteInactiveMQTTClients(lastSeenBefore time.Time) (int64, error)
+}
+
+// reaper periodically deletes inactive client records not seen within retention
+type reaper struct {
+	store     InactiveClientReaper
+	retention time.Duration
+	stop      chan struct{}
+	stopOnce  sync.Once
+	done      chan struct{}
+}
+
+// SetInactiveRetention deletes the records of clients inactive for longer
+// than retention, checking every tenth of it (between a minute and an hour).
+// Requires the tracker to implement InactiveClientReaper; connected clients
+// are never deleted
+func (h *TrackingHook) SetInactiveRetention(retention time.Duration) {
+	if retention <= 0 || h.reaper != nil {
+		return
+	}
+
+	store, ok := h.tracker.(InactiveClientReaper)
+	if !ok {
+		slog.Warn("Client tracker does not support deleting inactive clients, keeping them")
+		return
+	}
+
+	h.reaper = &reaper{
+		store:     store,
+		retention: retention,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go h.reaper.run(min(max(retention/10, time.Minute), time.Hour))
+}
+
+// run reaps once at startup and then every interval until stopped
+func (r *reaper) run(interval time.Duration) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.reap()
+		select {
+		case <-ticker.C:
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// reap deletes the records of clients inactive for longer than the retention
+func (r *reaper) reap() {
+	deleted, err := r.store.DeleteInactiveMQTTClients(time.Now().Add(-r.retention))
+	if err != nil {
+		slog.Error("Failed to delete inactive clients", "error", err)
+		return
+	}
+	if deleted > 0 {
+		slog.Info("Deleted inactive clients", "count", deleted, "retention", r.retention)
+	}
+}
+
+// close stops the reaper and waits for a running reap to finish
+func (r *reaper) close() {
+	r.stopOnce.Do(func() { close(r.stop) })
+	<-r.done
+}
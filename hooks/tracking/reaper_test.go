@@ -0,0 +1,50 @@
+package tracking
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// MockReaperTracker records inactive client deletions on top of MockClientTracker
+type MockReaperTracker struct {
+	*MockClientTracker
+	mu      sync.Mutex
+	cutoffs []time.Time
+}
+
+func (m *MockReaperTracker) DeleteInactiveMQTTClients(lastSeenBefore time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cutoffs = append(m.cutoffs, lastSeenBefore)
+	return 0, nil
+}
+
+func TestTrackingHook_InactiveRetention(t *testing.T) {
+	tracker := &MockReaperTracker{MockClientTracker: NewMockClientTracker()}
+	hook := NewTrackingHook(tracker)
+
+	hook.SetInactiveRetention(30 * 24 * time.Hour)
+	if err := hook.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	// The first reap runs right away; Stop waits for it
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	if len(tracker.cutoffs) != 1 {
+		t.Fatalf("reaps = %d, want 1", len(tracker.cutoffs))
+	}
+	if age := time.Since(tracker.cutoffs[0]); age < 30*24*time.Hour || age > 30*24*time.Hour+time.Minute {
+		t.Errorf("cutoff age = %v, want the 30 day retention", age)
+	}
+}
+
+func TestTrackingHook_InactiveRetentionUnsupported(t *testing.T) {
+	hook := NewTrackingHook(NewMockClientTracker())
+
+	hook.SetInactiveRetention(time.Hour)
+	if hook.reaper != nil {
+		t.Error("reaper started for a tracker that can't delete clients")
+	}
+}
@@ -3,22 +3,59 @@ package tracking
 import (
 	"bytes"
 	"log/slog"
+	"net"
+	"sync"
+	"time"
 
 	mqtt "github.com/mochi-mqtt/server/v2"
 	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github/bromq-dev/bromq/internal/storage"
 )
 
+// Config holds client tracking configuration
+type Config struct {
+	BatchWindow time.Duration `env:"TRACKING_BATCH_WINDOW" flag:"tracking-batch-window" default:"1s" desc:"Coalesce client connect/disconnect writes over this window (0 = write immediately)"`
+	GeoIPDB     string        `env:"TRACKING_GEOIP_DB" flag:"tracking-geoip-db" desc:"MaxMind GeoIP2/GeoLite2 Country or City database used to add geo_country to client metadata (empty = disabled)"`
+
+	InactiveRetention time.Duration `env:"TRACKING_INACTIVE_RETENTION" flag:"tracking-inactive-retention" default:"0" desc:"Delete the records of clients disconnected for longer than this, e.g. 720h (0 = keep forever)"`
+}
+
 // ClientTracker interface for tracking MQTT client connections
 type ClientTracker interface {
 	UpsertMQTTClientInterface(clientID string, mqttUserID uint, metadata interface{}) (interface{}, error)
-	MarkMQTTClientInactive(clientID string) error
+	MarkMQTTClientInactive(clientID string) (int64, error)
 	GetMQTTUserByUsernameInterface(username string) (interface{}, error)
 }
 
+// BatchClientTracker is implemented by trackers that can apply many client
+// updates in a single transaction
+type BatchClientTracker interface {
+	ApplyMQTTClientUpdates(updates []storage.ClientUpdate) error
+}
+
+// ConnectInfoTracker is implemented by trackers that can store the details a
+// client sent in its CONNECT packet
+type ConnectInfoTracker interface {
+	UpsertMQTTClientConnect(clientID string, mqttUserID uint, info storage.ConnectInfo) (*storage.MQTTClient, error)
+}
+
 // TrackingHook implements MQTT client tracking using a database
 type TrackingHook struct {
 	mqtt.HookBase
 	tracker ClientTracker
+	geo     GeoResolver // Optional, see SetGeoResolver
+
+	// Batching (enabled via SetBatchWindow)
+	batcher  BatchClientTracker
+	mu       sync.Mutex
+	pending  map[string]*storage.ClientUpdate
+	order    []string // client IDs in first-touched order
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+
+	reaper *reaper // nil = inactive clients are kept (see SetInactiveRetention)
 }
 
 // New AuthHook creates a new authentication hook
@@ -28,6 +65,131 @@ func NewTrackingHook(tracker ClientTracker) *TrackingHook {
 	}
 }
 
+// SetBatchWindow enables coalescing of client updates, flushed every window
+// Requires the tracker to implement BatchClientTracker; must be called before
+// the server starts. Remaining updates are flushed when the hook is stopped.
+func (h *TrackingHook) SetBatchWindow(window time.Duration) {
+	if window <= 0 || h.batcher != nil {
+		return
+	}
+
+	batcher, ok := h.tracker.(BatchClientTracker)
+	if !ok {
+		slog.Warn("Client tracker does not support batching, writing updates immediately")
+		return
+	}
+
+	h.batcher = batcher
+	h.pending = make(map[string]*storage.ClientUpdate)
+	h.stop = make(chan struct{})
+	h.done = make(chan struct{})
+
+	go h.flushLoop(window)
+}
+
+// Stop flushes any pending client updates (called by the server on shutdown)
+func (h *TrackingHook) Stop() error {
+	if h.reaper != nil {
+		h.reaper.close()
+	}
+	if h.batcher == nil {
+		return nil
+	}
+
+	h.stopOnce.Do(func() { close(h.stop) })
+	<-h.done
+	return nil
+}
+
+// flushLoop writes pending updates every window until stopped
+func (h *TrackingHook) flushLoop(window time.Duration) {
+	defer close(h.done)
+
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.Flush()
+		case <-h.stop:
+			h.Flush()
+			return
+		}
+	}
+}
+
+// Flush writes all pending client updates in one batch
+func (h *TrackingHook) Flush() {
+	if h.batcher == nil {
+		return
+	}
+
+	h.mu.Lock()
+	if len(h.order) == 0 {
+		h.mu.Unlock()
+		return
+	}
+	updates := make([]storage.ClientUpdate, 0, len(h.order))
+	for _, clientID := range h.order {
+		updates = append(updates, *h.pending[clientID])
+	}
+	h.pending = make(map[string]*storage.ClientUpdate)
+	h.order = nil
+	h.mu.Unlock()
+
+	if err := h.batcher.ApplyMQTTClientUpdates(updates); err != nil {
+		slog.Warn("Failed to flush client tracking batch", "clients", len(updates), "error", err)
+		return
+	}
+	slog.Debug("Client tracking batch flushed", "clients", len(updates))
+}
+
+// enqueue merges a connect or disconnect into the pending batch
+// info and metadata are only set for connects
+func (h *TrackingHook) enqueue(clientID string, mqttUserID uint, connected bool, info *storage.ConnectInfo, metadata map[string]interface{}) {
+	now := time.Now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	u, ok := h.pending[clientID]
+	if !ok {
+		u = &storage.ClientUpdate{ClientID: clientID}
+		h.pending[clientID] = u
+		h.order = append(h.order, clientID)
+	}
+
+	u.LastSeen = now
+	u.Active = connected
+	if connected {
+		if !u.Connected {
+			u.FirstSeen = now
+		}
+		u.Connected = true
+		u.MQTTUserID = mqttUserID
+		u.Info = info
+		if metadata != nil {
+			u.Metadata = metadata
+		}
+	}
+}
+
+// connectInfo extracts the tracked CONNECT details of a client
+func connectInfo(cl *mqtt.Client, pk packets.Packet) storage.ConnectInfo {
+	remoteIP := cl.Net.Remote
+	if host, _, err := net.SplitHostPort(remoteIP); err == nil {
+		remoteIP = host
+	}
+
+	return storage.ConnectInfo{
+		ProtocolVersion: cl.Properties.ProtocolVersion,
+		CleanStart:      pk.Connect.Clean,
+		Keepalive:       cl.State.Keepalive,
+		RemoteIP:        remoteIP,
+	}
+}
+
 // ID returns the hook identifier
 func (h *TrackingHook) ID() string {
 	return "client-tracking"
@@ -78,13 +240,32 @@ func (h *TrackingHook) OnConnect(cl *mqtt.Client, pk packets.Packet) error {
 		return nil
 	}
 
+	info := connectInfo(cl, pk)
+	geo := h.geoMetadata(info.RemoteIP)
+
+	// Queue the update when batching, otherwise write it now
+	if h.batcher != nil {
+		h.enqueue(cl.ID, mqttUserID, true, &info, geo)
+		return nil
+	}
+
 	// Create or update client record
-	_, err = h.tracker.UpsertMQTTClientInterface(cl.ID, mqttUserID, nil)
+	if infoTracker, ok := h.tracker.(ConnectInfoTracker); ok {
+		_, err = infoTracker.UpsertMQTTClientConnect(cl.ID, mqttUserID, info)
+	} else {
+		_, err = h.tracker.UpsertMQTTClientInterface(cl.ID, mqttUserID, nil)
+	}
 	if err != nil {
 		slog.Warn("Failed to track client connection", "error", err)
 		return nil // Don't fail the connection
 	}
 
+	if merger, ok := h.tracker.(MetadataMergeTracker); ok && geo != nil {
+		if err := merger.MergeMQTTClientMetadata(cl.ID, geo); err != nil {
+			slog.Warn("Failed to store client geo metadata", "client_id", cl.ID, "error", err)
+		}
+	}
+
 	slog.Debug("Client connection tracked", "client_id", cl.ID, "username", username)
 	return nil
 }
@@ -92,9 +273,22 @@ func (h *TrackingHook) OnConnect(cl *mqtt.Client, pk packets.Packet) error {
 // OnDisconnect is called when a client disconnects
 // This marks the client as inactive
 func (h *TrackingHook) OnDisconnect(cl *mqtt.Client, err error, expire bool) {
-	if err := h.tracker.MarkMQTTClientInactive(cl.ID); err != nil {
+	if h.batcher != nil {
+		h.enqueue(cl.ID, 0, false, nil, nil)
+		return
+	}
+
+	updated, err := h.tracker.MarkMQTTClientInactive(cl.ID)
+	if err != nil {
 		slog.Warn("Failed to mark client as inactive", "client_id", cl.ID, "error", err)
-	} else {
-		slog.Debug("Client marked as disconnected", "client_id", cl.ID)
+		return
+	}
+
+	if updated == 0 {
+		// Anonymous or otherwise untracked client
+		slog.Debug("No tracked record for disconnected client", "client_id", cl.ID)
+		return
 	}
+
+	slog.Debug("Client marked as disconnected", "client_id", cl.ID)
 }
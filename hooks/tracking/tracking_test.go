@@ -3,9 +3,12 @@ package tracking
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	mqtt "github.com/mochi-mqtt/server/v2"
 	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github/bromq-dev/bromq/internal/storage"
 )
 
 // MockClientTracker implements the ClientTracker interface for testing
@@ -57,12 +60,12 @@ func (m *MockClientTracker) UpsertMQTTClientInterface(clientID string, mqttUserI
 	return client, nil
 }
 
-func (m *MockClientTracker) MarkMQTTClientInactive(clientID string) error {
+func (m *MockClientTracker) MarkMQTTClientInactive(clientID string) (int64, error) {
 	if client, exists := m.clients[clientID]; exists {
 		client.IsActive = false
-		return nil
+		return 1, nil
 	}
-	return fmt.Errorf("client not found")
+	return 0, nil
 }
 
 func (m *MockClientTracker) GetMQTTUserByUsernameInterface(username string) (interface{}, error) {
@@ -269,3 +272,120 @@ func TestTrackingHook_OnDisconnect_NonExistent(t *testing.T) {
 	// Should not panic or error when disconnecting non-tracked client
 	hook.OnDisconnect(client, nil, false)
 }
+
+// MockBatchTracker records batched updates on top of MockClientTracker
+type MockBatchTracker struct {
+	*MockClientTracker
+	batches [][]storage.ClientUpdate
+}
+
+func (m *MockBatchTracker) ApplyMQTTClientUpdates(updates []storage.ClientUpdate) error {
+	m.batches = append(m.batches, updates)
+	for _, u := range updates {
+		if client, exists := m.clients[u.ClientID]; exists {
+			client.IsActive = u.Active
+			if u.Connected {
+				client.MQTTUserID = u.MQTTUserID
+			}
+		} else if u.Connected {
+			m.clients[u.ClientID] = &MockClient{ClientID: u.ClientID, MQTTUserID: u.MQTTUserID, IsActive: u.Active}
+		}
+	}
+	return nil
+}
+
+func TestTrackingHook_Batching(t *testing.T) {
+	tracker := &MockBatchTracker{MockClientTracker: NewMockClientTracker()}
+	tracker.AddUser("sensor", 7)
+
+	hook := NewTrackingHook(tracker)
+	hook.SetBatchWindow(time.Hour) // Flush manually
+	defer func() { _ = hook.Stop() }()
+
+	connect := packets.Packet{Connect: packets.ConnectParams{Username: []byte("sensor")}}
+	flapping := &mqtt.Client{ID: "flapping"}
+	steady := &mqtt.Client{ID: "steady"}
+	anonymous := &mqtt.Client{ID: "anonymous"}
+
+	// Rapid churn: 10 connect/disconnect cycles plus one steady connect
+	for i := 0; i < 10; i++ {
+		_ = hook.OnConnect(flapping, connect)
+		hook.OnDisconnect(flapping, nil, false)
+	}
+	_ = hook.OnConnect(flapping, connect)
+	_ = hook.OnConnect(steady, connect)
+	hook.OnDisconnect(anonymous, nil, false)
+
+	if len(tracker.clients) != 0 {
+		t.Fatalf("clients written before flush: %d", len(tracker.clients))
+	}
+
+	hook.Flush()
+
+	if len(tracker.batches) != 1 {
+		t.Fatalf("batches = %d, want 1", len(tracker.batches))
+	}
+	if got := len(tracker.batches[0]); got != 3 {
+		t.Errorf("batch size = %d, want 3 (coalesced per client)", got)
+	}
+
+	batch := tracker.batches[0]
+	if batch[0].ClientID != "flapping" || !batch[0].Active || !batch[0].Connected || batch[0].MQTTUserID != 7 {
+		t.Errorf("flapping update = %+v", batch[0])
+	}
+	if !batch[0].FirstSeen.Before(batch[0].LastSeen) && !batch[0].FirstSeen.Equal(batch[0].LastSeen) {
+		t.Errorf("flapping FirstSeen %v after LastSeen %v", batch[0].FirstSeen, batch[0].LastSeen)
+	}
+
+	for _, id := range []string{"flapping", "steady"} {
+		if client := tracker.clients[id]; client == nil || !client.IsActive {
+			t.Errorf("client %s final state = %+v, want active", id, client)
+		}
+	}
+	if _, exists := tracker.clients["anonymous"]; exists {
+		t.Error("anonymous client should not be tracked")
+	}
+
+	// Disconnect is flushed on Stop
+	hook.OnDisconnect(steady, nil, false)
+	_ = hook.Stop()
+	if tracker.clients["steady"].IsActive {
+		t.Error("pending disconnect was not flushed on Stop")
+	}
+}
+
+// MockInfoTracker records connect info on top of MockClientTracker
+type MockInfoTracker struct {
+	*MockClientTracker
+	info map[string]storage.ConnectInfo
+}
+
+func (m *MockInfoTracker) UpsertMQTTClientConnect(clientID string, mqttUserID uint, info storage.ConnectInfo) (*storage.MQTTClient, error) {
+	m.info[clientID] = info
+	_, err := m.UpsertMQTTClientInterface(clientID, mqttUserID, nil)
+	return &storage.MQTTClient{ClientID: clientID, MQTTUserID: mqttUserID, ConnectInfo: info}, err
+}
+
+func TestTrackingHook_ConnectInfo(t *testing.T) {
+	tracker := &MockInfoTracker{MockClientTracker: NewMockClientTracker(), info: map[string]storage.ConnectInfo{}}
+	tracker.AddUser("sensor", 7)
+	hook := NewTrackingHook(tracker)
+
+	client := &mqtt.Client{ID: "sensor-1"}
+	client.Net.Remote = "192.0.2.10:53211"
+	client.Properties.ProtocolVersion = 5
+	client.State.Keepalive = 30
+	pk := packets.Packet{Connect: packets.ConnectParams{Username: []byte("sensor"), Clean: true}}
+
+	if err := hook.OnConnect(client, pk); err != nil {
+		t.Fatalf("OnConnect() error = %v", err)
+	}
+
+	want := storage.ConnectInfo{ProtocolVersion: 5, CleanStart: true, Keepalive: 30, RemoteIP: "192.0.2.10"}
+	if got := tracker.info["sensor-1"]; got != want {
+		t.Errorf("connect info = %+v, want %+v", got, want)
+	}
+	if !tracker.clients["sensor-1"].IsActive {
+		t.Error("Expected client to be tracked as active")
+	}
+}
@@ -1,12 +1,16 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github/bromq-dev/bromq/hooks/bridge"
 	"github/bromq-dev/bromq/internal/storage"
 
 	"gorm.io/datatypes"
@@ -31,7 +35,7 @@ import (
 // @Failure 500 {object} ErrorResponse
 // @Router /bridges [get]
 func (h *Handler) ListBridges(w http.ResponseWriter, r *http.Request) {
-	params := parsePaginationParams(r)
+	params := h.parsePaginationParams(r)
 
 	bridges, total, err := h.db.ListBridgesPaginated(params.Page, params.PageSize, params.Search, params.SortBy, params.SortOrder)
 	if err != nil {
@@ -143,6 +147,18 @@ func (h *Handler) CreateBridge(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	if !req.CleanSession && req.ClientID == "" {
+		http.Error(w, `{"error":"client_id is required when clean_session is false"}`, http.StatusBadRequest)
+		return
+	}
+	if req.MaxQoS != nil && *req.MaxQoS > 2 {
+		http.Error(w, `{"error":"max_qos must be 0, 1, or 2"}`, http.StatusBadRequest)
+		return
+	}
+	if req.RetainPolicy != "" && req.RetainPolicy != storage.RetainPolicyPreserve && req.RetainPolicy != storage.RetainPolicyClear {
+		http.Error(w, `{"error":"retain_policy must be 'preserve' or 'clear'"}`, http.StatusBadRequest)
+		return
+	}
 
 	// Convert metadata to JSON
 	var metadata datatypes.JSON
@@ -184,6 +200,8 @@ func (h *Handler) CreateBridge(w http.ResponseWriter, r *http.Request) {
 		req.CleanSession,
 		req.KeepAlive,
 		req.ConnectionTimeout,
+		req.MaxQoS,
+		req.RetainPolicy,
 		metadata,
 		topics,
 	)
@@ -197,6 +215,137 @@ func (h *Handler) CreateBridge(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(bridge)
 }
 
+// CloneBridge godoc
+// @Summary Clone bridge
+// @Description Copy a bridge and its topic mappings under a new name. The copy is a manual bridge even if the source is provisioned, and does not reuse the source's client ID; a client ID is required to copy a bridge with a persistent session
+// @Tags Bridges
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Bridge ID"
+// @Param clone body CloneRequest true "Name (and optional client ID) for the copy"
+// @Success 201 {object} storage.Bridge
+// @Failure 400 {object} ErrorResponse "Invalid bridge ID or request, or no client ID for a persistent session"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 404 {object} ErrorResponse "Bridge not found"
+// @Failure 409 {object} ErrorResponse "Name already in use"
+// @Failure 500 {object} ErrorResponse
+// @Router /bridges/{id}/clone [post]
+func (h *Handler) CloneBridge(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid bridge ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req CloneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid request: %s"}`, err), http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		http.Error(w, `{"error":"bridge name is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetBridge(uint(id)); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"bridge not found: %s"}`, err), http.StatusNotFound)
+		return
+	}
+	if _, err := h.db.GetBridgeByName(req.Name); err == nil {
+		http.Error(w, fmt.Sprintf(`{"error":"a bridge named '%s' already exists"}`, req.Name), http.StatusConflict)
+		return
+	}
+
+	bridge, err := h.db.CloneBridge(uint(id), req.Name, req.ClientID)
+	if errors.Is(err, storage.ErrBridgeClientIDRequired) {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to clone bridge: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(bridge)
+}
+
+// maxBridgeTestTimeout caps how long a connection test may hold the request
+const maxBridgeTestTimeout = 30 * time.Second
+
+// bridgeTestTimeout caps a requested connection test timeout at
+// maxBridgeTestTimeout and, when API requests have a timeout, at 90% of it,
+// so the test's result is written before the request is cut off
+func bridgeTestTimeout(requested, requestTimeout time.Duration) time.Duration {
+	timeout := min(requested, maxBridgeTestTimeout)
+	if requestTimeout > 0 {
+		timeout = min(timeout, requestTimeout*9/10)
+	}
+	return timeout
+}
+
+// TestBridgeConnection godoc
+// @Summary Test bridge connection
+// @Description Try a short-lived connection to a remote broker with the given bridge settings, without saving anything. Topics and delivery settings are ignored. connection_timeout is capped below the API request timeout
+// @Tags Bridges
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param bridge body CreateBridgeRequest true "Bridge configuration to test"
+// @Success 200 {object} BridgeTestResponse "Probe result (success is false if the connection failed)"
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Router /bridges/test [post]
+func (h *Handler) TestBridgeConnection(w http.ResponseWriter, r *http.Request) {
+	var req CreateBridgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid request: %s"}`, err), http.StatusBadRequest)
+		return
+	}
+	if req.Host == "" {
+		http.Error(w, `{"error":"remote host is required"}`, http.StatusBadRequest)
+		return
+	}
+	if req.MQTTVersion != "" && req.MQTTVersion != "3" && req.MQTTVersion != "5" {
+		http.Error(w, `{"error":"mqtt_version must be '3' or '5'"}`, http.StatusBadRequest)
+		return
+	}
+
+	timeout := bridge.DefaultProbeTimeout
+	if req.ConnectionTimeout > 0 {
+		timeout = time.Duration(req.ConnectionTimeout) * time.Second
+	}
+	timeout = bridgeTestTimeout(timeout, h.config.RequestTimeout)
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := bridge.Probe(ctx, &storage.Bridge{
+		Host:        req.Host,
+		Port:        req.Port,
+		Username:    req.Username,
+		Password:    req.Password,
+		MQTTVersion: req.MQTTVersion,
+	})
+
+	response := BridgeTestResponse{
+		Success:    err == nil,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		response.Error = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
 // UpdateBridge godoc
 // @Summary Update bridge
 // @Description Update an existing MQTT bridge configuration and topic mappings
@@ -270,6 +419,18 @@ func (h *Handler) UpdateBridge(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	if !req.CleanSession && req.ClientID == "" {
+		http.Error(w, `{"error":"client_id is required when clean_session is false"}`, http.StatusBadRequest)
+		return
+	}
+	if req.MaxQoS != nil && *req.MaxQoS > 2 {
+		http.Error(w, `{"error":"max_qos must be 0, 1, or 2"}`, http.StatusBadRequest)
+		return
+	}
+	if req.RetainPolicy != "" && req.RetainPolicy != storage.RetainPolicyPreserve && req.RetainPolicy != storage.RetainPolicyClear {
+		http.Error(w, `{"error":"retain_policy must be 'preserve' or 'clear'"}`, http.StatusBadRequest)
+		return
+	}
 
 	// Convert metadata to JSON
 	var metadata datatypes.JSON
@@ -294,6 +455,8 @@ func (h *Handler) UpdateBridge(w http.ResponseWriter, r *http.Request) {
 		req.CleanSession,
 		req.KeepAlive,
 		req.ConnectionTimeout,
+		req.MaxQoS,
+		req.RetainPolicy,
 		metadata,
 	); err != nil {
 		http.Error(w, fmt.Sprintf(`{"error":"failed to update bridge: %s"}`, err), http.StatusInternalServerError)
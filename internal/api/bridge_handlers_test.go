@@ -0,0 +1,159 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+func TestTestBridgeConnection(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	// A port nothing listens on
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := ln.Addr().(*net.TCPAddr).Port
+	_ = ln.Close()
+
+	tests := []struct {
+		name        string
+		body        CreateBridgeRequest
+		wantStatus  int
+		wantSuccess bool
+	}{
+		{
+			name:       "missing host",
+			body:       CreateBridgeRequest{Port: closedPort},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid MQTT version",
+			body:       CreateBridgeRequest{Host: "127.0.0.1", Port: closedPort, MQTTVersion: "4"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "connection refused",
+			body:       CreateBridgeRequest{Host: "127.0.0.1", Port: closedPort, ConnectionTimeout: 2},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.body)
+			req := httptest.NewRequest(http.MethodPost, "/api/bridges/test", bytes.NewReader(body))
+			rec := httptest.NewRecorder()
+
+			handler.TestBridgeConnection(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("TestBridgeConnection() status = %v, want %v: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var response BridgeTestResponse
+			if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Success != tt.wantSuccess {
+				t.Errorf("TestBridgeConnection() success = %v, want %v", response.Success, tt.wantSuccess)
+			}
+			if !response.Success && response.Error == "" {
+				t.Error("TestBridgeConnection() failed without an error message")
+			}
+		})
+	}
+}
+
+func TestBridgeTestTimeout(t *testing.T) {
+	tests := []struct {
+		name           string
+		requested      time.Duration
+		requestTimeout time.Duration
+		want           time.Duration
+	}{
+		{"within both limits", 5 * time.Second, 10 * time.Second, 5 * time.Second},
+		{"capped below request timeout", 30 * time.Second, 10 * time.Second, 9 * time.Second},
+		{"no request timeout", time.Minute, 0, maxBridgeTestTimeout},
+		{"long request timeout", time.Minute, 2 * time.Minute, maxBridgeTestTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bridgeTestTimeout(tt.requested, tt.requestTimeout); got != tt.want {
+				t.Errorf("bridgeTestTimeout(%v, %v) = %v, want %v", tt.requested, tt.requestTimeout, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloneBridge(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	source, err := handler.db.CreateBridge("cloud", "mqtt.example.com", 1883, "user", "secret", "edge-1", "5", false, 60, 30, nil, "",
+		nil, []storage.BridgeTopic{{Local: "sensors/#", Remote: "edge/sensors/#", Direction: "out", QoS: 1}})
+	if err != nil {
+		t.Fatalf("CreateBridge() error = %v", err)
+	}
+	if err := handler.db.MarkBridgeAsProvisioned(source.ID, true); err != nil {
+		t.Fatalf("MarkBridgeAsProvisioned() error = %v", err)
+	}
+
+	body, _ := json.Marshal(CloneRequest{Name: "cloud-copy", ClientID: "edge-2"})
+	req := httptest.NewRequest(http.MethodPost, "/api/bridges/1/clone", bytes.NewReader(body))
+	req.SetPathValue("id", "1")
+	rec := httptest.NewRecorder()
+
+	handler.CloneBridge(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CloneBridge() status = %v, want %v: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+
+	clone, err := handler.db.GetBridgeByName("cloud-copy")
+	if err != nil {
+		t.Fatalf("GetBridgeByName() error = %v", err)
+	}
+	if clone.ProvisionedFromConfig {
+		t.Error("clone is provisioned, want a manual bridge")
+	}
+	if clone.ClientID != "edge-2" || clone.Password != "secret" || clone.CleanSession {
+		t.Errorf("clone = client %q password %q clean %v, want edge-2, the source's password and persistent session",
+			clone.ClientID, clone.Password, clone.CleanSession)
+	}
+	if len(clone.Topics) != 1 || clone.Topics[0].Remote != "edge/sensors/#" || clone.Topics[0].QoS != 1 {
+		t.Errorf("clone topics = %+v, want the source's mapping", clone.Topics)
+	}
+}
+
+func TestCloneBridge_PersistentNeedsClientID(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	if _, err := handler.db.CreateBridge("cloud", "mqtt.example.com", 1883, "", "", "edge-1", "5", false, 60, 30, nil, "", nil, nil); err != nil {
+		t.Fatalf("CreateBridge() error = %v", err)
+	}
+
+	body, _ := json.Marshal(CloneRequest{Name: "cloud-copy"})
+	req := httptest.NewRequest(http.MethodPost, "/api/bridges/1/clone", bytes.NewReader(body))
+	req.SetPathValue("id", "1")
+	rec := httptest.NewRecorder()
+
+	handler.CloneBridge(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("CloneBridge() status = %v, want %v: %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+	if _, err := handler.db.GetBridgeByName("cloud-copy"); err == nil {
+		t.Error("persistent bridge cloned without a client ID")
+	}
+}
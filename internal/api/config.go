@@ -2,19 +2,72 @@ package api
 
 import (
 	"crypto/rand"
+	"crypto/tls"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
+	"time"
+
+	"github/bromq-dev/bromq/internal/config"
 )
 
 // Config holds API server configuration
 type Config struct {
-	HTTPAddr  string `env:"HTTP_ADDR" flag:"http" default:":8080" desc:"HTTP API server address"`
-	JWTSecret string `env:"JWT_SECRET" flag:"jwt-secret" desc:"JWT secret for token signing (auto-generated if not set)"`
+	HTTPAddr      string `env:"HTTP_ADDR" flag:"http" default:":8080" desc:"HTTP API server address"`
+	JWTSecret     string `env:"JWT_SECRET" flag:"jwt-secret" desc:"JWT secret for token signing (auto-generated if not set)"`
+	JWTSecretFile string `env:"JWT_SECRET_FILE" flag:"jwt-secret-file" desc:"Read the JWT secret from a file, e.g. a Docker secret (overrides JWT_SECRET)"`
+
+	// HTTPS
+	TLSCertFile  string `env:"HTTP_TLS_CERT" flag:"http-tls-cert" desc:"TLS certificate file path (serves HTTPS on HTTP_ADDR when set with HTTP_TLS_KEY)"`
+	TLSKeyFile   string `env:"HTTP_TLS_KEY" flag:"http-tls-key" desc:"TLS key file path"`
+	RedirectAddr string `env:"HTTP_REDIRECT_ADDR" flag:"http-redirect-addr" desc:"Plain HTTP address that redirects to HTTPS, e.g. :80 (requires TLS)"`
+
+	// Request limits
+	RequestTimeout time.Duration `env:"HTTP_REQUEST_TIMEOUT" flag:"http-request-timeout" default:"10s" desc:"Maximum time to handle an API request (0 = no limit)"`
+	MaxBodyBytes   int64         `env:"HTTP_MAX_BODY_BYTES" flag:"http-max-body-bytes" default:"1048576" desc:"Maximum request body size in bytes for mutating API requests (0 = no limit)"`
+
+	// Pagination
+	DefaultPageSize int `env:"HTTP_DEFAULT_PAGE_SIZE" flag:"http-default-page-size" default:"25" desc:"Page size of list endpoints when the request sets none"`
+	MaxPageSize     int `env:"HTTP_MAX_PAGE_SIZE" flag:"http-max-page-size" default:"100" desc:"Largest page size a request may ask for, up to 1000 (larger requests are clamped)"`
+
+	// Response compression
+	EnableGzip   bool `env:"HTTP_GZIP" flag:"http-gzip" default:"true" desc:"Gzip-compress JSON API responses for clients that accept it"`
+	GzipMinBytes int  `env:"HTTP_GZIP_MIN_BYTES" flag:"http-gzip-min-bytes" default:"1024" desc:"Minimum response size in bytes before compression is applied"`
 }
 
+// Page size defaults, used when the config leaves them unset (e.g. in tests)
+const (
+	defaultPageSize    = 25
+	defaultMaxPageSize = 100
+	pageSizeCeiling    = 1000 // Hard limit on HTTP_MAX_PAGE_SIZE, whatever is configured
+)
+
 // PostParse applies post-parsing logic (JWT secret generation if not provided)
 func (c *Config) PostParse() error {
+	if c.JWTSecretFile != "" {
+		secret, err := config.ReadSecretFile(c.JWTSecretFile)
+		if err != nil {
+			return fmt.Errorf("JWT_SECRET_FILE: %w", err)
+		}
+		c.JWTSecret = secret
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return errors.New("HTTP_TLS_CERT and HTTP_TLS_KEY must be set together")
+	}
+	if c.RedirectAddr != "" && !c.TLSEnabled() {
+		return errors.New("HTTP_REDIRECT_ADDR requires HTTP_TLS_CERT and HTTP_TLS_KEY")
+	}
+
+	if c.MaxPageSize < 0 || c.MaxPageSize > pageSizeCeiling {
+		return fmt.Errorf("HTTP_MAX_PAGE_SIZE must be between 1 and %d", pageSizeCeiling)
+	}
+	if c.DefaultPageSize < 0 || (c.MaxPageSize > 0 && c.DefaultPageSize > c.MaxPageSize) {
+		return errors.New("HTTP_DEFAULT_PAGE_SIZE must be between 1 and HTTP_MAX_PAGE_SIZE")
+	}
+
 	if c.JWTSecret == "" {
 		// Generate a secure random secret
 		secret := make([]byte, 32) // 256 bits
@@ -35,3 +88,36 @@ func (c *Config) PostParse() error {
 func (c *Config) JWTSecretBytes() []byte {
 	return []byte(c.JWTSecret)
 }
+
+// PageSizes returns the default and maximum page size of list endpoints,
+// falling back to 25 and 100 for unset values
+func (c *Config) PageSizes() (defaultSize, maxSize int) {
+	defaultSize, maxSize = defaultPageSize, defaultMaxPageSize
+	if c == nil {
+		return defaultSize, maxSize
+	}
+	if c.MaxPageSize > 0 {
+		maxSize = min(c.MaxPageSize, pageSizeCeiling)
+	}
+	if c.DefaultPageSize > 0 {
+		defaultSize = c.DefaultPageSize
+	}
+	return min(defaultSize, maxSize), maxSize
+}
+
+// TLSEnabled reports whether the API is served over HTTPS
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// TLSConfig loads the certificate and key into a server TLS config
+func (c *Config) TLSConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load HTTP TLS certificate: %w", err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+// EffectiveConfigResponse is the loaded broker configuration with secrets left out
+// Fields are listed explicitly so new secret settings are never exposed by accident
+type EffectiveConfigResponse struct {
+	MQTT        *EffectiveMQTTConfig       `json:"mqtt,omitempty"`
+	HTTP        EffectiveHTTPConfig        `json:"http"`
+	Database    EffectiveDatabaseConfig    `json:"database"`
+	Hooks       []string                   `json:"hooks"`
+	Provisioned *storage.ProvisionedCounts `json:"provisioned"`
+}
+
+// EffectiveMQTTConfig describes the MQTT listeners and client limits
+type EffectiveMQTTConfig struct {
+	TCPAddr               string `json:"tcp_addr"`
+	WSAddr                string `json:"ws_addr"`
+	UnixSocket            string `json:"unix_socket,omitempty"`
+	TLSEnabled            bool   `json:"tls_enabled"`
+	ProxyProtocol         bool   `json:"proxy_protocol"`
+	AllowAnonymous        bool   `json:"allow_anonymous"`
+	AnonymousListeners    string `json:"anonymous_listeners,omitempty"`
+	AnonymousACL          string `json:"anonymous_acl,omitempty"`
+	RetainAvailable       bool   `json:"retain_available"`
+	RetainedBackend       string `json:"retained_backend"`
+	MaxClients            int    `json:"max_clients"`
+	ClientIDPolicy        string `json:"client_id_policy"`
+	MaxInflight           int    `json:"max_inflight"`
+	MaxQueued             int    `json:"max_queued"`
+	MaxKeepalive          string `json:"max_keepalive"`
+	SessionExpiryMax      string `json:"session_expiry_max"`
+	RejectExcessiveLimits bool   `json:"reject_excessive_limits"`
+	TopicSizeLimits       string `json:"topic_size_limits,omitempty"`
+	DeadLetterTopic       string `json:"dead_letter_topic,omitempty"`
+	RedactTopics          string `json:"redact_topics,omitempty"`
+	SysTopicsEnabled      bool   `json:"sys_topics_enabled"`
+}
+
+// EffectiveHTTPConfig describes the HTTP API server
+type EffectiveHTTPConfig struct {
+	Addr            string `json:"addr"`
+	TLSEnabled      bool   `json:"tls_enabled"`
+	RedirectAddr    string `json:"redirect_addr,omitempty"`
+	RequestTimeout  string `json:"request_timeout"`
+	MaxBodyBytes    int64  `json:"max_body_bytes"`
+	Gzip            bool   `json:"gzip"`
+	DefaultPageSize int    `json:"default_page_size"`
+	MaxPageSize     int    `json:"max_page_size"`
+}
+
+// EffectiveDatabaseConfig describes the database backend
+type EffectiveDatabaseConfig struct {
+	Type string `json:"type"`
+}
+
+// GetEffectiveConfig godoc
+// @Summary Get effective configuration
+// @Description Get the broker's loaded configuration for diagnosing misconfiguration: listener addresses, anonymous access, database type, registered hooks, and counts of config-provisioned resources. Secrets (passwords, JWT secret, secret file paths) are never included
+// @Tags Maintenance
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} EffectiveConfigResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/config [get]
+func (h *Handler) GetEffectiveConfig(w http.ResponseWriter, r *http.Request) {
+	provisioned, err := h.db.CountProvisioned()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	response := EffectiveConfigResponse{
+		Database:    EffectiveDatabaseConfig{Type: h.db.Dialector.Name()},
+		Hooks:       []string{},
+		Provisioned: provisioned,
+	}
+
+	if h.config != nil {
+		defaultPage, maxPage := h.config.PageSizes()
+		response.HTTP = EffectiveHTTPConfig{
+			Addr:            h.config.HTTPAddr,
+			TLSEnabled:      h.config.TLSEnabled(),
+			RedirectAddr:    h.config.RedirectAddr,
+			RequestTimeout:  h.config.RequestTimeout.String(),
+			MaxBodyBytes:    h.config.MaxBodyBytes,
+			Gzip:            h.config.EnableGzip,
+			DefaultPageSize: defaultPage,
+			MaxPageSize:     maxPage,
+		}
+	}
+
+	if h.mqtt != nil {
+		cfg := h.mqtt.Config()
+		response.MQTT = &EffectiveMQTTConfig{
+			TCPAddr:               cfg.TCPAddr,
+			WSAddr:                cfg.WSAddr,
+			UnixSocket:            cfg.UnixSocket,
+			TLSEnabled:            cfg.EnableTLS,
+			ProxyProtocol:         cfg.ProxyProtocol,
+			AllowAnonymous:        cfg.AllowAnonymous,
+			AnonymousListeners:    cfg.AnonymousListeners,
+			AnonymousACL:          cfg.AnonymousACL,
+			TopicSizeLimits:       cfg.TopicSizeLimits,
+			DeadLetterTopic:       cfg.DeadLetterTopic,
+			RedactTopics:          cfg.RedactTopics,
+			RetainAvailable:       cfg.RetainAvailable,
+			RetainedBackend:       cfg.RetainedBackend,
+			MaxClients:            cfg.MaxClients,
+			ClientIDPolicy:        cfg.ClientIDPolicy,
+			MaxInflight:           cfg.MaxInflight,
+			MaxQueued:             cfg.MaxQueued,
+			MaxKeepalive:          durationOrUnlimited(cfg.MaxKeepalive),
+			SessionExpiryMax:      durationOrUnlimited(cfg.SessionExpiryMax),
+			RejectExcessiveLimits: cfg.RejectExcessiveLimits,
+			SysTopicsEnabled:      cfg.SysTopicsEnabled,
+		}
+		response.Hooks = h.mqtt.HookIDs()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// durationOrUnlimited formats a limit where zero means no limit
+func durationOrUnlimited(d time.Duration) string {
+	if d <= 0 {
+		return "unlimited"
+	}
+	return d.String()
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+
+	"github/bromq-dev/bromq/internal/mqtt"
+)
+
+func TestGetEffectiveConfig(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.config.HTTPAddr = ":8080"
+	handler.config.JWTSecretFile = "/run/secrets/jwt_secret"
+	handler.mqtt = mqtt.New(&mqtt.Config{TCPAddr: ":1883", WSAddr: ":8883", AllowAnonymous: true})
+	if err := handler.mqtt.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := handler.db.CreateMQTTUser("provisioned-user", "user-password-123", "", nil)
+	if err != nil {
+		t.Fatalf("CreateMQTTUser() error = %v", err)
+	}
+	if err := handler.db.MarkAsProvisioned(user.ID, true); err != nil {
+		t.Fatalf("MarkAsProvisioned() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/config", nil)
+	rec := httptest.NewRecorder()
+	handler.GetEffectiveConfig(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GetEffectiveConfig() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	body := rec.Body.String()
+	for _, secret := range []string{handler.config.JWTSecret, "/run/secrets/jwt_secret", "user-password-123", "password"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("response contains secret %q: %s", secret, body)
+		}
+	}
+
+	var response EffectiveConfigResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.MQTT == nil || response.MQTT.TCPAddr != ":1883" || !response.MQTT.AllowAnonymous {
+		t.Errorf("mqtt = %+v, want tcp_addr :1883 with anonymous allowed", response.MQTT)
+	}
+	if response.HTTP.Addr != ":8080" {
+		t.Errorf("http.addr = %q, want :8080", response.HTTP.Addr)
+	}
+	if response.Database.Type != "sqlite" {
+		t.Errorf("database.type = %q, want sqlite", response.Database.Type)
+	}
+	if len(response.Hooks) != 1 || response.Hooks[0] != "allow-all-auth" {
+		t.Errorf("hooks = %v, want [allow-all-auth]", response.Hooks)
+	}
+	if response.Provisioned == nil || response.Provisioned.MQTTUsers != 1 {
+		t.Errorf("provisioned = %+v, want 1 MQTT user", response.Provisioned)
+	}
+}
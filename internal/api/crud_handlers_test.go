@@ -1,16 +1,22 @@
 package api
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github/bromq-dev/bromq/internal/mqtt"
 	"github/bromq-dev/bromq/internal/storage"
 
+	mochistorage "github.com/mochi-mqtt/server/v2/hooks/storage"
+	"github.com/mochi-mqtt/server/v2/packets"
 	"gorm.io/datatypes"
 )
 
@@ -439,6 +445,115 @@ func TestCreateMQTTUser(t *testing.T) {
 	}
 }
 
+func TestCreateMQTTUser_DefaultACL(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	create := func(request CreateMQTTUserRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(request)
+		req := httptest.NewRequest(http.MethodPost, "/api/mqtt/users", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.CreateMQTTUser(rec, req)
+		return rec
+	}
+
+	// Requesting the default ACL without one configured is rejected up front
+	rec := create(CreateMQTTUserRequest{Username: "device-none", Password: "password123", ApplyDefaultACL: true})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("CreateMQTTUser() without default ACL status = %v, want %v", rec.Code, http.StatusBadRequest)
+	}
+	if user, _ := handler.db.GetMQTTUserByUsername("device-none"); user != nil {
+		t.Error("user should not be created when the default ACL is unavailable")
+	}
+
+	handler.defaultACL = []storage.StaticACLRule{
+		{Topic: "devices/${clientid}/#", Permission: "pubsub"},
+		{Topic: "users/${username}/status", Permission: "pub"},
+	}
+
+	rec = create(CreateMQTTUserRequest{Username: "device-acl", Password: "password123", ApplyDefaultACL: true})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreateMQTTUser() status = %v, want %v: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+	var user storage.MQTTUser
+	if err := json.NewDecoder(rec.Body).Decode(&user); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	rules, err := handler.db.GetACLRulesByMQTTUserID(user.ID)
+	if err != nil {
+		t.Fatalf("GetACLRules() error = %v", err)
+	}
+	got := make(map[string]string)
+	for _, rule := range rules {
+		got[rule.Topic] = rule.Permission
+	}
+	want := map[string]string{
+		"devices/${clientid}/#":    "pubsub",
+		"users/${username}/status": "pub",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("created %d rules, want %d: %v", len(got), len(want), got)
+	}
+	for topic, permission := range want {
+		if got[topic] != permission {
+			t.Errorf("rule %q permission = %q, want %q", topic, got[topic], permission)
+		}
+	}
+
+	// Without the flag no rules are created
+	rec = create(CreateMQTTUserRequest{Username: "device-plain", Password: "password123"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreateMQTTUser() status = %v, want %v", rec.Code, http.StatusCreated)
+	}
+	var plain storage.MQTTUser
+	_ = json.NewDecoder(rec.Body).Decode(&plain)
+	if rules, _ := handler.db.GetACLRulesByMQTTUserID(plain.ID); len(rules) != 0 {
+		t.Errorf("expected no rules without applyDefaultAcl, got %d", len(rules))
+	}
+}
+
+func TestMQTTUser_IPRanges(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	body, _ := json.Marshal(CreateMQTTUserRequest{Username: "pinned", Password: "password123", AllowedCIDRs: []string{"bad"}})
+	rec := httptest.NewRecorder()
+	handler.CreateMQTTUser(rec, httptest.NewRequest(http.MethodPost, "/api/mqtt/users", bytes.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("CreateMQTTUser() with invalid CIDR status = %v, want %v", rec.Code, http.StatusBadRequest)
+	}
+
+	body, _ = json.Marshal(CreateMQTTUserRequest{Username: "pinned", Password: "password123", AllowedCIDRs: []string{"10.0.0.0/8"}})
+	rec = httptest.NewRecorder()
+	handler.CreateMQTTUser(rec, httptest.NewRequest(http.MethodPost, "/api/mqtt/users", bytes.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreateMQTTUser() status = %v, want %v: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+	var user storage.MQTTUser
+	_ = json.NewDecoder(rec.Body).Decode(&user)
+	if len(user.AllowedCIDRs) != 1 || user.AllowedCIDRs[0] != "10.0.0.0/8" {
+		t.Errorf("allowed_cidrs = %v, want [10.0.0.0/8]", user.AllowedCIDRs)
+	}
+
+	// A patch replaces the deny list and leaves the allow list alone
+	req := httptest.NewRequest(http.MethodPatch, "/api/mqtt/users/1", strings.NewReader(`{"denied_cidrs":["10.0.99.0/24"]}`))
+	req.SetPathValue("id", fmt.Sprint(user.ID))
+	rec = httptest.NewRecorder()
+	handler.PatchMQTTUser(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PatchMQTTUser() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	stored, err := handler.db.GetMQTTUserByUsername("pinned")
+	if err != nil {
+		t.Fatalf("GetMQTTUserByUsername() error = %v", err)
+	}
+	allow, deny := stored.IPRanges()
+	if len(allow) != 1 || len(deny) != 1 || deny[0] != "10.0.99.0/24" {
+		t.Errorf("IPRanges() = %v, %v; want [10.0.0.0/8], [10.0.99.0/24]", allow, deny)
+	}
+}
+
 func TestUpdateMQTTUser(t *testing.T) {
 	handler := setupTestHandler(t)
 
@@ -496,6 +611,148 @@ func TestUpdateMQTTUser(t *testing.T) {
 	}
 }
 
+func TestPatchMQTTUser(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	user, _ := handler.db.CreateMQTTUser("devicepatch", "password123", "Original",
+		datatypes.JSON([]byte(`{"location":"lab","tags":{"floor":1,"room":"a"}}`)))
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		id := fmt.Sprintf("%d", user.ID)
+		req := httptest.NewRequest(http.MethodPatch, "/api/mqtt/users/"+id, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		req.SetPathValue("id", id)
+		rec := httptest.NewRecorder()
+		handler.PatchMQTTUser(rec, req)
+		return rec
+	}
+
+	metadataOf := func(t *testing.T) map[string]interface{} {
+		t.Helper()
+		got, err := handler.db.GetMQTTUser(user.ID)
+		if err != nil {
+			t.Fatalf("GetMQTTUser() error = %v", err)
+		}
+		var metadata map[string]interface{}
+		if len(got.Metadata) > 0 {
+			if err := json.Unmarshal(got.Metadata, &metadata); err != nil {
+				t.Fatalf("Failed to decode metadata: %v", err)
+			}
+		}
+		return metadata
+	}
+
+	t.Run("description only", func(t *testing.T) {
+		rec := patch(`{"description":"Patched"}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("PatchMQTTUser() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+
+		got, _ := handler.db.GetMQTTUser(user.ID)
+		if got.Description != "Patched" {
+			t.Errorf("description = %q, want %q", got.Description, "Patched")
+		}
+		if got.Username != "devicepatch" {
+			t.Errorf("username = %q, want unchanged %q", got.Username, "devicepatch")
+		}
+		if metadata := metadataOf(t); metadata["location"] != "lab" || metadata["tags"] == nil {
+			t.Errorf("metadata = %v, want unchanged", metadata)
+		}
+	})
+
+	t.Run("set empty description", func(t *testing.T) {
+		if rec := patch(`{"description":""}`); rec.Code != http.StatusOK {
+			t.Fatalf("PatchMQTTUser() status = %v, want %v", rec.Code, http.StatusOK)
+		}
+		if got, _ := handler.db.GetMQTTUser(user.ID); got.Description != "" {
+			t.Errorf("description = %q, want empty", got.Description)
+		}
+	})
+
+	t.Run("metadata is merged", func(t *testing.T) {
+		if rec := patch(`{"metadata":{"location":null,"tags":{"room":"b"},"owner":"ops"}}`); rec.Code != http.StatusOK {
+			t.Fatalf("PatchMQTTUser() status = %v, want %v", rec.Code, http.StatusOK)
+		}
+
+		metadata := metadataOf(t)
+		if _, ok := metadata["location"]; ok {
+			t.Errorf("location should have been removed, metadata = %v", metadata)
+		}
+		if metadata["owner"] != "ops" {
+			t.Errorf("owner = %v, want ops", metadata["owner"])
+		}
+		tags, _ := metadata["tags"].(map[string]interface{})
+		if tags["room"] != "b" || tags["floor"] != float64(1) {
+			t.Errorf("tags = %v, want room b with floor kept", tags)
+		}
+	})
+
+	t.Run("null metadata clears it", func(t *testing.T) {
+		if rec := patch(`{"metadata":null}`); rec.Code != http.StatusOK {
+			t.Fatalf("PatchMQTTUser() status = %v, want %v", rec.Code, http.StatusOK)
+		}
+		if metadata := metadataOf(t); metadata != nil {
+			t.Errorf("metadata = %v, want cleared", metadata)
+		}
+	})
+
+	t.Run("empty username is rejected", func(t *testing.T) {
+		if rec := patch(`{"username":""}`); rec.Code != http.StatusBadRequest {
+			t.Errorf("PatchMQTTUser() status = %v, want %v", rec.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestUpdateMQTTUser_IfMatch(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	user, _ := handler.db.CreateMQTTUser("deviceetag", "password123", "Original", nil)
+	id := fmt.Sprintf("%d", user.ID)
+
+	get := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/api/mqtt/users/"+id, nil)
+		req.SetPathValue("id", id)
+		rec := httptest.NewRecorder()
+		handler.GetMQTTUser(rec, req)
+		return rec.Header().Get("ETag")
+	}
+	update := func(ifMatch, description string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(UpdateMQTTUserRequest{Username: "deviceetag", Description: description})
+		req := httptest.NewRequest(http.MethodPut, "/api/mqtt/users/"+id, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", ifMatch)
+		req.SetPathValue("id", id)
+		rec := httptest.NewRecorder()
+		handler.UpdateMQTTUser(rec, req)
+		return rec
+	}
+
+	stale := get()
+	if stale == "" {
+		t.Fatal("GetMQTTUser() did not return an ETag")
+	}
+
+	if rec := update(stale, "First edit"); rec.Code != http.StatusOK {
+		t.Fatalf("UpdateMQTTUser() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	if rec := update(stale, "Second edit"); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("UpdateMQTTUser() with stale If-Match status = %v, want %v", rec.Code, http.StatusPreconditionFailed)
+	}
+	if got, _ := handler.db.GetMQTTUser(user.ID); got.Description != "First edit" {
+		t.Errorf("description = %q after rejected update, want %q", got.Description, "First edit")
+	}
+
+	// Password changes bump the version too
+	if err := handler.db.UpdateMQTTUserPassword(user.ID, "newpassword123"); err != nil {
+		t.Fatalf("UpdateMQTTUserPassword() error = %v", err)
+	}
+	current := get()
+	if rec := update(current, "Third edit"); rec.Code != http.StatusOK {
+		t.Errorf("UpdateMQTTUser() with current If-Match status = %v, want %v", rec.Code, http.StatusOK)
+	}
+}
+
 func TestUpdateMQTTUserPassword(t *testing.T) {
 	handler := setupTestHandler(t)
 
@@ -651,6 +908,66 @@ func TestListMQTTClients(t *testing.T) {
 	}
 }
 
+func TestListMQTTClients_NDJSON(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	// More clients than one export batch
+	mqttUser, _ := handler.db.CreateMQTTUser("testdevice", "password123", "Test", nil)
+	const count = 1100
+	for i := 0; i < count; i++ {
+		if _, err := handler.db.UpsertMQTTClient(fmt.Sprintf("device-%04d", i), mqttUser.ID, nil); err != nil {
+			t.Fatalf("UpsertMQTTClient() error = %v", err)
+		}
+	}
+	if _, err := handler.db.MarkMQTTClientInactive("device-0000"); err != nil {
+		t.Fatalf("MarkMQTTClientInactive() error = %v", err)
+	}
+
+	export := func(query string) []storage.MQTTClient {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/mqtt/clients"+query, nil)
+		req.Header.Set("Accept", "application/x-ndjson")
+		rec := httptest.NewRecorder()
+		handler.ListMQTTClients(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("ListMQTTClients() status = %v, want %v", rec.Code, http.StatusOK)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Errorf("Content-Type = %q, want application/x-ndjson", ct)
+		}
+
+		var clients []storage.MQTTClient
+		scanner := bufio.NewScanner(rec.Body)
+		for scanner.Scan() {
+			var client storage.MQTTClient
+			if err := json.Unmarshal(scanner.Bytes(), &client); err != nil {
+				t.Fatalf("line %d is not a JSON object: %v", len(clients)+1, err)
+			}
+			clients = append(clients, client)
+		}
+		return clients
+	}
+
+	// Page parameters are ignored: every client is streamed once, in ID order
+	clients := export("?pageSize=10")
+	if len(clients) != count {
+		t.Fatalf("NDJSON export has %d lines, want %d", len(clients), count)
+	}
+	for i := 1; i < len(clients); i++ {
+		if clients[i].ID <= clients[i-1].ID {
+			t.Fatalf("line %d has ID %d after %d, want ascending IDs", i+1, clients[i].ID, clients[i-1].ID)
+		}
+	}
+
+	if clients := export("?search=device-10&active=true"); len(clients) != 100 {
+		t.Errorf("filtered NDJSON export has %d lines, want 100", len(clients))
+	}
+	if clients := export("?search=device-000&active=true"); len(clients) != 9 {
+		t.Errorf("active NDJSON export has %d lines, want 9 (device-0000 is inactive)", len(clients))
+	}
+}
+
 func TestGetMQTTClientDetails(t *testing.T) {
 	handler := setupTestHandler(t)
 
@@ -707,6 +1024,45 @@ func TestGetMQTTClientDetails(t *testing.T) {
 	}
 }
 
+func TestGetMQTTClientDetails_QueueDepth(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.mqtt = mqtt.New(&mqtt.Config{MaxInflight: 10})
+
+	mqttUser, _ := handler.db.CreateMQTTUser("testdevice", "password123", "Test", nil)
+	client, _ := handler.db.UpsertMQTTClient("device-queue", mqttUser.ID, nil)
+
+	cl := handler.mqtt.NewClient(nil, "tcp", client.ClientID, false)
+	cl.State.Inflight.Set(packets.Packet{
+		FixedHeader: packets.FixedHeader{Type: packets.Publish, Qos: 1},
+		PacketID:    1,
+	})
+	handler.mqtt.Clients.Add(cl)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/mqtt/clients/"+client.ClientID, nil)
+	req.SetPathValue("client_id", client.ClientID)
+	rec := httptest.NewRecorder()
+
+	handler.GetMQTTClientDetails(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GetMQTTClientDetails() status = %v, want %v", rec.Code, http.StatusOK)
+	}
+
+	var response MQTTClientDetailsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.QueueDepth == nil || *response.QueueDepth != 1 {
+		t.Errorf("GetMQTTClientDetails() queue_depth = %v, want 1", response.QueueDepth)
+	}
+	if response.QueueLimit == nil || *response.QueueLimit != 10 {
+		t.Errorf("GetMQTTClientDetails() queue_limit = %v, want 10", response.QueueLimit)
+	}
+	if !response.IsActive {
+		t.Error("GetMQTTClientDetails() is_active = false, want true")
+	}
+}
+
 func TestUpdateMQTTClientMetadata(t *testing.T) {
 	handler := setupTestHandler(t)
 
@@ -782,7 +1138,7 @@ func TestDeleteMQTTClient(t *testing.T) {
 		{
 			name:           "delete non-existent client",
 			id:             "999999",
-			wantStatusCode: http.StatusInternalServerError,
+			wantStatusCode: http.StatusNotFound,
 		},
 		{
 			name:           "delete with invalid ID",
@@ -1054,7 +1410,7 @@ func TestBlockProvisionedACLRuleUpdate(t *testing.T) {
 	manualRule, _ := handler.db.CreateACLRule(user.ID, "manual/topic/#", "pubsub")
 
 	// Create provisioned rule
-	handler.db.CreateProvisionedACLRule(user.ID, "provisioned/topic/#", "pubsub")
+	handler.db.CreateProvisionedACLRule(user.ID, "provisioned/topic/#", "pubsub", false)
 	provisionedRule, _ := handler.db.GetACLRulesByMQTTUserID(user.ID)
 	var provisionedRuleID int
 	for _, rule := range provisionedRule {
@@ -1119,7 +1475,7 @@ func TestBlockProvisionedACLRuleDelete(t *testing.T) {
 	manualRule, _ := handler.db.CreateACLRule(user.ID, "manual/delete/#", "pubsub")
 
 	// Create provisioned rule
-	handler.db.CreateProvisionedACLRule(user.ID, "provisioned/delete/#", "pubsub")
+	handler.db.CreateProvisionedACLRule(user.ID, "provisioned/delete/#", "pubsub", false)
 	provisionedRule, _ := handler.db.GetACLRulesByMQTTUserID(user.ID)
 	var provisionedRuleID int
 	for _, rule := range provisionedRule {
@@ -1168,3 +1524,58 @@ func TestBlockProvisionedACLRuleDelete(t *testing.T) {
 		})
 	}
 }
+
+func TestGetMQTTSession(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.mqtt = mqtt.New(&mqtt.Config{MaxInflight: 10})
+
+	// An offline persistent client with two messages held for it
+	cl := handler.mqtt.NewClient(nil, "tcp", "device-offline", false)
+	cl.Properties.ProtocolVersion = 4
+	cl.State.Subscriptions.Add("alerts/#", packets.Subscription{Filter: "alerts/#", Qos: 1})
+	for id := uint16(1); id <= 2; id++ {
+		cl.State.Inflight.Set(packets.Packet{
+			FixedHeader: packets.FixedHeader{Type: packets.Publish, Qos: 1},
+			TopicName:   "alerts/fire",
+			PacketID:    id,
+		})
+	}
+	cl.Stop(errors.New("client went offline"))
+	handler.mqtt.Clients.Add(cl)
+
+	// Stored by the durable session hook
+	if err := handler.badger.SaveSessionClient(&mochistorage.Client{ID: "device-offline"}); err != nil {
+		t.Fatalf("Failed to store session: %v", err)
+	}
+
+	get := func(clientID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/mqtt/sessions/"+clientID, nil)
+		req.SetPathValue("client_id", clientID)
+		rec := httptest.NewRecorder()
+		handler.GetMQTTSession(rec, req)
+		return rec
+	}
+
+	rec := get("device-offline")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GetMQTTSession() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var response MQTTSessionResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Connected || response.QueuedCount != 2 || response.InflightCount != 0 {
+		t.Errorf("GetMQTTSession() connected = %v, queued = %d, inflight = %d; want false, 2, 0",
+			response.Connected, response.QueuedCount, response.InflightCount)
+	}
+	if len(response.Subscriptions) != 1 || response.Subscriptions[0].Topic != "alerts/#" || response.Subscriptions[0].QoS != 1 {
+		t.Errorf("GetMQTTSession() subscriptions = %+v, want alerts/# at QoS 1", response.Subscriptions)
+	}
+	if !response.Persisted {
+		t.Error("GetMQTTSession() persisted = false, want true")
+	}
+
+	if rec := get("unknown"); rec.Code != http.StatusNotFound {
+		t.Errorf("GetMQTTSession() unknown client status = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+}
@@ -29,7 +29,7 @@ import (
 // @Failure 500 {object} ErrorResponse
 // @Router /dashboard/users [get]
 func (h *Handler) ListDashboardUsers(w http.ResponseWriter, r *http.Request) {
-	params := parsePaginationParams(r)
+	params := h.parsePaginationParams(r)
 
 	users, total, err := h.db.ListDashboardUsersPaginated(params.Page, params.PageSize, params.Search, params.SortBy, params.SortOrder)
 	if err != nil {
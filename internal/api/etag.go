@@ -0,0 +1,30 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// etag formats a resource version as a strong ETag
+func etag(version uint) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// ifMatchVersion returns the version in the request's If-Match header, as
+// sent back from a previous ETag. 0 means the header is absent or "*", so any
+// version may be updated
+func ifMatchVersion(r *http.Request) (uint, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return 0, nil
+	}
+
+	value = strings.Trim(strings.TrimPrefix(value, "W/"), `"`)
+	version, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || version == 0 {
+		return 0, fmt.Errorf("invalid If-Match header")
+	}
+	return uint(version), nil
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// sseKeepaliveInterval is how often a comment line is sent to keep idle streams open
+const sseKeepaliveInterval = 30 * time.Second
+
+// StreamEvents godoc
+// @Summary Stream live events
+// @Description Server-Sent Events stream of client connects/disconnects, publish summaries, and bridge status changes
+// @Tags Events
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param access_token query string false "Stream token from /auth/stream-token, for clients that can't set the Authorization header"
+// @Param types query string false "Comma-separated event types to include (client.connected, client.disconnected, client.taken_over, message.published, bridge.status)"
+// @Success 200 {string} string "text/event-stream"
+// @Failure 401 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse "Event stream not available"
+// @Router /events/stream [get]
+func (h *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	if h.events == nil {
+		http.Error(w, `{"error":"event stream not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	var types []string
+	for _, t := range strings.Split(r.URL.Query().Get("types"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+
+	sub := h.events.Subscribe(types...)
+	defer sub.Close()
+
+	// Streams outlive the server's write timeout
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable proxy buffering (nginx)
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepalive := time.NewTicker(sseKeepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.streamsDone:
+			return
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
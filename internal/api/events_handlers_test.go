@@ -0,0 +1,79 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github/bromq-dev/bromq/internal/events"
+)
+
+func TestStreamEvents(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.events = events.NewBus()
+
+	server := httptest.NewServer(http.HandlerFunc(handler.StreamEvents))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?types=" + events.ClientConnected)
+	if err != nil {
+		t.Fatalf("Failed to connect to stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("StreamEvents() status = %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	// Filtered out by the types query param
+	handler.events.Publish(events.MessagePublished, map[string]interface{}{"topic": "a/b"})
+	// Simulated client connect
+	handler.events.Publish(events.ClientConnected, map[string]interface{}{"client_id": "device-1"})
+
+	received := make(chan events.Event, 1)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				var event events.Event
+				if err := json.Unmarshal([]byte(data), &event); err == nil {
+					received <- event
+				}
+				return
+			}
+		}
+	}()
+
+	select {
+	case event := <-received:
+		if event.Type != events.ClientConnected {
+			t.Errorf("event type = %s, want %s", event.Type, events.ClientConnected)
+		}
+		if event.Data["client_id"] != "device-1" {
+			t.Errorf("event client_id = %v, want device-1", event.Data["client_id"])
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Did not receive connect event")
+	}
+}
+
+func TestStreamEvents_NoBus(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/events/stream", nil)
+	rec := httptest.NewRecorder()
+
+	handler.StreamEvents(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("StreamEvents() status = %v, want %v", rec.Code, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+// GzipMiddleware compresses JSON responses of at least minSize bytes for clients that send Accept-Encoding: gzip
+// Responses that already carry a Content-Encoding or are not JSON are passed through untouched
+func GzipMiddleware(minSize int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			if !acceptsGzip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			gw := &gzipResponseWriter{ResponseWriter: w, minSize: minSize}
+			defer gw.Close()
+
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
+
+// acceptsGzip reports whether the request allows a gzip-encoded response
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if strings.TrimSpace(name) == "gzip" && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter buffers the start of a response until it knows whether compression applies
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize     int
+	statusCode  int
+	buf         []byte
+	gz          *gzip.Writer
+	passthrough bool
+}
+
+// WriteHeader defers the status code until the compression decision is made
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if w.gz != nil || w.passthrough {
+		return
+	}
+	w.statusCode = code
+}
+
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	if w.gz != nil {
+		return w.gz.Write(p)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.minSize {
+		if err := w.start(w.shouldCompress()); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush sends any buffered data uncompressed so streaming responses are not held back
+func (w *gzipResponseWriter) Flush() {
+	if w.gz == nil && !w.passthrough {
+		_ = w.start(false)
+	}
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Close finishes the response, writing small bodies uncompressed
+func (w *gzipResponseWriter) Close() {
+	if w.gz != nil {
+		_ = w.gz.Close()
+		return
+	}
+	if !w.passthrough {
+		_ = w.start(false)
+	}
+}
+
+// shouldCompress reports whether the response content is eligible for gzip
+func (w *gzipResponseWriter) shouldCompress() bool {
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	return strings.HasPrefix(h.Get("Content-Type"), "application/json")
+}
+
+// start writes the header and buffered data, switching to gzip when compress is true
+func (w *gzipResponseWriter) start(compress bool) error {
+	if compress {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	} else {
+		w.passthrough = true
+	}
+
+	if w.statusCode != 0 {
+		w.ResponseWriter.WriteHeader(w.statusCode)
+	}
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if w.gz != nil {
+		_, err := w.gz.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGzipMiddleware_ListMQTTClients(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	mqttUser, err := handler.db.CreateMQTTUser("gzipdevice", "password123", "Test", nil)
+	if err != nil {
+		t.Fatalf("Failed to create MQTT user: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		if _, err := handler.db.UpsertMQTTClient(fmt.Sprintf("gzip-client-%03d", i), mqttUser.ID, nil); err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+	}
+
+	list := GzipMiddleware(1024)(http.HandlerFunc(handler.ListMQTTClients))
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		wantGzip       bool
+	}{
+		{
+			name:           "gzip requested",
+			acceptEncoding: "gzip, deflate",
+			wantGzip:       true,
+		},
+		{
+			name:           "gzip explicitly refused",
+			acceptEncoding: "gzip;q=0",
+			wantGzip:       false,
+		},
+		{
+			name:           "no accept encoding",
+			acceptEncoding: "",
+			wantGzip:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/mqtt/clients?pageSize=100", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+
+			list.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("ListMQTTClients() status = %v, want %v", rec.Code, http.StatusOK)
+			}
+
+			gotGzip := rec.Header().Get("Content-Encoding") == "gzip"
+			if gotGzip != tt.wantGzip {
+				t.Fatalf("Content-Encoding gzip = %v, want %v", gotGzip, tt.wantGzip)
+			}
+
+			var body io.Reader = rec.Body
+			if gotGzip {
+				gz, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatalf("Failed to create gzip reader: %v", err)
+				}
+				defer gz.Close()
+				body = gz
+			}
+
+			var response PaginatedResponse
+			if err := json.NewDecoder(body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Pagination.Total != 50 {
+				t.Errorf("Pagination.Total = %v, want 50", response.Pagination.Total)
+			}
+		})
+	}
+}
+
+func TestGzipMiddleware_SmallAndEncodedResponses(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		wantGzip bool
+	}{
+		{
+			name: "small JSON response not compressed",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"status":"ok"}`))
+			},
+			wantGzip: false,
+		},
+		{
+			name: "already encoded response not compressed again",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Encoding", "br")
+				_, _ = w.Write(make([]byte, 4096))
+			},
+			wantGzip: false,
+		},
+		{
+			name: "non-JSON response not compressed",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				_, _ = w.Write(make([]byte, 4096))
+			},
+			wantGzip: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/test", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+
+			GzipMiddleware(1024)(tt.handler).ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Encoding") == "gzip"; got != tt.wantGzip {
+				t.Errorf("Content-Encoding gzip = %v, want %v", got, tt.wantGzip)
+			}
+		})
+	}
+}
@@ -2,10 +2,18 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
-
+	"sync"
+	"time"
+
+	"github/bromq-dev/bromq/hooks/auth"
+	"github/bromq-dev/bromq/hooks/metrics"
+	"github/bromq-dev/bromq/hooks/retained"
+	"github/bromq-dev/bromq/internal/badgerstore"
+	"github/bromq-dev/bromq/internal/events"
 	"github/bromq-dev/bromq/internal/mqtt"
 	"github/bromq-dev/bromq/internal/script"
 	"github/bromq-dev/bromq/internal/storage"
@@ -14,19 +22,96 @@ import (
 // Handler holds dependencies for API handlers
 type Handler struct {
 	db     *storage.DB
+	badger *badgerstore.BadgerStore
 	mqtt   *mqtt.Server
 	engine *script.Engine
 	config *Config
+	topics RecentTopicSource
+	acl    UnmatchedACLSource
+	events *events.Bus
+
+	lastValues LastValueSource        // nil = last value cache disabled
+	throughput ThroughputSource       // nil = throughput history unavailable
+	bridges    BridgeStatusSource     // nil = bridge connection state unknown
+	routes     TopicRouteReloader     // nil = topic routes are not applied
+	retained   retained.RetainedStore // Retained message store (BadgerDB unless MQTT_RETAINED_BACKEND=sql)
+
+	defaultACL  []storage.StaticACLRule // Applied to new MQTT users on request
+	provisioned storage.ProvisionedSet  // What the config file provisions, for flag repair
+
+	revoked *RevokedTokens // Logged out JWTs, rejected by the auth middleware
+
+	maintenance sync.Mutex // Held while a compaction runs
+
+	streamsDone      chan struct{} // Closed on server shutdown to end SSE and WebSocket streams
+	closeStreamsOnce sync.Once
+}
+
+// RecentTopicSource provides message counts for recently published topics
+type RecentTopicSource interface {
+	RecentTopics() map[string]int64
+}
+
+// LastValueSource provides the most recent message published to a topic
+type LastValueSource interface {
+	Get(topic string) (*metrics.LastValue, bool)
+}
+
+// ThroughputSource provides the recent message and byte rate history
+type ThroughputSource interface {
+	Throughput(window time.Duration) metrics.ThroughputStats
+}
+
+// BridgeStatusSource provides the connection state of the running bridges
+type BridgeStatusSource interface {
+	ConnectedCount() int
+}
+
+// RetainedSizer is a retained store that accounts the payload bytes it holds
+// (retained.RetainedHook); GET /api/metrics reports it as retained_bytes
+type RetainedSizer interface {
+	RetainedBytes() int64
+}
+
+// RetainedRecounter is a retained store whose accounted bytes can be recounted
+// from storage (retained.RetainedHook), see POST /api/admin/retained/reload
+type RetainedRecounter interface {
+	Recount() ([]*badgerstore.RetainedMessage, error)
+}
+
+// TopicRouteReloader applies the stored topic routes after they change
+type TopicRouteReloader interface {
+	Reload() error
+}
+
+// UnmatchedACLSource provides recent publish/subscribe attempts denied
+// because no ACL rule matched, keyed by username
+type UnmatchedACLSource interface {
+	Unmatched() map[string][]auth.UnmatchedAttempt
 }
 
 // NewHandler creates a new API handler
-func NewHandler(db *storage.DB, mqttServer *mqtt.Server, scriptEngine *script.Engine, config *Config) *Handler {
-	return &Handler{
-		db:     db,
-		mqtt:   mqttServer,
-		engine: scriptEngine,
-		config: config,
+func NewHandler(db *storage.DB, badgerStore *badgerstore.BadgerStore, mqttServer *mqtt.Server, scriptEngine *script.Engine, config *Config) *Handler {
+	h := &Handler{
+		db:      db,
+		badger:  badgerStore,
+		mqtt:    mqttServer,
+		engine:  scriptEngine,
+		config:  config,
+		revoked: NewRevokedTokens(),
+
+		streamsDone: make(chan struct{}),
+	}
+	if badgerStore != nil {
+		h.retained = badgerStore
 	}
+	return h
+}
+
+// closeStreams ends the long-lived SSE and WebSocket streams, which would
+// otherwise hold up a graceful shutdown until its deadline
+func (h *Handler) closeStreams() {
+	h.closeStreamsOnce.Do(func() { close(h.streamsDone) })
 }
 
 // Login godoc
@@ -60,6 +145,10 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := h.db.RecordDashboardLogin(user); err != nil {
+		LoggerFromContext(r.Context()).Warn("Failed to record dashboard login", "username", user.Username, "error", err)
+	}
+
 	token, err := GenerateJWT(h.config.JWTSecretBytes(), user.ID, user.Username, user.Role)
 	if err != nil {
 		http.Error(w, fmt.Sprintf(`{"error":"failed to generate token: %s"}`, err), http.StatusInternalServerError)
@@ -73,6 +162,92 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// Logout godoc
+// @Summary Logout from dashboard
+// @Description Revoke the JWT used for this request so it is rejected until it expires
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse "Token has no ID to revoke"
+// @Failure 401 {object} ErrorResponse
+// @Router /auth/logout [post]
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := GetUserFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	// Tokens issued before jti was added can't be told apart, so they stay valid until they expire
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		http.Error(w, `{"error":"token cannot be revoked"}`, http.StatusBadRequest)
+		return
+	}
+
+	h.revoked.Revoke(claims.ID, claims.ExpiresAt.Time)
+	LoggerFromContext(r.Context()).Info("Dashboard user logged out", "username", claims.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(SuccessResponse{Message: "logged out"})
+}
+
+// StreamToken godoc
+// @Summary Get a stream token
+// @Description Issue a short-lived token for /events/stream and /ws. Browsers can't set the Authorization header on EventSource or WebSocket requests, so pass it as the access_token query parameter instead. It is rejected by every other endpoint
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} StreamTokenResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/stream-token [post]
+func (h *Handler) StreamToken(w http.ResponseWriter, r *http.Request) {
+	claims, ok := GetUserFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	expiresAt := time.Now().Add(StreamTokenTTL)
+	token, err := GenerateStreamToken(h.config.JWTSecretBytes(), claims.UserID, claims.Username, claims.Role, expiresAt)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to generate token: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(StreamTokenResponse{Token: token, ExpiresAt: expiresAt})
+}
+
+// Me godoc
+// @Summary Get current user
+// @Description Get the profile (username, role, last login) of the dashboard user the token belongs to
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} storage.DashboardUser
+// @Failure 401 {object} ErrorResponse "Not authenticated, or the user no longer exists"
+// @Router /auth/me [get]
+func (h *Handler) Me(w http.ResponseWriter, r *http.Request) {
+	claims, ok := GetUserFromContext(r)
+	if !ok {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	// Read from the database so a role changed since login is reported as it is now
+	user, err := h.db.GetDashboardUser(claims.UserID)
+	if err != nil {
+		// The token outlived its user (deleted since login)
+		http.Error(w, fmt.Sprintf(`{"error":"user not found: %s"}`, err), http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(user)
+}
+
 // ListACL godoc
 // @Summary List ACL rules
 // @Description Get paginated list of access control rules
@@ -83,18 +258,36 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 // @Param page query int false "Page number" default(1)
 // @Param pageSize query int false "Items per page" default(25)
 // @Param search query string false "Search by topic"
+// @Param mqttUserId query int false "Only rules of this MQTT user"
+// @Param permission query string false "Only rules with this permission (pub, sub, pubsub)"
 // @Param sortBy query string false "Sort field" default(id)
 // @Param sortOrder query string false "Sort order (asc/desc)" default(asc)
 // @Success 200 {object} PaginatedResponse{data=[]storage.ACLRule}
+// @Failure 400 {object} ErrorResponse "Invalid filter"
 // @Failure 401 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /acl [get]
 func (h *Handler) ListACL(w http.ResponseWriter, r *http.Request) {
 	// Parse pagination parameters
-	params := parsePaginationParams(r)
+	params := h.parsePaginationParams(r)
+
+	var mqttUserID uint
+	if idStr := r.URL.Query().Get("mqttUserId"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil || id == 0 {
+			http.Error(w, `{"error":"invalid mqttUserId"}`, http.StatusBadRequest)
+			return
+		}
+		mqttUserID = uint(id)
+	}
+	permission := r.URL.Query().Get("permission")
+	if permission != "" && permission != "pub" && permission != "sub" && permission != "pubsub" {
+		http.Error(w, `{"error":"invalid permission (must be pub, sub, or pubsub)"}`, http.StatusBadRequest)
+		return
+	}
 
 	// Get paginated rules
-	rules, total, err := h.db.ListACLRulesPaginated(params.Page, params.PageSize, params.Search, params.SortBy, params.SortOrder)
+	rules, total, err := h.db.ListACLRulesPaginated(params.Page, params.PageSize, params.Search, params.SortBy, params.SortOrder, mqttUserID, permission)
 	if err != nil {
 		http.Error(w, fmt.Sprintf(`{"error":"failed to list ACL rules: %s"}`, err), http.StatusInternalServerError)
 		return
@@ -148,17 +341,88 @@ func (h *Handler) CreateACL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rule, err := h.db.CreateACLRule(req.MQTTUserID, req.Topic, req.Permission)
+	rule, err := h.db.CreateACLRuleFromInput(storage.ACLRuleInput{
+		MQTTUserID: req.MQTTUserID,
+		Topic:      req.Topic,
+		Permission: req.Permission,
+		Retain:     req.Retain,
+	})
 	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"failed to create ACL rule: %s"}`, err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(rule.Version))
 	w.WriteHeader(http.StatusCreated)
 	_ = json.NewEncoder(w).Encode(rule)
 }
 
+// ExpandACLPattern godoc
+// @Summary Preview ACL placeholder expansion
+// @Description Resolve the placeholders of a topic pattern for a client, as the ACL check does on each publish and subscribe. Date placeholders (${year}, ${month}, ${day}) resolve to the current UTC date
+// @Tags ACL
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param pattern query string true "Topic pattern, e.g. devices/${clientid}/#"
+// @Param username query string false "MQTT username"
+// @Param clientId query string false "MQTT client ID"
+// @Success 200 {object} ExpandACLPatternResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /acl/expand [get]
+func (h *Handler) ExpandACLPattern(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	pattern := query.Get("pattern")
+	if pattern == "" {
+		http.Error(w, `{"error":"pattern is required"}`, http.StatusBadRequest)
+		return
+	}
+	username, clientID := query.Get("username"), query.Get("clientId")
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ExpandACLPatternResponse{
+		Pattern:  pattern,
+		Username: username,
+		ClientID: clientID,
+		Expanded: storage.ExpandTopicPattern(pattern, username, clientID),
+	})
+}
+
+// GetACL godoc
+// @Summary Get ACL rule
+// @Description Get a single access control rule by ID, with the MQTT user it applies to
+// @Tags ACL
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ACL Rule ID"
+// @Success 200 {object} ACLRuleDetailsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /acl/{id} [get]
+func (h *Handler) GetACL(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	idVal, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid ACL rule ID"}`, http.StatusBadRequest)
+		return
+	}
+	id := uint(idVal)
+
+	rule, err := h.db.GetACLRuleWithUser(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"ACL rule not found: %s"}`, err), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(rule.Version))
+	_ = json.NewEncoder(w).Encode(ACLRuleDetailsResponse{ACLRule: *rule, MQTTUser: &rule.MQTTUser})
+}
+
 // UpdateACL godoc
 // @Summary Update ACL rule
 // @Description Update an existing access control rule
@@ -168,12 +432,14 @@ func (h *Handler) CreateACL(w http.ResponseWriter, r *http.Request) {
 // @Security BearerAuth
 // @Param id path int true "ACL Rule ID"
 // @Param rule body UpdateACLRequest true "Updated ACL rule details"
+// @Param If-Match header string false "ETag from a previous read; the update fails with 412 if the rule has changed since"
 // @Success 200 {object} storage.ACLRule
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 403 {object} ErrorResponse "Admin only"
 // @Failure 404 {object} ErrorResponse "Rule not found"
 // @Failure 409 {object} ErrorResponse "Provisioned resource cannot be modified"
+// @Failure 412 {object} ErrorResponse "If-Match version is stale"
 // @Failure 500 {object} ErrorResponse
 // @Router /acl/{id} [put]
 func (h *Handler) UpdateACL(w http.ResponseWriter, r *http.Request) {
@@ -197,19 +463,36 @@ func (h *Handler) UpdateACL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err), http.StatusBadRequest)
+		return
+	}
+
 	var req UpdateACLRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, fmt.Sprintf(`{"error":"invalid request: %s"}`, err), http.StatusBadRequest)
 		return
 	}
 
-	rule, err := h.db.UpdateACLRule(id, req.Topic, req.Permission)
+	rule, err := h.db.UpdateACLRuleIfVersion(id, version, req.Topic, req.Permission)
 	if err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			http.Error(w, `{"error":"ACL rule was modified by another request; reload it and try again"}`, http.StatusPreconditionFailed)
+			return
+		}
 		http.Error(w, fmt.Sprintf(`{"error":"failed to update ACL rule: %s"}`, err), http.StatusInternalServerError)
 		return
 	}
+	if req.Retain != nil {
+		if rule, err = h.db.SetACLRuleRetain(id, *req.Retain); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"failed to update ACL rule: %s"}`, err), http.StatusInternalServerError)
+			return
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(rule.Version))
 	_ = json.NewEncoder(w).Encode(rule)
 }
 
@@ -259,6 +542,171 @@ func (h *Handler) DeleteACL(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(SuccessResponse{Message: "ACL rule deleted"})
 }
 
+// maxBulkACLItems caps the number of rules in one bulk request
+const maxBulkACLItems = 1000
+
+// BulkCreateACL godoc
+// @Summary Create ACL rules in bulk
+// @Description Create many access control rules in one transaction. Each rule is reported separately; an invalid or duplicate rule fails on its own without affecting the others
+// @Tags ACL
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param rules body BulkCreateACLRequest true "Rules to create (at most 1000)"
+// @Success 200 {object} BulkACLResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 500 {object} ErrorResponse
+// @Router /acl/bulk [post]
+func (h *Handler) BulkCreateACL(w http.ResponseWriter, r *http.Request) {
+	var req BulkCreateACLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid request: %s"}`, err), http.StatusBadRequest)
+		return
+	}
+	if len(req.Rules) == 0 || len(req.Rules) > maxBulkACLItems {
+		http.Error(w, fmt.Sprintf(`{"error":"rules must contain between 1 and %d items"}`, maxBulkACLItems), http.StatusBadRequest)
+		return
+	}
+
+	inputs := make([]storage.ACLRuleInput, len(req.Rules))
+	for i, rule := range req.Rules {
+		inputs[i] = storage.ACLRuleInput{MQTTUserID: rule.MQTTUserID, Topic: rule.Topic, Permission: rule.Permission, Retain: rule.Retain}
+	}
+
+	results, err := h.db.CreateACLRules(inputs)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(newBulkACLResponse(results))
+}
+
+// BulkDeleteACL godoc
+// @Summary Delete ACL rules in bulk
+// @Description Delete many access control rules in one transaction. Provisioned rules are skipped and unknown IDs fail; both are reported per item
+// @Tags ACL
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param ids body BulkDeleteACLRequest true "Rule IDs to delete (at most 1000)"
+// @Success 200 {object} BulkACLResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 500 {object} ErrorResponse
+// @Router /acl/bulk/delete [post]
+func (h *Handler) BulkDeleteACL(w http.ResponseWriter, r *http.Request) {
+	var req BulkDeleteACLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid request: %s"}`, err), http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > maxBulkACLItems {
+		http.Error(w, fmt.Sprintf(`{"error":"ids must contain between 1 and %d items"}`, maxBulkACLItems), http.StatusBadRequest)
+		return
+	}
+
+	results, err := h.db.DeleteACLRulesByID(req.IDs)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(newBulkACLResponse(results))
+}
+
+// newBulkACLResponse tallies per-item results
+func newBulkACLResponse(results []storage.BulkACLResult) BulkACLResponse {
+	response := BulkACLResponse{Results: results}
+	for _, result := range results {
+		switch result.Status {
+		case storage.BulkStatusSkipped:
+			response.Skipped++
+		case storage.BulkStatusFailed:
+			response.Failed++
+		default:
+			response.Succeeded++
+		}
+	}
+	return response
+}
+
+// CopyACL godoc
+// @Summary Copy ACL rules from another user
+// @Description Copy all non-provisioned ACL rules of the source MQTT user to the target user. Topics the target already has a rule for are skipped
+// @Tags ACL
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Target MQTT User ID"
+// @Param sourceId path int true "Source MQTT User ID"
+// @Success 200 {object} CopyACLResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 404 {object} ErrorResponse "User not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /mqtt/users/{id}/acl/copy-from/{sourceId} [post]
+func (h *Handler) CopyACL(w http.ResponseWriter, r *http.Request) {
+	targetVal, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid user ID"}`, http.StatusBadRequest)
+		return
+	}
+	sourceVal, err := strconv.ParseUint(r.PathValue("sourceId"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid source user ID"}`, http.StatusBadRequest)
+		return
+	}
+	targetID, sourceID := uint(targetVal), uint(sourceVal)
+
+	if targetID == sourceID {
+		http.Error(w, `{"error":"source and target must be different users"}`, http.StatusBadRequest)
+		return
+	}
+	for _, id := range []uint{targetID, sourceID} {
+		if _, err := h.db.GetMQTTUser(id); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"MQTT user %d not found"}`, id), http.StatusNotFound)
+			return
+		}
+	}
+
+	copied, skipped, err := h.db.CopyACLRules(targetID, sourceID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to copy ACL rules: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(CopyACLResponse{Copied: copied, Skipped: skipped})
+}
+
+// ListUnmatchedACL godoc
+// @Summary List unmatched ACL attempts
+// @Description Recent publishes/subscribes denied because no ACL rule matched the topic, grouped by username (requires MQTT_ACL_AUDIT_UNMATCHED)
+// @Tags ACL
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} UnmatchedACLResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /acl/unmatched [get]
+func (h *Handler) ListUnmatchedACL(w http.ResponseWriter, r *http.Request) {
+	response := UnmatchedACLResponse{Users: map[string][]auth.UnmatchedAttempt{}}
+	if h.acl != nil {
+		response.Enabled = true
+		response.Users = h.acl.Unmatched()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
 // ListClients godoc
 // @Summary List connected clients
 // @Description Get list of all currently connected MQTT clients with their connection details
@@ -338,17 +786,93 @@ func (h *Handler) DisconnectClient(w http.ResponseWriter, r *http.Request) {
 
 // GetMetrics godoc
 // @Summary Get server metrics
-// @Description Get MQTT server metrics in JSON format including clients, messages, subscriptions, and system stats
+// @Description Get MQTT server metrics in JSON format including clients, messages, subscriptions, retained payload bytes and system stats
 // @Tags Metrics
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {object} object
+// @Success 200 {object} MetricsResponse
 // @Failure 401 {object} ErrorResponse
 // @Router /metrics [get]
 func (h *Handler) GetMetrics(w http.ResponseWriter, r *http.Request) {
-	metrics := h.mqtt.GetMetrics()
+	response := MetricsResponse{Metrics: h.mqtt.GetMetrics()}
+	if h.engine != nil {
+		response.ScriptsDisabled = h.engine.ScriptsDisabled()
+	}
+	if sizer, ok := h.retained.(RetainedSizer); ok {
+		response.RetainedBytes = sizer.RetainedBytes()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// GetSummary godoc
+// @Summary Get dashboard summary
+// @Description Get counts of MQTT users, clients (total and active), ACL rules, bridges (total and connected), scripts (total and enabled) and retained messages in one call
+// @Tags Metrics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} SummaryResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /summary [get]
+func (h *Handler) GetSummary(w http.ResponseWriter, r *http.Request) {
+	counts, err := h.db.CountResources()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to count resources: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	response := SummaryResponse{
+		ResourceCounts: *counts,
+	}
+	// Without a retained store there are no retained messages to count
+	if h.retained != nil {
+		response.RetainedMessages, err = h.retained.CountRetainedMessages()
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"failed to count retained messages: %s"}`, err), http.StatusInternalServerError)
+			return
+		}
+	}
+	if h.bridges != nil {
+		response.BridgesConnected = h.bridges.ConnectedCount()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// GetThroughput godoc
+// @Summary Get throughput history
+// @Description Get PUBLISH message and byte counts in 10 second buckets over a recent window, with average rates per second. Kept in memory for the last hour
+// @Tags Metrics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param window query string false "How far back to report, e.g. 5m or 1h (default 5m, max 1h)"
+// @Success 200 {object} metrics.ThroughputStats
+// @Failure 400 {object} ErrorResponse "Invalid window"
+// @Failure 401 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse "Throughput history unavailable"
+// @Router /stats/throughput [get]
+func (h *Handler) GetThroughput(w http.ResponseWriter, r *http.Request) {
+	if h.throughput == nil {
+		http.Error(w, `{"error":"throughput history is unavailable"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	window := 5 * time.Minute
+	if value := r.URL.Query().Get("window"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < metrics.ThroughputBucketSize || parsed > metrics.ThroughputRetention {
+			http.Error(w, `{"error":"window must be a duration between 10s and 1h"}`, http.StatusBadRequest)
+			return
+		}
+		window = parsed
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(metrics)
+	_ = json.NewEncoder(w).Encode(h.throughput.Throughput(window))
 }
@@ -6,8 +6,13 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
+	"github/bromq-dev/bromq/hooks/auth"
+	"github/bromq-dev/bromq/hooks/metrics"
+	"github/bromq-dev/bromq/internal/badgerstore"
 	"github/bromq-dev/bromq/internal/storage"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -59,11 +64,17 @@ func setupTestHandler(t *testing.T) *Handler {
 	// Create a mock MQTT server that implements the needed interface
 	// We'll cast it to *mqtt.Server for compatibility
 	// In reality, the handlers should use an interface, but for testing we use a workaround
+	badger := badgerstore.OpenInMemory(t)
 	return &Handler{
-		db:     db,
-		mqtt:   nil, // Use nil for now, handlers that need MQTT will be skipped
-		engine: nil, // No script engine needed for basic tests
-		config: testConfig,
+		db:       db,
+		badger:   badger,
+		retained: badger,
+		mqtt:     nil, // Use nil for now, handlers that need MQTT will be skipped
+		engine:   nil, // No script engine needed for basic tests
+		config:   testConfig,
+		revoked:  NewRevokedTokens(),
+
+		streamsDone: make(chan struct{}),
 	}
 }
 
@@ -159,6 +170,91 @@ func TestLogin(t *testing.T) {
 	}
 }
 
+func TestLogout(t *testing.T) {
+	handler := setupTestHandler(t)
+	auth := NewAuthMiddleware(handler.config, handler.revoked)
+	protected := auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	logout := auth(http.HandlerFunc(handler.Logout))
+
+	request := func(h http.Handler, method, token string) int {
+		req := httptest.NewRequest(method, "/api/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	loggedOut, err := GenerateJWT(handler.config.JWTSecretBytes(), 1, "admin", "admin")
+	if err != nil {
+		t.Fatalf("GenerateJWT() error = %v", err)
+	}
+	other, err := GenerateJWT(handler.config.JWTSecretBytes(), 1, "admin", "admin")
+	if err != nil {
+		t.Fatalf("GenerateJWT() error = %v", err)
+	}
+
+	if code := request(logout, http.MethodPost, loggedOut); code != http.StatusOK {
+		t.Fatalf("Logout() status = %v, want %v", code, http.StatusOK)
+	}
+
+	if code := request(protected, http.MethodGet, loggedOut); code != http.StatusUnauthorized {
+		t.Errorf("logged out token status = %v, want %v", code, http.StatusUnauthorized)
+	}
+	if code := request(protected, http.MethodGet, other); code != http.StatusOK {
+		t.Errorf("other token of the same user status = %v, want %v", code, http.StatusOK)
+	}
+	if code := request(logout, http.MethodPost, loggedOut); code != http.StatusUnauthorized {
+		t.Errorf("second Logout() status = %v, want %v", code, http.StatusUnauthorized)
+	}
+}
+
+func TestMe(t *testing.T) {
+	handler := setupTestHandler(t)
+	me := NewAuthMiddleware(handler.config, handler.revoked)(http.HandlerFunc(handler.Me))
+
+	// Log in through the handler so the profile carries the login time
+	body, _ := json.Marshal(LoginRequest{Username: "admin", Password: "admin"})
+	loginRec := httptest.NewRecorder()
+	handler.Login(loginRec, httptest.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewReader(body)))
+	if loginRec.Code != http.StatusOK {
+		t.Fatalf("Login() status = %v, want %v", loginRec.Code, http.StatusOK)
+	}
+	var login LoginResponse
+	if err := json.NewDecoder(loginRec.Body).Decode(&login); err != nil {
+		t.Fatalf("Failed to decode login response: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/me", nil)
+	req.Header.Set("Authorization", "Bearer "+login.Token)
+	rec := httptest.NewRecorder()
+	me.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Me() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var user storage.DashboardUser
+	if err := json.NewDecoder(rec.Body).Decode(&user); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if user.Username != "admin" || user.Role != "admin" {
+		t.Errorf("Me() = %s (%s), want admin (admin)", user.Username, user.Role)
+	}
+	if user.LastLoginAt == nil {
+		t.Error("Me() last_login_at not set after login")
+	}
+	if strings.Contains(rec.Body.String(), "password") {
+		t.Errorf("Me() response exposes the password hash: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	me.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/auth/me", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Me() without token status = %v, want %v", rec.Code, http.StatusUnauthorized)
+	}
+}
+
 func TestLogin_InvalidJSON(t *testing.T) {
 	handler := setupTestHandler(t)
 
@@ -262,6 +358,161 @@ func TestListACL_Empty(t *testing.T) {
 	}
 }
 
+func TestListACL_PaginationAndFilters(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	sensor, err := handler.db.CreateMQTTUser("sensor", "password123", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create test MQTT user: %v", err)
+	}
+	gateway, err := handler.db.CreateMQTTUser("gateway", "password123", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create test MQTT user: %v", err)
+	}
+
+	// 30 sensor rules alternating pub/sub, 10 gateway pubsub rules
+	for i := 0; i < 30; i++ {
+		permission := "pub"
+		if i%2 == 1 {
+			permission = "sub"
+		}
+		if _, err := handler.db.CreateACLRule(sensor.ID, fmt.Sprintf("sensors/%02d", i), permission); err != nil {
+			t.Fatalf("Failed to create ACL rule: %v", err)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := handler.db.CreateACLRule(gateway.ID, fmt.Sprintf("gateways/%02d/#", i), "pubsub"); err != nil {
+			t.Fatalf("Failed to create ACL rule: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantTotal int64
+		wantLen   int
+		check     func(storage.ACLRule) bool
+	}{
+		{"second page", "?page=2&pageSize=15", 40, 15, nil},
+		{"last partial page", "?page=3&pageSize=15", 40, 10, nil},
+		{"by user", fmt.Sprintf("?mqttUserId=%d&pageSize=100", gateway.ID), 10, 10,
+			func(r storage.ACLRule) bool { return r.MQTTUserID == gateway.ID }},
+		{"by permission", "?permission=sub&pageSize=100", 15, 15,
+			func(r storage.ACLRule) bool { return r.Permission == "sub" }},
+		{"user, permission and topic", fmt.Sprintf("?mqttUserId=%d&permission=pub&search=sensors/1&pageSize=100", sensor.ID), 5, 5,
+			func(r storage.ACLRule) bool { return r.Permission == "pub" && strings.HasPrefix(r.Topic, "sensors/1") }},
+		{"user without matches", fmt.Sprintf("?mqttUserId=%d&permission=pub", gateway.ID), 0, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ListACL(rec, httptest.NewRequest(http.MethodGet, "/api/acl"+tt.query, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("ListACL() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+			}
+
+			var response struct {
+				Data       []storage.ACLRule  `json:"data"`
+				Pagination PaginationMetadata `json:"pagination"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Pagination.Total != tt.wantTotal || len(response.Data) != tt.wantLen {
+				t.Errorf("ListACL(%s) = %d rules of %d, want %d of %d", tt.query, len(response.Data), response.Pagination.Total, tt.wantLen, tt.wantTotal)
+			}
+			for _, rule := range response.Data {
+				if tt.check != nil && !tt.check(rule) {
+					t.Errorf("ListACL(%s) returned non-matching rule %+v", tt.query, rule)
+				}
+			}
+		})
+	}
+
+	for _, query := range []string{"?mqttUserId=abc", "?mqttUserId=0", "?permission=all"} {
+		rec := httptest.NewRecorder()
+		handler.ListACL(rec, httptest.NewRequest(http.MethodGet, "/api/acl"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("ListACL(%s) status = %v, want %v", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestParsePaginationParams_PageSize(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	tests := []struct {
+		name         string
+		config       Config
+		query        string
+		wantPageSize int
+	}{
+		{"built-in default", Config{}, "", 25},
+		{"above built-in max is clamped", Config{}, "?pageSize=500", 100},
+		{"configured default", Config{DefaultPageSize: 50, MaxPageSize: 500}, "", 50},
+		{"within configured max", Config{DefaultPageSize: 50, MaxPageSize: 500}, "?pageSize=400", 400},
+		{"above configured max is clamped", Config{DefaultPageSize: 50, MaxPageSize: 500}, "?pageSize=501", 500},
+		{"max above the ceiling is capped", Config{MaxPageSize: 5000}, "?pageSize=5000", 1000},
+		{"invalid page size uses the default", Config{DefaultPageSize: 50}, "?pageSize=-1", 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler.config = &tt.config
+			params := handler.parsePaginationParams(httptest.NewRequest(http.MethodGet, "/api/acl"+tt.query, nil))
+			if params.PageSize != tt.wantPageSize {
+				t.Errorf("parsePaginationParams(%q) page size = %d, want %d", tt.query, params.PageSize, tt.wantPageSize)
+			}
+		})
+	}
+
+	// Handlers report the clamped size in the pagination envelope
+	handler.config = &Config{MaxPageSize: 10}
+	rec := httptest.NewRecorder()
+	handler.ListACL(rec, httptest.NewRequest(http.MethodGet, "/api/acl?pageSize=100", nil))
+	var response PaginatedResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Pagination.PageSize != 10 {
+		t.Errorf("ListACL() page size = %d, want 10", response.Pagination.PageSize)
+	}
+
+	for _, config := range []Config{{JWTSecret: "secret", MaxPageSize: 1001}, {JWTSecret: "secret", DefaultPageSize: 200, MaxPageSize: 100}} {
+		if err := config.PostParse(); err == nil {
+			t.Errorf("PostParse() with default %d, max %d error = nil, want error", config.DefaultPageSize, config.MaxPageSize)
+		}
+	}
+}
+
+func TestListUnmatchedACL(t *testing.T) {
+	handler := setupTestHandler(t)
+	tracker := auth.NewUnmatchedTracker(0)
+	tracker.Record("sensor", "dev-1", "actuators/valve", "pub")
+	handler.acl = tracker
+
+	req := httptest.NewRequest(http.MethodGet, "/api/acl/unmatched", nil)
+	rec := httptest.NewRecorder()
+
+	handler.ListUnmatchedACL(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ListUnmatchedACL() status = %v, want %v", rec.Code, http.StatusOK)
+	}
+
+	var response UnmatchedACLResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if !response.Enabled {
+		t.Error("ListUnmatchedACL() enabled = false, want true")
+	}
+	attempts := response.Users["sensor"]
+	if len(attempts) != 1 || attempts[0].Topic != "actuators/valve" || attempts[0].Action != "pub" {
+		t.Errorf("ListUnmatchedACL() sensor attempts = %+v, want actuators/valve pub", attempts)
+	}
+}
+
 func TestCreateACL(t *testing.T) {
 	handler := setupTestHandler(t)
 
@@ -364,6 +615,171 @@ func TestCreateACL_InvalidJSON(t *testing.T) {
 	}
 }
 
+func TestExpandACLPattern(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	tests := []struct {
+		name     string
+		pattern  string
+		username string
+		clientID string
+		want     string
+	}{
+		{"replace username placeholder", "user/${username}/data", "alice", "device1", "user/alice/data"},
+		{"replace clientid placeholder", "device/${clientid}/telemetry", "alice", "sensor-001", "device/sensor-001/telemetry"},
+		{"replace both placeholders", "users/${username}/devices/${clientid}/status", "bob", "device-123", "users/bob/devices/device-123/status"},
+		{"no placeholders", "static/topic/path", "alice", "device1", "static/topic/path"},
+		{"multiple username placeholders", "${username}/${username}/data", "charlie", "device1", "charlie/charlie/data"},
+		{"with wildcards and placeholders", "user/${username}/+/${clientid}/#", "dave", "dev-999", "user/dave/+/dev-999/#"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := url.Values{"pattern": {tt.pattern}, "username": {tt.username}, "clientId": {tt.clientID}}
+			rec := httptest.NewRecorder()
+			handler.ExpandACLPattern(rec, httptest.NewRequest(http.MethodGet, "/api/acl/expand?"+query.Encode(), nil))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("ExpandACLPattern() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+			}
+			var got ExpandACLPatternResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if got.Expanded != tt.want {
+				t.Errorf("ExpandACLPattern(%q, %q, %q) = %q, want %q", tt.pattern, tt.username, tt.clientID, got.Expanded, tt.want)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ExpandACLPattern(rec, httptest.NewRequest(http.MethodGet, "/api/acl/expand?username=alice", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("ExpandACLPattern() without pattern status = %v, want %v", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestGetACL(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	mqttUser, err := handler.db.CreateMQTTUser("testuser", "password123", "Test user", nil)
+	if err != nil {
+		t.Fatalf("Failed to create test MQTT user: %v", err)
+	}
+	rule, err := handler.db.CreateACLRule(mqttUser.ID, "sensor/#", "pubsub")
+	if err != nil {
+		t.Fatalf("Failed to create test ACL rule: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		id             string
+		wantStatusCode int
+	}{
+		{
+			name:           "get existing rule",
+			id:             fmt.Sprintf("%d", rule.ID),
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "get non-existent rule",
+			id:             "999999",
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "get with invalid ID",
+			id:             "invalid",
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/acl/"+tt.id, nil)
+			req.SetPathValue("id", tt.id)
+			rec := httptest.NewRecorder()
+
+			handler.GetACL(rec, req)
+
+			if rec.Code != tt.wantStatusCode {
+				t.Fatalf("GetACL() status = %v, want %v: %s", rec.Code, tt.wantStatusCode, rec.Body.String())
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			var got ACLRuleDetailsResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if got.ID != rule.ID || got.Topic != "sensor/#" || got.Permission != "pubsub" {
+				t.Errorf("GetACL() = %+v, want rule %d sensor/# pubsub", got.ACLRule, rule.ID)
+			}
+			if got.MQTTUser == nil || got.MQTTUser.Username != "testuser" {
+				t.Errorf("GetACL() mqtt_user = %+v, want testuser", got.MQTTUser)
+			}
+			if rec.Header().Get("ETag") != etag(rule.Version) {
+				t.Errorf("GetACL() ETag = %q, want %q", rec.Header().Get("ETag"), etag(rule.Version))
+			}
+		})
+	}
+}
+
+func TestUpdateACL_IfMatch(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	mqttUser, err := handler.db.CreateMQTTUser("testuser", "password123", "Test user", nil)
+	if err != nil {
+		t.Fatalf("Failed to create test MQTT user: %v", err)
+	}
+	rule, err := handler.db.CreateACLRule(mqttUser.ID, "sensor/#", "pubsub")
+	if err != nil {
+		t.Fatalf("Failed to create test ACL rule: %v", err)
+	}
+	id := fmt.Sprintf("%d", rule.ID)
+
+	update := func(ifMatch, topic string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(UpdateACLRequest{Topic: topic, Permission: "pub"})
+		req := httptest.NewRequest(http.MethodPut, "/api/acl/"+id, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		req.SetPathValue("id", id)
+		rec := httptest.NewRecorder()
+		handler.UpdateACL(rec, req)
+		return rec
+	}
+
+	// Two editors read version 1; the first update wins
+	stale := etag(rule.Version)
+	rec := update(stale, "sensor/a/#")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("UpdateACL() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	current := rec.Header().Get("ETag")
+	if current == stale {
+		t.Fatalf("ETag = %s after update, want a new version", current)
+	}
+
+	if rec := update(stale, "sensor/b/#"); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("UpdateACL() with stale If-Match status = %v, want %v", rec.Code, http.StatusPreconditionFailed)
+	}
+	if got, _ := handler.db.GetACLRule(rule.ID); got.Topic != "sensor/a/#" {
+		t.Errorf("topic = %q after rejected update, want %q", got.Topic, "sensor/a/#")
+	}
+
+	if rec := update(current, "sensor/b/#"); rec.Code != http.StatusOK {
+		t.Errorf("UpdateACL() with current If-Match status = %v, want %v", rec.Code, http.StatusOK)
+	}
+	if rec := update("", "sensor/c/#"); rec.Code != http.StatusOK {
+		t.Errorf("UpdateACL() without If-Match status = %v, want %v", rec.Code, http.StatusOK)
+	}
+	if rec := update("not-a-version", "sensor/d/#"); rec.Code != http.StatusBadRequest {
+		t.Errorf("UpdateACL() with invalid If-Match status = %v, want %v", rec.Code, http.StatusBadRequest)
+	}
+}
+
 func TestDeleteACL(t *testing.T) {
 	handler := setupTestHandler(t)
 
@@ -592,6 +1008,141 @@ func TestGetMetrics(t *testing.T) {
 	// This test mainly verifies the endpoint doesn't crash
 }
 
+func TestGetThroughput(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/stats/throughput"+query, nil)
+		rec := httptest.NewRecorder()
+		handler.GetThroughput(rec, req)
+		return rec
+	}
+
+	if rec := get(""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("GetThroughput() without a source status = %v, want %v", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	tracker := metrics.NewThroughputTracker()
+	tracker.RecordIn(100)
+	handler.throughput = tracker
+
+	rec := get("?window=1m")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GetThroughput() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var stats metrics.ThroughputStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	var messages int64
+	for _, bucket := range stats.Buckets {
+		messages += bucket.MessagesIn
+	}
+	if len(stats.Buckets) != 7 || messages != 1 {
+		t.Errorf("buckets = %+v, want 6 complete + the current one, holding 1 publish", stats.Buckets)
+	}
+
+	for _, window := range []string{"soon", "1s", "2h"} {
+		if rec := get("?window=" + window); rec.Code != http.StatusBadRequest {
+			t.Errorf("GetThroughput(window=%s) status = %v, want %v", window, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+// fixedBridgeStatus reports a constant number of connected bridges
+type fixedBridgeStatus int
+
+func (f fixedBridgeStatus) ConnectedCount() int { return int(f) }
+
+func TestGetSummary(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	alice, err := handler.db.CreateMQTTUser("alice", "password", "", nil)
+	if err != nil {
+		t.Fatalf("CreateMQTTUser() error = %v", err)
+	}
+	if _, err := handler.db.CreateMQTTUser("bob", "password", "", nil); err != nil {
+		t.Fatalf("CreateMQTTUser() error = %v", err)
+	}
+	for _, pattern := range []string{"sensors/#", "alerts/#", "cmd/+"} {
+		if _, err := handler.db.CreateACLRule(alice.ID, pattern, "pubsub"); err != nil {
+			t.Fatalf("CreateACLRule() error = %v", err)
+		}
+	}
+	for _, clientID := range []string{"device-1", "device-2", "device-3"} {
+		if _, err := handler.db.UpsertMQTTClient(clientID, alice.ID, nil); err != nil {
+			t.Fatalf("UpsertMQTTClient() error = %v", err)
+		}
+	}
+	if _, err := handler.db.MarkMQTTClientInactive("device-3"); err != nil {
+		t.Fatalf("MarkMQTTClientInactive() error = %v", err)
+	}
+	for _, name := range []string{"cloud", "backup"} {
+		if _, err := handler.db.CreateBridge(name, "mqtt.example.com", 1883, "", "", "", "5", false, 60, 30, nil, "", nil, nil); err != nil {
+			t.Fatalf("CreateBridge() error = %v", err)
+		}
+	}
+	for i, enabled := range []bool{true, true, false} {
+		if _, err := handler.db.CreateScript(fmt.Sprintf("script-%d", i), "", "log.info('hi')", enabled, nil, nil); err != nil {
+			t.Fatalf("CreateScript() error = %v", err)
+		}
+	}
+	for _, topic := range []string{"status/a", "status/b"} {
+		if err := handler.retained.SaveRetainedMessage(topic, []byte("online"), 1); err != nil {
+			t.Fatalf("SaveRetainedMessage() error = %v", err)
+		}
+	}
+	handler.bridges = fixedBridgeStatus(1)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/summary", nil)
+	rec := httptest.NewRecorder()
+	handler.GetSummary(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GetSummary() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var got SummaryResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	want := SummaryResponse{
+		ResourceCounts: storage.ResourceCounts{
+			MQTTUsers:      2,
+			Clients:        3,
+			ActiveClients:  2,
+			ACLRules:       3,
+			Bridges:        2,
+			Scripts:        3,
+			EnabledScripts: 2,
+		},
+		BridgesConnected: 1,
+		RetainedMessages: 2,
+	}
+	if got != want {
+		t.Errorf("GetSummary() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGetSummary_NoRetainedStore(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.retained = nil
+
+	req := httptest.NewRequest(http.MethodGet, "/api/summary", nil)
+	rec := httptest.NewRecorder()
+	handler.GetSummary(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GetSummary() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var got SummaryResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if got.RetainedMessages != 0 {
+		t.Errorf("GetSummary() retained_messages = %d, want 0", got.RetainedMessages)
+	}
+}
+
 func TestHandlerCRUD_ACL_Integration(t *testing.T) {
 	handler := setupTestHandler(t)
 
@@ -684,3 +1235,195 @@ func TestHandlerCRUD_ACL_Integration(t *testing.T) {
 		t.Fatalf("Final ListACL() returned %d rules, want 0", len(response3.Data))
 	}
 }
+
+func TestBulkACL(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	mqttUser, err := handler.db.CreateMQTTUser("bulkuser", "password123", "", nil)
+	if err != nil {
+		t.Fatalf("Failed to create test MQTT user: %v", err)
+	}
+	existing, err := handler.db.CreateACLRule(mqttUser.ID, "devices/existing/#", "sub")
+	if err != nil {
+		t.Fatalf("Failed to create test ACL rule: %v", err)
+	}
+
+	post := func(handle http.HandlerFunc, path string, request interface{}) BulkACLResponse {
+		t.Helper()
+		body, _ := json.Marshal(request)
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handle(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("POST %s status = %v, want %v: %s", path, rec.Code, http.StatusOK, rec.Body.String())
+		}
+		var response BulkACLResponse
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return response
+	}
+
+	created := post(handler.BulkCreateACL, "/api/acl/bulk", BulkCreateACLRequest{Rules: []CreateACLRequest{
+		{MQTTUserID: mqttUser.ID, Topic: "devices/${clientid}/telemetry", Permission: "pub"},
+		{MQTTUserID: mqttUser.ID, Topic: "devices/${clientid}/cmd", Permission: "everything"}, // Invalid permission
+		{MQTTUserID: mqttUser.ID, Topic: "devices/existing/#", Permission: "sub"},             // Duplicate
+		{MQTTUserID: 9999, Topic: "devices/#", Permission: "sub"},                             // Unknown user
+		{MQTTUserID: mqttUser.ID, Topic: "devices/${clientid}/cmd", Permission: "sub"},
+	}})
+	if created.Succeeded != 2 || created.Failed != 3 {
+		t.Fatalf("BulkCreateACL() succeeded = %d, failed = %d; want 2, 3: %+v", created.Succeeded, created.Failed, created.Results)
+	}
+	wantStatus := []string{"created", "failed", "failed", "failed", "created"}
+	for i, result := range created.Results {
+		if result.Index != i || result.Status != wantStatus[i] {
+			t.Errorf("result %d = %+v, want status %s", i, result, wantStatus[i])
+		}
+		if result.Status == "failed" && result.Error == "" {
+			t.Errorf("result %d failed without an error message", i)
+		}
+	}
+
+	// Failed items don't roll back the rest of the batch
+	rules, _ := handler.db.GetACLRulesByMQTTUserID(mqttUser.ID)
+	if len(rules) != 3 {
+		t.Fatalf("user has %d rules, want 3", len(rules))
+	}
+
+	provisioned, err := handler.db.CreateACLRule(mqttUser.ID, "config/#", "sub")
+	if err != nil {
+		t.Fatalf("Failed to create test ACL rule: %v", err)
+	}
+	if err := handler.db.Model(&storage.ACLRule{}).Where("id = ?", provisioned.ID).Update("provisioned_from_config", true).Error; err != nil {
+		t.Fatalf("Failed to mark ACL rule as provisioned: %v", err)
+	}
+
+	deleted := post(handler.BulkDeleteACL, "/api/acl/bulk/delete", BulkDeleteACLRequest{
+		IDs: []uint{existing.ID, provisioned.ID, 9999, created.Results[0].ID},
+	})
+	if deleted.Succeeded != 2 || deleted.Skipped != 1 || deleted.Failed != 1 {
+		t.Errorf("BulkDeleteACL() = %+v, want 2 deleted, 1 skipped, 1 failed", deleted)
+	}
+	if deleted.Results[1].Status != "skipped" {
+		t.Errorf("provisioned rule status = %s, want skipped", deleted.Results[1].Status)
+	}
+	if _, err := handler.db.GetACLRule(provisioned.ID); err != nil {
+		t.Error("provisioned rule should not be deleted")
+	}
+	if _, err := handler.db.GetACLRule(existing.ID); err == nil {
+		t.Error("existing rule should be deleted")
+	}
+}
+
+func TestBulkACL_InvalidRequest(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	for _, body := range []string{`{"rules":[]}`, `not json`} {
+		rec := httptest.NewRecorder()
+		handler.BulkCreateACL(rec, httptest.NewRequest(http.MethodPost, "/api/acl/bulk", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("BulkCreateACL(%s) status = %v, want %v", body, rec.Code, http.StatusBadRequest)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.BulkDeleteACL(rec, httptest.NewRequest(http.MethodPost, "/api/acl/bulk/delete", strings.NewReader(`{"ids":[]}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("BulkDeleteACL() with no IDs status = %v, want %v", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCopyACL(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	source, _ := handler.db.CreateMQTTUser("template-device", "password123", "", nil)
+	target, _ := handler.db.CreateMQTTUser("new-device", "password123", "", nil)
+	for _, rule := range []struct{ topic, permission string }{
+		{"devices/${clientid}/#", "pubsub"},
+		{"alerts/#", "sub"},
+		{"config/#", "sub"},
+	} {
+		if _, err := handler.db.CreateACLRuleFromInput(storage.ACLRuleInput{
+			MQTTUserID: source.ID,
+			Topic:      rule.topic,
+			Permission: rule.permission,
+			Retain:     rule.permission == "pubsub",
+		}); err != nil {
+			t.Fatalf("Failed to create source rule: %v", err)
+		}
+	}
+	provisioned, _ := handler.db.CreateACLRule(source.ID, "provisioned/#", "sub")
+	if err := handler.db.Model(&storage.ACLRule{}).Where("id = ?", provisioned.ID).Update("provisioned_from_config", true).Error; err != nil {
+		t.Fatalf("Failed to mark rule as provisioned: %v", err)
+	}
+	// The target already has one of the source topics (with its own permission) and one of its own
+	if _, err := handler.db.CreateACLRule(target.ID, "alerts/#", "pubsub"); err != nil {
+		t.Fatalf("Failed to create target rule: %v", err)
+	}
+	if _, err := handler.db.CreateACLRule(target.ID, "own/#", "pub"); err != nil {
+		t.Fatalf("Failed to create target rule: %v", err)
+	}
+
+	copyFrom := func(targetID, sourceID uint) *httptest.ResponseRecorder {
+		path := fmt.Sprintf("/api/mqtt/users/%d/acl/copy-from/%d", targetID, sourceID)
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.SetPathValue("id", fmt.Sprint(targetID))
+		req.SetPathValue("sourceId", fmt.Sprint(sourceID))
+		rec := httptest.NewRecorder()
+		handler.CopyACL(rec, req)
+		return rec
+	}
+
+	rec := copyFrom(target.ID, source.ID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("CopyACL() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var response CopyACLResponse
+	_ = json.NewDecoder(rec.Body).Decode(&response)
+	if response.Copied != 2 || response.Skipped != 1 {
+		t.Errorf("CopyACL() = %+v, want 2 copied, 1 skipped", response)
+	}
+
+	rules, _ := handler.db.GetACLRulesByMQTTUserID(target.ID)
+	got := make(map[string]string)
+	for _, rule := range rules {
+		if _, dup := got[rule.Topic]; dup {
+			t.Errorf("duplicate rule for %s", rule.Topic)
+		}
+		got[rule.Topic] = rule.Permission
+	}
+	want := map[string]string{
+		"devices/${clientid}/#": "pubsub",
+		"alerts/#":              "pubsub", // Existing rule kept as it was
+		"config/#":              "sub",
+		"own/#":                 "pub",
+	}
+	if len(got) != len(want) {
+		t.Errorf("target rules = %v, want %v", got, want)
+	}
+	for topic, permission := range want {
+		if got[topic] != permission {
+			t.Errorf("rule %s permission = %q, want %q", topic, got[topic], permission)
+		}
+	}
+	for _, rule := range rules {
+		if wantRetain := rule.Topic == "devices/${clientid}/#"; rule.Retain != wantRetain {
+			t.Errorf("rule %s retain = %v, want %v", rule.Topic, rule.Retain, wantRetain)
+		}
+	}
+
+	// Copying again changes nothing
+	rec = copyFrom(target.ID, source.ID)
+	_ = json.NewDecoder(rec.Body).Decode(&response)
+	if response.Copied != 0 || response.Skipped != 3 {
+		t.Errorf("second CopyACL() = %+v, want 0 copied, 3 skipped", response)
+	}
+
+	if rec := copyFrom(target.ID, 9999); rec.Code != http.StatusNotFound {
+		t.Errorf("CopyACL() from unknown user status = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+	if rec := copyFrom(target.ID, target.ID); rec.Code != http.StatusBadRequest {
+		t.Errorf("CopyACL() onto itself status = %v, want %v", rec.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github/bromq-dev/bromq/internal/duration"
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+// CompactResponse reports the outcome of a maintenance compaction
+type CompactResponse struct {
+	Database         *storage.CompactResult `json:"database"`
+	BadgerFreedBytes int64                  `json:"badger_freed_bytes"`
+	Duration         string                 `json:"duration"`
+}
+
+// ReapClientsResponse reports the outcome of deleting inactive client records
+type ReapClientsResponse struct {
+	Deleted        int64     `json:"deleted"`
+	LastSeenBefore time.Time `json:"last_seen_before"`
+}
+
+// CompactStorage godoc
+// @Summary Compact storage
+// @Description Reclaim space in the database (VACUUM on SQLite, ANALYZE on PostgreSQL) and run BadgerDB value-log GC for script logs, state, and retained messages. Not subject to the API request timeout
+// @Tags Maintenance
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} CompactResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 409 {object} ErrorResponse "Compaction already running"
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/maintenance/compact [post]
+func (h *Handler) CompactStorage(w http.ResponseWriter, r *http.Request) {
+	// VACUUM rewrites the whole database file, so never run two at once
+	if !h.maintenance.TryLock() {
+		http.Error(w, `{"error":"compaction already in progress"}`, http.StatusConflict)
+		return
+	}
+	defer h.maintenance.Unlock()
+
+	// Compacting a large store outlasts the server's write timeout
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	start := time.Now()
+
+	dbResult, err := h.db.Compact()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to compact database: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	var badgerFreed int64
+	if h.badger != nil {
+		badgerFreed, err = h.badger.Compact()
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"failed to compact badger store: %s"}`, err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	response := CompactResponse{
+		Database:         dbResult,
+		BadgerFreedBytes: badgerFreed,
+		Duration:         time.Since(start).Round(time.Millisecond).String(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// RepairProvisionedFlags godoc
+// @Summary Repair provisioned flags
+// @Description Reconcile the provisioned_from_config flag of MQTT users, ACL rules, bridges, and scripts with the loaded config file: resources it defines are flagged and all others are cleared (everything is cleared when no config file is loaded)
+// @Tags Maintenance
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} storage.RepairReport "Number of rows corrected per resource type"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/repair [post]
+func (h *Handler) RepairProvisionedFlags(w http.ResponseWriter, r *http.Request) {
+	report, err := h.db.RepairProvisionedFlags(h.provisioned)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to repair provisioned flags: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// ReapInactiveClients godoc
+// @Summary Delete inactive clients
+// @Description Delete the records of clients that disconnected and have not been seen for longer than olderThan. Connected clients are never deleted
+// @Tags Maintenance
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param olderThan query string true "Minimum time since the client was last seen, e.g. 30d or 12h"
+// @Success 200 {object} ReapClientsResponse
+// @Failure 400 {object} ErrorResponse "Missing or invalid olderThan"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/clients/reap [post]
+func (h *Handler) ReapInactiveClients(w http.ResponseWriter, r *http.Request) {
+	olderThan, err := duration.Parse(r.URL.Query().Get("olderThan"))
+	if err != nil || olderThan <= 0 {
+		http.Error(w, `{"error":"olderThan must be a positive duration, e.g. 30d or 12h"}`, http.StatusBadRequest)
+		return
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	deleted, err := h.db.DeleteInactiveMQTTClients(cutoff)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ReapClientsResponse{Deleted: deleted, LastSeenBefore: cutoff})
+}
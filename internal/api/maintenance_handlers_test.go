@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+func TestCompactStorage(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	// Leave some free pages behind for VACUUM to reclaim
+	for i := 0; i < 20; i++ {
+		user, err := handler.db.CreateMQTTUser(fmt.Sprintf("compact-user-%d", i), "password123", "", nil)
+		if err != nil {
+			t.Fatalf("CreateMQTTUser() error = %v", err)
+		}
+		if err := handler.db.DeleteMQTTUser(user.ID); err != nil {
+			t.Fatalf("DeleteMQTTUser() error = %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/maintenance/compact", nil)
+	rec := httptest.NewRecorder()
+
+	handler.CompactStorage(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("CompactStorage() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var response CompactResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Database == nil || response.Database.Operation != "vacuum" {
+		t.Errorf("CompactStorage() database = %+v, want vacuum", response.Database)
+	}
+	if response.Database != nil && response.Database.FreedBytes == nil {
+		t.Error("CompactStorage() freed_bytes missing for sqlite")
+	}
+}
+
+func TestCompactStorage_AlreadyRunning(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	handler.maintenance.Lock()
+	defer handler.maintenance.Unlock()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/maintenance/compact", nil)
+	rec := httptest.NewRecorder()
+
+	handler.CompactStorage(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Errorf("CompactStorage() status = %v, want %v", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestReapInactiveClients(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	user, err := handler.db.CreateMQTTUser("reap-user", "password123", "", nil)
+	if err != nil {
+		t.Fatalf("CreateMQTTUser() error = %v", err)
+	}
+	old := time.Now().Add(-45 * 24 * time.Hour)
+	err = handler.db.ApplyMQTTClientUpdates([]storage.ClientUpdate{
+		{ClientID: "gone", MQTTUserID: user.ID, Connected: true, FirstSeen: old, LastSeen: old},
+		{ClientID: "online", MQTTUserID: user.ID, Connected: true, Active: true, FirstSeen: old, LastSeen: old},
+	})
+	if err != nil {
+		t.Fatalf("ApplyMQTTClientUpdates() error = %v", err)
+	}
+
+	reap := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/clients/reap"+query, nil)
+		rec := httptest.NewRecorder()
+		handler.ReapInactiveClients(rec, req)
+		return rec
+	}
+
+	for _, query := range []string{"", "?olderThan=soon", "?olderThan=0s"} {
+		if rec := reap(query); rec.Code != http.StatusBadRequest {
+			t.Errorf("ReapInactiveClients(%q) status = %v, want %v", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+
+	rec := reap("?olderThan=30d")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ReapInactiveClients() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var response ReapClientsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Deleted != 1 {
+		t.Errorf("deleted = %d, want 1 (the connected client is kept)", response.Deleted)
+	}
+	if _, err := handler.db.GetMQTTClientByClientID("online"); err != nil {
+		t.Errorf("connected client was deleted: %v", err)
+	}
+}
+
+func TestRepairProvisionedFlags(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	user, err := handler.db.CreateMQTTUser("sensor", "password123", "", nil)
+	if err != nil {
+		t.Fatalf("CreateMQTTUser() error = %v", err)
+	}
+	handler.provisioned = storage.ProvisionedSet{Users: []string{"sensor"}}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/repair", nil)
+	rec := httptest.NewRecorder()
+
+	handler.RepairProvisionedFlags(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("RepairProvisionedFlags() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var report storage.RepairReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if report.Users != 1 {
+		t.Errorf("RepairProvisionedFlags() users = %d, want 1", report.Users)
+	}
+	if got, _ := handler.db.GetMQTTUser(user.ID); !got.ProvisionedFromConfig {
+		t.Error("mislabeled user should be flagged as provisioned")
+	}
+}
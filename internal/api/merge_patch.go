@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/json"
+
+	"gorm.io/datatypes"
+)
+
+// mergePatchJSON applies a JSON Merge Patch (RFC 7386) to target and returns
+// the result, or nil when the patch removes the whole document
+func mergePatchJSON(target datatypes.JSON, patch []byte) (datatypes.JSON, error) {
+	var doc interface{}
+	if len(target) > 0 {
+		if err := json.Unmarshal(target, &doc); err != nil {
+			return nil, err
+		}
+	}
+
+	var p interface{}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, err
+	}
+
+	merged := mergePatch(doc, p)
+	if merged == nil {
+		return nil, nil
+	}
+	return json.Marshal(merged)
+}
+
+// mergePatch merges patch into target: objects are merged recursively, null
+// members are removed, and any other patch value replaces the target
+func mergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = make(map[string]interface{})
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergePatch(targetObj[key], value)
+	}
+	return targetObj
+}
@@ -1,11 +1,19 @@
 package api
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -15,7 +23,8 @@ import (
 type contextKey string
 
 const (
-	userContextKey contextKey = "user"
+	userContextKey   contextKey = "user"
+	loggerContextKey contextKey = "logger"
 )
 
 // JWTClaims represents the JWT token claims
@@ -23,17 +32,44 @@ type JWTClaims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
 	Role     string `json:"role"`
+	Scope    string `json:"scope,omitempty"` // StreamTokenScope for stream tokens, empty for full API tokens
 	jwt.RegisteredClaims
 }
 
+// StreamTokenScope marks short-lived tokens that only open live event streams
+const StreamTokenScope = "stream"
+
+// StreamTokenTTL is how long a stream token can be used to open a stream
+// Streams already open stay open after it expires
+const StreamTokenTTL = time.Minute
+
 // GenerateJWT generates a new JWT token for a user
+// Each token gets a random ID (jti) so it can be revoked on logout
 func GenerateJWT(secret []byte, userID uint, username, role string) (string, error) {
+	return generateJWT(secret, userID, username, role, "", time.Now().Add(24*time.Hour))
+}
+
+// GenerateStreamToken generates a token that expires at expiresAt and is only
+// accepted by the live event streams, so it is safe to put in a URL
+func GenerateStreamToken(secret []byte, userID uint, username, role string, expiresAt time.Time) (string, error) {
+	return generateJWT(secret, userID, username, role, StreamTokenScope, expiresAt)
+}
+
+// generateJWT signs a token with a random ID (jti) for the given scope
+func generateJWT(secret []byte, userID uint, username, role, scope string, expiresAt time.Time) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %w", err)
+	}
+
 	claims := JWTClaims{
 		UserID:   userID,
 		Username: username,
 		Role:     role,
+		Scope:    scope,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ID:        hex.EncodeToString(id),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -63,7 +99,9 @@ func ValidateJWT(secret []byte, tokenString string) (*JWTClaims, error) {
 }
 
 // NewAuthMiddleware creates a new authentication middleware with the given config
-func NewAuthMiddleware(config *Config) func(http.Handler) http.Handler {
+// Tokens in revoked (logged out) are rejected; revoked may be nil
+// Stream tokens are rejected, so a token leaked from a stream URL can't call the API
+func NewAuthMiddleware(config *Config, revoked *RevokedTokens) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extract token from Authorization header
@@ -80,20 +118,69 @@ func NewAuthMiddleware(config *Config) func(http.Handler) http.Handler {
 				return
 			}
 
-			// Validate token
-			claims, err := ValidateJWT(config.JWTSecretBytes(), parts[1])
-			if err != nil {
-				http.Error(w, fmt.Sprintf(`{"error":"invalid token: %s"}`, err), http.StatusUnauthorized)
+			claims, ok := authenticate(w, config, revoked, parts[1])
+			if !ok {
+				return
+			}
+			if claims.Scope != "" {
+				http.Error(w, `{"error":"invalid token: stream tokens only open event streams"}`, http.StatusUnauthorized)
 				return
 			}
 
-			// Add claims to context
-			ctx := context.WithValue(r.Context(), userContextKey, claims)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			next.ServeHTTP(w, withUser(r, claims))
 		})
 	}
 }
 
+// NewStreamAuthMiddleware authenticates the live event streams. Browsers can't
+// set headers on EventSource or WebSocket requests, so besides the Authorization
+// header it accepts a stream token (see GenerateStreamToken) in the
+// access_token query parameter
+func NewStreamAuthMiddleware(config *Config, revoked *RevokedTokens) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		headerAuth := NewAuthMiddleware(config, revoked)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.URL.Query().Get("access_token")
+			if token == "" {
+				headerAuth.ServeHTTP(w, r)
+				return
+			}
+
+			claims, ok := authenticate(w, config, revoked, token)
+			if !ok {
+				return
+			}
+			// Full API tokens are long-lived, so they must not end up in URLs and logs
+			if claims.Scope != StreamTokenScope {
+				http.Error(w, `{"error":"invalid token: access_token must be a stream token"}`, http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, withUser(r, claims))
+		})
+	}
+}
+
+// authenticate validates token and writes a 401 if it is invalid or revoked
+func authenticate(w http.ResponseWriter, config *Config, revoked *RevokedTokens, token string) (*JWTClaims, bool) {
+	claims, err := ValidateJWT(config.JWTSecretBytes(), token)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid token: %s"}`, err), http.StatusUnauthorized)
+		return nil, false
+	}
+	if revoked.IsRevoked(claims.ID) {
+		http.Error(w, `{"error":"invalid token: token has been revoked"}`, http.StatusUnauthorized)
+		return nil, false
+	}
+	return claims, true
+}
+
+// withUser adds claims to the request context
+func withUser(r *http.Request, claims *JWTClaims) *http.Request {
+	setRequestUser(r.Context(), claims.Username)
+	return r.WithContext(context.WithValue(r.Context(), userContextKey, claims))
+}
+
 // GetUserFromContext extracts JWT claims from request context
 func GetUserFromContext(r *http.Request) (*JWTClaims, bool) {
 	claims, ok := r.Context().Value(userContextKey).(*JWTClaims)
@@ -104,8 +191,9 @@ func GetUserFromContext(r *http.Request) (*JWTClaims, bool) {
 func CORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
 
 		// Handle preflight requests
 		if r.Method == http.MethodOptions {
@@ -117,25 +205,147 @@ func CORSMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// LoggingMiddleware logs HTTP requests
+// TimeoutMiddleware aborts requests that run longer than timeout with 503 Service Unavailable
+// A timeout of 0 disables the limit
+func TimeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if timeout <= 0 {
+			return next
+		}
+		return http.TimeoutHandler(next, timeout, `{"error":"request timed out"}`)
+	}
+}
+
+// NDJSONBypassMiddleware sends GET requests for newline-delimited JSON to the
+// streaming routes registered on stream straight to it, skipping the wrapped
+// middleware. http.TimeoutHandler buffers the whole response, which would hold a
+// streamed export in memory and cut it off. Other routes keep their limits
+func NDJSONBypassMiddleware(stream *http.ServeMux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet && wantsNDJSON(r) {
+				if _, pattern := stream.Handler(r); pattern != "" {
+					stream.ServeHTTP(w, r)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// BodyLimitMiddleware rejects mutating requests whose body exceeds maxBytes with 413 Request Entity Too Large
+// The body is buffered up front so handlers never see a truncated payload. A limit of 0 disables the check
+func BodyLimitMiddleware(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if maxBytes <= 0 || r.Body == nil || !isMutatingMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if r.ContentLength > maxBytes {
+				http.Error(w, `{"error":"request body too large"}`, http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					http.Error(w, `{"error":"request body too large"}`, http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, fmt.Sprintf(`{"error":"failed to read request body: %s"}`, err), http.StatusBadRequest)
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isMutatingMethod reports whether the HTTP method carries a request body that changes state
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// requestLogger is the per-request logger stored in the context
+// The auth middleware runs inside the logging middleware, so the username is
+// filled in later; TimeoutMiddleware may still be running the handler when the
+// completion line is written, hence the mutex
+type requestLogger struct {
+	mu       sync.Mutex
+	logger   *slog.Logger
+	username string
+}
+
+// LoggingMiddleware attaches a request-scoped logger (request ID, method, path) to the
+// context, echoes the request ID in X-Request-ID, and logs one line when the request completes
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
+		requestID := newRequestID()
+		w.Header().Set("X-Request-ID", requestID)
+
+		rl := &requestLogger{
+			logger: slog.With("request_id", requestID, "method", r.Method, "path", r.URL.Path),
+		}
+		ctx := context.WithValue(r.Context(), loggerContextKey, rl)
+
 		// Wrap response writer to capture status code
 		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 
-		next.ServeHTTP(rw, r)
+		next.ServeHTTP(rw, r.WithContext(ctx))
 
-		slog.Info("HTTP request",
-			"method", r.Method,
-			"uri", r.RequestURI,
+		LoggerFromContext(ctx).Info("HTTP request",
 			"status", rw.statusCode,
 			"duration", time.Since(start),
 		)
 	})
 }
 
+// LoggerFromContext returns the request-scoped logger, or the default logger
+// outside of LoggingMiddleware
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	rl, ok := ctx.Value(loggerContextKey).(*requestLogger)
+	if !ok {
+		return slog.Default()
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.logger
+}
+
+// setRequestUser adds the authenticated username to the request logger
+func setRequestUser(ctx context.Context, username string) {
+	rl, ok := ctx.Value(loggerContextKey).(*requestLogger)
+	if !ok {
+		return
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.username == "" {
+		rl.username = username
+		rl.logger = rl.logger.With("username", username)
+	}
+}
+
+// newRequestID returns a random 16-character hex request ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 // responseWriter wraps http.ResponseWriter to capture status code
 type responseWriter struct {
 	http.ResponseWriter
@@ -147,6 +357,20 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Hijack lets WebSocket upgrades take over the connection
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err == nil {
+		rw.statusCode = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController (flushing, deadlines)
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // AdminOnly middleware restricts access to admin users only
 func AdminOnly(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -164,4 +388,3 @@ func AdminOnly(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
-
@@ -1,10 +1,15 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -71,6 +76,9 @@ func TestGenerateJWT(t *testing.T) {
 			if claims.Role != tt.role {
 				t.Errorf("ValidateJWT() role = %v, want %v", claims.Role, tt.role)
 			}
+			if claims.ID == "" {
+				t.Error("GenerateJWT() token has no jti to revoke it by")
+			}
 		})
 	}
 }
@@ -161,6 +169,11 @@ func TestAuthMiddleware(t *testing.T) {
 		t.Fatalf("Failed to generate test token: %v", err)
 	}
 
+	streamToken, err := GenerateStreamToken(testJWTSecret, 1, "testuser", "user", time.Now().Add(StreamTokenTTL))
+	if err != nil {
+		t.Fatalf("Failed to generate stream token: %v", err)
+	}
+
 	// Handler that should only be called if auth succeeds
 	protectedHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		claims, ok := GetUserFromContext(r)
@@ -209,6 +222,11 @@ func TestAuthMiddleware(t *testing.T) {
 			authHeader:     "Bearer ",
 			wantStatusCode: http.StatusUnauthorized,
 		},
+		{
+			name:           "stream token",
+			authHeader:     fmt.Sprintf("Bearer %s", streamToken),
+			wantStatusCode: http.StatusUnauthorized,
+		},
 	}
 
 	for _, tt := range tests {
@@ -219,7 +237,7 @@ func TestAuthMiddleware(t *testing.T) {
 			}
 
 			rec := httptest.NewRecorder()
-			handler := NewAuthMiddleware(testConfig)(protectedHandler)
+			handler := NewAuthMiddleware(testConfig, nil)(protectedHandler)
 			handler.ServeHTTP(rec, req)
 
 			if rec.Code != tt.wantStatusCode {
@@ -229,6 +247,78 @@ func TestAuthMiddleware(t *testing.T) {
 	}
 }
 
+func TestStreamAuthMiddleware(t *testing.T) {
+	testConfig := &Config{
+		JWTSecret: string(testJWTSecret),
+	}
+
+	apiToken, err := GenerateJWT(testJWTSecret, 1, "testuser", "user")
+	if err != nil {
+		t.Fatalf("Failed to generate test token: %v", err)
+	}
+	streamToken, err := GenerateStreamToken(testJWTSecret, 1, "testuser", "user", time.Now().Add(StreamTokenTTL))
+	if err != nil {
+		t.Fatalf("Failed to generate stream token: %v", err)
+	}
+	expiredToken, err := GenerateStreamToken(testJWTSecret, 1, "testuser", "user", time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatalf("Failed to generate stream token: %v", err)
+	}
+
+	revoked := NewRevokedTokens()
+	revokedToken, err := GenerateStreamToken(testJWTSecret, 1, "testuser", "user", time.Now().Add(StreamTokenTTL))
+	if err != nil {
+		t.Fatalf("Failed to generate stream token: %v", err)
+	}
+	revokedClaims, err := ValidateJWT(testJWTSecret, revokedToken)
+	if err != nil {
+		t.Fatalf("ValidateJWT() error = %v", err)
+	}
+	revoked.Revoke(revokedClaims.ID, revokedClaims.ExpiresAt.Time)
+
+	streamHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if claims, ok := GetUserFromContext(r); !ok || claims.Username != "testuser" {
+			t.Errorf("StreamAuthMiddleware() claims = %v, want testuser", claims)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		authHeader     string
+		accessToken    string
+		wantStatusCode int
+	}{
+		{"api token in header", "Bearer " + apiToken, "", http.StatusOK},
+		{"stream token in header", "Bearer " + streamToken, "", http.StatusUnauthorized},
+		{"stream token in query", "", streamToken, http.StatusOK},
+		{"api token in query", "", apiToken, http.StatusUnauthorized},
+		{"expired stream token", "", expiredToken, http.StatusUnauthorized},
+		{"revoked stream token", "", revokedToken, http.StatusUnauthorized},
+		{"no token", "", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/api/ws"
+			if tt.accessToken != "" {
+				target += "?access_token=" + tt.accessToken
+			}
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+
+			rec := httptest.NewRecorder()
+			NewStreamAuthMiddleware(testConfig, revoked)(streamHandler).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatusCode {
+				t.Errorf("StreamAuthMiddleware() status = %v, want %v (body %s)", rec.Code, tt.wantStatusCode, rec.Body.String())
+			}
+		})
+	}
+}
+
 func TestGetUserFromContext(t *testing.T) {
 	// Generate a valid token and create a request with claims in context
 	validToken, err := GenerateJWT(testJWTSecret, 1, "testuser", "user")
@@ -430,3 +520,207 @@ func TestResponseWriter(t *testing.T) {
 		}
 	})
 }
+
+func TestLoggingMiddleware(t *testing.T) {
+	// Capture log output as JSON lines
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	defer slog.SetDefault(previous)
+
+	token, err := GenerateJWT(testJWTSecret, 1, "testuser", "user")
+	if err != nil {
+		t.Fatalf("Failed to generate test token: %v", err)
+	}
+
+	// The handler logs through the request-scoped logger
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		LoggerFromContext(r.Context()).Info("handler ran")
+		w.WriteHeader(http.StatusCreated)
+	})
+	auth := NewAuthMiddleware(&Config{JWTSecret: string(testJWTSecret)}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/things?x=1", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+
+	LoggingMiddleware(auth(handler)).ServeHTTP(rec, req)
+
+	requestID := rec.Header().Get("X-Request-ID")
+	if requestID == "" {
+		t.Fatal("LoggingMiddleware() X-Request-ID header missing")
+	}
+
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		lines = append(lines, entry)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("LoggingMiddleware() logged %d lines, want 2: %s", len(lines), buf.String())
+	}
+
+	for _, entry := range lines {
+		if entry["request_id"] != requestID {
+			t.Errorf("log %q request_id = %v, want %v", entry["msg"], entry["request_id"], requestID)
+		}
+		if entry["username"] != "testuser" {
+			t.Errorf("log %q username = %v, want testuser", entry["msg"], entry["username"])
+		}
+		if entry["method"] != http.MethodPost || entry["path"] != "/things" {
+			t.Errorf("log %q method/path = %v %v, want POST /things", entry["msg"], entry["method"], entry["path"])
+		}
+	}
+
+	completion := lines[1]
+	if completion["msg"] != "HTTP request" {
+		t.Errorf("completion line msg = %v, want HTTP request", completion["msg"])
+	}
+	if completion["status"] != float64(http.StatusCreated) {
+		t.Errorf("completion line status = %v, want %d", completion["status"], http.StatusCreated)
+	}
+}
+
+func TestLoggerFromContext_Default(t *testing.T) {
+	if LoggerFromContext(context.Background()) != slog.Default() {
+		t.Error("LoggerFromContext() outside a request should return slog.Default()")
+	}
+}
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	const limit = 64
+
+	// Echo handler decodes JSON to confirm the body reaches handlers intact
+	handler := BodyLimitMiddleware(limit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+			http.Error(w, `{"error":"invalid request"}`, http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	oversized := `{"username":"` + strings.Repeat("a", limit) + `"}`
+
+	tests := []struct {
+		name           string
+		method         string
+		body           string
+		chunked        bool
+		wantStatusCode int
+	}{
+		{
+			name:           "small JSON body allowed",
+			method:         http.MethodPost,
+			body:           `{"username":"test"}`,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "oversized JSON body rejected",
+			method:         http.MethodPost,
+			body:           oversized,
+			wantStatusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "oversized JSON body without content length rejected",
+			method:         http.MethodPut,
+			body:           oversized,
+			chunked:        true,
+			wantStatusCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "GET requests not limited",
+			method:         http.MethodGet,
+			body:           oversized,
+			wantStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/mqtt/users", strings.NewReader(tt.body))
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatusCode {
+				t.Errorf("BodyLimitMiddleware() status = %v, want %v", rec.Code, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("slow request times out", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/scripts", nil)
+
+		TimeoutMiddleware(20*time.Millisecond)(slow).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("TimeoutMiddleware() status = %v, want %v", rec.Code, http.StatusServiceUnavailable)
+		}
+	})
+
+	t.Run("zero timeout disables limit", func(t *testing.T) {
+		fast := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/scripts", nil)
+
+		TimeoutMiddleware(0)(fast).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("TimeoutMiddleware() status = %v, want %v", rec.Code, http.StatusOK)
+		}
+	})
+}
+
+func TestNDJSONBypassMiddleware(t *testing.T) {
+	named := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, name)
+		})
+	}
+	stream := http.NewServeMux()
+	stream.Handle("GET /mqtt/clients", named("stream"))
+	handler := NDJSONBypassMiddleware(stream)(named("buffered"))
+
+	tests := []struct {
+		method string
+		path   string
+		accept string
+		want   string
+	}{
+		{http.MethodGet, "/mqtt/clients", "application/x-ndjson", "stream"},
+		{http.MethodGet, "/mqtt/clients", "application/json, application/x-ndjson;q=0.9", "stream"},
+		{http.MethodGet, "/mqtt/clients", "application/json", "buffered"},
+		{http.MethodGet, "/mqtt/clients", "", "buffered"},
+		{http.MethodPost, "/mqtt/clients", "application/x-ndjson", "buffered"},
+		{http.MethodGet, "/acl", "application/x-ndjson", "buffered"}, // Not a streaming route
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("Accept", tt.accept)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if got := rec.Body.String(); got != tt.want {
+			t.Errorf("%s %s with Accept %q served by %s, want %s", tt.method, tt.path, tt.accept, got, tt.want)
+		}
+	}
+}
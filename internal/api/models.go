@@ -1,6 +1,11 @@
 package api
 
 import (
+	"time"
+
+	"github/bromq-dev/bromq/hooks/auth"
+	"github/bromq-dev/bromq/internal/mqtt"
+	"github/bromq-dev/bromq/internal/script"
 	"github/bromq-dev/bromq/internal/storage"
 
 	"gorm.io/datatypes"
@@ -18,6 +23,12 @@ type LoginResponse struct {
 	User  *storage.DashboardUser `json:"user"`
 }
 
+// StreamTokenResponse represents a short-lived token for opening live event streams
+type StreamTokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
 // === Admin User Requests ===
 
 // CreateDashboardUserRequest represents a request to create a new admin user
@@ -54,13 +65,33 @@ type CreateMQTTUserRequest struct {
 	Password    string         `json:"password"`
 	Description string         `json:"description"`
 	Metadata    datatypes.JSON `json:"metadata,omitempty"`
+
+	// Source networks (CIDR or single IP); deny entries take precedence
+	AllowedCIDRs []string `json:"allowed_cidrs,omitempty"`
+	DeniedCIDRs  []string `json:"denied_cidrs,omitempty"`
+
+	// ApplyDefaultACL creates the configured default_acl rules for the new user
+	ApplyDefaultACL bool `json:"applyDefaultAcl,omitempty"`
 }
 
 // UpdateMQTTUserRequest represents a request to update MQTT credentials
 type UpdateMQTTUserRequest struct {
-	Username    string         `json:"username"`
-	Description string         `json:"description"`
-	Metadata    datatypes.JSON `json:"metadata,omitempty"`
+	Username     string         `json:"username"`
+	Description  string         `json:"description"`
+	Metadata     datatypes.JSON `json:"metadata,omitempty"`
+	AllowedCIDRs *[]string      `json:"allowed_cidrs,omitempty"` // Omitted = unchanged, [] = any address
+	DeniedCIDRs  *[]string      `json:"denied_cidrs,omitempty"`
+}
+
+// PatchMQTTUserRequest is a JSON Merge Patch (RFC 7386) for an MQTT user
+// Omitted fields are left unchanged. Metadata is merged key by key, where a
+// null value removes the key; "metadata": null clears it entirely
+type PatchMQTTUserRequest struct {
+	Username     *string        `json:"username,omitempty"`
+	Description  *string        `json:"description,omitempty"`
+	Metadata     datatypes.JSON `json:"metadata,omitempty"`      // Not a pointer, so null arrives as "null" rather than as omitted
+	AllowedCIDRs *[]string      `json:"allowed_cidrs,omitempty"` // Replaced as a whole; [] allows any address
+	DeniedCIDRs  *[]string      `json:"denied_cidrs,omitempty"`
 }
 
 // UpdateMQTTPasswordRequest represents a request to update MQTT credentials password
@@ -75,17 +106,77 @@ type UpdateMQTTClientMetadataRequest struct {
 	Metadata datatypes.JSON `json:"metadata"`
 }
 
+// MQTTClientDetailsResponse is a client record with live queue state from the broker
+// Queue fields are omitted when the broker holds no session for the client
+type MQTTClientDetailsResponse struct {
+	storage.MQTTClient
+	QueueDepth *int `json:"queue_depth,omitempty"` // QoS 1/2 messages awaiting delivery or acknowledgement
+	QueueLimit *int `json:"queue_limit,omitempty"` // Per-client maximum (MQTT_MAX_INFLIGHT)
+}
+
+// MQTTSessionResponse is the session the broker holds for a client
+type MQTTSessionResponse struct {
+	mqtt.SessionInfo
+	Persisted bool `json:"persisted"` // Stored to survive a restart (MQTT_DURABLE_SESSIONS)
+}
+
 // CreateACLRequest represents a request to create an ACL rule
 type CreateACLRequest struct {
 	MQTTUserID uint   `json:"mqtt_user_id"`
 	Topic      string `json:"topic"`
 	Permission string `json:"permission"`
+	Retain     bool   `json:"retain,omitempty"` // Allow publishing retained messages
+}
+
+// ACLRuleDetailsResponse is an ACL rule with the MQTT user it applies to
+type ACLRuleDetailsResponse struct {
+	storage.ACLRule
+	MQTTUser *storage.MQTTUser `json:"mqtt_user"`
+}
+
+// ExpandACLPatternResponse is an ACL topic pattern resolved for one client
+type ExpandACLPatternResponse struct {
+	Pattern  string `json:"pattern"`
+	Username string `json:"username"`
+	ClientID string `json:"client_id"`
+	Expanded string `json:"expanded"`
 }
 
 // UpdateACLRequest represents a request to update an ACL rule
 type UpdateACLRequest struct {
 	Topic      string `json:"topic"`
 	Permission string `json:"permission"`
+	Retain     *bool  `json:"retain,omitempty"` // Omitted = unchanged
+}
+
+// BulkCreateACLRequest creates many ACL rules at once
+type BulkCreateACLRequest struct {
+	Rules []CreateACLRequest `json:"rules"`
+}
+
+// BulkDeleteACLRequest deletes many ACL rules at once
+type BulkDeleteACLRequest struct {
+	IDs []uint `json:"ids"`
+}
+
+// BulkACLResponse reports the outcome of each item of a bulk ACL request
+type BulkACLResponse struct {
+	Results   []storage.BulkACLResult `json:"results"`
+	Succeeded int                     `json:"succeeded"`
+	Skipped   int                     `json:"skipped"`
+	Failed    int                     `json:"failed"`
+}
+
+// CopyACLResponse reports the result of copying ACL rules between users
+type CopyACLResponse struct {
+	Copied  int `json:"copied"`
+	Skipped int `json:"skipped"` // Topics the target already had a rule for
+}
+
+// UnmatchedACLResponse lists denied attempts that no ACL rule matched
+type UnmatchedACLResponse struct {
+	Enabled bool                               `json:"enabled"` // False when MQTT_ACL_AUDIT_UNMATCHED is off
+	Users   map[string][]auth.UnmatchedAttempt `json:"users"`
 }
 
 // === Bridge Requests ===
@@ -110,6 +201,8 @@ type CreateBridgeRequest struct {
 	CleanSession      bool                   `json:"clean_session"`
 	KeepAlive         int                    `json:"keep_alive"`
 	ConnectionTimeout int                    `json:"connection_timeout"`
+	MaxQoS            *byte                  `json:"max_qos,omitempty"`       // Caps forwarded QoS (omit for no cap)
+	RetainPolicy      string                 `json:"retain_policy,omitempty"` // "preserve" (default) or "clear"
 	Metadata          map[string]interface{} `json:"metadata,omitempty"`
 	Topics            []BridgeTopicRequest   `json:"topics"`
 }
@@ -126,10 +219,32 @@ type UpdateBridgeRequest struct {
 	CleanSession      bool                   `json:"clean_session"`
 	KeepAlive         int                    `json:"keep_alive"`
 	ConnectionTimeout int                    `json:"connection_timeout"`
+	MaxQoS            *byte                  `json:"max_qos,omitempty"`       // Caps forwarded QoS (omit for no cap)
+	RetainPolicy      string                 `json:"retain_policy,omitempty"` // "preserve" (default) or "clear"
 	Metadata          map[string]interface{} `json:"metadata,omitempty"`
 	Topics            []BridgeTopicRequest   `json:"topics"`
 }
 
+// TopicRouteRequest creates or replaces a topic route
+type TopicRouteRequest struct {
+	Source  string `json:"source"`            // Topic filter, e.g. legacy/#
+	Target  string `json:"target"`            // e.g. v2/#
+	Enabled *bool  `json:"enabled,omitempty"` // Defaults to true
+}
+
+// CloneRequest names the copy made by a clone endpoint
+type CloneRequest struct {
+	Name     string `json:"name"`
+	ClientID string `json:"client_id,omitempty"` // Bridges only: client ID for the copy (the source's is never reused)
+}
+
+// BridgeTestResponse reports the outcome of a bridge connection test
+type BridgeTestResponse struct {
+	Success    bool   `json:"success"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
 // PaginationQuery represents pagination query parameters
 type PaginationQuery struct {
 	Page      int    `json:"page"`
@@ -200,3 +315,60 @@ type TestScriptRequest struct {
 	Type      string                 `json:"type"`
 	EventData map[string]interface{} `json:"event_data"` // Mock message data (kept as event_data for backward compatibility)
 }
+
+// ValidateScriptRequest represents a request to syntax-check a script
+type ValidateScriptRequest struct {
+	Content string `json:"content"`
+}
+
+// ValidateScriptResponse lists a script's syntax errors (empty when valid)
+type ValidateScriptResponse struct {
+	Valid  bool                 `json:"valid"`
+	Errors []script.SyntaxError `json:"errors"`
+}
+
+// ScriptKillSwitchResponse reports the global script kill switch state
+type ScriptKillSwitchResponse struct {
+	ScriptsDisabled bool `json:"scripts_disabled"`
+	Persisted       bool `json:"persisted"` // False if saving failed: the switch is in effect but resets on restart
+}
+
+// MetricsResponse is the broker metrics plus script engine state
+type MetricsResponse struct {
+	mqtt.Metrics
+	ScriptsDisabled bool  `json:"scripts_disabled"`
+	RetainedBytes   int64 `json:"retained_bytes"` // Payload bytes of the stored retained messages
+}
+
+// SummaryResponse counts everything the dashboard overview shows, in one call
+type SummaryResponse struct {
+	storage.ResourceCounts
+	BridgesConnected int   `json:"bridges_connected"` // 0 when the bridge manager isn't running
+	RetainedMessages int64 `json:"retained_messages"`
+}
+
+// === Topic Responses ===
+
+// TopicNode represents one level of the topic hierarchy
+type TopicNode struct {
+	Count    int64                 `json:"count"`              // Messages at this level and below
+	Retained bool                  `json:"retained,omitempty"` // A retained message exists for this exact topic
+	Children map[string]*TopicNode `json:"children,omitempty"` // Keyed by the next topic level
+}
+
+// === WebSocket Messages ===
+
+// WSRequest is a message sent by a WebSocket client
+type WSRequest struct {
+	Action   string `json:"action"`   // "subscribe" or "unsubscribe"
+	Resource string `json:"resource"` // e.g. "clients"
+}
+
+// WSMessage is a message pushed to WebSocket clients
+type WSMessage struct {
+	Type     string      `json:"type"`               // "delta", "subscribed", "unsubscribed", "error"
+	Resource string      `json:"resource,omitempty"` // Resource the message refers to
+	Op       string      `json:"op,omitempty"`       // Delta operation: "add", "update", "remove"
+	Data     interface{} `json:"data,omitempty"`     // Deltas: the full record as it is after the add/update, or was before the remove
+	Error    string      `json:"error,omitempty"`
+}
@@ -2,19 +2,23 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
 	"strconv"
 
+	"github/bromq-dev/bromq/hooks/auth"
 	"github/bromq-dev/bromq/internal/storage"
 )
 
 // parsePaginationParams parses pagination parameters from request
-func parsePaginationParams(r *http.Request) PaginationQuery {
+// Page sizes above the configured maximum are clamped to it
+func (h *Handler) parsePaginationParams(r *http.Request) PaginationQuery {
+	defaultSize, maxSize := h.config.PageSizes()
 	query := PaginationQuery{
 		Page:      1,
-		PageSize:  25, // Default page size
+		PageSize:  defaultSize,
 		Search:    "",
 		SortBy:    "",
 		SortOrder: "desc",
@@ -27,8 +31,8 @@ func parsePaginationParams(r *http.Request) PaginationQuery {
 	}
 
 	if pageSize := r.URL.Query().Get("pageSize"); pageSize != "" {
-		if ps, err := strconv.Atoi(pageSize); err == nil && ps > 0 && ps <= 100 {
-			query.PageSize = ps
+		if ps, err := strconv.Atoi(pageSize); err == nil && ps > 0 {
+			query.PageSize = min(ps, maxSize)
 		}
 	}
 
@@ -61,7 +65,7 @@ func parsePaginationParams(r *http.Request) PaginationQuery {
 // @Failure 500 {object} ErrorResponse
 // @Router /mqtt/users [get]
 func (h *Handler) ListMQTTUsers(w http.ResponseWriter, r *http.Request) {
-	params := parsePaginationParams(r)
+	params := h.parsePaginationParams(r)
 
 	users, total, err := h.db.ListMQTTUsersPaginated(params.Page, params.PageSize, params.Search, params.SortBy, params.SortOrder)
 	if err != nil {
@@ -93,7 +97,7 @@ func (h *Handler) ListMQTTUsers(w http.ResponseWriter, r *http.Request) {
 
 // CreateMQTTUser godoc
 // @Summary Create MQTT user
-// @Description Create new MQTT credentials (can be shared by multiple devices)
+// @Description Create new MQTT credentials (can be shared by multiple devices). Set applyDefaultAcl to also create the config file's default_acl rules for the user
 // @Tags MQTT Users
 // @Accept json
 // @Produce json
@@ -112,13 +116,40 @@ func (h *Handler) CreateMQTTUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.db.CreateMQTTUser(req.Username, req.Password, req.Description, req.Metadata)
+	if req.ApplyDefaultACL && len(h.defaultACL) == 0 {
+		http.Error(w, `{"error":"no default ACL is configured"}`, http.StatusBadRequest)
+		return
+	}
+
+	if _, err := auth.ParseIPFilter(req.AllowedCIDRs, req.DeniedCIDRs); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	input := storage.NewMQTTUser{
+		Username:     req.Username,
+		Password:     req.Password,
+		Description:  req.Description,
+		Metadata:     req.Metadata,
+		AllowedCIDRs: req.AllowedCIDRs,
+		DeniedCIDRs:  req.DeniedCIDRs,
+	}
+	if req.ApplyDefaultACL {
+		// Placeholders such as ${clientid} are stored verbatim and resolved per connection
+		for _, rule := range h.defaultACL {
+			input.ACLRules = append(input.ACLRules, storage.ACLRuleInput{Topic: rule.Topic, Permission: rule.Permission, Retain: rule.Retain})
+		}
+	}
+
+	// The user, its IP ranges and its default ACL are created together or not at all
+	user, err := h.db.CreateMQTTUserWithRules(input)
 	if err != nil {
 		http.Error(w, fmt.Sprintf(`{"error":"failed to create MQTT user: %s"}`, err), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(user.Version))
 	w.WriteHeader(http.StatusCreated)
 	_ = json.NewEncoder(w).Encode(user)
 }
@@ -152,6 +183,7 @@ func (h *Handler) GetMQTTUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(user.Version))
 	_ = json.NewEncoder(w).Encode(user)
 }
 
@@ -164,12 +196,14 @@ func (h *Handler) GetMQTTUser(w http.ResponseWriter, r *http.Request) {
 // @Security BearerAuth
 // @Param id path int true "MQTT User ID"
 // @Param user body UpdateMQTTUserRequest true "Updated MQTT user details"
+// @Param If-Match header string false "ETag from a previous read; the update fails with 412 if the user has changed since"
 // @Success 200 {object} storage.MQTTUser
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 403 {object} ErrorResponse "Admin only"
 // @Failure 404 {object} ErrorResponse
 // @Failure 409 {object} ErrorResponse "Provisioned resource cannot be modified"
+// @Failure 412 {object} ErrorResponse "If-Match version is stale"
 // @Failure 500 {object} ErrorResponse
 // @Router /mqtt/users/{id} [put]
 func (h *Handler) UpdateMQTTUser(w http.ResponseWriter, r *http.Request) {
@@ -182,7 +216,7 @@ func (h *Handler) UpdateMQTTUser(w http.ResponseWriter, r *http.Request) {
 	id := uint(idVal)
 
 	// Check if user is provisioned from config
-	user, err := h.db.GetMQTTUser(id)
+	user, err := h.db.GetMQTTUserForUpdate(id)
 	if err != nil {
 		http.Error(w, fmt.Sprintf(`{"error":"MQTT user not found: %s"}`, err), http.StatusNotFound)
 		return
@@ -193,24 +227,150 @@ func (h *Handler) UpdateMQTTUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err), http.StatusBadRequest)
+		return
+	}
+
 	var req UpdateMQTTUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, fmt.Sprintf(`{"error":"invalid request: %s"}`, err), http.StatusBadRequest)
 		return
 	}
 
-	if err := h.db.UpdateMQTTUser(id, req.Username, req.Description, req.Metadata); err != nil {
+	if err := validateIPRanges(req.AllowedCIDRs, req.DeniedCIDRs); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	patch := storage.MQTTUserPatch{
+		Username:     &req.Username,
+		Description:  &req.Description,
+		AllowedCIDRs: req.AllowedCIDRs,
+		DeniedCIDRs:  req.DeniedCIDRs,
+		IfVersion:    version,
+	}
+	if req.Metadata != nil {
+		patch.Metadata = &req.Metadata // Omitted metadata is left unchanged
+	}
+	if err := h.db.PatchMQTTUser(id, patch); err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			http.Error(w, `{"error":"MQTT user was modified by another request; reload it and try again"}`, http.StatusPreconditionFailed)
+			return
+		}
+		http.Error(w, fmt.Sprintf(`{"error":"failed to update MQTT user: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	// Read back from the primary so the ETag carries the version just written
+	user, err = h.db.GetMQTTUserForUpdate(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to get MQTT user: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(user.Version))
+	_ = json.NewEncoder(w).Encode(user)
+}
+
+// PatchMQTTUser godoc
+// @Summary Partially update MQTT user
+// @Description Update only the provided fields of an MQTT user (JSON Merge Patch). Metadata keys are merged; a null value removes a key
+// @Tags MQTT Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "MQTT User ID"
+// @Param user body PatchMQTTUserRequest true "Fields to change"
+// @Param If-Match header string false "ETag from a previous read; the update fails with 412 if the user has changed since"
+// @Success 200 {object} storage.MQTTUser
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "Provisioned resource cannot be modified"
+// @Failure 412 {object} ErrorResponse "If-Match version is stale"
+// @Failure 500 {object} ErrorResponse
+// @Router /mqtt/users/{id} [patch]
+func (h *Handler) PatchMQTTUser(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	idVal, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid user ID"}`, http.StatusBadRequest)
+		return
+	}
+	id := uint(idVal)
+
+	// The metadata patch is merged onto this read, so it must not come from a lagging replica
+	user, err := h.db.GetMQTTUserForUpdate(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"MQTT user not found: %s"}`, err), http.StatusNotFound)
+		return
+	}
+
+	if user.ProvisionedFromConfig {
+		http.Error(w, `{"error":"Cannot modify provisioned user. This user is managed by the configuration file. Edit the config file and restart the server to make changes."}`, http.StatusConflict)
+		return
+	}
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	var req PatchMQTTUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid request: %s"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	if req.Username != nil && *req.Username == "" {
+		http.Error(w, `{"error":"username cannot be empty"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := validateIPRanges(req.AllowedCIDRs, req.DeniedCIDRs); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	patch := storage.MQTTUserPatch{
+		Username:     req.Username,
+		Description:  req.Description,
+		AllowedCIDRs: req.AllowedCIDRs,
+		DeniedCIDRs:  req.DeniedCIDRs,
+		IfVersion:    version,
+	}
+	if req.Metadata != nil {
+		metadata, err := mergePatchJSON(user.Metadata, req.Metadata)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"invalid metadata: %s"}`, err), http.StatusBadRequest)
+			return
+		}
+		patch.Metadata = &metadata
+	}
+
+	if err := h.db.PatchMQTTUser(id, patch); err != nil {
+		if errors.Is(err, storage.ErrVersionConflict) {
+			http.Error(w, `{"error":"MQTT user was modified by another request; reload it and try again"}`, http.StatusPreconditionFailed)
+			return
+		}
 		http.Error(w, fmt.Sprintf(`{"error":"failed to update MQTT user: %s"}`, err), http.StatusInternalServerError)
 		return
 	}
 
-	user, err = h.db.GetMQTTUser(id)
+	// Read back from the primary so the ETag carries the version just written
+	user, err = h.db.GetMQTTUserForUpdate(id)
 	if err != nil {
 		http.Error(w, fmt.Sprintf(`{"error":"failed to get MQTT user: %s"}`, err), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(user.Version))
 	_ = json.NewEncoder(w).Encode(user)
 }
 
@@ -323,9 +483,10 @@ func (h *Handler) UpdateMQTTUserPassword(w http.ResponseWriter, r *http.Request)
 // ListMQTTClients godoc
 // @Summary List MQTT clients
 // @Description Get paginated list of connected MQTT devices/clients
+// @Description With Accept: application/x-ndjson every matching client is streamed as one JSON object per line, in ID order, ignoring page, pageSize and sorting
 // @Tags MQTT Clients
 // @Accept json
-// @Produce json
+// @Produce json,application/x-ndjson
 // @Security BearerAuth
 // @Param page query int false "Page number" default(1)
 // @Param pageSize query int false "Items per page" default(25)
@@ -339,11 +500,16 @@ func (h *Handler) UpdateMQTTUserPassword(w http.ResponseWriter, r *http.Request)
 // @Router /mqtt/clients [get]
 func (h *Handler) ListMQTTClients(w http.ResponseWriter, r *http.Request) {
 	// Parse pagination parameters
-	params := parsePaginationParams(r)
+	params := h.parsePaginationParams(r)
 
 	// Check query parameter for active filter
 	activeOnly := r.URL.Query().Get("active") == "true"
 
+	if wantsNDJSON(r) {
+		h.exportMQTTClients(w, r, params.Search, activeOnly)
+		return
+	}
+
 	// Get paginated clients - don't filter by active at DB level since we need to sync from broker
 	clients, _, err := h.db.ListMQTTClientsPaginated(params.Page, params.PageSize, params.Search, params.SortBy, params.SortOrder, false)
 	if err != nil {
@@ -404,15 +570,39 @@ func (h *Handler) ListMQTTClients(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(response)
 }
 
+// exportMQTTClients streams the clients matching search as NDJSON
+func (h *Handler) exportMQTTClients(w http.ResponseWriter, r *http.Request, search string, activeOnly bool) {
+	// Sync is_active status from broker memory (source of truth), as for pages
+	var connected map[string]bool
+	if h.mqtt != nil {
+		connected = make(map[string]bool)
+		for _, c := range h.mqtt.GetClients() {
+			connected[c.ID] = true
+		}
+	}
+
+	stream := newNDJSONStream(w)
+	err := h.db.ForEachMQTTClient(search, func(client *storage.MQTTClient) error {
+		if connected != nil {
+			client.IsActive = connected[client.ClientID]
+		}
+		if activeOnly && !client.IsActive {
+			return nil
+		}
+		return stream.Write(client)
+	})
+	stream.Finish(r, "MQTT clients", err)
+}
+
 // GetMQTTClientDetails godoc
 // @Summary Get MQTT client details
-// @Description Get details for a specific MQTT client by client ID
+// @Description Get details for a specific MQTT client by client ID, including its last CONNECT info (protocol version, clean start, keepalive, remote IP) and current message queue depth
 // @Tags MQTT Clients
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param client_id path string true "Client ID"
-// @Success 200 {object} storage.MQTTClient
+// @Success 200 {object} MQTTClientDetailsResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
@@ -430,14 +620,66 @@ func (h *Handler) GetMQTTClientDetails(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Sync is_active status from broker memory
+	response := MQTTClientDetailsResponse{MQTTClient: *client}
+
+	// Sync is_active status and queue depth from broker memory
 	if h.mqtt != nil {
 		_, isConnected := h.mqtt.Clients.Get(clientID)
-		client.IsActive = isConnected
+		response.IsActive = isConnected
+
+		if depth, limit, ok := h.mqtt.ClientQueueDepth(clientID); ok {
+			response.QueueDepth = &depth
+			response.QueueLimit = &limit
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(client)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// GetMQTTSession godoc
+// @Summary Get MQTT session
+// @Description Get the session the broker holds for a client, connected or not: its subscriptions and the QoS 1/2 messages sent and awaiting acknowledgement (inflight) or waiting to be sent (queued). Useful for diagnosing stuck deliveries to persistent clients
+// @Tags MQTT Clients
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param client_id path string true "Client ID"
+// @Success 200 {object} MQTTSessionResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse "No session for the client"
+// @Failure 503 {object} ErrorResponse "MQTT server not available"
+// @Router /mqtt/sessions/{client_id} [get]
+func (h *Handler) GetMQTTSession(w http.ResponseWriter, r *http.Request) {
+	clientID := r.PathValue("client_id")
+	if clientID == "" {
+		http.Error(w, `{"error":"client_id is required"}`, http.StatusBadRequest)
+		return
+	}
+	if h.mqtt == nil {
+		http.Error(w, `{"error":"MQTT server not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	session, ok := h.mqtt.GetSession(clientID)
+	if !ok {
+		http.Error(w, `{"error":"no session for client"}`, http.StatusNotFound)
+		return
+	}
+
+	response := MQTTSessionResponse{SessionInfo: *session}
+	if h.badger != nil {
+		stored, err := h.badger.GetSession(clientID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"failed to read stored session: %s"}`, err), http.StatusInternalServerError)
+			return
+		}
+		response.Persisted = stored != nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
 }
 
 // UpdateMQTTClientMetadata godoc
@@ -489,6 +731,7 @@ func (h *Handler) UpdateMQTTClientMetadata(w http.ResponseWriter, r *http.Reques
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /mqtt/clients/{id} [delete]
 func (h *Handler) DeleteMQTTClient(w http.ResponseWriter, r *http.Request) {
@@ -500,11 +743,30 @@ func (h *Handler) DeleteMQTTClient(w http.ResponseWriter, r *http.Request) {
 	}
 	id := uint(idVal)
 
-	if err := h.db.DeleteMQTTClient(id); err != nil {
+	deleted, err := h.db.DeleteMQTTClient(id)
+	if err != nil {
 		http.Error(w, fmt.Sprintf(`{"error":"failed to delete client: %s"}`, err), http.StatusInternalServerError)
 		return
 	}
 
+	if deleted == 0 {
+		http.Error(w, `{"error":"client not found"}`, http.StatusNotFound)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(SuccessResponse{Message: "client record deleted"})
 }
+
+// validateIPRanges checks the IP allow/deny lists of an update, if present
+func validateIPRanges(allow, deny *[]string) error {
+	var allowList, denyList []string
+	if allow != nil {
+		allowList = *allow
+	}
+	if deny != nil {
+		denyList = *deny
+	}
+	_, err := auth.ParseIPFilter(allowList, denyList)
+	return err
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ndjsonContentType is the media type of streamed list exports
+const ndjsonContentType = "application/x-ndjson"
+
+// ndjsonFlushEvery is how many lines are written between flushes to the client
+const ndjsonFlushEvery = 100
+
+// wantsNDJSON reports whether a list request asks for its results streamed as
+// newline-delimited JSON (Accept: application/x-ndjson) instead of a page
+func wantsNDJSON(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accept, ";")
+		if strings.TrimSpace(mediaType) == ndjsonContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// ndjsonStream writes a list export one JSON object per line as the rows are read
+type ndjsonStream struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	enc     *json.Encoder
+	written int
+}
+
+// newNDJSONStream starts an export on w. Exports run for as long as they take,
+// so the server's write timeout is lifted
+func newNDJSONStream(w http.ResponseWriter) *ndjsonStream {
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", ndjsonContentType)
+	return &ndjsonStream{w: w, rc: rc, enc: json.NewEncoder(w)}
+}
+
+// Write sends v as the next line
+func (s *ndjsonStream) Write(v interface{}) error {
+	if err := s.enc.Encode(v); err != nil {
+		return err
+	}
+	s.written++
+	if s.written%ndjsonFlushEvery == 0 {
+		_ = s.rc.Flush()
+	}
+	return nil
+}
+
+// Finish reports an export that failed with err (nil if it completed)
+// Once a line is out the status is committed; all we can do is cut the stream short
+func (s *ndjsonStream) Finish(r *http.Request, what string, err error) {
+	if err == nil {
+		return
+	}
+	if s.written == 0 {
+		http.Error(s.w, fmt.Sprintf(`{"error":"failed to export %s: %s"}`, what, err), http.StatusInternalServerError)
+		return
+	}
+	LoggerFromContext(r.Context()).Error("NDJSON export aborted", "export", what, "lines", s.written, "error", err)
+}
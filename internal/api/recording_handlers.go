@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github/bromq-dev/bromq/internal/badgerstore"
+)
+
+// ListRecordings godoc
+// @Summary List recorded messages
+// @Description Get paginated messages captured by the recording hook (newest first)
+// @Tags Recordings
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Items per page" default(25)
+// @Success 200 {object} PaginatedResponse{data=[]badgerstore.RecordedMessage}
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /recordings [get]
+func (h *Handler) ListRecordings(w http.ResponseWriter, r *http.Request) {
+	params := h.parsePaginationParams(r)
+
+	recordings, total, err := h.badger.ListRecordedMessages(params.Page, params.PageSize)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to list recordings: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	// Ensure we return empty array instead of null
+	if recordings == nil {
+		recordings = []badgerstore.RecordedMessage{}
+	}
+
+	// Calculate total pages
+	totalPages := int(math.Ceil(float64(total) / float64(params.PageSize)))
+
+	response := PaginatedResponse{
+		Data: recordings,
+		Pagination: PaginationMetadata{
+			Total:      total,
+			Page:       params.Page,
+			PageSize:   params.PageSize,
+			TotalPages: totalPages,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// ReplayRecording godoc
+// @Summary Replay recorded message
+// @Description Republish a recorded message to its original topic with its original QoS and retain flag. Recordings with a redacted payload cannot be replayed
+// @Tags Recordings
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Recording ID"
+// @Success 200 {object} SuccessResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "Payload was redacted"
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse "MQTT server unavailable"
+// @Router /recordings/{id}/replay [post]
+func (h *Handler) ReplayRecording(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	msg, err := h.badger.GetRecordedMessage(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to get recording: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+	if msg == nil {
+		http.Error(w, `{"error":"recording not found"}`, http.StatusNotFound)
+		return
+	}
+
+	// Replaying the mask would overwrite real device state with placeholder text
+	if msg.Redacted {
+		http.Error(w, `{"error":"recording payload was redacted and cannot be replayed"}`, http.StatusConflict)
+		return
+	}
+
+	if h.mqtt == nil {
+		http.Error(w, `{"error":"MQTT server unavailable"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	if err := h.mqtt.Publish(msg.Topic, msg.Payload, msg.Retain, msg.QoS); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to replay message: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(SuccessResponse{Message: "message replayed"})
+}
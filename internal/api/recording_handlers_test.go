@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github/bromq-dev/bromq/hooks/recording"
+	"github/bromq-dev/bromq/internal/badgerstore"
+	"github/bromq-dev/bromq/internal/mqtt"
+	"github/bromq-dev/bromq/internal/redact"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.mqtt = mqtt.New(mqtt.DefaultConfig())
+
+	hook := recording.NewRecordingHook(handler.badger, &recording.Config{Topics: "sensors/#", MaxMessages: 10})
+	if err := handler.mqtt.AddHook(hook, nil); err != nil {
+		t.Fatalf("Failed to add recording hook: %v", err)
+	}
+
+	// Simulate a client publish that matches the recording filter
+	client := &mochi.Client{ID: "device-1"}
+	_, _ = hook.OnPublish(client, packets.Packet{
+		FixedHeader: packets.FixedHeader{Type: packets.Publish},
+		TopicName:   "sensors/temp",
+		Payload:     []byte("21.5"),
+	})
+	hook.Flush()
+
+	// List recordings
+	req := httptest.NewRequest(http.MethodGet, "/api/recordings", nil)
+	rec := httptest.NewRecorder()
+	handler.ListRecordings(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ListRecordings() status = %v, want %v", rec.Code, http.StatusOK)
+	}
+
+	var response struct {
+		Data       []badgerstore.RecordedMessage `json:"data"`
+		Pagination PaginationMetadata            `json:"pagination"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Pagination.Total != 1 || len(response.Data) != 1 {
+		t.Fatalf("ListRecordings() returned %d recordings, want 1", len(response.Data))
+	}
+	recorded := response.Data[0]
+	if recorded.Topic != "sensors/temp" || string(recorded.Payload) != "21.5" {
+		t.Errorf("recorded message = %+v", recorded)
+	}
+
+	// Subscribe an inline client to catch the replayed message
+	received := make(chan packets.Packet, 1)
+	err := handler.mqtt.Subscribe("sensors/#", 1, func(cl *mochi.Client, sub packets.Subscription, pk packets.Packet) {
+		received <- pk
+	})
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	// Replay it
+	req = httptest.NewRequest(http.MethodPost, "/api/recordings/"+recorded.ID+"/replay", nil)
+	req.SetPathValue("id", recorded.ID)
+	rec = httptest.NewRecorder()
+	handler.ReplayRecording(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ReplayRecording() status = %v, want %v", rec.Code, http.StatusOK)
+	}
+
+	select {
+	case pk := <-received:
+		if pk.TopicName != "sensors/temp" || string(pk.Payload) != "21.5" {
+			t.Errorf("replayed packet topic = %s, payload = %s", pk.TopicName, pk.Payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("replayed message was not delivered")
+	}
+
+	// The replay itself must not be recorded again
+	if count, _ := handler.badger.CountRecordedMessages(); count != 1 {
+		t.Errorf("recordings after replay = %d, want 1", count)
+	}
+}
+
+func TestReplayRecordingNotFound(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/recordings/missing/replay", nil)
+	req.SetPathValue("id", "missing")
+	rec := httptest.NewRecorder()
+	handler.ReplayRecording(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("ReplayRecording() status = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestReplayRecordingRedacted(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.mqtt = mqtt.New(mqtt.DefaultConfig())
+
+	hook := recording.NewRecordingHook(handler.badger, &recording.Config{Topics: "#", MaxMessages: 10})
+	hook.SetRedactor(redact.Parse("secrets/#"))
+
+	_, _ = hook.OnPublish(&mochi.Client{ID: "device-1"}, packets.Packet{
+		FixedHeader: packets.FixedHeader{Type: packets.Publish, Retain: true},
+		TopicName:   "secrets/key",
+		Payload:     []byte("hunter2"),
+	})
+	hook.Flush()
+
+	recordings, _, err := handler.badger.ListRecordedMessages(1, 10)
+	if err != nil || len(recordings) != 1 {
+		t.Fatalf("ListRecordedMessages() = %d recordings, %v", len(recordings), err)
+	}
+	if !recordings[0].Redacted || string(recordings[0].Payload) != redact.Mask {
+		t.Fatalf("recorded message = %+v, want a redacted payload", recordings[0])
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/recordings/"+recordings[0].ID+"/replay", nil)
+	req.SetPathValue("id", recordings[0].ID)
+	rec := httptest.NewRecorder()
+	handler.ReplayRecording(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Errorf("ReplayRecording() status = %v, want %v", rec.Code, http.StatusConflict)
+	}
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github/bromq-dev/bromq/internal/badgerstore"
+	"github/bromq-dev/bromq/internal/mqtt"
+)
+
+// DeleteRetainedMessage godoc
+// @Summary Delete retained message
+// @Description Delete the retained message stored for a topic
+// @Tags Retained Messages
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param topic path string true "Topic name (may contain slashes)"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse "Missing topic"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /retained/{topic} [delete]
+func (h *Handler) DeleteRetainedMessage(w http.ResponseWriter, r *http.Request) {
+	topic := r.PathValue("topic")
+	if topic == "" {
+		http.Error(w, `{"error":"topic is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.retained.DeleteRetainedMessage(topic)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to delete retained message: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	if deleted == 0 {
+		http.Error(w, `{"error":"retained message not found"}`, http.StatusNotFound)
+		return
+	}
+
+	// Drop the broker's in-memory copy so new subscribers don't receive it
+	if h.mqtt != nil {
+		h.mqtt.ClearRetainedMessage(topic)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(SuccessResponse{Message: "retained message deleted"})
+}
+
+// RetainedDumpMessage is one retained message in an export dump; the payload is base64 in JSON
+type RetainedDumpMessage struct {
+	Topic   string `json:"topic"`
+	Payload []byte `json:"payload"`
+	QoS     byte   `json:"qos"`
+}
+
+// ImportRetainedResponse reports the outcome of a retained message import
+type ImportRetainedResponse struct {
+	Imported int      `json:"imported"`
+	Skipped  int      `json:"skipped"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// ReloadRetainedResponse reports the outcome of resyncing the broker's retained messages
+type ReloadRetainedResponse struct {
+	Loaded  int `json:"loaded"`
+	Removed int `json:"removed"`
+}
+
+// maxImportErrors caps how many per-message errors an import response lists
+const maxImportErrors = 20
+
+// maxImportBytes caps the size of a retained message import, which bypasses HTTP_MAX_BODY_BYTES
+const maxImportBytes = 256 << 20 // 256 MB
+
+// ExportRetainedMessages godoc
+// @Summary Export retained messages
+// @Description Stream every retained message as newline-delimited JSON, one {topic, payload (base64), qos} object per line
+// @Tags Retained Messages
+// @Produce application/x-ndjson
+// @Security BearerAuth
+// @Success 200 {array} RetainedDumpMessage
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 500 {object} ErrorResponse
+// @Router /retained/export [get]
+func (h *Handler) ExportRetainedMessages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Disposition", `attachment; filename="retained.ndjson"`)
+
+	stream := newNDJSONStream(w)
+	err := h.retained.ForEachRetainedMessage(func(msg *badgerstore.RetainedMessage) error {
+		return stream.Write(RetainedDumpMessage{Topic: msg.Topic, Payload: msg.Payload, QoS: msg.QoS})
+	})
+	stream.Finish(r, "retained messages", err)
+}
+
+// ImportRetainedMessages godoc
+// @Summary Import retained messages
+// @Description Restore retained messages from an export dump (newline-delimited JSON or a JSON array) into storage and the live broker.
+// @Description Existing messages on the same topics are replaced; current subscribers are not notified. Invalid entries are skipped
+// @Tags Retained Messages
+// @Accept application/x-ndjson
+// @Produce json
+// @Security BearerAuth
+// @Param messages body []RetainedDumpMessage true "Retained messages"
+// @Success 200 {object} ImportRetainedResponse
+// @Failure 400 {object} ErrorResponse "Malformed dump"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 413 {object} ErrorResponse "Dump too large"
+// @Failure 500 {object} ErrorResponse
+// @Router /retained/import [post]
+func (h *Handler) ImportRetainedMessages(w http.ResponseWriter, r *http.Request) {
+	// Imports run for as long as the upload takes, so the server's timeouts are lifted
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	body := bufio.NewReader(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	dec := json.NewDecoder(body)
+
+	// A leading '[' means a JSON array; anything else is read as a stream of objects
+	array := false
+	if first, err := peekNonSpace(body); err == nil && first == '[' {
+		if _, err := dec.Token(); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"invalid dump: %s"}`, err), http.StatusBadRequest)
+			return
+		}
+		array = true
+	}
+
+	resp := ImportRetainedResponse{}
+	skip := func(index int, reason string) {
+		resp.Skipped++
+		if len(resp.Errors) < maxImportErrors {
+			resp.Errors = append(resp.Errors, fmt.Sprintf("message %d: %s", index, reason))
+		}
+	}
+
+	for index := 0; ; index++ {
+		if array && !dec.More() {
+			break
+		}
+
+		var msg RetainedDumpMessage
+		if err := dec.Decode(&msg); err != nil {
+			if err == io.EOF && !array {
+				break
+			}
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				http.Error(w, fmt.Sprintf(`{"error":"dump too large (max %d bytes)","imported":%d}`, maxImportBytes, resp.Imported), http.StatusRequestEntityTooLarge)
+				return
+			}
+			// A syntax error leaves the decoder unusable, so the rest of the dump is lost
+			http.Error(w, fmt.Sprintf(`{"error":"invalid dump at message %d: %s","imported":%d}`, index, err, resp.Imported), http.StatusBadRequest)
+			return
+		}
+
+		if reason := validateRetainedDumpMessage(msg); reason != "" {
+			skip(index, reason)
+			continue
+		}
+
+		if err := h.retained.SaveRetainedMessage(msg.Topic, msg.Payload, msg.QoS); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"failed to save retained message %q: %s","imported":%d}`, msg.Topic, err, resp.Imported), http.StatusInternalServerError)
+			return
+		}
+		if h.mqtt != nil {
+			h.mqtt.RestoreRetainedMessage(msg.Topic, msg.Payload, msg.QoS)
+		}
+		resp.Imported++
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// ReloadRetainedMessages godoc
+// @Summary Reload retained messages
+// @Description Resync the broker's in-memory retained messages with storage without a restart, e.g. after the store was changed directly.
+// @Description Topics retained in the broker but no longer stored are dropped ($SYS topics excepted); subscribers are not notified
+// @Tags Maintenance
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} ReloadRetainedResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse "MQTT server not available"
+// @Router /admin/retained/reload [post]
+func (h *Handler) ReloadRetainedMessages(w http.ResponseWriter, r *http.Request) {
+	if h.mqtt == nil {
+		http.Error(w, `{"error":"MQTT server not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	// Reading through the recount also corrects the accounted retained bytes
+	var stored []*badgerstore.RetainedMessage
+	var err error
+	if counter, ok := h.retained.(RetainedRecounter); ok {
+		stored, err = counter.Recount()
+	} else {
+		stored, err = h.retained.GetAllRetainedMessages()
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to load retained messages: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	messages := make([]mqtt.RetainedSeed, 0, len(stored))
+	for _, msg := range stored {
+		messages = append(messages, mqtt.RetainedSeed{Topic: msg.Topic, Payload: msg.Payload, QoS: msg.QoS})
+	}
+	loaded, removed := h.mqtt.ReloadRetained(messages)
+	LoggerFromContext(r.Context()).Info("Reloaded retained messages from storage", "loaded", loaded, "removed", removed)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ReloadRetainedResponse{Loaded: loaded, Removed: removed})
+}
+
+// validateRetainedDumpMessage returns why a dump entry cannot be imported, or "" if it can
+func validateRetainedDumpMessage(msg RetainedDumpMessage) string {
+	switch {
+	case msg.Topic == "":
+		return "topic is required"
+	case strings.ContainsAny(msg.Topic, "+#"):
+		return "topic must not contain wildcards"
+	case msg.QoS > 2:
+		return "qos must be 0, 1 or 2"
+	case len(msg.Payload) == 0:
+		return "payload is empty (an empty retained payload clears the topic)"
+	}
+	return ""
+}
+
+// peekNonSpace skips leading whitespace and returns the next byte without consuming it
+func peekNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = r.ReadByte()
+		default:
+			return b[0], nil
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github/bromq-dev/bromq/internal/mqtt"
+)
+
+func TestDeleteRetainedMessage(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	if err := handler.badger.SaveRetainedMessage("sensors/temp", []byte("21.5"), 1); err != nil {
+		t.Fatalf("Failed to save retained message: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		topic          string
+		wantStatusCode int
+	}{
+		{
+			name:           "delete existing retained message",
+			topic:          "sensors/temp",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "delete already deleted retained message",
+			topic:          "sensors/temp",
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "delete non-existent retained message",
+			topic:          "does/not/exist",
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "delete with empty topic",
+			topic:          "",
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/api/retained/"+tt.topic, nil)
+			req.SetPathValue("topic", tt.topic)
+			rec := httptest.NewRecorder()
+
+			handler.DeleteRetainedMessage(rec, req)
+
+			if rec.Code != tt.wantStatusCode {
+				t.Errorf("DeleteRetainedMessage() status = %v, want %v", rec.Code, tt.wantStatusCode)
+				t.Logf("Response: %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRetainedExportImportRoundTrip(t *testing.T) {
+	source := setupTestHandler(t)
+
+	want := map[string]struct {
+		payload []byte
+		qos     byte
+	}{
+		"sensors/temp":     {[]byte("21.5"), 1},
+		"sensors/humidity": {[]byte("40"), 0},
+		"devices/blob":     {[]byte{0x00, 0xff, 0x10, '\n'}, 2},
+	}
+	for topic, msg := range want {
+		if err := source.badger.SaveRetainedMessage(topic, msg.payload, msg.qos); err != nil {
+			t.Fatalf("Failed to save retained message: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/retained/export", nil)
+	rec := httptest.NewRecorder()
+	source.ExportRetainedMessages(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ExportRetainedMessages() status = %v, want %v", rec.Code, http.StatusOK)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q, want application/x-ndjson", ct)
+	}
+	dump := rec.Body.Bytes()
+	if lines := strings.Count(string(dump), "\n"); lines != len(want) {
+		t.Fatalf("export has %d lines, want %d", lines, len(want))
+	}
+
+	// Import into a fresh database
+	target := setupTestHandler(t)
+	req = httptest.NewRequest(http.MethodPost, "/api/retained/import", bytes.NewReader(dump))
+	rec = httptest.NewRecorder()
+	target.ImportRetainedMessages(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ImportRetainedMessages() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var resp ImportRetainedResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Imported != len(want) || resp.Skipped != 0 {
+		t.Errorf("import = %+v, want %d imported and none skipped", resp, len(want))
+	}
+
+	for topic, msg := range want {
+		got, err := target.badger.GetRetainedMessage(topic)
+		if err != nil || got == nil {
+			t.Fatalf("retained message %q missing after import: %v", topic, err)
+		}
+		if !bytes.Equal(got.Payload, msg.payload) || got.QoS != msg.qos {
+			t.Errorf("retained message %q = (%q, %d), want (%q, %d)", topic, got.Payload, got.QoS, msg.payload, msg.qos)
+		}
+	}
+}
+
+func TestImportRetainedMessages(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantStatus   int
+		wantImported int
+		wantSkipped  int
+	}{
+		{
+			name:         "json array",
+			body:         ` [{"topic":"a/b","payload":"aGVsbG8=","qos":1},{"topic":"c","payload":"eA==","qos":0}]`,
+			wantStatus:   http.StatusOK,
+			wantImported: 2,
+		},
+		{
+			name:         "invalid entries are skipped",
+			body:         `{"topic":"a/+","payload":"eA==","qos":0}` + "\n" + `{"topic":"a/b","payload":"eA==","qos":3}` + "\n" + `{"topic":"","payload":"eA=="}` + "\n" + `{"topic":"ok","payload":"eA==","qos":1}`,
+			wantStatus:   http.StatusOK,
+			wantImported: 1,
+			wantSkipped:  3,
+		},
+		{
+			name:       "empty body",
+			body:       "",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "malformed line",
+			body:       `{"topic":"a","payload":"eA=="}` + "\n" + `not json`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := setupTestHandler(t)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/retained/import", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			handler.ImportRetainedMessages(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("ImportRetainedMessages() status = %v, want %v: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var resp ImportRetainedResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if resp.Imported != tt.wantImported || resp.Skipped != tt.wantSkipped {
+				t.Errorf("import = %+v, want %d imported and %d skipped", resp, tt.wantImported, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestReloadRetainedMessages(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	reload := func() (int, ReloadRetainedResponse) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/retained/reload", nil)
+		rec := httptest.NewRecorder()
+		handler.ReloadRetainedMessages(rec, req)
+
+		var resp ReloadRetainedResponse
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return rec.Code, resp
+	}
+
+	if code, _ := reload(); code != http.StatusServiceUnavailable {
+		t.Fatalf("ReloadRetainedMessages() without broker status = %v, want %v", code, http.StatusServiceUnavailable)
+	}
+
+	handler.mqtt = mqtt.New(mqtt.DefaultConfig())
+	handler.mqtt.RestoreRetainedMessage("stale/topic", []byte("old"), 0)
+	handler.mqtt.RestoreRetainedMessage("$SYS/broker/version", []byte("1.0"), 0)
+
+	// Added directly to storage, bypassing the broker
+	if err := handler.badger.SaveRetainedMessage("sensors/temp", []byte("21.5"), 1); err != nil {
+		t.Fatalf("Failed to save retained message: %v", err)
+	}
+
+	code, resp := reload()
+	if code != http.StatusOK {
+		t.Fatalf("ReloadRetainedMessages() status = %v, want %v", code, http.StatusOK)
+	}
+	if resp.Loaded != 1 || resp.Removed != 1 {
+		t.Errorf("reload = %+v, want 1 loaded and 1 removed", resp)
+	}
+
+	if messages := handler.mqtt.Topics.Messages("sensors/temp"); len(messages) != 1 || string(messages[0].Payload) != "21.5" || messages[0].FixedHeader.Qos != 1 {
+		t.Errorf("broker retained sensors/temp = %v, want payload 21.5 at QoS 1", messages)
+	}
+	if messages := handler.mqtt.Topics.Messages("stale/topic"); len(messages) != 0 {
+		t.Errorf("broker still retains stale/topic after reload: %v", messages)
+	}
+	if messages := handler.mqtt.Topics.Messages("$SYS/broker/version"); len(messages) != 1 {
+		t.Error("reload dropped a $SYS retained topic")
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+// RevokedTokens is the blacklist of logged out JWTs, keyed by their jti claim
+// Entries are dropped once the token would have expired anyway, so the list
+// never holds more than the logouts of one token lifetime. It is kept in memory,
+// so a restart forgets revocations
+type RevokedTokens struct {
+	mu     sync.Mutex
+	tokens map[string]time.Time // jti -> token expiry
+	now    func() time.Time
+}
+
+// NewRevokedTokens creates an empty blacklist
+func NewRevokedTokens() *RevokedTokens {
+	return &RevokedTokens{
+		tokens: make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// Revoke blacklists the token id until expiresAt
+func (t *RevokedTokens) Revoke(id string, expiresAt time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	for revoked, expiry := range t.tokens {
+		if !expiry.After(now) {
+			delete(t.tokens, revoked)
+		}
+	}
+	if expiresAt.After(now) {
+		t.tokens[id] = expiresAt
+	}
+}
+
+// IsRevoked reports whether the token id was logged out
+// A nil list revokes nothing
+func (t *RevokedTokens) IsRevoked(id string) bool {
+	if t == nil || id == "" {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	expiry, ok := t.tokens[id]
+	return ok && expiry.After(t.now())
+}
+
+// Len returns how many tokens are blacklisted, including expired ones not yet dropped
+func (t *RevokedTokens) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.tokens)
+}
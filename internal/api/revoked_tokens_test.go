@@ -0,0 +1,38 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRevokedTokens_Expiry(t *testing.T) {
+	now := time.Now()
+	revoked := NewRevokedTokens()
+	revoked.now = func() time.Time { return now }
+
+	revoked.Revoke("a", now.Add(time.Hour))
+	revoked.Revoke("b", now.Add(2*time.Hour))
+	revoked.Revoke("expired", now.Add(-time.Minute)) // Already invalid, not stored
+
+	if !revoked.IsRevoked("a") || !revoked.IsRevoked("b") {
+		t.Fatal("IsRevoked() = false for a revoked token")
+	}
+	if revoked.IsRevoked("c") || revoked.IsRevoked("") {
+		t.Error("IsRevoked() = true for a token that was never revoked")
+	}
+
+	// Once a token has expired its entry is no longer needed
+	now = now.Add(90 * time.Minute)
+	if revoked.IsRevoked("a") {
+		t.Error("IsRevoked() = true after the token expired")
+	}
+	revoked.Revoke("c", now.Add(time.Hour))
+	if got := revoked.Len(); got != 2 {
+		t.Errorf("Len() = %d, want 2 (expired entries dropped)", got)
+	}
+
+	var none *RevokedTokens
+	if none.IsRevoked("a") {
+		t.Error("nil IsRevoked() = true")
+	}
+}
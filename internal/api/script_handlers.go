@@ -2,12 +2,16 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github/bromq-dev/bromq/internal/badgerstore"
+	"github/bromq-dev/bromq/internal/script"
 	"github/bromq-dev/bromq/internal/storage"
 
 	"gorm.io/datatypes"
@@ -32,7 +36,7 @@ import (
 // @Failure 500 {object} ErrorResponse
 // @Router /scripts [get]
 func (h *Handler) ListScripts(w http.ResponseWriter, r *http.Request) {
-	params := parsePaginationParams(r)
+	params := h.parsePaginationParams(r)
 
 	scripts, total, err := h.db.ListScriptsPaginated(params.Page, params.PageSize, params.Search, params.SortBy, params.SortOrder)
 	if err != nil {
@@ -151,6 +155,7 @@ func (h *Handler) CreateScript(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf(`{"error":"failed to create script: %s"}`, err), http.StatusInternalServerError)
 		return
 	}
+	h.reloadScriptEngine(r)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -232,11 +237,162 @@ func (h *Handler) UpdateScript(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf(`{"error":"failed to get updated script: %s"}`, err), http.StatusInternalServerError)
 		return
 	}
+	h.reloadScriptEngine(r)
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(script)
 }
 
+// ListScriptVersions godoc
+// @Summary List script versions
+// @Description Previous versions of a script's content and triggers, newest first. A version is saved on every update that changes them
+// @Tags Scripts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Script ID"
+// @Success 200 {array} storage.ScriptVersion
+// @Failure 400 {object} ErrorResponse "Invalid script ID"
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse "Script not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /scripts/{id}/versions [get]
+func (h *Handler) ListScriptVersions(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid script ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetScript(uint(id)); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"script not found: %s"}`, err), http.StatusNotFound)
+		return
+	}
+
+	versions, err := h.db.ListScriptVersions(uint(id))
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to list script versions: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+	if versions == nil {
+		versions = []storage.ScriptVersion{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(versions)
+}
+
+// RollbackScript godoc
+// @Summary Roll back script
+// @Description Restore a script's description, content and triggers from a previous version. The replaced state is saved as a new version
+// @Tags Scripts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Script ID"
+// @Param version path int true "Version to restore"
+// @Success 200 {object} storage.Script
+// @Failure 400 {object} ErrorResponse "Invalid script ID or version"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 404 {object} ErrorResponse "Script or version not found"
+// @Failure 409 {object} ErrorResponse "Provisioned resource cannot be modified"
+// @Failure 500 {object} ErrorResponse
+// @Router /scripts/{id}/rollback/{version} [post]
+func (h *Handler) RollbackScript(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid script ID"}`, http.StatusBadRequest)
+		return
+	}
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil || version < 1 {
+		http.Error(w, `{"error":"invalid script version"}`, http.StatusBadRequest)
+		return
+	}
+
+	script, err := h.db.GetScript(uint(id))
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"script not found: %s"}`, err), http.StatusNotFound)
+		return
+	}
+	if script.ProvisionedFromConfig {
+		http.Error(w, `{"error":"Cannot modify provisioned script. This script is managed by the configuration file. Edit the config file and restart the server to make changes."}`, http.StatusConflict)
+		return
+	}
+	if _, err := h.db.GetScriptVersion(uint(id), version); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"script version not found: %s"}`, err), http.StatusNotFound)
+		return
+	}
+
+	script, err = h.db.RollbackScript(uint(id), version)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to roll back script: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+	h.reloadScriptEngine(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(script)
+}
+
+// CloneScript godoc
+// @Summary Clone script
+// @Description Copy a script and its triggers under a new name. The copy is a manual, editable script even if the source is provisioned, and starts disabled
+// @Tags Scripts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Script ID"
+// @Param clone body CloneRequest true "Name for the copy"
+// @Success 201 {object} storage.Script
+// @Failure 400 {object} ErrorResponse "Invalid script ID or request"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 404 {object} ErrorResponse "Script not found"
+// @Failure 409 {object} ErrorResponse "Name already in use"
+// @Failure 500 {object} ErrorResponse
+// @Router /scripts/{id}/clone [post]
+func (h *Handler) CloneScript(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid script ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req CloneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid request: %s"}`, err), http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		http.Error(w, `{"error":"script name is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetScript(uint(id)); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"script not found: %s"}`, err), http.StatusNotFound)
+		return
+	}
+	if _, err := h.db.GetScriptByName(req.Name); err == nil {
+		http.Error(w, fmt.Sprintf(`{"error":"a script named '%s' already exists"}`, req.Name), http.StatusConflict)
+		return
+	}
+
+	script, err := h.db.CloneScript(uint(id), req.Name)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to clone script: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(script)
+}
+
 // DeleteScript godoc
 // @Summary Delete script
 // @Description Delete a JavaScript script and all its triggers
@@ -277,6 +433,10 @@ func (h *Handler) DeleteScript(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf(`{"error":"failed to delete script: %s"}`, err), http.StatusInternalServerError)
 		return
 	}
+	if h.engine != nil {
+		h.engine.ForgetStats(uint(id))
+	}
+	h.reloadScriptEngine(r)
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(SuccessResponse{Message: "script deleted successfully"})
@@ -317,6 +477,7 @@ func (h *Handler) EnableScript(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf(`{"error":"failed to update script: %s"}`, err), http.StatusInternalServerError)
 		return
 	}
+	h.reloadScriptEngine(r)
 
 	status := "disabled"
 	if req.Enabled {
@@ -327,6 +488,103 @@ func (h *Handler) EnableScript(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(SuccessResponse{Message: fmt.Sprintf("script %s successfully", status)})
 }
 
+// EnableScriptTrigger godoc
+// @Summary Enable/disable script trigger
+// @Description Toggle a single trigger of a script, e.g. to silence one event type on a multi-trigger script. Takes effect immediately
+// @Tags Scripts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Script ID"
+// @Param triggerId path int true "Trigger ID"
+// @Param enabled body object{enabled=bool} true "Enable/disable flag"
+// @Success 200 {object} storage.ScriptTrigger
+// @Failure 400 {object} ErrorResponse "Invalid script ID, trigger ID or request"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 404 {object} ErrorResponse "Trigger not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /scripts/{id}/triggers/{triggerId}/enable [put]
+func (h *Handler) EnableScriptTrigger(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid script ID"}`, http.StatusBadRequest)
+		return
+	}
+	triggerID, err := strconv.ParseUint(r.PathValue("triggerId"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid trigger ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid request: %s"}`, err), http.StatusBadRequest)
+		return
+	}
+	if req.Enabled == nil {
+		http.Error(w, `{"error":"enabled is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	trigger, err := h.db.SetScriptTriggerEnabled(uint(id), uint(triggerID), *req.Enabled)
+	if err != nil {
+		if errors.Is(err, storage.ErrTriggerNotFound) {
+			http.Error(w, `{"error":"trigger not found"}`, http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf(`{"error":"failed to update trigger: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	h.reloadScriptEngine(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(trigger)
+}
+
+// reloadScriptEngine refreshes the engine's script cache after a script change so
+// it applies immediately. This also drops dynamic subscriptions (mqtt.subscribe)
+// of scripts that were disabled, deleted or updated
+func (h *Handler) reloadScriptEngine(r *http.Request) {
+	if h.engine == nil {
+		return
+	}
+	if err := h.engine.ReloadScripts(); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to reload script cache", "error", err)
+	}
+}
+
+// ValidateScript godoc
+// @Summary Validate script
+// @Description Compile a JavaScript script without running it and return any syntax errors with their line and column
+// @Tags Scripts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param script body ValidateScriptRequest true "Script content"
+// @Success 200 {object} ValidateScriptResponse
+// @Failure 400 {object} ErrorResponse "Invalid request"
+// @Failure 401 {object} ErrorResponse
+// @Router /scripts/validate [post]
+func (h *Handler) ValidateScript(w http.ResponseWriter, r *http.Request) {
+	var req ValidateScriptRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid request: %s"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	errs := script.Validate(req.Content)
+	if errs == nil {
+		errs = []script.SyntaxError{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ValidateScriptResponse{Valid: len(errs) == 0, Errors: errs})
+}
+
 // TestScript godoc
 // @Summary Test script
 // @Description Test a JavaScript script with mock event data without saving it to the database
@@ -375,17 +633,22 @@ func (h *Handler) TestScript(w http.ResponseWriter, r *http.Request) {
 
 // GetScriptLogs godoc
 // @Summary Get script logs
-// @Description Get paginated execution logs for a specific script with optional level filtering
+// @Description Get paginated execution logs for a specific script with optional level and structured field filtering
+// @Description With Accept: application/x-ndjson every matching log is streamed as one JSON object per line, oldest first, ignoring page and pageSize
 // @Tags Scripts
 // @Accept json
-// @Produce json
+// @Produce json,application/x-ndjson
 // @Security BearerAuth
 // @Param id path int true "Script ID"
 // @Param page query int false "Page number" default(1)
 // @Param pageSize query int false "Items per page" default(25)
 // @Param level query string false "Filter by log level (debug, info, warn, error)"
+// @Param search query string false "Case-insensitive text the message must contain"
+// @Param since query string false "Only logs at or after this time (RFC 3339)"
+// @Param until query string false "Only logs before this time (RFC 3339)"
+// @Param field query []string false "Filter by structured field as name:value, e.g. deviceId:sensor-1 (repeatable, all must match)" collectionFormat(multi)
 // @Success 200 {object} PaginatedResponse{data=[]badgerstore.ScriptLogEntry}
-// @Failure 400 {object} ErrorResponse "Invalid script ID"
+// @Failure 400 {object} ErrorResponse "Invalid script ID or filter"
 // @Failure 401 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /scripts/{id}/logs [get]
@@ -397,16 +660,135 @@ func (h *Handler) GetScriptLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	params := parsePaginationParams(r)
-	level := r.URL.Query().Get("level") // Optional filter by level
+	params := h.parsePaginationParams(r)
+	filter, err := parseScriptLogFilter(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err), http.StatusBadRequest)
+		return
+	}
 
 	badger := h.engine.GetBadger()
-	logs, total, err := badger.ListScriptLogs(uint(id), params.Page, params.PageSize, level)
+	if wantsNDJSON(r) {
+		exportScriptLogs(w, r, badger, []uint{uint(id)}, filter)
+		return
+	}
+
+	logs, total, err := badger.ListScriptLogsFiltered(uint(id), params.Page, params.PageSize, filter)
 	if err != nil {
 		http.Error(w, fmt.Sprintf(`{"error":"failed to list logs: %s"}`, err), http.StatusInternalServerError)
 		return
 	}
 
+	writeScriptLogs(w, logs, total, params)
+}
+
+// SearchScriptLogs godoc
+// @Summary Search script logs
+// @Description Search execution logs across all scripts, or the given ones, for debugging scripts that interact. Paginated, newest first
+// @Description With Accept: application/x-ndjson every matching log is streamed as one JSON object per line, oldest first within each script, ignoring page and pageSize
+// @Tags Scripts
+// @Accept json
+// @Produce json,application/x-ndjson
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Items per page" default(25)
+// @Param scriptId query []int false "Only logs of these scripts (repeatable or comma-separated)" collectionFormat(multi)
+// @Param level query string false "Filter by log level (debug, info, warn, error)"
+// @Param search query string false "Case-insensitive text the message must contain"
+// @Param since query string false "Only logs at or after this time (RFC 3339)"
+// @Param until query string false "Only logs before this time (RFC 3339)"
+// @Param field query []string false "Filter by structured field as name:value, e.g. deviceId:sensor-1 (repeatable, all must match)" collectionFormat(multi)
+// @Success 200 {object} PaginatedResponse{data=[]badgerstore.ScriptLogEntry}
+// @Failure 400 {object} ErrorResponse "Invalid script ID or filter"
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /scripts/logs [get]
+func (h *Handler) SearchScriptLogs(w http.ResponseWriter, r *http.Request) {
+	var scriptIDs []uint
+	seen := make(map[uint]bool)
+	for _, value := range r.URL.Query()["scriptId"] {
+		for _, idStr := range strings.Split(value, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(idStr), 10, 32)
+			if err != nil {
+				http.Error(w, fmt.Sprintf(`{"error":"invalid script ID '%s'"}`, idStr), http.StatusBadRequest)
+				return
+			}
+			if !seen[uint(id)] {
+				seen[uint(id)] = true
+				scriptIDs = append(scriptIDs, uint(id))
+			}
+		}
+	}
+
+	params := h.parsePaginationParams(r)
+	filter, err := parseScriptLogFilter(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	badger := h.engine.GetBadger()
+	if wantsNDJSON(r) {
+		exportScriptLogs(w, r, badger, scriptIDs, filter)
+		return
+	}
+
+	logs, total, err := badger.SearchScriptLogs(scriptIDs, params.Page, params.PageSize, filter)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to search logs: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	writeScriptLogs(w, logs, total, params)
+}
+
+// exportScriptLogs streams the logs of scriptIDs (all scripts if none) matching filter as NDJSON
+func exportScriptLogs(w http.ResponseWriter, r *http.Request, badger *badgerstore.BadgerStore, scriptIDs []uint, filter badgerstore.ScriptLogFilter) {
+	stream := newNDJSONStream(w)
+	err := badger.ForEachScriptLog(scriptIDs, filter, func(entry badgerstore.ScriptLogEntry) error {
+		return stream.Write(entry)
+	})
+	stream.Finish(r, "script logs", err)
+}
+
+// parseScriptLogFilter reads the level, search, since, until and field log filters from the query
+func parseScriptLogFilter(r *http.Request) (badgerstore.ScriptLogFilter, error) {
+	query := r.URL.Query()
+	filter := badgerstore.ScriptLogFilter{
+		Level: query.Get("level"),
+		Text:  query.Get("search"),
+	}
+
+	for _, bound := range []struct {
+		name   string
+		target *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		value := query.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid %s '%s' (expected RFC 3339, e.g. 2024-01-02T15:04:05Z)", bound.name, value)
+		}
+		*bound.target = parsed
+	}
+
+	for _, field := range query["field"] {
+		name, value, ok := strings.Cut(field, ":")
+		if !ok || name == "" {
+			return filter, fmt.Errorf("invalid field filter '%s' (expected name:value)", field)
+		}
+		if filter.Fields == nil {
+			filter.Fields = make(map[string]string)
+		}
+		filter.Fields[name] = value
+	}
+	return filter, nil
+}
+
+// writeScriptLogs writes one page of script logs as a PaginatedResponse
+func writeScriptLogs(w http.ResponseWriter, logs []badgerstore.ScriptLogEntry, total int64, params PaginationQuery) {
 	// Ensure we return empty array instead of null
 	if logs == nil {
 		logs = []badgerstore.ScriptLogEntry{}
@@ -461,6 +843,42 @@ func (h *Handler) ClearScriptLogs(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(SuccessResponse{Message: "logs cleared successfully"})
 }
 
+// GetScriptStats godoc
+// @Summary Get script execution stats
+// @Description Get execution count, failures, timeouts and durations of a script since the server started
+// @Tags Scripts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Script ID"
+// @Success 200 {object} script.ScriptStats
+// @Failure 400 {object} ErrorResponse "Invalid script ID"
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse "Script not found"
+// @Failure 503 {object} ErrorResponse "Script engine not available"
+// @Router /scripts/{id}/stats [get]
+func (h *Handler) GetScriptStats(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid script ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetScript(uint(id)); err != nil {
+		http.Error(w, `{"error":"script not found"}`, http.StatusNotFound)
+		return
+	}
+
+	if h.engine == nil {
+		http.Error(w, `{"error":"script engine not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.engine.Stats(uint(id)))
+}
+
 // GetScriptState godoc
 // @Summary Get script state
 // @Description Get all persistent state keys stored by a script
@@ -532,3 +950,51 @@ func (h *Handler) DeleteScriptStateKey(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(SuccessResponse{Message: "state key deleted successfully"})
 }
+
+// DisableAllScripts godoc
+// @Summary Disable all scripts
+// @Description Turn on the global kill switch: no script runs for any trigger until re-enabled. Each script's stored enabled flag is unchanged. The switch is saved and stays on across restarts; persisted is false if saving failed
+// @Tags Scripts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} ScriptKillSwitchResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Router /admin/scripts/disable-all [post]
+func (h *Handler) DisableAllScripts(w http.ResponseWriter, r *http.Request) {
+	h.setScriptsDisabled(w, r, true)
+}
+
+// EnableAllScripts godoc
+// @Summary Re-enable scripts
+// @Description Turn off the global kill switch so enabled scripts run again
+// @Tags Scripts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} ScriptKillSwitchResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Router /admin/scripts/enable-all [post]
+func (h *Handler) EnableAllScripts(w http.ResponseWriter, r *http.Request) {
+	h.setScriptsDisabled(w, r, false)
+}
+
+// setScriptsDisabled flips the engine's kill switch, saves it for the next
+// start and reports the new state. The switch takes effect even if saving
+// fails, which the response reports as persisted: false
+func (h *Handler) setScriptsDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	h.engine.SetScriptsDisabled(disabled)
+	persisted := true
+	if err := h.db.Settings().SetBool(storage.SettingScriptsDisabled, disabled); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to save script kill switch; it will reset on restart", "error", err)
+		persisted = false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ScriptKillSwitchResponse{
+		ScriptsDisabled: h.engine.ScriptsDisabled(),
+		Persisted:       persisted,
+	})
+}
@@ -0,0 +1,511 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github/bromq-dev/bromq/internal/script"
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+func TestCloneScript_Provisioned(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	source, err := handler.db.CreateProvisionedScript("config-script", "from config", "log.info('hi')", true, nil,
+		[]storage.ScriptTrigger{{Type: "on_publish", Topic: "sensors/#", Priority: 10, Enabled: true}})
+	if err != nil {
+		t.Fatalf("CreateProvisionedScript() error = %v", err)
+	}
+
+	body, _ := json.Marshal(CloneRequest{Name: "config-script-copy"})
+	req := httptest.NewRequest(http.MethodPost, "/api/scripts/1/clone", bytes.NewReader(body))
+	req.SetPathValue("id", "1")
+	rec := httptest.NewRecorder()
+
+	handler.CloneScript(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CloneScript() status = %v, want %v: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+
+	var clone storage.Script
+	if err := json.NewDecoder(rec.Body).Decode(&clone); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if clone.ID == source.ID || clone.ProvisionedFromConfig || clone.Enabled {
+		t.Errorf("clone = id %d provisioned %v enabled %v, want a new disabled manual script", clone.ID, clone.ProvisionedFromConfig, clone.Enabled)
+	}
+	if clone.Content != source.Content || len(clone.Triggers) != 1 || clone.Triggers[0].Topic != "sensors/#" {
+		t.Errorf("clone = %+v, want the source's content and triggers", clone)
+	}
+
+	// The copy is editable through the API
+	update, _ := json.Marshal(UpdateScriptRequest{Name: clone.Name, Content: "log.info('edited')", Enabled: true})
+	cloneID := strconv.FormatUint(uint64(clone.ID), 10)
+	req = httptest.NewRequest(http.MethodPut, "/api/scripts/"+cloneID, bytes.NewReader(update))
+	req.SetPathValue("id", cloneID)
+	rec = httptest.NewRecorder()
+
+	handler.UpdateScript(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("UpdateScript() on clone status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+}
+
+func TestCloneScript_DisabledTrigger(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	source, err := handler.db.CreateScript("source", "", "log.info('hi')", true, nil, []storage.ScriptTrigger{
+		{Type: "on_publish", Topic: "on/#", Enabled: true},
+		{Type: "on_publish", Topic: "off/#", Enabled: true},
+	})
+	if err != nil {
+		t.Fatalf("CreateScript() error = %v", err)
+	}
+	if _, err := handler.db.SetScriptTriggerEnabled(source.ID, source.Triggers[1].ID, false); err != nil {
+		t.Fatalf("SetScriptTriggerEnabled() error = %v", err)
+	}
+
+	clone, err := handler.db.CloneScript(source.ID, "source-copy")
+	if err != nil {
+		t.Fatalf("CloneScript() error = %v", err)
+	}
+	stored, err := handler.db.GetScript(clone.ID)
+	if err != nil {
+		t.Fatalf("GetScript() error = %v", err)
+	}
+
+	enabled := make(map[string]bool)
+	for _, trigger := range stored.Triggers {
+		enabled[trigger.Topic] = trigger.Enabled
+	}
+	if !enabled["on/#"] || enabled["off/#"] {
+		t.Errorf("clone trigger enabled = %v, want on/# enabled and off/# disabled", enabled)
+	}
+}
+
+func TestCloneScript_NameConflict(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	if _, err := handler.db.CreateScript("existing", "", "log.info('hi')", true, nil, nil); err != nil {
+		t.Fatalf("CreateScript() error = %v", err)
+	}
+
+	body, _ := json.Marshal(CloneRequest{Name: "existing"})
+	req := httptest.NewRequest(http.MethodPost, "/api/scripts/1/clone", bytes.NewReader(body))
+	req.SetPathValue("id", "1")
+	rec := httptest.NewRecorder()
+
+	handler.CloneScript(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Errorf("CloneScript() status = %v, want %v", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestRollbackScript(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	script, err := handler.db.CreateScript("rollback", "", "log.info('one');", true, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateScript() error = %v", err)
+	}
+	id := strconv.FormatUint(uint64(script.ID), 10)
+
+	for _, content := range []string{"log.info('two');", "log.info('three');"} {
+		body, _ := json.Marshal(UpdateScriptRequest{Name: "rollback", Content: content, Enabled: true})
+		req := httptest.NewRequest(http.MethodPut, "/api/scripts/"+id, bytes.NewReader(body))
+		req.SetPathValue("id", id)
+		rec := httptest.NewRecorder()
+		handler.UpdateScript(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("UpdateScript() status = %v: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/scripts/"+id+"/versions", nil)
+	req.SetPathValue("id", id)
+	rec := httptest.NewRecorder()
+	handler.ListScriptVersions(rec, req)
+
+	var versions []storage.ScriptVersion
+	if err := json.NewDecoder(rec.Body).Decode(&versions); err != nil {
+		t.Fatalf("Failed to decode versions: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("ListScriptVersions() returned %d versions, want 2", len(versions))
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/scripts/"+id+"/rollback/1", nil)
+	req.SetPathValue("id", id)
+	req.SetPathValue("version", "1")
+	rec = httptest.NewRecorder()
+	handler.RollbackScript(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("RollbackScript() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	restored, err := handler.db.GetScript(script.ID)
+	if err != nil {
+		t.Fatalf("GetScript() error = %v", err)
+	}
+	if restored.Content != "log.info('one');" {
+		t.Errorf("content after rollback = %q, want the first version", restored.Content)
+	}
+
+	// Unknown versions are reported as not found
+	req = httptest.NewRequest(http.MethodPost, "/api/scripts/"+id+"/rollback/99", nil)
+	req.SetPathValue("id", id)
+	req.SetPathValue("version", "99")
+	rec = httptest.NewRecorder()
+	handler.RollbackScript(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("RollbackScript() unknown version status = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestRollbackScript_DisabledTrigger(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	script, err := handler.db.CreateScript("rollback", "", "log.info('one');", true, nil,
+		[]storage.ScriptTrigger{{Type: "on_publish", Topic: "sensors/#", Enabled: true}})
+	if err != nil {
+		t.Fatalf("CreateScript() error = %v", err)
+	}
+	if _, err := handler.db.SetScriptTriggerEnabled(script.ID, script.Triggers[0].ID, false); err != nil {
+		t.Fatalf("SetScriptTriggerEnabled() error = %v", err)
+	}
+
+	// Version 1 keeps the disabled trigger
+	if err := handler.db.UpdateScript(script.ID, "rollback", "", "log.info('two');", true, nil,
+		[]storage.ScriptTrigger{{Type: "on_publish", Topic: "sensors/#", Enabled: true}}); err != nil {
+		t.Fatalf("UpdateScript() error = %v", err)
+	}
+
+	restored, err := handler.db.RollbackScript(script.ID, 1)
+	if err != nil {
+		t.Fatalf("RollbackScript() error = %v", err)
+	}
+	if len(restored.Triggers) != 1 || restored.Triggers[0].Enabled {
+		t.Errorf("triggers after rollback = %+v, want the disabled trigger", restored.Triggers)
+	}
+}
+
+func TestValidateScript(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	tests := []struct {
+		name      string
+		content   string
+		wantValid bool
+		wantLine  int
+	}{
+		{name: "valid", content: "log.info(msg.topic);", wantValid: true},
+		{name: "syntax error", content: "log.info('ok');\nlog.info(;", wantLine: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(ValidateScriptRequest{Content: tt.content})
+			req := httptest.NewRequest(http.MethodPost, "/api/scripts/validate", bytes.NewReader(body))
+			rec := httptest.NewRecorder()
+
+			handler.ValidateScript(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("ValidateScript() status = %v, want %v", rec.Code, http.StatusOK)
+			}
+
+			var response ValidateScriptResponse
+			if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Valid != tt.wantValid {
+				t.Fatalf("ValidateScript() valid = %v, want %v (errors %+v)", response.Valid, tt.wantValid, response.Errors)
+			}
+			if !tt.wantValid && (len(response.Errors) == 0 || response.Errors[0].Line != tt.wantLine) {
+				t.Errorf("ValidateScript() errors = %+v, want an error on line %d", response.Errors, tt.wantLine)
+			}
+		})
+	}
+}
+
+func TestGetScriptStats(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.engine = script.NewEngine(handler.db, handler.badger, nil)
+
+	created, err := handler.db.CreateScript("stats-script", "", "log.info('hi')", true, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateScript() error = %v", err)
+	}
+	id := strconv.FormatUint(uint64(created.ID), 10)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/scripts/"+id+"/stats", nil)
+	req.SetPathValue("id", id)
+	rec := httptest.NewRecorder()
+
+	handler.GetScriptStats(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GetScriptStats() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var stats script.ScriptStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if stats.ScriptID != created.ID || stats.Executions != 0 {
+		t.Errorf("stats = %+v, want zero counters for script %d", stats, created.ID)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/scripts/999/stats", nil)
+	req.SetPathValue("id", "999")
+	rec = httptest.NewRecorder()
+
+	handler.GetScriptStats(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GetScriptStats() for missing script status = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+
+	handler.engine = nil
+	req = httptest.NewRequest(http.MethodGet, "/api/scripts/"+id+"/stats", nil)
+	req.SetPathValue("id", id)
+	rec = httptest.NewRecorder()
+
+	handler.GetScriptStats(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("GetScriptStats() without engine status = %v, want %v", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestSearchScriptLogs(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.engine = script.NewEngine(handler.db, handler.badger, nil)
+
+	for _, log := range []struct {
+		scriptID uint
+		level    string
+	}{{1, "info"}, {1, "error"}, {2, "error"}, {2, "debug"}} {
+		if err := handler.badger.SaveScriptLog(log.scriptID, "on_publish", log.level, "message", nil, 1); err != nil {
+			t.Fatalf("SaveScriptLog() error = %v", err)
+		}
+		time.Sleep(time.Millisecond) // Unique timestamps
+	}
+
+	search := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/scripts/logs?"+query, nil)
+		rec := httptest.NewRecorder()
+		handler.SearchScriptLogs(rec, req)
+		return rec
+	}
+
+	rec := search("level=error")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("SearchScriptLogs() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var response struct {
+		Data []struct {
+			ScriptID uint `json:"script_id"`
+		} `json:"data"`
+		Pagination PaginationMetadata `json:"pagination"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Pagination.Total != 2 || len(response.Data) != 2 {
+		t.Fatalf("error logs = %+v, want one from each script", response)
+	}
+	if response.Data[0].ScriptID != 2 || response.Data[1].ScriptID != 1 {
+		t.Errorf("error logs from scripts [%d %d], want [2 1] (newest first)", response.Data[0].ScriptID, response.Data[1].ScriptID)
+	}
+
+	for _, query := range []string{"scriptId=abc", "since=yesterday", "field=nocolon"} {
+		if rec := search(query); rec.Code != http.StatusBadRequest {
+			t.Errorf("SearchScriptLogs(%s) status = %v, want %v", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestSearchScriptLogs_NDJSON(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.engine = script.NewEngine(handler.db, handler.badger, nil)
+
+	for i := 0; i < 3; i++ {
+		if err := handler.badger.SaveScriptLog(1, "on_publish", "info", fmt.Sprintf("message %d", i), nil, 1); err != nil {
+			t.Fatalf("SaveScriptLog() error = %v", err)
+		}
+		time.Sleep(time.Millisecond) // Unique timestamps
+	}
+	if err := handler.badger.SaveScriptLog(2, "on_publish", "info", "other script", nil, 1); err != nil {
+		t.Fatalf("SaveScriptLog() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/scripts/logs?scriptId=1&pageSize=1", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	rec := httptest.NewRecorder()
+	handler.SearchScriptLogs(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("SearchScriptLogs() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("NDJSON export has %d lines, want 3: %s", len(lines), rec.Body.String())
+	}
+	for i, line := range lines {
+		var entry struct {
+			ScriptID uint   `json:"script_id"`
+			Message  string `json:"message"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("line %d is not a JSON object: %v", i+1, err)
+		}
+		if want := fmt.Sprintf("message %d", i); entry.ScriptID != 1 || entry.Message != want {
+			t.Errorf("line %d = script %d %q, want script 1 %q (oldest first)", i+1, entry.ScriptID, entry.Message, want)
+		}
+	}
+}
+
+func TestEnableScriptTrigger(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.engine = script.NewEngine(handler.db, handler.badger, nil)
+	handler.engine.Start()
+	defer handler.engine.Shutdown(context.Background())
+
+	created, err := handler.db.CreateScript("multi-trigger", "", `state.set(msg.type, true);`, true, nil, []storage.ScriptTrigger{
+		{Type: "on_publish", Topic: "test/#", Priority: 100, Enabled: true},
+		{Type: "on_connect", Priority: 100, Enabled: true},
+	})
+	if err != nil {
+		t.Fatalf("CreateScript() error = %v", err)
+	}
+	if err := handler.engine.ReloadScripts(); err != nil {
+		t.Fatalf("ReloadScripts() error = %v", err)
+	}
+
+	var publishTrigger storage.ScriptTrigger
+	for _, trigger := range created.Triggers {
+		if trigger.Type == "on_publish" {
+			publishTrigger = trigger
+		}
+	}
+
+	toggle := func(scriptID, triggerID uint, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		sid, tid := strconv.FormatUint(uint64(scriptID), 10), strconv.FormatUint(uint64(triggerID), 10)
+		req := httptest.NewRequest(http.MethodPut, "/api/scripts/"+sid+"/triggers/"+tid+"/enable", strings.NewReader(body))
+		req.SetPathValue("id", sid)
+		req.SetPathValue("triggerId", tid)
+		rec := httptest.NewRecorder()
+		handler.EnableScriptTrigger(rec, req)
+		return rec
+	}
+
+	rec := toggle(created.ID, publishTrigger.ID, `{"enabled":false}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("EnableScriptTrigger() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var updated storage.ScriptTrigger
+	if err := json.NewDecoder(rec.Body).Decode(&updated); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if updated.ID != publishTrigger.ID || updated.Enabled {
+		t.Errorf("trigger = %+v, want trigger %d disabled", updated, publishTrigger.ID)
+	}
+
+	// Only the publish event is silenced
+	handler.engine.ExecuteForTrigger("on_publish", "test/topic", &script.Message{Type: "publish", Topic: "test/topic"})
+	handler.engine.ExecuteForTrigger("on_connect", "", &script.Message{Type: "connect", ClientID: "client-1"})
+	time.Sleep(100 * time.Millisecond)
+
+	if _, ran := handler.engine.GetState().Get(&created.ID, "publish"); ran {
+		t.Error("script ran for on_publish after its trigger was disabled")
+	}
+	if _, ran := handler.engine.GetState().Get(&created.ID, "connect"); !ran {
+		t.Error("script did not run for on_connect, whose trigger is still enabled")
+	}
+
+	// Re-enabling brings the event back
+	if rec := toggle(created.ID, publishTrigger.ID, `{"enabled":true}`); rec.Code != http.StatusOK {
+		t.Fatalf("EnableScriptTrigger() status = %v, want %v", rec.Code, http.StatusOK)
+	}
+	handler.engine.ExecuteForTrigger("on_publish", "test/topic", &script.Message{Type: "publish", Topic: "test/topic"})
+	time.Sleep(100 * time.Millisecond)
+
+	if _, ran := handler.engine.GetState().Get(&created.ID, "publish"); !ran {
+		t.Error("script did not run for on_publish after its trigger was re-enabled")
+	}
+
+	tests := []struct {
+		name       string
+		scriptID   uint
+		triggerID  uint
+		body       string
+		wantStatus int
+	}{
+		{name: "trigger of another script", scriptID: created.ID + 1, triggerID: publishTrigger.ID, body: `{"enabled":false}`, wantStatus: http.StatusNotFound},
+		{name: "unknown trigger", scriptID: created.ID, triggerID: 999, body: `{"enabled":false}`, wantStatus: http.StatusNotFound},
+		{name: "missing enabled", scriptID: created.ID, triggerID: publishTrigger.ID, body: `{}`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := toggle(tt.scriptID, tt.triggerID, tt.body); rec.Code != tt.wantStatus {
+				t.Errorf("EnableScriptTrigger() status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestScriptKillSwitch_Persisted(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.engine = script.NewEngine(handler.db, handler.badger, nil)
+
+	rec := httptest.NewRecorder()
+	handler.DisableAllScripts(rec, httptest.NewRequest(http.MethodPost, "/api/admin/scripts/disable-all", nil))
+	if rec.Code != http.StatusOK || !handler.engine.ScriptsDisabled() {
+		t.Fatalf("DisableAllScripts() status = %v, disabled = %v; want the switch on", rec.Code, handler.engine.ScriptsDisabled())
+	}
+	if saved, err := handler.db.Settings().GetBool(storage.SettingScriptsDisabled, false); err != nil || !saved {
+		t.Errorf("saved kill switch = %v, %v; want true", saved, err)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.EnableAllScripts(rec, httptest.NewRequest(http.MethodPost, "/api/admin/scripts/enable-all", nil))
+	if saved, err := handler.db.Settings().GetBool(storage.SettingScriptsDisabled, true); err != nil || saved {
+		t.Errorf("saved kill switch after enable-all = %v, %v; want false", saved, err)
+	}
+}
+
+func TestScriptKillSwitch_NotPersisted(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.engine = script.NewEngine(handler.db, handler.badger, nil)
+	if err := handler.db.Migrator().DropTable(&storage.Setting{}); err != nil {
+		t.Fatalf("DropTable() error = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.DisableAllScripts(rec, httptest.NewRequest(http.MethodPost, "/api/admin/scripts/disable-all", nil))
+
+	var got ScriptKillSwitchResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !got.ScriptsDisabled || got.Persisted {
+		t.Errorf("DisableAllScripts() = %+v, want scripts disabled but not persisted", got)
+	}
+	if !handler.engine.ScriptsDisabled() {
+		t.Error("kill switch not in effect after a failed save")
+	}
+}
@@ -1,12 +1,20 @@
 package api
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"io/fs"
 	"log/slog"
+	"net"
 	"net/http"
+	"sync"
 	"time"
 
+	"github/bromq-dev/bromq/hooks/retained"
 	"github/bromq-dev/bromq/internal/api/swagger"
+	"github/bromq-dev/bromq/internal/badgerstore"
+	"github/bromq-dev/bromq/internal/events"
 	"github/bromq-dev/bromq/internal/mqtt"
 	"github/bromq-dev/bromq/internal/script"
 	"github/bromq-dev/bromq/internal/storage"
@@ -21,30 +29,92 @@ type Server struct {
 	config  *Config
 	addr    string
 	webFS   fs.FS
+
+	mu             sync.Mutex
+	httpServer     *http.Server
+	redirectServer *http.Server
+	closed         bool // Shutdown was called; servers started later exit at once
 }
 
 // NewServer creates a new API server
-func NewServer(addr string, db *storage.DB, mqttServer *mqtt.Server, webFS fs.FS, scriptEngine *script.Engine, config *Config) *Server {
+func NewServer(addr string, db *storage.DB, badgerStore *badgerstore.BadgerStore, mqttServer *mqtt.Server, webFS fs.FS, scriptEngine *script.Engine, config *Config) *Server {
 	return &Server{
-		handler: NewHandler(db, mqttServer, scriptEngine, config),
+		handler: NewHandler(db, badgerStore, mqttServer, scriptEngine, config),
 		config:  config,
 		addr:    addr,
 		webFS:   webFS,
 	}
 }
 
+// SetRecentTopicSource sets the tracker used to include recently published topics in the topic tree
+func (s *Server) SetRecentTopicSource(source RecentTopicSource) {
+	s.handler.topics = source
+}
+
+// SetRetainedStore sets the store retained message endpoints read and write,
+// replacing the default BadgerDB store
+func (s *Server) SetRetainedStore(store retained.RetainedStore) {
+	s.handler.retained = store
+}
+
+// SetLastValueSource sets the cache backing GET /api/topics/last
+func (s *Server) SetLastValueSource(source LastValueSource) {
+	s.handler.lastValues = source
+}
+
+// SetThroughputSource sets the history backing GET /api/stats/throughput
+func (s *Server) SetThroughputSource(source ThroughputSource) {
+	s.handler.throughput = source
+}
+
+// SetBridgeStatusSource sets the bridge manager reporting how many bridges are connected
+func (s *Server) SetBridgeStatusSource(source BridgeStatusSource) {
+	s.handler.bridges = source
+}
+
+// SetTopicRouteReloader sets the hook reloaded after topic routes are changed
+func (s *Server) SetTopicRouteReloader(reloader TopicRouteReloader) {
+	s.handler.routes = reloader
+}
+
+// SetUnmatchedACLSource sets the tracker of ACL denials that matched no rule
+func (s *Server) SetUnmatchedACLSource(source UnmatchedACLSource) {
+	s.handler.acl = source
+}
+
+// SetEventBus sets the event bus backing the live event stream
+func (s *Server) SetEventBus(bus *events.Bus) {
+	s.handler.events = bus
+}
+
+// SetDefaultACL sets the template rules that CreateMQTTUser applies when
+// the request sets applyDefaultAcl
+func (s *Server) SetDefaultACL(rules []storage.StaticACLRule) {
+	s.handler.defaultACL = rules
+}
+
+// SetProvisionedSet sets the resources the config file provisions, which
+// POST /admin/repair reconciles provisioned flags against
+func (s *Server) SetProvisionedSet(set storage.ProvisionedSet) {
+	s.handler.provisioned = set
+}
+
 // Start starts the HTTP server
 func (s *Server) Start() error {
 	mux := http.NewServeMux()
 
 	// Create authentication middleware with config
-	authMiddleware := NewAuthMiddleware(s.config)
+	authMiddleware := NewAuthMiddleware(s.config, s.handler.revoked)
 
 	// API routes
 	apiMux := http.NewServeMux()
 
 	// Public routes
 	apiMux.HandleFunc("POST /auth/login", s.handler.Login)
+	apiMux.HandleFunc("GET /motd", s.handler.GetMOTD)
+	apiMux.Handle("POST /auth/logout", authMiddleware(http.HandlerFunc(s.handler.Logout)))
+	apiMux.Handle("GET /auth/me", authMiddleware(http.HandlerFunc(s.handler.Me)))
+	apiMux.Handle("POST /auth/stream-token", authMiddleware(http.HandlerFunc(s.handler.StreamToken)))
 
 	// Password change endpoint (any authenticated user can change their own password)
 	apiMux.Handle("PUT /auth/change-password", authMiddleware(http.HandlerFunc(s.handler.ChangePassword)))
@@ -65,13 +135,19 @@ func (s *Server) Start() error {
 	apiMux.Handle("GET /mqtt/users/{id}", authMiddleware(http.HandlerFunc(s.handler.GetMQTTUser)))
 	apiMux.Handle("GET /mqtt/clients", authMiddleware(http.HandlerFunc(s.handler.ListMQTTClients)))
 	apiMux.Handle("GET /mqtt/clients/{client_id}", authMiddleware(http.HandlerFunc(s.handler.GetMQTTClientDetails)))
+	apiMux.Handle("GET /mqtt/sessions/{client_id}", authMiddleware(http.HandlerFunc(s.handler.GetMQTTSession)))
 	apiMux.Handle("GET /acl", authMiddleware(http.HandlerFunc(s.handler.ListACL)))
+	apiMux.Handle("GET /acl/unmatched", authMiddleware(http.HandlerFunc(s.handler.ListUnmatchedACL)))
+	apiMux.Handle("GET /acl/expand", authMiddleware(http.HandlerFunc(s.handler.ExpandACLPattern)))
+	apiMux.Handle("GET /acl/{id}", authMiddleware(http.HandlerFunc(s.handler.GetACL)))
 
 	// Manage MQTT users - admin only
 	apiMux.Handle("POST /mqtt/users", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.CreateMQTTUser))))
 	apiMux.Handle("PUT /mqtt/users/{id}", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.UpdateMQTTUser))))
+	apiMux.Handle("PATCH /mqtt/users/{id}", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.PatchMQTTUser))))
 	apiMux.Handle("PUT /mqtt/users/{id}/password", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.UpdateMQTTUserPassword))))
 	apiMux.Handle("DELETE /mqtt/users/{id}", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.DeleteMQTTUser))))
+	apiMux.Handle("POST /mqtt/users/{id}/acl/copy-from/{sourceId}", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.CopyACL))))
 
 	// Manage MQTT clients - admin only
 	apiMux.Handle("PUT /mqtt/clients/{client_id}/metadata", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.UpdateMQTTClientMetadata))))
@@ -79,6 +155,8 @@ func (s *Server) Start() error {
 
 	// Manage ACL rules - admin only
 	apiMux.Handle("POST /acl", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.CreateACL))))
+	apiMux.Handle("POST /acl/bulk", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.BulkCreateACL))))
+	apiMux.Handle("POST /acl/bulk/delete", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.BulkDeleteACL))))
 	apiMux.Handle("PUT /acl/{id}", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.UpdateACL))))
 	apiMux.Handle("DELETE /acl/{id}", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.DeleteACL))))
 
@@ -89,25 +167,74 @@ func (s *Server) Start() error {
 
 	// Manage bridges - admin only
 	apiMux.Handle("POST /bridges", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.CreateBridge))))
+	apiMux.Handle("POST /bridges/{id}/clone", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.CloneBridge))))
+	apiMux.Handle("POST /bridges/test", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.TestBridgeConnection))))
 	apiMux.Handle("PUT /bridges/{id}", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.UpdateBridge))))
 	apiMux.Handle("DELETE /bridges/{id}", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.DeleteBridge))))
 
+	// === Topic Routes ===
+	// View routes - any authenticated user can view; manage - admin only
+	apiMux.Handle("GET /topic-routes", authMiddleware(http.HandlerFunc(s.handler.ListTopicRoutes)))
+	apiMux.Handle("GET /topic-routes/{id}", authMiddleware(http.HandlerFunc(s.handler.GetTopicRoute)))
+	apiMux.Handle("POST /topic-routes", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.CreateTopicRoute))))
+	apiMux.Handle("PUT /topic-routes/{id}", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.UpdateTopicRoute))))
+	apiMux.Handle("DELETE /topic-routes/{id}", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.DeleteTopicRoute))))
+
 	// === Script Management ===
 	// View scripts and logs - any authenticated user can view
 	apiMux.Handle("GET /scripts", authMiddleware(http.HandlerFunc(s.handler.ListScripts)))
+	apiMux.Handle("GET /scripts/logs", authMiddleware(http.HandlerFunc(s.handler.SearchScriptLogs)))
 	apiMux.Handle("GET /scripts/{id}", authMiddleware(http.HandlerFunc(s.handler.GetScript)))
 	apiMux.Handle("GET /scripts/{id}/logs", authMiddleware(http.HandlerFunc(s.handler.GetScriptLogs)))
+	apiMux.Handle("GET /scripts/{id}/versions", authMiddleware(http.HandlerFunc(s.handler.ListScriptVersions)))
+	apiMux.Handle("GET /scripts/{id}/stats", authMiddleware(http.HandlerFunc(s.handler.GetScriptStats)))
 	apiMux.Handle("GET /scripts/{id}/state", authMiddleware(http.HandlerFunc(s.handler.GetScriptState)))
 
 	// Manage scripts - admin only
 	apiMux.Handle("POST /scripts", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.CreateScript))))
 	apiMux.Handle("PUT /scripts/{id}", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.UpdateScript))))
 	apiMux.Handle("DELETE /scripts/{id}", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.DeleteScript))))
+	apiMux.Handle("POST /scripts/{id}/rollback/{version}", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.RollbackScript))))
+	apiMux.Handle("POST /scripts/{id}/clone", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.CloneScript))))
 	apiMux.Handle("POST /scripts/{id}/enable", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.EnableScript))))
+	apiMux.Handle("PUT /scripts/{id}/triggers/{triggerId}/enable", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.EnableScriptTrigger))))
+	apiMux.Handle("POST /scripts/validate", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.ValidateScript))))
 	apiMux.Handle("POST /scripts/test", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.TestScript))))
 	apiMux.Handle("DELETE /scripts/{id}/logs", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.ClearScriptLogs))))
 	apiMux.Handle("DELETE /scripts/{id}/state/{key}", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.DeleteScriptStateKey))))
 
+	// === Topics ===
+	// View topic hierarchy - any authenticated user can view
+	apiMux.Handle("GET /topics/tree", authMiddleware(http.HandlerFunc(s.handler.GetTopicTree)))
+	apiMux.Handle("GET /topics/last", authMiddleware(http.HandlerFunc(s.handler.GetLastValue)))
+
+	// === Retained Messages ===
+	// Manage retained messages - admin only
+	apiMux.Handle("DELETE /retained/{topic...}", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.DeleteRetainedMessage))))
+
+	// === Subscriptions ===
+	// Broker-wide subscription listing - admin only
+	apiMux.Handle("GET /subscriptions", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.ListSubscriptions))))
+
+	// === Message Recordings ===
+	// View recordings - any authenticated user can view
+	apiMux.Handle("GET /recordings", authMiddleware(http.HandlerFunc(s.handler.ListRecordings)))
+
+	// Replay recordings - admin only
+	apiMux.Handle("POST /recordings/{id}/replay", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.ReplayRecording))))
+
+	// === Maintenance ===
+	// Admin only (compaction is registered below, outside the request timeout)
+	apiMux.Handle("POST /admin/repair", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.RepairProvisionedFlags))))
+	apiMux.Handle("POST /admin/retained/reload", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.ReloadRetainedMessages))))
+	apiMux.Handle("POST /admin/clients/reap", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.ReapInactiveClients))))
+	apiMux.Handle("GET /admin/config", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.GetEffectiveConfig))))
+	apiMux.Handle("GET /admin/settings/motd", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.GetMOTD))))
+	apiMux.Handle("PUT /admin/settings/motd", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.UpdateMOTD))))
+	// Script kill switch - admin only
+	apiMux.Handle("POST /admin/scripts/disable-all", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.DisableAllScripts))))
+	apiMux.Handle("POST /admin/scripts/enable-all", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.EnableAllScripts))))
+
 	// Legacy/deprecated clients endpoint (for backward compatibility)
 	apiMux.Handle("GET /clients", authMiddleware(http.HandlerFunc(s.handler.ListClients)))
 	apiMux.Handle("GET /clients/{id}", authMiddleware(http.HandlerFunc(s.handler.GetClientDetails)))
@@ -115,9 +242,34 @@ func (s *Server) Start() error {
 
 	// Metrics - any authenticated user can view
 	apiMux.Handle("GET /metrics", authMiddleware(http.HandlerFunc(s.handler.GetMetrics)))
+	apiMux.Handle("GET /stats/throughput", authMiddleware(http.HandlerFunc(s.handler.GetThroughput)))
+	apiMux.Handle("GET /summary", authMiddleware(http.HandlerFunc(s.handler.GetSummary)))
+
+	// Mount API under /api with request limits
+	apiHandler := TimeoutMiddleware(s.config.RequestTimeout)(BodyLimitMiddleware(s.config.MaxBodyBytes)(apiMux))
+	// NDJSON list exports stream arbitrarily many rows, so they bypass the request timeout
+	ndjsonMux := http.NewServeMux()
+	ndjsonMux.Handle("GET /mqtt/clients", apiMux)
+	ndjsonMux.Handle("GET /scripts/logs", apiMux)
+	ndjsonMux.Handle("GET /scripts/{id}/logs", apiMux)
+	apiHandler = NDJSONBypassMiddleware(ndjsonMux)(apiHandler)
+	if s.config.EnableGzip {
+		apiHandler = GzipMiddleware(s.config.GzipMinBytes)(apiHandler)
+	}
+	mux.Handle("/api/", http.StripPrefix("/api", apiHandler))
 
-	// Mount API under /api
-	mux.Handle("/api/", http.StripPrefix("/api", apiMux))
+	// Live event streams are long-lived, so they bypass the request timeout and compression
+	// They also accept a stream token in the URL, since browsers can't set headers on them
+	streamAuth := NewStreamAuthMiddleware(s.config, s.handler.revoked)
+	mux.Handle("GET /api/events/stream", streamAuth(http.HandlerFunc(s.handler.StreamEvents)))
+	mux.Handle("GET /api/ws", streamAuth(http.HandlerFunc(s.handler.WebSocket)))
+
+	// Retained message dumps stream arbitrarily large bodies, so they bypass the body limit and timeout
+	mux.Handle("GET /api/retained/export", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.ExportRetainedMessages))))
+	mux.Handle("POST /api/retained/import", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.ImportRetainedMessages))))
+
+	// Compaction rewrites whole stores and can take minutes, so it bypasses the request timeout
+	mux.Handle("POST /api/admin/maintenance/compact", authMiddleware(AdminOnly(http.HandlerFunc(s.handler.CompactStorage))))
 
 	// Health check endpoint (no auth required)
 	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
@@ -151,6 +303,41 @@ func (s *Server) Start() error {
 	// Apply middleware
 	handler := LoggingMiddleware(CORSMiddleware(mux))
 
+	var tlsConfig *tls.Config
+	if s.config.TLSEnabled() {
+		var err error
+		if tlsConfig, err = s.config.TLSConfig(); err != nil {
+			return err
+		}
+	}
+
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	if tlsConfig == nil {
+		return s.serve(ln, handler)
+	}
+
+	if s.config.RedirectAddr != "" {
+		redirectLn, err := net.Listen("tcp", s.config.RedirectAddr)
+		if err != nil {
+			_ = ln.Close()
+			return err
+		}
+		go func() {
+			if err := s.serveRedirect(redirectLn, s.addr); err != nil {
+				slog.Error("HTTP to HTTPS redirect server failed", "error", err)
+			}
+		}()
+	}
+
+	slog.Info("HTTP API TLS enabled", "cert", s.config.TLSCertFile)
+	return s.serve(tls.NewListener(ln, tlsConfig), handler)
+}
+
+// serve runs the HTTP server on the given listener until Shutdown is called
+func (s *Server) serve(ln net.Listener, handler http.Handler) error {
 	// Create server with timeouts to prevent resource exhaustion
 	server := &http.Server{
 		Addr:           s.addr,
@@ -160,9 +347,89 @@ func (s *Server) Start() error {
 		IdleTimeout:    60 * time.Second,
 		MaxHeaderBytes: 1 << 20, // 1 MB
 	}
+	if s.handler != nil {
+		server.RegisterOnShutdown(s.handler.closeStreams)
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = ln.Close()
+		return nil
+	}
+	s.httpServer = server
+	s.mu.Unlock()
+
+	slog.Info("HTTP API server started", "address", ln.Addr().String())
+	if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// serveRedirect runs a plain HTTP server on ln that redirects every request
+// to the HTTPS server listening on httpsAddr
+func (s *Server) serveRedirect(ln net.Listener, httpsAddr string) error {
+	server := &http.Server{
+		Handler:           redirectHandler(httpsAddr),
+		ReadHeaderTimeout: 5 * time.Second,
+		IdleTimeout:       60 * time.Second,
+		MaxHeaderBytes:    1 << 20, // 1 MB
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = ln.Close()
+		return nil
+	}
+	s.redirectServer = server
+	s.mu.Unlock()
+
+	slog.Info("HTTP to HTTPS redirect server started", "address", ln.Addr().String())
+	if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// redirectHandler permanently redirects requests to the same host and path on
+// the port of httpsAddr. 308 keeps the method and body of API calls
+func redirectHandler(httpsAddr string) http.HandlerFunc {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	}
+}
+
+// Shutdown gracefully stops the HTTP server and the redirect server, if any
+// New connections are refused and in-flight requests are allowed to finish until ctx expires
+// Servers that have not started serving yet never will
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	server, redirect := s.httpServer, s.redirectServer
+	s.mu.Unlock()
+
+	if redirect != nil {
+		if err := redirect.Shutdown(ctx); err != nil {
+			slog.Error("Error shutting down redirect server", "error", err)
+		}
+	}
+	if server == nil {
+		return nil
+	}
 
-	slog.Info("HTTP API server started", "address", s.addr)
-	return server.ListenAndServe()
+	return server.Shutdown(ctx)
 }
 
 // spaHandler serves the Single Page Application with fallback to index.html
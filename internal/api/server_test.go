@@ -0,0 +1,291 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github/bromq-dev/bromq/internal/events"
+)
+
+func TestServerShutdown_DrainsActiveRequests(t *testing.T) {
+	server := &Server{}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.serve(ln, handler)
+	}()
+
+	// Start a slow request
+	respCh := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/")
+		if err != nil {
+			t.Errorf("Request failed: %v", err)
+			respCh <- nil
+			return
+		}
+		respCh <- resp
+	}()
+	<-started
+
+	shutdownDone := make(chan error, 1)
+	go func() {
+		shutdownDone <- server.Shutdown(context.Background())
+	}()
+
+	// Shutdown must wait for the in-flight request
+	select {
+	case err := <-shutdownDone:
+		t.Fatalf("Shutdown() returned before active request completed: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+
+	select {
+	case err := <-shutdownDone:
+		if err != nil {
+			t.Errorf("Shutdown() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown() did not return after active request completed")
+	}
+
+	if resp := <-respCh; resp != nil {
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("in-flight request status = %v, want %v", resp.StatusCode, http.StatusOK)
+		}
+	}
+
+	if err := <-serveErr; err != nil {
+		t.Errorf("serve() error = %v, want nil after Shutdown", err)
+	}
+}
+
+func TestServerShutdown_NotStarted(t *testing.T) {
+	server := &Server{}
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() error = %v, want nil", err)
+	}
+
+	// A server that starts after Shutdown must not serve
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.serve(ln, http.NotFoundHandler())
+	}()
+
+	select {
+	case err := <-serveErr:
+		if err != nil {
+			t.Errorf("serve() after Shutdown error = %v, want nil", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("serve() after Shutdown did not return")
+	}
+	if _, err := net.Dial("tcp", ln.Addr().String()); err == nil {
+		t.Error("listener still accepting after Shutdown")
+	}
+}
+
+func TestServerShutdown_EndsStreams(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.events = events.NewBus()
+	server := &Server{handler: handler}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go func() { _ = server.serve(ln, http.HandlerFunc(handler.StreamEvents)) }()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/")
+	if err != nil {
+		t.Fatalf("Failed to connect to stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// The open stream must not hold Shutdown until its deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Shutdown() took %v with an open stream", elapsed)
+	}
+}
+
+// writeSelfSignedCert writes a self-signed certificate for 127.0.0.1 and its
+// key to a temp dir, returning the cert pool trusting it and the file paths
+func writeSelfSignedCert(t *testing.T) (pool *x509.CertPool, certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "bromq test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+	return pool, certFile, keyFile
+}
+
+func TestServerServe_TLS(t *testing.T) {
+	pool, certFile, keyFile := writeSelfSignedCert(t)
+	config := &Config{TLSCertFile: certFile, TLSKeyFile: keyFile}
+	if !config.TLSEnabled() {
+		t.Fatal("TLSEnabled() = false with cert and key set")
+	}
+	tlsConfig, err := config.TLSConfig()
+	if err != nil {
+		t.Fatalf("TLSConfig() error = %v", err)
+	}
+
+	server := &Server{config: config}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	go func() { _ = server.serve(tls.NewListener(ln, tlsConfig), handler) }()
+	t.Cleanup(func() { _ = server.Shutdown(context.Background()) })
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := client.Get("https://" + ln.Addr().String() + "/health")
+	if err != nil {
+		t.Fatalf("HTTPS request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("HTTPS status = %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		t.Error("response was not served over TLS")
+	}
+
+	// Plain HTTP on the TLS port is refused
+	if resp, err := http.Get("http://" + ln.Addr().String() + "/health"); err == nil {
+		_ = resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			t.Error("plain HTTP request to the TLS listener succeeded")
+		}
+	}
+}
+
+func TestConfigTLS_Invalid(t *testing.T) {
+	_, certFile, keyFile := writeSelfSignedCert(t)
+
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{"cert without key", Config{JWTSecret: "secret", TLSCertFile: certFile}},
+		{"key without cert", Config{JWTSecret: "secret", TLSKeyFile: keyFile}},
+		{"redirect without TLS", Config{JWTSecret: "secret", RedirectAddr: ":80"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.PostParse(); err == nil {
+				t.Error("PostParse() error = nil, want error")
+			}
+		})
+	}
+
+	config := Config{TLSCertFile: keyFile, TLSKeyFile: certFile}
+	if _, err := config.TLSConfig(); err == nil {
+		t.Error("TLSConfig() with swapped files error = nil, want error")
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		httpsAddr string
+		host      string
+		target    string
+		want      string
+	}{
+		{"custom port", ":8443", "broker.local:8080", "/api/clients?page=2", "https://broker.local:8443/api/clients?page=2"},
+		{"default port omitted", ":443", "broker.local", "/", "https://broker.local/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.target, nil)
+			req.Host = tt.host
+			rec := httptest.NewRecorder()
+			redirectHandler(tt.httpsAddr).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusPermanentRedirect {
+				t.Errorf("status = %v, want %v", rec.Code, http.StatusPermanentRedirect)
+			}
+			if got := rec.Header().Get("Location"); got != tt.want {
+				t.Errorf("Location = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
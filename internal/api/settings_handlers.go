@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+// maxMOTDLength caps the banner message, in characters
+const maxMOTDLength = 1000
+
+// MOTDResponse is the dashboard banner message (empty when none is set)
+type MOTDResponse struct {
+	Message string `json:"message"`
+}
+
+// UpdateMOTDRequest sets the dashboard banner message; an empty message removes the banner
+type UpdateMOTDRequest struct {
+	Message string `json:"message"`
+}
+
+// GetMOTD godoc
+// @Summary Get the dashboard banner
+// @Description Get the message of the day shown as a banner in the dashboard and on the login page. Public, so it can be shown before signing in
+// @Tags Settings
+// @Produce json
+// @Success 200 {object} MOTDResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /motd [get]
+// @Router /admin/settings/motd [get]
+func (h *Handler) GetMOTD(w http.ResponseWriter, r *http.Request) {
+	message, err := h.db.Settings().GetString(storage.SettingMOTD, "")
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to load motd: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(MOTDResponse{Message: message})
+}
+
+// UpdateMOTD godoc
+// @Summary Set the dashboard banner
+// @Description Set the message of the day, e.g. a maintenance notice. An empty message removes the banner
+// @Tags Settings
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param motd body UpdateMOTDRequest true "Banner message"
+// @Success 200 {object} MOTDResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/settings/motd [put]
+func (h *Handler) UpdateMOTD(w http.ResponseWriter, r *http.Request) {
+	var req UpdateMOTDRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid request: %s"}`, err), http.StatusBadRequest)
+		return
+	}
+	if utf8.RuneCountInString(req.Message) > maxMOTDLength {
+		http.Error(w, fmt.Sprintf(`{"error":"message must be at most %d characters"}`, maxMOTDLength), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.Settings().SetString(storage.SettingMOTD, req.Message); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to save motd: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+	LoggerFromContext(r.Context()).Info("MOTD updated", "length", len(req.Message))
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(MOTDResponse{Message: req.Message})
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMOTD(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	get := func() MOTDResponse {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/motd", nil)
+		rec := httptest.NewRecorder()
+		handler.GetMOTD(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GetMOTD() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		var response MOTDResponse
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return response
+	}
+	put := func(message string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(UpdateMOTDRequest{Message: message})
+		req := httptest.NewRequest(http.MethodPut, "/api/admin/settings/motd", bytes.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler.UpdateMOTD(rec, req)
+		return rec
+	}
+
+	if got := get(); got.Message != "" {
+		t.Errorf("GetMOTD() before any update = %q, want empty", got.Message)
+	}
+
+	if rec := put("Maintenance tonight at 22:00 UTC"); rec.Code != http.StatusOK {
+		t.Fatalf("UpdateMOTD() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if got := get(); got.Message != "Maintenance tonight at 22:00 UTC" {
+		t.Errorf("GetMOTD() = %q, want the message just set", got.Message)
+	}
+
+	// Replacing it with an empty message removes the banner
+	if rec := put(""); rec.Code != http.StatusOK {
+		t.Fatalf("UpdateMOTD(empty) status = %v, want %v", rec.Code, http.StatusOK)
+	}
+	if got := get(); got.Message != "" {
+		t.Errorf("GetMOTD() after clearing = %q, want empty", got.Message)
+	}
+
+	if rec := put(strings.Repeat("x", maxMOTDLength+1)); rec.Code != http.StatusBadRequest {
+		t.Errorf("UpdateMOTD(too long) status = %v, want %v", rec.Code, http.StatusBadRequest)
+	}
+}
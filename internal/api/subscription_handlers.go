@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github/bromq-dev/bromq/internal/mqtt"
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+// ListSubscriptions godoc
+// @Summary List active subscriptions
+// @Description Get every subscribed topic filter across all client sessions with its subscribers. Use search to filter by substring or topic to find the filters a concrete topic would be delivered to
+// @Tags Clients
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param pageSize query int false "Items per page" default(25)
+// @Param search query string false "Only filters containing this text"
+// @Param topic query string false "Only filters matching this topic, e.g. sensors/1/temp"
+// @Param sortBy query string false "Sort field (filter, subscribers)" default(filter)
+// @Param sortOrder query string false "Sort order (asc/desc)" default(asc)
+// @Success 200 {object} PaginatedResponse{data=[]mqtt.FilterSubscriptions}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 503 {object} ErrorResponse "MQTT server not available"
+// @Router /subscriptions [get]
+func (h *Handler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	if h.mqtt == nil {
+		http.Error(w, `{"error":"MQTT server not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	params := h.parsePaginationParams(r)
+	topic := r.URL.Query().Get("topic")
+
+	subscriptions := make([]mqtt.FilterSubscriptions, 0)
+	for _, sub := range h.mqtt.Subscriptions() {
+		if params.Search != "" && !strings.Contains(sub.Filter, params.Search) {
+			continue
+		}
+		if topic != "" && !storage.MatchTopic(sub.Filter, topic) {
+			continue
+		}
+		subscriptions = append(subscriptions, sub)
+	}
+
+	// Subscriptions() is sorted by filter; re-sort only when asked
+	if params.SortBy == "subscribers" {
+		sort.SliceStable(subscriptions, func(i, j int) bool {
+			return subscriptions[i].Subscribers < subscriptions[j].Subscribers
+		})
+	}
+	if r.URL.Query().Get("sortOrder") == "desc" {
+		for i, j := 0, len(subscriptions)-1; i < j; i, j = i+1, j-1 {
+			subscriptions[i], subscriptions[j] = subscriptions[j], subscriptions[i]
+		}
+	}
+
+	total := len(subscriptions)
+	start := min((params.Page-1)*params.PageSize, total)
+	end := min(start+params.PageSize, total)
+
+	response := PaginatedResponse{
+		Data: subscriptions[start:end],
+		Pagination: PaginationMetadata{
+			Total:      int64(total),
+			Page:       params.Page,
+			PageSize:   params.PageSize,
+			TotalPages: int(math.Ceil(float64(total) / float64(params.PageSize))),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
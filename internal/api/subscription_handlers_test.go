@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github/bromq-dev/bromq/internal/mqtt"
+)
+
+func TestListSubscriptions(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.mqtt = mqtt.New(nil)
+
+	seed := map[string][]string{
+		"sensor-1": {"sensors/#", "alerts/+"},
+		"sensor-2": {"sensors/#"},
+	}
+	for clientID, filters := range seed {
+		cl := handler.mqtt.NewClient(nil, mqtt.ListenerTCP, clientID, false)
+		cl.Properties.Username = []byte("device")
+		for _, filter := range filters {
+			cl.State.Subscriptions.Add(filter, packets.Subscription{Filter: filter, Qos: 1})
+		}
+		handler.mqtt.Clients.Add(cl)
+	}
+
+	list := func(query string) []mqtt.FilterSubscriptions {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/subscriptions"+query, nil)
+		rec := httptest.NewRecorder()
+		handler.ListSubscriptions(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("ListSubscriptions(%q) status = %v: %s", query, rec.Code, rec.Body.String())
+		}
+		var response struct {
+			Data       []mqtt.FilterSubscriptions `json:"data"`
+			Pagination PaginationMetadata         `json:"pagination"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return response.Data
+	}
+
+	all := list("")
+	if len(all) != 2 {
+		t.Fatalf("subscriptions = %+v, want 2 filters", all)
+	}
+	if all[0].Filter != "alerts/+" || all[0].Subscribers != 1 {
+		t.Errorf("first = %+v, want alerts/+ with 1 subscriber", all[0])
+	}
+	if all[1].Filter != "sensors/#" || all[1].Subscribers != 2 ||
+		all[1].Clients[0].ClientID != "sensor-1" || all[1].Clients[1].ClientID != "sensor-2" {
+		t.Errorf("second = %+v, want sensors/# with sensor-1 and sensor-2", all[1])
+	}
+
+	if got := list("?topic=sensors/1/temp"); len(got) != 1 || got[0].Filter != "sensors/#" {
+		t.Errorf("topic search = %+v, want only sensors/#", got)
+	}
+	if got := list("?search=alerts"); len(got) != 1 || got[0].Filter != "alerts/+" {
+		t.Errorf("text search = %+v, want only alerts/+", got)
+	}
+	if got := list("?sortBy=subscribers&sortOrder=desc&pageSize=1"); len(got) != 1 || got[0].Filter != "sensors/#" {
+		t.Errorf("first page by subscribers = %+v, want sensors/#", got)
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// GetTopicTree godoc
+// @Summary Get topic tree
+// @Description Get a nested tree of retained topics (and optionally recently published topics) with message counts per level
+// @Tags Topics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param includeRecent query bool false "Include recently published topics"
+// @Success 200 {object} TopicNode
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /topics/tree [get]
+func (h *Handler) GetTopicTree(w http.ResponseWriter, r *http.Request) {
+	retained, err := h.retained.GetAllRetainedMessages()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to load retained messages: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	root := &TopicNode{}
+	for _, msg := range retained {
+		node := addTopicToTree(root, msg.Topic, 1)
+		node.Retained = true
+	}
+
+	if r.URL.Query().Get("includeRecent") == "true" && h.topics != nil {
+		for topic, count := range h.topics.RecentTopics() {
+			addTopicToTree(root, topic, count)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(root)
+}
+
+// GetLastValue godoc
+// @Summary Get last value of a topic
+// @Description Get the most recent message published to a topic, retained or not (requires LAST_VALUE_CACHE). The payload is base64-encoded
+// @Tags Topics
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param topic query string true "Topic name (no wildcards)"
+// @Success 200 {object} metrics.LastValue
+// @Failure 400 {object} ErrorResponse "Missing topic"
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse "No value cached for the topic"
+// @Failure 503 {object} ErrorResponse "Last value cache disabled"
+// @Router /topics/last [get]
+func (h *Handler) GetLastValue(w http.ResponseWriter, r *http.Request) {
+	if h.lastValues == nil {
+		http.Error(w, `{"error":"last value cache is disabled (set LAST_VALUE_CACHE=true)"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	topic := r.URL.Query().Get("topic")
+	if topic == "" {
+		http.Error(w, `{"error":"topic is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	value, ok := h.lastValues.Get(topic)
+	if !ok {
+		http.Error(w, `{"error":"no value cached for topic"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// addTopicToTree adds count to every node along the topic's path and returns the leaf node
+func addTopicToTree(root *TopicNode, topic string, count int64) *TopicNode {
+	node := root
+	node.Count += count
+
+	for _, level := range strings.Split(topic, "/") {
+		if node.Children == nil {
+			node.Children = make(map[string]*TopicNode)
+		}
+
+		child, ok := node.Children[level]
+		if !ok {
+			child = &TopicNode{}
+			node.Children[level] = child
+		}
+
+		child.Count += count
+		node = child
+	}
+
+	return node
+}
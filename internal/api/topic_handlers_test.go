@@ -0,0 +1,158 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github/bromq-dev/bromq/hooks/metrics"
+	"github/bromq-dev/bromq/internal/mqtt"
+)
+
+// mockTopicSource implements RecentTopicSource for testing
+type mockTopicSource map[string]int64
+
+func (m mockTopicSource) RecentTopics() map[string]int64 {
+	return m
+}
+
+func TestGetTopicTree(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.topics = mockTopicSource{"a/b/d": 3, "x": 2}
+
+	if err := handler.badger.SaveRetainedMessage("a/b/c", []byte("1"), 0); err != nil {
+		t.Fatalf("Failed to save retained message: %v", err)
+	}
+	if err := handler.badger.SaveRetainedMessage("a/b/d", []byte("2"), 0); err != nil {
+		t.Fatalf("Failed to save retained message: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		query         string
+		wantRootCount int64
+		wantABCount   int64
+		wantDCount    int64
+		wantX         bool
+	}{
+		{
+			name:          "retained only",
+			query:         "",
+			wantRootCount: 2,
+			wantABCount:   2,
+			wantDCount:    1,
+			wantX:         false,
+		},
+		{
+			name:          "include recent topics",
+			query:         "?includeRecent=true",
+			wantRootCount: 7,
+			wantABCount:   5,
+			wantDCount:    4,
+			wantX:         true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/topics/tree"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			handler.GetTopicTree(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("GetTopicTree() status = %v, want %v", rec.Code, http.StatusOK)
+			}
+
+			var root TopicNode
+			if err := json.NewDecoder(rec.Body).Decode(&root); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if root.Count != tt.wantRootCount {
+				t.Errorf("root count = %v, want %v", root.Count, tt.wantRootCount)
+			}
+
+			a, ok := root.Children["a"]
+			if !ok {
+				t.Fatalf("expected node 'a' at root, got %v", root.Children)
+			}
+			ab, ok := a.Children["b"]
+			if !ok {
+				t.Fatalf("expected node 'b' under 'a', got %v", a.Children)
+			}
+			if ab.Count != tt.wantABCount {
+				t.Errorf("a/b count = %v, want %v", ab.Count, tt.wantABCount)
+			}
+			if len(ab.Children) != 2 {
+				t.Fatalf("expected 2 children under a/b, got %d", len(ab.Children))
+			}
+
+			c, ok := ab.Children["c"]
+			if !ok || !c.Retained {
+				t.Errorf("expected retained node 'c' under a/b")
+			}
+			d, ok := ab.Children["d"]
+			if !ok || !d.Retained {
+				t.Fatalf("expected retained node 'd' under a/b")
+			}
+			if d.Count != tt.wantDCount {
+				t.Errorf("a/b/d count = %v, want %v", d.Count, tt.wantDCount)
+			}
+
+			if _, ok := root.Children["x"]; ok != tt.wantX {
+				t.Errorf("node 'x' present = %v, want %v", ok, tt.wantX)
+			}
+		})
+	}
+}
+
+func TestGetLastValue(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	get := func(topic string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/topics/last?topic="+url.QueryEscape(topic), nil)
+		rec := httptest.NewRecorder()
+		handler.GetLastValue(rec, req)
+		return rec
+	}
+
+	// Disabled until a cache is configured
+	if rec := get("sensors/temp"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("GetLastValue() without cache status = %v, want %v", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	handler.mqtt = mqtt.New(mqtt.DefaultConfig())
+	hook := metrics.NewMetricsHook(nil)
+	hook.SetLastValues(metrics.NewLastValueCache(metrics.Config{LastValueCache: true}))
+	if err := handler.mqtt.AddHook(hook, nil); err != nil {
+		t.Fatalf("Failed to add metrics hook: %v", err)
+	}
+	handler.lastValues = hook.LastValues()
+
+	// Not retained, so only the last value cache knows about it
+	if err := handler.mqtt.Publish("sensors/temp", []byte("21.5"), false, 1); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+
+	rec := get("sensors/temp")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GetLastValue() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var value metrics.LastValue
+	if err := json.NewDecoder(rec.Body).Decode(&value); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if value.Topic != "sensors/temp" || string(value.Payload) != "21.5" || value.QoS != 1 || value.Retain {
+		t.Errorf("last value = %+v", value)
+	}
+
+	if rec := get("sensors/humidity"); rec.Code != http.StatusNotFound {
+		t.Errorf("GetLastValue() unknown topic status = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+	if rec := get(""); rec.Code != http.StatusBadRequest {
+		t.Errorf("GetLastValue() missing topic status = %v, want %v", rec.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+// === Topic Route Handlers ===
+
+// ListTopicRoutes godoc
+// @Summary List topic routes
+// @Description Get all local republish rules. A publish on a topic matching a route's source is republished to its target
+// @Tags Topic Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} storage.TopicRoute
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /topic-routes [get]
+func (h *Handler) ListTopicRoutes(w http.ResponseWriter, r *http.Request) {
+	routes, err := h.db.ListTopicRoutes()
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to list topic routes: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	// Ensure we return empty array instead of null
+	if routes == nil {
+		routes = []storage.TopicRoute{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(routes)
+}
+
+// GetTopicRoute godoc
+// @Summary Get topic route
+// @Description Get a single topic route by ID
+// @Tags Topic Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Topic route ID"
+// @Success 200 {object} storage.TopicRoute
+// @Failure 400 {object} ErrorResponse "Invalid topic route ID"
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse "Topic route not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /topic-routes/{id} [get]
+func (h *Handler) GetTopicRoute(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid topic route ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	route, err := h.db.GetTopicRoute(uint(id))
+	if errors.Is(err, storage.ErrTopicRouteNotFound) {
+		http.Error(w, `{"error":"topic route not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to load topic route: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(route)
+}
+
+// CreateTopicRoute godoc
+// @Summary Create topic route
+// @Description Republish messages from a source topic filter to a target, e.g. legacy/# to v2/#. Each + in the target takes the level matched by the corresponding + in the source and a trailing # the levels matched by the source's #. Republished messages are not routed again, so routes can't loop
+// @Tags Topic Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param route body TopicRouteRequest true "Source and target"
+// @Success 201 {object} storage.TopicRoute
+// @Failure 400 {object} ErrorResponse "Invalid request or route"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Router /topic-routes [post]
+func (h *Handler) CreateTopicRoute(w http.ResponseWriter, r *http.Request) {
+	var req TopicRouteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid request: %s"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	route, err := h.db.CreateTopicRoute(req.Source, req.Target, req.Enabled == nil || *req.Enabled)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to create topic route: %s"}`, err), http.StatusBadRequest)
+		return
+	}
+	h.reloadTopicRoutes(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(route)
+}
+
+// UpdateTopicRoute godoc
+// @Summary Update topic route
+// @Description Replace a topic route's source, target and enabled flag
+// @Tags Topic Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Topic route ID"
+// @Param route body TopicRouteRequest true "Source and target"
+// @Success 200 {object} storage.TopicRoute
+// @Failure 400 {object} ErrorResponse "Invalid request or route"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 404 {object} ErrorResponse "Topic route not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /topic-routes/{id} [put]
+func (h *Handler) UpdateTopicRoute(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid topic route ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req TopicRouteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid request: %s"}`, err), http.StatusBadRequest)
+		return
+	}
+	if err := storage.ValidateTopicRoute(req.Source, req.Target); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err), http.StatusBadRequest)
+		return
+	}
+
+	route, err := h.db.UpdateTopicRoute(uint(id), req.Source, req.Target, req.Enabled == nil || *req.Enabled)
+	if errors.Is(err, storage.ErrTopicRouteNotFound) {
+		http.Error(w, `{"error":"topic route not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to update topic route: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+	h.reloadTopicRoutes(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(route)
+}
+
+// DeleteTopicRoute godoc
+// @Summary Delete topic route
+// @Description Delete a topic route
+// @Tags Topic Routes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Topic route ID"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse "Invalid topic route ID"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Admin only"
+// @Failure 404 {object} ErrorResponse "Topic route not found"
+// @Failure 500 {object} ErrorResponse
+// @Router /topic-routes/{id} [delete]
+func (h *Handler) DeleteTopicRoute(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 32)
+	if err != nil {
+		http.Error(w, `{"error":"invalid topic route ID"}`, http.StatusBadRequest)
+		return
+	}
+
+	err = h.db.DeleteTopicRoute(uint(id))
+	if errors.Is(err, storage.ErrTopicRouteNotFound) {
+		http.Error(w, `{"error":"topic route not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"failed to delete topic route: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+	h.reloadTopicRoutes(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(SuccessResponse{Message: "topic route deleted successfully"})
+}
+
+// reloadTopicRoutes applies changed routes to the broker
+func (h *Handler) reloadTopicRoutes(r *http.Request) {
+	if h.routes == nil {
+		return
+	}
+	if err := h.routes.Reload(); err != nil {
+		LoggerFromContext(r.Context()).Error("Failed to reload topic routes", "error", err)
+	}
+}
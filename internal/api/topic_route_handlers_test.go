@@ -0,0 +1,85 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+// countingReloader counts topic route reloads
+type countingReloader struct{ reloads int }
+
+func (c *countingReloader) Reload() error {
+	c.reloads++
+	return nil
+}
+
+func TestTopicRouteHandlers(t *testing.T) {
+	handler := setupTestHandler(t)
+	reloader := &countingReloader{}
+	handler.routes = reloader
+
+	send := func(method, id string, req TopicRouteRequest, fn http.HandlerFunc) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		r := httptest.NewRequest(method, "/api/topic-routes", bytes.NewReader(body))
+		if id != "" {
+			r.SetPathValue("id", id)
+		}
+		rec := httptest.NewRecorder()
+		fn(rec, r)
+		return rec
+	}
+
+	rec := send(http.MethodPost, "", TopicRouteRequest{Source: "legacy/#", Target: "v2/#"}, handler.CreateTopicRoute)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("CreateTopicRoute() status = %v, want %v: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+	var created storage.TopicRoute
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !created.Enabled {
+		t.Error("CreateTopicRoute() without enabled created a disabled route")
+	}
+	id := strconv.FormatUint(uint64(created.ID), 10)
+
+	if rec := send(http.MethodPost, "", TopicRouteRequest{Source: "sensors/+", Target: "rooms/#"}, handler.CreateTopicRoute); rec.Code != http.StatusBadRequest {
+		t.Errorf("CreateTopicRoute(invalid target) status = %v, want %v", rec.Code, http.StatusBadRequest)
+	}
+
+	disabled := false
+	rec = send(http.MethodPut, id, TopicRouteRequest{Source: "legacy/#", Target: "v3/#", Enabled: &disabled}, handler.UpdateTopicRoute)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("UpdateTopicRoute() status = %v, want %v: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if rec := send(http.MethodPut, "999", TopicRouteRequest{Source: "a/#", Target: "b/#"}, handler.UpdateTopicRoute); rec.Code != http.StatusNotFound {
+		t.Errorf("UpdateTopicRoute(missing) status = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ListTopicRoutes(rec, httptest.NewRequest(http.MethodGet, "/api/topic-routes", nil))
+	var routes []storage.TopicRoute
+	if err := json.NewDecoder(rec.Body).Decode(&routes); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(routes) != 1 || routes[0].Target != "v3/#" || routes[0].Enabled {
+		t.Errorf("ListTopicRoutes() = %+v, want the updated, disabled route", routes)
+	}
+
+	if rec := send(http.MethodDelete, id, TopicRouteRequest{}, handler.DeleteTopicRoute); rec.Code != http.StatusOK {
+		t.Errorf("DeleteTopicRoute() status = %v, want %v", rec.Code, http.StatusOK)
+	}
+	if rec := send(http.MethodDelete, id, TopicRouteRequest{}, handler.DeleteTopicRoute); rec.Code != http.StatusNotFound {
+		t.Errorf("DeleteTopicRoute(deleted) status = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+
+	// Only the successful create, update and delete reload the routes
+	if reloader.reloads != 3 {
+		t.Errorf("routes reloaded %d times, want 3", reloader.reloads)
+	}
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github/bromq-dev/bromq/internal/events"
+)
+
+const (
+	wsPingInterval = 30 * time.Second
+	wsPongWait     = 60 * time.Second
+	wsWriteWait    = 10 * time.Second
+)
+
+// wsResource describes which bus events produce deltas for a resource
+type wsResource struct {
+	dataKey string            // Event data key holding the changed record
+	ops     map[string]string // Event type -> delta operation
+}
+
+// wsResources lists the resources WebSocket clients can subscribe to
+var wsResources = map[string]wsResource{
+	"clients": {
+		dataKey: "client",
+		ops: map[string]string{
+			events.ClientAdded:   "add",
+			events.ClientUpdated: "update",
+			events.ClientRemoved: "remove",
+		},
+	},
+}
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Same-origin is not enforced, matching the API's open CORS policy (auth is via bearer token)
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// WebSocket godoc
+// @Summary Live resource deltas over WebSocket
+// @Description Bidirectional stream of incremental resource changes. Send {"action":"subscribe","resource":"clients"} to receive add/update/remove deltas for the client table. Every delta carries the full client record: as it is after an add or update, or as it was before a remove
+// @Tags Events
+// @Security BearerAuth
+// @Param access_token query string false "Stream token from /auth/stream-token, for clients that can't set the Authorization header"
+// @Success 101 {string} string "Switching Protocols"
+// @Failure 401 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse "Event stream not available"
+// @Router /ws [get]
+func (h *Handler) WebSocket(w http.ResponseWriter, r *http.Request) {
+	if h.events == nil {
+		http.Error(w, `{"error":"event stream not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade already wrote an error response
+		LoggerFromContext(r.Context()).Debug("WebSocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	var eventTypes []string
+	for _, res := range wsResources {
+		for eventType := range res.ops {
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+	sub := h.events.Subscribe(eventTypes...)
+	defer sub.Close()
+
+	// Reader goroutine: handles pongs and forwards client requests to the writer loop
+	requests := make(chan WSRequest)
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+
+		_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		})
+
+		for {
+			var req WSRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			select {
+			case requests <- req:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	subscribed := make(map[string]bool)
+
+	// Writer loop: the only goroutine that writes to the connection
+	for {
+		var msg *WSMessage
+
+		select {
+		case <-readerDone:
+			return
+		case <-h.streamsDone:
+			_ = conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(wsWriteWait))
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+			continue
+		case req := <-requests:
+			msg = handleWSRequest(req, subscribed)
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			msg = wsDelta(event, subscribed)
+		}
+
+		if msg == nil {
+			continue
+		}
+
+		_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		if err := conn.WriteJSON(msg); err != nil {
+			return
+		}
+	}
+}
+
+// handleWSRequest applies a subscribe/unsubscribe request and returns the reply
+func handleWSRequest(req WSRequest, subscribed map[string]bool) *WSMessage {
+	if _, ok := wsResources[req.Resource]; !ok {
+		return &WSMessage{Type: "error", Resource: req.Resource, Error: "unknown resource"}
+	}
+
+	switch req.Action {
+	case "subscribe":
+		subscribed[req.Resource] = true
+		return &WSMessage{Type: "subscribed", Resource: req.Resource}
+	case "unsubscribe":
+		delete(subscribed, req.Resource)
+		return &WSMessage{Type: "unsubscribed", Resource: req.Resource}
+	default:
+		return &WSMessage{Type: "error", Resource: req.Resource, Error: "unknown action"}
+	}
+}
+
+// wsDelta converts a bus event into a delta for a subscribed resource (nil if not subscribed)
+func wsDelta(event events.Event, subscribed map[string]bool) *WSMessage {
+	for resource, res := range wsResources {
+		op, ok := res.ops[event.Type]
+		if !ok || !subscribed[resource] {
+			continue
+		}
+		return &WSMessage{
+			Type:     "delta",
+			Resource: resource,
+			Op:       op,
+			Data:     event.Data[res.dataKey],
+		}
+	}
+	return nil
+}
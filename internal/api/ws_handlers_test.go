@@ -0,0 +1,103 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github/bromq-dev/bromq/internal/events"
+)
+
+func TestWebSocket_ClientDeltas(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.events = events.NewBus()
+	handler.db.SetEventBus(handler.events)
+
+	server := httptest.NewServer(http.HandlerFunc(handler.WebSocket))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to dial WebSocket: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	if err := conn.WriteJSON(WSRequest{Action: "subscribe", Resource: "clients"}); err != nil {
+		t.Fatalf("Failed to send subscribe: %v", err)
+	}
+
+	var ack WSMessage
+	if err := conn.ReadJSON(&ack); err != nil {
+		t.Fatalf("Failed to read subscribe ack: %v", err)
+	}
+	if ack.Type != "subscribed" || ack.Resource != "clients" {
+		t.Fatalf("ack = %+v, want subscribed to clients", ack)
+	}
+
+	mqttUser, err := handler.db.CreateMQTTUser("wsdevice", "password123", "Test", nil)
+	if err != nil {
+		t.Fatalf("Failed to create MQTT user: %v", err)
+	}
+
+	// First upsert adds the client, second updates it, then it is deleted
+	// Every delta carries the full record
+	var clientID uint
+	for _, wantOp := range []string{"add", "update", "remove"} {
+		if wantOp == "remove" {
+			if _, err := handler.db.DeleteMQTTClient(clientID); err != nil {
+				t.Fatalf("DeleteMQTTClient() error = %v", err)
+			}
+		} else {
+			client, err := handler.db.UpsertMQTTClient("ws-client-1", mqttUser.ID, nil)
+			if err != nil {
+				t.Fatalf("UpsertMQTTClient() error = %v", err)
+			}
+			clientID = client.ID
+		}
+
+		var delta WSMessage
+		if err := conn.ReadJSON(&delta); err != nil {
+			t.Fatalf("Failed to read delta: %v", err)
+		}
+		if delta.Type != "delta" || delta.Resource != "clients" || delta.Op != wantOp {
+			t.Errorf("delta = %+v, want %s delta for clients", delta, wantOp)
+		}
+
+		data, ok := delta.Data.(map[string]interface{})
+		if !ok || data["client_id"] != "ws-client-1" {
+			t.Errorf("delta data = %v, want client_id ws-client-1", delta.Data)
+		}
+	}
+}
+
+func TestWebSocket_UnknownResource(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.events = events.NewBus()
+
+	server := httptest.NewServer(http.HandlerFunc(handler.WebSocket))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to dial WebSocket: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	if err := conn.WriteJSON(WSRequest{Action: "subscribe", Resource: "unknown"}); err != nil {
+		t.Fatalf("Failed to send subscribe: %v", err)
+	}
+
+	var reply WSMessage
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatalf("Failed to read reply: %v", err)
+	}
+	if reply.Type != "error" {
+		t.Errorf("reply type = %s, want error", reply.Type)
+	}
+}
@@ -1,20 +1,34 @@
 package appconfig
 
 import (
+	"fmt"
+
+	"github/bromq-dev/bromq/hooks/bridge"
+	"github/bromq-dev/bromq/hooks/metrics"
+	"github/bromq-dev/bromq/hooks/recording"
+	"github/bromq-dev/bromq/hooks/tracking"
 	"github/bromq-dev/bromq/internal/api"
+	"github/bromq-dev/bromq/internal/config"
 	"github/bromq-dev/bromq/internal/mqtt"
+	"github/bromq-dev/bromq/internal/script"
 	"github/bromq-dev/bromq/internal/storage"
 )
 
 // Config holds all application configuration
 type Config struct {
-	Version    bool   `flag:"version,v" desc:"Show version and exit"`
-	ConfigFile string `env:"CONFIG_FILE" flag:"config,c" desc:"Path to YAML configuration file for provisioning"`
+	Version      bool   `flag:"version,v" desc:"Show version and exit"`
+	ConfigFile   string `env:"CONFIG_FILE" flag:"config,c" desc:"Path to YAML configuration file for provisioning, or a directory whose *.yml files are merged"`
+	ValidateOnly bool   `flag:"validate-config" desc:"Validate the configuration file (including script files) and exit with a non-zero code on problems, without starting the broker"`
 
 	Database   storage.DatabaseConfig `desc:"Database connection settings"`
 	BadgerPath string                 `env:"BADGER_PATH" flag:"badger-path" default:"badger" desc:"BadgerDB data directory for high-write data (script state, retained messages)"`
 	MQTT       mqtt.Config            `desc:"MQTT broker settings"`
 	API        api.Config             `desc:"HTTP API server settings"`
+	Tracking   tracking.Config        `desc:"Client tracking settings"`
+	Metrics    metrics.Config         `desc:"Topic metrics and last value cache settings"`
+	Recording  recording.Config       `desc:"Message recording settings"`
+	Bridge     bridge.Config          `desc:"Bridge settings"`
+	Script     script.Config          `desc:"Script engine settings"`
 	Logging    LogConfig              `desc:"Logging settings"`
 	Admin      AdminConfig            `desc:"Default admin credentials (only used on first run)"`
 }
@@ -27,17 +41,32 @@ type LogConfig struct {
 
 // AdminConfig holds default admin credentials (only used on first database initialization)
 type AdminConfig struct {
-	Username string `env:"ADMIN_USERNAME" flag:"admin-username" default:"admin" desc:"Default admin username (only used on first run)"`
-	Password string `env:"ADMIN_PASSWORD" flag:"admin-password" default:"admin" desc:"Default admin password (only used on first run)"`
+	Username     string `env:"ADMIN_USERNAME" flag:"admin-username" default:"admin" desc:"Default admin username (only used on first run)"`
+	Password     string `env:"ADMIN_PASSWORD" flag:"admin-password" default:"admin" desc:"Default admin password (only used on first run)"`
+	PasswordFile string `env:"ADMIN_PASSWORD_FILE" flag:"admin-password-file" desc:"Read the default admin password from a file, e.g. a Docker secret (overrides ADMIN_PASSWORD)"`
 }
 
 // PostParse runs post-parsing logic for all sub-configs
 func (c *Config) PostParse() error {
+	// Load secrets from files (Docker/Kubernetes secrets)
+	if c.Admin.PasswordFile != "" {
+		password, err := config.ReadSecretFile(c.Admin.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("ADMIN_PASSWORD_FILE: %w", err)
+		}
+		c.Admin.Password = password
+	}
+
 	// Apply database defaults
 	if err := c.Database.PostParse(); err != nil {
 		return err
 	}
 
+	// Validate MQTT listener settings
+	if err := c.MQTT.PostParse(); err != nil {
+		return err
+	}
+
 	// Apply API defaults (JWT secret generation)
 	if err := c.API.PostParse(); err != nil {
 		return err
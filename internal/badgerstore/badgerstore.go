@@ -3,6 +3,7 @@ package badgerstore
 import (
 	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
@@ -11,6 +12,9 @@ import (
 // BadgerStore wraps BadgerDB for high-write operational data
 type BadgerStore struct {
 	db *badger.DB
+
+	seqMu        sync.Mutex
+	recordingSeq *badger.Sequence // Lazily created, see recordingSequence
 }
 
 // Config holds BadgerDB configuration
@@ -48,6 +52,13 @@ func Open(config *Config) (*BadgerStore, error) {
 
 // Close closes the BadgerDB instance
 func (b *BadgerStore) Close() error {
+	b.seqMu.Lock()
+	if b.recordingSeq != nil {
+		_ = b.recordingSeq.Release()
+		b.recordingSeq = nil
+	}
+	b.seqMu.Unlock()
+
 	if b.db != nil {
 		return b.db.Close()
 	}
@@ -0,0 +1,198 @@
+package badgerstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// recordingPrefix is the key prefix for recorded messages
+// Key format: recording:{timestamp_ns}-{sequence} (zero-padded so keys sort
+// chronologically; the sequence keeps recordings of the same nanosecond apart)
+const recordingPrefix = "recording:"
+
+// recordingSeqKey holds the sequence numbering recordings
+const recordingSeqKey = "seq:recording"
+
+// RecordedMessage represents a published message captured for debugging/replay
+type RecordedMessage struct {
+	ID         string    `json:"id"` // Format: zero-padded timestamp_nanoseconds-sequence
+	ClientID   string    `json:"client_id"`
+	Topic      string    `json:"topic"`
+	Payload    []byte    `json:"payload"`
+	QoS        byte      `json:"qos"`
+	Retain     bool      `json:"retain"`
+	Redacted   bool      `json:"redacted,omitempty"` // Payload holds the redaction mask, not the original
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// SaveRecordedMessages stores recorded messages in one write batch, assigning
+// their IDs. A message without RecordedAt is stamped with the current time
+func (b *BadgerStore) SaveRecordedMessages(msgs []*RecordedMessage) error {
+	seq, err := b.recordingSequence()
+	if err != nil {
+		return err
+	}
+
+	wb := b.db.NewWriteBatch()
+	defer wb.Cancel()
+
+	for _, msg := range msgs {
+		if msg.RecordedAt.IsZero() {
+			msg.RecordedAt = time.Now()
+		}
+		n, err := seq.Next()
+		if err != nil {
+			return fmt.Errorf("failed to number recorded message: %w", err)
+		}
+		msg.ID = fmt.Sprintf("%020d-%020d", msg.RecordedAt.UnixNano(), n)
+
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("failed to marshal recorded message: %w", err)
+		}
+		if err := wb.Set([]byte(recordingPrefix+msg.ID), data); err != nil {
+			return err
+		}
+	}
+
+	return wb.Flush()
+}
+
+// recordingSequence returns the sequence numbering recordings, leasing it on first use
+func (b *BadgerStore) recordingSequence() (*badger.Sequence, error) {
+	b.seqMu.Lock()
+	defer b.seqMu.Unlock()
+
+	if b.recordingSeq == nil {
+		seq, err := b.db.GetSequence([]byte(recordingSeqKey), 1000)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open recording sequence: %w", err)
+		}
+		b.recordingSeq = seq
+	}
+	return b.recordingSeq, nil
+}
+
+// GetRecordedMessage retrieves a recorded message by ID (nil if not found)
+func (b *BadgerStore) GetRecordedMessage(id string) (*RecordedMessage, error) {
+	data, err := b.Get(recordingPrefix + id)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, nil // Not found
+	}
+
+	var msg RecordedMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal recorded message: %w", err)
+	}
+
+	return &msg, nil
+}
+
+// ListRecordedMessages retrieves recorded messages with pagination
+// Returns messages sorted by recorded_at DESC (newest first)
+func (b *BadgerStore) ListRecordedMessages(page, pageSize int) ([]RecordedMessage, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 25
+	}
+
+	var all []RecordedMessage
+
+	err := b.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(recordingPrefix)
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			value, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			var msg RecordedMessage
+			if err := json.Unmarshal(value, &msg); err != nil {
+				return fmt.Errorf("failed to unmarshal recorded message: %w", err)
+			}
+			all = append(all, msg)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].ID > all[j].ID
+	})
+
+	total := int64(len(all))
+	start := (page - 1) * pageSize
+	end := start + pageSize
+
+	if start >= len(all) {
+		return []RecordedMessage{}, total, nil
+	}
+
+	if end > len(all) {
+		end = len(all)
+	}
+
+	return all[start:end], total, nil
+}
+
+// CountRecordedMessages returns the number of stored recordings
+func (b *BadgerStore) CountRecordedMessages() (int, error) {
+	keys, err := b.ListKeysWithPrefix(recordingPrefix)
+	if err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
+
+// TrimRecordedMessages deletes the oldest recordings so at most max remain
+// Returns the number of recordings deleted
+func (b *BadgerStore) TrimRecordedMessages(max int) (int, error) {
+	keys, err := b.ListKeysWithPrefix(recordingPrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	excess := len(keys) - max
+	if excess <= 0 {
+		return 0, nil
+	}
+
+	// Zero-padded IDs sort lexicographically, oldest first
+	sort.Strings(keys)
+
+	err = b.db.Update(func(txn *badger.Txn) error {
+		for _, key := range keys[:excess] {
+			if err := txn.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return excess, nil
+}
+
+// ClearRecordedMessages deletes all recordings
+func (b *BadgerStore) ClearRecordedMessages() error {
+	return b.DeletePrefix(recordingPrefix)
+}
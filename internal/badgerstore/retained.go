@@ -42,9 +42,26 @@ func (b *BadgerStore) SaveRetainedMessage(topic string, payload []byte, qos byte
 }
 
 // DeleteRetainedMessage removes a retained message for a topic
-func (b *BadgerStore) DeleteRetainedMessage(topic string) error {
-	key := fmt.Sprintf("retained:%s", topic)
-	return b.Delete(key)
+// Returns the number of messages removed (0 if the topic had none)
+func (b *BadgerStore) DeleteRetainedMessage(topic string) (int64, error) {
+	key := []byte(fmt.Sprintf("retained:%s", topic))
+
+	var deleted int64
+	err := b.db.Update(func(txn *badger.Txn) error {
+		if _, err := txn.Get(key); err != nil {
+			if err == badger.ErrKeyNotFound {
+				return nil // Nothing to delete
+			}
+			return err
+		}
+		deleted = 1
+		return txn.Delete(key)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
 }
 
 // GetRetainedMessage retrieves a retained message for a specific topic
@@ -76,7 +93,18 @@ func (b *BadgerStore) GetRetainedMessage(topic string) (*RetainedMessage, error)
 func (b *BadgerStore) GetAllRetainedMessages() ([]*RetainedMessage, error) {
 	var messages []*RetainedMessage
 
-	err := b.db.View(func(txn *badger.Txn) error {
+	err := b.ForEachRetainedMessage(func(msg *RetainedMessage) error {
+		messages = append(messages, msg)
+		return nil
+	})
+
+	return messages, err
+}
+
+// ForEachRetainedMessage calls fn for every retained message in key order without
+// loading the whole set into memory. Iteration stops at the first error fn returns
+func (b *BadgerStore) ForEachRetainedMessage(fn func(*RetainedMessage) error) error {
+	return b.db.View(func(txn *badger.Txn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.Prefix = []byte("retained:")
 
@@ -95,15 +123,36 @@ func (b *BadgerStore) GetAllRetainedMessages() ([]*RetainedMessage, error) {
 			}
 
 			// Convert to RetainedMessage
-			messages = append(messages, &RetainedMessage{
+			err = fn(&RetainedMessage{
 				Topic:     msgData.Topic,
 				Payload:   msgData.Payload,
 				QoS:       msgData.QoS,
 				CreatedAt: time.Now(), // BadgerDB doesn't track created_at
 			})
+			if err != nil {
+				return err
+			}
 		}
 		return nil
 	})
+}
 
-	return messages, err
+// CountRetainedMessages returns how many topics have a retained message
+// Only keys are read, so the payloads stay on disk
+func (b *BadgerStore) CountRetainedMessages() (int64, error) {
+	var count int64
+	err := b.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte("retained:")
+		opts.PrefetchValues = false
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			count++
+		}
+		return nil
+	})
+	return count, err
 }
@@ -0,0 +1,36 @@
+package badgerstore
+
+import "testing"
+
+func TestDeleteRetainedMessage(t *testing.T) {
+	store := OpenInMemory(t)
+
+	if err := store.SaveRetainedMessage("home/light", []byte("on"), 0); err != nil {
+		t.Fatalf("Failed to save retained message: %v", err)
+	}
+
+	deleted, err := store.DeleteRetainedMessage("home/light")
+	if err != nil {
+		t.Fatalf("Failed to delete retained message: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("Expected 1 deleted message, got %d", deleted)
+	}
+
+	msg, err := store.GetRetainedMessage("home/light")
+	if err != nil {
+		t.Fatalf("Failed to get retained message: %v", err)
+	}
+	if msg != nil {
+		t.Error("Expected retained message to be gone after delete")
+	}
+
+	// Deleting again should report nothing matched
+	deleted, err = store.DeleteRetainedMessage("home/light")
+	if err != nil {
+		t.Fatalf("Failed to delete missing retained message: %v", err)
+	}
+	if deleted != 0 {
+		t.Errorf("Expected 0 deleted messages, got %d", deleted)
+	}
+}
@@ -1,9 +1,9 @@
 package badgerstore
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -19,12 +19,58 @@ type ScriptLogEntry struct {
 	Level           string                 `json:"level"`            // debug, info, warn, error
 	Message         string                 `json:"message"`
 	Context         map[string]interface{} `json:"context,omitempty"` // Client ID, topic, etc.
+	Fields          map[string]interface{} `json:"fields,omitempty"`  // Structured fields passed to log.*() by the script
 	ExecutionTimeMs int                    `json:"execution_time_ms"`
 	CreatedAt       time.Time              `json:"created_at"`
 }
 
+// ScriptLogFilter narrows ListScriptLogsFiltered and SearchScriptLogs results
+// Empty values match everything
+type ScriptLogFilter struct {
+	Level  string
+	Fields map[string]string // Field name -> value; every one must match
+	Since  time.Time         // Only logs at or after this time
+	Until  time.Time         // Only logs before this time
+	Text   string            // Case-insensitive substring of the message
+}
+
+// inRange reports whether a log written at ns (Unix nanoseconds) is within the
+// time range. It's checked against the key so out-of-range values aren't read
+func (f ScriptLogFilter) inRange(ns int64) bool {
+	if !f.Since.IsZero() && ns < f.Since.UnixNano() {
+		return false
+	}
+	if !f.Until.IsZero() && ns >= f.Until.UnixNano() {
+		return false
+	}
+	return true
+}
+
+// matches reports whether entry passes the filter. Field values are compared in
+// their text form, so a filter of "42" matches the number 42
+func (f ScriptLogFilter) matches(entry *ScriptLogEntry) bool {
+	if f.Level != "" && entry.Level != f.Level {
+		return false
+	}
+	if f.Text != "" && !strings.Contains(strings.ToLower(entry.Message), strings.ToLower(f.Text)) {
+		return false
+	}
+	for name, want := range f.Fields {
+		value, ok := entry.Fields[name]
+		if !ok || fmt.Sprint(value) != want {
+			return false
+		}
+	}
+	return true
+}
+
 // SaveScriptLog stores a script execution log entry
 func (b *BadgerStore) SaveScriptLog(scriptID uint, triggerType, level, message string, context map[string]interface{}, executionTimeMs int) error {
+	return b.SaveScriptLogWithFields(scriptID, triggerType, level, message, context, nil, executionTimeMs)
+}
+
+// SaveScriptLogWithFields stores a script execution log entry with structured fields
+func (b *BadgerStore) SaveScriptLogWithFields(scriptID uint, triggerType, level, message string, context, fields map[string]interface{}, executionTimeMs int) error {
 	now := time.Now()
 
 	// Generate unique ID: timestamp in nanoseconds
@@ -37,6 +83,7 @@ func (b *BadgerStore) SaveScriptLog(scriptID uint, triggerType, level, message s
 		Level:           level,
 		Message:         message,
 		Context:         context,
+		Fields:          fields,
 		ExecutionTimeMs: executionTimeMs,
 		CreatedAt:       now,
 	}
@@ -54,42 +101,101 @@ func (b *BadgerStore) SaveScriptLog(scriptID uint, triggerType, level, message s
 // ListScriptLogs retrieves logs for a specific script with pagination and filtering
 // Returns logs sorted by created_at DESC (newest first)
 func (b *BadgerStore) ListScriptLogs(scriptID uint, page, pageSize int, levelFilter string) ([]ScriptLogEntry, int64, error) {
+	return b.ListScriptLogsFiltered(scriptID, page, pageSize, ScriptLogFilter{Level: levelFilter})
+}
+
+// ListScriptLogsFiltered retrieves logs for a specific script matching filter, with pagination
+// Returns logs sorted by created_at DESC (newest first)
+func (b *BadgerStore) ListScriptLogsFiltered(scriptID uint, page, pageSize int, filter ScriptLogFilter) ([]ScriptLogEntry, int64, error) {
+	return b.scanScriptLogs([]uint{scriptID}, page, pageSize, filter)
+}
+
+// SearchScriptLogs retrieves logs of the given scripts (all scripts if none)
+// matching filter, with pagination. Returns logs sorted by created_at DESC (newest first)
+func (b *BadgerStore) SearchScriptLogs(scriptIDs []uint, page, pageSize int, filter ScriptLogFilter) ([]ScriptLogEntry, int64, error) {
+	return b.scanScriptLogs(scriptIDs, page, pageSize, filter)
+}
+
+// ForEachScriptLog passes each log of the given scripts (all scripts if none)
+// matching filter to fn, oldest first within a script, stopping at the first
+// error fn returns. Unlike SearchScriptLogs nothing is collected or sorted, so
+// exports of any size run in constant memory
+func (b *BadgerStore) ForEachScriptLog(scriptIDs []uint, filter ScriptLogFilter, fn func(ScriptLogEntry) error) error {
+	return b.db.View(func(txn *badger.Txn) error {
+		for _, prefix := range scriptLogPrefixes(scriptIDs) {
+			if err := scanScriptLogPrefix(txn, prefix, filter, fn); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// scriptLogPrefixes returns the key prefixes of the logs of scriptIDs, or of all logs if there are none
+func scriptLogPrefixes(scriptIDs []uint) []string {
+	if len(scriptIDs) == 0 {
+		return []string{"log:"}
+	}
+	prefixes := make([]string, len(scriptIDs))
+	for i, id := range scriptIDs {
+		prefixes[i] = fmt.Sprintf("log:%d:", id)
+	}
+	return prefixes
+}
+
+// scanScriptLogs returns one page of the logs of scriptIDs (all scripts if
+// none) matching filter, newest first, and the number of matching logs
+// Keys are time-ordered within a script, so each script's logs are walked
+// backwards from the filter's upper time bound and merged by time. Only the
+// page is decoded and kept; the rest are counted from their keys unless the
+// filter has to read values to match them
+func (b *BadgerStore) scanScriptLogs(scriptIDs []uint, page, pageSize int, filter ScriptLogFilter) ([]ScriptLogEntry, int64, error) {
 	if page < 1 {
 		page = 1
 	}
 	if pageSize < 1 {
 		pageSize = 25
 	}
+	skip := int64(page-1) * int64(pageSize)
 
-	prefix := fmt.Sprintf("log:%d:", scriptID)
-	var allLogs []ScriptLogEntry
+	logs := []ScriptLogEntry{}
 	var total int64
 
 	err := b.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.Prefix = []byte(prefix)
-
-		it := txn.NewIterator(opts)
-		defer it.Close()
-
-		for it.Rewind(); it.Valid(); it.Next() {
-			value, err := it.Item().ValueCopy(nil)
-			if err != nil {
-				return err
-			}
+		prefixes := scriptLogPrefixes(scriptIDs)
+		if len(scriptIDs) == 0 {
+			prefixes = scriptLogScriptPrefixes(txn)
+		}
 
-			var entry ScriptLogEntry
-			if err := json.Unmarshal(value, &entry); err != nil {
-				return fmt.Errorf("failed to unmarshal script log: %w", err)
+		cursors := make([]*scriptLogCursor, 0, len(prefixes))
+		defer func() {
+			for _, c := range cursors {
+				c.it.Close()
 			}
+		}()
+		for _, prefix := range prefixes {
+			cursors = append(cursors, newScriptLogCursor(txn, prefix, filter))
+		}
 
-			// Apply level filter if specified
-			if levelFilter != "" && entry.Level != levelFilter {
+		for c := newestScriptLog(cursors); c != nil; c = newestScriptLog(cursors) {
+			inPage := total >= skip && len(logs) < pageSize
+			if !inPage && !filter.readsValues() {
+				total++
+				c.next()
 				continue
 			}
 
-			allLogs = append(allLogs, entry)
-			total++
+			entry, err := decodeScriptLog(c.it.Item())
+			if err != nil {
+				return err
+			}
+			if filter.matches(&entry) {
+				if inPage {
+					logs = append(logs, entry)
+				}
+				total++
+			}
+			c.next()
 		}
 		return nil
 	})
@@ -97,25 +203,157 @@ func (b *BadgerStore) ListScriptLogs(scriptID uint, page, pageSize int, levelFil
 	if err != nil {
 		return nil, 0, err
 	}
+	return logs, total, nil
+}
 
-	// Sort by created_at DESC (newest first)
-	sort.Slice(allLogs, func(i, j int) bool {
-		return allLogs[i].CreatedAt.After(allLogs[j].CreatedAt)
-	})
+// readsValues reports whether matching a log needs more than its key
+func (f ScriptLogFilter) readsValues() bool {
+	return f.Level != "" || f.Text != "" || len(f.Fields) > 0
+}
+
+// scriptLogScriptPrefixes returns the key prefix of every script that has
+// logs, seeking past each script's logs instead of reading them
+func scriptLogScriptPrefixes(txn *badger.Txn) []string {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte("log:")
+	opts.PrefetchValues = false
+
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var prefixes []string
+	for it.Rewind(); it.Valid(); {
+		key := it.Item().Key()
+		i := bytes.IndexByte(key[len("log:"):], ':')
+		if i < 0 {
+			it.Next()
+			continue
+		}
+		prefix := string(key[:len("log:")+i+1])
+		prefixes = append(prefixes, prefix)
+
+		// ';' sorts right after ':', so this lands on the next script's first log
+		it.Seek([]byte(prefix[:len(prefix)-1] + ";"))
+	}
+	return prefixes
+}
+
+// scriptLogCursor walks one script's logs newest first within a filter's time range
+type scriptLogCursor struct {
+	it     *badger.Iterator
+	filter ScriptLogFilter
+	ns     int64 // Write time of the current log
+	done   bool
+}
+
+// newScriptLogCursor positions a cursor on the newest log under prefix before filter.Until
+func newScriptLogCursor(txn *badger.Txn, prefix string, filter ScriptLogFilter) *scriptLogCursor {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(prefix)
+	opts.PrefetchValues = false // Only the page's values are read
+	opts.Reverse = true
 
-	// Apply pagination
-	start := (page - 1) * pageSize
-	end := start + pageSize
+	c := &scriptLogCursor{it: txn.NewIterator(opts), filter: filter}
 
-	if start >= len(allLogs) {
-		return []ScriptLogEntry{}, total, nil
+	// A reverse seek lands on the last key at or before the target
+	seek := prefix + "\xff"
+	if !filter.Until.IsZero() {
+		seek = prefix + strconv.FormatInt(filter.Until.UnixNano(), 10)
 	}
+	c.it.Seek([]byte(seek))
+	c.settle()
+	return c
+}
 
-	if end > len(allLogs) {
-		end = len(allLogs)
+// next moves to the next older log in range
+func (c *scriptLogCursor) next() {
+	c.it.Next()
+	c.settle()
+}
+
+// settle skips logs at or after filter.Until and ends the walk at the first
+// log before filter.Since
+func (c *scriptLogCursor) settle() {
+	for ; c.it.Valid(); c.it.Next() {
+		// Key format: log:{scriptID}:{timestamp_ns}
+		ns, ok := scriptLogKeyTime(c.it.Item().Key())
+		if !ok || (!c.filter.Until.IsZero() && ns >= c.filter.Until.UnixNano()) {
+			continue
+		}
+		if !c.filter.Since.IsZero() && ns < c.filter.Since.UnixNano() {
+			break
+		}
+		c.ns = ns
+		return
 	}
+	c.done = true
+}
 
-	return allLogs[start:end], total, nil
+// newestScriptLog returns the cursor on the newest log, or nil when all are done
+// Scripts are few, so a linear pick is cheaper than keeping a heap
+func newestScriptLog(cursors []*scriptLogCursor) *scriptLogCursor {
+	var newest *scriptLogCursor
+	for _, c := range cursors {
+		if !c.done && (newest == nil || c.ns > newest.ns) {
+			newest = c
+		}
+	}
+	return newest
+}
+
+// decodeScriptLog reads the log entry stored in item
+func decodeScriptLog(item *badger.Item) (ScriptLogEntry, error) {
+	var entry ScriptLogEntry
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return entry, err
+	}
+	if err := json.Unmarshal(value, &entry); err != nil {
+		return entry, fmt.Errorf("failed to unmarshal script log: %w", err)
+	}
+	return entry, nil
+}
+
+// scanScriptLogPrefix passes each log under prefix matching filter to fn, stopping at the first error it returns
+func scanScriptLogPrefix(txn *badger.Txn, prefix string, filter ScriptLogFilter, fn func(ScriptLogEntry) error) error {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(prefix)
+	// Values are only fetched for keys within the time range
+	opts.PrefetchValues = filter.Since.IsZero() && filter.Until.IsZero()
+
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Rewind(); it.Valid(); it.Next() {
+		item := it.Item()
+
+		// Key format: log:{scriptID}:{timestamp_ns}
+		if ns, ok := scriptLogKeyTime(item.Key()); ok && !filter.inRange(ns) {
+			continue
+		}
+
+		entry, err := decodeScriptLog(item)
+		if err != nil {
+			return err
+		}
+
+		if filter.matches(&entry) {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// scriptLogKeyTime extracts the write time (Unix nanoseconds) from a log key
+func scriptLogKeyTime(key []byte) (int64, bool) {
+	i := bytes.LastIndexByte(key, ':')
+	if i < 0 {
+		return 0, false
+	}
+	ns, err := strconv.ParseInt(string(key[i+1:]), 10, 64)
+	return ns, err == nil
 }
 
 // GetScriptLog retrieves a single log entry by ID and script ID
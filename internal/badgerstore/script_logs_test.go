@@ -2,7 +2,9 @@ package badgerstore
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 )
@@ -241,6 +243,150 @@ func TestListScriptLogs_MultipleScripts(t *testing.T) {
 	}
 }
 
+func TestSearchScriptLogs(t *testing.T) {
+	store := OpenInMemory(t)
+
+	save := func(scriptID uint, level, message string) {
+		t.Helper()
+		if err := store.SaveScriptLog(scriptID, "on_publish", level, message, nil, 10); err != nil {
+			t.Fatalf("Failed to save log: %v", err)
+		}
+		time.Sleep(1 * time.Millisecond) // Ensure unique timestamps
+	}
+
+	save(1, "info", "Forwarded reading")
+	save(1, "error", "Sensor offline")
+	save(2, "error", "Alarm publish failed")
+	save(2, "info", "Alarm raised")
+	save(3, "warn", "Slow response")
+
+	// Level across every script, newest first
+	logs, total, err := store.SearchScriptLogs(nil, 1, 10, ScriptLogFilter{Level: "error"})
+	if err != nil {
+		t.Fatalf("SearchScriptLogs() error = %v", err)
+	}
+	if total != 2 || len(logs) != 2 {
+		t.Fatalf("Expected 2 error logs, got total %d, %d logs", total, len(logs))
+	}
+	if logs[0].ScriptID != 2 || logs[1].ScriptID != 1 {
+		t.Errorf("Expected error logs of scripts [2 1] newest first, got [%d %d]", logs[0].ScriptID, logs[1].ScriptID)
+	}
+
+	// Limited to some scripts, with a case-insensitive text search
+	logs, total, err = store.SearchScriptLogs([]uint{2, 3}, 1, 10, ScriptLogFilter{Text: "ALARM"})
+	if err != nil {
+		t.Fatalf("SearchScriptLogs() error = %v", err)
+	}
+	if total != 2 {
+		t.Errorf("Expected 2 logs mentioning alarm in scripts 2 and 3, got %d", total)
+	}
+	for _, log := range logs {
+		if log.ScriptID != 2 {
+			t.Errorf("Expected only script 2 logs, got script %d", log.ScriptID)
+		}
+	}
+
+	// Time range
+	all, _, err := store.SearchScriptLogs(nil, 1, 10, ScriptLogFilter{})
+	if err != nil {
+		t.Fatalf("SearchScriptLogs() error = %v", err)
+	}
+	if len(all) != 5 {
+		t.Fatalf("Expected 5 logs in total, got %d", len(all))
+	}
+	// all is newest first: keep the middle three
+	filter := ScriptLogFilter{Since: all[3].CreatedAt, Until: all[0].CreatedAt}
+	_, total, err = store.SearchScriptLogs(nil, 1, 10, filter)
+	if err != nil {
+		t.Fatalf("SearchScriptLogs() error = %v", err)
+	}
+	if total != 3 {
+		t.Errorf("Expected 3 logs in the time range, got %d", total)
+	}
+}
+
+func TestSearchScriptLogs_PagesAcrossScripts(t *testing.T) {
+	store := OpenInMemory(t)
+
+	// Interleave the logs of scripts 1, 2 and 12 (whose keys sort between them)
+	var messages []string
+	for i := 0; i < 9; i++ {
+		scriptID := []uint{1, 2, 12}[i%3]
+		message := fmt.Sprintf("log %d", i)
+		if err := store.SaveScriptLog(scriptID, "on_publish", "info", message, nil, 10); err != nil {
+			t.Fatalf("Failed to save log: %v", err)
+		}
+		messages = append(messages, message)
+		time.Sleep(1 * time.Millisecond) // Ensure unique timestamps
+	}
+
+	// Pages run newest first across every script
+	for page, want := range [][]string{{"log 8", "log 7", "log 6", "log 5"}, {"log 4", "log 3", "log 2", "log 1"}, {"log 0"}} {
+		logs, total, err := store.SearchScriptLogs(nil, page+1, 4, ScriptLogFilter{})
+		if err != nil {
+			t.Fatalf("SearchScriptLogs() error = %v", err)
+		}
+		if total != 9 {
+			t.Errorf("page %d: total = %d, want 9", page+1, total)
+		}
+		var got []string
+		for _, log := range logs {
+			got = append(got, log.Message)
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("page %d = %v, want %v", page+1, got, want)
+		}
+	}
+
+	// Time bounds apply per script before merging
+	all, _, err := store.SearchScriptLogs(nil, 1, 10, ScriptLogFilter{})
+	if err != nil {
+		t.Fatalf("SearchScriptLogs() error = %v", err)
+	}
+	filter := ScriptLogFilter{Since: all[6].CreatedAt, Until: all[1].CreatedAt, Level: "info"}
+	logs, total, err := store.SearchScriptLogs(nil, 2, 2, filter)
+	if err != nil {
+		t.Fatalf("SearchScriptLogs() error = %v", err)
+	}
+	if total != 5 || len(logs) != 2 || logs[0].Message != messages[4] || logs[1].Message != messages[3] {
+		t.Errorf("SearchScriptLogs(time range, page 2) = %d logs (total %d), want [log 4 log 3] of 5", len(logs), total)
+	}
+}
+
+func TestForEachScriptLog(t *testing.T) {
+	store := OpenInMemory(t)
+
+	for i := 0; i < 5; i++ {
+		if err := store.SaveScriptLog(uint(1+i%2), "on_publish", "info", fmt.Sprintf("log %d", i), nil, 10); err != nil {
+			t.Fatalf("Failed to save log: %v", err)
+		}
+		time.Sleep(1 * time.Millisecond) // Ensure unique timestamps
+	}
+
+	var messages []string
+	err := store.ForEachScriptLog([]uint{1}, ScriptLogFilter{}, func(entry ScriptLogEntry) error {
+		messages = append(messages, entry.Message)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachScriptLog() error = %v", err)
+	}
+	if strings.Join(messages, ",") != "log 0,log 2,log 4" {
+		t.Errorf("Expected script 1 logs oldest first, got %v", messages)
+	}
+
+	// An error from fn stops the scan
+	stop := errors.New("stop")
+	visited := 0
+	err = store.ForEachScriptLog(nil, ScriptLogFilter{}, func(ScriptLogEntry) error {
+		visited++
+		return stop
+	})
+	if !errors.Is(err, stop) || visited != 1 {
+		t.Errorf("Expected the scan to stop after the first error, got %v after %d logs", err, visited)
+	}
+}
+
 func TestClearScriptLogs(t *testing.T) {
 	store := OpenInMemory(t)
 
@@ -0,0 +1,145 @@
+package badgerstore
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/mochi-mqtt/server/v2/hooks/storage"
+)
+
+// Key prefixes of persistent MQTT session data. Subscription and inflight keys
+// continue with the client ID and a NUL separator, so one client's keys are
+// never a prefix match for another client whose ID starts the same way
+const (
+	sessionClientPrefix       = "session:client:"
+	sessionSubscriptionPrefix = "session:sub:"
+	sessionInflightPrefix     = "session:inflight:"
+)
+
+// SessionData is everything stored for one persistent session
+type SessionData struct {
+	Client        *storage.Client
+	Subscriptions []storage.Subscription
+	Inflight      []storage.Message
+}
+
+func sessionSubscriptionKey(clientID, filter string) string {
+	return sessionSubscriptionPrefix + clientID + "\x00" + filter
+}
+
+func sessionInflightKey(clientID string, packetID uint16) string {
+	return fmt.Sprintf("%s%s\x00%05d", sessionInflightPrefix, clientID, packetID)
+}
+
+// SaveSessionClient stores or updates a client session
+func (b *BadgerStore) SaveSessionClient(client *storage.Client) error {
+	data, err := json.Marshal(client)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session client: %w", err)
+	}
+	return b.Set(sessionClientPrefix+client.ID, data, 0)
+}
+
+// SaveSessionSubscription stores or updates one subscription of a session
+func (b *BadgerStore) SaveSessionSubscription(sub *storage.Subscription) error {
+	data, err := json.Marshal(sub)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session subscription: %w", err)
+	}
+	return b.Set(sessionSubscriptionKey(sub.Client, sub.Filter), data, 0)
+}
+
+// DeleteSessionSubscription removes one subscription of a session
+func (b *BadgerStore) DeleteSessionSubscription(clientID, filter string) error {
+	return b.Delete(sessionSubscriptionKey(clientID, filter))
+}
+
+// SaveInflightMessage stores a QoS 1/2 message awaiting delivery or acknowledgement
+func (b *BadgerStore) SaveInflightMessage(msg *storage.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal inflight message: %w", err)
+	}
+	return b.Set(sessionInflightKey(msg.Client, msg.PacketID), data, 0)
+}
+
+// DeleteInflightMessage removes a message once it is acknowledged or dropped
+func (b *BadgerStore) DeleteInflightMessage(clientID string, packetID uint16) error {
+	return b.Delete(sessionInflightKey(clientID, packetID))
+}
+
+// DeleteSession removes a client session with its subscriptions and inflight messages
+func (b *BadgerStore) DeleteSession(clientID string) error {
+	if err := b.Delete(sessionClientPrefix + clientID); err != nil {
+		return err
+	}
+	if err := b.DeletePrefix(sessionSubscriptionPrefix + clientID + "\x00"); err != nil {
+		return err
+	}
+	return b.DeletePrefix(sessionInflightPrefix + clientID + "\x00")
+}
+
+// GetSessionClients returns every stored client session
+func (b *BadgerStore) GetSessionClients() ([]storage.Client, error) {
+	return listSessionValues[storage.Client](b, sessionClientPrefix)
+}
+
+// GetSessionSubscriptions returns the subscriptions of every stored session
+func (b *BadgerStore) GetSessionSubscriptions() ([]storage.Subscription, error) {
+	return listSessionValues[storage.Subscription](b, sessionSubscriptionPrefix)
+}
+
+// GetInflightMessages returns the inflight messages of every stored session
+func (b *BadgerStore) GetInflightMessages() ([]storage.Message, error) {
+	return listSessionValues[storage.Message](b, sessionInflightPrefix)
+}
+
+// GetSession returns the stored session of one client, or nil if there is none
+func (b *BadgerStore) GetSession(clientID string) (*SessionData, error) {
+	data, err := b.Get(sessionClientPrefix + clientID)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	session := &SessionData{Client: &storage.Client{}}
+	if err := json.Unmarshal(data, session.Client); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session client: %w", err)
+	}
+	if session.Subscriptions, err = listSessionValues[storage.Subscription](b, sessionSubscriptionPrefix+clientID+"\x00"); err != nil {
+		return nil, err
+	}
+	if session.Inflight, err = listSessionValues[storage.Message](b, sessionInflightPrefix+clientID+"\x00"); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// listSessionValues decodes every JSON value stored under prefix
+func listSessionValues[T any](b *BadgerStore, prefix string) ([]T, error) {
+	var values []T
+	err := b.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(prefix)
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			data, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			var value T
+			if err := json.Unmarshal(data, &value); err != nil {
+				return fmt.Errorf("failed to unmarshal session data: %w", err)
+			}
+			values = append(values, value)
+		}
+		return nil
+	})
+	return values, err
+}
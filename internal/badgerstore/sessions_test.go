@@ -0,0 +1,39 @@
+package badgerstore
+
+import (
+	"testing"
+
+	"github.com/mochi-mqtt/server/v2/hooks/storage"
+)
+
+func TestDeleteSession(t *testing.T) {
+	store := OpenInMemory(t)
+
+	// "dev" is a prefix of "device" and must not take its data along
+	for _, id := range []string{"dev", "device"} {
+		if err := store.SaveSessionClient(&storage.Client{ID: id}); err != nil {
+			t.Fatalf("Failed to save session client: %v", err)
+		}
+		if err := store.SaveSessionSubscription(&storage.Subscription{Client: id, Filter: "a/#", Qos: 1}); err != nil {
+			t.Fatalf("Failed to save subscription: %v", err)
+		}
+		if err := store.SaveInflightMessage(&storage.Message{Client: id, PacketID: 1, TopicName: "a/b"}); err != nil {
+			t.Fatalf("Failed to save inflight message: %v", err)
+		}
+	}
+
+	if err := store.DeleteSession("dev"); err != nil {
+		t.Fatalf("Failed to delete session: %v", err)
+	}
+
+	if session, err := store.GetSession("dev"); err != nil || session != nil {
+		t.Errorf("GetSession(dev) = %+v, %v; want nil", session, err)
+	}
+	session, err := store.GetSession("device")
+	if err != nil || session == nil {
+		t.Fatalf("GetSession(device) = %+v, %v; want the session", session, err)
+	}
+	if len(session.Subscriptions) != 1 || len(session.Inflight) != 1 {
+		t.Errorf("device session has %d subscriptions and %d inflight, want 1 each", len(session.Subscriptions), len(session.Inflight))
+	}
+}
@@ -2,7 +2,9 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -14,21 +16,65 @@ type Config struct {
 	ACLRules []ACLRuleConfig  `yaml:"acl_rules" json:"acl_rules,omitempty" jsonschema:"title=ACL Rules,description=Access control rules for MQTT topic permissions"`
 	Bridges  []BridgeConfig   `yaml:"bridges" json:"bridges,omitempty" jsonschema:"title=MQTT Bridges,description=Bridge connections to remote MQTT brokers for message forwarding"`
 	Scripts  []ScriptConfig   `yaml:"scripts" json:"scripts,omitempty" jsonschema:"title=JavaScript Scripts,description=Custom JavaScript scripts that execute on MQTT events"`
+
+	DefaultACL []DefaultACLRuleConfig  `yaml:"default_acl" json:"default_acl,omitempty" jsonschema:"title=Default ACL,description=Template ACL rules applied to MQTT users created via the API with applyDefaultAcl set"`
+	Retained   []RetainedMessageConfig `yaml:"retained" json:"retained,omitempty" jsonschema:"title=Startup Retained Messages,description=Retained messages published every time the broker starts (e.g. a broker status message)"`
 }
 
 // MQTTUserConfig represents an MQTT user in the config file
 type MQTTUserConfig struct {
 	Username    string                 `yaml:"username" json:"username" jsonschema:"required,title=Username,description=MQTT username for device authentication. Supports env vars: ${VAR} or ${VAR:-default},minLength=1,example=sensor_user"`
-	Password    string                 `yaml:"password" json:"password" jsonschema:"required,title=Password,description=MQTT password. Supports env vars: ${PASSWORD} or ${PASSWORD:-default},minLength=1,example=${SENSOR_PASSWORD}"`
+	Password    string                 `yaml:"password" json:"password" jsonschema:"required,title=Password,description=MQTT password. Supports env vars: ${PASSWORD} or ${PASSWORD:-default} and secret files: ${file:/run/secrets/name},minLength=1,example=${SENSOR_PASSWORD}"`
 	Description string                 `yaml:"description,omitempty" json:"description,omitempty" jsonschema:"title=Description,description=Human-readable description of this MQTT user,example=Temperature and humidity sensors"`
 	Metadata    map[string]interface{} `yaml:"metadata,omitempty" json:"metadata,omitempty" jsonschema:"title=Metadata,description=Custom metadata key-value pairs (any valid JSON)"`
+
+	AllowedCIDRs []string `yaml:"allowed_cidrs,omitempty" json:"allowed_cidrs,omitempty" jsonschema:"title=Allowed Networks,description=Source networks (CIDR or single IP) this user may connect from. Empty allows any address,example=10.0.0.0/8"`
+	DeniedCIDRs  []string `yaml:"denied_cidrs,omitempty" json:"denied_cidrs,omitempty" jsonschema:"title=Denied Networks,description=Source networks (CIDR or single IP) this user may never connect from. Takes precedence over allowed_cidrs,example=10.0.99.0/24"`
+
+	pos position // Where the entry is defined (set by parseFile)
 }
 
 // ACLRuleConfig represents an ACL rule in the config file
 type ACLRuleConfig struct {
 	Username   string `yaml:"username" json:"username" jsonschema:"required,title=Username,description=MQTT username this rule applies to (must exist in users list),minLength=1,example=sensor_user"`
-	Topic      string `yaml:"topic" json:"topic" jsonschema:"required,title=Topic Pattern,description=MQTT topic pattern with wildcards (+/#) and runtime placeholders (${username}/${clientid}),minLength=1,example=sensors/${username}/#"`
+	Topic      string `yaml:"topic" json:"topic" jsonschema:"required,title=Topic Pattern,description=MQTT topic pattern with wildcards (+/#) and runtime placeholders (${username}/${clientid}/${year}/${month}/${day}),minLength=1,example=sensors/${username}/#"`
 	Permission string `yaml:"permission" json:"permission" jsonschema:"required,title=Permission,description=Access permission for this topic pattern,enum=pub,enum=sub,enum=pubsub"`
+	Retain     bool   `yaml:"retain,omitempty" json:"retain,omitempty" jsonschema:"title=Allow Retain,description=Allow publishing retained messages on matching topics (only enforced when MQTT_RETAIN_REQUIRES_PERMISSION is set),default=false"`
+
+	pos position // Where the entry is defined (set by parseFile)
+}
+
+// DefaultACLRuleConfig represents a template ACL rule for newly-created MQTT users
+// Placeholders are stored as-is and resolved per connection like any other rule
+type DefaultACLRuleConfig struct {
+	Topic      string `yaml:"topic" json:"topic" jsonschema:"required,title=Topic Pattern,description=MQTT topic pattern with wildcards (+/#) and runtime placeholders (${username}/${clientid}/${year}/${month}/${day}),minLength=1,example=devices/${clientid}/#"`
+	Permission string `yaml:"permission" json:"permission" jsonschema:"required,title=Permission,description=Access permission for this topic pattern,enum=pub,enum=sub,enum=pubsub"`
+	Retain     bool   `yaml:"retain,omitempty" json:"retain,omitempty" jsonschema:"title=Allow Retain,description=Allow publishing retained messages on matching topics (only enforced when MQTT_RETAIN_REQUIRES_PERMISSION is set),default=false"`
+
+	pos position // Where the entry is defined (set by parseFile)
+}
+
+// validateCIDRs checks that each entry is a CIDR or a single IP address
+func validateCIDRs(entries []string) error {
+	for _, entry := range entries {
+		if _, err := netip.ParsePrefix(entry); err == nil {
+			continue
+		}
+		if _, err := netip.ParseAddr(entry); err != nil {
+			return fmt.Errorf("invalid CIDR or IP address: %s", entry)
+		}
+	}
+	return nil
+}
+
+// RetainedMessageConfig represents a retained message seeded at startup
+// Publishing it again on every start replaces the stored copy, so restarts are idempotent
+type RetainedMessageConfig struct {
+	Topic   string `yaml:"topic" json:"topic" jsonschema:"required,title=Topic,description=Topic to retain the message on (no wildcards),minLength=1,example=broker/status"`
+	Payload string `yaml:"payload" json:"payload" jsonschema:"title=Payload,description=Message payload. Supports env vars,example=online"`
+	QoS     int    `yaml:"qos,omitempty" json:"qos,omitempty" jsonschema:"title=QoS,description=MQTT Quality of Service level,default=0,minimum=0,maximum=2"`
+
+	pos position // Where the entry is defined (set by parseFile)
 }
 
 // BridgeConfig represents an MQTT bridge in the config file
@@ -37,14 +83,23 @@ type BridgeConfig struct {
 	Host              string                 `yaml:"host" json:"host" jsonschema:"required,title=Remote Host,description=Remote MQTT broker hostname or IP. Supports env vars: ${HOST:-default},minLength=1,example=${CLOUD_MQTT_HOST:-mqtt.example.com}"`
 	Port              int                    `yaml:"port,omitempty" json:"port,omitempty" jsonschema:"title=Remote Port,description=Remote MQTT broker port,default=1883,minimum=1,maximum=65535,example=1883"`
 	Username          string                 `yaml:"username,omitempty" json:"username,omitempty" jsonschema:"title=Username,description=Username for remote broker authentication. Supports env vars,example=${CLOUD_USER}"`
-	Password          string                 `yaml:"password,omitempty" json:"password,omitempty" jsonschema:"title=Password,description=Password for remote broker authentication. Supports env vars,example=${CLOUD_PASSWORD}"`
+	Password          string                 `yaml:"password,omitempty" json:"password,omitempty" jsonschema:"title=Password,description=Password for remote broker authentication. Supports env vars and secret files (${file:/run/secrets/name}),example=${CLOUD_PASSWORD}"`
 	ClientID          string                 `yaml:"client_id,omitempty" json:"client_id,omitempty" jsonschema:"title=Client ID,description=MQTT client ID for bridge connection,example=edge-broker-001"`
 	MQTTVersion       string                 `yaml:"mqtt_version,omitempty" json:"mqtt_version,omitempty" jsonschema:"title=MQTT Version,description=MQTT protocol version: 3 (v3.1.1) or 5 (v5.0). Version 5 enables NoLocal subscriptions for loop prevention,enum=3,enum=5,default=5,example=5"`
-	CleanSession      bool                   `yaml:"clean_session,omitempty" json:"clean_session,omitempty" jsonschema:"title=Clean Session,description=Start with clean session (true) or resume previous session (false). For MQTT v5 this maps to CleanStart,default=true"`
+	CleanSession      *bool                  `yaml:"clean_session,omitempty" json:"clean_session,omitempty" jsonschema:"title=Clean Session,description=Start with clean session (true) or resume previous session (false). For MQTT v5 this maps to CleanStart. A persistent session (false) requires client_id,default=true"`
 	KeepAlive         int                    `yaml:"keep_alive,omitempty" json:"keep_alive,omitempty" jsonschema:"title=Keep Alive,description=Keep alive interval in seconds,default=60,minimum=1,example=60"`
 	ConnectionTimeout int                    `yaml:"connection_timeout,omitempty" json:"connection_timeout,omitempty" jsonschema:"title=Connection Timeout,description=Connection timeout in seconds,default=30,minimum=1,example=30"`
+	MaxQoS            *int                   `yaml:"max_qos,omitempty" json:"max_qos,omitempty" jsonschema:"title=Max QoS,description=Cap the QoS of forwarded messages and subscriptions (e.g. when the remote broker only supports QoS 1). Unset means no cap,minimum=0,maximum=2,example=1"`
+	RetainPolicy      string                 `yaml:"retain_policy,omitempty" json:"retain_policy,omitempty" jsonschema:"title=Retain Policy,description=Whether forwarded messages keep their retain flag (preserve) or are always sent non-retained (clear),enum=preserve,enum=clear,default=preserve"`
 	Metadata          map[string]interface{} `yaml:"metadata,omitempty" json:"metadata,omitempty" jsonschema:"title=Metadata,description=Custom metadata key-value pairs"`
 	Topics            []BridgeTopicConfig    `yaml:"topics" json:"topics" jsonschema:"required,title=Topic Mappings,description=Topic mappings for message forwarding,minItems=1"`
+
+	pos position // Where the entry is defined (set by parseFile)
+}
+
+// UsesCleanSession reports whether the bridge starts a clean session (the default)
+func (b BridgeConfig) UsesCleanSession() bool {
+	return b.CleanSession == nil || *b.CleanSession
 }
 
 // BridgeTopicConfig represents a topic mapping in a bridge configuration
@@ -53,6 +108,8 @@ type BridgeTopicConfig struct {
 	Remote    string `yaml:"remote" json:"remote" jsonschema:"required,title=Remote Topic,description=Remote topic pattern for forwarding,minLength=1,example=edge/sensors/#"`
 	Direction string `yaml:"direction" json:"direction" jsonschema:"required,title=Direction,description=Message forwarding direction,enum=in,enum=out,enum=both,example=out"`
 	QoS       int    `yaml:"qos,omitempty" json:"qos,omitempty" jsonschema:"title=QoS,description=MQTT Quality of Service level,default=0,minimum=0,maximum=2,example=1"`
+
+	pos position // Where the entry is defined (set by parseFile)
 }
 
 // ScriptConfig represents a script in the config file
@@ -60,10 +117,12 @@ type ScriptConfig struct {
 	Name        string                 `yaml:"name" json:"name" jsonschema:"required,title=Script Name,description=Unique name for this script,minLength=1,example=message-logger"`
 	Description string                 `yaml:"description,omitempty" json:"description,omitempty" jsonschema:"title=Description,description=Human-readable description,example=Log all published messages"`
 	Enabled     bool                   `yaml:"enabled" json:"enabled" jsonschema:"title=Enabled,description=Whether this script is active,default=true"`
-	File        string                 `yaml:"file,omitempty" json:"file,omitempty" jsonschema:"title=Script File,description=Path to JavaScript file. Supports env vars. Mutually exclusive with content,example=./scripts/logger.js"`
+	File        string                 `yaml:"file,omitempty" json:"file,omitempty" jsonschema:"title=Script File,description=Path to JavaScript file; relative paths are resolved from the directory of the config file. Supports env vars. Mutually exclusive with content,example=./scripts/logger.js"`
 	Content     string                 `yaml:"content,omitempty" json:"content,omitempty" jsonschema:"title=Script Content,description=Inline JavaScript code. Supports env vars (${API_KEY}) and $$ escaping for JS templates ($${var}). Mutually exclusive with file,example=log.info('Message:', msg.topic);"`
 	Metadata    map[string]interface{} `yaml:"metadata,omitempty" json:"metadata,omitempty" jsonschema:"title=Metadata,description=Custom metadata key-value pairs accessible in script"`
 	Triggers    []ScriptTriggerConfig  `yaml:"triggers" json:"triggers" jsonschema:"required,title=Triggers,description=When this script should execute,minItems=1"`
+
+	pos position // Where the entry is defined (set by parseFile)
 }
 
 // ScriptTriggerConfig represents a trigger for a script
@@ -72,6 +131,8 @@ type ScriptTriggerConfig struct {
 	Topic    string `yaml:"topic,omitempty" json:"topic,omitempty" jsonschema:"title=Topic Filter,description=MQTT topic pattern to filter events (empty = all topics). Supports wildcards (+/#),example=#"`
 	Priority int    `yaml:"priority,omitempty" json:"priority,omitempty" jsonschema:"title=Priority,description=Execution order (lower = earlier). Default: 100,default=100,minimum=0,example=50"`
 	Enabled  bool   `yaml:"enabled" json:"enabled" jsonschema:"title=Enabled,description=Whether this trigger is active,default=true"`
+
+	pos position // Where the entry is defined (set by parseFile)
 }
 
 // reservedPlaceholders lists variable names that should never be expanded as env vars
@@ -79,6 +140,9 @@ type ScriptTriggerConfig struct {
 var reservedPlaceholders = []string{
 	"username", // ACL placeholder - replaced at runtime with MQTT username
 	"clientid", // ACL placeholder - replaced at runtime with MQTT client ID
+	"year",     // ACL placeholder - replaced at check time with the current UTC year
+	"month",    // ACL placeholder - replaced at check time with the current UTC month (01-12)
+	"day",      // ACL placeholder - replaced at check time with the current UTC day (01-31)
 	// Add more reserved placeholders here as needed
 }
 
@@ -92,15 +156,38 @@ func isReservedPlaceholder(name string) bool {
 	return false
 }
 
-// customMapper is used by os.Expand to handle environment variable expansion
+// filePrefix marks a ${file:/path} reference to a secret file
+const filePrefix = "file:"
+
+// customMapper resolves a single ${...} reference during expansion
 // Supports:
-// - ${username} and ${clientid} - preserved as ACL/MQTT placeholders
+// - ${username}, ${clientid}, ${year}, ${month} and ${day} - preserved as ACL/MQTT placeholders
+// - ${file:/run/secrets/name} - contents of a secret file (Docker/Kubernetes secrets)
+// - ${VAR:?message} - required env var, fails with message if unset/empty (Docker Compose style)
 // - ${VAR:-default} - env var with default value (Docker Compose style)
 // - ${VAR} - standard env var expansion
-func customMapper(name string) string {
+func customMapper(name string) (string, error) {
 	// Preserve reserved runtime placeholders - never expand these
 	if isReservedPlaceholder(name) {
-		return "${" + name + "}"
+		return "${" + name + "}", nil
+	}
+
+	// Handle secret files: ${file:/path}
+	if path, ok := strings.CutPrefix(name, filePrefix); ok {
+		return ReadSecretFile(path)
+	}
+
+	// Handle required syntax: ${VAR:?message}
+	// (unless the ":?" is inside a default value, e.g. ${VAR:-a:?b})
+	if varName, message, ok := strings.Cut(name, ":?"); ok && !strings.Contains(varName, ":-") {
+		varName = strings.TrimSpace(varName)
+		if val := os.Getenv(varName); val != "" {
+			return val, nil
+		}
+		if message == "" {
+			message = "required variable is not set"
+		}
+		return "", fmt.Errorf("%s: %s", varName, message)
 	}
 
 	// Handle default value syntax: ${VAR:-default}
@@ -112,14 +199,44 @@ func customMapper(name string) string {
 
 			// Return env var if set and non-empty, otherwise use default
 			if val := os.Getenv(varName); val != "" {
-				return val
+				return val, nil
 			}
-			return defaultVal
+			return defaultVal, nil
 		}
 	}
 
 	// Standard env var expansion
-	return os.Getenv(name)
+	return os.Getenv(name), nil
+}
+
+// expandVariables runs customMapper over every ${...} reference in content
+// and returns the first error encountered
+func expandVariables(content string) (string, error) {
+	var firstErr error
+	expanded := os.Expand(content, func(name string) string {
+		val, err := customMapper(name)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return val
+	})
+	return expanded, firstErr
+}
+
+// ReadSecretFile returns the contents of a secret file with trailing newlines removed
+// (secret files are usually written with a final newline that isn't part of the value)
+func ReadSecretFile(path string) (string, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return "", fmt.Errorf("secret file path is empty")
+	}
+
+	// #nosec G304 -- Secret file paths are controlled by the operator
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
 }
 
 // escapeDollarSigns protects $$ (double dollar) from expansion
@@ -137,9 +254,31 @@ func restoreDollarSigns(content string) string {
 // Supports Docker Compose-style syntax:
 // - ${VAR} - expand environment variable (empty string if unset)
 // - ${VAR:-default} - expand env var with default value if unset/empty
-// - ${username} and ${clientid} - preserved as ACL/MQTT runtime placeholders
+// - ${VAR:?message} - fail to load with message if the env var is unset/empty
+// - ${file:/path} - contents of a secret file (e.g. /run/secrets/mqtt_password)
+// - ${username}, ${clientid}, ${year}, ${month} and ${day} - preserved as ACL/MQTT runtime placeholders
 // - $${...} - escaped, becomes literal ${...} (for JavaScript template literals)
+// If path is a directory, all config files in it are merged (see LoadDir)
 func Load(path string) (*Config, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return LoadDir(path)
+	}
+
+	cfg, err := parseFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// parseFile reads, expands, and parses a single config file without validating it
+func parseFile(path string) (*Config, error) {
 	// Read the file
 	// #nosec G304 -- Config file path is controlled by operator via CLI flag/env var
 	data, err := os.ReadFile(path)
@@ -154,41 +293,141 @@ func Load(path string) (*Config, error) {
 	content = escapeDollarSigns(content)
 
 	// Step 2: Expand environment variables using custom mapper
-	// Mapper handles: ${username}, ${clientid}, ${VAR:-default}, ${VAR}
-	expanded := os.Expand(content, customMapper)
+	// Mapper handles: reserved placeholders (${username}, ${clientid}, ${year}...), ${file:/path}, ${VAR:?message}, ${VAR:-default}, ${VAR}
+	expanded, err := expandVariables(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand config variables: %w", err)
+	}
 
 	// Step 3: Restore escaped dollar signs
 	expanded = restoreDollarSigns(expanded)
 
-	// Parse YAML
+	// Parse YAML, keeping the node tree for the line numbers of each entry
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(expanded), &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
 	var cfg Config
-	if err := yaml.Unmarshal([]byte(expanded), &cfg); err != nil {
+	if err := doc.Decode(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	cfg.recordPositions(path, &doc)
+	cfg.resolveScriptFiles(filepath.Dir(path))
 
-	// Validate
-	if err := cfg.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid config: %w", err)
+	return &cfg, nil
+}
+
+// ValidationError is a single problem found by Validate
+type ValidationError struct {
+	File    string // Config file the entry came from (empty if not loaded from a file)
+	Line    int    // Line of the entry in File (0 if unknown)
+	Path    string // Offending entry, e.g. users[2] or bridges[0].topics[1] (indexes start at 0)
+	Message string
+}
+
+// Error formats the problem as file:line: path: message, so editors can jump to it
+func (e ValidationError) Error() string {
+	var b strings.Builder
+	if e.File != "" && e.Line > 0 {
+		fmt.Fprintf(&b, "%s:%d: ", e.File, e.Line)
+	}
+	if e.Path != "" {
+		b.WriteString(e.Path + ": ")
 	}
+	b.WriteString(e.Message)
+	return b.String()
+}
 
-	return &cfg, nil
+// ValidationErrors lists every problem found by Validate, in file order
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	problems := make([]string, len(e))
+	for i, err := range e {
+		problems[i] = err.Error()
+	}
+	return fmt.Sprintf("%d problems: %s", len(e), strings.Join(problems, "; "))
+}
+
+// addf records a problem with the entry at path, defined at pos
+func (e *ValidationErrors) addf(pos position, path, format string, args ...any) {
+	*e = append(*e, ValidationError{File: pos.file, Line: pos.line, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// resolveScriptFiles makes relative script file paths relative to baseDir (the
+// directory of the config file that references them) instead of the working
+// directory the server happens to be started from
+func (c *Config) resolveScriptFiles(baseDir string) {
+	for i, script := range c.Scripts {
+		if script.File != "" && !filepath.IsAbs(script.File) {
+			c.Scripts[i].File = filepath.Join(baseDir, script.File)
+		}
+	}
+}
+
+// Source returns the script's JavaScript, reading it from File if set
+func (s ScriptConfig) Source() (string, error) {
+	if s.File == "" {
+		return s.Content, nil
+	}
+	// #nosec G304 -- Script paths come from the operator's config file
+	content, err := os.ReadFile(s.File)
+	if err != nil {
+		return "", fmt.Errorf("failed to read script file '%s': %w", s.File, err)
+	}
+	return string(content), nil
 }
 
-// Validate checks if the config is valid
+// ValidateScripts reads the source of every script and passes it to check
+// (normally a syntax check), reporting unreadable files and failed checks like
+// Validate does. Validate itself never touches the filesystem
+func (c *Config) ValidateScripts(check func(content string) error) error {
+	var errs ValidationErrors
+	for i, script := range c.Scripts {
+		path := fmt.Sprintf("scripts[%d]", i)
+		content, err := script.Source()
+		if err != nil {
+			errs.addf(script.pos, path, "script '%s': %v", script.Name, err)
+			continue
+		}
+		if err := check(content); err != nil {
+			errs.addf(script.pos, path, "script '%s': %v", script.Name, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Validate checks if the config is valid. It reports every problem rather than
+// stopping at the first; the returned error is a ValidationErrors
 func (c *Config) Validate() error {
+	var errs ValidationErrors
+
 	// Check for duplicate usernames
 	seen := make(map[string]bool)
-	for _, user := range c.Users {
+	for i, user := range c.Users {
+		path := fmt.Sprintf("users[%d]", i)
 		if user.Username == "" {
-			return fmt.Errorf("user missing username")
+			errs.addf(user.pos, path, "user missing username")
+		} else if seen[user.Username] {
+			errs.addf(user.pos, path, "duplicate username: %s", user.Username)
 		}
+		seen[user.Username] = true
 		if user.Password == "" {
-			return fmt.Errorf("user '%s' missing password", user.Username)
+			errs.addf(user.pos, path, "user '%s' missing password", user.Username)
 		}
-		if seen[user.Username] {
-			return fmt.Errorf("duplicate username: %s", user.Username)
+		if err := validateCIDRs(user.AllowedCIDRs); err != nil {
+			errs.addf(user.pos, path, "user '%s' allowed_cidrs: %v", user.Username, err)
+		}
+		if err := validateCIDRs(user.DeniedCIDRs); err != nil {
+			errs.addf(user.pos, path, "user '%s' denied_cidrs: %v", user.Username, err)
 		}
-		seen[user.Username] = true
 	}
 
 	// Validate ACL rules
@@ -197,78 +436,132 @@ func (c *Config) Validate() error {
 		validUsernames[user.Username] = true
 	}
 
-	for _, rule := range c.ACLRules {
+	aclRules := make(map[string]bool)
+	for i, rule := range c.ACLRules {
+		path := fmt.Sprintf("acl_rules[%d]", i)
 		if rule.Username == "" {
-			return fmt.Errorf("ACL rule missing username")
+			errs.addf(rule.pos, path, "ACL rule missing username")
+		} else if !validUsernames[rule.Username] {
+			// Check if username exists
+			errs.addf(rule.pos, path, "ACL rule references unknown user: %s", rule.Username)
 		}
 		if rule.Topic == "" {
-			return fmt.Errorf("ACL rule for user '%s' missing topic", rule.Username)
+			errs.addf(rule.pos, path, "ACL rule for user '%s' missing topic", rule.Username)
+		} else if aclRules[aclRuleKey(rule)] {
+			errs.addf(rule.pos, path, "duplicate ACL rule for user '%s' on topic: %s", rule.Username, rule.Topic)
 		}
+		aclRules[aclRuleKey(rule)] = true
+
+		// Validate permission
 		if rule.Permission == "" {
-			return fmt.Errorf("ACL rule for user '%s' missing permission", rule.Username)
+			errs.addf(rule.pos, path, "ACL rule for user '%s' missing permission", rule.Username)
+		} else if rule.Permission != "pub" && rule.Permission != "sub" && rule.Permission != "pubsub" {
+			errs.addf(rule.pos, path, "ACL rule for user '%s' has invalid permission: %s (must be pub, sub, or pubsub)", rule.Username, rule.Permission)
 		}
+	}
 
-		// Check if username exists
-		if !validUsernames[rule.Username] {
-			return fmt.Errorf("ACL rule references unknown user: %s", rule.Username)
+	// Validate default ACL templates
+	defaultTopics := make(map[string]bool)
+	for i, rule := range c.DefaultACL {
+		path := fmt.Sprintf("default_acl[%d]", i)
+		if rule.Topic == "" {
+			errs.addf(rule.pos, path, "default ACL rule missing topic")
+		} else if defaultTopics[rule.Topic] {
+			errs.addf(rule.pos, path, "duplicate default ACL rule topic: %s", rule.Topic)
 		}
-
-		// Validate permission
+		defaultTopics[rule.Topic] = true
 		if rule.Permission != "pub" && rule.Permission != "sub" && rule.Permission != "pubsub" {
-			return fmt.Errorf("ACL rule for user '%s' has invalid permission: %s (must be pub, sub, or pubsub)", rule.Username, rule.Permission)
+			errs.addf(rule.pos, path, "default ACL rule has invalid permission: %s (must be pub, sub, or pubsub)", rule.Permission)
+		}
+	}
+
+	// Validate startup retained messages
+	retainedTopics := make(map[string]bool)
+	for i, msg := range c.Retained {
+		path := fmt.Sprintf("retained[%d]", i)
+		if msg.Topic == "" {
+			errs.addf(msg.pos, path, "retained message missing topic")
+		} else if strings.ContainsAny(msg.Topic, "+#") {
+			errs.addf(msg.pos, path, "retained message topic '%s' must not contain wildcards", msg.Topic)
+		} else if retainedTopics[msg.Topic] {
+			errs.addf(msg.pos, path, "duplicate retained message topic: %s", msg.Topic)
+		}
+		retainedTopics[msg.Topic] = true
+		if msg.QoS < 0 || msg.QoS > 2 {
+			errs.addf(msg.pos, path, "retained message '%s' has invalid qos: %d (must be 0, 1, or 2)", msg.Topic, msg.QoS)
 		}
 	}
 
 	// Validate bridges
 	bridgeNames := make(map[string]bool)
-	for _, bridge := range c.Bridges {
+	for i, bridge := range c.Bridges {
+		path := fmt.Sprintf("bridges[%d]", i)
 		if bridge.Name == "" {
-			return fmt.Errorf("bridge missing name")
+			errs.addf(bridge.pos, path, "bridge missing name")
+		} else if bridgeNames[bridge.Name] {
+			errs.addf(bridge.pos, path, "duplicate bridge name: %s", bridge.Name)
 		}
+		bridgeNames[bridge.Name] = true
 		if bridge.Host == "" {
-			return fmt.Errorf("bridge '%s' missing host", bridge.Name)
-		}
-		if bridgeNames[bridge.Name] {
-			return fmt.Errorf("duplicate bridge name: %s", bridge.Name)
+			errs.addf(bridge.pos, path, "bridge '%s' missing host", bridge.Name)
 		}
-		bridgeNames[bridge.Name] = true
 
 		// Set defaults
 		if bridge.Port == 0 {
 			bridge.Port = 1883
 		}
 		if bridge.Port < 1 || bridge.Port > 65535 {
-			return fmt.Errorf("bridge '%s' has invalid port: %d", bridge.Name, bridge.Port)
+			errs.addf(bridge.pos, path, "bridge '%s' has invalid port: %d", bridge.Name, bridge.Port)
+		}
+		// The remote broker only resumes a session for the same client ID
+		if !bridge.UsesCleanSession() && bridge.ClientID == "" {
+			errs.addf(bridge.pos, path, "bridge '%s' uses clean_session: false but has no client_id (persistent sessions need a stable client ID)", bridge.Name)
+		}
+		if bridge.MaxQoS != nil && (*bridge.MaxQoS < 0 || *bridge.MaxQoS > 2) {
+			errs.addf(bridge.pos, path, "bridge '%s' has invalid max_qos %d (must be 0, 1, or 2)", bridge.Name, *bridge.MaxQoS)
+		}
+		if bridge.RetainPolicy != "" && bridge.RetainPolicy != "preserve" && bridge.RetainPolicy != "clear" {
+			errs.addf(bridge.pos, path, "bridge '%s' has invalid retain_policy '%s' (must be preserve or clear)", bridge.Name, bridge.RetainPolicy)
 		}
 
 		// Validate topics
 		if len(bridge.Topics) == 0 {
-			return fmt.Errorf("bridge '%s' has no topics configured", bridge.Name)
+			errs.addf(bridge.pos, path, "bridge '%s' has no topics configured", bridge.Name)
 		}
-		for _, topic := range bridge.Topics {
+		topicsValid := true
+		for j, topic := range bridge.Topics {
+			topicPath := fmt.Sprintf("%s.topics[%d]", path, j)
+			problems := len(errs)
 			if topic.Local == "" {
-				return fmt.Errorf("bridge '%s' has topic with empty local", bridge.Name)
+				errs.addf(topic.pos, topicPath, "bridge '%s' has topic with empty local", bridge.Name)
 			}
 			if topic.Remote == "" {
-				return fmt.Errorf("bridge '%s' has topic with empty remote", bridge.Name)
+				errs.addf(topic.pos, topicPath, "bridge '%s' has topic with empty remote", bridge.Name)
 			}
 			if topic.Direction != "in" && topic.Direction != "out" && topic.Direction != "both" {
-				return fmt.Errorf("bridge '%s' has invalid direction '%s' (must be in, out, or both)", bridge.Name, topic.Direction)
+				errs.addf(topic.pos, topicPath, "bridge '%s' has invalid direction '%s' (must be in, out, or both)", bridge.Name, topic.Direction)
 			}
 			if topic.QoS < 0 || topic.QoS > 2 {
-				return fmt.Errorf("bridge '%s' has invalid QoS %d (must be 0, 1, or 2)", bridge.Name, topic.QoS)
+				errs.addf(topic.pos, topicPath, "bridge '%s' has invalid QoS %d (must be 0, 1, or 2)", bridge.Name, topic.QoS)
+			}
+			topicsValid = topicsValid && len(errs) == problems
+		}
+		// Comparing mappings only makes sense once each one is valid on its own
+		if topicsValid {
+			if err := validateBridgeTopicMappings(bridge); err != nil {
+				errs.addf(bridge.pos, path, "%v", err)
 			}
 		}
 	}
 
 	// Validate scripts
 	scriptNames := make(map[string]bool)
-	for _, script := range c.Scripts {
+	for i, script := range c.Scripts {
+		path := fmt.Sprintf("scripts[%d]", i)
 		if script.Name == "" {
-			return fmt.Errorf("script missing name")
-		}
-		if scriptNames[script.Name] {
-			return fmt.Errorf("duplicate script name: %s", script.Name)
+			errs.addf(script.pos, path, "script missing name")
+		} else if scriptNames[script.Name] {
+			errs.addf(script.pos, path, "duplicate script name: %s", script.Name)
 		}
 		scriptNames[script.Name] = true
 
@@ -276,39 +569,98 @@ func (c *Config) Validate() error {
 		hasFile := script.File != ""
 		hasContent := script.Content != ""
 		if !hasFile && !hasContent {
-			return fmt.Errorf("script '%s' must have either file or content", script.Name)
+			errs.addf(script.pos, path, "script '%s' must have either file or content", script.Name)
 		}
 		if hasFile && hasContent {
-			return fmt.Errorf("script '%s' cannot have both file and content", script.Name)
+			errs.addf(script.pos, path, "script '%s' cannot have both file and content", script.Name)
 		}
 
 		// Validate triggers
 		if len(script.Triggers) == 0 {
-			return fmt.Errorf("script '%s' has no triggers configured", script.Name)
+			errs.addf(script.pos, path, "script '%s' has no triggers configured", script.Name)
 		}
-		for i, trigger := range script.Triggers {
-			if trigger.Type == "" {
-				return fmt.Errorf("script '%s' trigger %d missing type", script.Name, i+1)
-			}
+		for j, trigger := range script.Triggers {
+			triggerPath := fmt.Sprintf("%s.triggers[%d]", path, j)
 			// Validate trigger type
-			validTriggers := []string{"on_publish", "on_connect", "on_disconnect", "on_subscribe"}
-			valid := false
-			for _, vt := range validTriggers {
-				if trigger.Type == vt {
-					valid = true
-					break
-				}
-			}
-			if !valid {
-				return fmt.Errorf("script '%s' has invalid type '%s' (must be one of: on_publish, on_connect, on_disconnect, on_subscribe)", script.Name, trigger.Type)
+			switch trigger.Type {
+			case "":
+				errs.addf(trigger.pos, triggerPath, "script '%s' trigger missing type", script.Name)
+			case "on_publish", "on_connect", "on_disconnect", "on_subscribe":
+			default:
+				errs.addf(trigger.pos, triggerPath, "script '%s' has invalid type '%s' (must be one of: on_publish, on_connect, on_disconnect, on_subscribe)", script.Name, trigger.Type)
 			}
 
 			// Set default priority
 			if trigger.Priority == 0 {
-				script.Triggers[i].Priority = 100
+				script.Triggers[j].Priority = 100
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateBridgeTopicMappings checks a bridge's topic mappings against each other:
+// the same local topic forwarded out twice or the same remote topic subscribed
+// twice duplicates every message, and an "out" mapping whose remote topic is
+// subscribed back by an "in" mapping onto the same local topics echoes messages
+// (topics are numbered from 1 in errors, matching their order in the file)
+func validateBridgeTopicMappings(bridge BridgeConfig) error {
+	forwardsOut := func(t BridgeTopicConfig) bool { return t.Direction == "out" || t.Direction == "both" }
+	forwardsIn := func(t BridgeTopicConfig) bool { return t.Direction == "in" || t.Direction == "both" }
+
+	for i, a := range bridge.Topics {
+		for j := i + 1; j < len(bridge.Topics); j++ {
+			b := bridge.Topics[j]
+
+			if forwardsOut(a) && forwardsOut(b) && a.Local == b.Local {
+				return fmt.Errorf("bridge '%s' forwards local topic '%s' out more than once (topics %d and %d)",
+					bridge.Name, a.Local, i+1, j+1)
+			}
+			if forwardsIn(a) && forwardsIn(b) && a.Remote == b.Remote {
+				return fmt.Errorf("bridge '%s' subscribes to remote topic '%s' more than once (topics %d and %d)",
+					bridge.Name, a.Remote, i+1, j+1)
+			}
+
+			// Check both orderings for an out mapping that feeds an in mapping
+			for _, pair := range [][2]int{{i, j}, {j, i}} {
+				out, in := bridge.Topics[pair[0]], bridge.Topics[pair[1]]
+				if forwardsOut(out) && forwardsIn(in) &&
+					filtersOverlap(out.Remote, in.Remote) && filtersOverlap(out.Local, in.Local) {
+					return fmt.Errorf("bridge '%s' topics %d and %d form an echo loop: local '%s' is forwarded to remote '%s' and subscribed back from remote '%s' to local '%s'",
+						bridge.Name, pair[0]+1, pair[1]+1, out.Local, out.Remote, in.Remote, in.Local)
+				}
 			}
 		}
 	}
 
 	return nil
 }
+
+// filtersOverlap reports whether at least one topic matches both MQTT topic filters
+func filtersOverlap(a, b string) bool {
+	aLevels := strings.Split(a, "/")
+	bLevels := strings.Split(b, "/")
+
+	for i := 0; ; i++ {
+		aDone, bDone := i >= len(aLevels), i >= len(bLevels)
+		switch {
+		case aDone && bDone:
+			return true
+		case aDone:
+			return bLevels[i] == "#" // "a/#" also matches "a"
+		case bDone:
+			return aLevels[i] == "#"
+		}
+
+		if aLevels[i] == "#" || bLevels[i] == "#" {
+			return true
+		}
+		if aLevels[i] != "+" && bLevels[i] != "+" && aLevels[i] != bLevels[i] {
+			return false
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -8,6 +9,12 @@ import (
 )
 
 func TestLoad(t *testing.T) {
+	secretPath := filepath.Join(t.TempDir(), "mqtt_password")
+	// Secret files usually end with a newline that isn't part of the value
+	if err := os.WriteFile(secretPath, []byte("s3cret-from-file\n"), 0600); err != nil {
+		t.Fatalf("failed to write secret: %v", err)
+	}
+
 	tests := []struct {
 		name        string
 		configYAML  string
@@ -200,6 +207,77 @@ acl_rules:
 			wantErr:     true,
 			errContains: "missing topic",
 		},
+		{
+			name: "default ACL keeps placeholders",
+			configYAML: `
+default_acl:
+  - topic: "devices/${clientid}/#"
+    permission: pubsub
+  - topic: "users/${username}/status"
+    permission: pub
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *Config) {
+				if len(cfg.DefaultACL) != 2 {
+					t.Fatalf("expected 2 default ACL rules, got %d", len(cfg.DefaultACL))
+				}
+				if cfg.DefaultACL[0].Topic != "devices/${clientid}/#" {
+					t.Errorf("expected topic 'devices/${clientid}/#', got '%s'", cfg.DefaultACL[0].Topic)
+				}
+			},
+		},
+		{
+			name: "user with IP ranges",
+			configYAML: `
+users:
+  - username: test_user
+    password: pass123
+    allowed_cidrs: ["10.0.0.0/8", "192.168.1.5"]
+    denied_cidrs: ["10.0.99.0/24"]
+`,
+			wantErr: false,
+		},
+		{
+			name: "user with invalid CIDR",
+			configYAML: `
+users:
+  - username: test_user
+    password: pass123
+    allowed_cidrs: ["10.0.0.0/40"]
+`,
+			wantErr:     true,
+			errContains: "invalid CIDR",
+		},
+		{
+			name: "startup retained message",
+			configYAML: `
+retained:
+  - topic: broker/status
+    payload: online
+    qos: 1
+`,
+			wantErr: false,
+		},
+		{
+			name: "startup retained message with wildcard",
+			configYAML: `
+retained:
+  - topic: broker/#
+    payload: online
+`,
+			wantErr:     true,
+			errContains: "wildcards",
+		},
+		{
+			name: "default ACL invalid permission",
+			configYAML: `
+default_acl:
+  - topic: "devices/${clientid}/#"
+    permission: all
+`,
+			wantErr:     true,
+			errContains: "invalid permission",
+		},
 		{
 			name: "multiple users and rules",
 			configYAML: `
@@ -255,6 +333,81 @@ acl_rules: []
 				}
 			},
 		},
+		{
+			name: "secret read from file",
+			configYAML: `
+users:
+  - username: sensor
+    password: "${file:` + secretPath + `}"
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Users[0].Password != "s3cret-from-file" {
+					t.Errorf("expected password 's3cret-from-file', got '%s'", cfg.Users[0].Password)
+				}
+			},
+		},
+		{
+			name: "missing secret file",
+			configYAML: `
+users:
+  - username: sensor
+    password: "${file:` + secretPath + `.missing}"
+`,
+			wantErr:     true,
+			errContains: "failed to read secret file",
+		},
+		{
+			name: "required env var set",
+			configYAML: `
+users:
+  - username: sensor
+    password: "${REQUIRED_PASSWORD:?must set}"
+`,
+			envVars: map[string]string{
+				"REQUIRED_PASSWORD": "present",
+			},
+			wantErr: false,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Users[0].Password != "present" {
+					t.Errorf("expected password 'present', got '%s'", cfg.Users[0].Password)
+				}
+			},
+		},
+		{
+			name: "required env var unset with message",
+			configYAML: `
+users:
+  - username: sensor
+    password: "${MISSING_PASSWORD:?must set}"
+`,
+			wantErr:     true,
+			errContains: "MISSING_PASSWORD: must set",
+		},
+		{
+			name: "required env var unset without message",
+			configYAML: `
+users:
+  - username: sensor
+    password: "${MISSING_PASSWORD:?}"
+`,
+			wantErr:     true,
+			errContains: "MISSING_PASSWORD: required variable is not set",
+		},
+		{
+			name: "default containing :?",
+			configYAML: `
+users:
+  - username: sensor
+    password: "${MISSING_PASSWORD:-a:?b}"
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *Config) {
+				if cfg.Users[0].Password != "a:?b" {
+					t.Errorf("expected password 'a:?b', got '%s'", cfg.Users[0].Password)
+				}
+			},
+		},
 		{
 			name: "valid script with script_file",
 			configYAML: `
@@ -585,6 +738,91 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestValidateReportsAllProblems(t *testing.T) {
+	configYAML := `
+users:
+  - username: sensor
+    password: secret
+  - username: camera
+acl_rules:
+  - username: sensor
+    topic: sensors/#
+    permission: pubsub
+  - username: ghost
+    topic: ghost/#
+    permission: pub
+`
+	configPath := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(configPath, []byte(configYAML), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	_, err := Load(configPath)
+	if err == nil {
+		t.Fatal("Load() error = nil, want both problems")
+	}
+	for _, want := range []string{
+		"2 problems",
+		"users[1]: user 'camera' missing password",
+		"acl_rules[1]: ACL rule references unknown user: ghost",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Load() error = %q, want containing %q", err.Error(), want)
+		}
+	}
+
+	var problems ValidationErrors
+	if !errors.As(err, &problems) {
+		t.Fatalf("Load() error is %T, want ValidationErrors", err)
+	}
+	if len(problems) != 2 || problems[0].Path != "users[1]" || problems[1].Path != "acl_rules[1]" {
+		t.Errorf("problems = %+v", problems)
+	}
+}
+
+func TestValidateErrorLineNumbers(t *testing.T) {
+	configYAML := `users:
+  - username: sensor
+    password: secret
+acl_rules:
+  - username: sensor
+    topic: sensors/#
+    permission: pubsub
+  - username: ghost
+    topic: ghost/#
+    permission: pub
+bridges:
+  - name: cloud
+    host: mqtt.example.com
+    topics:
+      - local: a/#
+        remote: b/#
+        direction: sideways
+`
+	configPath := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(configPath, []byte(configYAML), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	_, err := Load(configPath)
+	var problems ValidationErrors
+	if !errors.As(err, &problems) || len(problems) != 2 {
+		t.Fatalf("Load() error = %v, want 2 validation problems", err)
+	}
+
+	// The line of the list item ("- username: ghost")
+	if got := problems[0]; got.File != configPath || got.Line != 8 || got.Path != "acl_rules[1]" {
+		t.Errorf("unknown user problem = %+v, want %s line 8", got, configPath)
+	}
+	if want := configPath + ":8: acl_rules[1]: ACL rule references unknown user: ghost"; !strings.Contains(err.Error(), want) {
+		t.Errorf("Load() error = %q, want containing %q", err.Error(), want)
+	}
+	// Nested entries get their own line
+	if got := problems[1]; got.Line != 15 || got.Path != "bridges[0].topics[0]" {
+		t.Errorf("bridge topic problem = %+v, want line 15", got)
+	}
+}
+
 // Helper function to check if string contains substring
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(substr) == 0 ||
@@ -816,8 +1054,10 @@ scripts:
 func TestReservedPlaceholdersStillWork(t *testing.T) {
 	os.Setenv("username", "SHOULD_NOT_EXPAND") // Set conflicting env var
 	os.Setenv("clientid", "SHOULD_NOT_EXPAND")
+	os.Setenv("year", "SHOULD_NOT_EXPAND")
 	defer os.Unsetenv("username")
 	defer os.Unsetenv("clientid")
+	defer os.Unsetenv("year")
 
 	configYAML := `
 users:
@@ -831,6 +1071,9 @@ acl_rules:
   - username: testuser
     topic: "device/${clientid}/#"
     permission: sub
+  - username: testuser
+    topic: "data/${year}/${month}/${day}/#"
+    permission: pub
 `
 
 	tmpDir := t.TempDir()
@@ -851,4 +1094,163 @@ acl_rules:
 	if cfg.ACLRules[1].Topic != "device/${clientid}/#" {
 		t.Errorf("Expected ${clientid} to be preserved, got: %s", cfg.ACLRules[1].Topic)
 	}
+	if cfg.ACLRules[2].Topic != "data/${year}/${month}/${day}/#" {
+		t.Errorf("Expected date placeholders to be preserved, got: %s", cfg.ACLRules[2].Topic)
+	}
+}
+
+func TestValidateBridgeTopicMappings(t *testing.T) {
+	tests := []struct {
+		name        string
+		topics      []BridgeTopicConfig
+		errContains string
+	}{
+		{
+			name: "distinct mappings",
+			topics: []BridgeTopicConfig{
+				{Local: "sensors/#", Remote: "edge/sensors/#", Direction: "out"},
+				{Local: "commands/#", Remote: "cloud/commands/#", Direction: "in"},
+				{Local: "shared/#", Remote: "shared/#", Direction: "both"},
+			},
+		},
+		{
+			name: "same local topic forwarded out twice",
+			topics: []BridgeTopicConfig{
+				{Local: "sensors/#", Remote: "edge/a/#", Direction: "out"},
+				{Local: "sensors/#", Remote: "edge/b/#", Direction: "both"},
+			},
+			errContains: "forwards local topic 'sensors/#' out more than once (topics 1 and 2)",
+		},
+		{
+			name: "same remote topic subscribed twice",
+			topics: []BridgeTopicConfig{
+				{Local: "a/#", Remote: "cloud/#", Direction: "in"},
+				{Local: "b/#", Remote: "cloud/#", Direction: "in"},
+			},
+			errContains: "subscribes to remote topic 'cloud/#' more than once",
+		},
+		{
+			name: "out and in mappings form a self-loop",
+			topics: []BridgeTopicConfig{
+				{Local: "data/#", Remote: "edge/data/#", Direction: "in"},
+				{Local: "data/+/temp", Remote: "edge/data/+/temp", Direction: "out"},
+			},
+			errContains: "topics 2 and 1 form an echo loop",
+		},
+		{
+			name: "out and in on disjoint local topics",
+			topics: []BridgeTopicConfig{
+				{Local: "up/#", Remote: "relay/#", Direction: "out"},
+				{Local: "down/#", Remote: "relay/#", Direction: "in"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBridgeTopicMappings(BridgeConfig{Name: "edge", Host: "localhost", Topics: tt.topics})
+			if tt.errContains == "" {
+				if err != nil {
+					t.Errorf("validateBridgeTopicMappings() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("validateBridgeTopicMappings() error = %v, want containing %q", err, tt.errContains)
+			}
+		})
+	}
+}
+
+func TestValidateBridgeDeliveryPolicy(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	tests := []struct {
+		name         string
+		maxQoS       *int
+		retainPolicy string
+		errContains  string
+	}{
+		{name: "defaults"},
+		{name: "max qos 1 and clear retain", maxQoS: intPtr(1), retainPolicy: "clear"},
+		{name: "max qos out of range", maxQoS: intPtr(3), errContains: "invalid max_qos 3"},
+		{name: "negative max qos", maxQoS: intPtr(-1), errContains: "invalid max_qos -1"},
+		{name: "unknown retain policy", retainPolicy: "drop", errContains: "invalid retain_policy 'drop'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Bridges: []BridgeConfig{{
+				Name:         "edge",
+				Host:         "localhost",
+				MaxQoS:       tt.maxQoS,
+				RetainPolicy: tt.retainPolicy,
+				Topics:       []BridgeTopicConfig{{Local: "a/#", Remote: "b/#", Direction: "out"}},
+			}}}
+			err := cfg.Validate()
+			if tt.errContains == "" {
+				if err != nil {
+					t.Errorf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Validate() error = %v, want containing %q", err, tt.errContains)
+			}
+		})
+	}
+}
+
+func TestValidateBridgePersistentSession(t *testing.T) {
+	persistent := false
+	bridge := BridgeConfig{
+		Name:         "edge",
+		Host:         "localhost",
+		CleanSession: &persistent,
+		Topics:       []BridgeTopicConfig{{Local: "a/#", Remote: "b/#", Direction: "in"}},
+	}
+
+	err := (&Config{Bridges: []BridgeConfig{bridge}}).Validate()
+	if err == nil || !strings.Contains(err.Error(), "has no client_id") {
+		t.Errorf("Validate() error = %v, want missing client_id error", err)
+	}
+
+	bridge.ClientID = "edge-001"
+	if err := (&Config{Bridges: []BridgeConfig{bridge}}).Validate(); err != nil {
+		t.Errorf("Validate() unexpected error with client_id: %v", err)
+	}
+
+	// Omitting clean_session means a clean session, which needs no client ID
+	bridge.ClientID = ""
+	bridge.CleanSession = nil
+	if !bridge.UsesCleanSession() {
+		t.Error("UsesCleanSession() = false for unset clean_session, want true")
+	}
+	if err := (&Config{Bridges: []BridgeConfig{bridge}}).Validate(); err != nil {
+		t.Errorf("Validate() unexpected error for default clean session: %v", err)
+	}
+}
+
+func TestFiltersOverlap(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/c", false},
+		{"a/+", "a/b", true},
+		{"a/#", "a", true},
+		{"a/#", "b/#", false},
+		{"a/+/c", "a/b/+", true},
+		{"a/+", "a/b/c", false},
+		{"#", "anything/at/all", true},
+	}
+
+	for _, tt := range tests {
+		if got := filtersOverlap(tt.a, tt.b); got != tt.want {
+			t.Errorf("filtersOverlap(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := filtersOverlap(tt.b, tt.a); got != tt.want {
+			t.Errorf("filtersOverlap(%q, %q) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
 }
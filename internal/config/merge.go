@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LoadDir loads every *.yml and *.yaml file in dir in lexical order and merges
+// them into one config, so users, bridges, and scripts can live in separate
+// files. Later files override earlier ones by unique name (see Merge), and the
+// combined config is validated as a whole, so an ACL rule may reference a user
+// defined in another file
+func LoadDir(dir string) (*Config, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config directory: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if !entry.IsDir() && (ext == ".yml" || ext == ".yaml") {
+			files = append(files, entry.Name())
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no *.yml or *.yaml files in config directory %s", dir)
+	}
+	sort.Strings(files)
+
+	merged := &Config{}
+	for _, name := range files {
+		cfg, err := parseFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		merged.Merge(cfg)
+	}
+
+	if err := merged.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return merged, nil
+}
+
+// Merge overlays other onto c. Entries are matched by their unique name
+// (username, bridge name, script name; username and topic for ACL rules;
+// topic for default ACL rules and retained messages): a match is replaced in
+// place, anything new is appended. Only entries of c are matched, so two
+// entries with the same name in other are both kept for Validate to report
+func (c *Config) Merge(other *Config) {
+	c.Users = mergeByKey(c.Users, other.Users, func(u MQTTUserConfig) string { return u.Username })
+	c.ACLRules = mergeByKey(c.ACLRules, other.ACLRules, aclRuleKey)
+	c.Bridges = mergeByKey(c.Bridges, other.Bridges, func(b BridgeConfig) string { return b.Name })
+	c.Scripts = mergeByKey(c.Scripts, other.Scripts, func(s ScriptConfig) string { return s.Name })
+	c.DefaultACL = mergeByKey(c.DefaultACL, other.DefaultACL, func(r DefaultACLRuleConfig) string { return r.Topic })
+	c.Retained = mergeByKey(c.Retained, other.Retained, func(m RetainedMessageConfig) string { return m.Topic })
+}
+
+// aclRuleKey identifies an ACL rule by its username and topic
+func aclRuleKey(r ACLRuleConfig) string {
+	return r.Username + "\x00" + r.Topic
+}
+
+// mergeByKey replaces items of base whose key matches an overlay item and
+// appends the remaining overlay items in order. Each base item is replaced at
+// most once; a second overlay item with its key is appended as a duplicate
+func mergeByKey[T any](base, overlay []T, key func(T) string) []T {
+	index := make(map[string]int, len(base))
+	for i, item := range base {
+		index[key(item)] = i
+	}
+	for _, item := range overlay {
+		k := key(item)
+		if i, ok := index[k]; ok {
+			base[i] = item
+			delete(index, k)
+			continue
+		}
+		base = append(base, item)
+	}
+	return base
+}
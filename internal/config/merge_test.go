@@ -0,0 +1,162 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfigDir writes files into a temporary directory and returns its path
+func writeConfigDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{
+		"10-users.yml": `
+users:
+  - username: sensor
+    password: pass1
+    description: "Sensors"
+  - username: camera
+    password: pass2
+`,
+		"20-acl.yaml": `
+acl_rules:
+  - username: sensor
+    topic: "sensors/${username}/#"
+    permission: pubsub
+  - username: camera
+    topic: "video/${clientid}"
+    permission: pub
+`,
+		"30-overrides.yml": `
+users:
+  - username: sensor
+    password: pass3
+    description: "Sensors (override)"
+bridges:
+  - name: cloud
+    host: mqtt.example.com
+    topics:
+      - local: "sensors/#"
+        remote: "edge/sensors/#"
+        direction: out
+scripts:
+  - name: logger
+    enabled: true
+    content: "log.info(msg.topic);"
+    triggers:
+      - type: on_publish
+        enabled: true
+`,
+		"README.md": "not a config file",
+	})
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load(dir) error = %v", err)
+	}
+
+	if len(cfg.Users) != 2 {
+		t.Fatalf("expected 2 users, got %d", len(cfg.Users))
+	}
+	// The later file replaces sensor in place
+	if cfg.Users[0].Username != "sensor" || cfg.Users[0].Password != "pass3" || cfg.Users[0].Description != "Sensors (override)" {
+		t.Errorf("sensor = %+v, want the 30-overrides.yml definition", cfg.Users[0])
+	}
+	if len(cfg.ACLRules) != 2 {
+		t.Errorf("expected 2 ACL rules, got %d", len(cfg.ACLRules))
+	}
+	if cfg.ACLRules[0].Topic != "sensors/${username}/#" {
+		t.Errorf("ACL topic = %q, want placeholders preserved", cfg.ACLRules[0].Topic)
+	}
+	if len(cfg.Bridges) != 1 || cfg.Bridges[0].Name != "cloud" {
+		t.Errorf("bridges = %+v, want cloud", cfg.Bridges)
+	}
+	if len(cfg.Scripts) != 1 || cfg.Scripts[0].Triggers[0].Priority != 100 {
+		t.Errorf("scripts = %+v, want logger with default priority", cfg.Scripts)
+	}
+}
+
+func TestLoadDir_ValidatesCombinedConfig(t *testing.T) {
+	// Each file is fine on its own, but the rule references a user no file defines
+	dir := writeConfigDir(t, map[string]string{
+		"users.yml": `
+users:
+  - username: sensor
+    password: pass1
+`,
+		"acl.yml": `
+acl_rules:
+  - username: camera
+    topic: "video/#"
+    permission: pub
+`,
+	})
+
+	_, err := Load(dir)
+	if err == nil || !strings.Contains(err.Error(), "unknown user") {
+		t.Errorf("Load(dir) error = %v, want unknown user", err)
+	}
+}
+
+func TestLoadDir_DuplicatesWithinFile(t *testing.T) {
+	// A later file may override an earlier one, but a file repeating a name is a mistake
+	dir := writeConfigDir(t, map[string]string{
+		"10-users.yml": `
+users:
+  - username: sensor
+    password: pass1
+`,
+		"20-users.yml": `
+users:
+  - username: sensor
+    password: pass2
+  - username: sensor
+    password: pass3
+acl_rules:
+  - username: sensor
+    topic: "sensors/#"
+    permission: pub
+  - username: sensor
+    topic: "sensors/#"
+    permission: sub
+`,
+	})
+
+	_, err := LoadDir(dir)
+	if err == nil {
+		t.Fatal("LoadDir() error = nil, want duplicates within 20-users.yml reported")
+	}
+	for _, want := range []string{"20-users.yml:5", "duplicate username: sensor", "20-users.yml:11", "duplicate ACL rule for user 'sensor'"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadDir() error = %v, want it to contain %q", err, want)
+		}
+	}
+}
+
+func TestLoadDir_Empty(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{"notes.txt": "nothing here"})
+
+	if _, err := LoadDir(dir); err == nil {
+		t.Error("LoadDir() should fail for a directory without config files")
+	}
+}
+
+func TestLoadDir_ParseErrorNamesFile(t *testing.T) {
+	dir := writeConfigDir(t, map[string]string{"broken.yml": "users: [unclosed"})
+
+	_, err := LoadDir(dir)
+	if err == nil || !strings.Contains(err.Error(), "broken.yml") {
+		t.Errorf("LoadDir() error = %v, want it to name broken.yml", err)
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import "gopkg.in/yaml.v3"
+
+// position is where a config entry was defined, reported by Validate
+// Lines are counted after variable expansion, so a multi-line ${VAR} value
+// above an entry shifts its line
+type position struct {
+	file string
+	line int
+}
+
+// recordPositions stores the file and line of every list entry in c, using
+// the YAML node tree c was decoded from. Positions travel with the entries
+// through Merge, so errors point at the right file of a config directory
+func (c *Config) recordPositions(file string, doc *yaml.Node) {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return
+	}
+	root := doc.Content[0]
+	at := func(node *yaml.Node) position {
+		return position{file: file, line: node.Line}
+	}
+
+	for i, node := range sequenceItems(root, "users") {
+		if i < len(c.Users) {
+			c.Users[i].pos = at(node)
+		}
+	}
+	for i, node := range sequenceItems(root, "acl_rules") {
+		if i < len(c.ACLRules) {
+			c.ACLRules[i].pos = at(node)
+		}
+	}
+	for i, node := range sequenceItems(root, "default_acl") {
+		if i < len(c.DefaultACL) {
+			c.DefaultACL[i].pos = at(node)
+		}
+	}
+	for i, node := range sequenceItems(root, "retained") {
+		if i < len(c.Retained) {
+			c.Retained[i].pos = at(node)
+		}
+	}
+	for i, node := range sequenceItems(root, "bridges") {
+		if i >= len(c.Bridges) {
+			break
+		}
+		c.Bridges[i].pos = at(node)
+		for j, topic := range sequenceItems(node, "topics") {
+			if j < len(c.Bridges[i].Topics) {
+				c.Bridges[i].Topics[j].pos = at(topic)
+			}
+		}
+	}
+	for i, node := range sequenceItems(root, "scripts") {
+		if i >= len(c.Scripts) {
+			break
+		}
+		c.Scripts[i].pos = at(node)
+		for j, trigger := range sequenceItems(node, "triggers") {
+			if j < len(c.Scripts[i].Triggers) {
+				c.Scripts[i].Triggers[j].pos = at(trigger)
+			}
+		}
+	}
+}
+
+// sequenceItems returns the items of the sequence stored under key in a
+// mapping node, or nil if there is none
+func sequenceItems(mapping *yaml.Node, key string) []*yaml.Node {
+	if mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			if value := mapping.Content[i+1]; value.Kind == yaml.SequenceNode {
+				return value.Content
+			}
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+// Package duration parses durations that may be given in days, as used by
+// retention settings and maintenance endpoints
+package duration
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var daysPattern = regexp.MustCompile(`^(\d+)d$`)
+
+// Parse parses a duration string that supports days (e.g., "30d", "7d", "24h", "1h30m")
+// Supports all standard Go duration units plus "d" for days (24 hours)
+func Parse(s string) (time.Duration, error) {
+	// Check for days suffix
+	if matches := daysPattern.FindStringSubmatch(s); matches != nil {
+		days, _ := strconv.Atoi(matches[1])
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	// Fall back to standard time.ParseDuration for other formats
+	return time.ParseDuration(s)
+}
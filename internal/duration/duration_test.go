@@ -0,0 +1,46 @@
+package duration
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		// Days
+		{"1 day", "1d", 24 * time.Hour, false},
+		{"7 days", "7d", 7 * 24 * time.Hour, false},
+		{"30 days", "30d", 30 * 24 * time.Hour, false},
+		{"90 days", "90d", 90 * 24 * time.Hour, false},
+
+		// Standard durations
+		{"1 hour", "1h", 1 * time.Hour, false},
+		{"30 minutes", "30m", 30 * time.Minute, false},
+		{"1 hour 30 minutes", "1h30m", 1*time.Hour + 30*time.Minute, false},
+		{"24 hours", "24h", 24 * time.Hour, false},
+
+		// Edge cases
+		{"0 days", "0d", 0, false},
+		{"invalid", "invalid", 0, true},
+		{"negative", "-1d", 0, true},
+		{"empty", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("Parse(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
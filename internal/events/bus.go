@@ -0,0 +1,111 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types published on the bus
+const (
+	ClientConnected    = "client.connected"
+	ClientDisconnected = "client.disconnected"
+	ClientTakenOver    = "client.taken_over" // A new connection replaced a connected client's session
+	MessagePublished   = "message.published"
+	BridgeStatus       = "bridge.status"
+
+	// Client table changes (tracked client records in the database)
+	ClientAdded   = "client.added"
+	ClientUpdated = "client.updated"
+	ClientRemoved = "client.removed"
+)
+
+// subscriberBufferSize is the number of events buffered per subscriber before events are dropped
+const subscriberBufferSize = 256
+
+// Event represents a live broker event
+type Event struct {
+	Type      string                 `json:"type"`
+	Timestamp time.Time              `json:"timestamp"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+// Bus is an in-process publish/subscribe event bus
+// Publishing never blocks: events are dropped for subscribers that fall behind
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription receives events from the bus
+type Subscription struct {
+	C     <-chan Event
+	ch    chan Event
+	types map[string]bool // empty = all types
+	bus   *Bus
+	once  sync.Once
+}
+
+// NewBus creates a new event bus
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe registers a subscriber for the given event types (all types if none are given)
+func (b *Bus) Subscribe(types ...string) *Subscription {
+	ch := make(chan Event, subscriberBufferSize)
+	sub := &Subscription{
+		C:     ch,
+		ch:    ch,
+		types: make(map[string]bool, len(types)),
+		bus:   b,
+	}
+	for _, t := range types {
+		sub.types[t] = true
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub
+}
+
+// Publish sends an event to all matching subscribers
+// Safe to call on a nil bus so hooks work without one configured
+func (b *Bus) Publish(eventType string, data map[string]interface{}) {
+	if b == nil {
+		return
+	}
+
+	event := Event{
+		Type:      eventType,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers {
+		if len(sub.types) > 0 && !sub.types[eventType] {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			// Subscriber is too slow, drop the event
+		}
+	}
+}
+
+// Close unsubscribes and closes the subscription channel
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subscribers, s)
+		s.bus.mu.Unlock()
+		close(s.ch)
+	})
+}
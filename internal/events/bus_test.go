@@ -0,0 +1,63 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBus_PublishSubscribe(t *testing.T) {
+	bus := NewBus()
+
+	all := bus.Subscribe()
+	defer all.Close()
+	connects := bus.Subscribe(ClientConnected)
+	defer connects.Close()
+
+	bus.Publish(ClientConnected, map[string]interface{}{"client_id": "device-1"})
+	bus.Publish(MessagePublished, map[string]interface{}{"topic": "a/b"})
+
+	for _, want := range []string{ClientConnected, MessagePublished} {
+		select {
+		case event := <-all.C:
+			if event.Type != want {
+				t.Errorf("unfiltered subscriber got %s, want %s", event.Type, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("unfiltered subscriber did not receive %s", want)
+		}
+	}
+
+	select {
+	case event := <-connects.C:
+		if event.Type != ClientConnected || event.Data["client_id"] != "device-1" {
+			t.Errorf("filtered subscriber got %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("filtered subscriber did not receive client.connected")
+	}
+
+	select {
+	case event := <-connects.C:
+		t.Errorf("filtered subscriber received unexpected %s event", event.Type)
+	default:
+	}
+}
+
+func TestBus_Close(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe()
+	sub.Close()
+	sub.Close() // Safe to call twice
+
+	if _, ok := <-sub.C; ok {
+		t.Error("Expected channel to be closed")
+	}
+
+	// Publishing after close must not panic
+	bus.Publish(ClientConnected, nil)
+}
+
+func TestBus_NilPublish(t *testing.T) {
+	var bus *Bus
+	bus.Publish(ClientConnected, nil) // Must not panic
+}
@@ -0,0 +1,84 @@
+package mqtt
+
+import (
+	"fmt"
+	"log/slog"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github/bromq-dev/bromq/internal/events"
+)
+
+// Policies for a client connecting with the ID of a connected client
+const (
+	ClientIDPolicyTakeover = "takeover" // Disconnect the existing client (MQTT default)
+	ClientIDPolicyReject   = "reject"   // Refuse the new client
+)
+
+// EventPublisher emits live broker events (see events.Bus)
+type EventPublisher interface {
+	Publish(eventType string, data map[string]interface{})
+}
+
+// ClientIDPolicyHook applies the client ID policy to connecting clients whose
+// ID is already connected, refusing them or recording the session takeover
+// It is a connect check (see Server.AddConnectCheck): only authenticated clients
+// can take over a session or learn from a refusal that an ID is connected
+type ClientIDPolicyHook struct {
+	server    *mqtt.Server
+	reject    bool
+	publisher EventPublisher
+}
+
+// NewClientIDPolicyHook creates a hook applying cfg.ClientIDPolicy
+func NewClientIDPolicyHook(server *mqtt.Server, cfg *Config) (*ClientIDPolicyHook, error) {
+	hook := &ClientIDPolicyHook{server: server}
+	switch cfg.ClientIDPolicy {
+	case ClientIDPolicyTakeover, "":
+	case ClientIDPolicyReject:
+		hook.reject = true
+	default:
+		return nil, fmt.Errorf("invalid client ID policy '%s' (expected %s or %s)", cfg.ClientIDPolicy, ClientIDPolicyTakeover, ClientIDPolicyReject)
+	}
+	return hook, nil
+}
+
+// SetEvents publishes a client.taken_over event for each session takeover
+func (h *ClientIDPolicyHook) SetEvents(publisher EventPublisher) {
+	h.publisher = publisher
+}
+
+// OnAuthenticated refuses a duplicate client ID under the reject policy,
+// otherwise records that mochi is about to take over the existing session
+func (h *ClientIDPolicyHook) OnAuthenticated(cl *mqtt.Client, pk packets.Packet) error {
+	existing, ok := h.server.Clients.Get(cl.ID)
+	if !ok || existing.Net.Inline || existing.Closed() {
+		return nil
+	}
+
+	if h.reject {
+		slog.Warn("Rejected client with the ID of a connected client",
+			"client_id", cl.ID,
+			"remote", cl.Net.Remote,
+			"connected_remote", existing.Net.Remote)
+		// Mapped to Identifier Rejected for v3 clients by SendConnack
+		_ = h.server.SendConnack(cl, packets.ErrClientIdentifierNotValid, false, nil)
+		return packets.ErrClientIdentifierNotValid
+	}
+
+	slog.Info("Client session taken over",
+		"client_id", cl.ID,
+		"remote", cl.Net.Remote,
+		"previous_remote", existing.Net.Remote)
+	if h.publisher != nil {
+		h.publisher.Publish(events.ClientTakenOver, map[string]interface{}{
+			"client_id":         cl.ID,
+			"username":          string(pk.Connect.Username),
+			"remote":            cl.Net.Remote,
+			"previous_remote":   existing.Net.Remote,
+			"previous_username": string(existing.Properties.Username),
+		})
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package mqtt
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github/bromq-dev/bromq/internal/events"
+)
+
+func TestClientIDPolicyHook(t *testing.T) {
+	start := func(t *testing.T, policy string, bus *events.Bus, authHook mqtt.Hook) *Server {
+		t.Helper()
+		server := New(&Config{TCPAddr: "127.0.0.1:0", ClientIDPolicy: policy})
+		hook, err := NewClientIDPolicyHook(server.Server, server.config)
+		if err != nil {
+			t.Fatalf("NewClientIDPolicyHook() error = %v", err)
+		}
+		hook.SetEvents(bus)
+		server.AddConnectCheck(hook)
+		if err := server.AddAuthHook(authHook); err != nil {
+			t.Fatalf("AddAuthHook() error = %v", err)
+		}
+		if err := server.Start(); err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+		t.Cleanup(func() { _ = server.Close() })
+		return server
+	}
+
+	t.Run("reject refuses a duplicate client ID", func(t *testing.T) {
+		server := start(t, ClientIDPolicyReject, nil, new(auth.AllowHook))
+
+		existing, code := connectV5(t, tcpAddress(server), "device-1")
+		if code != packets.CodeSuccess.Code {
+			t.Fatalf("first CONNACK = %#x, want success", code)
+		}
+		if _, code := connectV5(t, tcpAddress(server), "device-1"); code != packets.ErrClientIdentifierNotValid.Code {
+			t.Errorf("duplicate CONNACK = %#x, want %#x (client identifier not valid)", code, packets.ErrClientIdentifierNotValid.Code)
+		}
+
+		// The connected client keeps its session
+		_ = existing.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		if n, err := existing.Read(make([]byte, 1)); n > 0 || !isTimeout(err) {
+			t.Errorf("existing client got %d bytes, %v; want it left connected", n, err)
+		}
+		if cl, ok := server.Clients.Get("device-1"); !ok || cl.Closed() {
+			t.Error("existing client was disconnected")
+		}
+	})
+
+	t.Run("takeover drops the existing client", func(t *testing.T) {
+		bus := events.NewBus()
+		sub := bus.Subscribe(events.ClientTakenOver)
+		defer sub.Close()
+		server := start(t, ClientIDPolicyTakeover, bus, new(auth.AllowHook))
+
+		existing, code := connectV5(t, tcpAddress(server), "device-1")
+		if code != packets.CodeSuccess.Code {
+			t.Fatalf("first CONNACK = %#x, want success", code)
+		}
+		if _, code := connectV5(t, tcpAddress(server), "device-1"); code != packets.CodeSuccess.Code {
+			t.Fatalf("takeover CONNACK = %#x, want success", code)
+		}
+
+		// The old connection gets a DISCONNECT (session taken over) and is closed
+		_ = existing.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if _, err := io.ReadAll(existing); err != nil {
+			t.Errorf("existing client still connected after takeover: %v", err)
+		}
+
+		select {
+		case event := <-sub.C:
+			if event.Data["client_id"] != "device-1" {
+				t.Errorf("client.taken_over event = %+v, want client_id device-1", event.Data)
+			}
+		case <-time.After(time.Second):
+			t.Error("no client.taken_over event published")
+		}
+	})
+
+	t.Run("unauthenticated clients are not checked", func(t *testing.T) {
+		for _, policy := range []string{ClientIDPolicyReject, ClientIDPolicyTakeover} {
+			bus := events.NewBus()
+			sub := bus.Subscribe(events.ClientTakenOver)
+			server := start(t, policy, bus, &passwordAuthHook{password: "secret"})
+
+			if _, code := connectV5As(t, tcpAddress(server), "device-1", "secret"); code != packets.CodeSuccess.Code {
+				t.Fatalf("%s: first CONNACK = %#x, want success", policy, code)
+			}
+			// A peer with a wrong password can neither take over the session
+			// nor learn from a refusal that the ID is connected
+			if _, code := connectV5As(t, tcpAddress(server), "device-1", "guess"); code != packets.ErrBadUsernameOrPassword.Code {
+				t.Errorf("%s: unauthenticated duplicate CONNACK = %#x, want %#x (bad username or password)", policy, code, packets.ErrBadUsernameOrPassword.Code)
+			}
+			select {
+			case event := <-sub.C:
+				t.Errorf("%s: client.taken_over published for an unauthenticated client: %+v", policy, event.Data)
+			case <-time.After(50 * time.Millisecond):
+			}
+			sub.Close()
+		}
+	})
+
+	t.Run("unknown policy", func(t *testing.T) {
+		server := New(&Config{ClientIDPolicy: "newest"})
+		if _, err := NewClientIDPolicyHook(server.Server, server.config); err == nil {
+			t.Error("NewClientIDPolicyHook() accepted an unknown policy")
+		}
+	})
+}
+
+// passwordAuthHook accepts clients sending password
+type passwordAuthHook struct {
+	mqtt.HookBase
+	password string
+}
+
+func (h *passwordAuthHook) ID() string { return "password-auth" }
+
+func (h *passwordAuthHook) Provides(b byte) bool { return b == mqtt.OnConnectAuthenticate }
+
+func (h *passwordAuthHook) OnConnectAuthenticate(cl *mqtt.Client, pk packets.Packet) bool {
+	return string(pk.Connect.Password) == h.password
+}
+
+// isTimeout reports whether err is a network read deadline error
+func isTimeout(err error) bool {
+	var netErr interface{ Timeout() bool }
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
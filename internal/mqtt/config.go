@@ -1,25 +1,157 @@
 package mqtt
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Listener IDs, as reported in mochi's cl.Net.Listener
+const (
+	ListenerTCP       = "tcp"
+	ListenerWebSocket = "ws"
+	ListenerUnix      = "unix"
+)
+
 // Config holds MQTT server configuration
 type Config struct {
-	TCPAddr         string `env:"MQTT_TCP_ADDR" flag:"mqtt-tcp" default:":1883" desc:"MQTT TCP listener address"`
-	WSAddr          string `env:"MQTT_WS_ADDR" flag:"mqtt-ws" default:":8883" desc:"MQTT WebSocket listener address"`
-	EnableTLS       bool   `env:"MQTT_ENABLE_TLS" flag:"mqtt-tls" desc:"Enable TLS for MQTT connections"`
-	TLSCertFile     string `env:"MQTT_TLS_CERT" flag:"mqtt-tls-cert" desc:"TLS certificate file path"`
-	TLSKeyFile      string `env:"MQTT_TLS_KEY" flag:"mqtt-tls-key" desc:"TLS key file path"`
-	MaxClients      int    `env:"MQTT_MAX_CLIENTS" flag:"mqtt-max-clients" default:"0" desc:"Maximum number of concurrent clients (0 = unlimited)"`
-	RetainAvailable bool   `env:"MQTT_RETAIN_AVAILABLE" flag:"mqtt-retain" default:"true" desc:"Enable retained messages"`
-	AllowAnonymous  bool   `env:"MQTT_ALLOW_ANONYMOUS" flag:"mqtt-allow-anonymous" desc:"Allow clients to connect without credentials (insecure)"`
+	TCPAddr            string `env:"MQTT_TCP_ADDR" flag:"mqtt-tcp" default:":1883" desc:"MQTT TCP listener address"`
+	WSAddr             string `env:"MQTT_WS_ADDR" flag:"mqtt-ws" default:":8883" desc:"MQTT WebSocket listener address"`
+	UnixSocket         string `env:"MQTT_UNIX_SOCKET" flag:"mqtt-unix-socket" desc:"Path of a Unix domain socket to also accept MQTT connections on (empty = disabled); clients have no source IP, so MQTT_ALLOWED_CIDRS rejects them"`
+	EnableTLS          bool   `env:"MQTT_ENABLE_TLS" flag:"mqtt-tls" desc:"Enable TLS for MQTT connections"`
+	TLSCertFile        string `env:"MQTT_TLS_CERT" flag:"mqtt-tls-cert" desc:"TLS certificate file path"`
+	TLSKeyFile         string `env:"MQTT_TLS_KEY" flag:"mqtt-tls-key" desc:"TLS key file path"`
+	MaxClients         int    `env:"MQTT_MAX_CLIENTS" flag:"mqtt-max-clients" default:"0" desc:"Maximum number of concurrent clients (0 = unlimited)"`
+	RetainAvailable    bool   `env:"MQTT_RETAIN_AVAILABLE" flag:"mqtt-retain" default:"true" desc:"Enable retained messages"`
+	AllowAnonymous     bool   `env:"MQTT_ALLOW_ANONYMOUS" flag:"mqtt-allow-anonymous" desc:"Allow clients to connect without credentials (insecure)"`
+	AnonymousListeners string `env:"MQTT_ANONYMOUS_LISTENERS" flag:"mqtt-anonymous-listeners" desc:"Comma-separated listeners (tcp, ws, unix) that accept anonymous clients; overrides MQTT_ALLOW_ANONYMOUS per listener when set"`
+	AnonymousACL       string `env:"MQTT_ANONYMOUS_ACL" flag:"mqtt-anonymous-acl" desc:"ACL for anonymous clients as comma-separated topic:permission pairs, e.g. public/#:sub (empty = rules of the MQTT user named anonymous)"`
+
+	// Retained publishes need an ACL rule with retain set; others have the flag stripped
+	RetainRequiresPermission bool `env:"MQTT_RETAIN_REQUIRES_PERMISSION" flag:"mqtt-retain-requires-permission" desc:"Only let clients publish retained messages on topics where an ACL rule grants retain (the retain flag is stripped otherwise)"`
+
+	// Source address filtering for every client; MQTT users can narrow it further
+	AllowedCIDRs string `env:"MQTT_ALLOWED_CIDRS" flag:"mqtt-allowed-cidrs" desc:"Comma-separated networks (CIDR or IP) clients may connect from (empty = any)"`
+	DeniedCIDRs  string `env:"MQTT_DENIED_CIDRS" flag:"mqtt-denied-cidrs" desc:"Comma-separated networks (CIDR or IP) whose clients are always rejected"`
+
+	// PROXY protocol on the TCP listener, for brokers behind a TCP load balancer
+	ProxyProtocol     bool   `env:"MQTT_PROXY_PROTOCOL" flag:"mqtt-proxy-protocol" desc:"Accept PROXY protocol v1/v2 headers on the TCP listener so the original client address is recorded and used for IP filtering"`
+	ProxyTrustedCIDRs string `env:"MQTT_PROXY_TRUSTED_CIDRS" flag:"mqtt-proxy-trusted-cidrs" desc:"Comma-separated networks (CIDR or IP) of load balancers allowed to send PROXY headers (required with MQTT_PROXY_PROTOCOL)"`
+
+	// Session limits (0 = unlimited)
+	MaxKeepalive          time.Duration `env:"MQTT_MAX_KEEPALIVE" flag:"mqtt-max-keepalive" default:"0" desc:"Maximum client keepalive (0 = unlimited)"`
+	SessionExpiryMax      time.Duration `env:"MQTT_SESSION_EXPIRY_MAX" flag:"mqtt-session-expiry-max" default:"0" desc:"Maximum session expiry interval (0 = unlimited)"`
+	RejectExcessiveLimits bool          `env:"MQTT_REJECT_EXCESSIVE_LIMITS" flag:"mqtt-reject-excessive-limits" desc:"Reject clients requesting more than the keepalive/session expiry maximums instead of clamping"`
+
+	// Connecting with the ID of a connected client
+	ClientIDPolicy string `env:"MQTT_CLIENT_ID_POLICY" flag:"mqtt-client-id-policy" default:"takeover" desc:"When a client connects with the ID of a connected client: takeover (disconnect the existing client) or reject (refuse the new one until the existing client disconnects or times out)"`
+
+	// Reconnect throttling per client ID (0 = disabled)
+	ReconnectLimit  int           `env:"MQTT_RECONNECT_LIMIT" flag:"mqtt-reconnect-limit" default:"0" desc:"Refuse a client ID that connects (with valid credentials) more than this many times within MQTT_RECONNECT_WINDOW, with Connection Rate Exceeded (0 = disabled)"`
+	ReconnectWindow time.Duration `env:"MQTT_RECONNECT_WINDOW" flag:"mqtt-reconnect-window" default:"1m" desc:"Window over which MQTT_RECONNECT_LIMIT counts connects, starting at a client ID's first connect; a throttled client is let in again when it ends"`
+
+	// Last wills on denied topics, or ones the client's ACL doesn't allow publishing to
+	WillDenyTopics string `env:"MQTT_WILL_DENY_TOPICS" flag:"mqtt-will-deny-topics" desc:"Comma-separated topic filters clients may not set a last will on, e.g. $SYS/#,alerts/#"`
+	WillCheckACL   bool   `env:"MQTT_WILL_CHECK_ACL" flag:"mqtt-will-check-acl" desc:"Also deny wills on topics the client's ACL doesn't let it publish to"`
+	WillPolicy     string `env:"MQTT_WILL_POLICY" flag:"mqtt-will-policy" default:"strip" desc:"What to do with a client whose will is denied: strip (connect without the will) or reject (refuse with Not Authorized)"`
+
+	// Idle clients (0 = disabled)
+	IdleTimeout time.Duration `env:"MQTT_IDLE_TIMEOUT" flag:"mqtt-idle-timeout" default:"0" desc:"Disconnect clients that send nothing, not even a keepalive ping, for this long, e.g. 30m (0 = disabled)"`
+
+	// Where retained messages are persisted
+	RetainedBackend string `env:"MQTT_RETAINED_BACKEND" flag:"mqtt-retained-backend" default:"badger" desc:"Store for retained messages: badger (high write throughput) or sql (the main database, topics up to 512 bytes)"`
+
+	// Persistent sessions survive restarts (stored in BadgerDB)
+	DurableSessions bool `env:"MQTT_DURABLE_SESSIONS" flag:"mqtt-durable-sessions" desc:"Persist the subscriptions and undelivered QoS 1/2 messages of non-clean-session clients across broker restarts"`
+
+	// Per-client message buffers (0 = broker default of 8192)
+	MaxInflight int `env:"MQTT_MAX_INFLIGHT" flag:"mqtt-max-inflight" default:"0" desc:"Maximum QoS 1/2 messages held per client awaiting delivery or acknowledgement (max 65535)"`
+	MaxQueued   int `env:"MQTT_MAX_QUEUED" flag:"mqtt-max-queued" default:"0" desc:"Maximum outbound messages buffered per client before new messages are dropped"`
+
+	// Payload size limits per topic filter, checked in order (first match wins)
+	TopicSizeLimits string `env:"MQTT_TOPIC_SIZE_LIMITS" flag:"mqtt-topic-size-limits" desc:"Max payload size per topic filter as comma-separated filter:size pairs, e.g. firmware/#:5MB,#:256KB (first match wins, empty = unlimited)"`
+
+	// Topic limits for publishes and subscriptions (0 = unlimited)
+	MaxTopicLength int `env:"MQTT_MAX_TOPIC_LENGTH" flag:"mqtt-max-topic-length" default:"0" desc:"Reject publishes and subscriptions whose topic is longer than this many bytes (0 = unlimited)"`
+	MaxTopicLevels int `env:"MQTT_MAX_TOPIC_LEVELS" flag:"mqtt-max-topic-levels" default:"0" desc:"Reject publishes and subscriptions whose topic has more levels than this (0 = unlimited)"`
+
+	// Dead-letter topic for publishes rejected by ACL or payload limits
+	DeadLetterTopic string `env:"MQTT_DEAD_LETTER_TOPIC" flag:"mqtt-dead-letter-topic" desc:"Publish a JSON summary (topic, reason, client) of each rejected publish to this topic (empty = disabled)"`
+
+	// Payloads masked wherever the broker shows or stores a copy of them
+	RedactTopics string `env:"MQTT_REDACT_TOPICS" flag:"mqtt-redact-topics" desc:"Comma-separated topic filters whose payloads are masked in live events, recordings, the last value cache and script logs, e.g. secrets/#,+/credentials (delivery is unchanged)"`
+
+	// ACL denial logging (denials are always counted in mqtt_acl_denials_total)
+	LogACLDenials        bool          `env:"MQTT_LOG_ACL_DENIALS" flag:"mqtt-log-acl-denials" desc:"Log denied publishes/subscribes (sampled)"`
+	ACLDenialLogInterval time.Duration `env:"MQTT_ACL_DENIAL_LOG_INTERVAL" flag:"mqtt-acl-denial-log-interval" default:"10s" desc:"Log at most one ACL denial per interval; the rest are summarized in the next line"`
+
+	// Unmatched ACL audit (tracks denials no rule matched, see GET /api/acl/unmatched)
+	ACLAuditUnmatched bool `env:"MQTT_ACL_AUDIT_UNMATCHED" flag:"mqtt-acl-audit-unmatched" desc:"Record publishes/subscribes denied because no ACL rule matched"`
+	ACLAuditSize      int  `env:"MQTT_ACL_AUDIT_SIZE" flag:"mqtt-acl-audit-size" default:"50" desc:"Maximum unmatched attempts kept per user"`
+
+	// $SYS topic publishing
+	SysTopicsEnabled bool          `env:"MQTT_SYS_TOPICS" flag:"mqtt-sys-topics" desc:"Publish broker stats under $SYS/broker/..."`
+	SysInterval      time.Duration `env:"MQTT_SYS_INTERVAL" flag:"mqtt-sys-interval" default:"10s" desc:"Interval between $SYS topic updates"`
 }
 
 // DefaultConfig returns a default MQTT configuration
 func DefaultConfig() *Config {
 	return &Config{
-		TCPAddr:         ":1883",
-		WSAddr:          ":8883",
-		EnableTLS:       false,
-		MaxClients:      0, // Unlimited
-		RetainAvailable: true,
-		AllowAnonymous:  false, // Disabled by default for security
+		TCPAddr:          ":1883",
+		WSAddr:           ":8883",
+		EnableTLS:        false,
+		MaxClients:       0, // Unlimited
+		ClientIDPolicy:   ClientIDPolicyTakeover,
+		WillPolicy:       WillPolicyStrip,
+		RetainAvailable:  true,
+		AllowAnonymous:   false, // Disabled by default for security
+		SysTopicsEnabled: false, // Opt-in, so upgrades don't start publishing stats
+		SysInterval:      10 * time.Second,
+	}
+}
+
+// PostParse validates settings that refer to each other
+func (c *Config) PostParse() error {
+	configured := map[string]bool{
+		ListenerTCP:       c.TCPAddr != "",
+		ListenerWebSocket: c.WSAddr != "",
+		ListenerUnix:      c.UnixSocket != "",
+	}
+	for _, listener := range strings.Split(c.AnonymousListeners, ",") {
+		listener = strings.TrimSpace(listener)
+		if listener == "" {
+			continue
+		}
+		enabled, known := configured[listener]
+		if !known {
+			return fmt.Errorf("MQTT_ANONYMOUS_LISTENERS: unknown listener %q (expected tcp, ws or unix)", listener)
+		}
+		if !enabled {
+			return fmt.Errorf("MQTT_ANONYMOUS_LISTENERS: listener %q is not configured", listener)
+		}
+	}
+	return nil
+}
+
+// AnonymousPolicy returns whether each listener accepts anonymous clients
+// Without MQTT_ANONYMOUS_LISTENERS every listener follows AllowAnonymous
+func (c *Config) AnonymousPolicy() map[string]bool {
+	policy := map[string]bool{
+		ListenerTCP:       c.AllowAnonymous,
+		ListenerWebSocket: c.AllowAnonymous,
+		ListenerUnix:      c.AllowAnonymous,
+	}
+	if strings.TrimSpace(c.AnonymousListeners) == "" {
+		return policy
+	}
+
+	for listener := range policy {
+		policy[listener] = false
+	}
+	for _, listener := range strings.Split(c.AnonymousListeners, ",") {
+		if listener = strings.TrimSpace(listener); listener != "" {
+			policy[listener] = true
+		}
 	}
+	return policy
 }
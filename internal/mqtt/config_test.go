@@ -0,0 +1,28 @@
+package mqtt
+
+import "testing"
+
+func TestConfigPostParse_AnonymousListeners(t *testing.T) {
+	tests := []struct {
+		name      string
+		listeners string
+		unix      string
+		wantErr   bool
+	}{
+		{name: "unset", listeners: ""},
+		{name: "configured listeners", listeners: "tcp, ws"},
+		{name: "unix socket configured", listeners: "unix", unix: "/tmp/bromq.sock"},
+		{name: "misspelled listener", listeners: "tcp,websocket", wantErr: true},
+		{name: "unix socket not configured", listeners: "unix", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.AnonymousListeners = tt.listeners
+			cfg.UnixSocket = tt.unix
+			if err := cfg.PostParse(); (err != nil) != tt.wantErr {
+				t.Errorf("PostParse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
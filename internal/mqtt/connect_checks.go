@@ -0,0 +1,42 @@
+package mqtt
+
+import (
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// ConnectCheck vets a client once it has authenticated, before its session is
+// established. Mochi runs OnConnect before authentication, so a check there
+// would act on (and reveal state to) peers that never prove who they are
+type ConnectCheck interface {
+	// OnAuthenticated returns an error to refuse the client, after sending it
+	// a CONNACK with the reason
+	OnAuthenticated(cl *mqtt.Client, pk packets.Packet) error
+}
+
+// AddConnectCheck runs check on every client the auth hook accepts, in the
+// order checks were added. Add checks before the server starts
+func (s *Server) AddConnectCheck(check ConnectCheck) {
+	s.connectChecks = append(s.connectChecks, check)
+}
+
+// checkedAuthHook wraps the auth hook so the connect checks run once it accepts a client
+type checkedAuthHook struct {
+	mqtt.Hook
+	server *Server
+}
+
+// OnConnectAuthenticate authenticates the client, then applies the connect checks
+// A refused client is stopped, so mochi's own CONNACK for the failure isn't sent
+func (h *checkedAuthHook) OnConnectAuthenticate(cl *mqtt.Client, pk packets.Packet) bool {
+	if !h.Hook.OnConnectAuthenticate(cl, pk) {
+		return false
+	}
+	for _, check := range h.server.connectChecks {
+		if err := check.OnAuthenticated(cl, pk); err != nil {
+			cl.Stop(err)
+			return false
+		}
+	}
+	return true
+}
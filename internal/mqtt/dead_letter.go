@@ -0,0 +1,59 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+)
+
+// DeadLetterMessage is the summary published for each rejected publish
+type DeadLetterMessage struct {
+	Topic     string    `json:"topic"`
+	Reason    string    `json:"reason"`
+	ClientID  string    `json:"client_id"`
+	Username  string    `json:"username,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// DeadLetter republishes a summary of rejected publishes to a topic, so
+// monitoring tools can consume rejections over MQTT
+// Summaries are sent by the inline client, which bypasses ACL and payload
+// limits, so a dead-letter publish is never rejected itself
+type DeadLetter struct {
+	server *mqtt.Server
+	topic  string
+}
+
+// NewDeadLetter creates a dead-letter publisher for topic
+func NewDeadLetter(server *mqtt.Server, topic string) *DeadLetter {
+	return &DeadLetter{server: server, topic: topic}
+}
+
+// Topic returns the dead-letter topic
+func (d *DeadLetter) Topic() string {
+	return d.topic
+}
+
+// RejectPublish publishes a summary of a publish to topic that was rejected for reason
+func (d *DeadLetter) RejectPublish(cl *mqtt.Client, topic, reason string) {
+	if cl.Net.Inline {
+		return // Never dead-letter the broker's own publishes
+	}
+
+	payload, err := json.Marshal(DeadLetterMessage{
+		Topic:     topic,
+		Reason:    reason,
+		ClientID:  cl.ID,
+		Username:  string(cl.Properties.Username),
+		Timestamp: time.Now().UTC(),
+	})
+	if err != nil {
+		return
+	}
+
+	if err := d.server.Publish(d.topic, payload, false, 0); err != nil {
+		slog.Warn("Failed to publish dead letter", "topic", d.topic, "error", err)
+	}
+}
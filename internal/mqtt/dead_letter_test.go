@@ -0,0 +1,67 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+func TestDeadLetter_PayloadLimit(t *testing.T) {
+	server := New(&Config{})
+	_ = server.AddHook(new(auth.AllowHook), nil)
+	limits, _ := ParseTopicSizeLimits("#:8")
+	hook := NewPayloadLimitsHook(limits)
+	hook.SetDeadLetter(NewDeadLetter(server.Server, "bromq/dead-letter"))
+	if err := server.AddHook(hook, nil); err != nil {
+		t.Fatalf("AddHook() error = %v", err)
+	}
+
+	deadLetters := make(chan packets.Packet, 4)
+	delivered := make(chan packets.Packet, 4)
+	_ = server.Subscribe("bromq/dead-letter", 1, func(cl *mqtt.Client, sub packets.Subscription, pk packets.Packet) {
+		deadLetters <- pk
+	})
+	_ = server.Subscribe("sensors/#", 2, func(cl *mqtt.Client, sub packets.Subscription, pk packets.Packet) {
+		delivered <- pk
+	})
+	if err := server.Serve(); err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+	defer func() { _ = server.Close() }()
+
+	cl := server.NewClient(nil, ListenerTCP, "sensor-1", false)
+	cl.Properties.ProtocolVersion = 4
+	cl.Properties.Username = []byte("device")
+	cl.State.Inflight.ResetReceiveQuota(10) // Normally set by the CONNECT handshake
+	err := server.InjectPacket(cl, packets.Packet{
+		FixedHeader: packets.FixedHeader{Type: packets.Publish},
+		TopicName:   "sensors/temp",
+		Payload:     []byte("far too large"),
+	})
+	if err != nil {
+		t.Fatalf("InjectPacket() error = %v", err)
+	}
+
+	select {
+	case pk := <-deadLetters:
+		var msg DeadLetterMessage
+		if err := json.Unmarshal(pk.Payload, &msg); err != nil {
+			t.Fatalf("dead letter is not JSON: %v", err)
+		}
+		if msg.Topic != "sensors/temp" || msg.Reason != RejectReasonPayloadSize || msg.ClientID != "sensor-1" || msg.Username != "device" {
+			t.Errorf("dead letter = %+v", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no dead letter published for the rejected message")
+	}
+
+	select {
+	case pk := <-delivered:
+		t.Errorf("rejected message was delivered: %s", pk.Payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
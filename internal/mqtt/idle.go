@@ -0,0 +1,132 @@
+package mqtt
+
+import (
+	"bytes"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// IdleHook disconnects clients that have sent nothing, not even a keepalive ping,
+// for longer than the idle timeout. It frees the connection slots of zombie
+// devices whose keepalive is disabled or far longer than the timeout
+type IdleHook struct {
+	mqtt.HookBase
+	server   *mqtt.Server
+	timeout  time.Duration
+	lastSeen sync.Map // Client ID -> *atomic.Int64, unix nanoseconds of the last packet read
+	now      func() time.Time
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewIdleHook creates a hook disconnecting clients idle for longer than timeout
+func NewIdleHook(server *mqtt.Server, timeout time.Duration) *IdleHook {
+	return &IdleHook{
+		server:  server,
+		timeout: timeout,
+		now:     time.Now,
+		stop:    make(chan struct{}),
+	}
+}
+
+// ID returns the hook identifier
+func (h *IdleHook) ID() string {
+	return "idle-disconnect"
+}
+
+// Provides indicates which hook methods this hook provides
+func (h *IdleHook) Provides(b byte) bool {
+	return bytes.Contains([]byte{
+		mqtt.OnSessionEstablished,
+		mqtt.OnPacketRead,
+		mqtt.OnDisconnect,
+	}, []byte{b})
+}
+
+// Init starts the periodic idle check
+func (h *IdleHook) Init(config any) error {
+	// Check often enough that clients are dropped soon after crossing the timeout
+	interval := min(max(h.timeout/4, time.Second), time.Minute)
+	go h.loop(interval)
+	return nil
+}
+
+// Stop ends the periodic idle check
+func (h *IdleHook) Stop() error {
+	h.stopOnce.Do(func() { close(h.stop) })
+	return nil
+}
+
+func (h *IdleHook) loop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.sweep()
+		case <-h.stop:
+			return
+		}
+	}
+}
+
+// OnSessionEstablished starts tracking a connected client
+func (h *IdleHook) OnSessionEstablished(cl *mqtt.Client, pk packets.Packet) {
+	h.touch(cl.ID)
+}
+
+// OnPacketRead records activity for every packet a client sends, pings included
+func (h *IdleHook) OnPacketRead(cl *mqtt.Client, pk packets.Packet) (packets.Packet, error) {
+	h.touch(cl.ID)
+	return pk, nil
+}
+
+// OnDisconnect stops tracking a client
+func (h *IdleHook) OnDisconnect(cl *mqtt.Client, err error, expire bool) {
+	h.lastSeen.Delete(cl.ID)
+}
+
+// touch records activity for a client now
+func (h *IdleHook) touch(clientID string) {
+	now := h.now().UnixNano()
+	if seen, ok := h.lastSeen.Load(clientID); ok {
+		seen.(*atomic.Int64).Store(now)
+		return
+	}
+	seen := &atomic.Int64{}
+	seen.Store(now)
+	h.lastSeen.Store(clientID, seen)
+}
+
+// sweep disconnects every client idle for longer than the timeout and returns how many
+func (h *IdleHook) sweep() int {
+	cutoff := h.now().Add(-h.timeout).UnixNano()
+	disconnected := 0
+
+	for _, cl := range h.server.Clients.GetAll() {
+		if cl.Net.Inline || cl.Closed() {
+			continue
+		}
+		seen, ok := h.lastSeen.Load(cl.ID)
+		if !ok {
+			// Connected before tracking started (e.g. taken over); start the clock now
+			h.touch(cl.ID)
+			continue
+		}
+		if seen.(*atomic.Int64).Load() >= cutoff {
+			continue
+		}
+
+		slog.Info("Disconnecting idle client", "client_id", cl.ID, "idle_timeout", h.timeout)
+		_ = h.server.DisconnectClient(cl, packets.ErrKeepAliveTimeout)
+		h.lastSeen.Delete(cl.ID)
+		disconnected++
+	}
+	return disconnected
+}
@@ -0,0 +1,52 @@
+package mqtt
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestIdleHook_DisconnectsStaleClients(t *testing.T) {
+	server := New(&Config{})
+	hook := NewIdleHook(server.Server, time.Minute)
+
+	now := time.Now()
+	hook.now = func() time.Time { return now }
+
+	// addClient registers a connected client last active at lastSeen
+	addClient := func(id string, lastSeen time.Time) {
+		conn, peer := net.Pipe()
+		go func() { _, _ = io.Copy(io.Discard, peer) }()
+		t.Cleanup(func() { _ = peer.Close() })
+
+		cl := server.NewClient(conn, "tcp", id, false)
+		server.Clients.Add(cl)
+		hook.now = func() time.Time { return lastSeen }
+		hook.touch(id)
+		hook.now = func() time.Time { return now }
+	}
+	addClient("stale", now.Add(-2*time.Minute))
+	addClient("fresh", now.Add(-10*time.Second))
+
+	if got := hook.sweep(); got != 1 {
+		t.Errorf("sweep() disconnected %d clients, want 1", got)
+	}
+
+	stale, _ := server.Clients.Get("stale")
+	if !stale.Closed() {
+		t.Error("client idle past the timeout was not disconnected")
+	}
+	fresh, _ := server.Clients.Get("fresh")
+	if fresh.Closed() {
+		t.Error("recently active client was disconnected")
+	}
+
+	// Activity resets the clock
+	now = now.Add(55 * time.Second)
+	hook.touch("fresh")
+	now = now.Add(55 * time.Second)
+	if got := hook.sweep(); got != 0 || fresh.Closed() {
+		t.Errorf("sweep() after activity disconnected %d clients, want 0", got)
+	}
+}
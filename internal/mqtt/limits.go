@@ -0,0 +1,86 @@
+package mqtt
+
+import (
+	"bytes"
+	"log/slog"
+	"math"
+	"time"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// SessionLimitsHook enforces the configured keepalive and session expiry maximums
+// on connecting clients, either clamping the requested values or rejecting the client
+type SessionLimitsHook struct {
+	mqtt.HookBase
+	server           *mqtt.Server
+	maxKeepalive     uint16 // seconds, 0 = unlimited
+	sessionExpiryMax uint32 // seconds, 0 = unlimited
+	reject           bool
+}
+
+// NewSessionLimitsHook creates a hook enforcing the session limits in cfg
+func NewSessionLimitsHook(server *mqtt.Server, cfg *Config) *SessionLimitsHook {
+	return &SessionLimitsHook{
+		server:           server,
+		maxKeepalive:     uint16(durationSeconds(cfg.MaxKeepalive, math.MaxUint16)),
+		sessionExpiryMax: uint32(durationSeconds(cfg.SessionExpiryMax, math.MaxUint32)),
+		reject:           cfg.RejectExcessiveLimits,
+	}
+}
+
+// ID returns the hook identifier
+func (h *SessionLimitsHook) ID() string {
+	return "session-limits"
+}
+
+// Provides indicates which hook methods this hook provides
+func (h *SessionLimitsHook) Provides(b byte) bool {
+	return bytes.Contains([]byte{
+		mqtt.OnConnect,
+	}, []byte{b})
+}
+
+// OnConnect clamps or rejects an excessive keepalive or session expiry request
+func (h *SessionLimitsHook) OnConnect(cl *mqtt.Client, pk packets.Packet) error {
+	// A keepalive of 0 disables keepalive entirely, so it exceeds any maximum
+	keepaliveExceeded := h.maxKeepalive > 0 && (cl.State.Keepalive == 0 || cl.State.Keepalive > h.maxKeepalive)
+	expiryExceeded := h.sessionExpiryMax > 0 && cl.Properties.ProtocolVersion == 5 &&
+		cl.Properties.Props.SessionExpiryInterval > h.sessionExpiryMax
+
+	if h.reject && (keepaliveExceeded || expiryExceeded) {
+		slog.Warn("Rejected client exceeding session limits",
+			"client_id", cl.ID,
+			"keepalive", cl.State.Keepalive,
+			"session_expiry", cl.Properties.Props.SessionExpiryInterval)
+
+		code := packets.ErrImplementationSpecificError
+		if cl.Properties.ProtocolVersion < 5 {
+			code = packets.ErrServerUnavailable // v3 has no equivalent return code
+		}
+		_ = h.server.SendConnack(cl, code, false, nil)
+		return code
+	}
+
+	if keepaliveExceeded {
+		slog.Debug("Clamped client keepalive", "client_id", cl.ID, "requested", cl.State.Keepalive, "max", h.maxKeepalive)
+		cl.State.Keepalive = h.maxKeepalive
+		cl.State.ServerKeepalive = true // Sent to v5 clients as Server Keep Alive in the CONNACK
+	}
+
+	// Session expiry is clamped by the server's MaximumSessionExpiryInterval capability
+	// (see New), which also reports the granted value to the client in the CONNACK
+
+	return nil
+}
+
+// durationSeconds converts d to whole seconds, capped at limit
+// Positive durations under a second round up so they never mean "unlimited"
+func durationSeconds(d time.Duration, limit uint64) uint64 {
+	if d <= 0 {
+		return 0
+	}
+	secs := uint64((d + time.Second - 1) / time.Second)
+	return min(secs, limit)
+}
@@ -0,0 +1,129 @@
+package mqtt
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// connectPacket builds a CONNECT packet requesting the given keepalive and session expiry
+func connectPacket(version byte, keepalive uint16, sessionExpiry uint32) packets.Packet {
+	return packets.Packet{
+		FixedHeader:     packets.FixedHeader{Type: packets.Connect},
+		ProtocolVersion: version,
+		Connect: packets.ConnectParams{
+			ProtocolName:     []byte("MQTT"),
+			ClientIdentifier: "limits-client",
+			Keepalive:        keepalive,
+		},
+		Properties: packets.Properties{
+			SessionExpiryInterval:     sessionExpiry,
+			SessionExpiryIntervalFlag: sessionExpiry > 0,
+		},
+	}
+}
+
+func TestSessionLimitsHook_ClampsKeepalive(t *testing.T) {
+	tests := []struct {
+		name          string
+		keepalive     uint16
+		wantKeepalive uint16
+		wantServerSet bool
+	}{
+		{name: "excessive keepalive is clamped", keepalive: 3600, wantKeepalive: 60, wantServerSet: true},
+		{name: "disabled keepalive is clamped", keepalive: 0, wantKeepalive: 60, wantServerSet: true},
+		{name: "keepalive within limit is kept", keepalive: 30, wantKeepalive: 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := New(&Config{MaxKeepalive: time.Minute})
+			hook := NewSessionLimitsHook(server.Server, server.config)
+
+			pk := connectPacket(5, tt.keepalive, 0)
+			cl := server.NewClient(nil, "tcp", "", false)
+			cl.ParseConnect("tcp", pk)
+
+			if err := hook.OnConnect(cl, pk); err != nil {
+				t.Fatalf("OnConnect() error = %v", err)
+			}
+			if cl.State.Keepalive != tt.wantKeepalive {
+				t.Errorf("Keepalive = %d, want %d", cl.State.Keepalive, tt.wantKeepalive)
+			}
+			if cl.State.ServerKeepalive != tt.wantServerSet {
+				t.Errorf("ServerKeepalive = %v, want %v", cl.State.ServerKeepalive, tt.wantServerSet)
+			}
+		})
+	}
+}
+
+func TestSessionLimitsHook_Reject(t *testing.T) {
+	tests := []struct {
+		name          string
+		version       byte
+		keepalive     uint16
+		sessionExpiry uint32
+		wantErr       bool
+	}{
+		{name: "excessive keepalive", version: 5, keepalive: 3600, wantErr: true},
+		{name: "excessive session expiry", version: 5, keepalive: 30, sessionExpiry: 7200, wantErr: true},
+		{name: "excessive keepalive v3", version: 4, keepalive: 3600, wantErr: true},
+		{name: "within limits", version: 5, keepalive: 30, sessionExpiry: 600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := New(&Config{
+				MaxKeepalive:          time.Minute,
+				SessionExpiryMax:      time.Hour,
+				RejectExcessiveLimits: true,
+			})
+			hook := NewSessionLimitsHook(server.Server, server.config)
+
+			pk := connectPacket(tt.version, tt.keepalive, tt.sessionExpiry)
+			cl := server.NewClient(nil, "tcp", "", false)
+			cl.ParseConnect("tcp", pk)
+
+			err := hook.OnConnect(cl, pk)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OnConnect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && tt.version < 5 && !errors.Is(err, packets.ErrServerUnavailable) {
+				t.Errorf("OnConnect() error = %v, want %v for v3 clients", err, packets.ErrServerUnavailable)
+			}
+			if cl.State.Keepalive != tt.keepalive {
+				t.Errorf("Keepalive = %d, want unchanged %d", cl.State.Keepalive, tt.keepalive)
+			}
+		})
+	}
+}
+
+func TestNew_SessionExpiryMax(t *testing.T) {
+	server := New(&Config{SessionExpiryMax: time.Hour})
+
+	if got := server.Options.Capabilities.MaximumSessionExpiryInterval; got != 3600 {
+		t.Errorf("MaximumSessionExpiryInterval = %d, want 3600", got)
+	}
+}
+
+func TestDurationSeconds(t *testing.T) {
+	tests := []struct {
+		d     time.Duration
+		limit uint64
+		want  uint64
+	}{
+		{0, 100, 0},
+		{-time.Second, 100, 0},
+		{500 * time.Millisecond, 100, 1},
+		{90 * time.Second, 100, 90},
+		{time.Hour, 100, 100},
+	}
+
+	for _, tt := range tests {
+		if got := durationSeconds(tt.d, tt.limit); got != tt.want {
+			t.Errorf("durationSeconds(%v, %d) = %d, want %d", tt.d, tt.limit, got, tt.want)
+		}
+	}
+}
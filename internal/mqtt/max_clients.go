@@ -0,0 +1,82 @@
+package mqtt
+
+import (
+	"bytes"
+	"log/slog"
+	"sync/atomic"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// RejectReasonMaxClients is the rejection reason for connections over MQTT_MAX_CLIENTS
+const RejectReasonMaxClients = "max_clients"
+
+// MaxClientsHook refuses new connections once maxClients clients are connected.
+// A client reconnecting with the ID of a connected client takes over its slot,
+// so it is let through. Inline clients (scripts, bridges) don't take a slot
+//
+// mochi's own Capabilities.MaximumClients is left unlimited on purpose: it
+// refuses every connect to a full broker, takeovers included, so a device
+// whose old connection went stale would be locked out until that connection
+// timed out
+type MaxClientsHook struct {
+	mqtt.HookBase
+	server     *mqtt.Server
+	maxClients int64
+	metrics    *PrometheusMetrics
+}
+
+// NewMaxClientsHook creates a hook capping connections at cfg.MaxClients
+func NewMaxClientsHook(server *mqtt.Server, cfg *Config) *MaxClientsHook {
+	return &MaxClientsHook{
+		server:     server,
+		maxClients: int64(cfg.MaxClients),
+	}
+}
+
+// SetMetrics counts refused connections in mqtt_connections_rejected_total
+func (h *MaxClientsHook) SetMetrics(metrics *PrometheusMetrics) {
+	h.metrics = metrics
+}
+
+// ID returns the hook identifier
+func (h *MaxClientsHook) ID() string {
+	return "max-clients"
+}
+
+// Provides indicates which hook methods this hook provides
+func (h *MaxClientsHook) Provides(b byte) bool {
+	return bytes.Contains([]byte{
+		mqtt.OnConnect,
+	}, []byte{b})
+}
+
+// OnConnect refuses the client with Server Busy when the broker is full
+// Concurrent connects can briefly overshoot the cap, as each is only counted
+// once authenticated
+func (h *MaxClientsHook) OnConnect(cl *mqtt.Client, pk packets.Packet) error {
+	connected := atomic.LoadInt64(&h.server.Info.ClientsConnected)
+	if connected < h.maxClients {
+		return nil
+	}
+	if existing, ok := h.server.Clients.Get(cl.ID); ok && !existing.Net.Inline && !existing.Closed() {
+		return nil // Session takeover, the existing connection is closed
+	}
+
+	slog.Warn("Rejected client over the connection limit",
+		"client_id", cl.ID,
+		"remote", cl.Net.Remote,
+		"connected", connected,
+		"max", h.maxClients)
+	if h.metrics != nil {
+		h.metrics.RecordConnectionRejected(RejectReasonMaxClients)
+	}
+
+	code := packets.ErrServerBusy
+	if cl.Properties.ProtocolVersion < 5 {
+		code = packets.ErrServerUnavailable // v3 has no Server Busy return code
+	}
+	_ = h.server.SendConnack(cl, code, false, nil)
+	return code
+}
@@ -0,0 +1,139 @@
+package mqtt
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// connectV5 dials addr, sends a v5 CONNECT for clientID and returns the
+// connection with the CONNACK reason code. The connection is closed when the
+// test ends
+func connectV5(t *testing.T, addr, clientID string) (net.Conn, byte) {
+	t.Helper()
+	return connectV5As(t, addr, clientID, "")
+}
+
+// connectV5As is connectV5 with credentials: user "device" and password
+// (no credentials if password is empty)
+func connectV5As(t *testing.T, addr, clientID, password string) (net.Conn, byte) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	pk := packets.Packet{
+		FixedHeader:     packets.FixedHeader{Type: packets.Connect},
+		ProtocolVersion: 5,
+		Connect: packets.ConnectParams{
+			ProtocolName:     []byte("MQTT"),
+			Clean:            true,
+			Keepalive:        30,
+			ClientIdentifier: clientID,
+		},
+	}
+	if password != "" {
+		pk.Connect.UsernameFlag = true
+		pk.Connect.Username = []byte("device")
+		pk.Connect.PasswordFlag = true
+		pk.Connect.Password = []byte(password)
+	}
+	buf := new(bytes.Buffer)
+	if err := pk.ConnectEncode(buf); err != nil {
+		t.Fatalf("ConnectEncode() error = %v", err)
+	}
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	// Read the whole CONNACK (its remaining length fits one byte) so only later
+	// packets are left on the connection. The reason code follows the flags
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		t.Fatalf("reading CONNACK: %v", err)
+	}
+	connack := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, connack); err != nil || len(connack) < 2 {
+		t.Fatalf("reading CONNACK: %v", err)
+	}
+	return conn, connack[1]
+}
+
+func TestMaxClientsHook_RefusesConnectionsOverCap(t *testing.T) {
+	server := New(&Config{TCPAddr: "127.0.0.1:0", MaxClients: 2})
+	metrics := NewPrometheusMetricsWithRegistry(prometheus.NewRegistry())
+	hook := NewMaxClientsHook(server.Server, server.config)
+	hook.SetMetrics(metrics)
+	if err := server.AddHook(hook, nil); err != nil {
+		t.Fatalf("AddHook() error = %v", err)
+	}
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatalf("AddHook() error = %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+
+	connect := func(t *testing.T, clientID string) byte {
+		_, code := connectV5(t, tcpAddress(server), clientID)
+		return code
+	}
+
+	for _, id := range []string{"first", "second"} {
+		if code := connect(t, id); code != packets.CodeSuccess.Code {
+			t.Fatalf("CONNACK for %s = %#x, want success", id, code)
+		}
+	}
+
+	if code := connect(t, "third"); code != packets.ErrServerBusy.Code {
+		t.Errorf("CONNACK over the cap = %#x, want %#x (server busy)", code, packets.ErrServerBusy.Code)
+	}
+	if got := testutil.ToFloat64(metrics.connectionsRejected.WithLabelValues(RejectReasonMaxClients)); got != 1 {
+		t.Errorf("mqtt_connections_rejected_total{reason=max_clients} = %v, want 1", got)
+	}
+
+	if got := server.GetMetrics().MaxClients; got != 2 {
+		t.Errorf("GetMetrics().MaxClients = %d, want 2", got)
+	}
+}
+
+func TestMaxClientsHook_AllowsSessionTakeoverWhenFull(t *testing.T) {
+	server := New(&Config{TCPAddr: "127.0.0.1:0", MaxClients: 1})
+	if err := server.AddHook(NewMaxClientsHook(server.Server, server.config), nil); err != nil {
+		t.Fatalf("AddHook() error = %v", err)
+	}
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatalf("AddHook() error = %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+
+	// mochi's cap would refuse the takeover below, so it must stay unlimited
+	if got := server.Options.Capabilities.MaximumClients; got != math.MaxInt64 {
+		t.Fatalf("Capabilities.MaximumClients = %d, want unlimited", got)
+	}
+
+	if _, code := connectV5(t, tcpAddress(server), "device-1"); code != packets.CodeSuccess.Code {
+		t.Fatalf("CONNACK = %#x, want success", code)
+	}
+	if _, code := connectV5(t, tcpAddress(server), "device-1"); code != packets.CodeSuccess.Code {
+		t.Errorf("CONNACK for session takeover of a full broker = %#x, want success", code)
+	}
+	if _, code := connectV5(t, tcpAddress(server), "device-2"); code != packets.ErrServerBusy.Code {
+		t.Errorf("CONNACK for a new client of a full broker = %#x, want %#x (server busy)", code, packets.ErrServerBusy.Code)
+	}
+}
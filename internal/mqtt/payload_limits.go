@@ -0,0 +1,150 @@
+package mqtt
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+// RejectReasonPayloadSize is the rejection reason for payloads over their topic's limit
+const RejectReasonPayloadSize = "payload_size"
+
+// TopicSizeLimit caps the payload size of messages published to topics matching Filter
+type TopicSizeLimit struct {
+	Filter   string
+	MaxBytes int
+}
+
+// ParseTopicSizeLimits parses a comma-separated list of filter:size pairs,
+// e.g. "firmware/#:5MB,#:256KB". Sizes are bytes with an optional KB, MB or GB
+// suffix (powers of 1024). The size follows the last colon, so filters may contain colons
+func ParseTopicSizeLimits(spec string) ([]TopicSizeLimit, error) {
+	var limits []TopicSizeLimit
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		idx := strings.LastIndex(entry, ":")
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid size limit '%s' (expected filter:size)", entry)
+		}
+		size, err := parseByteSize(entry[idx+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid size limit '%s': %w", entry, err)
+		}
+		limits = append(limits, TopicSizeLimit{Filter: entry[:idx], MaxBytes: size})
+	}
+	return limits, nil
+}
+
+// parseByteSize parses a size such as "512", "256KB" or "5MB"
+func parseByteSize(s string) (int, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multiplier := 1
+	for _, unit := range []struct {
+		suffix string
+		factor int
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.factor
+			break
+		}
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("size must be a non-negative number of bytes, KB, MB or GB")
+	}
+	return n * multiplier, nil
+}
+
+// PayloadLimitsHook rejects publishes whose payload exceeds the limit of the
+// first matching topic filter. Inline publishes (scripts, bridges) are not limited
+type PayloadLimitsHook struct {
+	mqtt.HookBase
+	limits     []TopicSizeLimit
+	metrics    *PrometheusMetrics
+	deadLetter *DeadLetter // nil = rejections are not republished
+}
+
+// NewPayloadLimitsHook creates a hook enforcing limits in order (first match wins)
+func NewPayloadLimitsHook(limits []TopicSizeLimit) *PayloadLimitsHook {
+	return &PayloadLimitsHook{limits: limits}
+}
+
+// SetMetrics counts rejections in mqtt_messages_rejected_total
+func (h *PayloadLimitsHook) SetMetrics(metrics *PrometheusMetrics) {
+	h.metrics = metrics
+}
+
+// SetDeadLetter republishes a summary of each rejected publish
+func (h *PayloadLimitsHook) SetDeadLetter(deadLetter *DeadLetter) {
+	h.deadLetter = deadLetter
+}
+
+// ID returns the hook identifier
+func (h *PayloadLimitsHook) ID() string {
+	return "payload-limits"
+}
+
+// Provides indicates which hook methods this hook provides
+func (h *PayloadLimitsHook) Provides(b byte) bool {
+	return bytes.Contains([]byte{
+		mqtt.OnPublish,
+	}, []byte{b})
+}
+
+// Limit returns the payload limit for topic, or -1 if no filter matches
+func (h *PayloadLimitsHook) Limit(topic string) int {
+	for _, limit := range h.limits {
+		if storage.MatchTopic(limit.Filter, topic) {
+			return limit.MaxBytes
+		}
+	}
+	return -1
+}
+
+// OnPublish rejects the message if its payload is over the topic's limit
+func (h *PayloadLimitsHook) OnPublish(cl *mqtt.Client, pk packets.Packet) (packets.Packet, error) {
+	if cl.Net.Inline {
+		return pk, nil
+	}
+
+	limit := h.Limit(pk.TopicName)
+	if limit < 0 || len(pk.Payload) <= limit {
+		return pk, nil
+	}
+
+	slog.Debug("Rejected oversized publish",
+		"client_id", cl.ID,
+		"topic", pk.TopicName,
+		"size", len(pk.Payload),
+		"limit", limit)
+	if h.metrics != nil {
+		h.metrics.RecordMessageRejected(RejectReasonPayloadSize)
+	}
+	if h.deadLetter != nil {
+		h.deadLetter.RejectPublish(cl, pk.TopicName, RejectReasonPayloadSize)
+	}
+
+	return pk, rejectPublish(cl, pk, packets.ErrPacketTooLarge)
+}
+
+// rejectPublish returns the OnPublish error that drops pk
+// v5 QoS 1/2 publishers get code in their PUBACK; mochi would deliver the
+// message for any other client, so those are dropped silently
+func rejectPublish(cl *mqtt.Client, pk packets.Packet, code packets.Code) error {
+	if cl.Properties.ProtocolVersion == 5 && pk.FixedHeader.Qos > 0 {
+		return code
+	}
+	return packets.ErrRejectPacket
+}
@@ -0,0 +1,92 @@
+package mqtt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseTopicSizeLimits(t *testing.T) {
+	limits, err := ParseTopicSizeLimits("firmware/#:5MB, logs/+:512, #:256kb")
+	if err != nil {
+		t.Fatalf("ParseTopicSizeLimits() error = %v", err)
+	}
+
+	want := []TopicSizeLimit{
+		{Filter: "firmware/#", MaxBytes: 5 << 20},
+		{Filter: "logs/+", MaxBytes: 512},
+		{Filter: "#", MaxBytes: 256 << 10},
+	}
+	if len(limits) != len(want) {
+		t.Fatalf("limits = %+v, want %+v", limits, want)
+	}
+	for i := range want {
+		if limits[i] != want[i] {
+			t.Errorf("limits[%d] = %+v, want %+v", i, limits[i], want[i])
+		}
+	}
+
+	for _, spec := range []string{"firmware/#", "#:lots", ":5MB", "#:-1"} {
+		if _, err := ParseTopicSizeLimits(spec); err == nil {
+			t.Errorf("ParseTopicSizeLimits(%q) error = nil, want error", spec)
+		}
+	}
+}
+
+func TestPayloadLimitsHook_OnPublish(t *testing.T) {
+	limits, _ := ParseTopicSizeLimits("firmware/#:1KB,#:16")
+	hook := NewPayloadLimitsHook(limits)
+	metrics := NewPrometheusMetricsWithRegistry(prometheus.NewRegistry())
+	hook.SetMetrics(metrics)
+
+	server := New(nil)
+	tests := []struct {
+		name    string
+		version byte
+		qos     byte
+		topic   string
+		size    int
+		wantErr error
+	}{
+		{name: "small payload passes", version: 4, topic: "sensors/temp", size: 16},
+		{name: "large firmware passes", version: 4, topic: "firmware/v2", size: 1024},
+		{name: "oversized payload dropped", version: 4, topic: "sensors/temp", size: 17, wantErr: packets.ErrRejectPacket},
+		{name: "oversized firmware dropped", version: 5, topic: "firmware/v2", size: 1025, wantErr: packets.ErrRejectPacket},
+		{name: "v5 qos1 publisher gets reason code", version: 5, qos: 1, topic: "sensors/temp", size: 17, wantErr: packets.ErrPacketTooLarge},
+	}
+
+	rejected := 0
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := server.NewClient(nil, ListenerTCP, "limits-client", false)
+			cl.Properties.ProtocolVersion = tt.version
+			pk := packets.Packet{
+				FixedHeader: packets.FixedHeader{Type: packets.Publish, Qos: tt.qos},
+				TopicName:   tt.topic,
+				Payload:     bytes.Repeat([]byte("x"), tt.size),
+			}
+
+			_, err := hook.OnPublish(cl, pk)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("OnPublish() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				rejected++
+			}
+		})
+	}
+
+	if got := testutil.ToFloat64(metrics.messagesRejected.WithLabelValues(RejectReasonPayloadSize)); got != float64(rejected) {
+		t.Errorf("mqtt_messages_rejected_total = %v, want %d", got, rejected)
+	}
+
+	// Inline publishes (scripts, bridges) are never limited
+	inline := server.NewClient(nil, "local", "inline", true)
+	if _, err := hook.OnPublish(inline, packets.Packet{TopicName: "sensors/temp", Payload: make([]byte, 4096)}); err != nil {
+		t.Errorf("OnPublish() for inline client error = %v, want nil", err)
+	}
+}
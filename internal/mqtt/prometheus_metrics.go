@@ -18,96 +18,154 @@ type PrometheusMetrics struct {
 	// ACL metrics
 	aclChecks    *prometheus.CounterVec
 	aclDenied    *prometheus.CounterVec
+	aclDenials   *prometheus.CounterVec
 	authAttempts *prometheus.CounterVec
 	authFailures *prometheus.CounterVec
+	authReasons  *prometheus.CounterVec
+	// Publishes dropped by broker-side checks (e.g. payload size limits)
+	messagesRejected *prometheus.CounterVec
+	// Subscriptions refused by broker-side checks (e.g. topic limits)
+	subscriptionsRejected *prometheus.CounterVec
+	// Connections refused by broker-side checks (e.g. the client cap)
+	connectionsRejected *prometheus.CounterVec
+	// Payload bytes of the stored retained messages
+	retainedBytes prometheus.Gauge
 }
 
 // NewPrometheusMetrics creates a new Prometheus metrics collector
 func NewPrometheusMetrics() *PrometheusMetrics {
+	return NewPrometheusMetricsWithRegistry(prometheus.DefaultRegisterer)
+}
+
+// NewPrometheusMetricsWithRegistry creates a metrics collector registered with reg
+// Tests use a fresh registry so collectors don't clash across test cases
+func NewPrometheusMetricsWithRegistry(reg prometheus.Registerer) *PrometheusMetrics {
+	factory := promauto.With(reg)
 	return &PrometheusMetrics{
-		messagesReceived: promauto.NewCounterVec(
+		messagesReceived: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "mqtt_messages_received_total",
 				Help: "Total number of PUBLISH messages received from clients",
 			},
 			[]string{"client_id"},
 		),
-		messagesSent: promauto.NewCounterVec(
+		messagesSent: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "mqtt_messages_sent_total",
 				Help: "Total number of PUBLISH messages sent to clients",
 			},
 			[]string{"client_id"},
 		),
-		bytesReceived: promauto.NewCounterVec(
+		bytesReceived: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "mqtt_bytes_received_total",
 				Help: "Total bytes received from clients",
 			},
 			[]string{"client_id"},
 		),
-		bytesSent: promauto.NewCounterVec(
+		bytesSent: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "mqtt_bytes_sent_total",
 				Help: "Total bytes sent to clients",
 			},
 			[]string{"client_id"},
 		),
-		packetsReceived: promauto.NewCounterVec(
+		packetsReceived: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "mqtt_packets_received_total",
 				Help: "Total MQTT packets received from clients",
 			},
 			[]string{"client_id"},
 		),
-		packetsSent: promauto.NewCounterVec(
+		packetsSent: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "mqtt_packets_sent_total",
 				Help: "Total MQTT packets sent to clients",
 			},
 			[]string{"client_id"},
 		),
-		clientsConnected: promauto.NewGauge(
+		clientsConnected: factory.NewGauge(
 			prometheus.GaugeOpts{
 				Name: "mqtt_clients_connected",
 				Help: "Number of currently connected MQTT clients",
 			},
 		),
-		clientConnectedTime: promauto.NewGaugeVec(
+		clientConnectedTime: factory.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "mqtt_client_connected_timestamp_seconds",
 				Help: "Unix timestamp when client connected",
 			},
 			[]string{"client_id"},
 		),
-		aclChecks: promauto.NewCounterVec(
+		aclChecks: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "mqtt_acl_checks_total",
 				Help: "Total number of ACL authorization checks",
 			},
 			[]string{"username", "action", "result"},
 		),
-		aclDenied: promauto.NewCounterVec(
+		aclDenied: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "mqtt_acl_denied_total",
-				Help: "Total number of ACL denials (security monitoring)",
+				Help: "Total number of ACL denials by username (security monitoring; topics are left out to bound cardinality)",
 			},
-			[]string{"username", "action", "topic"},
+			[]string{"username", "action"},
 		),
-		authAttempts: promauto.NewCounterVec(
+		aclDenials: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mqtt_acl_denials_total",
+				Help: "Total number of ACL denials by action (pub, sub)",
+			},
+			[]string{"action"},
+		),
+		authAttempts: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "mqtt_auth_attempts_total",
 				Help: "Total number of authentication attempts",
 			},
 			[]string{"username", "result"},
 		),
-		authFailures: promauto.NewCounterVec(
+		authFailures: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "mqtt_auth_failures_total",
 				Help: "Total number of authentication failures (security monitoring)",
 			},
 			[]string{"username"},
 		),
+		authReasons: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mqtt_auth_failure_reasons_total",
+				Help: "Total number of authentication failures by reason (bad_password, unknown_user, anonymous_disabled, ip_denied, rate_limited, error)",
+			},
+			[]string{"reason"},
+		),
+		messagesRejected: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mqtt_messages_rejected_total",
+				Help: "Total number of PUBLISH messages rejected by the broker by reason (payload_size, topic_length, topic_depth)",
+			},
+			[]string{"reason"},
+		),
+		subscriptionsRejected: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mqtt_subscriptions_rejected_total",
+				Help: "Total number of subscription filters rejected by the broker by reason (topic_length, topic_depth)",
+			},
+			[]string{"reason"},
+		),
+		connectionsRejected: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mqtt_connections_rejected_total",
+				Help: "Total number of client connections refused by the broker by reason (max_clients, reconnect_rate, will_denied)",
+			},
+			[]string{"reason"},
+		),
+		retainedBytes: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "mqtt_retained_bytes",
+				Help: "Total payload bytes of the stored retained messages",
+			},
+		),
 	}
 }
 
@@ -151,8 +209,9 @@ func (pm *PrometheusMetrics) RecordACLCheck(username, action, result string) {
 }
 
 // RecordACLDenied records an ACL denial (for security monitoring)
-func (pm *PrometheusMetrics) RecordACLDenied(username, action, topic string) {
-	pm.aclDenied.WithLabelValues(username, action, topic).Inc()
+func (pm *PrometheusMetrics) RecordACLDenied(username, action string) {
+	pm.aclDenied.WithLabelValues(username, action).Inc()
+	pm.aclDenials.WithLabelValues(action).Inc()
 }
 
 // RecordAuthAttempt records an authentication attempt
@@ -161,6 +220,28 @@ func (pm *PrometheusMetrics) RecordAuthAttempt(username, result string) {
 }
 
 // RecordAuthFailure records an authentication failure (for security monitoring)
-func (pm *PrometheusMetrics) RecordAuthFailure(username string) {
+// Reasons are counted separately so alerts don't depend on per-user series
+func (pm *PrometheusMetrics) RecordAuthFailure(username, reason string) {
 	pm.authFailures.WithLabelValues(username).Inc()
+	pm.authReasons.WithLabelValues(reason).Inc()
+}
+
+// RecordMessageRejected records a publish dropped by a broker-side check
+func (pm *PrometheusMetrics) RecordMessageRejected(reason string) {
+	pm.messagesRejected.WithLabelValues(reason).Inc()
+}
+
+// RecordSubscriptionRejected records a subscription filter refused by a broker-side check
+func (pm *PrometheusMetrics) RecordSubscriptionRejected(reason string) {
+	pm.subscriptionsRejected.WithLabelValues(reason).Inc()
+}
+
+// RecordConnectionRejected records a connection refused by a broker-side check
+func (pm *PrometheusMetrics) RecordConnectionRejected(reason string) {
+	pm.connectionsRejected.WithLabelValues(reason).Inc()
+}
+
+// SetRetainedBytes sets the total payload size of the stored retained messages
+func (pm *PrometheusMetrics) SetRetainedBytes(bytes int64) {
+	pm.retainedBytes.Set(float64(bytes))
 }
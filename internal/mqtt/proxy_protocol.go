@@ -0,0 +1,243 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyHeaderTimeout bounds how long a trusted proxy may take to send its header
+const proxyHeaderTimeout = 5 * time.Second
+
+// proxyV2Signature starts every PROXY protocol v2 header
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyListener wraps a TCP listener so connections from trusted proxies report
+// the client address carried in their PROXY protocol header. Connections from
+// other peers are passed through untouched, so they can't spoof their address
+type proxyListener struct {
+	net.Listener
+	trusted []netip.Prefix
+}
+
+// newProxyListener listens on addr, accepting PROXY headers from peers in the
+// comma-separated trustedCIDRs (CIDRs or single addresses)
+func newProxyListener(addr, trustedCIDRs string) (*proxyListener, error) {
+	trusted, err := parseTrustedProxies(trustedCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	if len(trusted) == 0 {
+		return nil, fmt.Errorf("PROXY protocol requires at least one trusted proxy network")
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &proxyListener{Listener: l, trusted: trusted}, nil
+}
+
+// parseTrustedProxies parses a comma-separated list of CIDRs or single addresses
+func parseTrustedProxies(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy '%s' (expected CIDR or IP address)", entry)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// Accept waits for the next connection, wrapping it when the peer is a trusted proxy
+// The header itself is read lazily, in the connection's own goroutine
+func (l *proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.isTrusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	return &proxyConn{Conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// isTrusted reports whether addr is in one of the trusted proxy networks
+func (l *proxyListener) isTrusted(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	ip, ok := netip.AddrFromSlice(tcpAddr.IP)
+	if !ok {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, prefix := range l.trusted {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyConn is a connection from a trusted proxy. A header is optional, so
+// health checks that connect without one still work
+type proxyConn struct {
+	net.Conn
+	reader *bufio.Reader
+
+	once   sync.Once
+	remote net.Addr // Client address from the header (nil = use the proxy's)
+	err    error
+}
+
+// readHeader consumes the PROXY header, if any, before the first read
+func (c *proxyConn) readHeader() {
+	c.once.Do(func() {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+		c.remote, c.err = readProxyHeader(c.reader)
+		_ = c.Conn.SetReadDeadline(time.Time{})
+	})
+}
+
+// Read reads MQTT data following the header
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the client address from the header, or the proxy's address
+// when the header carried none
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyHeader reads a PROXY protocol v1 or v2 header from r and returns the
+// source address it carries. It returns a nil address, without consuming
+// anything, when the stream doesn't start with a header (an MQTT CONNECT starts
+// with 0x10), and for headers that carry no address (v1 UNKNOWN, v2 LOCAL)
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch first[0] {
+	case 'P':
+		return readProxyHeaderV1(r)
+	case proxyV2Signature[0]:
+		return readProxyHeaderV2(r)
+	}
+	return nil, nil
+}
+
+// readProxyHeaderV1 parses "PROXY TCP4|TCP6|UNKNOWN src dst sport dport\r\n"
+func readProxyHeaderV1(r *bufio.Reader) (net.Addr, error) {
+	const maxLength = 107 // Longest valid v1 line, including CRLF
+
+	var line []byte
+	for len(line) < maxLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("proxy protocol: reading v1 header: %w", err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errors.New("proxy protocol: v1 header is not terminated by CRLF")
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return nil, errors.New("proxy protocol: malformed v1 header")
+	}
+	if fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if (fields[1] != "TCP4" && fields[1] != "TCP6") || len(fields) != 6 {
+		return nil, errors.New("proxy protocol: malformed v1 header")
+	}
+
+	ip, err := netip.ParseAddr(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("proxy protocol: invalid source address '%s'", fields[2])
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("proxy protocol: invalid source port '%s'", fields[4])
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, uint16(port))), nil
+}
+
+// readProxyHeaderV2 parses the binary v2 header: signature, version/command,
+// address family, length, then the addresses (and TLVs, which are skipped)
+func readProxyHeaderV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("proxy protocol: reading v2 header: %w", err)
+	}
+	if !bytes.Equal(header[:12], proxyV2Signature) {
+		return nil, errors.New("proxy protocol: invalid v2 signature")
+	}
+	if header[12]>>4 != 2 {
+		return nil, fmt.Errorf("proxy protocol: unsupported version %d", header[12]>>4)
+	}
+
+	body := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("proxy protocol: reading v2 addresses: %w", err)
+	}
+
+	// LOCAL connections (e.g. proxy health checks) carry no client address
+	if header[12]&0x0f == 0 {
+		return nil, nil
+	}
+
+	var ipLen int
+	switch header[13] >> 4 {
+	case 1: // AF_INET
+		ipLen = 4
+	case 2: // AF_INET6
+		ipLen = 16
+	default: // AF_UNSPEC or AF_UNIX
+		return nil, nil
+	}
+	if len(body) < 2*ipLen+4 {
+		return nil, errors.New("proxy protocol: v2 address block too short")
+	}
+
+	ip, _ := netip.AddrFromSlice(body[:ipLen])
+	port := binary.BigEndian.Uint16(body[2*ipLen:])
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip.Unmap(), port)), nil
+}
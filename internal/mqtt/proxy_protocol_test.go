@@ -0,0 +1,237 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github/bromq-dev/bromq/hooks/auth"
+)
+
+// proxyV2Header builds a v2 PROXY header for a TCP connection over IPv4
+func proxyV2Header(command byte, src, dst net.IP, srcPort, dstPort uint16) []byte {
+	body := make([]byte, 12)
+	copy(body[0:4], src.To4())
+	copy(body[4:8], dst.To4())
+	binary.BigEndian.PutUint16(body[8:10], srcPort)
+	binary.BigEndian.PutUint16(body[10:12], dstPort)
+
+	header := append([]byte{}, proxyV2Signature...)
+	header = append(header, 0x20|command, 0x11)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(body)))
+	return append(header, body...)
+}
+
+// tcpAddress returns the address the server's TCP listener is bound to
+func tcpAddress(server *Server) string {
+	listener, _ := server.Listeners.Get(ListenerTCP)
+	return listener.Address()
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      []byte
+		wantRemote string // "" = no address in the header
+		wantErr    bool
+	}{
+		{
+			name:       "v1 TCP4",
+			input:      []byte("PROXY TCP4 203.0.113.7 10.0.0.1 40000 1883\r\n"),
+			wantRemote: "203.0.113.7:40000",
+		},
+		{
+			name:       "v1 TCP6",
+			input:      []byte("PROXY TCP6 2001:db8::7 2001:db8::1 40000 1883\r\n"),
+			wantRemote: "[2001:db8::7]:40000",
+		},
+		{
+			name:  "v1 UNKNOWN",
+			input: []byte("PROXY UNKNOWN\r\n"),
+		},
+		{
+			name:       "v2 PROXY",
+			input:      proxyV2Header(1, net.ParseIP("198.51.100.9"), net.ParseIP("10.0.0.1"), 51000, 1883),
+			wantRemote: "198.51.100.9:51000",
+		},
+		{
+			name:  "v2 LOCAL",
+			input: proxyV2Header(0, net.ParseIP("198.51.100.9"), net.ParseIP("10.0.0.1"), 51000, 1883),
+		},
+		{
+			name:  "no header",
+			input: nil,
+		},
+		{
+			name:    "v1 missing ports",
+			input:   []byte("PROXY TCP4 203.0.113.7 10.0.0.1\r\n"),
+			wantErr: true,
+		},
+		{
+			name:    "v1 without CRLF",
+			input:   []byte("PROXY TCP4 203.0.113.7 10.0.0.1 40000 1883 " + strings.Repeat("x", 100)),
+			wantErr: true,
+		},
+		{
+			name:    "v2 bad signature",
+			input:   append([]byte("\r\n\r\n\x00\r\nQUIX\n"), 0x21, 0x11, 0, 0),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The MQTT stream that follows must be left intact
+			connect := []byte{0x10, 0x0c}
+			r := bufio.NewReader(bytes.NewReader(append(append([]byte{}, tt.input...), connect...)))
+
+			remote, err := readProxyHeader(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readProxyHeader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got := ""
+			if remote != nil {
+				got = remote.String()
+			}
+			if got != tt.wantRemote {
+				t.Errorf("readProxyHeader() remote = %q, want %q", got, tt.wantRemote)
+			}
+
+			rest, _ := io.ReadAll(r)
+			if !bytes.Equal(rest, connect) {
+				t.Errorf("remaining stream = %x, want %x", rest, connect)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := parseTrustedProxies(" 10.0.0.0/8, 192.168.1.5 ,,")
+	if err != nil {
+		t.Fatalf("parseTrustedProxies() error = %v", err)
+	}
+	if len(prefixes) != 2 || prefixes[1].String() != "192.168.1.5/32" {
+		t.Errorf("parseTrustedProxies() = %v, want [10.0.0.0/8 192.168.1.5/32]", prefixes)
+	}
+
+	if _, err := parseTrustedProxies("not-a-network"); err == nil {
+		t.Error("parseTrustedProxies() accepted an invalid entry")
+	}
+}
+
+func TestProxyProtocol_ClientAddress(t *testing.T) {
+	start := func(t *testing.T, trusted string, ipFilter *auth.IPFilter) *Server {
+		t.Helper()
+		server := New(&Config{TCPAddr: "127.0.0.1:0", ProxyProtocol: true, ProxyTrustedCIDRs: trusted})
+		hook := auth.NewAuthHook(nil, true)
+		hook.SetIPFilter(ipFilter)
+		if err := server.AddHook(hook, nil); err != nil {
+			t.Fatalf("AddHook() error = %v", err)
+		}
+		if err := server.Start(); err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+		t.Cleanup(func() { _ = server.Close() })
+		return server
+	}
+
+	// connect sends header followed by a CONNECT and returns the CONNACK reason
+	// code, or an error when the broker hangs up instead. The connection stays
+	// open until the test ends
+	connect := func(t *testing.T, addr string, header []byte) (byte, error) {
+		t.Helper()
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("Dial() error = %v", err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+		pk := packets.Packet{
+			FixedHeader:     packets.FixedHeader{Type: packets.Connect},
+			ProtocolVersion: 4,
+			Connect: packets.ConnectParams{
+				ProtocolName:     []byte("MQTT"),
+				Clean:            true,
+				Keepalive:        30,
+				ClientIdentifier: "proxied",
+			},
+		}
+		buf := bytes.NewBuffer(append([]byte{}, header...))
+		if err := pk.ConnectEncode(buf); err != nil {
+			t.Fatalf("ConnectEncode() error = %v", err)
+		}
+		if _, err := conn.Write(buf.Bytes()); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+
+		connack := make([]byte, 4)
+		if _, err := io.ReadFull(conn, connack); err != nil {
+			return 0, err
+		}
+		return connack[3], nil
+	}
+
+	header := []byte("PROXY TCP4 203.0.113.7 10.0.0.1 40000 1883\r\n")
+
+	t.Run("header from trusted proxy sets the client address", func(t *testing.T) {
+		server := start(t, "127.0.0.1", nil)
+
+		if code, err := connect(t, tcpAddress(server), header); err != nil || code != 0 {
+			t.Fatalf("CONNACK = %d, %v; want success", code, err)
+		}
+
+		details, err := server.GetClientDetails("proxied")
+		if err != nil {
+			t.Fatalf("GetClientDetails() error = %v", err)
+		}
+		if details.Remote != "203.0.113.7:40000" {
+			t.Errorf("client remote = %q, want 203.0.113.7:40000", details.Remote)
+		}
+	})
+
+	t.Run("IP filter applies to the client address", func(t *testing.T) {
+		deny, _ := auth.ParseIPFilter(nil, []string{"203.0.113.0/24"})
+		server := start(t, "127.0.0.1", deny)
+
+		code, err := connect(t, tcpAddress(server), header)
+		if err == nil && code == 0 {
+			t.Error("client from a denied network connected through the proxy, want rejection")
+		}
+	})
+
+	t.Run("connection without header from trusted proxy", func(t *testing.T) {
+		server := start(t, "127.0.0.1", nil)
+
+		if code, err := connect(t, tcpAddress(server), nil); err != nil || code != 0 {
+			t.Errorf("CONNACK = %d, %v; want success", code, err)
+		}
+	})
+
+	t.Run("header from untrusted peer is not parsed", func(t *testing.T) {
+		server := start(t, "10.0.0.0/8", nil)
+
+		if code, err := connect(t, tcpAddress(server), header); err == nil && code == 0 {
+			t.Error("untrusted peer's PROXY header was accepted, want the connection dropped")
+		}
+	})
+
+	t.Run("trusted proxies are required", func(t *testing.T) {
+		server := New(&Config{TCPAddr: "127.0.0.1:0", ProxyProtocol: true})
+		if err := server.Start(); err == nil {
+			_ = server.Close()
+			t.Error("Start() succeeded without trusted proxies, want error")
+		}
+	})
+}
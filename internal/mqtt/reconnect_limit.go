@@ -0,0 +1,135 @@
+package mqtt
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// RejectReasonReconnectRate is the rejection reason for clients over MQTT_RECONNECT_LIMIT
+const RejectReasonReconnectRate = "reconnect_rate"
+
+// AuthFailureRateLimited is the auth failure reason (auth.ReasonRateLimited)
+// recorded for throttled clients, so they also show up next to other refused logins
+const AuthFailureRateLimited = "rate_limited"
+
+// ReconnectLimitHook refuses clients that connect with the same client ID more
+// than limit times within a window, so a device stuck in a reconnect loop doesn't
+// flood client tracking, events and logs. Each client ID's window is fixed from
+// the first connect counted in it; once it ends the client is let in again.
+// It is a connect check (see Server.AddConnectCheck), and only admitted connects
+// are counted, so a peer without the device's credentials can't lock it out
+type ReconnectLimitHook struct {
+	server  *mqtt.Server
+	limit   int
+	window  time.Duration
+	metrics *PrometheusMetrics
+	now     func() time.Time
+
+	mu        sync.Mutex
+	attempts  map[string]*reconnectWindow // client ID -> connects in its current window
+	lastSweep time.Time
+}
+
+// reconnectWindow counts the admitted and refused connects of one client ID since start
+type reconnectWindow struct {
+	start   time.Time
+	count   int
+	refused int
+}
+
+// NewReconnectLimitHook creates a hook allowing cfg.ReconnectLimit connects per
+// client ID every cfg.ReconnectWindow
+func NewReconnectLimitHook(server *mqtt.Server, cfg *Config) (*ReconnectLimitHook, error) {
+	if cfg.ReconnectLimit <= 0 {
+		return nil, fmt.Errorf("reconnect limit must be positive, got %d", cfg.ReconnectLimit)
+	}
+	if cfg.ReconnectWindow <= 0 {
+		return nil, fmt.Errorf("reconnect window must be positive, got %s", cfg.ReconnectWindow)
+	}
+	return &ReconnectLimitHook{
+		server:   server,
+		limit:    cfg.ReconnectLimit,
+		window:   cfg.ReconnectWindow,
+		now:      time.Now,
+		attempts: make(map[string]*reconnectWindow),
+	}, nil
+}
+
+// SetMetrics counts throttled reconnects in mqtt_connections_rejected_total and
+// mqtt_auth_failure_reasons_total
+func (h *ReconnectLimitHook) SetMetrics(metrics *PrometheusMetrics) {
+	h.metrics = metrics
+}
+
+// OnAuthenticated refuses the client with Connection Rate Exceeded when its
+// client ID has already connected limit times in the current window
+func (h *ReconnectLimitHook) OnAuthenticated(cl *mqtt.Client, pk packets.Packet) error {
+	if cl.Net.Inline {
+		return nil
+	}
+
+	allowed, firstRefusal := h.record(cl.ID)
+	if allowed {
+		return nil
+	}
+
+	// Only the first refusal of a window is logged; a looping client would
+	// otherwise produce the very log spam this hook exists to stop
+	if firstRefusal {
+		slog.Warn("Throttling client reconnecting too fast",
+			"client_id", cl.ID,
+			"remote", cl.Net.Remote,
+			"limit", h.limit,
+			"window", h.window)
+	}
+	if h.metrics != nil {
+		h.metrics.RecordConnectionRejected(RejectReasonReconnectRate)
+		username := string(cl.Properties.Username)
+		if username == "" {
+			username = "anonymous"
+		}
+		h.metrics.RecordAuthFailure(username, AuthFailureRateLimited)
+	}
+
+	code := packets.ErrConnectionRateExceeded
+	if cl.Properties.ProtocolVersion < 5 {
+		code = packets.ErrServerUnavailable // v3 has no Connection Rate Exceeded return code
+	}
+	_ = h.server.SendConnack(cl, code, false, nil)
+	return code
+}
+
+// record counts a connect by clientID if it is within the limit of the current
+// window, and reports whether it was admitted and, if not, whether it is the
+// first connect refused in the window
+func (h *ReconnectLimitHook) record(clientID string) (allowed, firstRefusal bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	if now.Sub(h.lastSweep) >= h.window {
+		for id, attempts := range h.attempts {
+			if now.Sub(attempts.start) >= h.window {
+				delete(h.attempts, id)
+			}
+		}
+		h.lastSweep = now
+	}
+
+	attempts, ok := h.attempts[clientID]
+	if !ok || now.Sub(attempts.start) >= h.window {
+		attempts = &reconnectWindow{start: now}
+		h.attempts[clientID] = attempts
+	}
+	if attempts.count < h.limit {
+		attempts.count++
+		return true, false
+	}
+	attempts.refused++
+	return false, attempts.refused == 1
+}
@@ -0,0 +1,108 @@
+package mqtt
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github/bromq-dev/bromq/hooks/auth"
+)
+
+func TestReconnectLimitHook_ThrottlesRapidReconnects(t *testing.T) {
+	server := New(&Config{TCPAddr: "127.0.0.1:0", ReconnectLimit: 3, ReconnectWindow: time.Minute})
+	metrics := NewPrometheusMetricsWithRegistry(prometheus.NewRegistry())
+	hook, err := NewReconnectLimitHook(server.Server, server.config)
+	if err != nil {
+		t.Fatalf("NewReconnectLimitHook() error = %v", err)
+	}
+	hook.SetMetrics(metrics)
+
+	var mu sync.Mutex
+	now := time.Now()
+	hook.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	advance := func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	}
+
+	server.AddConnectCheck(hook)
+	if err := server.AddAuthHook(&passwordAuthHook{password: "secret"}); err != nil {
+		t.Fatalf("AddAuthHook() error = %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+
+	reconnect := func(clientID string) byte {
+		conn, code := connectV5As(t, tcpAddress(server), clientID, "secret")
+		_ = conn.Close()
+		return code
+	}
+
+	// Connects with a wrong password don't count, so they can't lock a device out
+	for i := 0; i < 5; i++ {
+		conn, code := connectV5As(t, tcpAddress(server), "flapper", "guess")
+		_ = conn.Close()
+		if code != packets.ErrBadUsernameOrPassword.Code {
+			t.Fatalf("unauthenticated CONNACK = %#x, want bad username or password", code)
+		}
+	}
+
+	// A flapping device gets its first three connects, then is throttled
+	for i := 1; i <= 3; i++ {
+		if code := reconnect("flapper"); code != packets.CodeSuccess.Code {
+			t.Fatalf("connect %d CONNACK = %#x, want success", i, code)
+		}
+	}
+	for i := 4; i <= 5; i++ {
+		if code := reconnect("flapper"); code != packets.ErrConnectionRateExceeded.Code {
+			t.Errorf("connect %d CONNACK = %#x, want %#x (connection rate exceeded)", i, code, packets.ErrConnectionRateExceeded.Code)
+		}
+	}
+	if got := testutil.ToFloat64(metrics.connectionsRejected.WithLabelValues(RejectReasonReconnectRate)); got != 2 {
+		t.Errorf("mqtt_connections_rejected_total{reason=reconnect_rate} = %v, want 2", got)
+	}
+	if AuthFailureRateLimited != auth.ReasonRateLimited {
+		t.Errorf("AuthFailureRateLimited = %q, want auth.ReasonRateLimited %q", AuthFailureRateLimited, auth.ReasonRateLimited)
+	}
+	if got := testutil.ToFloat64(metrics.authReasons.WithLabelValues(auth.ReasonRateLimited)); got != 2 {
+		t.Errorf("mqtt_auth_failure_reasons_total{reason=rate_limited} = %v, want 2", got)
+	}
+
+	// Other client IDs are counted separately
+	if code := reconnect("steady"); code != packets.CodeSuccess.Code {
+		t.Errorf("CONNACK for another client = %#x, want success", code)
+	}
+
+	// Refused connects don't extend the window: it ends a minute after the first connect
+	advance(59 * time.Second)
+	if code := reconnect("flapper"); code != packets.ErrConnectionRateExceeded.Code {
+		t.Errorf("CONNACK late in the window = %#x, want connection rate exceeded", code)
+	}
+	advance(time.Second)
+	if code := reconnect("flapper"); code != packets.CodeSuccess.Code {
+		t.Errorf("CONNACK after the window = %#x, want success", code)
+	}
+}
+
+func TestNewReconnectLimitHook_Invalid(t *testing.T) {
+	server := New(&Config{})
+	for _, cfg := range []Config{
+		{ReconnectLimit: 0, ReconnectWindow: time.Minute},
+		{ReconnectLimit: 5, ReconnectWindow: 0},
+	} {
+		if _, err := NewReconnectLimitHook(server.Server, &cfg); err == nil {
+			t.Errorf("NewReconnectLimitHook(%+v) accepted an invalid config", cfg)
+		}
+	}
+}
@@ -3,15 +3,38 @@ package mqtt
 import (
 	"fmt"
 	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	mqtt "github.com/mochi-mqtt/server/v2"
 	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
 )
 
 // Server wraps the mochi-mqtt server
 type Server struct {
 	*mqtt.Server
-	config *Config
+	config  *Config
+	sysStop chan struct{}
+
+	closeOnce sync.Once
+	closeErr  error
+
+	hooksMu sync.Mutex
+	hookIDs []string // IDs of registered hooks, in order
+
+	startupRetained []RetainedSeed // Published once the server is serving
+	connectChecks   []ConnectCheck // Run once the auth hook accepts a client
+}
+
+// RetainedSeed is a retained message published every time the server starts
+type RetainedSeed struct {
+	Topic   string
+	Payload []byte
+	QoS     byte
 }
 
 // New creates a new MQTT server instance
@@ -23,21 +46,80 @@ func New(cfg *Config) *Server {
 	opts := &mqtt.Options{
 		Capabilities: mqtt.NewDefaultServerCapabilities(),
 		InlineClient: true, // Enable inline client for bridge inbound messages
+		// $SYS topics are published by our own publisher (see sys.go), so push
+		// mochi's built-in resend interval out of the way
+		SysTopicResendInterval: math.MaxInt32,
 	}
 
 	if !cfg.RetainAvailable {
 		opts.Capabilities.RetainAvailable = 0
 	}
 
-	return &Server{
-		Server: mqtt.New(opts),
-		config: cfg,
+	if cfg.MaxInflight > 0 {
+		opts.Capabilities.MaximumInflight = uint16(min(cfg.MaxInflight, math.MaxUint16))
+	}
+	if cfg.MaxQueued > 0 {
+		opts.Capabilities.MaximumClientWritesPending = int32(min(cfg.MaxQueued, math.MaxInt32))
+	}
+
+	if cfg.SessionExpiryMax > 0 {
+		opts.Capabilities.MaximumSessionExpiryInterval = uint32(durationSeconds(cfg.SessionExpiryMax, math.MaxUint32))
+	}
+
+	s := &Server{
+		Server:  mqtt.New(opts),
+		config:  cfg,
+		sysStop: make(chan struct{}),
 	}
+
+	if cfg.MaxKeepalive > 0 || cfg.SessionExpiryMax > 0 {
+		if err := s.AddHook(NewSessionLimitsHook(s.Server, cfg), nil); err != nil {
+			slog.Error("Failed to add session limits hook", "error", err)
+		}
+	}
+	if cfg.IdleTimeout > 0 {
+		if err := s.AddHook(NewIdleHook(s.Server, cfg.IdleTimeout), nil); err != nil {
+			slog.Error("Failed to add idle disconnect hook", "error", err)
+		}
+	}
+
+	return s
+}
+
+// AddHook adds a hook to the server, recording its ID for HookIDs
+func (s *Server) AddHook(hook mqtt.Hook, config any) error {
+	if err := s.Server.AddHook(hook, config); err != nil {
+		return err
+	}
+	s.hooksMu.Lock()
+	s.hookIDs = append(s.hookIDs, hook.ID())
+	s.hooksMu.Unlock()
+	return nil
+}
+
+// HookIDs returns the IDs of hooks added through AddHook, in order
+func (s *Server) HookIDs() []string {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+	return append([]string(nil), s.hookIDs...)
+}
+
+// Config returns a copy of the server configuration
+func (s *Server) Config() Config {
+	return *s.config
+}
+
+// SetStartupRetained sets retained messages to publish when Start has
+// loaded the stored retained messages. They go through the inline client, so
+// the retained hook persists them and each restart overwrites the stored copy
+func (s *Server) SetStartupRetained(messages []RetainedSeed) {
+	s.startupRetained = messages
 }
 
 // AddAuthHook adds an authentication hook to the server
+// Clients it accepts then go through the connect checks (see AddConnectCheck)
 func (s *Server) AddAuthHook(hook mqtt.Hook) error {
-	return s.AddHook(hook, nil)
+	return s.AddHook(&checkedAuthHook{Hook: hook, server: s}, nil)
 }
 
 // AddACLHook adds an ACL hook to the server
@@ -49,21 +131,28 @@ func (s *Server) AddACLHook(hook mqtt.Hook) error {
 func (s *Server) Start() error {
 	// Add TCP listener
 	if s.config.TCPAddr != "" {
-		tcp := listeners.NewTCP(listeners.Config{
-			ID:      "tcp",
+		var tcp listeners.Listener = listeners.NewTCP(listeners.Config{
+			ID:      ListenerTCP,
 			Address: s.config.TCPAddr,
 		})
+		if s.config.ProxyProtocol {
+			l, err := newProxyListener(s.config.TCPAddr, s.config.ProxyTrustedCIDRs)
+			if err != nil {
+				return fmt.Errorf("failed to add TCP listener: %w", err)
+			}
+			tcp = listeners.NewNet(ListenerTCP, l)
+		}
 		err := s.AddListener(tcp)
 		if err != nil {
 			return fmt.Errorf("failed to add TCP listener: %w", err)
 		}
-		slog.Info("MQTT TCP listener started", "address", s.config.TCPAddr)
+		slog.Info("MQTT TCP listener started", "address", s.config.TCPAddr, "proxy_protocol", s.config.ProxyProtocol)
 	}
 
 	// Add WebSocket listener
 	if s.config.WSAddr != "" {
 		ws := listeners.NewWebsocket(listeners.Config{
-			ID:      "ws",
+			ID:      ListenerWebSocket,
 			Address: s.config.WSAddr,
 		})
 		err := s.AddListener(ws)
@@ -73,8 +162,48 @@ func (s *Server) Start() error {
 		slog.Info("MQTT WebSocket listener started", "address", s.config.WSAddr)
 	}
 
+	// Add Unix domain socket listener (local-only control channel)
+	if s.config.UnixSocket != "" {
+		unix := listeners.NewUnixSock(listeners.Config{
+			ID:      ListenerUnix,
+			Address: s.config.UnixSocket,
+		})
+		err := s.AddListener(unix)
+		if err != nil {
+			return fmt.Errorf("failed to add Unix socket listener: %w", err)
+		}
+		slog.Info("MQTT Unix socket listener started", "path", s.config.UnixSocket)
+	}
+
 	// Start the server
-	return s.Serve()
+	if err := s.Serve(); err != nil {
+		return err
+	}
+
+	s.publishStartupRetained()
+	s.startSysPublisher()
+	return nil
+}
+
+// publishStartupRetained publishes the configured startup retained messages
+func (s *Server) publishStartupRetained() {
+	for _, msg := range s.startupRetained {
+		if err := s.Publish(msg.Topic, msg.Payload, true, msg.QoS); err != nil {
+			slog.Error("Failed to publish startup retained message", "topic", msg.Topic, "error", err)
+			continue
+		}
+		slog.Info("Published startup retained message", "topic", msg.Topic)
+	}
+}
+
+// Close stops the $SYS publisher and shuts down the MQTT server
+// Later calls return the result of the first
+func (s *Server) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.sysStop)
+		s.closeErr = s.Server.Close()
+	})
+	return s.closeErr
 }
 
 // GetClients returns information about all connected clients
@@ -129,6 +258,18 @@ func (s *Server) GetClientDetails(clientID string) (*ClientDetails, error) {
 	}, nil
 }
 
+// ClientQueueDepth returns the number of QoS 1/2 messages held for a client
+// (awaiting delivery or acknowledgement) and the per-client maximum
+// Sessions of disconnected persistent clients are included, so this also
+// reports messages queued while a client is offline
+func (s *Server) ClientQueueDepth(clientID string) (depth, limit int, ok bool) {
+	cl, ok := s.Clients.Get(clientID)
+	if !ok {
+		return 0, 0, false
+	}
+	return cl.State.Inflight.Len(), int(s.Options.Capabilities.MaximumInflight), true
+}
+
 // ClientInfo holds basic information about a connected client
 type ClientInfo struct {
 	ID                 string `json:"id"`
@@ -161,6 +302,111 @@ type SubscriptionInfo struct {
 	QoS   byte   `json:"qos"`
 }
 
+// SessionInfo describes the session the broker holds for a client, which
+// outlives the connection for clients that did not ask for a clean session
+type SessionInfo struct {
+	ClientID        string             `json:"client_id"`
+	Username        string             `json:"username"`
+	Connected       bool               `json:"connected"`
+	Clean           bool               `json:"clean"`
+	ProtocolVersion byte               `json:"protocol_version"`
+	DisconnectedAt  int64              `json:"disconnected_at,omitempty"` // Unix time, 0 while connected
+	Subscriptions   []SubscriptionInfo `json:"subscriptions"`
+	InflightCount   int                `json:"inflight_count"` // Sent and awaiting acknowledgement
+	QueuedCount     int                `json:"queued_count"`   // Not sent yet: the client is offline or over its receive maximum
+}
+
+// GetSession returns the session held for a client, connected or not
+func (s *Server) GetSession(clientID string) (*SessionInfo, bool) {
+	cl, ok := s.Clients.Get(clientID)
+	if !ok {
+		return nil, false
+	}
+
+	info := &SessionInfo{
+		ClientID:        cl.ID,
+		Username:        string(cl.Properties.Username),
+		Connected:       !cl.Closed(),
+		Clean:           cl.Properties.Clean,
+		ProtocolVersion: cl.Properties.ProtocolVersion,
+		Subscriptions:   []SubscriptionInfo{},
+	}
+	if !info.Connected {
+		info.DisconnectedAt = cl.StopTime()
+	}
+
+	for filter, sub := range cl.State.Subscriptions.GetAll() {
+		info.Subscriptions = append(info.Subscriptions, SubscriptionInfo{Topic: filter, QoS: sub.Qos})
+	}
+	sort.Slice(info.Subscriptions, func(i, j int) bool {
+		return info.Subscriptions[i].Topic < info.Subscriptions[j].Topic
+	})
+
+	// Everything held for an offline client waits for it to reconnect; a
+	// connected client's messages held back by its send quota have Expiry -1
+	for _, pk := range cl.State.Inflight.GetAll(false) {
+		if !info.Connected || pk.Expiry == -1 {
+			info.QueuedCount++
+		} else {
+			info.InflightCount++
+		}
+	}
+
+	return info, true
+}
+
+// ClearRetainedMessage removes the in-memory retained message for a topic
+// Persistent storage is not touched (hooks only fire for published packets)
+func (s *Server) ClearRetainedMessage(topic string) {
+	s.Topics.RetainMessage(packets.Packet{
+		FixedHeader: packets.FixedHeader{
+			Type:   packets.Publish,
+			Retain: true,
+		},
+		TopicName: topic,
+	})
+}
+
+// RestoreRetainedMessage installs a retained message in the broker's in-memory store
+// Unlike Publish, nothing is delivered to current subscribers and no hooks fire,
+// so the caller is responsible for persisting the message
+func (s *Server) RestoreRetainedMessage(topic string, payload []byte, qos byte) {
+	s.Topics.RetainMessage(packets.Packet{
+		FixedHeader: packets.FixedHeader{
+			Type:   packets.Publish,
+			Retain: true,
+			Qos:    qos,
+		},
+		TopicName: topic,
+		Payload:   payload,
+		Created:   time.Now().Unix(),
+	})
+}
+
+// ReloadRetained replaces the broker's retained messages with messages, e.g. after
+// storage was changed directly. Retained topics missing from messages are dropped,
+// except $SYS topics, which the broker maintains itself. Subscribers are not notified
+func (s *Server) ReloadRetained(messages []RetainedSeed) (loaded, removed int) {
+	keep := make(map[string]struct{}, len(messages))
+	for _, msg := range messages {
+		keep[msg.Topic] = struct{}{}
+	}
+
+	for topic := range s.Topics.Retained.GetAll() {
+		if _, ok := keep[topic]; ok || strings.HasPrefix(topic, "$SYS/") {
+			continue
+		}
+		s.ClearRetainedMessage(topic)
+		removed++
+	}
+
+	for _, msg := range messages {
+		s.RestoreRetainedMessage(msg.Topic, msg.Payload, msg.QoS)
+		loaded++
+	}
+	return loaded, removed
+}
+
 // DisconnectClient forcefully disconnects a client by ID
 func (s *Server) DisconnectClient(clientID string) error {
 	cl, ok := s.Clients.Get(clientID)
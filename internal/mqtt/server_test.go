@@ -0,0 +1,98 @@
+package mqtt
+
+import (
+	"testing"
+
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github/bromq-dev/bromq/hooks/retained"
+	"github/bromq-dev/bromq/internal/badgerstore"
+)
+
+func TestNew_ClientBufferCapabilities(t *testing.T) {
+	tests := []struct {
+		name         string
+		cfg          *Config
+		wantInflight uint16
+		wantPending  int32
+	}{
+		{name: "broker defaults", cfg: &Config{}, wantInflight: 8192, wantPending: 8192},
+		{name: "configured", cfg: &Config{MaxInflight: 100, MaxQueued: 50}, wantInflight: 100, wantPending: 50},
+		{name: "inflight capped", cfg: &Config{MaxInflight: 100000}, wantInflight: 65535, wantPending: 8192},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caps := New(tt.cfg).Options.Capabilities
+
+			if caps.MaximumInflight != tt.wantInflight {
+				t.Errorf("MaximumInflight = %d, want %d", caps.MaximumInflight, tt.wantInflight)
+			}
+			if caps.MaximumClientWritesPending != tt.wantPending {
+				t.Errorf("MaximumClientWritesPending = %d, want %d", caps.MaximumClientWritesPending, tt.wantPending)
+			}
+		})
+	}
+}
+
+func TestClientQueueDepth(t *testing.T) {
+	server := New(&Config{MaxInflight: 100})
+
+	cl := server.NewClient(nil, "tcp", "slow-consumer", false)
+	for id := uint16(1); id <= 3; id++ {
+		cl.State.Inflight.Set(packets.Packet{
+			FixedHeader: packets.FixedHeader{Type: packets.Publish, Qos: 1},
+			PacketID:    id,
+		})
+	}
+	server.Clients.Add(cl)
+
+	depth, limit, ok := server.ClientQueueDepth("slow-consumer")
+	if !ok {
+		t.Fatal("ClientQueueDepth() ok = false, want true")
+	}
+	if depth != 3 || limit != 100 {
+		t.Errorf("ClientQueueDepth() = (%d, %d), want (3, 100)", depth, limit)
+	}
+
+	if _, _, ok := server.ClientQueueDepth("unknown"); ok {
+		t.Error("ClientQueueDepth() ok = true for unknown client, want false")
+	}
+}
+
+func TestStartupRetained(t *testing.T) {
+	store := badgerstore.OpenInMemory(t)
+
+	start := func(payload string) *Server {
+		t.Helper()
+		server := New(&Config{RetainAvailable: true})
+		if err := server.AddHook(retained.NewRetainedHook(store), nil); err != nil {
+			t.Fatalf("AddHook() error = %v", err)
+		}
+		server.SetStartupRetained([]RetainedSeed{{Topic: "broker/status", Payload: []byte(payload), QoS: 1}})
+		if err := server.Start(); err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+		t.Cleanup(func() { _ = server.Close() })
+		return server
+	}
+
+	server := start("online")
+	if messages := server.Topics.Messages("broker/status"); len(messages) != 1 || string(messages[0].Payload) != "online" {
+		t.Fatalf("retained messages = %v, want one with payload online", messages)
+	}
+	stored, err := store.GetRetainedMessage("broker/status")
+	if err != nil || string(stored.Payload) != "online" || stored.QoS != 1 {
+		t.Fatalf("stored retained message = %+v, %v; want online at QoS 1", stored, err)
+	}
+
+	// A restart with a new payload replaces the stored message rather than adding one
+	server = start("online v2")
+	if messages := server.Topics.Messages("broker/status"); len(messages) != 1 || string(messages[0].Payload) != "online v2" {
+		t.Errorf("retained messages after restart = %v, want one with payload online v2", messages)
+	}
+	all, err := store.GetAllRetainedMessages()
+	if err != nil || len(all) != 1 || string(all[0].Payload) != "online v2" {
+		t.Errorf("stored retained messages after restart = %d, %v; want one with payload online v2", len(all), err)
+	}
+}
@@ -0,0 +1,51 @@
+package mqtt
+
+import "sort"
+
+// FilterSubscriptions lists the clients subscribed to one topic filter
+type FilterSubscriptions struct {
+	Filter      string       `json:"filter"`
+	Subscribers int          `json:"subscribers"`
+	Clients     []Subscriber `json:"clients"`
+}
+
+// Subscriber is one client's subscription to a filter
+type Subscriber struct {
+	ClientID string `json:"client_id"`
+	Username string `json:"username,omitempty"`
+	QoS      byte   `json:"qos"`
+}
+
+// Subscriptions aggregates the subscriptions of every client session by filter,
+// sorted by filter. Sessions of disconnected persistent clients are included
+func (s *Server) Subscriptions() []FilterSubscriptions {
+	byFilter := make(map[string]*FilterSubscriptions)
+
+	for _, cl := range s.Clients.GetAll() {
+		if cl.Net.Inline {
+			continue
+		}
+		for filter, sub := range cl.State.Subscriptions.GetAll() {
+			entry, ok := byFilter[filter]
+			if !ok {
+				entry = &FilterSubscriptions{Filter: filter}
+				byFilter[filter] = entry
+			}
+			entry.Clients = append(entry.Clients, Subscriber{
+				ClientID: cl.ID,
+				Username: string(cl.Properties.Username),
+				QoS:      sub.Qos,
+			})
+		}
+	}
+
+	result := make([]FilterSubscriptions, 0, len(byFilter))
+	for _, entry := range byFilter {
+		entry.Subscribers = len(entry.Clients)
+		sort.Slice(entry.Clients, func(i, j int) bool { return entry.Clients[i].ClientID < entry.Clients[j].ClientID })
+		result = append(result, *entry)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Filter < result[j].Filter })
+
+	return result
+}
@@ -0,0 +1,93 @@
+package mqtt
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// sysTopicPrefix is the root of all broker stats topics
+const sysTopicPrefix = "$SYS/"
+
+// startSysPublisher publishes broker stats under $SYS/broker/... at the configured interval
+// When disabled, any $SYS values left over from server startup are cleared instead
+func (s *Server) startSysPublisher() {
+	if !s.config.SysTopicsEnabled {
+		s.clearSysTopics()
+		return
+	}
+
+	interval := s.config.SysInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	s.publishSysTopics()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.publishSysTopics()
+			case <-s.sysStop:
+				return
+			}
+		}
+	}()
+
+	slog.Info("$SYS topic publishing enabled", "interval", interval)
+}
+
+// sysTopics returns the current $SYS topic values
+func (s *Server) sysTopics() map[string]string {
+	m := s.GetMetrics()
+
+	return map[string]string{
+		"$SYS/broker/version":             s.Info.Version,
+		"$SYS/broker/uptime":              strconv.FormatInt(int64(m.Uptime.Seconds()), 10),
+		"$SYS/broker/clients/connected":   strconv.Itoa(m.ConnectedClients),
+		"$SYS/broker/clients/total":       strconv.Itoa(m.TotalClients),
+		"$SYS/broker/messages/received":   strconv.FormatInt(m.MessagesReceived, 10),
+		"$SYS/broker/messages/sent":       strconv.FormatInt(m.MessagesSent, 10),
+		"$SYS/broker/messages/dropped":    strconv.FormatInt(m.MessagesDropped, 10),
+		"$SYS/broker/retained/messages":   strconv.Itoa(m.RetainedMessages),
+		"$SYS/broker/subscriptions/count": strconv.Itoa(m.SubscriptionsTotal),
+	}
+}
+
+// publishSysTopics publishes the current stats to $SYS subscribers
+// Values are retained in memory only so they never reach persistent retained storage
+func (s *Server) publishSysTopics() {
+	for topic, value := range s.sysTopics() {
+		payload := []byte(value)
+
+		s.Topics.RetainMessage(packets.Packet{
+			FixedHeader: packets.FixedHeader{
+				Type:   packets.Publish,
+				Retain: true,
+			},
+			TopicName: topic,
+			Payload:   payload,
+			Created:   time.Now().Unix(),
+		})
+
+		if err := s.Publish(topic, payload, false, 0); err != nil {
+			slog.Warn("Failed to publish $SYS topic", "topic", topic, "error", err)
+		}
+	}
+}
+
+// clearSysTopics removes in-memory retained $SYS messages
+func (s *Server) clearSysTopics() {
+	for topic := range s.Topics.Retained.GetAll() {
+		if strings.HasPrefix(topic, sysTopicPrefix) {
+			s.ClearRetainedMessage(topic)
+		}
+	}
+}
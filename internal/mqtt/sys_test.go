@@ -0,0 +1,91 @@
+package mqtt
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+func TestSysPublisher(t *testing.T) {
+	cfg := &Config{
+		SysTopicsEnabled: true,
+		SysInterval:      20 * time.Millisecond,
+	}
+	server := New(cfg)
+
+	var mu sync.Mutex
+	received := make(map[string]string)
+
+	// Subscribe an inline client to all $SYS topics before starting
+	err := server.Subscribe("$SYS/#", 1, func(cl *mqtt.Client, sub packets.Subscription, pk packets.Packet) {
+		mu.Lock()
+		defer mu.Unlock()
+		received[pk.TopicName] = string(pk.Payload)
+	})
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Close() }()
+
+	want := []string{
+		"$SYS/broker/uptime",
+		"$SYS/broker/clients/connected",
+		"$SYS/broker/messages/received",
+		"$SYS/broker/messages/sent",
+		"$SYS/broker/retained/messages",
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		missing := ""
+		for _, topic := range want {
+			if _, ok := received[topic]; !ok {
+				missing = topic
+				break
+			}
+		}
+		mu.Unlock()
+
+		if missing == "" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Did not receive %s (got %v)", missing, received)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSysPublisher_Disabled(t *testing.T) {
+	server := New(&Config{SysTopicsEnabled: false})
+
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer func() { _ = server.Close() }()
+
+	for topic := range server.Topics.Retained.GetAll() {
+		if strings.HasPrefix(topic, sysTopicPrefix) {
+			t.Errorf("Expected no retained $SYS topics when disabled, found %s", topic)
+		}
+	}
+}
+
+func TestServer_CloseTwice(t *testing.T) {
+	server := New(&Config{SysTopicsEnabled: true, SysInterval: time.Hour})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+
+	_ = server.Close()
+	_ = server.Close() // Must not panic on the already closed server
+}
@@ -0,0 +1,113 @@
+package mqtt
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// Rejection reasons for topics over the configured length or depth
+const (
+	RejectReasonTopicLength = "topic_length"
+	RejectReasonTopicDepth  = "topic_depth"
+)
+
+// TopicLimitsHook rejects publishes and subscriptions whose topic is longer
+// than maxLength bytes or has more than maxLevels levels (0 = unlimited).
+// Inline publishes (scripts, bridges) are not limited
+type TopicLimitsHook struct {
+	mqtt.HookBase
+	maxLength int
+	maxLevels int
+	metrics   *PrometheusMetrics
+}
+
+// NewTopicLimitsHook creates a hook enforcing the topic limits in cfg
+func NewTopicLimitsHook(cfg *Config) *TopicLimitsHook {
+	return &TopicLimitsHook{
+		maxLength: cfg.MaxTopicLength,
+		maxLevels: cfg.MaxTopicLevels,
+	}
+}
+
+// SetMetrics counts rejected publishes in mqtt_messages_rejected_total and
+// rejected subscriptions in mqtt_subscriptions_rejected_total
+func (h *TopicLimitsHook) SetMetrics(metrics *PrometheusMetrics) {
+	h.metrics = metrics
+}
+
+// ID returns the hook identifier
+func (h *TopicLimitsHook) ID() string {
+	return "topic-limits"
+}
+
+// Provides indicates which hook methods this hook provides
+func (h *TopicLimitsHook) Provides(b byte) bool {
+	return bytes.Contains([]byte{
+		mqtt.OnPublish,
+		mqtt.OnSubscribe,
+	}, []byte{b})
+}
+
+// Check returns the rejection reason for topic, or "" if it is within limits
+// The $share/<group>/ prefix of a shared subscription doesn't count as levels
+func (h *TopicLimitsHook) Check(topic string) string {
+	if h.maxLength > 0 && len(topic) > h.maxLength {
+		return RejectReasonTopicLength
+	}
+	if h.maxLevels > 0 && strings.Count(unshared(topic), "/")+1 > h.maxLevels {
+		return RejectReasonTopicDepth
+	}
+	return ""
+}
+
+// OnPublish rejects the message if its topic is over the limits
+func (h *TopicLimitsHook) OnPublish(cl *mqtt.Client, pk packets.Packet) (packets.Packet, error) {
+	if cl.Net.Inline {
+		return pk, nil
+	}
+
+	reason := h.Check(pk.TopicName)
+	if reason == "" {
+		return pk, nil
+	}
+
+	slog.Debug("Rejected publish over topic limits", "client_id", cl.ID, "reason", reason, "length", len(pk.TopicName))
+	if h.metrics != nil {
+		h.metrics.RecordMessageRejected(reason)
+	}
+	return pk, rejectPublish(cl, pk, packets.ErrTopicNameInvalid)
+}
+
+// OnSubscribe blanks filters over the limits. mochi then answers them with
+// Topic Filter Invalid in the SUBACK, keeping the other filters of the packet
+func (h *TopicLimitsHook) OnSubscribe(cl *mqtt.Client, pk packets.Packet) packets.Packet {
+	for i, sub := range pk.Filters {
+		reason := h.Check(sub.Filter)
+		if reason == "" {
+			continue
+		}
+
+		slog.Debug("Rejected subscription over topic limits", "client_id", cl.ID, "reason", reason, "length", len(sub.Filter))
+		if h.metrics != nil {
+			h.metrics.RecordSubscriptionRejected(reason)
+		}
+		pk.Filters[i].Filter = ""
+	}
+	return pk
+}
+
+// unshared strips the $share/<group>/ prefix from a shared subscription filter
+func unshared(filter string) string {
+	rest, ok := strings.CutPrefix(filter, "$share/")
+	if !ok {
+		return filter
+	}
+	if _, after, found := strings.Cut(rest, "/"); found {
+		return after
+	}
+	return filter
+}
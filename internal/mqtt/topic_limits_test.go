@@ -0,0 +1,94 @@
+package mqtt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTopicLimitsHook_OnPublish(t *testing.T) {
+	hook := NewTopicLimitsHook(&Config{MaxTopicLength: 32, MaxTopicLevels: 4})
+	metrics := NewPrometheusMetricsWithRegistry(prometheus.NewRegistry())
+	hook.SetMetrics(metrics)
+
+	server := New(nil)
+	tests := []struct {
+		name    string
+		version byte
+		qos     byte
+		topic   string
+		wantErr error
+	}{
+		{name: "compliant topic passes", version: 4, topic: "sensors/room1/temp"},
+		{name: "topic at both limits passes", version: 4, topic: "a/b/c/" + strings.Repeat("x", 26)},
+		{name: "over-long topic dropped", version: 4, topic: "sensors/" + strings.Repeat("x", 25), wantErr: packets.ErrRejectPacket},
+		{name: "over-deep topic dropped", version: 4, topic: "a/b/c/d/e", wantErr: packets.ErrRejectPacket},
+		{name: "v5 qos1 publisher gets reason code", version: 5, qos: 1, topic: "a/b/c/d/e", wantErr: packets.ErrTopicNameInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := server.NewClient(nil, ListenerTCP, "topic-client", false)
+			cl.Properties.ProtocolVersion = tt.version
+			pk := packets.Packet{
+				FixedHeader: packets.FixedHeader{Type: packets.Publish, Qos: tt.qos},
+				TopicName:   tt.topic,
+			}
+
+			_, err := hook.OnPublish(cl, pk)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("OnPublish() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if got := testutil.ToFloat64(metrics.messagesRejected.WithLabelValues(RejectReasonTopicLength)); got != 1 {
+		t.Errorf("rejected for length = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.messagesRejected.WithLabelValues(RejectReasonTopicDepth)); got != 2 {
+		t.Errorf("rejected for depth = %v, want 2", got)
+	}
+
+	// Inline publishes (scripts, bridges) are never limited
+	inline := server.NewClient(nil, "local", "inline", true)
+	if _, err := hook.OnPublish(inline, packets.Packet{TopicName: "a/b/c/d/e/f"}); err != nil {
+		t.Errorf("OnPublish() for inline client error = %v, want nil", err)
+	}
+}
+
+func TestTopicLimitsHook_OnSubscribe(t *testing.T) {
+	hook := NewTopicLimitsHook(&Config{MaxTopicLength: 32, MaxTopicLevels: 3})
+	metrics := NewPrometheusMetricsWithRegistry(prometheus.NewRegistry())
+	hook.SetMetrics(metrics)
+
+	server := New(nil)
+	cl := server.NewClient(nil, ListenerTCP, "topic-client", false)
+	pk := packets.Packet{
+		FixedHeader: packets.FixedHeader{Type: packets.Subscribe},
+		Filters: packets.Subscriptions{
+			{Filter: "sensors/+/temp"},
+			{Filter: "$share/group/sensors/+/temp"}, // The share prefix isn't counted as levels
+			{Filter: "sensors/+/temp/#"},
+			{Filter: "logs/" + strings.Repeat("x", 28)},
+		},
+	}
+
+	pk = hook.OnSubscribe(cl, pk)
+	want := []string{"sensors/+/temp", "$share/group/sensors/+/temp", "", ""}
+	for i, filter := range want {
+		if pk.Filters[i].Filter != filter {
+			t.Errorf("Filters[%d] = %q, want %q", i, pk.Filters[i].Filter, filter)
+		}
+	}
+
+	if got := testutil.ToFloat64(metrics.subscriptionsRejected.WithLabelValues(RejectReasonTopicDepth)); got != 1 {
+		t.Errorf("rejected for depth = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.subscriptionsRejected.WithLabelValues(RejectReasonTopicLength)); got != 1 {
+		t.Errorf("rejected for length = %v, want 1", got)
+	}
+}
@@ -0,0 +1,125 @@
+package mqtt
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+// Policies for a connecting client whose last will is not allowed
+const (
+	WillPolicyStrip  = "strip"  // Accept the client without its will
+	WillPolicyReject = "reject" // Refuse the client with Not Authorized
+)
+
+// RejectReasonWillDenied is the rejection reason for clients refused over their will
+const RejectReasonWillDenied = "will_denied"
+
+// WillACL answers whether a client's rules grant an action on a topic, without
+// the side effects of a real ACL check (see auth.ACLHook.Allows)
+type WillACL interface {
+	Allows(cl *mqtt.Client, topic, action string) (bool, error)
+}
+
+// WillPolicyHook checks the last will of connecting clients. Mochi publishes
+// wills without an ACL check, so a client could otherwise leave a message on a
+// topic it may not publish to, such as $SYS/#. A will on a denied topic, or one
+// the client's ACL doesn't allow publishing to, is stripped or the client is refused
+// It is a connect check (see Server.AddConnectCheck), so the ACL is only
+// consulted for clients that have authenticated
+type WillPolicyHook struct {
+	server  *mqtt.Server
+	deny    []string
+	reject  bool
+	acl     WillACL
+	metrics *PrometheusMetrics
+}
+
+// NewWillPolicyHook creates a hook denying wills on the cfg.WillDenyTopics
+// filters and applying cfg.WillPolicy to them
+func NewWillPolicyHook(server *mqtt.Server, cfg *Config) (*WillPolicyHook, error) {
+	hook := &WillPolicyHook{server: server}
+	switch cfg.WillPolicy {
+	case WillPolicyStrip, "":
+	case WillPolicyReject:
+		hook.reject = true
+	default:
+		return nil, fmt.Errorf("invalid will policy '%s' (expected %s or %s)", cfg.WillPolicy, WillPolicyStrip, WillPolicyReject)
+	}
+	for _, filter := range strings.Split(cfg.WillDenyTopics, ",") {
+		if filter = strings.TrimSpace(filter); filter != "" {
+			hook.deny = append(hook.deny, filter)
+		}
+	}
+	return hook, nil
+}
+
+// SetACL also denies wills on topics the client's ACL doesn't let it publish to
+func (h *WillPolicyHook) SetACL(acl WillACL) {
+	h.acl = acl
+}
+
+// SetMetrics counts clients refused over their will in mqtt_connections_rejected_total
+func (h *WillPolicyHook) SetMetrics(metrics *PrometheusMetrics) {
+	h.metrics = metrics
+}
+
+// Allowed reports whether cl may leave a will on topic
+func (h *WillPolicyHook) Allowed(cl *mqtt.Client, topic string) bool {
+	for _, filter := range h.deny {
+		if storage.MatchTopic(filter, topic) {
+			return false
+		}
+	}
+	if h.acl == nil {
+		return true
+	}
+	allowed, err := h.acl.Allows(cl, topic, "pub")
+	if err != nil {
+		slog.Error("Will ACL check error", "client_id", cl.ID, "will_topic", topic, "error", err)
+		return false
+	}
+	return allowed
+}
+
+// OnAuthenticated strips a disallowed will from the client, or refuses the
+// client under the reject policy. It runs before the session (and its will) is stored
+func (h *WillPolicyHook) OnAuthenticated(cl *mqtt.Client, pk packets.Packet) error {
+	if cl.Net.Inline || cl.Properties.Will.Flag == 0 {
+		return nil
+	}
+
+	topic := cl.Properties.Will.TopicName
+	if h.Allowed(cl, topic) {
+		return nil
+	}
+
+	if h.reject {
+		slog.Warn("Rejected client with a disallowed will",
+			"client_id", cl.ID,
+			"username", string(cl.Properties.Username),
+			"remote", cl.Net.Remote,
+			"will_topic", topic)
+		if h.metrics != nil {
+			h.metrics.RecordConnectionRejected(RejectReasonWillDenied)
+		}
+		code := packets.ErrNotAuthorized
+		if cl.Properties.ProtocolVersion < 5 {
+			code = packets.ErrBadUsernameOrPassword // Mapped to Not Authorized for v3 clients by SendConnack
+		}
+		_ = h.server.SendConnack(cl, code, false, nil)
+		return code
+	}
+
+	slog.Info("Stripped disallowed will from client",
+		"client_id", cl.ID,
+		"username", string(cl.Properties.Username),
+		"will_topic", topic)
+	cl.Properties.Will = mqtt.Will{}
+	return nil
+}
@@ -0,0 +1,150 @@
+package mqtt
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// connectWithWill dials addr and sends a v5 CONNECT for clientID with a will on
+// willTopic, returning the CONNACK reason code
+func connectWithWill(t *testing.T, addr, clientID, willTopic string) byte {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	pk := packets.Packet{
+		FixedHeader:     packets.FixedHeader{Type: packets.Connect},
+		ProtocolVersion: 5,
+		Connect: packets.ConnectParams{
+			ProtocolName:     []byte("MQTT"),
+			Clean:            true,
+			Keepalive:        30,
+			ClientIdentifier: clientID,
+			WillFlag:         true,
+			WillTopic:        willTopic,
+			WillPayload:      []byte("offline"),
+		},
+	}
+	buf := new(bytes.Buffer)
+	if err := pk.ConnectEncode(buf); err != nil {
+		t.Fatalf("ConnectEncode() error = %v", err)
+	}
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		t.Fatalf("reading CONNACK: %v", err)
+	}
+	connack := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, connack); err != nil || len(connack) < 2 {
+		t.Fatalf("reading CONNACK: %v", err)
+	}
+	return connack[1]
+}
+
+// denyTopicACL denies publishing to a single topic
+type denyTopicACL string
+
+func (a denyTopicACL) Allows(cl *mqtt.Client, topic, action string) (bool, error) {
+	return topic != string(a), nil
+}
+
+func TestWillPolicyHook(t *testing.T) {
+	start := func(t *testing.T, cfg *Config, acl WillACL) (*Server, *PrometheusMetrics) {
+		t.Helper()
+		cfg.TCPAddr = "127.0.0.1:0"
+		server := New(cfg)
+		hook, err := NewWillPolicyHook(server.Server, server.config)
+		if err != nil {
+			t.Fatalf("NewWillPolicyHook() error = %v", err)
+		}
+		if acl != nil {
+			hook.SetACL(acl)
+		}
+		metrics := NewPrometheusMetricsWithRegistry(prometheus.NewRegistry())
+		hook.SetMetrics(metrics)
+		server.AddConnectCheck(hook)
+		if err := server.AddAuthHook(new(auth.AllowHook)); err != nil {
+			t.Fatalf("AddAuthHook() error = %v", err)
+		}
+		if err := server.Start(); err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+		t.Cleanup(func() { _ = server.Close() })
+		return server, metrics
+	}
+
+	willOf := func(t *testing.T, server *Server, clientID string) mqtt.Will {
+		t.Helper()
+		cl, ok := server.Clients.Get(clientID)
+		if !ok {
+			t.Fatalf("client %s not connected", clientID)
+		}
+		return cl.Properties.Will
+	}
+
+	t.Run("strip removes a will on a denied topic and keeps an allowed one", func(t *testing.T) {
+		server, _ := start(t, &Config{WillDenyTopics: "$SYS/#, alerts/#"}, nil)
+
+		if code := connectWithWill(t, tcpAddress(server), "sneaky", "$SYS/broker/uptime"); code != packets.CodeSuccess.Code {
+			t.Fatalf("CONNACK with a denied will = %#x, want success", code)
+		}
+		if will := willOf(t, server, "sneaky"); will.Flag != 0 || will.TopicName != "" {
+			t.Errorf("denied will kept: %+v", will)
+		}
+
+		if code := connectWithWill(t, tcpAddress(server), "device-1", "status/device-1"); code != packets.CodeSuccess.Code {
+			t.Fatalf("CONNACK with an allowed will = %#x, want success", code)
+		}
+		if will := willOf(t, server, "device-1"); will.Flag != 1 || will.TopicName != "status/device-1" || string(will.Payload) != "offline" {
+			t.Errorf("allowed will = %+v, want status/device-1 kept", will)
+		}
+	})
+
+	t.Run("reject refuses a client with a denied will", func(t *testing.T) {
+		server, metrics := start(t, &Config{WillDenyTopics: "$SYS/#", WillPolicy: WillPolicyReject}, nil)
+
+		if code := connectWithWill(t, tcpAddress(server), "sneaky", "$SYS/broker/uptime"); code != packets.ErrNotAuthorized.Code {
+			t.Errorf("CONNACK with a denied will = %#x, want %#x (not authorized)", code, packets.ErrNotAuthorized.Code)
+		}
+		if got := testutil.ToFloat64(metrics.connectionsRejected.WithLabelValues(RejectReasonWillDenied)); got != 1 {
+			t.Errorf("mqtt_connections_rejected_total{reason=will_denied} = %v, want 1", got)
+		}
+		if code := connectWithWill(t, tcpAddress(server), "device-1", "status/device-1"); code != packets.CodeSuccess.Code {
+			t.Errorf("CONNACK with an allowed will = %#x, want success", code)
+		}
+	})
+
+	t.Run("ACL denies wills the client may not publish", func(t *testing.T) {
+		server, _ := start(t, &Config{WillCheckACL: true}, denyTopicACL("status/other"))
+
+		if code := connectWithWill(t, tcpAddress(server), "device-1", "status/other"); code != packets.CodeSuccess.Code {
+			t.Fatalf("CONNACK = %#x, want success", code)
+		}
+		if will := willOf(t, server, "device-1"); will.Flag != 0 {
+			t.Errorf("will denied by ACL kept: %+v", will)
+		}
+	})
+
+	t.Run("unknown policy", func(t *testing.T) {
+		server := New(&Config{WillPolicy: "drop"})
+		if _, err := NewWillPolicyHook(server.Server, server.config); err == nil {
+			t.Error("NewWillPolicyHook() accepted an unknown policy")
+		}
+	})
+}
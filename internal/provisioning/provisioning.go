@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"os"
 
 	"github/bromq-dev/bromq/internal/config"
 	"github/bromq-dev/bromq/internal/storage"
@@ -76,6 +75,25 @@ func Provision(db *storage.DB, cfg *config.Config) error {
 	return nil
 }
 
+// ProvisionedSet lists the resources cfg provisions, for repairing
+// provisioned flags with storage.RepairProvisionedFlags
+func ProvisionedSet(cfg *config.Config) storage.ProvisionedSet {
+	set := storage.ProvisionedSet{ACLRules: make(map[string][]string)}
+	for _, user := range cfg.Users {
+		set.Users = append(set.Users, user.Username)
+	}
+	for _, rule := range cfg.ACLRules {
+		set.ACLRules[rule.Username] = append(set.ACLRules[rule.Username], rule.Topic)
+	}
+	for _, bridge := range cfg.Bridges {
+		set.Bridges = append(set.Bridges, bridge.Name)
+	}
+	for _, script := range cfg.Scripts {
+		set.Scripts = append(set.Scripts, script.Name)
+	}
+	return set
+}
+
 // provisionUser creates or updates an MQTT user
 func provisionUser(db *storage.DB, userCfg config.MQTTUserConfig) (uint, error) {
 	// Check if user already exists
@@ -99,6 +117,10 @@ func provisionUser(db *storage.DB, userCfg config.MQTTUserConfig) (uint, error)
 			return 0, fmt.Errorf("failed to update user: %w", err)
 		}
 
+		if err := setUserIPRanges(db, existingUser.ID, userCfg); err != nil {
+			return 0, err
+		}
+
 		// Mark as provisioned
 		if err := db.MarkAsProvisioned(existingUser.ID, true); err != nil {
 			return 0, fmt.Errorf("failed to mark user as provisioned: %w", err)
@@ -116,19 +138,33 @@ func provisionUser(db *storage.DB, userCfg config.MQTTUserConfig) (uint, error)
 		}
 	}
 
-	user, err := db.CreateMQTTUser(userCfg.Username, userCfg.Password, userCfg.Description, metadataJSON)
+	// Created with its IP ranges in one step, so it never connects unrestricted
+	user, err := db.CreateMQTTUserWithRules(storage.NewMQTTUser{
+		Username:     userCfg.Username,
+		Password:     userCfg.Password,
+		Description:  userCfg.Description,
+		Metadata:     metadataJSON,
+		AllowedCIDRs: userCfg.AllowedCIDRs,
+		DeniedCIDRs:  userCfg.DeniedCIDRs,
+		Provisioned:  true,
+	})
 	if err != nil {
 		return 0, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	// Mark as provisioned
-	if err := db.MarkAsProvisioned(user.ID, true); err != nil {
-		return 0, fmt.Errorf("failed to mark new user as provisioned: %w", err)
-	}
-
 	return user.ID, nil
 }
 
+// setUserIPRanges replaces a user's IP allow/deny lists with the configured ones
+func setUserIPRanges(db *storage.DB, id uint, userCfg config.MQTTUserConfig) error {
+	allowed := append([]string{}, userCfg.AllowedCIDRs...)
+	denied := append([]string{}, userCfg.DeniedCIDRs...)
+	if err := db.PatchMQTTUser(id, storage.MQTTUserPatch{AllowedCIDRs: &allowed, DeniedCIDRs: &denied}); err != nil {
+		return fmt.Errorf("failed to update IP ranges: %w", err)
+	}
+	return nil
+}
+
 // syncACLRules intelligently syncs ACL rules - only modifies what changed
 func syncACLRules(db *storage.DB, userIDMap map[string]uint, configRules []config.ACLRuleConfig) error {
 	// Build map of config rules by user
@@ -160,17 +196,18 @@ func syncACLRules(db *storage.DB, userIDMap map[string]uint, configRules []confi
 		// Get config rules for this user (may be empty)
 		configRules := configRulesByUser[userID]
 
-		// Build map of existing rules: (topic, permission) -> rule
+		// Build map of existing rules: (topic, permission, retain) -> rule
 		existingMap := make(map[string]storage.ACLRule)
 		for _, rule := range provisionedRules {
-			key := rule.Topic + "|" + rule.Permission
+			key := aclRuleKey(rule.Topic, rule.Permission, rule.Retain)
 			existingMap[key] = rule
 		}
 
 		// Build set of config rules
 		configSet := make(map[string]config.ACLRuleConfig)
 		for _, ruleCfg := range configRules {
-			key := ruleCfg.Topic + "|" + ruleCfg.Permission
+			// Stored patterns are normalized, so compare normalized config patterns
+			key := aclRuleKey(storage.NormalizeTopicPattern(ruleCfg.Topic), ruleCfg.Permission, ruleCfg.Retain)
 			configSet[key] = ruleCfg
 		}
 
@@ -188,7 +225,7 @@ func syncACLRules(db *storage.DB, userIDMap map[string]uint, configRules []confi
 		for key, ruleCfg := range configSet {
 			if _, exists := existingMap[key]; !exists {
 				slog.Debug("Creating new ACL rule", "username", username, "topic", ruleCfg.Topic, "permission", ruleCfg.Permission)
-				if err := db.CreateProvisionedACLRule(userID, ruleCfg.Topic, ruleCfg.Permission); err != nil {
+				if err := db.CreateProvisionedACLRule(userID, ruleCfg.Topic, ruleCfg.Permission, ruleCfg.Retain); err != nil {
 					return fmt.Errorf("failed to create ACL rule: %w", err)
 				}
 			}
@@ -199,6 +236,11 @@ func syncACLRules(db *storage.DB, userIDMap map[string]uint, configRules []confi
 	return nil
 }
 
+// aclRuleKey identifies a provisioned rule by everything the config controls
+func aclRuleKey(topic, permission string, retain bool) string {
+	return fmt.Sprintf("%s|%s|%t", topic, permission, retain)
+}
+
 // cleanupOrphanedUsers removes users that were provisioned but are no longer in config
 func cleanupOrphanedUsers(db *storage.DB, currentUserMap map[string]uint) error {
 	// Get all provisioned users from database
@@ -247,6 +289,16 @@ func provisionBridge(db *storage.DB, bridgeCfg config.BridgeConfig) (uint, error
 		}
 	}
 
+	var maxQoS *byte
+	if bridgeCfg.MaxQoS != nil {
+		qos := byte(*bridgeCfg.MaxQoS) // #nosec G115 -- validated to 0-2 in config.Validate
+		maxQoS = &qos
+	}
+	retainPolicy := bridgeCfg.RetainPolicy
+	if retainPolicy == "" {
+		retainPolicy = storage.RetainPolicyPreserve
+	}
+
 	// Convert config topics to storage topics
 	topics := make([]storage.BridgeTopic, len(bridgeCfg.Topics))
 	for i, topicCfg := range bridgeCfg.Topics {
@@ -271,9 +323,11 @@ func provisionBridge(db *storage.DB, bridgeCfg config.BridgeConfig) (uint, error
 			"password":                bridgeCfg.Password,
 			"client_id":               bridgeCfg.ClientID,
 			"mqtt_version":            bridgeCfg.MQTTVersion,
-			"clean_session":           bridgeCfg.CleanSession,
+			"clean_session":           bridgeCfg.UsesCleanSession(),
 			"keep_alive":              bridgeCfg.KeepAlive,
 			"connection_timeout":      bridgeCfg.ConnectionTimeout,
+			"max_qos":                 maxQoS,
+			"retain_policy":           retainPolicy,
 			"metadata":                metadataJSON,
 			"provisioned_from_config": true,
 		}
@@ -306,9 +360,11 @@ func provisionBridge(db *storage.DB, bridgeCfg config.BridgeConfig) (uint, error
 		bridgeCfg.Password,
 		bridgeCfg.ClientID,
 		bridgeCfg.MQTTVersion,
-		bridgeCfg.CleanSession,
+		bridgeCfg.UsesCleanSession(),
 		bridgeCfg.KeepAlive,
 		bridgeCfg.ConnectionTimeout,
+		maxQoS,
+		retainPolicy,
 		metadataJSON,
 		topics,
 	)
@@ -349,18 +405,13 @@ func cleanupOrphanedBridges(db *storage.DB, currentBridgeMap map[string]uint) er
 // provisionScript creates or updates a script
 func provisionScript(db *storage.DB, scriptCfg config.ScriptConfig) (uint, error) {
 	// Load script content from file if specified
-	scriptContent := scriptCfg.Content
-	if scriptCfg.File != "" {
-		content, err := os.ReadFile(scriptCfg.File)
-		if err != nil {
-			return 0, fmt.Errorf("failed to read script file '%s': %w", scriptCfg.File, err)
-		}
-		scriptContent = string(content)
+	scriptContent, err := scriptCfg.Source()
+	if err != nil {
+		return 0, err
 	}
 
 	// Convert metadata to JSON
 	var metadataJSON []byte
-	var err error
 	if scriptCfg.Metadata != nil {
 		metadataJSON, err = json.Marshal(scriptCfg.Metadata)
 		if err != nil {
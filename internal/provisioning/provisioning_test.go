@@ -1,6 +1,8 @@
 package provisioning
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github/bromq-dev/bromq/internal/config"
@@ -769,3 +771,50 @@ func TestProvision_ManualACLRulesNotTouched(t *testing.T) {
 		t.Error("Manual rule was deleted (should be preserved)")
 	}
 }
+
+func TestProvision_ScriptFileRelativeToConfig(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// The script lives next to the config, not in the working directory
+	configDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(configDir, "scripts"), 0755); err != nil {
+		t.Fatalf("failed to create scripts dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "scripts", "logger.js"), []byte("log.info('hello');"), 0644); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	configYAML := `
+scripts:
+  - name: logger
+    enabled: true
+    file: ./scripts/logger.js
+    triggers:
+      - type: on_publish
+        topic: "#"
+        enabled: true
+`
+	configPath := filepath.Join(configDir, "config.yml")
+	if err := os.WriteFile(configPath, []byte(configYAML), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if want := filepath.Join(configDir, "scripts", "logger.js"); cfg.Scripts[0].File != want {
+		t.Errorf("script file = %s, want %s", cfg.Scripts[0].File, want)
+	}
+
+	if err := Provision(db, cfg); err != nil {
+		t.Fatalf("Provision failed: %v", err)
+	}
+	script, err := db.GetScriptByName("logger")
+	if err != nil {
+		t.Fatalf("script not provisioned: %v", err)
+	}
+	if script.Content != "log.info('hello');" {
+		t.Errorf("script content = %q", script.Content)
+	}
+}
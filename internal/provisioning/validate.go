@@ -0,0 +1,28 @@
+package provisioning
+
+import (
+	"fmt"
+
+	"github/bromq-dev/bromq/internal/config"
+	"github/bromq-dev/bromq/internal/script"
+)
+
+// ValidateConfig loads the config at path and checks everything provisioning
+// would need without touching a database: the config itself, and that every
+// script file exists and every script compiles. The error lists every problem
+// (see config.ValidationErrors)
+func ValidateConfig(path string) error {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+
+	return cfg.ValidateScripts(func(content string) error {
+		syntaxErrors := script.Validate(content)
+		if len(syntaxErrors) == 0 {
+			return nil
+		}
+		first := syntaxErrors[0]
+		return fmt.Errorf("syntax error at line %d, column %d: %s", first.Line, first.Column, first.Message)
+	})
+}
@@ -0,0 +1,86 @@
+package provisioning
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github/bromq-dev/bromq/internal/config"
+)
+
+func TestValidateConfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "good.js"), []byte("log.info(msg.topic);"), 0644); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.js"), []byte("function ("), 0644); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+
+	writeConfig := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+		return path
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		path := writeConfig("good.yml", `
+users:
+  - username: sensor
+    password: secret
+acl_rules:
+  - username: sensor
+    topic: sensors/#
+    permission: pub
+scripts:
+  - name: logger
+    file: good.js
+    triggers:
+      - type: on_publish
+`)
+		if err := ValidateConfig(path); err != nil {
+			t.Errorf("ValidateConfig() error = %v", err)
+		}
+	})
+
+	t.Run("script problems", func(t *testing.T) {
+		path := writeConfig("bad.yml", `
+scripts:
+  - name: missing
+    file: missing.js
+    triggers:
+      - type: on_publish
+  - name: broken
+    file: broken.js
+    triggers:
+      - type: on_publish
+`)
+		err := ValidateConfig(path)
+		var problems config.ValidationErrors
+		if !errors.As(err, &problems) || len(problems) != 2 {
+			t.Fatalf("ValidateConfig() error = %v, want 2 problems", err)
+		}
+		if !strings.Contains(problems[0].Error(), "script 'missing': failed to read script file") {
+			t.Errorf("problem 1 = %v", problems[0])
+		}
+		if !strings.Contains(problems[1].Error(), "script 'broken': syntax error at line 1") {
+			t.Errorf("problem 2 = %v", problems[1])
+		}
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		path := writeConfig("invalid.yml", `
+acl_rules:
+  - username: ghost
+    topic: ghost/#
+    permission: pub
+`)
+		if err := ValidateConfig(path); err == nil || !strings.Contains(err.Error(), "unknown user") {
+			t.Errorf("ValidateConfig() error = %v, want unknown user", err)
+		}
+	})
+}
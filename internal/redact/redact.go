@@ -0,0 +1,67 @@
+// Package redact masks the payloads of messages on sensitive topics wherever the
+// broker shows or stores a copy of them: live events, recordings, the last value
+// cache and script logs. Delivery to subscribers and retained storage are unchanged
+package redact
+
+import (
+	"strings"
+
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+// Mask replaces a redacted payload
+const Mask = "[REDACTED]"
+
+// Redactor matches topics against the redaction filters
+// A nil Redactor redacts nothing, so callers don't need to check for one
+type Redactor struct {
+	filters []string
+}
+
+// New creates a redactor for the given topic filters, or nil if there are none
+func New(filters []string) *Redactor {
+	if len(filters) == 0 {
+		return nil
+	}
+	return &Redactor{filters: filters}
+}
+
+// Parse creates a redactor from comma-separated topic filters, e.g. "secrets/#,+/credentials"
+func Parse(list string) *Redactor {
+	var filters []string
+	for _, filter := range strings.Split(list, ",") {
+		if filter = strings.TrimSpace(filter); filter != "" {
+			filters = append(filters, filter)
+		}
+	}
+	return New(filters)
+}
+
+// Filters returns the redaction topic filters
+func (r *Redactor) Filters() []string {
+	if r == nil {
+		return nil
+	}
+	return r.filters
+}
+
+// Redacts reports whether payloads published to topic are masked
+func (r *Redactor) Redacts(topic string) bool {
+	if r == nil {
+		return false
+	}
+	for _, filter := range r.filters {
+		if storage.MatchTopic(filter, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// Payload returns payload, or Mask if topic is redacted
+func (r *Redactor) Payload(topic string, payload []byte) []byte {
+	if r.Redacts(topic) {
+		return []byte(Mask)
+	}
+	return payload
+}
@@ -0,0 +1,41 @@
+package redact
+
+import "testing"
+
+func TestRedactor(t *testing.T) {
+	redactor := Parse(" secrets/#, ,+/credentials ")
+
+	tests := []struct {
+		topic string
+		want  bool
+	}{
+		{"secrets", true},
+		{"secrets/db/password", true},
+		{"device-1/credentials", true},
+		{"device-1/credentials/old", false},
+		{"sensors/temp", false},
+	}
+	for _, tt := range tests {
+		if got := redactor.Redacts(tt.topic); got != tt.want {
+			t.Errorf("Redacts(%q) = %v, want %v", tt.topic, got, tt.want)
+		}
+	}
+
+	if got := string(redactor.Payload("secrets/key", []byte("hunter2"))); got != Mask {
+		t.Errorf("Payload() on a redacted topic = %q, want %q", got, Mask)
+	}
+	if got := string(redactor.Payload("sensors/temp", []byte("21.5"))); got != "21.5" {
+		t.Errorf("Payload() on another topic = %q, want the payload", got)
+	}
+}
+
+func TestRedactor_None(t *testing.T) {
+	redactor := Parse("")
+	if redactor != nil {
+		t.Fatalf("Parse(\"\") = %v, want nil", redactor)
+	}
+	// A nil redactor passes payloads through
+	if got := string(redactor.Payload("secrets/key", []byte("hunter2"))); got != "hunter2" {
+		t.Errorf("nil Payload() = %q, want the payload", got)
+	}
+}
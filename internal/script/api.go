@@ -1,6 +1,7 @@
 package script
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
@@ -10,7 +11,10 @@ import (
 
 	"github.com/dop251/goja"
 	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/packets"
 	"gorm.io/datatypes"
+
+	"github/bromq-dev/bromq/internal/storage"
 )
 
 // Global tracking of script-published messages to prevent self-triggering
@@ -102,12 +106,20 @@ type ScriptAPI struct {
 	logs         []ScriptLogEntry
 	publishCount int // Track publishes in this execution
 	maxPublishes int // Rate limit: max publishes per execution
+
+	// Dynamic subscriptions (mqtt.subscribe) and timers (setTimeout); nil registry = not available
+	script        *storage.Script
+	message       *Message
+	subscriptions *subscriptionRegistry
+	timers        *timerRegistry
+	owner         *scriptVM // VM handlers registered in this API run in (see handlerRef)
 }
 
 // ScriptLogEntry represents a log entry from a script
 type ScriptLogEntry struct {
 	Level   string
 	Message string
+	Fields  map[string]interface{} `json:",omitempty"` // Structured fields, e.g. log.info("msg", {deviceId: "d1"})
 }
 
 // NewScriptAPI creates a new script API instance
@@ -141,6 +153,8 @@ func (api *ScriptAPI) setupAPIs() {
 	// Create mqtt object
 	mqttObj := api.vm.NewObject()
 	_ = mqttObj.Set("publish", api.mqttPublish)
+	_ = mqttObj.Set("subscribe", api.mqttSubscribe)
+	_ = mqttObj.Set("unsubscribe", api.mqttUnsubscribe)
 	_ = api.vm.Set("mqtt", mqttObj)
 
 	// Create state object (script-scoped)
@@ -158,6 +172,20 @@ func (api *ScriptAPI) setupAPIs() {
 	_ = globalObj.Set("delete", api.globalDelete)
 	_ = globalObj.Set("keys", api.globalKeys)
 	_ = api.vm.Set("global", globalObj)
+
+	// Timers
+	_ = api.vm.Set("setTimeout", api.setTimeout)
+	_ = api.vm.Set("clearTimeout", api.clearTimeout)
+}
+
+// reset starts a new execution of script in this API's VM: logs and the publish
+// count are per execution, and handlers see the msg they were called with
+func (api *ScriptAPI) reset(script *storage.Script, message *Message) {
+	api.script = script
+	api.message = message
+	api.triggerType = message.Type
+	api.logs = make([]ScriptLogEntry, 0)
+	api.publishCount = 0
 }
 
 // GetLogs returns all collected logs
@@ -168,33 +196,61 @@ func (api *ScriptAPI) GetLogs() []ScriptLogEntry {
 // Log functions
 
 func (api *ScriptAPI) logDebug(call goja.FunctionCall) goja.Value {
-	msg := api.formatLogMessage(call.Arguments)
-	api.logs = append(api.logs, ScriptLogEntry{Level: "debug", Message: msg})
-	slog.Debug(msg, "script", api.scriptName, "trigger", api.triggerType)
+	api.log(slog.LevelDebug, "debug", call.Arguments)
 	return goja.Undefined()
 }
 
 func (api *ScriptAPI) logInfo(call goja.FunctionCall) goja.Value {
-	msg := api.formatLogMessage(call.Arguments)
-	api.logs = append(api.logs, ScriptLogEntry{Level: "info", Message: msg})
-	slog.Info(msg, "script", api.scriptName, "trigger", api.triggerType)
+	api.log(slog.LevelInfo, "info", call.Arguments)
 	return goja.Undefined()
 }
 
 func (api *ScriptAPI) logWarn(call goja.FunctionCall) goja.Value {
-	msg := api.formatLogMessage(call.Arguments)
-	api.logs = append(api.logs, ScriptLogEntry{Level: "warn", Message: msg})
-	slog.Warn(msg, "script", api.scriptName, "trigger", api.triggerType)
+	api.log(slog.LevelWarn, "warn", call.Arguments)
 	return goja.Undefined()
 }
 
 func (api *ScriptAPI) logError(call goja.FunctionCall) goja.Value {
-	msg := api.formatLogMessage(call.Arguments)
-	api.logs = append(api.logs, ScriptLogEntry{Level: "error", Message: msg})
-	slog.Error(msg, "script", api.scriptName, "trigger", api.triggerType)
+	api.log(slog.LevelError, "error", call.Arguments)
 	return goja.Undefined()
 }
 
+// log records a log entry at level. A plain object passed after the message is
+// stored as structured fields instead of being printed: log.info("Reading", {deviceId: "d1"})
+func (api *ScriptAPI) log(level slog.Level, levelName string, args []goja.Value) {
+	args, fields := splitLogFields(args)
+	msg := api.formatLogMessage(args)
+	api.logs = append(api.logs, ScriptLogEntry{Level: levelName, Message: msg, Fields: fields})
+
+	attrs := []any{"script", api.scriptName, "trigger", api.triggerType}
+	if fields != nil {
+		attrs = append(attrs, "fields", fields)
+	}
+	slog.Log(context.Background(), level, msg, attrs...)
+}
+
+// splitLogFields separates a trailing plain object (the fields) from the message arguments
+// Fields go through JSON so they are stored exactly as they will be read back
+func splitLogFields(args []goja.Value) ([]goja.Value, map[string]interface{}) {
+	if len(args) < 2 {
+		return args, nil
+	}
+	obj, ok := args[len(args)-1].(*goja.Object)
+	if !ok || obj.ClassName() != "Object" {
+		return args, nil
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return args, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return args, nil
+	}
+	return args[:len(args)-1], fields
+}
+
 func (api *ScriptAPI) formatLogMessage(args []goja.Value) string {
 	if len(args) == 0 {
 		return ""
@@ -259,6 +315,79 @@ func (api *ScriptAPI) mqttPublish(call goja.FunctionCall) goja.Value {
 	return goja.Undefined()
 }
 
+// mqttSubscribe registers handler for messages published to a topic filter
+// The handler runs as its own execution of this script for each matching publish,
+// in the VM of the execution that subscribed (see handlerRef)
+func (api *ScriptAPI) mqttSubscribe(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 2 {
+		panic(api.vm.NewTypeError("mqtt.subscribe requires 2 arguments (topic, handler)"))
+	}
+	filter := call.Argument(0).String()
+	handler := call.Argument(1)
+	if _, ok := goja.AssertFunction(handler); !ok {
+		panic(api.vm.NewTypeError("mqtt.subscribe handler must be a function"))
+	}
+	ref := api.register(handler)
+
+	// Test runs have no stored script to run the handler as
+	if api.subscriptions == nil || api.script == nil || api.scriptID == 0 {
+		api.logs = append(api.logs, ScriptLogEntry{Level: "warn", Message: "mqtt.subscribe is ignored in test runs: " + filter})
+		return api.vm.ToValue(false)
+	}
+
+	if err := api.subscriptions.subscribe(api.script, filter, ref); err != nil {
+		panic(api.vm.NewTypeError(err.Error()))
+	}
+	return api.vm.ToValue(true)
+}
+
+// mqttUnsubscribe removes a subscription made with mqtt.subscribe, returning whether it existed
+func (api *ScriptAPI) mqttUnsubscribe(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 1 {
+		panic(api.vm.NewTypeError("mqtt.unsubscribe requires 1 argument (topic)"))
+	}
+	if api.subscriptions == nil || api.scriptID == 0 {
+		return api.vm.ToValue(false)
+	}
+	return api.vm.ToValue(api.subscriptions.unsubscribe(api.scriptID, call.Argument(0).String()))
+}
+
+// Timer functions
+
+// setTimeout runs handler once after delay milliseconds, as its own execution of
+// this script in the current VM with the current msg (see handlerRef). Returns an ID for clearTimeout
+func (api *ScriptAPI) setTimeout(call goja.FunctionCall) goja.Value {
+	if len(call.Arguments) < 1 {
+		panic(api.vm.NewTypeError("setTimeout requires at least 1 argument (handler, delay)"))
+	}
+	handler := call.Argument(0)
+	if _, ok := goja.AssertFunction(handler); !ok {
+		panic(api.vm.NewTypeError("setTimeout handler must be a function"))
+	}
+	ref := api.register(handler)
+	delay := time.Duration(call.Argument(1).ToInteger()) * time.Millisecond
+
+	// Test runs have no stored script to run the handler as
+	if api.timers == nil || api.script == nil || api.scriptID == 0 {
+		api.logs = append(api.logs, ScriptLogEntry{Level: "warn", Message: "setTimeout is ignored in test runs"})
+		return api.vm.ToValue(0)
+	}
+
+	id, err := api.timers.schedule(api.script, ref, delay, api.message)
+	if err != nil {
+		panic(api.vm.NewTypeError(err.Error()))
+	}
+	return api.vm.ToValue(id)
+}
+
+// clearTimeout cancels a pending timer set by this script, returning whether it was pending
+func (api *ScriptAPI) clearTimeout(call goja.FunctionCall) goja.Value {
+	if api.timers == nil || api.scriptID == 0 {
+		return api.vm.ToValue(false)
+	}
+	return api.vm.ToValue(api.timers.cancel(api.scriptID, call.Argument(0).ToInteger()))
+}
+
 // State functions (script-scoped)
 
 func (api *ScriptAPI) stateSet(call goja.FunctionCall) goja.Value {
@@ -393,6 +522,27 @@ type Message struct {
 	CleanSession        bool   `json:"cleanSession,omitempty"`
 	Error               string `json:"error,omitempty"`
 	PublishedByScriptID *uint  `json:"-"` // Internal: tracks which script published this message (prevents self-triggering)
+
+	// MQTT 5 user properties from the PUBLISH or CONNECT packet
+	UserProperties map[string]string `json:"userProperties,omitempty"`
+
+	// MQTT 5 payload metadata from the PUBLISH packet
+	ContentType   string `json:"contentType,omitempty"`
+	PayloadFormat byte   `json:"payloadFormat,omitempty"` // 0 = unspecified bytes, 1 = UTF-8
+}
+
+// UserPropertiesMap flattens MQTT 5 user properties into a map for scripts
+// Keys may repeat in MQTT 5; the last value wins
+func UserPropertiesMap(props []packets.UserProperty) map[string]string {
+	if len(props) == 0 {
+		return nil
+	}
+
+	result := make(map[string]string, len(props))
+	for _, prop := range props {
+		result[prop.Key] = prop.Val
+	}
+	return result
 }
 
 // ToJSON converts message to JSON for logging
@@ -411,5 +561,8 @@ func (m *Message) ToMap() map[string]interface{} {
 	result["username"] = m.Username
 	result["qos"] = m.QoS
 	result["retain"] = m.Retain
+	if len(m.UserProperties) > 0 {
+		result["user_properties"] = m.UserProperties
+	}
 	return result
 }
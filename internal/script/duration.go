@@ -2,25 +2,9 @@ package script
 
 import (
 	"fmt"
-	"regexp"
-	"strconv"
 	"time"
 )
 
-// ParseDurationWithDays parses a duration string that supports days (e.g., "30d", "7d", "24h", "1h30m")
-// Supports all standard Go duration units plus "d" for days (24 hours)
-func ParseDurationWithDays(s string) (time.Duration, error) {
-	// Check for days suffix
-	dayRegex := regexp.MustCompile(`^(\d+)d$`)
-	if matches := dayRegex.FindStringSubmatch(s); matches != nil {
-		days, _ := strconv.Atoi(matches[1])
-		return time.Duration(days) * 24 * time.Hour, nil
-	}
-
-	// Fall back to standard time.ParseDuration for other formats
-	return time.ParseDuration(s)
-}
-
 // CalculateCleanupInterval calculates an appropriate cleanup interval based on retention period
 // Strategy: Check every 1/10th of retention period, clamped between 1 hour and 24 hours
 func CalculateCleanupInterval(retention time.Duration) time.Duration {
@@ -3,47 +3,9 @@ package script
 import (
 	"testing"
 	"time"
-)
-
-func TestParseDurationWithDays(t *testing.T) {
-	tests := []struct {
-		name    string
-		input   string
-		want    time.Duration
-		wantErr bool
-	}{
-		// Days
-		{"1 day", "1d", 24 * time.Hour, false},
-		{"7 days", "7d", 7 * 24 * time.Hour, false},
-		{"30 days", "30d", 30 * 24 * time.Hour, false},
-		{"90 days", "90d", 90 * 24 * time.Hour, false},
 
-		// Standard durations
-		{"1 hour", "1h", 1 * time.Hour, false},
-		{"30 minutes", "30m", 30 * time.Minute, false},
-		{"1 hour 30 minutes", "1h30m", 1*time.Hour + 30*time.Minute, false},
-		{"24 hours", "24h", 24 * time.Hour, false},
-
-		// Edge cases
-		{"0 days", "0d", 0, false},
-		{"invalid", "invalid", 0, true},
-		{"negative", "-1d", 0, true},
-		{"empty", "", 0, true},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := ParseDurationWithDays(tt.input)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("ParseDurationWithDays(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
-				return
-			}
-			if !tt.wantErr && got != tt.want {
-				t.Errorf("ParseDurationWithDays(%q) = %v, want %v", tt.input, got, tt.want)
-			}
-		})
-	}
-}
+	"github/bromq-dev/bromq/internal/duration"
+)
 
 func TestCalculateCleanupInterval(t *testing.T) {
 	tests := []struct {
@@ -59,13 +21,13 @@ func TestCalculateCleanupInterval(t *testing.T) {
 		{"1 hour", 1 * time.Hour, 1 * time.Hour},
 
 		// Medium retention - 1/10th
-		{"12 hours", 12 * time.Hour, 72 * time.Minute}, // 1.2h
-		{"24 hours", 24 * time.Hour, 144 * time.Minute}, // 2.4h
+		{"12 hours", 12 * time.Hour, 72 * time.Minute},                // 1.2h
+		{"24 hours", 24 * time.Hour, 144 * time.Minute},               // 2.4h
 		{"7 days", 7 * 24 * time.Hour, 16*time.Hour + 48*time.Minute}, // 16.8h
 
 		// Long retention (> 10 days) - clamp to 24h maximum
-		{"30 days", 30 * 24 * time.Hour, 24 * time.Hour}, // clamped to 24h
-		{"90 days", 90 * 24 * time.Hour, 24 * time.Hour}, // clamped to 24h
+		{"30 days", 30 * 24 * time.Hour, 24 * time.Hour},   // clamped to 24h
+		{"90 days", 90 * 24 * time.Hour, 24 * time.Hour},   // clamped to 24h
 		{"365 days", 365 * 24 * time.Hour, 24 * time.Hour}, // clamped to 24h
 	}
 
@@ -120,7 +82,7 @@ func TestCleanupIntervalExamples(t *testing.T) {
 
 	for _, ex := range examples {
 		t.Run(ex.retention, func(t *testing.T) {
-			retention, _ := ParseDurationWithDays(ex.retention)
+			retention, _ := duration.Parse(ex.retention)
 			interval := CalculateCleanupInterval(retention)
 
 			wantInterval, _ := time.ParseDuration(ex.wantCheck)
@@ -2,19 +2,28 @@ package script
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	mqtt "github.com/mochi-mqtt/server/v2"
 
 	"github/bromq-dev/bromq/internal/badgerstore"
+	"github/bromq-dev/bromq/internal/duration"
+	"github/bromq-dev/bromq/internal/redact"
 	"github/bromq-dev/bromq/internal/storage"
 )
 
+// Config holds script engine settings
+type Config struct {
+	SubscribeTopics string `env:"SCRIPT_SUBSCRIBE_TOPICS" flag:"script-subscribe-topics" default:"#" desc:"Comma-separated topic filters scripts may subscribe within with mqtt.subscribe (# excludes $SYS topics)"`
+}
+
 // Engine manages script execution, state, and lifecycle
 type Engine struct {
 	db              *storage.DB
@@ -22,11 +31,16 @@ type Engine struct {
 	mqttServer      *mqtt.Server
 	state           *StateManagerBadger
 	runtime         *Runtime
-	scriptCache     *ScriptCache  // Cache enabled scripts to avoid DB queries on every event
-	defaultTimeout  time.Duration // Default script execution timeout
-	maxPublishes    int           // Max publishes per script execution
-	logRetention    time.Duration // How long to keep logs (0 = forever)
-	cleanupInterval time.Duration // How often to run cleanup
+	scriptCache     *ScriptCache          // Cache enabled scripts to avoid DB queries on every event
+	subscriptions   *subscriptionRegistry // Topic filters scripts subscribed to with mqtt.subscribe
+	timers          *timerRegistry        // Pending setTimeout handlers
+	metrics         *Metrics              // Optional Prometheus metrics (nil = disabled)
+	stats           *statsTracker         // Per-script execution counters for the API
+	disabled        atomic.Bool           // Global kill switch, leaves each script's enabled flag untouched
+	defaultTimeout  time.Duration         // Default script execution timeout
+	maxPublishes    int                   // Max publishes per script execution
+	logRetention    time.Duration         // How long to keep logs (0 = forever)
+	cleanupInterval time.Duration         // How often to run cleanup
 	cleanupTicker   *time.Ticker
 	stopChan        chan struct{}
 	wg              sync.WaitGroup
@@ -39,6 +53,8 @@ func NewEngine(db *storage.DB, badger *badgerstore.BadgerStore, mqttServer *mqtt
 	state := NewStateManagerBadger(badger)
 	runtime := NewRuntime(db, badger, state, mqttServer)
 	scriptCache := NewScriptCache(db)
+	subscriptions := newSubscriptionRegistry(parseSubscribeTopics(""))
+	runtime.subscriptions = subscriptions
 
 	// Load timeout configuration
 	defaultTimeout := loadTimeoutConfig()
@@ -62,19 +78,24 @@ func NewEngine(db *storage.DB, badger *badgerstore.BadgerStore, mqttServer *mqtt
 		slog.Info("Script log cleanup disabled (logs kept forever)")
 	}
 
-	return &Engine{
+	e := &Engine{
 		db:              db,
 		badger:          badger,
 		mqttServer:      mqttServer,
 		state:           state,
 		runtime:         runtime,
 		scriptCache:     scriptCache,
+		subscriptions:   subscriptions,
+		stats:           newStatsTracker(),
 		defaultTimeout:  defaultTimeout,
 		maxPublishes:    maxPublishes,
 		logRetention:    logRetention,
 		cleanupInterval: cleanupInterval,
 		stopChan:        make(chan struct{}),
 	}
+	e.timers = newTimerRegistry(e.runTimer)
+	runtime.timers = e.timers
+	return e
 }
 
 // loadTimeoutConfig loads the default script execution timeout from environment
@@ -117,7 +138,7 @@ func loadLogRetentionConfig() time.Duration {
 		retentionStr = "1d" // Default: 1 day
 	}
 
-	retention, err := ParseDurationWithDays(retentionStr)
+	retention, err := duration.Parse(retentionStr)
 	if err != nil {
 		slog.Warn("Invalid SCRIPT_LOG_RETENTION, using default",
 			"value", retentionStr,
@@ -194,6 +215,7 @@ func (e *Engine) Shutdown(ctx context.Context) error {
 
 	// Stop accepting new executions
 	close(e.stopChan)
+	e.timers.stop()
 
 	// Wait for in-flight scripts to complete (with timeout from context)
 	done := make(chan struct{})
@@ -228,6 +250,14 @@ func (e *Engine) ExecuteForTrigger(triggerType, topic string, message *Message)
 	default:
 	}
 
+	if e.disabled.Load() {
+		return
+	}
+
+	if triggerType == "on_publish" {
+		e.executeSubscriptions(topic, message)
+	}
+
 	// Get matching scripts from cache (avoids expensive database query on every event)
 	scripts := e.scriptCache.GetScriptsForTrigger(triggerType, topic)
 
@@ -245,13 +275,47 @@ func (e *Engine) ExecuteForTrigger(triggerType, topic string, message *Message)
 		e.wg.Add(1)
 		go func(s storage.Script) {
 			defer e.wg.Done()
-			e.executeScript(&s, message)
+			e.executeScript(&s, nil, message)
 		}(script)
 	}
 }
 
-// executeScript executes a single script
-func (e *Engine) executeScript(script *storage.Script, message *Message) {
+// executeSubscriptions runs the handlers of script subscriptions (mqtt.subscribe) matching topic
+func (e *Engine) executeSubscriptions(topic string, message *Message) {
+	for _, sub := range e.subscriptions.matching(topic) {
+		if !e.track() {
+			return
+		}
+		go func(sub *scriptSubscription) {
+			defer e.wg.Done()
+			e.executeScript(&sub.script, sub.handler, message)
+		}(sub)
+	}
+}
+
+// runTimer runs a fired setTimeout handler, unless the engine is stopping or disabled
+func (e *Engine) runTimer(script storage.Script, handler *handlerRef, message *Message) {
+	if e.disabled.Load() || !e.track() {
+		return
+	}
+	defer e.wg.Done()
+	e.executeScript(&script, handler, message)
+}
+
+// track adds an execution to the shutdown wait group, reporting false once
+// shutdown has begun. Adding under shutdownMux keeps it from racing with Wait
+func (e *Engine) track() bool {
+	e.shutdownMux.Lock()
+	defer e.shutdownMux.Unlock()
+	if e.isShutdown {
+		return false
+	}
+	e.wg.Add(1)
+	return true
+}
+
+// executeScript executes a single script, or the handler it registered if handler is set
+func (e *Engine) executeScript(script *storage.Script, handler *handlerRef, message *Message) {
 	// Prevent self-triggering: if this script published the message, skip execution
 	if message.PublishedByScriptID != nil && *message.PublishedByScriptID == script.ID {
 		slog.Debug("Skipping self-triggered script",
@@ -269,7 +333,8 @@ func (e *Engine) executeScript(script *storage.Script, message *Message) {
 		"topic", message.Topic,
 		"client", message.ClientID)
 
-	result := e.runtime.Execute(ctx, script, message)
+	result := e.runtime.executeHandler(ctx, script, handler, message)
+	e.recordExecution(script, message, result)
 
 	if !result.Success {
 		slog.Error("Script execution failed",
@@ -285,6 +350,75 @@ func (e *Engine) executeScript(script *storage.Script, message *Message) {
 	}
 }
 
+// recordExecution updates the per-script counters and Prometheus metrics
+func (e *Engine) recordExecution(script *storage.Script, message *Message, result *ExecutionResult) {
+	timedOut := errors.Is(result.Error, ErrExecutionTimeout)
+	e.stats.record(script.ID, result, timedOut)
+
+	if e.metrics == nil {
+		return
+	}
+	e.metrics.RecordExecution(script.Name, message.Type, float64(result.ExecutionTimeMs)/1000, result.Success)
+	switch {
+	case timedOut:
+		e.metrics.RecordTimeout(script.Name, message.Type)
+	case !result.Success:
+		e.metrics.RecordFailure(script.Name, message.Type, failureType(result.Error))
+	}
+}
+
+// failureType classifies an execution error for the error_type label
+func failureType(err error) string {
+	switch {
+	case errors.Is(err, ErrCompilation):
+		return "compilation"
+	case errors.Is(err, ErrPanic):
+		return "panic"
+	case errors.Is(err, ErrRuntime):
+		return "runtime"
+	}
+	return "unknown"
+}
+
+// SetSubscribeTopics restricts mqtt.subscribe to the comma-separated topic filters
+func (e *Engine) SetSubscribeTopics(topics string) {
+	e.subscriptions.setAllowed(parseSubscribeTopics(topics))
+}
+
+// SetScriptsDisabled turns the global kill switch on or off
+// While on, no script runs for any trigger; stored enabled flags are unchanged
+func (e *Engine) SetScriptsDisabled(disabled bool) {
+	if e.disabled.Swap(disabled) != disabled {
+		slog.Warn("Script execution kill switch changed", "scripts_disabled", disabled)
+	}
+}
+
+// ScriptsDisabled reports whether the global kill switch is on
+func (e *Engine) ScriptsDisabled() bool {
+	return e.disabled.Load()
+}
+
+// SetRedactor masks the payloads of redacted topics in the message context of
+// script logs. Scripts still see the real payload
+func (e *Engine) SetRedactor(redactor *redact.Redactor) {
+	e.runtime.redactor = redactor
+}
+
+// SetMetrics enables Prometheus metrics for script executions
+func (e *Engine) SetMetrics(metrics *Metrics) {
+	e.metrics = metrics
+}
+
+// Stats returns the execution counters of a script since the server started
+func (e *Engine) Stats(scriptID uint) ScriptStats {
+	return e.stats.get(scriptID)
+}
+
+// ForgetStats drops the execution counters of a deleted script
+func (e *Engine) ForgetStats(scriptID uint) {
+	e.stats.forget(scriptID)
+}
+
 // TestScript tests a script with mock message data (for API testing endpoint)
 func (e *Engine) TestScript(scriptContent string, triggerType string, messageData map[string]interface{}) *ExecutionResult {
 	// Create mock script
@@ -376,6 +510,18 @@ func (e *Engine) cleanupOldLogs() {
 }
 
 // ReloadScripts reloads the script cache (called when scripts change via API)
+// Dynamic subscriptions and pending timers of scripts that were disabled, deleted
+// or updated are dropped
 func (e *Engine) ReloadScripts() error {
-	return e.scriptCache.Reload()
+	if err := e.scriptCache.Reload(); err != nil {
+		return err
+	}
+	scripts := e.scriptCache.Scripts()
+	if dropped := e.subscriptions.prune(scripts); dropped > 0 {
+		slog.Info("Dropped script subscriptions of changed scripts", "subscriptions", dropped)
+	}
+	if cancelled := e.timers.prune(scripts); cancelled > 0 {
+		slog.Info("Cancelled timers of changed scripts", "timers", cancelled)
+	}
+	return nil
 }
@@ -2,6 +2,7 @@ package script
 
 import (
 	"context"
+	"strconv"
 	"testing"
 	"time"
 
@@ -326,3 +327,255 @@ func TestEngineShutdownMultipleTimes(t *testing.T) {
 		t.Errorf("Second shutdown failed: %v", err2)
 	}
 }
+
+func TestEngineKillSwitch(t *testing.T) {
+	db, _, _, mqttServer := setupTestRuntime(t)
+	defer mqttServer.Close()
+
+	engine := NewEngine(db, badgerstore.OpenInMemory(t), mqttServer)
+	engine.Start()
+	defer engine.Shutdown(context.Background())
+
+	script, _ := db.CreateScript("kill-switch-script", "", `
+		state.set("ran", true);
+	`, true, []byte("{}"), []storage.ScriptTrigger{
+		{Type: "on_publish", Topic: "test/#", Priority: 100, Enabled: true},
+	})
+	engine.ReloadScripts()
+
+	message := &Message{Type: "publish", Topic: "test/topic", ClientID: "test-client"}
+
+	engine.SetScriptsDisabled(true)
+	engine.ExecuteForTrigger("on_publish", "test/topic", message)
+	time.Sleep(100 * time.Millisecond)
+
+	if _, ran := engine.GetState().Get(&script.ID, "ran"); ran {
+		t.Error("Expected script NOT to execute while the kill switch is on")
+	}
+	stored, _ := db.GetScript(script.ID)
+	if !stored.Enabled {
+		t.Error("Kill switch should not change the stored enabled flag")
+	}
+
+	engine.SetScriptsDisabled(false)
+	engine.ExecuteForTrigger("on_publish", "test/topic", message)
+	time.Sleep(100 * time.Millisecond)
+
+	if _, ran := engine.GetState().Get(&script.ID, "ran"); !ran {
+		t.Error("Expected script to execute after the kill switch is turned off")
+	}
+}
+
+func TestEngineDynamicSubscription(t *testing.T) {
+	db, _, _, mqttServer := setupTestRuntime(t)
+	defer mqttServer.Close()
+
+	engine := NewEngine(db, badgerstore.OpenInMemory(t), mqttServer)
+	engine.SetSubscribeTopics("dyn/#, bound/#")
+	engine.Start()
+	defer engine.Shutdown(context.Background())
+
+	// Handlers keep their closures and the script's helpers, and bound functions work
+	script, _ := db.CreateScript("dynamic-subscriber", "", `
+		const subscriber = msg.clientId;
+		function record(key, m) {
+			state.set(key, subscriber + ":" + m.topic + "=" + m.payload);
+		}
+		mqtt.subscribe("dyn/#", function (m) { record("received", m); });
+		mqtt.subscribe("bound/#", record.bind(null, "bound"));
+		state.set("subscribed", (state.get("subscribed") || 0) + 1);
+	`, true, []byte("{}"), []storage.ScriptTrigger{
+		{Type: "on_connect", Enabled: true},
+	})
+	denied, _ := db.CreateScript("other-subscriber", "", `
+		mqtt.subscribe("other/#", function (m) {});
+		state.set("subscribed", true);
+	`, true, []byte("{}"), []storage.ScriptTrigger{
+		{Type: "on_connect", Enabled: true},
+	})
+	engine.ReloadScripts()
+
+	engine.ExecuteForTrigger("on_connect", "", &Message{Type: "connect", ClientID: "test-client"})
+	time.Sleep(100 * time.Millisecond)
+
+	if _, ok := engine.GetState().Get(&script.ID, "subscribed"); !ok {
+		t.Fatal("Expected script to subscribe")
+	}
+	if _, ok := engine.GetState().Get(&denied.ID, "subscribed"); ok {
+		t.Error("Expected subscribing outside SCRIPT_SUBSCRIBE_TOPICS to fail")
+	}
+
+	publish := func(topic, payload string) {
+		engine.ExecuteForTrigger("on_publish", topic, &Message{Type: "publish", Topic: topic, Payload: payload, ClientID: "test-client"})
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	publish("other/topic", "ignored")
+	if _, ok := engine.GetState().Get(&script.ID, "received"); ok {
+		t.Error("Expected handler NOT to run for a non-matching topic")
+	}
+
+	publish("dyn/sensor", "42")
+	if got, _ := engine.GetState().Get(&script.ID, "received"); got != "test-client:dyn/sensor=42" {
+		t.Errorf("received = %v, want test-client:dyn/sensor=42", got)
+	}
+	publish("bound/sensor", "7")
+	if got, _ := engine.GetState().Get(&script.ID, "bound"); got != "test-client:bound/sensor=7" {
+		t.Errorf("bound = %v, want test-client:bound/sensor=7", got)
+	}
+
+	// Running the handlers doesn't repeat the subscribing run
+	if got, _ := engine.GetState().Get(&script.ID, "subscribed"); got != float64(1) {
+		t.Errorf("subscribed = %v, want 1", got)
+	}
+
+	// Disabling the script drops its subscriptions
+	_ = db.UpdateScriptEnabled(script.ID, false)
+	engine.ReloadScripts()
+
+	publish("dyn/sensor", "43")
+	if got, _ := engine.GetState().Get(&script.ID, "received"); got != "test-client:dyn/sensor=42" {
+		t.Errorf("received = %v after disabling, want handler not to run", got)
+	}
+}
+
+func TestEngineStateGuardedSubscription(t *testing.T) {
+	db, _, _, mqttServer := setupTestRuntime(t)
+	defer mqttServer.Close()
+
+	engine := NewEngine(db, badgerstore.OpenInMemory(t), mqttServer)
+	engine.SetSubscribeTopics("guarded/#")
+	engine.Start()
+	defer engine.Shutdown(context.Background())
+
+	// The subscribing path is not taken again once "subscribed" is set
+	script, _ := db.CreateScript("guarded-subscriber", "", `
+		if (!state.get("subscribed")) {
+			mqtt.subscribe("guarded/#", function (m) {
+				state.set("count", (state.get("count") || 0) + 1);
+			});
+			state.set("subscribed", true);
+		}
+	`, true, []byte("{}"), []storage.ScriptTrigger{
+		{Type: "on_connect", Enabled: true},
+	})
+	engine.ReloadScripts()
+
+	engine.ExecuteForTrigger("on_connect", "", &Message{Type: "connect", ClientID: "test-client"})
+	time.Sleep(100 * time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		engine.ExecuteForTrigger("on_publish", "guarded/a", &Message{Type: "publish", Topic: "guarded/a", ClientID: "test-client"})
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if got, _ := engine.GetState().Get(&script.ID, "count"); got != float64(3) {
+		t.Errorf("count = %v, want 3", got)
+	}
+	if failed := engine.Stats(script.ID).Failures; failed != 0 {
+		t.Errorf("failed executions = %d, want 0", failed)
+	}
+}
+
+func TestEngineTimerReschedules(t *testing.T) {
+	db, _, _, mqttServer := setupTestRuntime(t)
+	defer mqttServer.Close()
+
+	engine := NewEngine(db, badgerstore.OpenInMemory(t), mqttServer)
+	engine.Start()
+	defer engine.Shutdown(context.Background())
+
+	// A heartbeat that sets the next timer from its own handler
+	const ticks = 50
+	script, _ := db.CreateScript("heartbeat", "", `
+		let n = 0;
+		function tick() {
+			n++;
+			state.set("ticks", n);
+			if (n < `+strconv.Itoa(ticks)+`) {
+				setTimeout(tick, 1);
+			}
+		}
+		setTimeout(tick, 1);
+	`, true, []byte("{}"), []storage.ScriptTrigger{
+		{Type: "on_connect", Enabled: true},
+	})
+	engine.ReloadScripts()
+
+	engine.ExecuteForTrigger("on_connect", "", &Message{Type: "connect", ClientID: "test-client"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if got, _ := engine.GetState().Get(&script.ID, "ticks"); got == float64(ticks) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, _ := engine.GetState().Get(&script.ID, "ticks"); got != float64(ticks) {
+		t.Fatalf("ticks = %v, want %d", got, ticks)
+	}
+	if pending := engine.timers.pending(script.ID); pending != 0 {
+		t.Errorf("pending timers = %d, want 0", pending)
+	}
+}
+
+func TestEngineTimers(t *testing.T) {
+	db, _, _, mqttServer := setupTestRuntime(t)
+	defer mqttServer.Close()
+
+	engine := NewEngine(db, badgerstore.OpenInMemory(t), mqttServer)
+	engine.Start()
+	defer engine.Shutdown(context.Background())
+
+	script, _ := db.CreateScript("timer-script", "", `
+		// Handlers see the variables of the execution that set them
+		const topic = msg.topic;
+		setTimeout(function () {
+			state.set("fired:" + topic, Date.now());
+		}, 200);
+		if (msg.topic === "cancel/me") {
+			const id = setTimeout(function () { state.set("cancelled_fired", true); }, 50);
+			clearTimeout(id);
+		}
+	`, true, []byte("{}"), []storage.ScriptTrigger{
+		{Type: "on_publish", Topic: "#", Enabled: true},
+	})
+	engine.ReloadScripts()
+
+	publish := func(topic string) {
+		engine.ExecuteForTrigger("on_publish", topic, &Message{Type: "publish", Topic: topic, ClientID: "test-client"})
+	}
+
+	publish("timer/a")
+	time.Sleep(100 * time.Millisecond)
+	if _, fired := engine.GetState().Get(&script.ID, "fired:timer/a"); fired {
+		t.Fatal("Expected timer NOT to fire before its delay")
+	}
+	time.Sleep(250 * time.Millisecond)
+	if _, fired := engine.GetState().Get(&script.ID, "fired:timer/a"); !fired {
+		t.Error("Expected timer to fire after its delay")
+	}
+
+	publish("cancel/me")
+	time.Sleep(350 * time.Millisecond)
+	if _, fired := engine.GetState().Get(&script.ID, "cancelled_fired"); fired {
+		t.Error("Expected clearTimeout to cancel the timer")
+	}
+
+	// Disabling the script cancels its pending timers
+	publish("timer/b")
+	time.Sleep(50 * time.Millisecond)
+	if pending := engine.timers.pending(script.ID); pending != 1 {
+		t.Fatalf("pending timers = %d, want 1", pending)
+	}
+	_ = db.UpdateScriptEnabled(script.ID, false)
+	engine.ReloadScripts()
+
+	time.Sleep(300 * time.Millisecond)
+	if _, fired := engine.GetState().Get(&script.ID, "fired:timer/b"); fired {
+		t.Error("Expected timer of a disabled script NOT to fire")
+	}
+	if pending := engine.timers.pending(script.ID); pending != 0 {
+		t.Errorf("pending timers after disable = %d, want 0", pending)
+	}
+}
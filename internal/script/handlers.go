@@ -0,0 +1,96 @@
+package script
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+// scriptVM is a goja runtime kept alive by the handlers a script registered in it
+// with mqtt.subscribe or setTimeout. A runtime isn't goroutine-safe, so the script's
+// run and every later handler call in it are serialized by mu
+type scriptVM struct {
+	mu  sync.Mutex
+	vm  *goja.Runtime
+	api *ScriptAPI
+
+	interruptMu sync.Mutex
+	active      *ExecutionResult // Execution currently running in vm (nil = idle)
+}
+
+// handlerRef is a handler function passed to mqtt.subscribe or setTimeout, with
+// the VM it was created in. Calling it there keeps its closures and the script's
+// helpers without running the script again
+type handlerRef struct {
+	vm *scriptVM
+	fn goja.Callable
+}
+
+// register returns the reference a handler passed to mqtt.subscribe or setTimeout runs through
+func (api *ScriptAPI) register(handler goja.Value) *handlerRef {
+	fn, _ := goja.AssertFunction(handler)
+	return &handlerRef{vm: api.owner, fn: fn}
+}
+
+// begin marks execution as the one running in the VM and clears an interrupt
+// left over from an earlier execution. s.mu must be held
+func (s *scriptVM) begin(execution *ExecutionResult) {
+	s.interruptMu.Lock()
+	defer s.interruptMu.Unlock()
+	s.active = execution
+	s.vm.ClearInterrupt()
+}
+
+// end marks the VM idle. s.mu must be held
+func (s *scriptVM) end() {
+	s.interruptMu.Lock()
+	defer s.interruptMu.Unlock()
+	s.active = nil
+}
+
+// interrupt stops execution if it is still the one running in the VM, so a
+// late timeout never hits a later execution
+func (s *scriptVM) interrupt(execution *ExecutionResult) {
+	s.interruptMu.Lock()
+	defer s.interruptMu.Unlock()
+	if s.active == execution {
+		s.vm.Interrupt("execution timeout")
+	}
+}
+
+// call runs the handler with message as msg. The VM must be locked
+func (h *handlerRef) call(message *Message) error {
+	if _, err := h.fn(goja.Undefined(), setMsg(h.vm.vm, message)); err != nil {
+		return fmt.Errorf("%w: %w", ErrRuntime, err)
+	}
+	return nil
+}
+
+// setMsg sets the msg global to message and returns it
+func setMsg(vm *goja.Runtime, message *Message) goja.Value {
+	// Always expose an object so scripts can read msg.userProperties.key safely
+	userProperties := message.UserProperties
+	if userProperties == nil {
+		userProperties = map[string]string{}
+	}
+
+	// Convert Message to map with JSON field names for JavaScript access
+	msg := vm.ToValue(map[string]interface{}{
+		"type":           message.Type,
+		"topic":          message.Topic,
+		"payload":        message.Payload,
+		"clientId":       message.ClientID,
+		"username":       message.Username,
+		"qos":            message.QoS,
+		"retain":         message.Retain,
+		"cleanSession":   message.CleanSession,
+		"error":          message.Error,
+		"userProperties": userProperties,
+		"contentType":    message.ContentType,
+		"payloadFormat":  message.PayloadFormat,
+		"json":           msgJSON(vm, message),
+	})
+	_ = vm.Set("msg", msg)
+	return msg
+}
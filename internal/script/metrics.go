@@ -1,6 +1,8 @@
 package script
 
 import (
+	"sync"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -12,12 +14,32 @@ type Metrics struct {
 	executionFailures *prometheus.CounterVec
 	executionTimeouts *prometheus.CounterVec
 	scriptsActive     prometheus.Gauge
+
+	// Cardinality guard: only the first maxScriptLabels script names get
+	// their own series, the rest are reported as OverflowScriptLabel
+	labelsMu        sync.Mutex
+	labels          map[string]struct{}
+	maxScriptLabels int
 }
 
+// DefaultMaxScriptLabels caps how many distinct script names are used as label values
+const DefaultMaxScriptLabels = 200
+
+// OverflowScriptLabel is the script_name label used once the label cap is reached
+const OverflowScriptLabel = "_other"
+
 // NewMetrics creates a new script metrics collector
 func NewMetrics() *Metrics {
+	return NewMetricsWithRegistry(prometheus.DefaultRegisterer)
+}
+
+// NewMetricsWithRegistry creates a script metrics collector registered with reg
+func NewMetricsWithRegistry(reg prometheus.Registerer) *Metrics {
+	factory := promauto.With(reg)
 	return &Metrics{
-		executionDuration: promauto.NewHistogramVec(
+		labels:          make(map[string]struct{}),
+		maxScriptLabels: DefaultMaxScriptLabels,
+		executionDuration: factory.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:    "script_execution_duration_seconds",
 				Help:    "Histogram of script execution durations",
@@ -25,28 +47,28 @@ func NewMetrics() *Metrics {
 			},
 			[]string{"script_name", "trigger_type"},
 		),
-		executionTotal: promauto.NewCounterVec(
+		executionTotal: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "script_executions_total",
 				Help: "Total number of script executions",
 			},
 			[]string{"script_name", "trigger_type", "result"},
 		),
-		executionFailures: promauto.NewCounterVec(
+		executionFailures: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "script_execution_failures_total",
 				Help: "Total number of script execution failures",
 			},
 			[]string{"script_name", "trigger_type", "error_type"},
 		),
-		executionTimeouts: promauto.NewCounterVec(
+		executionTimeouts: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "script_execution_timeouts_total",
 				Help: "Total number of script execution timeouts",
 			},
 			[]string{"script_name", "trigger_type"},
 		),
-		scriptsActive: promauto.NewGauge(
+		scriptsActive: factory.NewGauge(
 			prometheus.GaugeOpts{
 				Name: "scripts_active_total",
 				Help: "Number of active (enabled) scripts",
@@ -55,8 +77,32 @@ func NewMetrics() *Metrics {
 	}
 }
 
+// SetMaxScriptLabels changes the number of distinct script names tracked
+// before further scripts are folded into OverflowScriptLabel
+func (m *Metrics) SetMaxScriptLabels(n int) {
+	m.labelsMu.Lock()
+	defer m.labelsMu.Unlock()
+	m.maxScriptLabels = n
+}
+
+// scriptLabel returns the label value to use for scriptName
+func (m *Metrics) scriptLabel(scriptName string) string {
+	m.labelsMu.Lock()
+	defer m.labelsMu.Unlock()
+
+	if _, ok := m.labels[scriptName]; ok {
+		return scriptName
+	}
+	if len(m.labels) >= m.maxScriptLabels {
+		return OverflowScriptLabel
+	}
+	m.labels[scriptName] = struct{}{}
+	return scriptName
+}
+
 // RecordExecution records a script execution with duration and result
 func (m *Metrics) RecordExecution(scriptName, triggerType string, durationSeconds float64, success bool) {
+	scriptName = m.scriptLabel(scriptName)
 	m.executionDuration.WithLabelValues(scriptName, triggerType).Observe(durationSeconds)
 
 	result := "success"
@@ -68,11 +114,13 @@ func (m *Metrics) RecordExecution(scriptName, triggerType string, durationSecond
 
 // RecordFailure records a script execution failure
 func (m *Metrics) RecordFailure(scriptName, triggerType, errorType string) {
+	scriptName = m.scriptLabel(scriptName)
 	m.executionFailures.WithLabelValues(scriptName, triggerType, errorType).Inc()
 }
 
 // RecordTimeout records a script execution timeout
 func (m *Metrics) RecordTimeout(scriptName, triggerType string) {
+	scriptName = m.scriptLabel(scriptName)
 	m.executionTimeouts.WithLabelValues(scriptName, triggerType).Inc()
 }
 
@@ -0,0 +1,101 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github/bromq-dev/bromq/internal/badgerstore"
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+func TestEngineExecutionMetrics(t *testing.T) {
+	db, _, _, mqttServer := setupTestRuntime(t)
+	defer mqttServer.Close()
+
+	engine := NewEngine(db, badgerstore.OpenInMemory(t), mqttServer)
+	metrics := NewMetricsWithRegistry(prometheus.NewRegistry())
+	engine.SetMetrics(metrics)
+
+	ok, err := db.CreateScript("ok-script", "", `log.info("hi");`, true, []byte("{}"), []storage.ScriptTrigger{
+		{Type: "on_publish", Topic: "test/#", Priority: 100, Enabled: true},
+	})
+	if err != nil {
+		t.Fatalf("CreateScript() error = %v", err)
+	}
+	failing, err := db.CreateScript("failing-script", "", `throw new Error("boom");`, true, []byte("{}"), []storage.ScriptTrigger{
+		{Type: "on_publish", Topic: "test/#", Priority: 100, Enabled: true},
+	})
+	if err != nil {
+		t.Fatalf("CreateScript() error = %v", err)
+	}
+
+	message := &Message{Type: "on_publish", Topic: "test/a"}
+	engine.executeScript(ok, nil, message)
+	engine.executeScript(ok, nil, message)
+	engine.executeScript(failing, nil, message)
+
+	if got := testutil.ToFloat64(metrics.executionTotal.WithLabelValues("ok-script", "on_publish", "success")); got != 2 {
+		t.Errorf("successful executions = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(metrics.executionTotal.WithLabelValues("failing-script", "on_publish", "failure")); got != 1 {
+		t.Errorf("failed executions = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.executionFailures.WithLabelValues("failing-script", "on_publish", "runtime")); got != 1 {
+		t.Errorf("runtime failures = %v, want 1", got)
+	}
+
+	stats := engine.Stats(ok.ID)
+	if stats.Executions != 2 || stats.Failures != 0 || stats.LastExecutedAt == nil {
+		t.Errorf("Stats(ok) = %+v, want 2 executions without failures", stats)
+	}
+	stats = engine.Stats(failing.ID)
+	if stats.Executions != 1 || stats.Failures != 1 || stats.LastError == "" {
+		t.Errorf("Stats(failing) = %+v, want 1 failed execution with last error", stats)
+	}
+
+	engine.ForgetStats(ok.ID)
+	if stats := engine.Stats(ok.ID); stats.Executions != 0 {
+		t.Errorf("Stats() after ForgetStats = %+v, want zero", stats)
+	}
+}
+
+func TestMetrics_ScriptLabelCap(t *testing.T) {
+	metrics := NewMetricsWithRegistry(prometheus.NewRegistry())
+	metrics.SetMaxScriptLabels(2)
+
+	for _, name := range []string{"a", "b", "c", "d", "a"} {
+		metrics.RecordExecution(name, "on_publish", 0.01, true)
+	}
+
+	if got := testutil.ToFloat64(metrics.executionTotal.WithLabelValues("a", "on_publish", "success")); got != 2 {
+		t.Errorf("executions for a = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(metrics.executionTotal.WithLabelValues(OverflowScriptLabel, "on_publish", "success")); got != 2 {
+		t.Errorf("executions for %s = %v, want 2", OverflowScriptLabel, got)
+	}
+	if got := testutil.CollectAndCount(metrics.executionTotal); got != 3 {
+		t.Errorf("series = %d, want 3", got)
+	}
+}
+
+func TestFailureType(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("%w: %w", ErrCompilation, errors.New("SyntaxError")), "compilation"},
+		{fmt.Errorf("%w: %w", ErrRuntime, errors.New("ReferenceError")), "runtime"},
+		{fmt.Errorf("%w: %v", ErrPanic, "nil map"), "panic"},
+		{errors.New("runtime error: index out of range"), "unknown"},
+		{nil, "unknown"},
+	}
+	for _, tt := range tests {
+		if got := failureType(tt.err); got != tt.want {
+			t.Errorf("failureType(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -2,17 +2,32 @@ package script
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"mime"
+	"strings"
 	"time"
 
 	"github.com/dop251/goja"
 	mqtt "github.com/mochi-mqtt/server/v2"
 
 	"github/bromq-dev/bromq/internal/badgerstore"
+	"github/bromq-dev/bromq/internal/redact"
 	"github/bromq-dev/bromq/internal/storage"
 )
 
+// ErrExecutionTimeout is wrapped by the error of a script that exceeded its timeout
+var ErrExecutionTimeout = errors.New("execution timeout")
+
+// Errors wrapped by the error of a failed execution, telling what went wrong
+var (
+	ErrCompilation = errors.New("compilation error") // The script doesn't compile
+	ErrRuntime     = errors.New("runtime error")     // The script threw or was interrupted
+	ErrPanic       = errors.New("script panic")      // The engine panicked running the script
+)
+
 // ExecutionResult contains the result of script execution
 type ExecutionResult struct {
 	Success         bool
@@ -29,6 +44,9 @@ type Runtime struct {
 	mqttServer     *mqtt.Server
 	defaultTimeout time.Duration
 	maxPublishes   int
+	subscriptions  *subscriptionRegistry // Dynamic subscriptions (nil = mqtt.subscribe unavailable)
+	timers         *timerRegistry        // Pending timers (nil = setTimeout unavailable)
+	redactor       *redact.Redactor      // Masks payloads of redacted topics in execution logs
 }
 
 // NewRuntime creates a new runtime
@@ -55,6 +73,16 @@ func (r *Runtime) SetMaxPublishes(maxPublishes int) {
 
 // Execute runs a script with the given message context
 func (r *Runtime) Execute(ctx context.Context, script *storage.Script, message *Message) *ExecutionResult {
+	return r.execute(ctx, script, message, nil)
+}
+
+// executeHandler runs a handler the script registered with mqtt.subscribe or
+// setTimeout, with the given message context
+func (r *Runtime) executeHandler(ctx context.Context, script *storage.Script, handler *handlerRef, message *Message) *ExecutionResult {
+	return r.execute(ctx, script, message, handler)
+}
+
+func (r *Runtime) execute(ctx context.Context, script *storage.Script, message *Message, handler *handlerRef) *ExecutionResult {
 	startTime := time.Now()
 
 	result := &ExecutionResult{
@@ -72,15 +100,22 @@ func (r *Runtime) Execute(ctx context.Context, script *storage.Script, message *
 	execCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
+	// Handlers run in the VM that registered them; the script's own run gets a new one
+	var sv *scriptVM
+	if handler != nil {
+		sv = handler.vm
+	} else {
+		sv = r.newVM(script, message)
+	}
+
 	// Execute in goroutine to handle timeout
 	done := make(chan bool)
 	var execErr error
-	var vm *goja.Runtime // Store VM reference for interrupt
 
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				execErr = fmt.Errorf("script panic: %v", r)
+				execErr = fmt.Errorf("%w: %v", ErrPanic, r)
 				slog.Error("Script panic",
 					"script", script.Name,
 					"error", execErr,
@@ -89,38 +124,26 @@ func (r *Runtime) Execute(ctx context.Context, script *storage.Script, message *
 			done <- true
 		}()
 
-		// Create new Goja VM for this execution
-		vm = goja.New()
-
-		// Set up APIs
-		api := NewScriptAPI(vm, script.ID, script.Name, message.Type, r.state, r.mqttServer, r.maxPublishes)
-
-		// Convert Message to map with JSON field names for JavaScript access
-		msgMap := map[string]interface{}{
-			"type":         message.Type,
-			"topic":        message.Topic,
-			"payload":      message.Payload,
-			"clientId":     message.ClientID,
-			"username":     message.Username,
-			"qos":          message.QoS,
-			"retain":       message.Retain,
-			"cleanSession": message.CleanSession,
-			"error":        message.Error,
-		}
-
-		// Set msg object in scope
-		_ = vm.Set("msg", msgMap)
+		sv.mu.Lock()
+		defer sv.mu.Unlock()
 
-		// Compile and run script
-		program, err := goja.Compile(script.Name, script.Content, false)
-		if err != nil {
-			execErr = fmt.Errorf("compilation error: %w", err)
+		// Timed out while an earlier execution held the VM
+		if execCtx.Err() != nil {
+			execErr = fmt.Errorf("%w waiting for the script's VM", ErrExecutionTimeout)
 			return
 		}
+		sv.begin(result)
+		defer sv.end()
+
+		api := sv.api
+		api.reset(script, message)
 
-		_, err = vm.RunProgram(program)
-		if err != nil {
-			execErr = fmt.Errorf("runtime error: %w", err)
+		if handler != nil {
+			execErr = handler.call(message)
+		} else {
+			execErr = r.run(sv, script, message)
+		}
+		if execErr != nil {
 			return
 		}
 
@@ -143,9 +166,7 @@ func (r *Runtime) Execute(ctx context.Context, script *storage.Script, message *
 
 	case <-execCtx.Done():
 		// Timeout - interrupt the VM to stop execution
-		if vm != nil {
-			vm.Interrupt("execution timeout")
-		}
+		sv.interrupt(result)
 
 		// Wait for goroutine to finish after interrupt (with a safety timeout)
 		select {
@@ -159,7 +180,7 @@ func (r *Runtime) Execute(ctx context.Context, script *storage.Script, message *
 		}
 
 		result.ExecutionTimeMs = int(time.Since(startTime).Milliseconds())
-		result.Error = fmt.Errorf("execution timeout after %v", timeout)
+		result.Error = fmt.Errorf("%w after %v", ErrExecutionTimeout, timeout)
 		result.Success = false
 
 		slog.Warn("Script execution timeout",
@@ -174,10 +195,40 @@ func (r *Runtime) Execute(ctx context.Context, script *storage.Script, message *
 	return result
 }
 
+// newVM creates the VM for a run of script. It lives on while handlers the run
+// registers with mqtt.subscribe or setTimeout are pending
+func (r *Runtime) newVM(script *storage.Script, message *Message) *scriptVM {
+	vm := goja.New()
+	api := NewScriptAPI(vm, script.ID, script.Name, message.Type, r.state, r.mqttServer, r.maxPublishes)
+	api.subscriptions = r.subscriptions
+	api.timers = r.timers
+
+	sv := &scriptVM{vm: vm, api: api}
+	api.owner = sv
+	return sv
+}
+
+// run compiles and runs script in sv. sv must be locked
+func (r *Runtime) run(sv *scriptVM, script *storage.Script, message *Message) error {
+	setMsg(sv.vm, message)
+
+	program, err := compileScript(script.Name, script.Content)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCompilation, err)
+	}
+	if _, err := sv.vm.RunProgram(program); err != nil {
+		return fmt.Errorf("%w: %w", ErrRuntime, err)
+	}
+	return nil
+}
+
 // logExecution logs the script execution to BadgerDB
 func (r *Runtime) logExecution(scriptID uint, message *Message, result *ExecutionResult) {
 	// Create context with message details
 	context := message.ToMap()
+	if r.redactor.Redacts(message.Topic) {
+		context["payload"] = redact.Mask
+	}
 
 	// Only auto-log errors/failures (reduces noise for high-frequency scripts)
 	if !result.Success {
@@ -201,15 +252,45 @@ func (r *Runtime) logExecution(scriptID uint, message *Message, result *Executio
 
 	// Always log user messages from the script (log.info, log.warn, etc.)
 	for _, logEntry := range result.Logs {
-		if err := r.badger.SaveScriptLog(
+		if err := r.badger.SaveScriptLogWithFields(
 			scriptID,
 			message.Type,
 			logEntry.Level,
 			logEntry.Message,
 			context,
+			logEntry.Fields,
 			0, // User logs don't have execution time
 		); err != nil {
 			slog.Error("Failed to create script log", "error", err)
 		}
 	}
 }
+
+// msgJSON returns the msg.json() helper, which parses the payload when the
+// publisher declared a JSON content type and throws otherwise
+func msgJSON(vm *goja.Runtime, message *Message) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		if !isJSONContentType(message.ContentType) {
+			contentType := message.ContentType
+			if contentType == "" {
+				contentType = "none"
+			}
+			panic(vm.NewTypeError(fmt.Sprintf("msg.json() requires a JSON content type (got %s)", contentType)))
+		}
+
+		var value interface{}
+		if err := json.Unmarshal([]byte(message.Payload), &value); err != nil {
+			panic(vm.NewTypeError(fmt.Sprintf("msg.json() failed to parse payload: %v", err)))
+		}
+		return vm.ToValue(value)
+	}
+}
+
+// isJSONContentType reports whether contentType is application/json or a +json type
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -249,6 +249,91 @@ func TestRuntimeExecuteWithEventData(t *testing.T) {
 	}
 }
 
+func TestRuntimeMsgJSON(t *testing.T) {
+	_, _, runtime, mqttServer := setupTestRuntime(t)
+	defer mqttServer.Close()
+
+	script := &storage.Script{
+		ID:   1,
+		Name: "json-test",
+		Content: `
+			if (msg.contentType !== "application/json") throw new Error("Wrong contentType");
+			if (msg.payloadFormat !== 1) throw new Error("Wrong payloadFormat");
+			const data = msg.json();
+			if (data.temp !== 21.5) throw new Error("Wrong temp: " + data.temp);
+			if (data.tags[1] !== "b") throw new Error("Wrong tags");
+		`,
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		payload     string
+		wantSuccess bool
+	}{
+		{
+			name:        "json content type parses",
+			contentType: "application/json",
+			payload:     `{"temp": 21.5, "tags": ["a", "b"]}`,
+			wantSuccess: true,
+		},
+		{
+			name:        "non-json content type throws",
+			contentType: "text/plain",
+			payload:     `{"temp": 21.5, "tags": ["a", "b"]}`,
+			wantSuccess: false,
+		},
+		{
+			name:        "invalid json payload throws",
+			contentType: "application/json",
+			payload:     `not json`,
+			wantSuccess: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := &Message{
+				Type:          "publish",
+				Topic:         "test/topic",
+				Payload:       tt.payload,
+				ContentType:   tt.contentType,
+				PayloadFormat: 1,
+			}
+
+			// Skip the contentType assertion for the non-json case so msg.json() is what throws
+			s := *script
+			if tt.contentType != "application/json" {
+				s.Content = "msg.json();"
+			}
+
+			result := runtime.Execute(context.Background(), &s, message)
+			if result.Success != tt.wantSuccess {
+				t.Errorf("Execute() success = %v, want %v (error: %v)", result.Success, tt.wantSuccess, result.Error)
+			}
+		})
+	}
+}
+
+func TestIsJSONContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"application/json", true},
+		{"application/json; charset=utf-8", true},
+		{"application/vnd.api+json", true},
+		{"text/plain", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isJSONContentType(tt.contentType); got != tt.want {
+			t.Errorf("isJSONContentType(%q) = %v, want %v", tt.contentType, got, tt.want)
+		}
+	}
+}
+
 func TestRuntimeLogLevels(t *testing.T) {
 	_, _, runtime, mqttServer := setupTestRuntime(t)
 	defer mqttServer.Close()
@@ -291,6 +376,58 @@ func TestRuntimeLogLevels(t *testing.T) {
 	}
 }
 
+func TestRuntimeLogFields(t *testing.T) {
+	_, badger, runtime, mqttServer := setupTestRuntime(t)
+	defer mqttServer.Close()
+
+	script := &storage.Script{
+		ID:   1,
+		Name: "log-fields",
+		Content: `
+			log.info("Reading", msg.payload, {deviceId: "sensor-1", value: 42});
+			log.warn("Reading", {deviceId: "sensor-2"});
+			log.info({deviceId: "not-fields"});
+		`,
+	}
+	message := &Message{Type: "publish", Topic: "test/topic", Payload: "21.5", ClientID: "test-client"}
+
+	result := runtime.Execute(context.Background(), script, message)
+	if !result.Success {
+		t.Fatalf("Expected success, got error: %v", result.Error)
+	}
+
+	first := result.Logs[0]
+	if first.Message != "Reading 21.5" || first.Fields["deviceId"] != "sensor-1" || first.Fields["value"] != float64(42) {
+		t.Errorf("first log = %q %v, want message 'Reading 21.5' with deviceId and value fields", first.Message, first.Fields)
+	}
+	// A lone object is the message, not fields
+	if last := result.Logs[2]; last.Fields != nil {
+		t.Errorf("lone object logged as fields: %v", last.Fields)
+	}
+
+	logs, total, err := badger.ListScriptLogsFiltered(script.ID, 1, 10, badgerstore.ScriptLogFilter{
+		Fields: map[string]string{"deviceId": "sensor-1"},
+	})
+	if err != nil {
+		t.Fatalf("ListScriptLogsFiltered() error = %v", err)
+	}
+	if total != 1 || logs[0].Message != "Reading 21.5" {
+		t.Fatalf("logs for deviceId=sensor-1 = %v (total %d), want the first log only", logs, total)
+	}
+	if logs[0].Fields["value"] != float64(42) {
+		t.Errorf("stored fields = %v, want value 42", logs[0].Fields)
+	}
+
+	// Numbers match their text form
+	_, total, _ = badger.ListScriptLogsFiltered(script.ID, 1, 10, badgerstore.ScriptLogFilter{
+		Level:  "info",
+		Fields: map[string]string{"value": "42"},
+	})
+	if total != 1 {
+		t.Errorf("logs for value=42 = %d, want 1", total)
+	}
+}
+
 func TestRuntimeCompilationError(t *testing.T) {
 	_, _, runtime, mqttServer := setupTestRuntime(t)
 	defer mqttServer.Close()
@@ -12,6 +12,8 @@ import (
 type ScriptCache struct {
 	db      *storage.DB
 	scripts map[string][]storage.Script // Map: triggerType -> scripts
+	indexes map[string]*topicIndex      // Map: triggerType -> topic filters of those scripts' triggers
+	loaded  []storage.Script            // Every enabled script, with or without triggers
 	mu      sync.RWMutex
 }
 
@@ -20,6 +22,7 @@ func NewScriptCache(db *storage.DB) *ScriptCache {
 	return &ScriptCache{
 		db:      db,
 		scripts: make(map[string][]storage.Script),
+		indexes: make(map[string]*topicIndex),
 	}
 }
 
@@ -37,17 +40,7 @@ func (c *ScriptCache) Load() error {
 		return err
 	}
 
-	// Group by trigger type for fast lookup
-	cache := make(map[string][]storage.Script)
-	for _, script := range scripts {
-		for _, trigger := range script.Triggers {
-			if trigger.Enabled {
-				cache[trigger.Type] = append(cache[trigger.Type], script)
-			}
-		}
-	}
-
-	c.scripts = cache
+	cache := c.set(scripts)
 
 	// Count total triggers
 	totalTriggers := 0
@@ -65,6 +58,46 @@ func (c *ScriptCache) Load() error {
 	return nil
 }
 
+// set replaces the cached scripts, grouping them by trigger type and indexing
+// their topic filters. The caller must hold the write lock
+func (c *ScriptCache) set(scripts []storage.Script) map[string][]storage.Script {
+	// Group by trigger type for fast lookup
+	cache := make(map[string][]storage.Script)
+	for _, script := range scripts {
+		for _, trigger := range script.Triggers {
+			if trigger.Enabled {
+				cache[trigger.Type] = append(cache[trigger.Type], script)
+			}
+		}
+	}
+
+	// Index each entry's filters for its type, so topic lookups skip non-matching scripts
+	indexes := make(map[string]*topicIndex, len(cache))
+	for triggerType, entries := range cache {
+		index := &topicIndex{}
+		for i, script := range entries {
+			for _, trigger := range script.Triggers {
+				if trigger.Type == triggerType && trigger.Enabled {
+					index.add(trigger.Topic, i)
+				}
+			}
+		}
+		indexes[triggerType] = index
+	}
+
+	c.scripts = cache
+	c.indexes = indexes
+	c.loaded = scripts
+	return cache
+}
+
+// Scripts returns every cached (enabled) script
+func (c *ScriptCache) Scripts() []storage.Script {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.loaded
+}
+
 // GetScriptsForTrigger returns cached scripts matching the trigger type and topic
 func (c *ScriptCache) GetScriptsForTrigger(triggerType, topic string) []storage.Script {
 	c.mu.RLock()
@@ -81,17 +114,10 @@ func (c *ScriptCache) GetScriptsForTrigger(triggerType, topic string) []storage.
 	}
 
 	// Filter by topic pattern
-	filtered := make([]storage.Script, 0, len(scripts))
-	for _, script := range scripts {
-		for _, trigger := range script.Triggers {
-			if trigger.Type == triggerType && trigger.Enabled {
-				// Empty topic filter matches all topics
-				if trigger.Topic == "" || storage.MatchTopic(trigger.Topic, topic) {
-					filtered = append(filtered, script)
-					break // Only add script once even if multiple triggers match
-				}
-			}
-		}
+	matched := c.indexes[triggerType].match(topic)
+	filtered := make([]storage.Script, 0, len(matched))
+	for _, i := range matched {
+		filtered = append(filtered, scripts[i])
 	}
 
 	return filtered
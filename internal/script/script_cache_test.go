@@ -0,0 +1,102 @@
+package script
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+// linearScriptsForTrigger is the reference lookup: check every cached script's triggers
+func linearScriptsForTrigger(scripts []storage.Script, triggerType, topic string) []storage.Script {
+	filtered := make([]storage.Script, 0, len(scripts))
+	for _, script := range scripts {
+		for _, trigger := range script.Triggers {
+			if trigger.Type == triggerType && trigger.Enabled {
+				if trigger.Topic == "" || storage.MatchTopic(trigger.Topic, topic) {
+					filtered = append(filtered, script)
+					break
+				}
+			}
+		}
+	}
+	return filtered
+}
+
+func scriptIDs(scripts []storage.Script) []uint {
+	ids := make([]uint, len(scripts))
+	for i, script := range scripts {
+		ids[i] = script.ID
+	}
+	return ids
+}
+
+func TestScriptCache_IndexMatchesLinearScan(t *testing.T) {
+	filters := []string{
+		"", "#", "+", "a", "a/b", "a/+", "a/#", "a/+/c", "a/b/#", "+/b", "+/+",
+		"+/#", "a/#/c", "$SYS/#", "$SYS/+", "a//b", "a/", "/a", "a/b/c/d",
+	}
+	topics := []string{
+		"a", "a/b", "a/b/c", "a/x/c", "a/b/c/d", "b", "b/b", "$SYS/broker",
+		"$SYS", "a//b", "a/", "/a", "/", "a/#", "a/+", "x/y/z",
+	}
+
+	var scripts []storage.Script
+	for i, filter := range filters {
+		triggers := []storage.ScriptTrigger{{Type: "on_publish", Topic: filter, Enabled: true}}
+		// Some scripts have several publish triggers, or one of another type
+		if i%3 == 0 {
+			triggers = append(triggers, storage.ScriptTrigger{Type: "on_publish", Topic: filters[(i+5)%len(filters)], Enabled: true})
+		}
+		if i%4 == 0 {
+			triggers = append(triggers, storage.ScriptTrigger{Type: "on_connect", Enabled: true})
+		}
+		scripts = append(scripts, storage.Script{ID: uint(i + 1), Triggers: triggers})
+	}
+
+	cache := NewScriptCache(nil)
+	cache.set(scripts)
+	cached := cache.scripts["on_publish"]
+
+	for _, topic := range topics {
+		want := scriptIDs(linearScriptsForTrigger(cached, "on_publish", topic))
+		got := scriptIDs(cache.GetScriptsForTrigger("on_publish", topic))
+		if !slices.Equal(got, want) {
+			t.Errorf("GetScriptsForTrigger(%q) = %v, want %v", topic, got, want)
+		}
+	}
+
+	if got := cache.GetScriptsForTrigger("on_subscribe", "a/b"); got != nil {
+		t.Errorf("GetScriptsForTrigger() for a type without scripts = %v, want nil", got)
+	}
+}
+
+func BenchmarkScriptCache_GetScriptsForTrigger(b *testing.B) {
+	const scriptCount = 5000
+
+	scripts := make([]storage.Script, scriptCount)
+	for i := range scripts {
+		scripts[i] = storage.Script{
+			ID: uint(i + 1),
+			Triggers: []storage.ScriptTrigger{
+				{Type: "on_publish", Topic: fmt.Sprintf("devices/%d/telemetry/#", i), Enabled: true},
+			},
+		}
+	}
+	cache := NewScriptCache(nil)
+	cache.set(scripts)
+
+	b.Run("index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cache.GetScriptsForTrigger("on_publish", "devices/42/telemetry/temp")
+		}
+	})
+
+	b.Run("linear", func(b *testing.B) {
+		cached := cache.scripts["on_publish"]
+		for i := 0; i < b.N; i++ {
+			linearScriptsForTrigger(cached, "on_publish", "devices/42/telemetry/temp")
+		}
+	})
+}
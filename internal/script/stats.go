@@ -0,0 +1,81 @@
+package script
+
+import (
+	"sync"
+	"time"
+)
+
+// ScriptStats summarizes a script's executions since the server started
+type ScriptStats struct {
+	ScriptID        uint       `json:"script_id"`
+	Executions      uint64     `json:"executions"`
+	Failures        uint64     `json:"failures"`
+	Timeouts        uint64     `json:"timeouts"`
+	TotalDurationMs int64      `json:"total_duration_ms"`
+	AvgDurationMs   float64    `json:"avg_duration_ms"`
+	MaxDurationMs   int        `json:"max_duration_ms"`
+	LastExecutedAt  *time.Time `json:"last_executed_at,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+}
+
+// statsTracker keeps in-memory execution counters per script ID
+type statsTracker struct {
+	mu    sync.Mutex
+	stats map[uint]*ScriptStats
+}
+
+func newStatsTracker() *statsTracker {
+	return &statsTracker{stats: make(map[uint]*ScriptStats)}
+}
+
+// record adds one execution result to the script's counters
+func (t *statsTracker) record(scriptID uint, result *ExecutionResult, timedOut bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.stats[scriptID]
+	if !ok {
+		s = &ScriptStats{ScriptID: scriptID}
+		t.stats[scriptID] = s
+	}
+
+	now := time.Now()
+	s.Executions++
+	s.TotalDurationMs += int64(result.ExecutionTimeMs)
+	s.MaxDurationMs = max(s.MaxDurationMs, result.ExecutionTimeMs)
+	s.LastExecutedAt = &now
+
+	if !result.Success {
+		s.Failures++
+		if result.Error != nil {
+			s.LastError = result.Error.Error()
+		}
+	}
+	if timedOut {
+		s.Timeouts++
+	}
+}
+
+// get returns a copy of the script's counters (zero values if it never ran)
+func (t *statsTracker) get(scriptID uint) ScriptStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.stats[scriptID]
+	if !ok {
+		return ScriptStats{ScriptID: scriptID}
+	}
+
+	out := *s
+	if out.Executions > 0 {
+		out.AvgDurationMs = float64(out.TotalDurationMs) / float64(out.Executions)
+	}
+	return out
+}
+
+// forget drops the counters of a deleted script
+func (t *statsTracker) forget(scriptID uint) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.stats, scriptID)
+}
@@ -0,0 +1,215 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+// maxSubscriptionsPerScript bounds the dynamic subscriptions one script can hold
+const maxSubscriptionsPerScript = 100
+
+// ErrSubscriptionNotAllowed is returned when a script subscribes outside SCRIPT_SUBSCRIBE_TOPICS
+var ErrSubscriptionNotAllowed = errors.New("topic filter not allowed for scripts")
+
+// scriptSubscription is a topic filter a script subscribed to with mqtt.subscribe
+type scriptSubscription struct {
+	filter  string
+	handler *handlerRef
+	script  storage.Script // The script as it was when it subscribed
+}
+
+// subscriptionRegistry tracks scripts' dynamic subscriptions. Subscriptions of a
+// script are dropped when it is disabled, deleted or updated (see prune)
+type subscriptionRegistry struct {
+	mu      sync.RWMutex
+	allowed []string                                // Filters scripts may subscribe within
+	subs    map[uint]map[string]*scriptSubscription // Script ID -> filter -> subscription
+}
+
+// newSubscriptionRegistry creates a registry restricting subscriptions to filters covered by allowed
+func newSubscriptionRegistry(allowed []string) *subscriptionRegistry {
+	return &subscriptionRegistry{
+		allowed: allowed,
+		subs:    make(map[uint]map[string]*scriptSubscription),
+	}
+}
+
+// parseSubscribeTopics parses the comma-separated filters scripts may subscribe within
+// Empty means "#", which allows every topic except $-prefixed ones such as $SYS
+func parseSubscribeTopics(topics string) []string {
+	var allowed []string
+	for _, filter := range strings.Split(topics, ",") {
+		filter = strings.TrimSpace(filter)
+		if filter == "" {
+			continue
+		}
+		if !validFilter(filter) {
+			slog.Warn("Ignoring invalid SCRIPT_SUBSCRIBE_TOPICS filter", "filter", filter)
+			continue
+		}
+		allowed = append(allowed, filter)
+	}
+	if len(allowed) == 0 {
+		return []string{"#"}
+	}
+	return allowed
+}
+
+// setAllowed restricts subscriptions made from now on to filters covered by allowed
+func (r *subscriptionRegistry) setAllowed(allowed []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.allowed = allowed
+}
+
+// subscribe adds or replaces script's subscription to filter
+func (r *subscriptionRegistry) subscribe(script *storage.Script, filter string, handler *handlerRef) error {
+	if !validFilter(filter) {
+		return fmt.Errorf("invalid topic filter '%s'", filter)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.isAllowed(filter) {
+		return fmt.Errorf("%w: %s", ErrSubscriptionNotAllowed, filter)
+	}
+
+	subs, ok := r.subs[script.ID]
+	if !ok {
+		subs = make(map[string]*scriptSubscription)
+		r.subs[script.ID] = subs
+	}
+	if _, exists := subs[filter]; !exists && len(subs) >= maxSubscriptionsPerScript {
+		return fmt.Errorf("subscription limit reached (max %d per script)", maxSubscriptionsPerScript)
+	}
+	subs[filter] = &scriptSubscription{filter: filter, handler: handler, script: *script}
+	return nil
+}
+
+// unsubscribe removes script's subscription to filter, reporting whether it existed
+func (r *subscriptionRegistry) unsubscribe(scriptID uint, filter string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	subs := r.subs[scriptID]
+	if _, ok := subs[filter]; !ok {
+		return false
+	}
+	delete(subs, filter)
+	if len(subs) == 0 {
+		delete(r.subs, scriptID)
+	}
+	return true
+}
+
+// matching returns the subscriptions whose filter matches topic, ordered by script ID then filter
+func (r *subscriptionRegistry) matching(topic string) []*scriptSubscription {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matched []*scriptSubscription
+	for _, subs := range r.subs {
+		for _, sub := range subs {
+			if storage.MatchTopic(sub.filter, topic) {
+				matched = append(matched, sub)
+			}
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].script.ID != matched[j].script.ID {
+			return matched[i].script.ID < matched[j].script.ID
+		}
+		return matched[i].filter < matched[j].filter
+	})
+	return matched
+}
+
+// prune drops the subscriptions of scripts missing from enabled (disabled or
+// deleted) or changed since they subscribed. It returns how many were dropped
+func (r *subscriptionRegistry) prune(enabled []storage.Script) int {
+	current := make(map[uint]storage.Script, len(enabled))
+	for _, script := range enabled {
+		current[script.ID] = script
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	dropped := 0
+	for scriptID, subs := range r.subs {
+		script, ok := current[scriptID]
+		for filter, sub := range subs {
+			if ok && sub.script.UpdatedAt.Equal(script.UpdatedAt) {
+				continue
+			}
+			delete(subs, filter)
+			dropped++
+		}
+		if len(subs) == 0 {
+			delete(r.subs, scriptID)
+		}
+	}
+	return dropped
+}
+
+// isAllowed reports whether filter is covered by one of the allowed filters. r.mu must be held
+func (r *subscriptionRegistry) isAllowed(filter string) bool {
+	for _, allowed := range r.allowed {
+		if filterCovers(allowed, filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterCovers reports whether every topic matched by requested is also matched by allowed
+func filterCovers(allowed, requested string) bool {
+	// A leading wildcard doesn't match $ topics [MQTT-4.7.2-1]
+	if strings.HasPrefix(requested, "$") && (strings.HasPrefix(allowed, "#") || strings.HasPrefix(allowed, "+")) {
+		return false
+	}
+
+	allowedLevels := strings.Split(allowed, "/")
+	requestedLevels := strings.Split(requested, "/")
+	for i, level := range allowedLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(requestedLevels) {
+			return false
+		}
+		switch {
+		case requestedLevels[i] == "#":
+			return false // Requested reaches levels allowed doesn't
+		case level == "+":
+			continue
+		case level != requestedLevels[i]:
+			return false
+		}
+	}
+	return len(allowedLevels) == len(requestedLevels)
+}
+
+// validFilter reports whether filter is a valid MQTT topic filter
+func validFilter(filter string) bool {
+	if filter == "" {
+		return false
+	}
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		if strings.Contains(level, "#") && (level != "#" || i != len(levels)-1) {
+			return false
+		}
+		if strings.Contains(level, "+") && level != "+" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,33 @@
+package script
+
+import "testing"
+
+func TestFilterCovers(t *testing.T) {
+	tests := []struct {
+		allowed, requested string
+		want               bool
+	}{
+		{"#", "a/b", true},
+		{"#", "#", true},
+		{"#", "$SYS/#", false},
+		{"+/#", "$SYS/broker", false},
+		{"$SYS/#", "$SYS/broker/+", true},
+		{"a/#", "a", true},
+		{"a/#", "a/b/#", true},
+		{"a/#", "b/#", false},
+		{"a/+", "a/b", true},
+		{"a/+", "a/+", true},
+		{"a/+", "a/#", false},
+		{"a/+", "a/b/c", false},
+		{"a/b", "a/+", false},
+		{"a/b", "a/b", true},
+		{"a/+/c", "a/x/c", true},
+		{"a/+/c", "a/x", false},
+	}
+
+	for _, tt := range tests {
+		if got := filterCovers(tt.allowed, tt.requested); got != tt.want {
+			t.Errorf("filterCovers(%q, %q) = %v, want %v", tt.allowed, tt.requested, got, tt.want)
+		}
+	}
+}
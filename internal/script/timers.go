@@ -0,0 +1,164 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github/bromq-dev/bromq/internal/storage"
+)
+
+const (
+	maxTimersPerScript = 100            // Pending timers one script can hold
+	maxTimerDelay      = 24 * time.Hour // Longest delay setTimeout accepts
+)
+
+// ErrTimersStopped is returned when scheduling after the engine shut down
+var ErrTimersStopped = errors.New("script timers are stopped")
+
+// timerFunc runs a fired timer's handler as an execution of script
+type timerFunc func(script storage.Script, handler *handlerRef, message *Message)
+
+// scriptTimer is a pending setTimeout. Its handler runs with the msg of the execution that set it
+type scriptTimer struct {
+	timer     *time.Timer
+	updatedAt time.Time // Script version that set the timer
+}
+
+// timerRegistry tracks scripts' pending timers. Timers of a script are cancelled
+// when it is disabled, deleted or updated (see prune), and all of them on stop
+type timerRegistry struct {
+	mu      sync.Mutex
+	fire    timerFunc
+	nextID  int64
+	timers  map[uint]map[int64]*scriptTimer // Script ID -> timer ID -> timer
+	stopped bool
+}
+
+// newTimerRegistry creates a registry that hands fired timers to fire
+func newTimerRegistry(fire timerFunc) *timerRegistry {
+	return &timerRegistry{
+		fire:   fire,
+		timers: make(map[uint]map[int64]*scriptTimer),
+	}
+}
+
+// schedule runs handler as script after delay and returns the timer's ID
+func (r *timerRegistry) schedule(script *storage.Script, handler *handlerRef, delay time.Duration, message *Message) (int64, error) {
+	if delay < 0 {
+		delay = 0
+	}
+	if delay > maxTimerDelay {
+		return 0, fmt.Errorf("timer delay too long (max %v)", maxTimerDelay)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopped {
+		return 0, ErrTimersStopped
+	}
+	timers, ok := r.timers[script.ID]
+	if !ok {
+		timers = make(map[int64]*scriptTimer)
+		r.timers[script.ID] = timers
+	}
+	if len(timers) >= maxTimersPerScript {
+		return 0, fmt.Errorf("timer limit reached (max %d pending per script)", maxTimersPerScript)
+	}
+
+	r.nextID++
+	id := r.nextID
+	snapshot := *script
+	timers[id] = &scriptTimer{
+		updatedAt: script.UpdatedAt,
+		timer: time.AfterFunc(delay, func() {
+			// Cancelled timers may still fire if they raced with cancel; only run tracked ones
+			if r.remove(snapshot.ID, id) {
+				r.fire(snapshot, handler, message)
+			}
+		}),
+	}
+	return id, nil
+}
+
+// cancel stops script's timer id, reporting whether it was pending
+func (r *timerRegistry) cancel(scriptID uint, id int64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	timer, ok := r.timers[scriptID][id]
+	if !ok {
+		return false
+	}
+	timer.timer.Stop()
+	r.deleteLocked(scriptID, id)
+	return true
+}
+
+// remove untracks a fired timer, reporting whether it was still pending
+func (r *timerRegistry) remove(scriptID uint, id int64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.timers[scriptID][id]; !ok {
+		return false
+	}
+	r.deleteLocked(scriptID, id)
+	return true
+}
+
+func (r *timerRegistry) deleteLocked(scriptID uint, id int64) {
+	delete(r.timers[scriptID], id)
+	if len(r.timers[scriptID]) == 0 {
+		delete(r.timers, scriptID)
+	}
+}
+
+// prune cancels the timers of scripts missing from enabled (disabled or deleted)
+// or changed since they set them. It returns how many were cancelled
+func (r *timerRegistry) prune(enabled []storage.Script) int {
+	current := make(map[uint]time.Time, len(enabled))
+	for _, script := range enabled {
+		current[script.ID] = script.UpdatedAt
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cancelled := 0
+	for scriptID, timers := range r.timers {
+		updatedAt, ok := current[scriptID]
+		for id, timer := range timers {
+			if ok && timer.updatedAt.Equal(updatedAt) {
+				continue
+			}
+			timer.timer.Stop()
+			r.deleteLocked(scriptID, id)
+			cancelled++
+		}
+	}
+	return cancelled
+}
+
+// pending returns how many timers script has scheduled
+func (r *timerRegistry) pending(scriptID uint) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.timers[scriptID])
+}
+
+// stop cancels every timer and refuses new ones (engine shutdown)
+func (r *timerRegistry) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stopped = true
+	for _, timers := range r.timers {
+		for _, timer := range timers {
+			timer.timer.Stop()
+		}
+	}
+	r.timers = make(map[uint]map[int64]*scriptTimer)
+}
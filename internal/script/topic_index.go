@@ -0,0 +1,78 @@
+package script
+
+import (
+	"slices"
+	"strings"
+)
+
+// topicIndex maps trigger topic filters to entries (positions in a cache list)
+// so matching a topic costs roughly the number of matching filters rather than
+// the number of filters. Matching follows storage.MatchTopic exactly
+type topicIndex struct {
+	root node
+	all  []int // Entries with an empty filter, which match every topic
+}
+
+// node is one topic level in the filter trie
+type node struct {
+	children map[string]*node
+	entries  []int // Entries whose filter ends at this level
+}
+
+// add indexes entry under filter
+func (x *topicIndex) add(filter string, entry int) {
+	if filter == "" {
+		x.all = append(x.all, entry)
+		return
+	}
+
+	n := &x.root
+	for _, level := range strings.Split(filter, "/") {
+		if n.children == nil {
+			n.children = make(map[string]*node)
+		}
+		child, ok := n.children[level]
+		if !ok {
+			child = &node{}
+			n.children[level] = child
+		}
+		n = child
+	}
+	n.entries = append(n.entries, entry)
+}
+
+// match returns the entries whose filter matches topic, in ascending order without duplicates
+func (x *topicIndex) match(topic string) []int {
+	matched := append([]int(nil), x.all...)
+	levels := strings.Split(topic, "/")
+	// Topics starting with $ (e.g. $SYS) are not matched by a leading wildcard [MQTT-4.7.2-1]
+	dollar := strings.HasPrefix(topic, "$")
+
+	var walk func(n *node, i int)
+	walk = func(n *node, i int) {
+		// A trailing # also matches the parent level ("a/#" matches "a"). A # that
+		// isn't last never matches, so nothing below a # node is visited
+		wildcards := i > 0 || !dollar
+		if hash, ok := n.children["#"]; ok && wildcards {
+			matched = append(matched, hash.entries...)
+		}
+		if i == len(levels) {
+			matched = append(matched, n.entries...)
+			return
+		}
+		if plus, ok := n.children["+"]; ok && wildcards {
+			walk(plus, i+1)
+		}
+		// A topic level that is itself "+" or "#" was already visited as a wildcard
+		if levels[i] == "+" || levels[i] == "#" {
+			return
+		}
+		if child, ok := n.children[levels[i]]; ok {
+			walk(child, i+1)
+		}
+	}
+	walk(&x.root, 0)
+
+	slices.Sort(matched)
+	return slices.Compact(matched)
+}
@@ -0,0 +1,49 @@
+package script
+
+import (
+	"errors"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/parser"
+)
+
+// SyntaxError is a problem found in a script before it runs
+// Line and Column start at 1; they are 0 when the location is unknown
+type SyntaxError struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+// compileScript compiles script source the same way for execution and validation
+func compileScript(name, content string) (*goja.Program, error) {
+	return goja.Compile(name, content, false)
+}
+
+// Validate compiles a script without running it and returns its syntax errors
+// The parser reports every error it finds; errors only the compiler detects
+// (such as redeclared let/const bindings) are checked once parsing succeeds
+func Validate(content string) []SyntaxError {
+	if _, err := parser.ParseFile(nil, "script", content, 0); err != nil {
+		var list parser.ErrorList
+		if errors.As(err, &list) {
+			result := make([]SyntaxError, len(list))
+			for i, e := range list {
+				result[i] = SyntaxError{Line: e.Position.Line, Column: e.Position.Column, Message: e.Message}
+			}
+			return result
+		}
+		return []SyntaxError{{Message: err.Error()}}
+	}
+
+	if _, err := compileScript("script", content); err != nil {
+		var syntaxErr *goja.CompilerSyntaxError
+		if errors.As(err, &syntaxErr) && syntaxErr.File != nil {
+			pos := syntaxErr.File.Position(syntaxErr.Offset)
+			return []SyntaxError{{Line: pos.Line, Column: pos.Column, Message: syntaxErr.Message}}
+		}
+		return []SyntaxError{{Message: err.Error()}}
+	}
+
+	return nil
+}
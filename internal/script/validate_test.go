@@ -0,0 +1,37 @@
+package script
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		wantErrors bool
+		wantLine   int
+		wantColumn int
+	}{
+		{name: "valid script", content: "const data = msg.json();\nlog.info(data);"},
+		{name: "uses script APIs without running them", content: "mqtt.publish('a', 'b', 0, false);"},
+		{name: "syntax error", content: "log.info('start');\nif (msg.topic {\n}", wantErrors: true, wantLine: 2, wantColumn: 15},
+		{name: "redeclared binding", content: "let a = 1;\nlet a = 2;", wantErrors: true, wantLine: 2, wantColumn: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := Validate(tt.content)
+			if !tt.wantErrors {
+				if len(errs) != 0 {
+					t.Errorf("Validate() = %+v, want no errors", errs)
+				}
+				return
+			}
+
+			if len(errs) == 0 {
+				t.Fatal("Validate() = no errors, want a syntax error")
+			}
+			if errs[0].Line != tt.wantLine || errs[0].Column != tt.wantColumn || errs[0].Message == "" {
+				t.Errorf("Validate() first error = %+v, want line %d column %d with a message", errs[0], tt.wantLine, tt.wantColumn)
+			}
+		})
+	}
+}
@@ -3,6 +3,9 @@ package storage
 import (
 	"fmt"
 	"strings"
+	"time"
+
+	"gorm.io/gorm"
 )
 
 // ListACLRules returns all ACL rules
@@ -16,7 +19,8 @@ func (db *DB) ListACLRules() ([]ACLRule, error) {
 }
 
 // ListACLRulesPaginated returns paginated ACL rules with optional search and sorting
-func (db *DB) ListACLRulesPaginated(page, pageSize int, search, sortBy, sortOrder string) ([]ACLRule, int64, error) {
+// A non-zero mqttUserID or non-empty permission restricts the rules to that user or exact permission
+func (db *DB) ListACLRulesPaginated(page, pageSize int, search, sortBy, sortOrder string, mqttUserID uint, permission string) ([]ACLRule, int64, error) {
 	var rules []ACLRule
 	var total int64
 
@@ -26,6 +30,12 @@ func (db *DB) ListACLRulesPaginated(page, pageSize int, search, sortBy, sortOrde
 	if search != "" {
 		query = query.Where("topic LIKE ?", "%"+search+"%")
 	}
+	if mqttUserID != 0 {
+		query = query.Where("mqtt_user_id = ?", mqttUserID)
+	}
+	if permission != "" {
+		query = query.Where("permission = ?", permission)
+	}
 
 	// Get total count
 	if err := query.Count(&total).Error; err != nil {
@@ -55,14 +65,18 @@ func (db *DB) ListACLRulesPaginated(page, pageSize int, search, sortBy, sortOrde
 // GetACLRulesByMQTTUserID returns all ACL rules for a specific MQTT user
 // Uses in-memory cache to avoid database queries on hot path (MQTT pub/sub)
 func (db *DB) GetACLRulesByMQTTUserID(mqttUserID uint) ([]ACLRule, error) {
+	if db.authSnapshot != nil {
+		return db.snapshotACLRules(mqttUserID)
+	}
+
 	// Check cache first
 	if cachedRules, found := db.cache.GetACLRules(mqttUserID); found {
 		return cachedRules, nil
 	}
 
-	// Cache miss - query database
+	// Cache miss - query the primary, so a lagging replica can't cache revoked rules
 	var rules []ACLRule
-	err := db.Where("mqtt_user_id = ?", mqttUserID).Order("topic").Find(&rules).Error
+	err := db.primary().Where("mqtt_user_id = ?", mqttUserID).Order("topic").Find(&rules).Error
 	if err != nil {
 		return nil, fmt.Errorf("failed to get ACL rules: %w", err)
 	}
@@ -89,10 +103,10 @@ func (db *DB) CreateACLRule(mqttUserID uint, topicPattern, permission string) (*
 		return nil, fmt.Errorf("MQTT user not found")
 	}
 
-	// Create rule
+	// Create rule (normalized so equivalent patterns hit the unique index)
 	rule := ACLRule{
 		MQTTUserID: mqttUserID,
-		Topic:      topicPattern,
+		Topic:      NormalizeTopicPattern(topicPattern),
 		Permission: permission,
 	}
 
@@ -106,8 +120,30 @@ func (db *DB) CreateACLRule(mqttUserID uint, topicPattern, permission string) (*
 	return &rule, nil
 }
 
+// CreateACLRuleFromInput creates a rule with all of input's settings, including
+// Retain, in a single insert
+func (db *DB) CreateACLRuleFromInput(input ACLRuleInput) (*ACLRule, error) {
+	var rule *ACLRule
+	err := db.primary().Transaction(func(tx *gorm.DB) error {
+		var err error
+		rule, err = createACLRuleTx(tx, input)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	db.cache.DeleteACLRules(rule.MQTTUserID)
+	return rule, nil
+}
+
 // UpdateACLRule updates an existing ACL rule
 func (db *DB) UpdateACLRule(id uint, topicPattern, permission string) (*ACLRule, error) {
+	return db.UpdateACLRuleIfVersion(id, 0, topicPattern, permission)
+}
+
+// UpdateACLRuleIfVersion updates an ACL rule only if it is still at version
+// (0 = any version), returning ErrVersionConflict otherwise
+func (db *DB) UpdateACLRuleIfVersion(id, version uint, topicPattern, permission string) (*ACLRule, error) {
 	// Validate permission
 	if permission != "pub" && permission != "sub" && permission != "pubsub" {
 		return nil, fmt.Errorf("invalid permission: must be 'pub', 'sub', or 'pubsub'")
@@ -118,18 +154,62 @@ func (db *DB) UpdateACLRule(id uint, topicPattern, permission string) (*ACLRule,
 	if err := db.First(&rule, id).Error; err != nil {
 		return nil, fmt.Errorf("ACL rule not found")
 	}
+	if version > 0 && rule.Version != version {
+		return nil, ErrVersionConflict
+	}
+
+	// Update fields, re-checking the version in case of a concurrent write
+	query := db.Model(&ACLRule{}).Where("id = ?", id)
+	if version > 0 {
+		query = query.Where("version = ?", version)
+	}
+	result := query.Updates(map[string]interface{}{
+		"topic":      NormalizeTopicPattern(topicPattern),
+		"permission": permission,
+		"version":    gorm.Expr("version + 1"),
+	})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to update ACL rule: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		if version > 0 {
+			return nil, ErrVersionConflict
+		}
+		return nil, fmt.Errorf("ACL rule not found")
+	}
 
-	// Update fields
-	rule.Topic = topicPattern
+	db.cache.DeleteTopicMatcher(rule.Topic)
+	rule.Topic = NormalizeTopicPattern(topicPattern)
 	rule.Permission = permission
+	rule.Version++
 
-	if err := db.Save(&rule).Error; err != nil {
+	// Invalidate ACL cache for this user
+	db.cache.DeleteACLRules(rule.MQTTUserID)
+
+	return &rule, nil
+}
+
+// SetACLRuleRetain sets whether a rule lets its user publish retained messages
+func (db *DB) SetACLRuleRetain(id uint, retain bool) (*ACLRule, error) {
+	var rule ACLRule
+	if err := db.First(&rule, id).Error; err != nil {
+		return nil, fmt.Errorf("ACL rule not found")
+	}
+	if rule.Retain == retain {
+		return &rule, nil
+	}
+
+	err := db.Model(&ACLRule{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"retain":  retain,
+		"version": gorm.Expr("version + 1"),
+	}).Error
+	if err != nil {
 		return nil, fmt.Errorf("failed to update ACL rule: %w", err)
 	}
+	rule.Retain = retain
+	rule.Version++
 
-	// Invalidate ACL cache for this user
 	db.cache.DeleteACLRules(rule.MQTTUserID)
-
 	return &rule, nil
 }
 
@@ -142,6 +222,15 @@ func (db *DB) GetACLRule(id uint) (*ACLRule, error) {
 	return &rule, nil
 }
 
+// GetACLRuleWithUser retrieves an ACL rule by ID with its MQTT user loaded
+func (db *DB) GetACLRuleWithUser(id uint) (*ACLRule, error) {
+	var rule ACLRule
+	if err := db.Preload("MQTTUser").First(&rule, id).Error; err != nil {
+		return nil, fmt.Errorf("ACL rule not found")
+	}
+	return &rule, nil
+}
+
 // DeleteACLRule deletes an ACL rule by ID
 func (db *DB) DeleteACLRule(id uint) error {
 	// Get rule to find user ID for cache invalidation
@@ -162,13 +251,14 @@ func (db *DB) DeleteACLRule(id uint) error {
 
 	// Invalidate ACL cache for this user
 	db.cache.DeleteACLRules(rule.MQTTUserID)
+	db.cache.DeleteTopicMatcher(rule.Topic)
 
 	return nil
 }
 
 // CheckACL checks if an MQTT user has permission for a specific topic and action
 // Note: This is for MQTT users only. Admin users (dashboard) don't use MQTT ACL checks.
-// Supports dynamic placeholders: ${username} and ${clientid}
+// Supports dynamic placeholders: ${username}, ${clientid}, ${year}, ${month} and ${day}
 func (db *DB) CheckACL(username, clientID, topic, action string) (bool, error) {
 	// Get MQTT user
 	user, err := db.GetMQTTUserByUsername(username)
@@ -191,38 +281,129 @@ func (db *DB) CheckACL(username, clientID, topic, action string) (bool, error) {
 
 	// Check if any rule matches the topic
 	for _, rule := range rules {
-		// Replace placeholders in the pattern before matching
-		expandedPattern := replacePlaceholders(rule.Topic, username, clientID)
-
-		if MatchTopic(expandedPattern, topic) {
-			// Check if permission matches action
-			switch action {
-			case "pub":
-				if rule.Permission == "pub" || rule.Permission == "pubsub" {
-					return true, nil
-				}
-			case "sub":
-				if rule.Permission == "sub" || rule.Permission == "pubsub" {
-					return true, nil
-				}
-			}
+		// Check the permission first - it's far cheaper than matching the topic
+		if !ruleAllows(rule.Permission, rule.Retain, action) {
+			continue
+		}
+
+		// Match using the precompiled pattern (expands placeholders per level)
+		if db.cache.GetTopicMatcher(rule.Topic).Match(topic, username, clientID) {
+			return true, nil
 		}
 	}
 
 	return false, nil
 }
 
+// HasMatchingACLRule reports whether any of the user's rules matches topic,
+// whatever its permission. A denied request with no matching rule points at a
+// missing rule rather than one that grants the wrong action
+func (db *DB) HasMatchingACLRule(username, clientID, topic string) (bool, error) {
+	user, err := db.GetMQTTUserByUsername(username)
+	if err != nil {
+		if err.Error() == "record not found" {
+			return false, nil
+		}
+		return false, err
+	}
+	if user == nil {
+		return false, nil
+	}
+
+	rules, err := db.GetACLRulesByMQTTUserID(user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, rule := range rules {
+		if db.cache.GetTopicMatcher(rule.Topic).Match(topic, username, clientID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// permissionAllows reports whether a rule permission grants the action (pub or sub)
+func permissionAllows(permission, action string) bool {
+	switch action {
+	case "pub":
+		return permission == "pub" || permission == "pubsub"
+	case "sub":
+		return permission == "sub" || permission == "pubsub"
+	}
+	return false
+}
+
+// ruleAllows reports whether a rule grants the action. The "retain" action
+// (publishing a retained message) also needs the rule's retain flag
+func ruleAllows(permission string, retain bool, action string) bool {
+	if action == "retain" {
+		return retain && permissionAllows(permission, "pub")
+	}
+	return permissionAllows(permission, action)
+}
+
+// NormalizeTopicPattern returns the canonical form of an ACL topic pattern:
+// surrounding whitespace is trimmed, repeated separators are collapsed and a
+// trailing separator is dropped, so "a//b/" and "a/b" are stored as the same rule
+// Only patterns are normalized: the topic a client publishes or subscribes to is
+// matched byte for byte, since "a/b/" and "a//b" are distinct MQTT topics
+func NormalizeTopicPattern(pattern string) string {
+	pattern = strings.TrimSpace(pattern)
+	if !strings.Contains(pattern, "//") && !strings.HasSuffix(pattern, "/") {
+		return pattern // Fast path for the hot ACL check
+	}
+
+	for strings.Contains(pattern, "//") {
+		pattern = strings.ReplaceAll(pattern, "//", "/")
+	}
+	if len(pattern) > 1 {
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	return pattern
+}
+
+// ExpandTopicPattern resolves the placeholders of an ACL topic pattern for a
+// client, giving the pattern CheckACL matches that client's topics against
+func ExpandTopicPattern(pattern, username, clientID string) string {
+	return replacePlaceholders(pattern, username, clientID)
+}
+
+// aclNow is the clock date placeholders are resolved against (replaced in tests)
+var aclNow = time.Now
+
 // replacePlaceholders replaces dynamic placeholders in topic patterns
-// Supports: ${username} and ${clientid}
+// Supports: ${username}, ${clientid} and the current UTC date as ${year}
+// (2006), ${month} (01-12) and ${day} (01-31) for time-partitioned topics
+// Dates are expanded first so a username or client ID can't inject one
 func replacePlaceholders(pattern, username, clientID string) string {
-	result := pattern
+	result := replaceDatePlaceholders(pattern, aclNow())
 	result = strings.ReplaceAll(result, "${username}", username)
 	result = strings.ReplaceAll(result, "${clientid}", clientID)
 	return result
 }
 
+// replaceDatePlaceholders expands ${year}, ${month} and ${day} to now in UTC
+func replaceDatePlaceholders(pattern string, now time.Time) string {
+	if !strings.Contains(pattern, "${year}") && !strings.Contains(pattern, "${month}") && !strings.Contains(pattern, "${day}") {
+		return pattern
+	}
+	now = now.UTC()
+	return strings.NewReplacer(
+		"${year}", now.Format("2006"),
+		"${month}", now.Format("01"),
+		"${day}", now.Format("02"),
+	).Replace(pattern)
+}
+
 // MatchTopic checks if a topic matches a pattern with MQTT wildcards (+ and #)
 func MatchTopic(pattern, topic string) bool {
+	// Topics starting with $ (e.g. $SYS) are not matched by a leading wildcard [MQTT-4.7.2-1]
+	// so access to them must be granted explicitly
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(pattern, "#") || strings.HasPrefix(pattern, "+")) {
+		return false
+	}
+
 	patternLevels := strings.Split(pattern, "/")
 	topicLevels := strings.Split(topic, "/")
 
@@ -269,7 +450,7 @@ func (db *DB) DeleteProvisionedACLRules(mqttUserID uint) error {
 }
 
 // CreateProvisionedACLRule creates a new ACL rule marked as provisioned from config
-func (db *DB) CreateProvisionedACLRule(mqttUserID uint, topicPattern, permission string) error {
+func (db *DB) CreateProvisionedACLRule(mqttUserID uint, topicPattern, permission string, retain bool) error {
 	// Validate permission
 	if permission != "pub" && permission != "sub" && permission != "pubsub" {
 		return fmt.Errorf("invalid permission: must be 'pub', 'sub', or 'pubsub'")
@@ -278,8 +459,9 @@ func (db *DB) CreateProvisionedACLRule(mqttUserID uint, topicPattern, permission
 	// Create rule marked as provisioned
 	rule := ACLRule{
 		MQTTUserID:            mqttUserID,
-		Topic:                 topicPattern,
+		Topic:                 NormalizeTopicPattern(topicPattern),
 		Permission:            permission,
+		Retain:                retain,
 		ProvisionedFromConfig: true,
 	}
 
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Statuses reported per item by the bulk ACL operations
+const (
+	BulkStatusCreated = "created"
+	BulkStatusDeleted = "deleted"
+	BulkStatusSkipped = "skipped" // Provisioned rules are left to the config file
+	BulkStatusFailed  = "failed"
+)
+
+// ACLRuleInput describes one rule to create in a batch
+type ACLRuleInput struct {
+	MQTTUserID uint
+	Topic      string
+	Permission string
+	Retain     bool
+}
+
+// BulkACLResult is the outcome of one item of a bulk ACL operation
+type BulkACLResult struct {
+	Index  int      `json:"index"` // Position of the item in the request
+	ID     uint     `json:"id,omitempty"`
+	Status string   `json:"status"`
+	Error  string   `json:"error,omitempty"`
+	Rule   *ACLRule `json:"rule,omitempty"` // The created rule
+}
+
+// CreateACLRules creates many rules in a single transaction. Each rule runs in
+// its own savepoint, so an invalid or duplicate rule is reported in its result
+// without undoing the others. The error is only set if the batch as a whole
+// could not be committed
+func (db *DB) CreateACLRules(inputs []ACLRuleInput) ([]BulkACLResult, error) {
+	results := make([]BulkACLResult, len(inputs))
+	touched := make(map[uint]bool)
+
+	err := db.primary().Transaction(func(tx *gorm.DB) error {
+		for i, input := range inputs {
+			results[i] = BulkACLResult{Index: i}
+
+			var rule *ACLRule
+			err := tx.Transaction(func(item *gorm.DB) error {
+				var err error
+				rule, err = createACLRuleTx(item, input)
+				return err
+			})
+			if err != nil {
+				results[i].Status = BulkStatusFailed
+				results[i].Error = err.Error()
+				continue
+			}
+
+			results[i].ID = rule.ID
+			results[i].Status = BulkStatusCreated
+			results[i].Rule = rule
+			touched[rule.MQTTUserID] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ACL rules: %w", err)
+	}
+
+	for userID := range touched {
+		db.cache.DeleteACLRules(userID)
+	}
+	return results, nil
+}
+
+// createACLRuleTx validates and inserts a single rule within tx
+func createACLRuleTx(tx *gorm.DB, input ACLRuleInput) (*ACLRule, error) {
+	if input.Permission != "pub" && input.Permission != "sub" && input.Permission != "pubsub" {
+		return nil, fmt.Errorf("invalid permission: must be 'pub', 'sub', or 'pubsub'")
+	}
+	if NormalizeTopicPattern(input.Topic) == "" {
+		return nil, fmt.Errorf("topic is required")
+	}
+
+	var user MQTTUser
+	if err := tx.Select("id").First(&user, input.MQTTUserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("MQTT user not found")
+		}
+		return nil, err
+	}
+
+	rule := ACLRule{
+		MQTTUserID: input.MQTTUserID,
+		Topic:      NormalizeTopicPattern(input.Topic),
+		Permission: input.Permission,
+		Retain:     input.Retain,
+	}
+	if err := tx.Create(&rule).Error; err != nil {
+		return nil, fmt.Errorf("failed to create ACL rule: %w", err)
+	}
+	return &rule, nil
+}
+
+// DeleteACLRulesByID deletes many rules in a single transaction. Provisioned
+// rules are skipped and unknown IDs are reported as failed; neither stops the
+// rest of the batch
+func (db *DB) DeleteACLRulesByID(ids []uint) ([]BulkACLResult, error) {
+	results := make([]BulkACLResult, len(ids))
+	var deleted []ACLRule
+
+	err := db.primary().Transaction(func(tx *gorm.DB) error {
+		for i, id := range ids {
+			results[i] = BulkACLResult{Index: i, ID: id}
+
+			var rule ACLRule
+			if err := tx.First(&rule, id).Error; err != nil {
+				if !errors.Is(err, gorm.ErrRecordNotFound) {
+					return err
+				}
+				results[i].Status = BulkStatusFailed
+				results[i].Error = "ACL rule not found"
+				continue
+			}
+			if rule.ProvisionedFromConfig {
+				results[i].Status = BulkStatusSkipped
+				results[i].Error = "provisioned ACL rule is managed by the configuration file"
+				continue
+			}
+
+			if err := tx.Delete(&ACLRule{}, id).Error; err != nil {
+				return err
+			}
+			results[i].Status = BulkStatusDeleted
+			deleted = append(deleted, rule)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete ACL rules: %w", err)
+	}
+
+	for _, rule := range deleted {
+		db.cache.DeleteACLRules(rule.MQTTUserID)
+		db.cache.DeleteTopicMatcher(rule.Topic)
+	}
+	return results, nil
+}
+
+// CopyACLRules copies the source user's ACL rules (except provisioned ones) to
+// the target user. Topics the target already has a rule for are skipped, so
+// the target ends up with the union of both rule sets. Returns the number of
+// rules copied and skipped
+func (db *DB) CopyACLRules(targetID, sourceID uint) (copied, skipped int, err error) {
+	if targetID == sourceID {
+		return 0, 0, fmt.Errorf("source and target must be different users")
+	}
+
+	err = db.primary().Transaction(func(tx *gorm.DB) error {
+		for _, id := range []uint{targetID, sourceID} {
+			if err := tx.Select("id").First(&MQTTUser{}, id).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("MQTT user %d not found", id)
+				}
+				return err
+			}
+		}
+
+		var targetRules []ACLRule
+		if err := tx.Select("topic").Where("mqtt_user_id = ?", targetID).Find(&targetRules).Error; err != nil {
+			return fmt.Errorf("failed to load target ACL rules: %w", err)
+		}
+		existing := make(map[string]bool, len(targetRules))
+		for _, rule := range targetRules {
+			existing[NormalizeTopicPattern(rule.Topic)] = true
+		}
+
+		var sourceRules []ACLRule
+		if err := tx.Where("mqtt_user_id = ? AND provisioned_from_config = ?", sourceID, false).Order("id").Find(&sourceRules).Error; err != nil {
+			return fmt.Errorf("failed to load source ACL rules: %w", err)
+		}
+		for _, rule := range sourceRules {
+			topic := NormalizeTopicPattern(rule.Topic)
+			if existing[topic] {
+				skipped++
+				continue
+			}
+			clone := ACLRule{MQTTUserID: targetID, Topic: topic, Permission: rule.Permission, Retain: rule.Retain}
+			if err := tx.Create(&clone).Error; err != nil {
+				return fmt.Errorf("failed to copy ACL rule %s: %w", rule.Topic, err)
+			}
+			existing[topic] = true
+			copied++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if copied > 0 {
+		db.cache.DeleteACLRules(targetID)
+	}
+	return copied, skipped, nil
+}
@@ -0,0 +1,36 @@
+package storage
+
+import "testing"
+
+func TestBulkACLRules_InvalidateCache(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user := createTestMQTTUser(t, db, "sensor", "password123", "")
+
+	// Warm the rule cache with an empty rule set
+	if allowed, _ := db.CheckACL("sensor", "c1", "sensors/temp", "pub"); allowed {
+		t.Fatal("CheckACL() should deny before any rule exists")
+	}
+
+	results, err := db.CreateACLRules([]ACLRuleInput{
+		{MQTTUserID: user.ID, Topic: "sensors/#/", Permission: "pub"},
+		{MQTTUserID: user.ID, Topic: "sensors/#", Permission: "pub"}, // Same pattern once normalized
+	})
+	if err != nil {
+		t.Fatalf("CreateACLRules() error = %v", err)
+	}
+	if results[0].Status != BulkStatusCreated || results[1].Status != BulkStatusFailed {
+		t.Fatalf("CreateACLRules() results = %+v, want created then failed", results)
+	}
+	if allowed, _ := db.CheckACL("sensor", "c1", "sensors/temp", "pub"); !allowed {
+		t.Error("CheckACL() should see the bulk-created rule")
+	}
+
+	if _, err := db.DeleteACLRulesByID([]uint{results[0].ID}); err != nil {
+		t.Fatalf("DeleteACLRulesByID() error = %v", err)
+	}
+	if allowed, _ := db.CheckACL("sensor", "c1", "sensors/temp", "pub"); allowed {
+		t.Error("CheckACL() should deny after the rule is bulk-deleted")
+	}
+}
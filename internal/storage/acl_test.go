@@ -1,7 +1,12 @@
 package storage
 
 import (
+	"fmt"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func TestCreateACLRule(t *testing.T) {
@@ -357,6 +362,26 @@ func TestMatchTopic(t *testing.T) {
 			want:    false,
 		},
 
+		// $-prefixed topics
+		{
+			name:    "leading multi-level wildcard does not match $SYS",
+			pattern: "#",
+			topic:   "$SYS/broker/uptime",
+			want:    false,
+		},
+		{
+			name:    "leading single-level wildcard does not match $SYS",
+			pattern: "+/broker/uptime",
+			topic:   "$SYS/broker/uptime",
+			want:    false,
+		},
+		{
+			name:    "explicit $SYS pattern matches",
+			pattern: "$SYS/#",
+			topic:   "$SYS/broker/uptime",
+			want:    true,
+		},
+
 		// Single-level wildcard (+)
 		{
 			name:    "single-level wildcard match",
@@ -547,6 +572,71 @@ func TestDuplicateACLRulePrevention(t *testing.T) {
 	}
 }
 
+func TestNormalizeTopicPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"a/b", "a/b"},
+		{"a/b/", "a/b"},
+		{" a/b ", "a/b"},
+		{"a//b", "a/b"},
+		{"a///b//#", "a/b/#"},
+		{"devices/${clientid}/", "devices/${clientid}"},
+		{"/a/b", "/a/b"},
+		{"/", "/"},
+		{"#", "#"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeTopicPattern(tt.pattern); got != tt.want {
+			t.Errorf("NormalizeTopicPattern(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestDuplicateACLRulePrevention_Normalized(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user := createTestMQTTUser(t, db, "testuser", "password123", "Test MQTT user")
+
+	rule, err := db.CreateACLRule(user.ID, "a/b/", "pub")
+	if err != nil {
+		t.Fatalf("CreateACLRule() failed: %v", err)
+	}
+	if rule.Topic != "a/b" {
+		t.Errorf("CreateACLRule() stored topic = %q, want %q", rule.Topic, "a/b")
+	}
+
+	// Normalized-equivalent patterns are duplicates of the first rule
+	for _, pattern := range []string{"a/b", "a//b", " a/b/ "} {
+		if _, err := db.CreateACLRule(user.ID, pattern, "sub"); err == nil {
+			t.Errorf("CreateACLRule(%q) should have failed as a duplicate of a/b/", pattern)
+		}
+	}
+
+	// Updating another rule onto an equivalent pattern is a duplicate too
+	other, err := db.CreateACLRule(user.ID, "a/c", "pub")
+	if err != nil {
+		t.Fatalf("CreateACLRule() failed: %v", err)
+	}
+	if _, err := db.UpdateACLRule(other.ID, "a//b/", "pub"); err == nil {
+		t.Error("UpdateACLRule() should have failed as a duplicate of a/b")
+	}
+
+	// Client topics are matched as sent: "a/b/" and "a//b" are other topics
+	for topic, want := range map[string]bool{"a/b": true, "a/b/": false, "a//b": false} {
+		allowed, err := db.CheckACL("testuser", "client1", topic, "pub")
+		if err != nil {
+			t.Fatalf("CheckACL() error = %v", err)
+		}
+		if allowed != want {
+			t.Errorf("CheckACL(%q) = %v, want %v", topic, allowed, want)
+		}
+	}
+}
+
 func TestReplacePlaceholders(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -610,6 +700,97 @@ func TestReplacePlaceholders(t *testing.T) {
 	}
 }
 
+func TestReplacePlaceholders_Date(t *testing.T) {
+	// 23:30 on Dec 31 2025 in UTC-5 is already Jan 1 2026 in UTC
+	aclNow = func() time.Time { return time.Date(2025, 12, 31, 23, 30, 0, 0, time.FixedZone("EST", -5*60*60)) }
+	t.Cleanup(func() { aclNow = time.Now })
+
+	tests := []struct {
+		pattern  string
+		username string
+		want     string
+	}{
+		{"data/${year}/${month}/${day}/#", "alice", "data/2026/01/01/#"},
+		{"logs/${username}/${year}-${month}", "alice", "logs/alice/2026-01"},
+		{"data/${year}", "alice", "data/2026"},
+		// A username can't inject a date placeholder
+		{"user/${username}", "${year}", "user/${year}"},
+	}
+	for _, tt := range tests {
+		if got := replacePlaceholders(tt.pattern, tt.username, "c1"); got != tt.want {
+			t.Errorf("replacePlaceholders(%q, %q) = %q, want %q", tt.pattern, tt.username, got, tt.want)
+		}
+	}
+
+	matcher := compileTopicPattern("data/${year}/${month}/+")
+	if !matcher.Match("data/2026/01/temp", "alice", "c1") {
+		t.Error("Match() rejected a topic for the current month")
+	}
+	if matcher.Match("data/2025/12/temp", "alice", "c1") {
+		t.Error("Match() accepted a topic for the previous month")
+	}
+}
+
+func TestCheckACLWithDatePlaceholders(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user := createTestMQTTUser(t, db, "logger", "password123", "")
+	createTestACLRule(t, db, user.ID, "data/${year}/#", "pub")
+
+	year := time.Now().UTC().Year()
+	tests := []struct {
+		topic       string
+		wantAllowed bool
+	}{
+		{fmt.Sprintf("data/%d/01/reading", year), true},
+		{fmt.Sprintf("data/%d", year), true},
+		{fmt.Sprintf("data/%d/01/reading", year-1), false},
+		{"data/${year}/01/reading", false},
+	}
+	for _, tt := range tests {
+		allowed, err := db.CheckACL("logger", "c1", tt.topic, "pub")
+		if err != nil {
+			t.Fatalf("CheckACL() unexpected error: %v", err)
+		}
+		if allowed != tt.wantAllowed {
+			t.Errorf("CheckACL(%q) allowed = %v, want %v", tt.topic, allowed, tt.wantAllowed)
+		}
+	}
+}
+
+func TestCheckACL_Retain(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user := createTestMQTTUser(t, db, "sensor", "password123", "")
+	rule := createTestACLRule(t, db, user.ID, "status/#", "pub")
+	subRule := createTestACLRule(t, db, user.ID, "config/#", "sub")
+
+	if allowed, _ := db.CheckACL("sensor", "c1", "status/online", "retain"); allowed {
+		t.Error("CheckACL(retain) allowed before the rule grants retain")
+	}
+
+	updated, err := db.SetACLRuleRetain(rule.ID, true)
+	if err != nil {
+		t.Fatalf("SetACLRuleRetain() error = %v", err)
+	}
+	if !updated.Retain || updated.Version != rule.Version+1 {
+		t.Errorf("SetACLRuleRetain() = retain %v version %d, want true and %d", updated.Retain, updated.Version, rule.Version+1)
+	}
+	if allowed, _ := db.CheckACL("sensor", "c1", "status/online", "retain"); !allowed {
+		t.Error("CheckACL(retain) denied after the rule grants retain")
+	}
+
+	// Retain without publish permission grants nothing
+	if _, err := db.SetACLRuleRetain(subRule.ID, true); err != nil {
+		t.Fatalf("SetACLRuleRetain() error = %v", err)
+	}
+	if allowed, _ := db.CheckACL("sensor", "c1", "config/x", "retain"); allowed {
+		t.Error("CheckACL(retain) allowed on a subscribe-only rule")
+	}
+}
+
 func TestCheckACLWithPlaceholders(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -770,7 +951,7 @@ func TestCreateProvisionedACLRule(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := db.CreateProvisionedACLRule(tt.userID, tt.topicPattern, tt.permission)
+			err := db.CreateProvisionedACLRule(tt.userID, tt.topicPattern, tt.permission, false)
 
 			if tt.wantErr {
 				if err == nil {
@@ -814,8 +995,8 @@ func TestDeleteProvisionedACLRules(t *testing.T) {
 	user := createTestMQTTUser(t, db, "testuser", "password123", "Test user")
 
 	// Create both provisioned and manual rules
-	db.CreateProvisionedACLRule(user.ID, "provisioned/1/#", "pub")
-	db.CreateProvisionedACLRule(user.ID, "provisioned/2/#", "sub")
+	db.CreateProvisionedACLRule(user.ID, "provisioned/1/#", "pub", false)
+	db.CreateProvisionedACLRule(user.ID, "provisioned/2/#", "sub", false)
 	db.CreateACLRule(user.ID, "manual/1/#", "pubsub")
 
 	// Verify all rules exist
@@ -859,8 +1040,8 @@ func TestDeleteProvisionedACLRules_MultipleUsers(t *testing.T) {
 	user2 := createTestMQTTUser(t, db, "user2", "pass2", "User 2")
 
 	// Create provisioned rules for both users
-	db.CreateProvisionedACLRule(user1.ID, "user1/#", "pubsub")
-	db.CreateProvisionedACLRule(user2.ID, "user2/#", "pubsub")
+	db.CreateProvisionedACLRule(user1.ID, "user1/#", "pubsub", false)
+	db.CreateProvisionedACLRule(user2.ID, "user2/#", "pubsub", false)
 
 	// Delete provisioned rules for user1 only
 	err := db.DeleteProvisionedACLRules(user1.ID)
@@ -880,3 +1061,127 @@ func TestDeleteProvisionedACLRules_MultipleUsers(t *testing.T) {
 		t.Errorf("expected 1 rule for user2, got %d", len(rules2))
 	}
 }
+
+// setupACLBenchmark creates a user with n rules, the matching one last
+func setupACLBenchmark(b *testing.B, n int) *DB {
+	b.Helper()
+
+	config := DefaultSQLiteConfig(":memory:")
+	db, err := OpenWithCache(config, NewCacheWithRegistry(prometheus.NewRegistry()))
+	if err != nil {
+		b.Fatalf("failed to open test database: %v", err)
+	}
+	b.Cleanup(func() { _ = db.Close() })
+
+	user, err := db.CreateMQTTUser("bench_user", "password", "", nil)
+	if err != nil {
+		b.Fatalf("failed to create user: %v", err)
+	}
+
+	for i := 0; i < n-1; i++ {
+		pattern := fmt.Sprintf("devices/${clientid}/group%d/+/data", i)
+		if _, err := db.CreateACLRule(user.ID, pattern, "pubsub"); err != nil {
+			b.Fatalf("failed to create rule: %v", err)
+		}
+	}
+	if _, err := db.CreateACLRule(user.ID, "sensors/+/temp/#", "pub"); err != nil {
+		b.Fatalf("failed to create rule: %v", err)
+	}
+
+	return db
+}
+
+// BenchmarkCheckACL measures a worst-case lookup (matching rule scanned last)
+// with the per-user rule cache warm, which is the steady state on the publish path
+func BenchmarkCheckACL(b *testing.B) {
+	for _, n := range []int{10, 100, 500} {
+		b.Run(fmt.Sprintf("rules=%d", n), func(b *testing.B) {
+			db := setupACLBenchmark(b, n)
+
+			// Warm the user and rule caches
+			if allowed, err := db.CheckACL("bench_user", "client-1", "sensors/room1/temp/c", "pub"); err != nil || !allowed {
+				b.Fatalf("CheckACL() = %v, %v; want true", allowed, err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, _ = db.CheckACL("bench_user", "client-1", "sensors/room1/temp/c", "pub")
+			}
+		})
+	}
+}
+
+// BenchmarkCheckACLCacheMiss measures the cost when the rules must be loaded from the database
+func BenchmarkCheckACLCacheMiss(b *testing.B) {
+	db := setupACLBenchmark(b, 500)
+	user, err := db.GetMQTTUserByUsername("bench_user")
+	if err != nil {
+		b.Fatalf("failed to get user: %v", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		db.cache.DeleteACLRules(user.ID)
+		_, _ = db.CheckACL("bench_user", "client-1", "sensors/room1/temp/c", "pub")
+	}
+}
+
+// FuzzMatchTopic checks MatchTopic invariants that hold for any topic
+// Run with: go test -run=^$ -fuzz=FuzzMatchTopic ./internal/storage/
+func FuzzMatchTopic(f *testing.F) {
+	f.Add("devices/sensor1/telemetry", 1)
+	f.Add("$SYS/broker/clients", 0)
+	f.Add("a//b", 1)
+	f.Add("/leading", 0)
+	f.Add("trailing/", 2)
+	f.Add("", 0)
+
+	f.Fuzz(func(t *testing.T, topic string, level int) {
+		// Published topics never contain wildcards
+		if strings.ContainsAny(topic, "+#") {
+			t.Skip()
+		}
+		levels := strings.Split(topic, "/")
+		if level < 0 {
+			level = -level
+		}
+		level %= len(levels)
+
+		// An exact topic always matches itself
+		if !MatchTopic(topic, topic) {
+			t.Errorf("MatchTopic(%q, %q) = false, want true", topic, topic)
+		}
+
+		// # at the root matches everything except $-prefixed topics
+		if got, want := MatchTopic("#", topic), !strings.HasPrefix(topic, "$"); got != want {
+			t.Errorf("MatchTopic(\"#\", %q) = %v, want %v", topic, got, want)
+		}
+
+		// + matches exactly one level: replacing a level with + still matches,
+		// but a lone + never matches a topic with more than one level
+		wildcard := append([]string(nil), levels...)
+		wildcard[level] = "+"
+		pattern := strings.Join(wildcard, "/")
+		leadingWildcardOnSys := level == 0 && strings.HasPrefix(topic, "$")
+		if got := MatchTopic(pattern, topic); got == leadingWildcardOnSys {
+			t.Errorf("MatchTopic(%q, %q) = %v, want %v", pattern, topic, got, !leadingWildcardOnSys)
+		}
+		if len(levels) > 1 && MatchTopic("+", topic) {
+			t.Errorf("MatchTopic(\"+\", %q) = true, + crossed a separator", topic)
+		}
+		if MatchTopic(pattern+"/x", topic) {
+			t.Errorf("MatchTopic(%q, %q) = true, want false", pattern+"/x", topic)
+		}
+
+		// A # that is not the last level never matches
+		invalid := append([]string(nil), levels...)
+		invalid[level] = "#"
+		invalid = append(invalid, "x")
+		pattern = strings.Join(invalid, "/")
+		if MatchTopic(pattern, topic) {
+			t.Errorf("MatchTopic(%q, %q) = true, non-final # must never match", pattern, topic)
+		}
+	})
+}
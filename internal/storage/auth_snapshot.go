@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+// authSnapshot holds every MQTT user and ACL rule in memory, so authentication
+// and ACL checks never read the database. Any change to users or rules marks
+// it stale and the next lookup reloads it in full, which suits deployments
+// with a modest number of users
+type authSnapshot struct {
+	mu    sync.Mutex // Serializes reloads
+	stale atomic.Bool
+	data  atomic.Pointer[authSnapshotData]
+}
+
+// authSnapshotData is one immutable load of the users and rules tables
+type authSnapshotData struct {
+	users map[string]*MQTTUser // Keyed by username
+	rules map[uint][]ACLRule   // Keyed by mqtt_user_id, ordered by topic
+}
+
+// EnableAuthSnapshot loads all MQTT users and ACL rules into memory and serves
+// GetMQTTUserByUsername and GetACLRulesByMQTTUserID from that snapshot from
+// now on. Unknown usernames are rejected without a database query
+func (db *DB) EnableAuthSnapshot() error {
+	snapshot := &authSnapshot{}
+	db.authSnapshot = snapshot
+	if err := db.loadAuthSnapshot(); err != nil {
+		db.authSnapshot = nil
+		return err
+	}
+
+	// Every user or rule change already invalidates the cache
+	db.cache.setInvalidateHook(db.invalidateAuthSnapshot)
+
+	data := snapshot.data.Load()
+	slog.Info("In-memory MQTT auth enabled", "users", len(data.users), "acl_users", len(data.rules))
+	return nil
+}
+
+// invalidateAuthSnapshot marks the snapshot for reload on its next lookup
+func (db *DB) invalidateAuthSnapshot() {
+	if db.authSnapshot != nil {
+		db.authSnapshot.stale.Store(true)
+	}
+}
+
+// authSnapshotData returns the current snapshot, reloading it first if stale
+func (db *DB) authSnapshotData() (*authSnapshotData, error) {
+	snapshot := db.authSnapshot
+	if snapshot.stale.Load() {
+		snapshot.mu.Lock()
+		defer snapshot.mu.Unlock()
+		if snapshot.stale.Load() {
+			if err := db.loadAuthSnapshot(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return snapshot.data.Load(), nil
+}
+
+// loadAuthSnapshot reads all users and rules from the primary (a replica
+// could still miss the change that made the snapshot stale)
+func (db *DB) loadAuthSnapshot() error {
+	snapshot := db.authSnapshot
+
+	// Cleared before reading, so a change made during the load marks it stale again
+	snapshot.stale.Store(false)
+
+	var users []MQTTUser
+	if err := db.primary().Find(&users).Error; err != nil {
+		snapshot.stale.Store(true)
+		return fmt.Errorf("failed to load MQTT users: %w", err)
+	}
+	var rules []ACLRule
+	if err := db.primary().Order("topic").Find(&rules).Error; err != nil {
+		snapshot.stale.Store(true)
+		return fmt.Errorf("failed to load ACL rules: %w", err)
+	}
+
+	data := &authSnapshotData{
+		users: make(map[string]*MQTTUser, len(users)),
+		rules: make(map[uint][]ACLRule),
+	}
+	for i := range users {
+		data.users[users[i].Username] = &users[i]
+	}
+	for _, rule := range rules {
+		data.rules[rule.MQTTUserID] = append(data.rules[rule.MQTTUserID], rule)
+	}
+	snapshot.data.Store(data)
+	return nil
+}
+
+// snapshotUser looks up an MQTT user in the snapshot
+func (db *DB) snapshotUser(username string) (*MQTTUser, error) {
+	data, err := db.authSnapshotData()
+	if err != nil {
+		return nil, err
+	}
+	user, ok := data.users[username]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return user, nil
+}
+
+// snapshotACLRules looks up a user's ACL rules in the snapshot
+func (db *DB) snapshotACLRules(mqttUserID uint) ([]ACLRule, error) {
+	data, err := db.authSnapshotData()
+	if err != nil {
+		return nil, err
+	}
+	return data.rules[mqttUserID], nil
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestAuthSnapshot(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	sensor := createTestMQTTUser(t, db, "sensor", "password123", "Sensors")
+	createTestACLRule(t, db, sensor.ID, "sensors/${username}/#", "pubsub")
+
+	if err := db.EnableAuthSnapshot(); err != nil {
+		t.Fatalf("EnableAuthSnapshot() error = %v", err)
+	}
+
+	user, err := db.AuthenticateMQTTUser("sensor", "password123")
+	if err != nil || user == nil {
+		t.Fatalf("AuthenticateMQTTUser() = %v, %v; want sensor", user, err)
+	}
+	if allowed, err := db.CheckACL("sensor", "c1", "sensors/sensor/temp", "pub"); err != nil || !allowed {
+		t.Errorf("CheckACL() = %v, %v; want true", allowed, err)
+	}
+	if _, err := db.GetMQTTUserByUsername("unknown"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetMQTTUserByUsername(unknown) error = %v, want ErrRecordNotFound", err)
+	}
+
+	// A write that bypasses the storage layer is not seen until the next invalidation
+	if err := db.Model(&MQTTUser{}).Where("id = ?", sensor.ID).Update("description", "raw").Error; err != nil {
+		t.Fatalf("raw update error = %v", err)
+	}
+	if user, _ := db.GetMQTTUserByUsername("sensor"); user.Description != "Sensors" {
+		t.Errorf("description = %q, want the snapshot value", user.Description)
+	}
+	db.invalidateAuthSnapshot()
+	if user, _ := db.GetMQTTUserByUsername("sensor"); user.Description != "raw" {
+		t.Errorf("description = %q, want the reloaded value", user.Description)
+	}
+}
+
+func TestAuthSnapshot_RefreshesOnChanges(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	if err := db.EnableAuthSnapshot(); err != nil {
+		t.Fatalf("EnableAuthSnapshot() error = %v", err)
+	}
+
+	// Create
+	user := createTestMQTTUser(t, db, "camera", "password123", "")
+	if _, err := db.AuthenticateMQTTUser("camera", "password123"); err != nil {
+		t.Fatalf("AuthenticateMQTTUser() after create error = %v", err)
+	}
+
+	// ACL create, update, and delete
+	rule := createTestACLRule(t, db, user.ID, "video/#", "pub")
+	if allowed, _ := db.CheckACL("camera", "c1", "video/front", "pub"); !allowed {
+		t.Error("CheckACL() should allow after the rule is created")
+	}
+	if _, err := db.UpdateACLRule(rule.ID, "audio/#", "pub"); err != nil {
+		t.Fatalf("UpdateACLRule() error = %v", err)
+	}
+	if allowed, _ := db.CheckACL("camera", "c1", "video/front", "pub"); allowed {
+		t.Error("CheckACL() should deny the old topic after the rule is updated")
+	}
+	if err := db.DeleteACLRule(rule.ID); err != nil {
+		t.Fatalf("DeleteACLRule() error = %v", err)
+	}
+	if allowed, _ := db.CheckACL("camera", "c1", "audio/front", "pub"); allowed {
+		t.Error("CheckACL() should deny after the rule is deleted")
+	}
+
+	// Rename and password change
+	if err := db.UpdateMQTTUser(user.ID, "camera2", "", nil); err != nil {
+		t.Fatalf("UpdateMQTTUser() error = %v", err)
+	}
+	if _, err := db.GetMQTTUserByUsername("camera"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("old username still found after rename: %v", err)
+	}
+	if err := db.UpdateMQTTUserPassword(user.ID, "newpassword"); err != nil {
+		t.Fatalf("UpdateMQTTUserPassword() error = %v", err)
+	}
+	if _, err := db.AuthenticateMQTTUser("camera2", "newpassword"); err != nil {
+		t.Errorf("AuthenticateMQTTUser() with new password error = %v", err)
+	}
+
+	// Delete
+	if err := db.DeleteMQTTUser(user.ID); err != nil {
+		t.Fatalf("DeleteMQTTUser() error = %v", err)
+	}
+	if _, err := db.GetMQTTUserByUsername("camera2"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("deleted user still found: %v", err)
+	}
+}
+
+// BenchmarkAuthLookup compares the database and in-memory paths for the
+// lookups done on connect and publish. Password verification is left out:
+// bcrypt costs the same on both paths and would hide the difference
+func BenchmarkAuthLookup(b *testing.B) {
+	lookup := func(b *testing.B, db *DB) {
+		if _, err := db.GetMQTTUserByUsername("bench_user"); err != nil {
+			b.Fatalf("GetMQTTUserByUsername() error = %v", err)
+		}
+		if allowed, err := db.CheckACL("bench_user", "client-1", "sensors/room1/temp/c", "pub"); err != nil || !allowed {
+			b.Fatalf("CheckACL() = %v, %v; want true", allowed, err)
+		}
+	}
+
+	b.Run("database", func(b *testing.B) {
+		db := setupACLBenchmark(b, 100)
+		user, err := db.GetMQTTUserByUsername("bench_user")
+		if err != nil {
+			b.Fatalf("failed to get user: %v", err)
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			// Every lookup misses the cache, as for a client not seen recently
+			db.cache.DeleteMQTTUser("bench_user")
+			db.cache.DeleteACLRules(user.ID)
+			lookup(b, db)
+		}
+	})
+
+	b.Run("in-memory", func(b *testing.B) {
+		db := setupACLBenchmark(b, 100)
+		if err := db.EnableAuthSnapshot(); err != nil {
+			b.Fatalf("EnableAuthSnapshot() error = %v", err)
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			lookup(b, db)
+		}
+	})
+}
@@ -1,12 +1,34 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
+// ErrBridgeClientIDRequired is returned when cloning a bridge with a persistent
+// session without a client ID for the copy
+var ErrBridgeClientIDRequired = errors.New("the bridge keeps a persistent session, so its copy needs a client ID")
+
+// Retain policies for messages forwarded across a bridge
+const (
+	RetainPolicyPreserve = "preserve" // Forward the retain flag unchanged
+	RetainPolicyClear    = "clear"    // Always forward as non-retained
+)
+
+// validateBridgeDelivery checks a bridge's QoS cap and retain policy
+func validateBridgeDelivery(maxQoS *byte, retainPolicy string) error {
+	if maxQoS != nil && *maxQoS > 2 {
+		return fmt.Errorf("invalid max_qos: %d (must be 0, 1, or 2)", *maxQoS)
+	}
+	if retainPolicy != "" && retainPolicy != RetainPolicyPreserve && retainPolicy != RetainPolicyClear {
+		return fmt.Errorf("invalid retain_policy: %s (must be '%s' or '%s')", retainPolicy, RetainPolicyPreserve, RetainPolicyClear)
+	}
+	return nil
+}
+
 // CreateBridge creates a new MQTT bridge with its topic mappings
 func (db *DB) CreateBridge(
 	name, host string,
@@ -16,6 +38,8 @@ func (db *DB) CreateBridge(
 	mqttVersion string,
 	cleanSession bool,
 	keepAlive, connectionTimeout int,
+	maxQoS *byte,
+	retainPolicy string,
 	metadata datatypes.JSON,
 	topics []BridgeTopic,
 ) (*Bridge, error) {
@@ -32,6 +56,13 @@ func (db *DB) CreateBridge(
 		return nil, fmt.Errorf("invalid mqtt_version: %s (must be '3' or '5')", mqttVersion)
 	}
 
+	if err := validateBridgeDelivery(maxQoS, retainPolicy); err != nil {
+		return nil, err
+	}
+	if retainPolicy == "" {
+		retainPolicy = RetainPolicyPreserve
+	}
+
 	// Validate topics
 	for _, topic := range topics {
 		if topic.Local == "" || topic.Remote == "" {
@@ -53,12 +84,28 @@ func (db *DB) CreateBridge(
 		CleanSession:      cleanSession,
 		KeepAlive:         keepAlive,
 		ConnectionTimeout: connectionTimeout,
+		MaxQoS:            maxQoS,
+		RetainPolicy:      retainPolicy,
 		Metadata:          metadata,
 		Topics:            topics,
 	}
 
-	if err := db.Create(bridge).Error; err != nil {
-		return nil, fmt.Errorf("failed to create bridge: %w", err)
+	// One transaction, so a bridge is never stored with clean_session flipped to true
+	err := db.primary().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(bridge).Error; err != nil {
+			return fmt.Errorf("failed to create bridge: %w", err)
+		}
+
+		// GORM workaround: the default:true tag replaces a false clean_session on insert
+		if !cleanSession {
+			if err := tx.Model(bridge).Update("clean_session", false).Error; err != nil {
+				return fmt.Errorf("failed to set clean_session=false: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return bridge, nil
@@ -141,6 +188,8 @@ func (db *DB) UpdateBridge(
 	clientID string,
 	cleanSession bool,
 	keepAlive, connectionTimeout int,
+	maxQoS *byte,
+	retainPolicy string,
 	metadata datatypes.JSON,
 ) (*Bridge, error) {
 	bridge, err := db.GetBridge(id)
@@ -155,7 +204,7 @@ func (db *DB) UpdateBridge(
 	}
 
 	return db.updateBridgeInternal(id, name, host, port, username,
-		password, clientID, cleanSession, keepAlive, connectionTimeout, metadata)
+		password, clientID, cleanSession, keepAlive, connectionTimeout, maxQoS, retainPolicy, metadata)
 }
 
 // updateBridgeInternal performs the actual update without provisioning checks
@@ -168,6 +217,8 @@ func (db *DB) updateBridgeInternal(
 	clientID string,
 	cleanSession bool,
 	keepAlive, connectionTimeout int,
+	maxQoS *byte,
+	retainPolicy string,
 	metadata datatypes.JSON,
 ) (*Bridge, error) {
 	if name == "" || host == "" {
@@ -178,6 +229,13 @@ func (db *DB) updateBridgeInternal(
 		return nil, fmt.Errorf("invalid port: %d", port)
 	}
 
+	if err := validateBridgeDelivery(maxQoS, retainPolicy); err != nil {
+		return nil, err
+	}
+	if retainPolicy == "" {
+		retainPolicy = RetainPolicyPreserve
+	}
+
 	updates := map[string]interface{}{
 		"name":               name,
 		"host":               host,
@@ -188,6 +246,8 @@ func (db *DB) updateBridgeInternal(
 		"clean_session":      cleanSession,
 		"keep_alive":         keepAlive,
 		"connection_timeout": connectionTimeout,
+		"max_qos":            maxQoS,
+		"retain_policy":      retainPolicy,
 		"metadata":           metadata,
 	}
 
@@ -241,6 +301,48 @@ func (db *DB) UpdateBridgeTopics(id uint, topics []BridgeTopic) error {
 	})
 }
 
+// CloneBridge copies a bridge and its topic mappings under a new name
+// The copy is always a manual (non-provisioned) bridge. Two bridges sharing a
+// client ID would keep disconnecting each other on the remote broker, so the
+// source's client ID is not copied; pass clientID to set one for the copy. It is
+// required when the source keeps a persistent session (ErrBridgeClientIDRequired)
+func (db *DB) CloneBridge(id uint, name, clientID string) (*Bridge, error) {
+	source, err := db.GetBridge(id)
+	if err != nil {
+		return nil, err
+	}
+	if !source.CleanSession && clientID == "" {
+		return nil, ErrBridgeClientIDRequired
+	}
+
+	topics := make([]BridgeTopic, len(source.Topics))
+	for i, topic := range source.Topics {
+		topics[i] = BridgeTopic{
+			Local:     topic.Local,
+			Remote:    topic.Remote,
+			Direction: topic.Direction,
+			QoS:       topic.QoS,
+		}
+	}
+
+	return db.CreateBridge(
+		name,
+		source.Host,
+		source.Port,
+		source.Username,
+		source.Password,
+		clientID,
+		source.MQTTVersion,
+		source.CleanSession,
+		source.KeepAlive,
+		source.ConnectionTimeout,
+		source.MaxQoS,
+		source.RetainPolicy,
+		source.Metadata,
+		topics,
+	)
+}
+
 // DeleteBridge deletes a bridge and its topics (cascade)
 func (db *DB) DeleteBridge(id uint) error {
 	bridge, err := db.GetBridge(id)
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestCreateBridge_CleanSession(t *testing.T) {
+	db := setupTestDB(t)
+
+	for _, clean := range []bool{true, false} {
+		name := "clean"
+		if !clean {
+			name = "persistent"
+		}
+		bridge, err := db.CreateBridge(name, "remote.example.com", 1883, "", "", name, "5", clean, 60, 30, nil, "", nil, nil)
+		if err != nil {
+			t.Fatalf("CreateBridge(%s) error = %v", name, err)
+		}
+
+		got, err := db.GetBridge(bridge.ID)
+		if err != nil {
+			t.Fatalf("GetBridge(%s) error = %v", name, err)
+		}
+		if got.CleanSession != clean {
+			t.Errorf("%s bridge CleanSession = %v, want %v", name, got.CleanSession, clean)
+		}
+	}
+}
+
+func TestCreateBridge_CleanSessionRollsBack(t *testing.T) {
+	db := setupTestDB(t)
+
+	// Fail the clean_session=false update that follows the insert
+	if err := db.Callback().Update().Before("gorm:update").Register("test:fail_update", func(tx *gorm.DB) {
+		_ = tx.AddError(errors.New("update failed"))
+	}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	if _, err := db.CreateBridge("persistent", "remote.example.com", 1883, "", "", "persistent", "5", false, 60, 30, nil, "", nil, nil); err == nil {
+		t.Fatal("CreateBridge() error = nil, want the failed update")
+	}
+
+	var count int64
+	if err := db.Model(&Bridge{}).Count(&count).Error; err != nil {
+		t.Fatalf("Count() error = %v", err)
+	}
+	if count != 0 {
+		t.Errorf("bridges stored = %d, want 0: the insert must roll back with the update", count)
+	}
+}
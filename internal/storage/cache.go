@@ -14,11 +14,13 @@ import (
 type Cache struct {
 	users         sync.Map // map[string]*cachedUser - keyed by username
 	aclRules      sync.Map // map[uint]*cachedACLRules - keyed by mqtt_user_id
+	matchers      sync.Map // map[string]*topicMatcher - keyed by ACL topic pattern
 	metrics       *CacheMetrics
 	ttl           time.Duration
 	cleanupTicker *time.Ticker
 	stopChan      chan struct{}
 	stopOnce      sync.Once // Ensures stopChan is only closed once
+	onInvalidate  func()    // Called when user or ACL entries are invalidated (may be nil)
 }
 
 // cachedUser wraps an MQTT user with expiration time
@@ -35,10 +37,10 @@ type cachedACLRules struct {
 
 // CacheMetrics holds Prometheus metrics for cache operations
 type CacheMetrics struct {
-	hits       *prometheus.CounterVec
-	misses     *prometheus.CounterVec
-	size       *prometheus.GaugeVec
-	evictions  *prometheus.CounterVec
+	hits        *prometheus.CounterVec
+	misses      *prometheus.CounterVec
+	size        *prometheus.GaugeVec
+	evictions   *prometheus.CounterVec
 	expirations *prometheus.CounterVec
 }
 
@@ -242,6 +244,7 @@ func (c *Cache) SetMQTTUser(username string, user *MQTTUser) {
 
 // DeleteMQTTUser removes an MQTT user from cache
 func (c *Cache) DeleteMQTTUser(username string) {
+	c.notifyInvalidate()
 	c.users.Delete(username)
 	c.metrics.evictions.WithLabelValues("mqtt_user").Inc()
 	c.updateUserCacheSize()
@@ -282,6 +285,7 @@ func (c *Cache) SetACLRules(mqttUserID uint, rules []ACLRule) {
 
 // DeleteACLRules removes cached ACL rules for a user
 func (c *Cache) DeleteACLRules(mqttUserID uint) {
+	c.notifyInvalidate()
 	c.aclRules.Delete(mqttUserID)
 	c.metrics.evictions.WithLabelValues("acl_rules").Inc()
 	c.updateACLCacheSize()
@@ -289,10 +293,42 @@ func (c *Cache) DeleteACLRules(mqttUserID uint) {
 
 // InvalidateAllACLRules clears all cached ACL rules (used when any ACL rule changes)
 func (c *Cache) InvalidateAllACLRules() {
-	c.aclRules = sync.Map{}
+	c.notifyInvalidate()
+	c.aclRules.Clear()
+	c.matchers.Clear()
 	c.metrics.size.WithLabelValues("acl_rules").Set(0)
 }
 
+// GetTopicMatcher returns the compiled matcher for an ACL topic pattern,
+// compiling and caching it on first use
+func (c *Cache) GetTopicMatcher(pattern string) *topicMatcher {
+	if val, ok := c.matchers.Load(pattern); ok {
+		return val.(*topicMatcher)
+	}
+
+	// Rules stored before normalization was introduced may not be canonical
+	matcher := compileTopicPattern(NormalizeTopicPattern(pattern))
+	c.matchers.Store(pattern, matcher)
+	return matcher
+}
+
+// DeleteTopicMatcher drops a compiled pattern (used when a rule's pattern changes or is removed)
+func (c *Cache) DeleteTopicMatcher(pattern string) {
+	c.matchers.Delete(pattern)
+}
+
+// setInvalidateHook registers fn to run whenever user or ACL entries are invalidated
+func (c *Cache) setInvalidateHook(fn func()) {
+	c.onInvalidate = fn
+}
+
+// notifyInvalidate runs the invalidation hook, if any
+func (c *Cache) notifyInvalidate() {
+	if c.onInvalidate != nil {
+		c.onInvalidate()
+	}
+}
+
 // updateUserCacheSize updates the user cache size metric
 func (c *Cache) updateUserCacheSize() {
 	count := 0
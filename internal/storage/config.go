@@ -3,18 +3,38 @@ package storage
 import (
 	"fmt"
 	"strings"
+	"time"
+
+	"github/bromq-dev/bromq/internal/config"
 )
 
 // DatabaseConfig holds database connection configuration
 type DatabaseConfig struct {
-	Type     string `env:"DB_TYPE" flag:"db-type" default:"sqlite" desc:"Database type (sqlite, postgres, mysql)"`
-	FilePath string `env:"DB_PATH" flag:"db-path" default:"bromq.db" desc:"SQLite database file path"`
-	Host     string `env:"DB_HOST" flag:"db-host" default:"localhost" desc:"Database host (postgres/mysql)"`
-	Port     int    `env:"DB_PORT" flag:"db-port" desc:"Database port (postgres/mysql). Auto-detected if not set"`
-	User     string `env:"DB_USER" flag:"db-user" default:"mqtt" desc:"Database user (postgres/mysql)"`
-	Password string `env:"DB_PASSWORD" flag:"db-password" desc:"Database password (postgres/mysql)"`
-	DBName   string `env:"DB_NAME" flag:"db-name" default:"mqtt" desc:"Database name (postgres/mysql)"`
-	SSLMode  string `env:"DB_SSLMODE" flag:"db-sslmode" default:"disable" desc:"SSL mode for postgres (disable, require, verify-ca, verify-full)"`
+	Type         string `env:"DB_TYPE" flag:"db-type" default:"sqlite" desc:"Database type (sqlite, postgres, mysql)"`
+	FilePath     string `env:"DB_PATH" flag:"db-path" default:"bromq.db" desc:"SQLite database file path"`
+	Host         string `env:"DB_HOST" flag:"db-host" default:"localhost" desc:"Database host (postgres/mysql)"`
+	Port         int    `env:"DB_PORT" flag:"db-port" desc:"Database port (postgres/mysql). Auto-detected if not set"`
+	User         string `env:"DB_USER" flag:"db-user" default:"mqtt" desc:"Database user (postgres/mysql)"`
+	Password     string `env:"DB_PASSWORD" flag:"db-password" desc:"Database password (postgres/mysql)"`
+	PasswordFile string `env:"DB_PASSWORD_FILE" flag:"db-password-file" desc:"Read the database password from a file, e.g. a Docker secret (overrides DB_PASSWORD)"`
+	DBName       string `env:"DB_NAME" flag:"db-name" default:"mqtt" desc:"Database name (postgres/mysql)"`
+	SSLMode      string `env:"DB_SSLMODE" flag:"db-sslmode" default:"disable" desc:"SSL mode for postgres (disable, require, verify-ca, verify-full)"`
+
+	DisableAutoMigrate bool `env:"DB_DISABLE_AUTO_MIGRATE" flag:"db-disable-auto-migrate" desc:"Don't apply pending schema migrations at startup (run the migrate tool instead)"`
+
+	// Connection pool (postgres/mysql only - SQLite always uses a single connection)
+	MaxOpenConns    int           `env:"DB_MAX_OPEN_CONNS" flag:"db-max-open-conns" default:"25" desc:"Maximum open connections (0 = unlimited)"`
+	MaxIdleConns    int           `env:"DB_MAX_IDLE_CONNS" flag:"db-max-idle-conns" default:"5" desc:"Maximum idle connections kept in the pool"`
+	ConnMaxLifetime time.Duration `env:"DB_CONN_MAX_LIFETIME" flag:"db-conn-max-lifetime" default:"30m" desc:"Maximum time a connection may be reused (0 = forever)"`
+
+	// In-memory authentication (edge deployments)
+	AuthInMemory bool `env:"DB_AUTH_IN_MEMORY" flag:"db-auth-in-memory" desc:"Keep all MQTT users and ACL rules in memory and authenticate without database reads (reloaded after every user/ACL change; uses memory proportional to the user count)"`
+
+	// Slow query log (0 = disabled)
+	SlowQueryThreshold time.Duration `env:"DB_SLOW_QUERY_THRESHOLD" flag:"db-slow-query-threshold" default:"0" desc:"Log SQL statements that take at least this long, e.g. 200ms, with the query (without bound values) and elapsed time (0 = disabled)"`
+
+	// Optional read replica (reads go to the replica, writes to the primary)
+	ReplicaDSN string `env:"DB_REPLICA_DSN" flag:"db-replica-dsn" desc:"Read-replica connection string for the same database type (SQLite: file path). Empty = disabled"`
 }
 
 // DefaultSQLiteConfig returns default SQLite configuration
@@ -25,9 +45,16 @@ func DefaultSQLiteConfig(filePath string) *DatabaseConfig {
 	}
 }
 
-
 // PostParse applies defaults and validation after parsing
 func (c *DatabaseConfig) PostParse() error {
+	if c.PasswordFile != "" {
+		password, err := config.ReadSecretFile(c.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("DB_PASSWORD_FILE: %w", err)
+		}
+		c.Password = password
+	}
+
 	// Set default ports based on database type if not specified
 	if c.Port == 0 {
 		switch c.Type {
@@ -37,6 +64,23 @@ func (c *DatabaseConfig) PostParse() error {
 			c.Port = 3306
 		}
 	}
+
+	// Validate connection pool settings
+	if c.MaxOpenConns < 0 {
+		return fmt.Errorf("DB_MAX_OPEN_CONNS must be >= 0, got %d", c.MaxOpenConns)
+	}
+	if c.MaxIdleConns < 0 {
+		return fmt.Errorf("DB_MAX_IDLE_CONNS must be >= 0, got %d", c.MaxIdleConns)
+	}
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		return fmt.Errorf("DB_MAX_IDLE_CONNS (%d) must not exceed DB_MAX_OPEN_CONNS (%d)", c.MaxIdleConns, c.MaxOpenConns)
+	}
+	if c.ConnMaxLifetime < 0 {
+		return fmt.Errorf("DB_CONN_MAX_LIFETIME must be >= 0, got %s", c.ConnMaxLifetime)
+	}
+	if c.SlowQueryThreshold < 0 {
+		return fmt.Errorf("DB_SLOW_QUERY_THRESHOLD must be >= 0, got %s", c.SlowQueryThreshold)
+	}
 	return nil
 }
 
@@ -44,16 +88,7 @@ func (c *DatabaseConfig) PostParse() error {
 func (c *DatabaseConfig) ConnectionString() (string, error) {
 	switch c.Type {
 	case "sqlite":
-		// For in-memory databases (tests), no pragmas needed
-		if c.FilePath == ":memory:" || strings.HasPrefix(c.FilePath, "file::memory:") {
-			return c.FilePath, nil
-		}
-		// For file-based SQLite: Only enable foreign keys
-		// With MaxOpenConns=1:
-		// - WAL mode unnecessary (no concurrent readers)
-		// - busy_timeout unnecessary (no lock contention)
-		// - Simple DELETE mode = one file, easy backups
-		return c.FilePath + "?_pragma=foreign_keys(1)", nil
+		return sqliteConnectionString(c.FilePath), nil
 
 	case "postgres":
 		return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
@@ -67,3 +102,29 @@ func (c *DatabaseConfig) ConnectionString() (string, error) {
 		return "", fmt.Errorf("unsupported database type: %s (supported: sqlite, postgres, mysql)", c.Type)
 	}
 }
+
+// ReplicaConnectionString returns the read-replica connection string
+// For SQLite the replica DSN is a file path and gets the same pragmas as the primary
+func (c *DatabaseConfig) ReplicaConnectionString() (string, error) {
+	if c.ReplicaDSN == "" {
+		return "", fmt.Errorf("no read replica configured")
+	}
+	if c.Type == "sqlite" {
+		return sqliteConnectionString(c.ReplicaDSN), nil
+	}
+	return c.ReplicaDSN, nil
+}
+
+// sqliteConnectionString builds the SQLite DSN for a file path
+func sqliteConnectionString(filePath string) string {
+	// For in-memory databases (tests), no pragmas needed
+	if filePath == ":memory:" || strings.HasPrefix(filePath, "file::memory:") {
+		return filePath
+	}
+	// For file-based SQLite: Only enable foreign keys
+	// With MaxOpenConns=1:
+	// - WAL mode unnecessary (no concurrent readers)
+	// - busy_timeout unnecessary (no lock contention)
+	// - Simple DELETE mode = one file, easy backups
+	return filePath + "?_pragma=foreign_keys(1)"
+}
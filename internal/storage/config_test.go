@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDatabaseConfig_PostParsePool(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  DatabaseConfig
+		wantErr bool
+	}{
+		{
+			name:   "valid pool settings",
+			config: DatabaseConfig{Type: "postgres", MaxOpenConns: 25, MaxIdleConns: 5, ConnMaxLifetime: 30 * time.Minute},
+		},
+		{
+			name:   "unlimited open connections",
+			config: DatabaseConfig{Type: "postgres", MaxOpenConns: 0, MaxIdleConns: 10},
+		},
+		{
+			name:    "negative max open connections",
+			config:  DatabaseConfig{Type: "postgres", MaxOpenConns: -1},
+			wantErr: true,
+		},
+		{
+			name:    "negative max idle connections",
+			config:  DatabaseConfig{Type: "postgres", MaxIdleConns: -1},
+			wantErr: true,
+		},
+		{
+			name:    "idle exceeds open",
+			config:  DatabaseConfig{Type: "postgres", MaxOpenConns: 5, MaxIdleConns: 10},
+			wantErr: true,
+		},
+		{
+			name:    "negative lifetime",
+			config:  DatabaseConfig{Type: "postgres", ConnMaxLifetime: -time.Second},
+			wantErr: true,
+		},
+		{
+			name:    "negative slow query threshold",
+			config:  DatabaseConfig{Type: "postgres", SlowQueryThreshold: -time.Second},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.PostParse()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PostParse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigurePool(t *testing.T) {
+	db := setupTestDB(t)
+
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		t.Fatalf("failed to get sql.DB: %v", err)
+	}
+
+	configurePool(sqlDB, &DatabaseConfig{
+		Type:            "postgres",
+		MaxOpenConns:    7,
+		MaxIdleConns:    3,
+		ConnMaxLifetime: time.Minute,
+	})
+
+	if got := sqlDB.Stats().MaxOpenConnections; got != 7 {
+		t.Errorf("MaxOpenConnections = %d, want 7", got)
+	}
+}
+
+func TestDatabaseConfig_PasswordFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db_password")
+	if err := os.WriteFile(path, []byte("from-secret\n"), 0600); err != nil {
+		t.Fatalf("failed to write secret: %v", err)
+	}
+
+	config := DatabaseConfig{Type: "postgres", Password: "from-env", PasswordFile: path}
+	if err := config.PostParse(); err != nil {
+		t.Fatalf("PostParse() error = %v", err)
+	}
+	if config.Password != "from-secret" {
+		t.Errorf("Password = %q, want %q", config.Password, "from-secret")
+	}
+
+	config.PasswordFile = filepath.Join(t.TempDir(), "missing")
+	if err := config.PostParse(); err == nil {
+		t.Error("PostParse() with missing password file error = nil, want error")
+	}
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"fmt"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -168,3 +169,14 @@ func (db *DB) AuthenticateDashboardUser(username, password string) (*DashboardUs
 
 	return user, nil
 }
+
+// RecordDashboardLogin sets the user's last login time to now
+// UpdateColumn leaves updated_at alone, which tracks changes to the user itself
+func (db *DB) RecordDashboardLogin(user *DashboardUser) error {
+	now := time.Now()
+	if err := db.Model(user).UpdateColumn("last_login_at", now).Error; err != nil {
+		return fmt.Errorf("failed to record login: %w", err)
+	}
+	user.LastLoginAt = &now
+	return nil
+}
@@ -1,21 +1,32 @@
 package storage
 
 import (
+	"database/sql"
 	"fmt"
 	"log/slog"
 
+	"github/bromq-dev/bromq/internal/events"
+
 	sqlite "github.com/glebarez/sqlite" // Pure Go SQLite driver (no CGO required)
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
 )
 
 // DB wraps the GORM database connection with in-memory caching
 type DB struct {
 	*gorm.DB
-	cache *Cache
+	cache  *Cache
+	events *events.Bus // Optional bus for client table change events
+
+	authSnapshot *authSnapshot // Non-nil when MQTT auth is served from memory
+}
+
+// SetEventBus sets the event bus used to publish client table changes
+func (db *DB) SetEventBus(bus *events.Bus) {
+	db.events = bus
 }
 
 // Open creates a new database connection and runs auto-migrations
@@ -39,21 +50,14 @@ func OpenWithCache(config *DatabaseConfig, cache *Cache) (*DB, error) {
 	}
 
 	// Select appropriate GORM dialector based on database type
-	var dialector gorm.Dialector
-	switch config.Type {
-	case "sqlite":
-		dialector = sqlite.Open(dsn)
-	case "postgres":
-		dialector = postgres.Open(dsn)
-	case "mysql":
-		dialector = mysql.Open(dsn)
-	default:
-		return nil, fmt.Errorf("unsupported database type: %s", config.Type)
+	dialector, err := openDialector(config.Type, dsn)
+	if err != nil {
+		return nil, err
 	}
 
 	// Open database with GORM
 	gormDB, err := gorm.Open(dialector, &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Silent), // Reduce log noise
+		Logger: newGormLogger(config.SlowQueryThreshold),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
@@ -74,8 +78,8 @@ func OpenWithCache(config *DatabaseConfig, cache *Cache) (*DB, error) {
 		// - SQLite is used for auth/config (low write volume, cached reads)
 		// - High-write data will eventually move to BadgerDB
 		// - Single connection = zero lock contention, predictable behavior
-		sqlDB.SetMaxOpenConns(1)   // Single connection - no contention
-		sqlDB.SetMaxIdleConns(1)   // Keep one connection open
+		sqlDB.SetMaxOpenConns(1)    // Single connection - no contention
+		sqlDB.SetMaxIdleConns(1)    // Keep one connection open
 		sqlDB.SetConnMaxLifetime(0) // Reuse connection indefinitely (local file)
 
 		// Verify foreign keys are enabled (set via connection string)
@@ -89,10 +93,18 @@ func OpenWithCache(config *DatabaseConfig, cache *Cache) (*DB, error) {
 				return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
 			}
 		}
+	} else {
+		// Network databases (Postgres/MySQL) use the configured pool settings
+		configurePool(sqlDB, config)
+	}
+
+	// Route reads to the replica when one is configured (opt-in)
+	if config.ReplicaDSN != "" {
+		if err := useReadReplica(gormDB, config); err != nil {
+			return nil, err
+		}
+		slog.Info("Read replica enabled", "type", config.Type)
 	}
-	// Network databases (Postgres/MySQL) use Go's defaults:
-	// - MaxOpenConns: unlimited (database server handles limits)
-	// - MaxIdleConns: 2 (small pool for common case)
 
 	// Use provided cache or create a new one
 	if cache == nil {
@@ -104,33 +116,93 @@ func OpenWithCache(config *DatabaseConfig, cache *Cache) (*DB, error) {
 		cache: cache,
 	}
 
-	// Run auto-migrations (GORM handles all schema changes)
-	if err := storage.autoMigrate(); err != nil {
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	// Apply pending schema migrations unless operators run them explicitly (cmd/migrate)
+	pending := 0
+	if !config.DisableAutoMigrate {
+		if _, err := storage.Migrate(); err != nil {
+			return nil, fmt.Errorf("failed to run migrations: %w", err)
+		}
+	} else {
+		if pending, err = storage.PendingMigrations(); err != nil {
+			return nil, fmt.Errorf("failed to check migrations: %w", err)
+		}
+		if pending > 0 {
+			slog.Warn("Database has pending migrations; run the migrate tool with --up", "pending", pending)
+		}
 	}
 
 	// Warm cache with MQTT users and ACL rules for performance
-	if err := storage.warmCache(); err != nil {
-		slog.Warn("Failed to warm cache", "error", err)
+	// (skipped while migrations are pending since tables may not exist yet)
+	if pending == 0 {
+		if config.AuthInMemory {
+			if err := storage.EnableAuthSnapshot(); err != nil {
+				return nil, fmt.Errorf("failed to load in-memory auth snapshot: %w", err)
+			}
+		} else if err := storage.warmCache(); err != nil {
+			slog.Warn("Failed to warm cache", "error", err)
+		}
 	}
 
 	slog.Info("Database connected successfully", "type", config.Type)
 	return storage, nil
 }
 
-// autoMigrate runs GORM's auto-migration for all models
-func (db *DB) autoMigrate() error {
-	return db.AutoMigrate(
-		&DashboardUser{},
-		&MQTTUser{},
-		&MQTTClient{},
-		&ACLRule{},
-		&Bridge{},
-		&BridgeTopic{},
-		&Script{},
-		&ScriptTrigger{},
-		// Note: RetainedMessage, ScriptLog, and ScriptState now stored in BadgerDB for better write performance
-	)
+// openDialector returns the GORM dialector for a database type
+func openDialector(dbType, dsn string) (gorm.Dialector, error) {
+	switch dbType {
+	case "sqlite":
+		return sqlite.Open(dsn), nil
+	case "postgres":
+		return postgres.Open(dsn), nil
+	case "mysql":
+		return mysql.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s", dbType)
+	}
+}
+
+// useReadReplica registers the replica with GORM's dbresolver so that
+// SELECT queries go to the replica while writes and transactions stay on the primary
+func useReadReplica(gormDB *gorm.DB, config *DatabaseConfig) error {
+	replicaDSN, err := config.ReplicaConnectionString()
+	if err != nil {
+		return err
+	}
+
+	replica, err := openDialector(config.Type, replicaDSN)
+	if err != nil {
+		return err
+	}
+
+	resolver := dbresolver.Register(dbresolver.Config{
+		Replicas: []gorm.Dialector{replica},
+	})
+
+	// Mirror the primary's pool settings on the replica
+	if config.Type == "sqlite" {
+		resolver.SetMaxOpenConns(1).SetMaxIdleConns(1).SetConnMaxLifetime(0)
+	} else {
+		resolver.SetMaxOpenConns(config.MaxOpenConns).
+			SetMaxIdleConns(config.MaxIdleConns).
+			SetConnMaxLifetime(config.ConnMaxLifetime)
+	}
+
+	if err := gormDB.Use(resolver); err != nil {
+		return fmt.Errorf("failed to configure read replica: %w", err)
+	}
+	return nil
+}
+
+// configurePool applies the connection pool settings to a network database
+func configurePool(sqlDB *sql.DB, config *DatabaseConfig) {
+	sqlDB.SetMaxOpenConns(config.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(config.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
+
+	slog.Debug("Database connection pool configured",
+		"max_open", config.MaxOpenConns,
+		"max_idle", config.MaxIdleConns,
+		"max_lifetime", config.ConnMaxLifetime)
 }
 
 // CreateDefaultAdmin creates a default admin user on first run
@@ -192,10 +264,11 @@ func (db *DB) Close() error {
 
 // warmCache pre-loads MQTT users and ACL rules into the cache for performance
 // This prevents cache misses on startup and ensures the hot path is fast
+// Reads go to the primary, since a lagging replica would cache stale credentials
 func (db *DB) warmCache() error {
 	// Load all MQTT users
 	var users []MQTTUser
-	if err := db.Find(&users).Error; err != nil {
+	if err := db.primary().Find(&users).Error; err != nil {
 		return fmt.Errorf("failed to load MQTT users for cache: %w", err)
 	}
 	db.cache.WarmMQTTUsers(users)
@@ -203,7 +276,7 @@ func (db *DB) warmCache() error {
 
 	// Load all ACL rules
 	var rules []ACLRule
-	if err := db.Find(&rules).Error; err != nil {
+	if err := db.primary().Find(&rules).Error; err != nil {
 		return fmt.Errorf("failed to load ACL rules for cache: %w", err)
 	}
 	db.cache.WarmACLRules(rules)
@@ -211,3 +284,72 @@ func (db *DB) warmCache() error {
 
 	return nil
 }
+
+// ResourceCounts holds the number of resources of each kind, for the dashboard summary
+type ResourceCounts struct {
+	MQTTUsers      int64 `json:"mqtt_users"`
+	Clients        int64 `json:"clients"`
+	ActiveClients  int64 `json:"active_clients"`
+	ACLRules       int64 `json:"acl_rules"`
+	Bridges        int64 `json:"bridges"`
+	Scripts        int64 `json:"scripts"`
+	EnabledScripts int64 `json:"enabled_scripts"`
+}
+
+// CountResources counts every kind of resource with one COUNT query each
+func (db *DB) CountResources() (*ResourceCounts, error) {
+	counts := &ResourceCounts{}
+	targets := []struct {
+		model any
+		where string
+		count *int64
+	}{
+		{&MQTTUser{}, "", &counts.MQTTUsers},
+		{&MQTTClient{}, "", &counts.Clients},
+		{&MQTTClient{}, "is_active = ?", &counts.ActiveClients},
+		{&ACLRule{}, "", &counts.ACLRules},
+		{&Bridge{}, "", &counts.Bridges},
+		{&Script{}, "", &counts.Scripts},
+		{&Script{}, "enabled = ?", &counts.EnabledScripts},
+	}
+
+	for _, target := range targets {
+		query := db.Model(target.model)
+		if target.where != "" {
+			query = query.Where(target.where, true)
+		}
+		if err := query.Count(target.count).Error; err != nil {
+			return nil, fmt.Errorf("failed to count resources: %w", err)
+		}
+	}
+	return counts, nil
+}
+
+// ProvisionedCounts holds the number of resources managed by the config file
+type ProvisionedCounts struct {
+	MQTTUsers int64 `json:"mqtt_users"`
+	ACLRules  int64 `json:"acl_rules"`
+	Bridges   int64 `json:"bridges"`
+	Scripts   int64 `json:"scripts"`
+}
+
+// CountProvisioned counts resources provisioned from the config file
+func (db *DB) CountProvisioned() (*ProvisionedCounts, error) {
+	counts := &ProvisionedCounts{}
+	targets := []struct {
+		model any
+		count *int64
+	}{
+		{&MQTTUser{}, &counts.MQTTUsers},
+		{&ACLRule{}, &counts.ACLRules},
+		{&Bridge{}, &counts.Bridges},
+		{&Script{}, &counts.Scripts},
+	}
+
+	for _, target := range targets {
+		if err := db.Model(target.model).Where("provisioned_from_config = ?", true).Count(target.count).Error; err != nil {
+			return nil, fmt.Errorf("failed to count provisioned resources: %w", err)
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// CompactResult describes what a database compaction did
+type CompactResult struct {
+	Operation  string `json:"operation"`             // "vacuum", "analyze", or "none"
+	FreedBytes *int64 `json:"freed_bytes,omitempty"` // Only reported where file size can be measured (SQLite)
+}
+
+// Compact reclaims space left behind by deleted rows
+// SQLite is rebuilt with VACUUM, PostgreSQL refreshes planner statistics with ANALYZE
+// (autovacuum already reclaims space), and MySQL is left alone
+func (db *DB) Compact() (*CompactResult, error) {
+	primary := db.primary()
+
+	switch primary.Dialector.Name() {
+	case "sqlite":
+		before, err := db.sqliteSize()
+		if err != nil {
+			return nil, err
+		}
+		if err := primary.Exec("VACUUM").Error; err != nil {
+			return nil, fmt.Errorf("failed to vacuum database: %w", err)
+		}
+		after, err := db.sqliteSize()
+		if err != nil {
+			return nil, err
+		}
+
+		freed := max(before-after, 0)
+		slog.Info("Database vacuumed", "freed_bytes", freed)
+		return &CompactResult{Operation: "vacuum", FreedBytes: &freed}, nil
+
+	case "postgres":
+		if err := primary.Exec("ANALYZE").Error; err != nil {
+			return nil, fmt.Errorf("failed to analyze database: %w", err)
+		}
+		slog.Info("Database analyzed")
+		return &CompactResult{Operation: "analyze"}, nil
+
+	default:
+		return &CompactResult{Operation: "none"}, nil
+	}
+}
+
+// sqliteSize returns the size of the SQLite database in bytes
+func (db *DB) sqliteSize() (int64, error) {
+	var pageCount, pageSize int64
+	if err := db.primary().Raw("PRAGMA page_count").Scan(&pageCount).Error; err != nil {
+		return 0, fmt.Errorf("failed to read page count: %w", err)
+	}
+	if err := db.primary().Raw("PRAGMA page_size").Scan(&pageSize).Error; err != nil {
+		return 0, fmt.Errorf("failed to read page size: %w", err)
+	}
+	return pageCount * pageSize, nil
+}
@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// SchemaMigration records a migration that has been applied to the database
+type SchemaMigration struct {
+	Version   uint      `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	Name      string    `gorm:"not null" json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// TableName specifies the table name for SchemaMigration
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// migration is a single versioned schema change
+type migration struct {
+	version uint
+	name    string
+	up      func(tx *gorm.DB) error
+}
+
+// migrations lists all schema changes in order
+// Append new entries with the next version number; never edit or reorder applied ones
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "initial_schema",
+		up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(
+				&DashboardUser{},
+				&MQTTUser{},
+				&MQTTClient{},
+				&ACLRule{},
+				&Bridge{},
+				&BridgeTopic{},
+				&Script{},
+				&ScriptTrigger{},
+				// Note: RetainedMessage, ScriptLog, and ScriptState now stored in BadgerDB for better write performance
+			)
+		},
+	},
+	{
+		// Speeds up re-provisioning, which deletes a user's config-managed rules
+		// (per-user lookups in CheckACL already use idx_acl_user_topic)
+		version: 2,
+		name:    "acl_user_provisioned_index",
+		up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasIndex(&ACLRule{}, "idx_acl_user_provisioned") {
+				return nil
+			}
+			return tx.Migrator().CreateIndex(&ACLRule{}, "idx_acl_user_provisioned")
+		},
+	},
+	{
+		version: 3,
+		name:    "bridge_delivery_policy",
+		up: func(tx *gorm.DB) error {
+			for _, column := range []string{"MaxQoS", "RetainPolicy"} {
+				if tx.Migrator().HasColumn(&Bridge{}, column) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&Bridge{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version: 4,
+		name:    "script_versions",
+		up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&ScriptVersion{})
+		},
+	},
+	{
+		version: 5,
+		name:    "mqtt_client_connect_info",
+		up: func(tx *gorm.DB) error {
+			for _, column := range []string{"ProtocolVersion", "CleanStart", "Keepalive", "RemoteIP"} {
+				if tx.Migrator().HasColumn(&MQTTClient{}, column) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&MQTTClient{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version: 6,
+		name:    "resource_versions",
+		up: func(tx *gorm.DB) error {
+			for _, model := range []interface{}{&MQTTUser{}, &ACLRule{}} {
+				if tx.Migrator().HasColumn(model, "Version") {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(model, "Version"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version: 7,
+		name:    "mqtt_user_ip_filter",
+		up: func(tx *gorm.DB) error {
+			for _, column := range []string{"AllowedCIDRs", "DeniedCIDRs"} {
+				if tx.Migrator().HasColumn(&MQTTUser{}, column) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&MQTTUser{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		version: 8,
+		name:    "acl_rule_retain",
+		up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&ACLRule{}, "Retain") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&ACLRule{}, "Retain")
+		},
+	},
+	{
+		version: 9,
+		name:    "retained_messages",
+		up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&RetainedMessage{})
+		},
+	},
+	{
+		version: 10,
+		name:    "dashboard_user_last_login",
+		up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&DashboardUser{}, "LastLoginAt") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&DashboardUser{}, "LastLoginAt")
+		},
+	},
+	{
+		version: 11,
+		name:    "settings",
+		up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&Setting{})
+		},
+	},
+	{
+		version: 12,
+		name:    "topic_routes",
+		up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&TopicRoute{})
+		},
+	},
+}
+
+// MigrationStatus describes whether a known migration has been applied
+type MigrationStatus struct {
+	Version   uint       `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// MigrationStatus returns the state of every known migration
+func (db *DB) MigrationStatus() ([]MigrationStatus, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		s := MigrationStatus{Version: m.version, Name: m.name}
+		if record, ok := applied[m.version]; ok {
+			s.Applied = true
+			s.AppliedAt = &record.AppliedAt
+		}
+		status = append(status, s)
+	}
+
+	return status, nil
+}
+
+// PendingMigrations returns the number of migrations not yet applied
+func (db *DB) PendingMigrations() (int, error) {
+	status, err := db.MigrationStatus()
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, s := range status {
+		if !s.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// Migrate applies all pending migrations in version order
+// Each migration runs in its own transaction together with its schema_migrations record
+// Returns the number of migrations applied
+func (db *DB) Migrate() (int, error) {
+	if err := db.primary().AutoMigrate(&SchemaMigration{}); err != nil {
+		return 0, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.version]; ok {
+			continue
+		}
+
+		err := db.primary().Transaction(func(tx *gorm.DB) error {
+			if err := m.up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   m.version,
+				Name:      m.name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+
+		slog.Info("Applied database migration", "version", m.version, "name", m.name)
+		count++
+	}
+
+	return count, nil
+}
+
+// appliedMigrations loads applied migrations keyed by version
+// A missing schema_migrations table means nothing has been applied yet
+func (db *DB) appliedMigrations() (map[uint]SchemaMigration, error) {
+	applied := make(map[uint]SchemaMigration)
+	if !db.primary().Migrator().HasTable(&SchemaMigration{}) {
+		return applied, nil
+	}
+
+	var records []SchemaMigration
+	if err := db.primary().Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to load schema migrations: %w", err)
+	}
+
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// primary returns a session pinned to the primary database, so schema checks
+// never read from a read replica
+func (db *DB) primary() *gorm.DB {
+	return db.Clauses(dbresolver.Write)
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMigrateFreshDatabase(t *testing.T) {
+	config := DefaultSQLiteConfig(":memory:")
+	config.DisableAutoMigrate = true
+
+	db, err := OpenWithCache(config, NewCacheWithRegistry(prometheus.NewRegistry()))
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	// Nothing is created until migrations run
+	if db.Migrator().HasTable(&MQTTUser{}) {
+		t.Fatal("mqtt_users exists before migrating")
+	}
+	pending, err := db.PendingMigrations()
+	if err != nil {
+		t.Fatalf("PendingMigrations() error = %v", err)
+	}
+	if pending != len(migrations) {
+		t.Errorf("PendingMigrations() = %d, want %d", pending, len(migrations))
+	}
+
+	applied, err := db.Migrate()
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("Migrate() applied = %d, want %d", applied, len(migrations))
+	}
+
+	wantTables := []string{
+		"schema_migrations",
+		"dashboard_users",
+		"mqtt_users",
+		"mqtt_clients",
+		"acl_rules",
+		"bridges",
+		"bridge_topics",
+		"scripts",
+		"script_triggers",
+	}
+	for _, table := range wantTables {
+		if !db.Migrator().HasTable(table) {
+			t.Errorf("table %s missing after Migrate()", table)
+		}
+	}
+
+	status, err := db.MigrationStatus()
+	if err != nil {
+		t.Fatalf("MigrationStatus() error = %v", err)
+	}
+	for _, s := range status {
+		if !s.Applied || s.AppliedAt == nil {
+			t.Errorf("migration %d (%s) not recorded as applied", s.Version, s.Name)
+		}
+	}
+
+	// Running again is a no-op
+	applied, err = db.Migrate()
+	if err != nil {
+		t.Fatalf("second Migrate() error = %v", err)
+	}
+	if applied != 0 {
+		t.Errorf("second Migrate() applied = %d, want 0", applied)
+	}
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"sync/atomic"
 	"time"
 
 	"gorm.io/datatypes"
@@ -14,6 +15,7 @@ type DashboardUser struct {
 	PasswordHash string         `gorm:"not null" json:"-"` // Never expose password hash in JSON
 	Role         string         `gorm:"not null;default:viewer" json:"role"`
 	Metadata     datatypes.JSON `gorm:"type:jsonb" json:"metadata,omitempty"` // Custom attributes
+	LastLoginAt  *time.Time     `json:"last_login_at,omitempty"`              // Last successful dashboard login
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
 }
@@ -25,14 +27,21 @@ func (DashboardUser) TableName() string {
 
 // MQTTUser represents MQTT authentication credentials (can be shared by multiple devices)
 type MQTTUser struct {
-	ID                   uint           `gorm:"primaryKey" json:"id"`
-	Username             string         `gorm:"uniqueIndex;not null" json:"username"`
-	PasswordHash         string         `gorm:"not null" json:"-"` // Never expose password hash in JSON
-	Description          string         `gorm:"type:text" json:"description"`
-	Metadata             datatypes.JSON `gorm:"type:jsonb" json:"metadata,omitempty"` // Custom attributes
-	ProvisionedFromConfig bool          `gorm:"default:false" json:"provisioned_from_config"` // Managed by config file
-	CreatedAt            time.Time      `json:"created_at"`
-	UpdatedAt            time.Time      `json:"updated_at"`
+	ID                    uint                        `gorm:"primaryKey" json:"id"`
+	Username              string                      `gorm:"uniqueIndex;not null" json:"username"`
+	PasswordHash          string                      `gorm:"not null" json:"-"` // Never expose password hash in JSON
+	Description           string                      `gorm:"type:text" json:"description"`
+	Metadata              datatypes.JSON              `gorm:"type:jsonb" json:"metadata,omitempty"`                // Custom attributes
+	AllowedCIDRs          datatypes.JSONSlice[string] `gorm:"column:allowed_cidrs" json:"allowed_cidrs,omitempty"` // Source networks allowed to connect (empty = any)
+	DeniedCIDRs           datatypes.JSONSlice[string] `gorm:"column:denied_cidrs" json:"denied_cidrs,omitempty"`   // Source networks always rejected
+	ProvisionedFromConfig bool                        `gorm:"default:false" json:"provisioned_from_config"`        // Managed by config file
+	Version               uint                        `gorm:"not null;default:1" json:"version"`                   // Incremented on every update (optimistic concurrency)
+	CreatedAt             time.Time                   `json:"created_at"`
+	UpdatedAt             time.Time                   `json:"updated_at"`
+
+	// ipFilter holds the auth hook's parsed AllowedCIDRs and DeniedCIDRs. Cached
+	// users are replaced rather than modified when the lists change
+	ipFilter atomic.Value
 }
 
 // TableName specifies the table name for MQTTUser model
@@ -45,6 +54,22 @@ func (u *MQTTUser) GetID() uint {
 	return u.ID
 }
 
+// IPRanges returns the user's source address lists for the auth hook
+func (u *MQTTUser) IPRanges() (allow, deny []string) {
+	return u.AllowedCIDRs, u.DeniedCIDRs
+}
+
+// LoadIPFilter returns the filter the auth hook parsed from IPRanges, if any
+func (u *MQTTUser) LoadIPFilter() (any, bool) {
+	filter := u.ipFilter.Load()
+	return filter, filter != nil
+}
+
+// StoreIPFilter keeps the filter the auth hook parsed from IPRanges
+func (u *MQTTUser) StoreIPFilter(filter any) {
+	u.ipFilter.Store(filter)
+}
+
 // MQTTClient represents an individual MQTT device/client connection
 // Multiple clients can use the same MQTTUser credentials
 type MQTTClient struct {
@@ -55,9 +80,18 @@ type MQTTClient struct {
 	FirstSeen  time.Time      `gorm:"not null" json:"first_seen"`
 	LastSeen   time.Time      `gorm:"not null" json:"last_seen"`
 	IsActive   bool           `gorm:"default:false" json:"is_active"` // Currently connected
-	CreatedAt  time.Time      `json:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at"`
-	MQTTUser   MQTTUser       `gorm:"foreignKey:MQTTUserID;constraint:OnDelete:CASCADE" json:"-"`
+	ConnectInfo
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	MQTTUser  MQTTUser  `gorm:"foreignKey:MQTTUserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// ConnectInfo is what a client reported in its most recent CONNECT packet
+type ConnectInfo struct {
+	ProtocolVersion uint8  `json:"protocol_version,omitempty"` // 3, 4 (v3.1.1) or 5
+	CleanStart      bool   `json:"clean_start"`
+	Keepalive       uint16 `json:"keepalive"` // Seconds, as granted by the broker
+	RemoteIP        string `json:"remote_ip,omitempty"`
 }
 
 // TableName specifies the table name for MQTTClient model
@@ -69,10 +103,12 @@ func (MQTTClient) TableName() string {
 // Rules are associated with MQTTUser (credentials), not individual clients
 type ACLRule struct {
 	ID                    uint      `gorm:"primaryKey" json:"id"`
-	MQTTUserID            uint      `gorm:"uniqueIndex:idx_acl_user_topic;not null" json:"mqtt_user_id"`
+	MQTTUserID            uint      `gorm:"uniqueIndex:idx_acl_user_topic;index:idx_acl_user_provisioned;not null" json:"mqtt_user_id"`
 	Topic                 string    `gorm:"uniqueIndex:idx_acl_user_topic;not null" json:"topic"`
 	Permission            string    `gorm:"not null;check:permission IN ('pub', 'sub', 'pubsub')" json:"permission"`
-	ProvisionedFromConfig bool      `gorm:"default:false" json:"provisioned_from_config"` // Managed by config file
+	Retain                bool      `gorm:"not null;default:false" json:"retain"`                                        // May publish retained messages (enforced with MQTT_RETAIN_REQUIRES_PERMISSION)
+	ProvisionedFromConfig bool      `gorm:"default:false;index:idx_acl_user_provisioned" json:"provisioned_from_config"` // Managed by config file
+	Version               uint      `gorm:"not null;default:1" json:"version"`                                           // Incremented on every update (optimistic concurrency)
 	CreatedAt             time.Time `json:"created_at"`
 	MQTTUser              MQTTUser  `gorm:"foreignKey:MQTTUserID;constraint:OnDelete:CASCADE" json:"-"`
 }
@@ -112,9 +148,11 @@ type Bridge struct {
 	Password              string         `gorm:"default:''" json:"-"` // Plain text, needed for outbound connections
 	ClientID              string         `gorm:"default:''" json:"client_id"`
 	MQTTVersion           string         `gorm:"default:'5';check:mqtt_version IN ('3', '5')" json:"mqtt_version"` // MQTT protocol version: "3" (3.1.1) or "5"
-	CleanSession          bool           `gorm:"default:true" json:"clean_session"`                                 // v3: CleanSession, v5: CleanStart
-	KeepAlive             int            `gorm:"default:60" json:"keep_alive"`                                      // seconds
-	ConnectionTimeout     int            `gorm:"default:30" json:"connection_timeout"`                              // seconds
+	CleanSession          bool           `gorm:"default:true" json:"clean_session"`                                // v3: CleanSession, v5: CleanStart
+	KeepAlive             int            `gorm:"default:60" json:"keep_alive"`                                     // seconds
+	ConnectionTimeout     int            `gorm:"default:30" json:"connection_timeout"`                             // seconds
+	MaxQoS                *byte          `json:"max_qos,omitempty"`                                                // Caps forwarded QoS in both directions (nil = no cap)
+	RetainPolicy          string         `gorm:"default:'preserve'" json:"retain_policy"`                          // "preserve" or "clear" the retain flag on forwarded messages
 	ProvisionedFromConfig bool           `gorm:"default:false" json:"provisioned_from_config"`
 	Metadata              datatypes.JSON `gorm:"type:jsonb" json:"metadata,omitempty"`
 	CreatedAt             time.Time      `json:"created_at"`
@@ -178,3 +216,70 @@ type ScriptTrigger struct {
 func (ScriptTrigger) TableName() string {
 	return "script_triggers"
 }
+
+// RetainedMessage is a retained MQTT message, stored here only with the sql retained
+// backend (MQTT_RETAINED_BACKEND=sql); by default retained messages live in BadgerDB
+type RetainedMessage struct {
+	Topic     string    `gorm:"primaryKey;size:512" json:"topic"`
+	Payload   []byte    `json:"payload"`
+	QoS       byte      `gorm:"column:qos;not null;default:0" json:"qos"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for RetainedMessage model
+func (RetainedMessage) TableName() string {
+	return "retained_messages"
+}
+
+// TopicRoute republishes messages published on one local topic to another,
+// e.g. mirroring legacy/# to v2/#, without a bridge
+type TopicRoute struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Source    string    `gorm:"not null;size:512" json:"source"` // Topic filter, e.g. legacy/#
+	Target    string    `gorm:"not null;size:512" json:"target"` // Wildcards take the levels the source's matched, e.g. v2/#
+	Enabled   bool      `gorm:"not null" json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for TopicRoute model
+func (TopicRoute) TableName() string {
+	return "topic_routes"
+}
+
+// Setting is a server setting changed at runtime from the dashboard, stored as a key-value pair
+type Setting struct {
+	Key       string    `gorm:"primaryKey;size:191" json:"key"`
+	Value     string    `gorm:"type:text;not null" json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for Setting model
+func (Setting) TableName() string {
+	return "settings"
+}
+
+// ScriptVersion is a snapshot of a script's code and triggers taken before an update
+type ScriptVersion struct {
+	ID          uint                                      `gorm:"primaryKey" json:"id"`
+	ScriptID    uint                                      `gorm:"not null;uniqueIndex:idx_script_version" json:"script_id"`
+	Version     int                                       `gorm:"not null;uniqueIndex:idx_script_version" json:"version"` // Increases with each update
+	Description string                                    `gorm:"type:text" json:"description"`
+	Content     string                                    `gorm:"type:text;not null" json:"content"`
+	Triggers    datatypes.JSONSlice[ScriptVersionTrigger] `json:"triggers"`
+	CreatedAt   time.Time                                 `json:"created_at"`
+}
+
+// TableName specifies the table name for ScriptVersion model
+func (ScriptVersion) TableName() string {
+	return "script_versions"
+}
+
+// ScriptVersionTrigger is a trigger as stored in a script version
+type ScriptVersionTrigger struct {
+	Type     string `json:"type"`
+	Topic    string `json:"topic"`
+	Priority int    `json:"priority"`
+	Enabled  bool   `json:"enabled"`
+}
@@ -1,15 +1,32 @@
 package storage
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
+	"github/bromq-dev/bromq/internal/events"
+
 	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
 // UpsertMQTTClient creates or updates an MQTT client record
 // Used when a client connects to track first/last seen times
 func (db *DB) UpsertMQTTClient(clientID string, mqttUserID uint, metadata datatypes.JSON) (*MQTTClient, error) {
+	return db.upsertMQTTClient(clientID, mqttUserID, metadata, nil)
+}
+
+// UpsertMQTTClientConnect is UpsertMQTTClient for a fresh connection, also
+// recording what the client sent in its CONNECT packet
+func (db *DB) UpsertMQTTClientConnect(clientID string, mqttUserID uint, info ConnectInfo) (*MQTTClient, error) {
+	return db.upsertMQTTClient(clientID, mqttUserID, nil, &info)
+}
+
+// upsertMQTTClient creates or updates a client, replacing its connect info when given
+func (db *DB) upsertMQTTClient(clientID string, mqttUserID uint, metadata datatypes.JSON, info *ConnectInfo) (*MQTTClient, error) {
 	var client MQTTClient
 	now := time.Now()
 
@@ -25,10 +42,14 @@ func (db *DB) UpsertMQTTClient(clientID string, mqttUserID uint, metadata dataty
 			LastSeen:   now,
 			IsActive:   true,
 		}
+		if info != nil {
+			client.ConnectInfo = *info
+		}
 
 		if err := db.Create(&client).Error; err != nil {
 			return nil, fmt.Errorf("failed to create MQTT client: %w", err)
 		}
+		db.events.Publish(events.ClientAdded, map[string]interface{}{"client": client})
 	} else {
 		// Client exists, update last seen and active status
 		updates := map[string]interface{}{
@@ -46,16 +67,127 @@ func (db *DB) UpsertMQTTClient(clientID string, mqttUserID uint, metadata dataty
 			updates["metadata"] = metadata
 		}
 
+		if info != nil {
+			setConnectInfo(updates, *info)
+		}
+
 		if err := db.Model(&client).Updates(updates).Error; err != nil {
 			return nil, fmt.Errorf("failed to update MQTT client: %w", err)
 		}
+		db.events.Publish(events.ClientUpdated, map[string]interface{}{"client": client})
 	}
 
 	return &client, nil
 }
 
+// ClientUpdate is the coalesced connection state of one client, applied in bulk
+// by ApplyMQTTClientUpdates
+type ClientUpdate struct {
+	ClientID   string
+	MQTTUserID uint      // Only used when Connected is true
+	Connected  bool      // Client connected (with credentials) at least once in the batch
+	Active     bool      // Final connection state
+	FirstSeen  time.Time // Earliest connect in the batch (used when creating the record)
+	LastSeen   time.Time
+	Info       *ConnectInfo           // Latest connect info in the batch (nil = unchanged)
+	Metadata   map[string]interface{} // Keys merged into the client's metadata (nil = unchanged)
+}
+
+// setConnectInfo adds the connect info columns to an update map
+// Map updates are used so zero values (e.g. clean_start=false) are written too
+func setConnectInfo(updates map[string]interface{}, info ConnectInfo) {
+	updates["protocol_version"] = info.ProtocolVersion
+	updates["clean_start"] = info.CleanStart
+	updates["keepalive"] = info.Keepalive
+	updates["remote_ip"] = info.RemoteIP
+}
+
+// ApplyMQTTClientUpdates writes a batch of client connection changes in one transaction
+// New clients are created with the batch's FirstSeen; existing records keep theirs.
+// Disconnects for clients without a record (e.g. anonymous) are ignored.
+func (db *DB) ApplyMQTTClientUpdates(updates []ClientUpdate) error {
+	var added, changed []MQTTClient
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, u := range updates {
+			var client MQTTClient
+			err := tx.Where("client_id = ?", u.ClientID).First(&client).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				if !u.Connected {
+					continue
+				}
+
+				client = MQTTClient{
+					ClientID:   u.ClientID,
+					MQTTUserID: u.MQTTUserID,
+					FirstSeen:  u.FirstSeen,
+					LastSeen:   u.LastSeen,
+					IsActive:   u.Active,
+				}
+				if u.Info != nil {
+					client.ConnectInfo = *u.Info
+				}
+				if u.Metadata != nil {
+					merged, err := mergeMetadata(nil, u.Metadata)
+					if err != nil {
+						return fmt.Errorf("failed to build metadata for MQTT client %s: %w", u.ClientID, err)
+					}
+					client.Metadata = merged
+				}
+				if err := tx.Create(&client).Error; err != nil {
+					return fmt.Errorf("failed to create MQTT client %s: %w", u.ClientID, err)
+				}
+				added = append(added, client)
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to load MQTT client %s: %w", u.ClientID, err)
+			}
+
+			fields := map[string]interface{}{
+				"last_seen": u.LastSeen,
+				"is_active": u.Active,
+			}
+			if u.Connected && client.MQTTUserID != u.MQTTUserID {
+				fields["mqtt_user_id"] = u.MQTTUserID
+			}
+			if u.Info != nil {
+				setConnectInfo(fields, *u.Info)
+			}
+			if u.Metadata != nil {
+				// A client whose metadata can't be merged keeps it; the rest of the batch still applies
+				if merged, err := mergeMetadata(client.Metadata, u.Metadata); err != nil {
+					slog.Warn("Leaving MQTT client metadata unchanged", "client_id", u.ClientID, "error", err)
+				} else {
+					fields["metadata"] = merged
+				}
+			}
+
+			if err := tx.Model(&client).Updates(fields).Error; err != nil {
+				return fmt.Errorf("failed to update MQTT client %s: %w", u.ClientID, err)
+			}
+			changed = append(changed, client)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Publish only after the transaction commits
+	for _, client := range added {
+		db.events.Publish(events.ClientAdded, map[string]interface{}{"client": client})
+	}
+	for _, client := range changed {
+		db.events.Publish(events.ClientUpdated, map[string]interface{}{"client": client})
+	}
+
+	return nil
+}
+
 // MarkMQTTClientInactive marks a client as disconnected
-func (db *DB) MarkMQTTClientInactive(clientID string) error {
+// Returns the number of client records updated (0 if the client is not tracked)
+func (db *DB) MarkMQTTClientInactive(clientID string) (int64, error) {
 	result := db.Model(&MQTTClient{}).
 		Where("client_id = ?", clientID).
 		Updates(map[string]interface{}{
@@ -64,10 +196,16 @@ func (db *DB) MarkMQTTClientInactive(clientID string) error {
 		})
 
 	if result.Error != nil {
-		return fmt.Errorf("failed to mark client inactive: %w", result.Error)
+		return 0, fmt.Errorf("failed to mark client inactive: %w", result.Error)
 	}
 
-	return nil
+	if result.RowsAffected > 0 {
+		db.events.Publish(events.ClientUpdated, map[string]interface{}{
+			"client": map[string]interface{}{"client_id": clientID, "is_active": false},
+		})
+	}
+
+	return result.RowsAffected, nil
 }
 
 // GetMQTTClient retrieves a client by ID
@@ -146,6 +284,37 @@ func (db *DB) ListMQTTClientsPaginated(page, pageSize int, search, sortBy, sortO
 	return clients, total, nil
 }
 
+// mqttClientBatchSize is how many clients ForEachMQTTClient loads per query
+const mqttClientBatchSize = 500
+
+// ForEachMQTTClient passes every client whose ID contains search (all if empty)
+// to fn in ID order, stopping at the first error fn returns
+// Clients are loaded in batches with a keyset cursor on the primary key, so
+// memory stays flat however many clients there are
+func (db *DB) ForEachMQTTClient(search string, fn func(*MQTTClient) error) error {
+	var lastID uint
+	for {
+		query := db.Preload("MQTTUser").Where("id > ?", lastID)
+		if search != "" {
+			query = query.Where("client_id LIKE ?", "%"+search+"%")
+		}
+
+		var batch []MQTTClient
+		if err := query.Order("id").Limit(mqttClientBatchSize).Find(&batch).Error; err != nil {
+			return fmt.Errorf("failed to list MQTT clients: %w", err)
+		}
+		for i := range batch {
+			if err := fn(&batch[i]); err != nil {
+				return err
+			}
+		}
+		if len(batch) < mqttClientBatchSize {
+			return nil
+		}
+		lastID = batch[len(batch)-1].ID
+	}
+}
+
 // ListMQTTClientsByUser returns all clients for a specific MQTT user
 func (db *DB) ListMQTTClientsByUser(mqttUserID uint, activeOnly bool) ([]MQTTClient, error) {
 	var clients []MQTTClient
@@ -176,21 +345,102 @@ func (db *DB) UpdateMQTTClientMetadata(clientID string, metadata datatypes.JSON)
 		return fmt.Errorf("client not found")
 	}
 
+	db.events.Publish(events.ClientUpdated, map[string]interface{}{
+		"client": map[string]interface{}{"client_id": clientID, "metadata": metadata},
+	})
+
 	return nil
 }
 
+// MergeMQTTClientMetadata sets the given keys in a client's metadata, keeping
+// any other keys (e.g. ones set by operators through the API)
+func (db *DB) MergeMQTTClientMetadata(clientID string, values map[string]interface{}) error {
+	var merged datatypes.JSON
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var client MQTTClient
+		if err := tx.Where("client_id = ?", clientID).First(&client).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("client not found")
+			}
+			return fmt.Errorf("failed to load MQTT client: %w", err)
+		}
+
+		var err error
+		merged, err = mergeMetadata(client.Metadata, values)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&client).Update("metadata", merged).Error; err != nil {
+			return fmt.Errorf("failed to update client metadata: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	db.events.Publish(events.ClientUpdated, map[string]interface{}{
+		"client": map[string]interface{}{"client_id": clientID, "metadata": merged},
+	})
+	return nil
+}
+
+// mergeMetadata sets values on top of a metadata JSON object
+// Metadata that isn't a JSON object is left alone rather than overwritten
+func mergeMetadata(current datatypes.JSON, values map[string]interface{}) (datatypes.JSON, error) {
+	fields := make(map[string]interface{}, len(values))
+	if len(current) > 0 && string(current) != "null" {
+		if err := json.Unmarshal(current, &fields); err != nil {
+			return nil, fmt.Errorf("client metadata is not a JSON object: %w", err)
+		}
+	}
+
+	for key, value := range values {
+		fields[key] = value
+	}
+
+	merged, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode client metadata: %w", err)
+	}
+	return datatypes.JSON(merged), nil
+}
+
 // DeleteMQTTClient deletes a client record
-func (db *DB) DeleteMQTTClient(id uint) error {
+// Returns the number of records deleted (0 if no client matched the ID)
+func (db *DB) DeleteMQTTClient(id uint) (int64, error) {
+	// Load the record first so the removed event carries it like added and updated do
+	var client MQTTClient
+	if err := db.First(&client, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get MQTT client: %w", err)
+	}
+
 	result := db.Delete(&MQTTClient{}, id)
 	if result.Error != nil {
-		return fmt.Errorf("failed to delete MQTT client: %w", result.Error)
+		return 0, fmt.Errorf("failed to delete MQTT client: %w", result.Error)
 	}
 
-	if result.RowsAffected == 0 {
-		return fmt.Errorf("client not found")
+	if result.RowsAffected > 0 {
+		db.events.Publish(events.ClientRemoved, map[string]interface{}{"client": &client})
 	}
 
-	return nil
+	return result.RowsAffected, nil
+}
+
+// DeleteInactiveMQTTClients deletes the records of disconnected clients last
+// seen before lastSeenBefore. Connected clients are never deleted
+// Returns the number of records deleted. No per-client events are published,
+// as a reap can remove thousands of long-gone clients at once
+func (db *DB) DeleteInactiveMQTTClients(lastSeenBefore time.Time) (int64, error) {
+	result := db.Where("is_active = ? AND last_seen < ?", false, lastSeenBefore).Delete(&MQTTClient{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete inactive MQTT clients: %w", result.Error)
+	}
+	return result.RowsAffected, nil
 }
 
 // GetClientCount returns the number of clients (active or total)
@@ -2,7 +2,9 @@ package storage
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 
 	"gorm.io/datatypes"
 )
@@ -138,6 +140,136 @@ func TestUpsertMQTTClient_Update(t *testing.T) {
 	}
 }
 
+func TestApplyMQTTClientUpdates(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	mqttUser := createTestMQTTUser(t, db, "device_user", "password123", "Device credentials")
+
+	// Existing client whose FirstSeen must be preserved
+	existing, err := db.UpsertMQTTClient("existing", mqttUser.ID, nil)
+	if err != nil {
+		t.Fatalf("UpsertMQTTClient() error = %v", err)
+	}
+
+	first := time.Now().Add(time.Minute)
+	last := first.Add(time.Second)
+
+	err = db.ApplyMQTTClientUpdates([]ClientUpdate{
+		{ClientID: "existing", MQTTUserID: mqttUser.ID, Connected: true, Active: false, FirstSeen: first, LastSeen: last},
+		{ClientID: "new", MQTTUserID: mqttUser.ID, Connected: true, Active: true, FirstSeen: first, LastSeen: last},
+		{ClientID: "anonymous", Connected: false, Active: false, LastSeen: last},
+	})
+	if err != nil {
+		t.Fatalf("ApplyMQTTClientUpdates() error = %v", err)
+	}
+
+	got, err := db.GetMQTTClientByClientID("existing")
+	if err != nil {
+		t.Fatalf("GetMQTTClientByClientID(existing) error = %v", err)
+	}
+	if got.IsActive {
+		t.Error("existing client IsActive = true, want false")
+	}
+	if !got.FirstSeen.Equal(existing.FirstSeen) {
+		t.Errorf("existing FirstSeen = %v, want preserved %v", got.FirstSeen, existing.FirstSeen)
+	}
+	if !got.LastSeen.Equal(last) {
+		t.Errorf("existing LastSeen = %v, want %v", got.LastSeen, last)
+	}
+
+	created, err := db.GetMQTTClientByClientID("new")
+	if err != nil {
+		t.Fatalf("GetMQTTClientByClientID(new) error = %v", err)
+	}
+	if !created.IsActive || !created.FirstSeen.Equal(first) {
+		t.Errorf("new client = active %v, first seen %v; want active, %v", created.IsActive, created.FirstSeen, first)
+	}
+
+	if _, err := db.GetMQTTClientByClientID("anonymous"); err == nil {
+		t.Error("disconnect-only update should not create a client record")
+	}
+}
+
+func TestApplyMQTTClientUpdates_NonObjectMetadata(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	mqttUser := createTestMQTTUser(t, db, "device_user", "password123", "Device credentials")
+	if _, err := db.UpsertMQTTClient("listy", mqttUser.ID, datatypes.JSON(`[1,2]`)); err != nil {
+		t.Fatalf("UpsertMQTTClient() error = %v", err)
+	}
+	if _, err := db.UpsertMQTTClient("other", mqttUser.ID, nil); err != nil {
+		t.Fatalf("UpsertMQTTClient() error = %v", err)
+	}
+
+	last := time.Now().Add(time.Minute)
+	geo := map[string]interface{}{"geo_country": "NL"}
+	err := db.ApplyMQTTClientUpdates([]ClientUpdate{
+		{ClientID: "listy", MQTTUserID: mqttUser.ID, Connected: true, Active: true, LastSeen: last, Metadata: geo},
+		{ClientID: "other", MQTTUserID: mqttUser.ID, Connected: true, Active: true, LastSeen: last, Metadata: geo},
+	})
+	if err != nil {
+		t.Fatalf("ApplyMQTTClientUpdates() error = %v", err)
+	}
+
+	listy, _ := db.GetMQTTClientByClientID("listy")
+	if string(listy.Metadata) != `[1,2]` || !listy.LastSeen.Equal(last) {
+		t.Errorf("listy = metadata %s, last seen %v; want metadata untouched, last seen %v", listy.Metadata, listy.LastSeen, last)
+	}
+	other, _ := db.GetMQTTClientByClientID("other")
+	if !strings.Contains(string(other.Metadata), `"geo_country":"NL"`) {
+		t.Errorf("other metadata = %s, want geo_country merged", other.Metadata)
+	}
+}
+
+func TestUpsertMQTTClientConnect(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	mqttUser := createTestMQTTUser(t, db, "device_user", "password123", "Device credentials")
+
+	info := ConnectInfo{ProtocolVersion: 5, CleanStart: true, Keepalive: 30, RemoteIP: "192.0.2.10"}
+	if _, err := db.UpsertMQTTClientConnect("sensor-1", mqttUser.ID, info); err != nil {
+		t.Fatalf("UpsertMQTTClientConnect() error = %v", err)
+	}
+
+	got, err := db.GetMQTTClientByClientID("sensor-1")
+	if err != nil {
+		t.Fatalf("GetMQTTClientByClientID() error = %v", err)
+	}
+	if got.ConnectInfo != info {
+		t.Errorf("ConnectInfo = %+v, want %+v", got.ConnectInfo, info)
+	}
+
+	// Reconnecting replaces the info, including zero values
+	info = ConnectInfo{ProtocolVersion: 4, CleanStart: false, Keepalive: 0, RemoteIP: "2001:db8::1"}
+	if _, err := db.UpsertMQTTClientConnect("sensor-1", mqttUser.ID, info); err != nil {
+		t.Fatalf("UpsertMQTTClientConnect() reconnect error = %v", err)
+	}
+	got, _ = db.GetMQTTClientByClientID("sensor-1")
+	if got.ConnectInfo != info {
+		t.Errorf("ConnectInfo after reconnect = %+v, want %+v", got.ConnectInfo, info)
+	}
+
+	// Batched updates carry the info too
+	batched := ConnectInfo{ProtocolVersion: 5, Keepalive: 60, RemoteIP: "198.51.100.7"}
+	now := time.Now()
+	err = db.ApplyMQTTClientUpdates([]ClientUpdate{
+		{ClientID: "sensor-1", MQTTUserID: mqttUser.ID, Connected: true, Active: true, FirstSeen: now, LastSeen: now, Info: &batched},
+		{ClientID: "sensor-2", MQTTUserID: mqttUser.ID, Connected: true, Active: true, FirstSeen: now, LastSeen: now, Info: &batched},
+	})
+	if err != nil {
+		t.Fatalf("ApplyMQTTClientUpdates() error = %v", err)
+	}
+	for _, clientID := range []string{"sensor-1", "sensor-2"} {
+		got, _ = db.GetMQTTClientByClientID(clientID)
+		if got.ConnectInfo != batched {
+			t.Errorf("%s ConnectInfo = %+v, want %+v", clientID, got.ConnectInfo, batched)
+		}
+	}
+}
+
 func TestMarkMQTTClientInactive(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -147,8 +279,7 @@ func TestMarkMQTTClientInactive(t *testing.T) {
 	tests := []struct {
 		name     string
 		setup    func() string // Returns client ID
-		wantErr  bool
-		checkErr func(error) bool
+		wantRows int64
 	}{
 		{
 			name: "mark existing client inactive",
@@ -156,33 +287,29 @@ func TestMarkMQTTClientInactive(t *testing.T) {
 				client, _ := db.UpsertMQTTClient("device-active", mqttUser.ID, nil)
 				return client.ClientID
 			},
-			wantErr: false,
+			wantRows: 1,
 		},
 		{
 			name: "mark non-existent client",
 			setup: func() string {
 				return "nonexistent-client"
 			},
-			wantErr: false, // GORM doesn't error on 0 rows affected for Updates
+			wantRows: 0,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			clientID := tt.setup()
-			err := db.MarkMQTTClientInactive(clientID)
-
-			if tt.wantErr {
-				if err == nil {
-					t.Errorf("MarkMQTTClientInactive() expected error but got none")
-				}
-				return
-			}
-
+			rows, err := db.MarkMQTTClientInactive(clientID)
 			if err != nil {
 				t.Fatalf("MarkMQTTClientInactive() unexpected error: %v", err)
 			}
 
+			if rows != tt.wantRows {
+				t.Errorf("MarkMQTTClientInactive() rows = %v, want %v", rows, tt.wantRows)
+			}
+
 			// If client exists, verify it's marked inactive
 			client, err := db.GetMQTTClientByClientID(clientID)
 			if err == nil {
@@ -194,6 +321,41 @@ func TestMarkMQTTClientInactive(t *testing.T) {
 	}
 }
 
+func TestDeleteInactiveMQTTClients(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	mqttUser := createTestMQTTUser(t, db, "testuser", "password123", "Test")
+	now := time.Now()
+	old := now.Add(-60 * 24 * time.Hour)
+
+	err := db.ApplyMQTTClientUpdates([]ClientUpdate{
+		{ClientID: "old-inactive", MQTTUserID: mqttUser.ID, Connected: true, Active: false, FirstSeen: old, LastSeen: old},
+		{ClientID: "recent-inactive", MQTTUserID: mqttUser.ID, Connected: true, Active: false, FirstSeen: old, LastSeen: now},
+		{ClientID: "old-active", MQTTUserID: mqttUser.ID, Connected: true, Active: true, FirstSeen: old, LastSeen: old},
+	})
+	if err != nil {
+		t.Fatalf("ApplyMQTTClientUpdates() error = %v", err)
+	}
+
+	deleted, err := db.DeleteInactiveMQTTClients(now.Add(-30 * 24 * time.Hour))
+	if err != nil {
+		t.Fatalf("DeleteInactiveMQTTClients() error = %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("DeleteInactiveMQTTClients() = %d, want 1", deleted)
+	}
+
+	if _, err := db.GetMQTTClientByClientID("old-inactive"); err == nil {
+		t.Error("old inactive client should have been deleted")
+	}
+	for _, clientID := range []string{"recent-inactive", "old-active"} {
+		if _, err := db.GetMQTTClientByClientID(clientID); err != nil {
+			t.Errorf("%s should remain: %v", clientID, err)
+		}
+	}
+}
+
 func TestGetMQTTClient(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -477,14 +639,41 @@ func TestUpdateMQTTClientMetadata(t *testing.T) {
 	}
 }
 
+func TestMergeMQTTClientMetadata(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	mqttUser := createTestMQTTUser(t, db, "device_user", "password123", "Device credentials")
+	if _, err := db.UpsertMQTTClient("sensor-1", mqttUser.ID, datatypes.JSON(`{"location":"roof"}`)); err != nil {
+		t.Fatalf("UpsertMQTTClient() error = %v", err)
+	}
+
+	if err := db.MergeMQTTClientMetadata("sensor-1", map[string]interface{}{"geo_country": "NZ"}); err != nil {
+		t.Fatalf("MergeMQTTClientMetadata() error = %v", err)
+	}
+
+	client, _ := db.GetMQTTClientByClientID("sensor-1")
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(client.Metadata, &metadata); err != nil {
+		t.Fatalf("metadata is not JSON: %v", err)
+	}
+	if metadata["location"] != "roof" || metadata["geo_country"] != "NZ" {
+		t.Errorf("metadata = %v, want location kept and geo_country added", metadata)
+	}
+
+	if err := db.MergeMQTTClientMetadata("missing", map[string]interface{}{"geo_country": "NZ"}); err == nil {
+		t.Error("MergeMQTTClientMetadata() for unknown client error = nil, want error")
+	}
+}
+
 func TestDeleteMQTTClient(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
 	tests := []struct {
-		name    string
-		setup   func() uint // Returns client ID to delete
-		wantErr bool
+		name     string
+		setup    func() uint // Returns client ID to delete
+		wantRows int64
 	}{
 		{
 			name: "delete existing client",
@@ -493,33 +682,29 @@ func TestDeleteMQTTClient(t *testing.T) {
 				client, _ := db.UpsertMQTTClient("delete-me", mqttUser.ID, nil)
 				return client.ID
 			},
-			wantErr: false,
+			wantRows: 1,
 		},
 		{
 			name: "delete non-existent client",
 			setup: func() uint {
 				return 999999
 			},
-			wantErr: true,
+			wantRows: 0,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			id := tt.setup()
-			err := db.DeleteMQTTClient(id)
-
-			if tt.wantErr {
-				if err == nil {
-					t.Errorf("DeleteMQTTClient() expected error but got none")
-				}
-				return
-			}
-
+			rows, err := db.DeleteMQTTClient(id)
 			if err != nil {
 				t.Fatalf("DeleteMQTTClient() unexpected error: %v", err)
 			}
 
+			if rows != tt.wantRows {
+				t.Errorf("DeleteMQTTClient() rows = %v, want %v", rows, tt.wantRows)
+			}
+
 			// Verify client is deleted
 			_, err = db.GetMQTTClient(id)
 			if err == nil {
@@ -1,36 +1,105 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
+// authFailure is an authentication error that knows why authentication failed
+// The auth hook reads the reason to label failure metrics
+type authFailure struct {
+	reason  string
+	message string
+}
+
+func (e *authFailure) Error() string { return e.message }
+
+// AuthFailureReason returns the metrics label for this failure
+func (e *authFailure) AuthFailureReason() string { return e.reason }
+
+// Errors returned by AuthenticateMQTTUser
+var (
+	ErrUnknownMQTTUser error = &authFailure{reason: "unknown_user", message: "user not found"}
+	ErrBadPassword     error = &authFailure{reason: "bad_password", message: "invalid password"}
+)
+
+// ErrVersionConflict is returned by a conditional update when the resource
+// has changed since the caller read the version it passed
+var ErrVersionConflict = errors.New("resource was modified by another request")
+
 // CreateMQTTUser creates a new MQTT credential
 func (db *DB) CreateMQTTUser(username, password, description string, metadata datatypes.JSON) (*MQTTUser, error) {
-	if username == "" || password == "" {
+	return db.CreateMQTTUserWithRules(NewMQTTUser{
+		Username:    username,
+		Password:    password,
+		Description: description,
+		Metadata:    metadata,
+	})
+}
+
+// NewMQTTUser describes an MQTT credential to create along with its source
+// address restrictions and ACL rules
+type NewMQTTUser struct {
+	Username     string
+	Password     string
+	Description  string
+	Metadata     datatypes.JSON
+	AllowedCIDRs []string
+	DeniedCIDRs  []string
+	ACLRules     []ACLRuleInput // MQTTUserID is filled in with the new user's
+	Provisioned  bool           // Managed by the config file
+}
+
+// CreateMQTTUserWithRules creates the user, its IP ranges and its ACL rules in
+// one transaction, so the credential never exists without its restrictions
+func (db *DB) CreateMQTTUserWithRules(input NewMQTTUser) (*MQTTUser, error) {
+	if input.Username == "" || input.Password == "" {
 		return nil, fmt.Errorf("username and password are required")
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hash, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
 
 	user := &MQTTUser{
-		Username:     username,
-		PasswordHash: string(hash),
-		Description:  description,
-		Metadata:     metadata,
-	}
-
-	if err := db.Create(user).Error; err != nil {
-		return nil, fmt.Errorf("failed to create MQTT user: %w", err)
+		Username:              input.Username,
+		PasswordHash:          string(hash),
+		Description:           input.Description,
+		Metadata:              input.Metadata,
+		ProvisionedFromConfig: input.Provisioned,
+	}
+	if len(input.AllowedCIDRs) > 0 {
+		user.AllowedCIDRs = datatypes.NewJSONSlice(input.AllowedCIDRs)
+	}
+	if len(input.DeniedCIDRs) > 0 {
+		user.DeniedCIDRs = datatypes.NewJSONSlice(input.DeniedCIDRs)
+	}
+
+	err = db.primary().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return fmt.Errorf("failed to create MQTT user: %w", err)
+		}
+		for _, rule := range input.ACLRules {
+			rule.MQTTUserID = user.ID
+			if _, err := createACLRuleTx(tx, rule); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Add to cache immediately
-	db.cache.SetMQTTUser(username, user)
+	db.cache.SetMQTTUser(user.Username, user)
+	db.cache.DeleteACLRules(user.ID)
+	db.invalidateAuthSnapshot()
 
 	return user, nil
 }
@@ -44,17 +113,31 @@ func (db *DB) GetMQTTUser(id uint) (*MQTTUser, error) {
 	return &user, nil
 }
 
+// GetMQTTUserForUpdate retrieves an MQTT user by ID from the primary
+// Used by read-modify-write paths, where a lagging replica would return a stale version
+func (db *DB) GetMQTTUserForUpdate(id uint) (*MQTTUser, error) {
+	var user MQTTUser
+	if err := db.primary().First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 // GetMQTTUserByUsername retrieves an MQTT user by username
 // Uses in-memory cache to avoid database queries on hot path (MQTT pub/sub)
 func (db *DB) GetMQTTUserByUsername(username string) (*MQTTUser, error) {
+	if db.authSnapshot != nil {
+		return db.snapshotUser(username)
+	}
+
 	// Check cache first
 	if cachedUser, found := db.cache.GetMQTTUser(username); found {
 		return cachedUser, nil
 	}
 
-	// Cache miss - query database
+	// Cache miss - query the primary, so a lagging replica can't cache a deleted user
 	var user MQTTUser
-	if err := db.Where("username = ?", username).First(&user).Error; err != nil {
+	if err := db.primary().Where("username = ?", username).First(&user).Error; err != nil {
 		return nil, err
 	}
 
@@ -115,7 +198,7 @@ func (db *DB) ListMQTTUsersPaginated(page, pageSize int, search, sortBy, sortOrd
 // UpdateMQTTUser updates an MQTT user's information
 func (db *DB) UpdateMQTTUser(id uint, username, description string, metadata datatypes.JSON) error {
 	// Get old username to invalidate cache
-	oldUser, err := db.GetMQTTUser(id)
+	oldUser, err := db.GetMQTTUserForUpdate(id)
 	if err != nil {
 		return fmt.Errorf("MQTT user not found")
 	}
@@ -123,6 +206,7 @@ func (db *DB) UpdateMQTTUser(id uint, username, description string, metadata dat
 	updates := map[string]interface{}{
 		"username":    username,
 		"description": description,
+		"version":     gorm.Expr("version + 1"),
 	}
 
 	if metadata != nil {
@@ -148,6 +232,73 @@ func (db *DB) UpdateMQTTUser(id uint, username, description string, metadata dat
 	return nil
 }
 
+// MQTTUserPatch lists the MQTT user fields to change; nil fields are left as they are
+type MQTTUserPatch struct {
+	Username     *string
+	Description  *string
+	Metadata     *datatypes.JSON // Points to nil to clear the metadata
+	AllowedCIDRs *[]string       // Points to an empty list to allow any source address
+	DeniedCIDRs  *[]string
+	IfVersion    uint // Only update if the user is at this version (0 = any version)
+}
+
+// PatchMQTTUser updates only the fields set in patch
+// Returns ErrVersionConflict if patch.IfVersion is set and no longer current
+func (db *DB) PatchMQTTUser(id uint, patch MQTTUserPatch) error {
+	oldUser, err := db.GetMQTTUserForUpdate(id)
+	if err != nil {
+		return fmt.Errorf("MQTT user not found")
+	}
+	if patch.IfVersion > 0 && oldUser.Version != patch.IfVersion {
+		return ErrVersionConflict
+	}
+
+	updates := map[string]interface{}{}
+	if patch.Username != nil {
+		updates["username"] = *patch.Username
+	}
+	if patch.Description != nil {
+		updates["description"] = *patch.Description
+	}
+	if patch.Metadata != nil {
+		updates["metadata"] = *patch.Metadata
+	}
+	if patch.AllowedCIDRs != nil {
+		updates["allowed_cidrs"] = datatypes.NewJSONSlice(*patch.AllowedCIDRs)
+	}
+	if patch.DeniedCIDRs != nil {
+		updates["denied_cidrs"] = datatypes.NewJSONSlice(*patch.DeniedCIDRs)
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	updates["version"] = gorm.Expr("version + 1")
+
+	query := db.Model(&MQTTUser{}).Where("id = ?", id)
+	if patch.IfVersion > 0 {
+		// Checked again in the UPDATE itself in case of a concurrent write
+		query = query.Where("version = ?", patch.IfVersion)
+	}
+	result := query.Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update MQTT user: %w", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		if patch.IfVersion > 0 {
+			return ErrVersionConflict
+		}
+		return fmt.Errorf("MQTT user not found")
+	}
+
+	db.cache.DeleteMQTTUser(oldUser.Username)
+	if patch.Username != nil && *patch.Username != oldUser.Username {
+		db.cache.DeleteMQTTUser(*patch.Username)
+	}
+
+	return nil
+}
+
 // UpdateMQTTUserPassword updates an MQTT user's password
 func (db *DB) UpdateMQTTUserPassword(id uint, password string) error {
 	// Get username to invalidate cache
@@ -161,7 +312,10 @@ func (db *DB) UpdateMQTTUserPassword(id uint, password string) error {
 		return fmt.Errorf("failed to hash password: %w", err)
 	}
 
-	result := db.Model(&MQTTUser{}).Where("id = ?", id).Update("password_hash", string(hash))
+	result := db.Model(&MQTTUser{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"password_hash": string(hash),
+		"version":       gorm.Expr("version + 1"),
+	})
 	if result.Error != nil {
 		return fmt.Errorf("failed to update password: %w", result.Error)
 	}
@@ -203,14 +357,17 @@ func (db *DB) DeleteMQTTUser(id uint) error {
 // AuthenticateMQTTUser verifies MQTT user credentials
 func (db *DB) AuthenticateMQTTUser(username, password string) (*MQTTUser, error) {
 	user, err := db.GetMQTTUserByUsername(username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUnknownMQTTUser
+	}
 	if err != nil {
-		// User not found in mqtt_users table
-		return nil, fmt.Errorf("user not found")
+		// Not a verdict on the credentials; the auth hook reports it as an error
+		return nil, fmt.Errorf("failed to look up MQTT user: %w", err)
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
 		// Invalid password
-		return nil, fmt.Errorf("invalid password")
+		return nil, ErrBadPassword
 	}
 
 	return user, nil
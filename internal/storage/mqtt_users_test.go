@@ -95,6 +95,52 @@ func TestCreateMQTTUser(t *testing.T) {
 	}
 }
 
+func TestCreateMQTTUserWithRules(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	user, err := db.CreateMQTTUserWithRules(NewMQTTUser{
+		Username:     "sensor",
+		Password:     "secret",
+		AllowedCIDRs: []string{"10.0.0.0/8"},
+		ACLRules: []ACLRuleInput{
+			{Topic: "sensors/${clientid}/#", Permission: "pub", Retain: true},
+			{Topic: "commands/#", Permission: "sub"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateMQTTUserWithRules() error = %v", err)
+	}
+	if len(user.AllowedCIDRs) != 1 || user.AllowedCIDRs[0] != "10.0.0.0/8" {
+		t.Errorf("AllowedCIDRs = %v, want [10.0.0.0/8]", user.AllowedCIDRs)
+	}
+	rules, err := db.GetACLRulesByMQTTUserID(user.ID)
+	if err != nil {
+		t.Fatalf("GetACLRulesByMQTTUserID() error = %v", err)
+	}
+	retain := map[string]bool{}
+	for _, rule := range rules {
+		retain[rule.Topic] = rule.Retain
+	}
+	if len(rules) != 2 || !retain["sensors/${clientid}/#"] || retain["commands/#"] {
+		t.Errorf("rules = %+v, want sensors/${clientid}/# (retained) and commands/#", rules)
+	}
+
+	// A rule that can't be created leaves no user behind
+	_, err = db.CreateMQTTUserWithRules(NewMQTTUser{
+		Username:     "partial",
+		Password:     "secret",
+		AllowedCIDRs: []string{"10.0.0.0/8"},
+		ACLRules:     []ACLRuleInput{{Topic: "a/#", Permission: "pub"}, {Topic: "b/#", Permission: "admin"}},
+	})
+	if err == nil {
+		t.Fatal("CreateMQTTUserWithRules() accepted an invalid rule")
+	}
+	if _, err := db.GetMQTTUserByUsername("partial"); err == nil {
+		t.Error("user was created despite its ACL failing")
+	}
+}
+
 func TestGetMQTTUser(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
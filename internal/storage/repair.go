@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+
+	"gorm.io/gorm"
+)
+
+// ProvisionedSet names the resources the current config file provisions
+type ProvisionedSet struct {
+	Users    []string
+	ACLRules map[string][]string // Username -> topic patterns
+	Bridges  []string
+	Scripts  []string
+}
+
+// RepairReport counts the rows whose provisioned flag was corrected
+type RepairReport struct {
+	Users    int `json:"users"`
+	ACLRules int `json:"acl_rules"`
+	Bridges  int `json:"bridges"`
+	Scripts  int `json:"scripts"`
+}
+
+// RepairProvisionedFlags reconciles the provisioned_from_config flag of every
+// user, ACL rule, bridge, and script with set: rows named in the config are
+// flagged and all others are cleared. Rows are matched the way provisioning
+// matches them (users, bridges, and scripts by name, ACL rules by username and
+// normalized topic)
+func (db *DB) RepairProvisionedFlags(set ProvisionedSet) (*RepairReport, error) {
+	report := &RepairReport{}
+	var changedUsers []string
+	var changedRuleUsers []uint
+
+	err := db.primary().Transaction(func(tx *gorm.DB) error {
+		var users []MQTTUser
+		if err := tx.Select("id", "username", "provisioned_from_config").Find(&users).Error; err != nil {
+			return fmt.Errorf("failed to load MQTT users: %w", err)
+		}
+		wantUsers := nameSet(set.Users)
+		usernames := make(map[uint]string, len(users))
+		for _, user := range users {
+			usernames[user.ID] = user.Username
+			if want := wantUsers[user.Username]; want != user.ProvisionedFromConfig {
+				if err := setProvisioned(tx, &MQTTUser{}, user.ID, want); err != nil {
+					return err
+				}
+				changedUsers = append(changedUsers, user.Username)
+			}
+		}
+		report.Users = len(changedUsers)
+
+		var rules []ACLRule
+		if err := tx.Select("id", "mqtt_user_id", "topic", "provisioned_from_config").Find(&rules).Error; err != nil {
+			return fmt.Errorf("failed to load ACL rules: %w", err)
+		}
+		wantRules := make(map[string]map[string]bool, len(set.ACLRules))
+		for username, topics := range set.ACLRules {
+			wantRules[username] = make(map[string]bool, len(topics))
+			for _, topic := range topics {
+				wantRules[username][NormalizeTopicPattern(topic)] = true
+			}
+		}
+		for _, rule := range rules {
+			username := usernames[rule.MQTTUserID]
+			want := wantUsers[username] && wantRules[username][NormalizeTopicPattern(rule.Topic)]
+			if want != rule.ProvisionedFromConfig {
+				if err := setProvisioned(tx, &ACLRule{}, rule.ID, want); err != nil {
+					return err
+				}
+				changedRuleUsers = append(changedRuleUsers, rule.MQTTUserID)
+			}
+		}
+		report.ACLRules = len(changedRuleUsers)
+
+		var bridges []Bridge
+		if err := tx.Select("id", "name", "provisioned_from_config").Find(&bridges).Error; err != nil {
+			return fmt.Errorf("failed to load bridges: %w", err)
+		}
+		wantBridges := nameSet(set.Bridges)
+		for _, bridge := range bridges {
+			if want := wantBridges[bridge.Name]; want != bridge.ProvisionedFromConfig {
+				if err := setProvisioned(tx, &Bridge{}, bridge.ID, want); err != nil {
+					return err
+				}
+				report.Bridges++
+			}
+		}
+
+		var scripts []Script
+		if err := tx.Select("id", "name", "provisioned_from_config").Find(&scripts).Error; err != nil {
+			return fmt.Errorf("failed to load scripts: %w", err)
+		}
+		wantScripts := nameSet(set.Scripts)
+		for _, script := range scripts {
+			if want := wantScripts[script.Name]; want != script.ProvisionedFromConfig {
+				if err := setProvisioned(tx, &Script{}, script.ID, want); err != nil {
+					return err
+				}
+				report.Scripts++
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Cached users and rules carry the flag too
+	for _, username := range changedUsers {
+		db.cache.DeleteMQTTUser(username)
+	}
+	for _, userID := range changedRuleUsers {
+		db.cache.DeleteACLRules(userID)
+	}
+
+	slog.Info("Repaired provisioned flags",
+		"users", report.Users,
+		"acl_rules", report.ACLRules,
+		"bridges", report.Bridges,
+		"scripts", report.Scripts)
+	return report, nil
+}
+
+// setProvisioned sets the provisioned flag of a single row of model
+func setProvisioned(tx *gorm.DB, model interface{}, id uint, provisioned bool) error {
+	if err := tx.Model(model).Where("id = ?", id).Update("provisioned_from_config", provisioned).Error; err != nil {
+		return fmt.Errorf("failed to update provisioned flag: %w", err)
+	}
+	return nil
+}
+
+// nameSet builds a lookup set from names
+func nameSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
@@ -0,0 +1,71 @@
+package storage
+
+import "testing"
+
+func TestRepairProvisionedFlags(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// sensor is in the config but was never flagged; stale was flagged by an
+	// old config and has since been removed from it
+	sensor := createTestMQTTUser(t, db, "sensor", "password123", "")
+	stale := createTestMQTTUser(t, db, "stale", "password123", "")
+	if err := db.MarkAsProvisioned(stale.ID, true); err != nil {
+		t.Fatalf("MarkAsProvisioned() error = %v", err)
+	}
+	configRule := createTestACLRule(t, db, sensor.ID, "sensors/${username}/#", "pubsub")
+	manualRule := createTestACLRule(t, db, sensor.ID, "manual/#", "sub")
+
+	bridge, err := db.CreateBridge("cloud", "remote.example.com", 1883, "", "", "cloud", "5", true, 60, 30, nil, "", nil, nil)
+	if err != nil {
+		t.Fatalf("CreateBridge() error = %v", err)
+	}
+	script, err := db.CreateScript("logger", "", "log.info('hi');", true, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateScript() error = %v", err)
+	}
+
+	set := ProvisionedSet{
+		Users:    []string{"sensor"},
+		ACLRules: map[string][]string{"sensor": {"sensors/${username}/#/"}}, // Matched after normalization
+		Bridges:  []string{"cloud"},
+		Scripts:  []string{"logger"},
+	}
+
+	report, err := db.RepairProvisionedFlags(set)
+	if err != nil {
+		t.Fatalf("RepairProvisionedFlags() error = %v", err)
+	}
+	want := RepairReport{Users: 2, ACLRules: 1, Bridges: 1, Scripts: 1}
+	if *report != want {
+		t.Errorf("RepairProvisionedFlags() report = %+v, want %+v", *report, want)
+	}
+
+	if user, _ := db.GetMQTTUserByUsername("sensor"); !user.ProvisionedFromConfig {
+		t.Error("sensor should be flagged as provisioned")
+	}
+	if user, _ := db.GetMQTTUserByUsername("stale"); user.ProvisionedFromConfig {
+		t.Error("stale should no longer be flagged as provisioned")
+	}
+	if rule, _ := db.GetACLRule(configRule.ID); !rule.ProvisionedFromConfig {
+		t.Error("config ACL rule should be flagged as provisioned")
+	}
+	if rule, _ := db.GetACLRule(manualRule.ID); rule.ProvisionedFromConfig {
+		t.Error("manual ACL rule should not be flagged as provisioned")
+	}
+	if got, _ := db.GetBridge(bridge.ID); !got.ProvisionedFromConfig {
+		t.Error("bridge should be flagged as provisioned")
+	}
+	if got, _ := db.GetScript(script.ID); !got.ProvisionedFromConfig {
+		t.Error("script should be flagged as provisioned")
+	}
+
+	// A second run has nothing left to fix
+	report, err = db.RepairProvisionedFlags(set)
+	if err != nil {
+		t.Fatalf("RepairProvisionedFlags() error = %v", err)
+	}
+	if *report != (RepairReport{}) {
+		t.Errorf("second RepairProvisionedFlags() report = %+v, want no changes", *report)
+	}
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func openTestFileDB(t *testing.T, config *DatabaseConfig) *DB {
+	t.Helper()
+
+	db, err := OpenWithCache(config, NewCacheWithRegistry(prometheus.NewRegistry()))
+	if err != nil {
+		t.Fatalf("failed to open database %s: %v", config.FilePath, err)
+	}
+	return db
+}
+
+func TestReadReplicaRouting(t *testing.T) {
+	dir := t.TempDir()
+	primaryPath := filepath.Join(dir, "primary.db")
+	replicaPath := filepath.Join(dir, "replica.db")
+
+	// Seed the replica with a user that only exists there
+	replicaDB := openTestFileDB(t, DefaultSQLiteConfig(replicaPath))
+	if _, err := replicaDB.CreateMQTTUser("replica-only", "password", "", nil); err != nil {
+		t.Fatalf("failed to seed replica: %v", err)
+	}
+	_ = replicaDB.Close()
+
+	// Open the primary with the replica configured
+	config := DefaultSQLiteConfig(primaryPath)
+	config.ReplicaDSN = replicaPath
+	db := openTestFileDB(t, config)
+
+	// Migrations must have run against the primary, not been skipped because the replica is migrated
+	if !db.primary().Migrator().HasTable(&MQTTUser{}) {
+		t.Fatal("primary was not migrated")
+	}
+
+	// Writes go to the primary
+	if _, err := db.CreateMQTTUser("primary-only", "password", "", nil); err != nil {
+		t.Fatalf("CreateMQTTUser() error = %v", err)
+	}
+
+	// Reads go to the replica
+	users, err := db.ListMQTTUsers()
+	if err != nil {
+		t.Fatalf("ListMQTTUsers() error = %v", err)
+	}
+	if len(users) != 1 || users[0].Username != "replica-only" {
+		t.Errorf("ListMQTTUsers() via replica = %v, want [replica-only]", usernames(users))
+	}
+	_ = db.Close()
+
+	// Check each file directly
+	primaryDB := openTestFileDB(t, DefaultSQLiteConfig(primaryPath))
+	defer func() { _ = primaryDB.Close() }()
+	users, err = primaryDB.ListMQTTUsers()
+	if err != nil {
+		t.Fatalf("ListMQTTUsers() on primary error = %v", err)
+	}
+	if len(users) != 1 || users[0].Username != "primary-only" {
+		t.Errorf("primary users = %v, want [primary-only]", usernames(users))
+	}
+}
+
+func TestAuthCacheFillReadsPrimary(t *testing.T) {
+	dir := t.TempDir()
+	replicaPath := filepath.Join(dir, "replica.db")
+
+	// The lagging replica still has a user and rule the primary no longer has
+	replicaDB := openTestFileDB(t, DefaultSQLiteConfig(replicaPath))
+	stale, err := replicaDB.CreateMQTTUser("deleted", "password", "", nil)
+	if err != nil {
+		t.Fatalf("failed to seed replica: %v", err)
+	}
+	if _, err := replicaDB.CreateACLRule(stale.ID, "#", "pubsub"); err != nil {
+		t.Fatalf("failed to seed replica rule: %v", err)
+	}
+	_ = replicaDB.Close()
+
+	config := DefaultSQLiteConfig(filepath.Join(dir, "primary.db"))
+	config.ReplicaDSN = replicaPath
+	db := openTestFileDB(t, config)
+	defer func() { _ = db.Close() }()
+
+	// Same ID as the replica's user, but without its rule
+	user, err := db.CreateMQTTUser("sensor", "password", "", nil)
+	if err != nil {
+		t.Fatalf("CreateMQTTUser() error = %v", err)
+	}
+	if user.ID != stale.ID {
+		t.Fatalf("primary user ID = %d, want %d", user.ID, stale.ID)
+	}
+
+	if _, err := db.GetMQTTUserByUsername("deleted"); err == nil {
+		t.Error("GetMQTTUserByUsername() found a user that only exists on the replica")
+	}
+	rules, err := db.GetACLRulesByMQTTUserID(user.ID)
+	if err != nil {
+		t.Fatalf("GetACLRulesByMQTTUserID() error = %v", err)
+	}
+	if len(rules) != 0 {
+		t.Errorf("GetACLRulesByMQTTUserID() = %d rules from the replica, want 0", len(rules))
+	}
+}
+
+func TestPatchMQTTUserReadsPrimary(t *testing.T) {
+	dir := t.TempDir()
+	replicaPath := filepath.Join(dir, "replica.db")
+
+	// The lagging replica still has the user at its first version
+	replicaDB := openTestFileDB(t, DefaultSQLiteConfig(replicaPath))
+	if _, err := replicaDB.CreateMQTTUser("sensor", "password", "", nil); err != nil {
+		t.Fatalf("failed to seed replica: %v", err)
+	}
+	_ = replicaDB.Close()
+
+	config := DefaultSQLiteConfig(filepath.Join(dir, "primary.db"))
+	config.ReplicaDSN = replicaPath
+	db := openTestFileDB(t, config)
+	defer func() { _ = db.Close() }()
+
+	user, err := db.CreateMQTTUser("sensor", "password", "", nil)
+	if err != nil {
+		t.Fatalf("CreateMQTTUser() error = %v", err)
+	}
+	description := "first"
+	if err := db.PatchMQTTUser(user.ID, MQTTUserPatch{Description: &description}); err != nil {
+		t.Fatalf("PatchMQTTUser() error = %v", err)
+	}
+
+	current, err := db.GetMQTTUserForUpdate(user.ID)
+	if err != nil {
+		t.Fatalf("GetMQTTUserForUpdate() error = %v", err)
+	}
+	if current.Version != user.Version+1 || current.Description != "first" {
+		t.Fatalf("GetMQTTUserForUpdate() = version %d %q, want version %d %q", current.Version, current.Description, user.Version+1, "first")
+	}
+
+	// The version check must compare against the primary, not the replica's older version
+	description = "second"
+	if err := db.PatchMQTTUser(user.ID, MQTTUserPatch{Description: &description, IfVersion: current.Version}); err != nil {
+		t.Errorf("PatchMQTTUser() with the current version error = %v", err)
+	}
+}
+
+func usernames(users []MQTTUser) []string {
+	names := make([]string, 0, len(users))
+	for _, u := range users {
+		names = append(names, u.Username)
+	}
+	return names
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxRetainedTopicLength is the longest topic the SQL retained backend can store
+// (the topic is the primary key, which is size-limited on MySQL)
+const MaxRetainedTopicLength = 512
+
+// SaveRetainedMessage stores or replaces the retained message for a topic
+func (db *DB) SaveRetainedMessage(topic string, payload []byte, qos byte) error {
+	if len(topic) > MaxRetainedTopicLength {
+		return fmt.Errorf("topic too long for the sql retained backend (max %d bytes)", MaxRetainedTopicLength)
+	}
+
+	msg := RetainedMessage{Topic: topic, Payload: payload, QoS: qos}
+	return db.primary().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "topic"}},
+		DoUpdates: clause.AssignmentColumns([]string{"payload", "qos", "updated_at"}),
+	}).Create(&msg).Error
+}
+
+// DeleteRetainedMessage removes the retained message for a topic
+// Returns the number of messages removed (0 if the topic had none)
+func (db *DB) DeleteRetainedMessage(topic string) (int64, error) {
+	result := db.primary().Where("topic = ?", topic).Delete(&RetainedMessage{})
+	return result.RowsAffected, result.Error
+}
+
+// GetRetainedMessage returns the retained message for a topic, or nil if there is none
+func (db *DB) GetRetainedMessage(topic string) (*RetainedMessage, error) {
+	var msg RetainedMessage
+	if err := db.Where("topic = ?", topic).First(&msg).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// CountRetainedMessages returns how many topics have a retained message
+func (db *DB) CountRetainedMessages() (int64, error) {
+	var count int64
+	err := db.Model(&RetainedMessage{}).Count(&count).Error
+	return count, err
+}
+
+// ForEachRetainedMessage calls fn for every retained message in topic order,
+// loading them in batches. Iteration stops at the first error fn returns
+func (db *DB) ForEachRetainedMessage(fn func(*RetainedMessage) error) error {
+	var batch []RetainedMessage
+	var fnErr error
+	err := db.Order("topic").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			if fnErr = fn(&batch[i]); fnErr != nil {
+				return fnErr
+			}
+		}
+		return nil
+	}).Error
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// MaxScriptVersions is how many previous versions are kept per script
+// The oldest versions are pruned once a script has more
+const MaxScriptVersions = 20
+
+// versionTriggers converts triggers to their stored version form
+func versionTriggers(triggers []ScriptTrigger) datatypes.JSONSlice[ScriptVersionTrigger] {
+	result := make(datatypes.JSONSlice[ScriptVersionTrigger], len(triggers))
+	for i, trigger := range triggers {
+		result[i] = ScriptVersionTrigger{
+			Type:     trigger.Type,
+			Topic:    trigger.Topic,
+			Priority: trigger.Priority,
+			Enabled:  trigger.Enabled,
+		}
+	}
+	return result
+}
+
+// saveScriptVersion snapshots the current state of a script before it is
+// updated to the given description, content and triggers
+// Nothing is saved when none of them change (e.g. only enabling the script)
+func saveScriptVersion(tx *gorm.DB, current *Script, description, content string, triggers []ScriptTrigger) error {
+	previous := versionTriggers(current.Triggers)
+	if current.Description == description && current.Content == content && slices.Equal(previous, versionTriggers(triggers)) {
+		return nil
+	}
+
+	var latest int
+	if err := tx.Model(&ScriptVersion{}).Where("script_id = ?", current.ID).
+		Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+		return fmt.Errorf("failed to read script versions: %w", err)
+	}
+
+	version := ScriptVersion{
+		ScriptID:    current.ID,
+		Version:     latest + 1,
+		Description: current.Description,
+		Content:     current.Content,
+		Triggers:    previous,
+	}
+	if err := tx.Create(&version).Error; err != nil {
+		return fmt.Errorf("failed to save script version: %w", err)
+	}
+
+	// Prune versions beyond the cap
+	if version.Version > MaxScriptVersions {
+		if err := tx.Where("script_id = ? AND version <= ?", current.ID, version.Version-MaxScriptVersions).
+			Delete(&ScriptVersion{}).Error; err != nil {
+			return fmt.Errorf("failed to prune script versions: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ListScriptVersions returns the stored versions of a script, newest first
+func (db *DB) ListScriptVersions(scriptID uint) ([]ScriptVersion, error) {
+	var versions []ScriptVersion
+	if err := db.Where("script_id = ?", scriptID).Order("version DESC").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list script versions: %w", err)
+	}
+	return versions, nil
+}
+
+// GetScriptVersion retrieves one stored version of a script
+func (db *DB) GetScriptVersion(scriptID uint, version int) (*ScriptVersion, error) {
+	var v ScriptVersion
+	if err := db.Where("script_id = ? AND version = ?", scriptID, version).First(&v).Error; err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// RollbackScript restores a script's description, content and triggers from a
+// stored version. The name, enabled state and metadata are left as they are
+// The state being replaced is saved as a new version, so a rollback can be undone
+func (db *DB) RollbackScript(scriptID uint, version int) (*Script, error) {
+	v, err := db.GetScriptVersion(scriptID, version)
+	if err != nil {
+		return nil, fmt.Errorf("script version not found: %w", err)
+	}
+
+	current, err := db.GetScript(scriptID)
+	if err != nil {
+		return nil, fmt.Errorf("script not found: %w", err)
+	}
+
+	triggers := make([]ScriptTrigger, len(v.Triggers))
+	for i, trigger := range v.Triggers {
+		triggers[i] = ScriptTrigger{
+			Type:     trigger.Type,
+			Topic:    trigger.Topic,
+			Priority: trigger.Priority,
+			Enabled:  trigger.Enabled,
+		}
+	}
+
+	if err := db.UpdateScript(scriptID, current.Name, v.Description, v.Content, current.Enabled, nil, triggers); err != nil {
+		return nil, err
+	}
+	return db.GetScript(scriptID)
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestScriptVersions_Rollback(t *testing.T) {
+	db := setupTestDB(t)
+
+	script, err := db.CreateScript("versioned", "v1", "log.info('one');", true, nil,
+		[]ScriptTrigger{{Type: "on_publish", Topic: "a/#", Priority: 100, Enabled: true}})
+	if err != nil {
+		t.Fatalf("CreateScript() error = %v", err)
+	}
+
+	if err := db.UpdateScript(script.ID, "versioned", "v2", "log.info('two');", true, nil,
+		[]ScriptTrigger{{Type: "on_publish", Topic: "b/#", Priority: 100, Enabled: true}}); err != nil {
+		t.Fatalf("UpdateScript() error = %v", err)
+	}
+	if err := db.UpdateScript(script.ID, "versioned", "v3", "log.info('three');", true, nil,
+		[]ScriptTrigger{{Type: "on_connect", Priority: 100, Enabled: true}}); err != nil {
+		t.Fatalf("UpdateScript() error = %v", err)
+	}
+
+	versions, err := db.ListScriptVersions(script.ID)
+	if err != nil {
+		t.Fatalf("ListScriptVersions() error = %v", err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 || versions[1].Content != "log.info('one');" {
+		t.Fatalf("versions = %+v, want v2 (two) then v1 (one)", versions)
+	}
+
+	restored, err := db.RollbackScript(script.ID, 1)
+	if err != nil {
+		t.Fatalf("RollbackScript() error = %v", err)
+	}
+	if restored.Content != "log.info('one');" || restored.Description != "v1" {
+		t.Errorf("restored = %q (%s), want the first version", restored.Content, restored.Description)
+	}
+	if len(restored.Triggers) != 1 || restored.Triggers[0].Topic != "a/#" {
+		t.Errorf("restored triggers = %+v, want the first version's trigger", restored.Triggers)
+	}
+
+	// The replaced state was saved, so the rollback can itself be undone
+	latest, err := db.GetScriptVersion(script.ID, 3)
+	if err != nil || latest.Content != "log.info('three');" {
+		t.Errorf("GetScriptVersion(3) = %+v, %v, want the pre-rollback content", latest, err)
+	}
+}
+
+func TestScriptVersions_SkipsUnchangedAndCaps(t *testing.T) {
+	db := setupTestDB(t)
+
+	script, err := db.CreateScript("capped", "", "log.info(0);", true, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateScript() error = %v", err)
+	}
+
+	// Only toggling enabled doesn't create a version
+	if err := db.UpdateScript(script.ID, "capped", "", "log.info(0);", false, nil, nil); err != nil {
+		t.Fatalf("UpdateScript() error = %v", err)
+	}
+	if versions, _ := db.ListScriptVersions(script.ID); len(versions) != 0 {
+		t.Fatalf("versions after enable toggle = %d, want 0", len(versions))
+	}
+
+	for i := 1; i <= MaxScriptVersions+5; i++ {
+		content := fmt.Sprintf("log.info(%d);", i)
+		if err := db.UpdateScript(script.ID, "capped", "", content, true, nil, nil); err != nil {
+			t.Fatalf("UpdateScript() error = %v", err)
+		}
+	}
+
+	versions, err := db.ListScriptVersions(script.ID)
+	if err != nil {
+		t.Fatalf("ListScriptVersions() error = %v", err)
+	}
+	if len(versions) != MaxScriptVersions {
+		t.Errorf("versions = %d, want %d", len(versions), MaxScriptVersions)
+	}
+	if versions[0].Version != MaxScriptVersions+5 {
+		t.Errorf("newest version = %d, want %d", versions[0].Version, MaxScriptVersions+5)
+	}
+}
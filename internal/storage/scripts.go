@@ -1,12 +1,16 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
+// ErrTriggerNotFound is returned when a script has no trigger with the given ID
+var ErrTriggerNotFound = errors.New("trigger not found")
+
 // CreateScript creates a new script with triggers
 func (db *DB) CreateScript(name, description, scriptContent string, enabled bool, metadata datatypes.JSON, triggers []ScriptTrigger) (*Script, error) {
 	if name == "" {
@@ -108,9 +112,21 @@ func (db *DB) ListScriptsPaginated(page, pageSize int, search, sortBy, sortOrder
 }
 
 // UpdateScript updates a script's information and triggers
+// The previous content and triggers are kept as a script version when they change
 func (db *DB) UpdateScript(id uint, name, description, scriptContent string, enabled bool, metadata datatypes.JSON, triggers []ScriptTrigger) error {
 	// Start transaction
 	return db.Transaction(func(tx *gorm.DB) error {
+		var current Script
+		if err := tx.Preload("Triggers").First(&current, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("script not found")
+			}
+			return fmt.Errorf("failed to load script: %w", err)
+		}
+		if err := saveScriptVersion(tx, &current, description, scriptContent, triggers); err != nil {
+			return err
+		}
+
 		// Update script fields
 		updates := map[string]interface{}{
 			"name":        name,
@@ -138,6 +154,7 @@ func (db *DB) UpdateScript(id uint, name, description, scriptContent string, ena
 		}
 
 		// Create new triggers
+		enabled := triggersEnabled(triggers)
 		for i := range triggers {
 			triggers[i].ScriptID = id
 			if err := tx.Create(&triggers[i]).Error; err != nil {
@@ -145,11 +162,87 @@ func (db *DB) UpdateScript(id uint, name, description, scriptContent string, ena
 			}
 		}
 
-		return nil
+		return restoreTriggersEnabled(tx, triggers, enabled)
+	})
+}
+
+// CloneScript copies a script and its triggers under a new name
+// The copy is always a manual (non-provisioned) script, so it can be edited
+// even when the source is managed by the config file. It starts disabled so
+// the same logic doesn't run twice until the copy has been changed
+func (db *DB) CloneScript(id uint, name string) (*Script, error) {
+	if name == "" {
+		return nil, fmt.Errorf("script name is required")
+	}
+
+	var clone *Script
+	err := db.primary().Transaction(func(tx *gorm.DB) error {
+		var source Script
+		if err := tx.Preload("Triggers").First(&source, id).Error; err != nil {
+			return err
+		}
+
+		triggers := make([]ScriptTrigger, len(source.Triggers))
+		for i, trigger := range source.Triggers {
+			triggers[i] = ScriptTrigger{
+				Type:     trigger.Type,
+				Topic:    trigger.Topic,
+				Priority: trigger.Priority,
+				Enabled:  trigger.Enabled,
+			}
+		}
+
+		clone = &Script{
+			Name:           name,
+			Description:    source.Description,
+			Content:        source.Content,
+			TimeoutSeconds: source.TimeoutSeconds,
+			Metadata:       source.Metadata,
+			Triggers:       triggers,
+		}
+		enabled := triggersEnabled(triggers)
+		if err := tx.Create(clone).Error; err != nil {
+			return fmt.Errorf("failed to create script: %w", err)
+		}
+
+		// GORM workaround: the default:true tag replaces a false enabled on insert
+		if err := tx.Model(clone).Update("enabled", false).Error; err != nil {
+			return fmt.Errorf("failed to set enabled=false: %w", err)
+		}
+		return restoreTriggersEnabled(tx, clone.Triggers, enabled)
 	})
+	if err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// triggersEnabled records each trigger's enabled flag before insert, which
+// replaces a false enabled with the default:true tag's value
+func triggersEnabled(triggers []ScriptTrigger) []bool {
+	enabled := make([]bool, len(triggers))
+	for i, trigger := range triggers {
+		enabled[i] = trigger.Enabled
+	}
+	return enabled
+}
+
+// restoreTriggersEnabled sets enabled=false on the inserted triggers that were
+// disabled before insert (see triggersEnabled)
+func restoreTriggersEnabled(tx *gorm.DB, triggers []ScriptTrigger, enabled []bool) error {
+	for i := range triggers {
+		if enabled[i] {
+			continue
+		}
+		if err := tx.Model(&triggers[i]).Update("enabled", false).Error; err != nil {
+			return fmt.Errorf("failed to set trigger enabled=false: %w", err)
+		}
+		triggers[i].Enabled = false
+	}
+	return nil
 }
 
-// DeleteScript deletes a script and cascades to triggers and logs
+// DeleteScript deletes a script and cascades to triggers, versions and logs
 func (db *DB) DeleteScript(id uint) error {
 	result := db.Delete(&Script{}, id)
 	if result.Error != nil {
@@ -160,6 +253,10 @@ func (db *DB) DeleteScript(id uint) error {
 		return fmt.Errorf("script not found")
 	}
 
+	if err := db.Where("script_id = ?", id).Delete(&ScriptVersion{}).Error; err != nil {
+		return fmt.Errorf("failed to delete script versions: %w", err)
+	}
+
 	return nil
 }
 
@@ -177,6 +274,25 @@ func (db *DB) UpdateScriptEnabled(id uint, enabled bool) error {
 	return nil
 }
 
+// SetScriptTriggerEnabled enables or disables a single trigger of a script
+func (db *DB) SetScriptTriggerEnabled(scriptID, triggerID uint, enabled bool) (*ScriptTrigger, error) {
+	var trigger ScriptTrigger
+	if err := db.Where("id = ? AND script_id = ?", triggerID, scriptID).First(&trigger).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTriggerNotFound
+		}
+		return nil, err
+	}
+
+	// Update by column: a struct update would skip false
+	if err := db.Model(&trigger).Update("enabled", enabled).Error; err != nil {
+		return nil, fmt.Errorf("failed to update trigger enabled status: %w", err)
+	}
+	trigger.Enabled = enabled
+
+	return &trigger, nil
+}
+
 // GetEnabledScriptsForTrigger retrieves all enabled scripts with matching triggers for a given event type and topic
 // This is the key function called by the script hook
 func (db *DB) GetEnabledScriptsForTrigger(triggerType, topic string) ([]Script, error) {
@@ -319,6 +435,7 @@ func (db *DB) UpdateProvisionedScript(id uint, name, description, scriptContent
 		}
 
 		// Create new triggers
+		enabled := triggersEnabled(triggers)
 		for i := range triggers {
 			triggers[i].ScriptID = id
 			if err := tx.Create(&triggers[i]).Error; err != nil {
@@ -326,7 +443,7 @@ func (db *DB) UpdateProvisionedScript(id uint, name, description, scriptContent
 			}
 		}
 
-		return nil
+		return restoreTriggersEnabled(tx, triggers, enabled)
 	})
 }
 
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Keys of the runtime-tunable settings
+const (
+	SettingMOTD            = "motd"             // Dashboard banner message, e.g. a maintenance notice
+	SettingScriptsDisabled = "scripts_disabled" // Global script kill switch
+)
+
+// Settings reads and writes server settings changed at runtime from the dashboard
+// Values are stored as text; the typed accessors convert them and return the
+// fallback for keys that were never set, so callers don't need to seed defaults
+type Settings struct {
+	db *DB
+}
+
+// Settings returns the settings stored in this database
+func (db *DB) Settings() *Settings {
+	return &Settings{db: db}
+}
+
+// Get returns the raw value stored for key
+// ok is false if the setting was never set
+func (s *Settings) Get(key string) (value string, ok bool, err error) {
+	var setting Setting
+	// Struct conditions get the column quoted; "key" is reserved in MySQL
+	if err := s.db.Where(&Setting{Key: key}).First(&setting).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return setting.Value, true, nil
+}
+
+// Set stores the raw value for key, replacing any previous value
+func (s *Settings) Set(key, value string) error {
+	setting := Setting{Key: key, Value: value}
+	return s.db.primary().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&setting).Error
+}
+
+// GetString returns the value of key, or fallback if it was never set
+func (s *Settings) GetString(key, fallback string) (string, error) {
+	value, ok, err := s.Get(key)
+	if err != nil || !ok {
+		return fallback, err
+	}
+	return value, nil
+}
+
+// SetString stores a string value for key
+func (s *Settings) SetString(key, value string) error {
+	return s.Set(key, value)
+}
+
+// GetBool returns the boolean value of key, or fallback if it was never set
+func (s *Settings) GetBool(key string, fallback bool) (bool, error) {
+	value, ok, err := s.Get(key)
+	if err != nil || !ok {
+		return fallback, err
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fallback, fmt.Errorf("setting %s is not a bool: %w", key, err)
+	}
+	return parsed, nil
+}
+
+// SetBool stores a boolean value for key
+func (s *Settings) SetBool(key string, value bool) error {
+	return s.Set(key, strconv.FormatBool(value))
+}
+
+// GetInt returns the integer value of key, or fallback if it was never set
+func (s *Settings) GetInt(key string, fallback int) (int, error) {
+	value, ok, err := s.Get(key)
+	if err != nil || !ok {
+		return fallback, err
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fallback, fmt.Errorf("setting %s is not an int: %w", key, err)
+	}
+	return parsed, nil
+}
+
+// SetInt stores an integer value for key
+func (s *Settings) SetInt(key string, value int) error {
+	return s.Set(key, strconv.Itoa(value))
+}
+
+// GetJSON decodes the value of key into v
+// ok is false, and v left untouched, if the setting was never set
+func (s *Settings) GetJSON(key string, v any) (ok bool, err error) {
+	value, ok, err := s.Get(key)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err := json.Unmarshal([]byte(value), v); err != nil {
+		return false, fmt.Errorf("setting %s is not valid JSON: %w", key, err)
+	}
+	return true, nil
+}
+
+// SetJSON stores v encoded as JSON for key
+func (s *Settings) SetJSON(key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode setting %s: %w", key, err)
+	}
+	return s.Set(key, string(data))
+}
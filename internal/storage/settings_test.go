@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"testing"
+)
+
+func TestSettings_TypedRoundTrip(t *testing.T) {
+	settings := setupTestDB(t).Settings()
+
+	t.Run("string", func(t *testing.T) {
+		if got, err := settings.GetString("banner", "none"); err != nil || got != "none" {
+			t.Errorf("GetString() unset = %q, %v; want fallback", got, err)
+		}
+		if err := settings.SetString("banner", "Maintenance at 22:00"); err != nil {
+			t.Fatalf("SetString() error = %v", err)
+		}
+		if got, err := settings.GetString("banner", "none"); err != nil || got != "Maintenance at 22:00" {
+			t.Errorf("GetString() = %q, %v; want the stored value", got, err)
+		}
+		// Setting an empty string is different from never setting it
+		if err := settings.SetString("banner", ""); err != nil {
+			t.Fatalf("SetString() error = %v", err)
+		}
+		if got, _ := settings.GetString("banner", "none"); got != "" {
+			t.Errorf("GetString() after clearing = %q, want empty", got)
+		}
+	})
+
+	t.Run("bool", func(t *testing.T) {
+		if got, err := settings.GetBool("paused", true); err != nil || !got {
+			t.Errorf("GetBool() unset = %v, %v; want fallback", got, err)
+		}
+		for _, want := range []bool{true, false} {
+			if err := settings.SetBool("paused", want); err != nil {
+				t.Fatalf("SetBool() error = %v", err)
+			}
+			if got, err := settings.GetBool("paused", !want); err != nil || got != want {
+				t.Errorf("GetBool() = %v, %v; want %v", got, err, want)
+			}
+		}
+	})
+
+	t.Run("int", func(t *testing.T) {
+		if got, err := settings.GetInt("limit", 7); err != nil || got != 7 {
+			t.Errorf("GetInt() unset = %d, %v; want fallback", got, err)
+		}
+		if err := settings.SetInt("limit", -42); err != nil {
+			t.Fatalf("SetInt() error = %v", err)
+		}
+		if got, err := settings.GetInt("limit", 7); err != nil || got != -42 {
+			t.Errorf("GetInt() = %d, %v; want -42", got, err)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		type window struct {
+			Start string   `json:"start"`
+			Days  []string `json:"days"`
+		}
+		var got window
+		if ok, err := settings.GetJSON("window", &got); err != nil || ok {
+			t.Errorf("GetJSON() unset = %v, %v; want not found", ok, err)
+		}
+		want := window{Start: "22:00", Days: []string{"sat", "sun"}}
+		if err := settings.SetJSON("window", want); err != nil {
+			t.Fatalf("SetJSON() error = %v", err)
+		}
+		if ok, err := settings.GetJSON("window", &got); err != nil || !ok {
+			t.Fatalf("GetJSON() = %v, %v; want found", ok, err)
+		}
+		if got.Start != want.Start || len(got.Days) != 2 || got.Days[1] != "sun" {
+			t.Errorf("GetJSON() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		if err := settings.SetString("mode", "drain"); err != nil {
+			t.Fatalf("SetString() error = %v", err)
+		}
+		if got, err := settings.GetBool("mode", false); err == nil || got {
+			t.Errorf("GetBool() on a string = %v, %v; want fallback and an error", got, err)
+		}
+		if got, err := settings.GetInt("mode", 3); err == nil || got != 3 {
+			t.Errorf("GetInt() on a string = %d, %v; want fallback and an error", got, err)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
+)
+
+// newGormLogger returns the GORM logger for the configured slow query threshold:
+// silent when it is 0, otherwise one that logs only queries taking at least that long
+func newGormLogger(slowThreshold time.Duration) logger.Interface {
+	if slowThreshold <= 0 {
+		return logger.Default.LogMode(logger.Silent) // Reduce log noise
+	}
+	return &slowQueryLogger{threshold: slowThreshold, log: slog.Default()}
+}
+
+// slowQueryLogger logs queries slower than threshold through slog, so they come
+// out as structured (JSON with LOG_FORMAT=json) lines like the rest of the logs.
+// Everything else GORM would log is dropped
+type slowQueryLogger struct {
+	threshold time.Duration
+	log       *slog.Logger
+}
+
+// LogMode is a no-op; the logger only reports slow queries
+func (l *slowQueryLogger) LogMode(logger.LogLevel) logger.Interface {
+	return l
+}
+
+func (l *slowQueryLogger) Info(context.Context, string, ...interface{})  {}
+func (l *slowQueryLogger) Warn(context.Context, string, ...interface{})  {}
+func (l *slowQueryLogger) Error(context.Context, string, ...interface{}) {}
+
+// Trace logs the statement if it took at least the threshold
+func (l *slowQueryLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	if elapsed < l.threshold {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []any{
+		"sql", sql,
+		"rows", rows,
+		"elapsed_ms", float64(elapsed.Microseconds()) / 1000,
+		"threshold", l.threshold,
+		"caller", utils.FileWithLineNum(),
+	}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	l.log.WarnContext(ctx, "Slow database query", attrs...)
+}
+
+// ParamsFilter keeps the bound values out of the logged SQL, as they can be
+// password hashes or other secrets
+func (l *slowQueryLogger) ParamsFilter(_ context.Context, sql string, _ ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
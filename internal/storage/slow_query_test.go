@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestSlowQueryLogger(t *testing.T) {
+	config := DefaultSQLiteConfig(":memory:")
+	config.SlowQueryThreshold = time.Nanosecond // Every query is slow
+	db, err := OpenWithCache(config, NewCacheWithRegistry(prometheus.NewRegistry()))
+	if err != nil {
+		t.Fatalf("OpenWithCache() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	slowLogger, ok := db.Logger.(*slowQueryLogger)
+	if !ok {
+		t.Fatalf("GORM logger = %T, want *slowQueryLogger", db.Logger)
+	}
+	slowLogger.log = slog.New(slog.NewJSONHandler(&buf, nil))
+
+	if _, err := db.CreateMQTTUser("slow-user", "secret-password", "", nil); err != nil {
+		t.Fatalf("CreateMQTTUser() error = %v", err)
+	}
+	if _, err := db.GetMQTTUserByUsername("slow-user"); err != nil {
+		t.Fatalf("GetMQTTUserByUsername() error = %v", err)
+	}
+
+	var found bool
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line is not JSON: %q", line)
+		}
+		sql, _ := entry["sql"].(string)
+		if entry["msg"] != "Slow database query" || !strings.Contains(sql, "mqtt_users") {
+			continue
+		}
+		found = true
+		if _, ok := entry["elapsed_ms"].(float64); !ok {
+			t.Errorf("slow query entry %v has no elapsed_ms", entry)
+		}
+		if strings.Contains(sql, "slow-user") {
+			t.Errorf("slow query SQL includes a bound value: %s", sql)
+		}
+	}
+	if !found {
+		t.Errorf("no slow query logged for mqtt_users, got:\n%s", buf.String())
+	}
+}
+
+func TestNewGormLogger_DisabledByDefault(t *testing.T) {
+	if _, ok := newGormLogger(0).(*slowQueryLogger); ok {
+		t.Error("newGormLogger(0) returned the slow query logger, want the silent one")
+	}
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StaticACLRule is a topic/permission rule held in memory rather than the
+// database, such as the anonymous ACL
+type StaticACLRule struct {
+	Topic      string
+	Permission string // "pub", "sub", or "pubsub"
+	Retain     bool   // Grants the "retain" action along with pub
+}
+
+// StaticACL checks a fixed set of rules with the same matching (wildcards and
+// ${username}/${clientid} placeholders) as database ACL rules
+type StaticACL struct {
+	rules    []StaticACLRule
+	matchers []*topicMatcher
+}
+
+// NewStaticACL compiles a fixed rule set
+func NewStaticACL(rules []StaticACLRule) (*StaticACL, error) {
+	acl := &StaticACL{
+		rules:    make([]StaticACLRule, len(rules)),
+		matchers: make([]*topicMatcher, len(rules)),
+	}
+	for i, rule := range rules {
+		rule.Topic = NormalizeTopicPattern(rule.Topic)
+		acl.rules[i] = rule
+		if rule.Topic == "" {
+			return nil, fmt.Errorf("rule %d: topic is required", i+1)
+		}
+		if rule.Permission != "pub" && rule.Permission != "sub" && rule.Permission != "pubsub" {
+			return nil, fmt.Errorf("rule %d: invalid permission '%s' (must be pub, sub, or pubsub)", i+1, rule.Permission)
+		}
+		acl.matchers[i] = compileTopicPattern(rule.Topic)
+	}
+	return acl, nil
+}
+
+// ParseStaticACL builds a StaticACL from a comma-separated list of
+// topic:permission pairs, e.g. "public/#:sub,devices/${clientid}/#:pubsub"
+// The permission follows the last colon, so topics may contain colons
+func ParseStaticACL(spec string) (*StaticACL, error) {
+	var rules []StaticACLRule
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		idx := strings.LastIndex(entry, ":")
+		if idx < 0 {
+			return nil, fmt.Errorf("invalid ACL entry '%s' (expected topic:permission)", entry)
+		}
+		rules = append(rules, StaticACLRule{Topic: entry[:idx], Permission: entry[idx+1:]})
+	}
+	return NewStaticACL(rules)
+}
+
+// Rules returns the rules in this ACL
+func (a *StaticACL) Rules() []StaticACLRule {
+	return a.rules
+}
+
+// CheckACL reports whether any rule grants the action on topic
+func (a *StaticACL) CheckACL(username, clientID, topic, action string) (bool, error) {
+	for i, rule := range a.rules {
+		if ruleAllows(rule.Permission, rule.Retain, action) && a.matchers[i].Match(topic, username, clientID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// HasMatchingACLRule reports whether any rule matches topic, whatever its permission
+func (a *StaticACL) HasMatchingACLRule(username, clientID, topic string) (bool, error) {
+	for _, matcher := range a.matchers {
+		if matcher.Match(topic, username, clientID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseStaticACL(t *testing.T) {
+	acl, err := ParseStaticACL("public/#:sub, devices/${clientid}/#:pubsub,urn:status:pub")
+	if err != nil {
+		t.Fatalf("ParseStaticACL() error = %v", err)
+	}
+
+	want := []StaticACLRule{
+		{Topic: "public/#", Permission: "sub"},
+		{Topic: "devices/${clientid}/#", Permission: "pubsub"},
+		{Topic: "urn:status", Permission: "pub"},
+	}
+	rules := acl.Rules()
+	if len(rules) != len(want) {
+		t.Fatalf("Rules() = %v, want %v", rules, want)
+	}
+	for i := range want {
+		if rules[i] != want[i] {
+			t.Errorf("rule %d = %+v, want %+v", i, rules[i], want[i])
+		}
+	}
+
+	for _, spec := range []string{"public/#", "public/#:write", ":sub"} {
+		if _, err := ParseStaticACL(spec); err == nil {
+			t.Errorf("ParseStaticACL(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestStaticACL_CheckACL(t *testing.T) {
+	acl, err := ParseStaticACL("public/#:sub,devices/${clientid}/#:pubsub")
+	if err != nil {
+		t.Fatalf("ParseStaticACL() error = %v", err)
+	}
+
+	tests := []struct {
+		clientID string
+		topic    string
+		action   string
+		want     bool
+	}{
+		{"c1", "public/news", "sub", true},
+		{"c1", "public/news", "pub", false},
+		{"c1", "devices/c1/temp", "pub", true},
+		{"c1", "devices/c2/temp", "pub", false},
+		{"c1", "private/data", "sub", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join([]string{tt.clientID, tt.topic, tt.action}, "|"), func(t *testing.T) {
+			got, err := acl.CheckACL("anonymous", tt.clientID, tt.topic, tt.action)
+			if err != nil {
+				t.Fatalf("CheckACL() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("CheckACL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package storage
+
+import "strings"
+
+// topicMatcher is an ACL topic pattern split into levels once, so matching a
+// topic on the publish path doesn't allocate
+// MatchTopic remains the reference implementation; Match must agree with
+// MatchTopic(replacePlaceholders(pattern, username, clientID), topic)
+type topicMatcher struct {
+	pattern         string
+	levels          []string
+	hasPlaceholders bool
+}
+
+// compileTopicPattern splits an ACL topic pattern into a reusable matcher
+func compileTopicPattern(pattern string) *topicMatcher {
+	return &topicMatcher{
+		pattern:         pattern,
+		levels:          strings.Split(pattern, "/"),
+		hasPlaceholders: strings.Contains(pattern, "${"),
+	}
+}
+
+// Match reports whether topic matches the pattern after placeholder expansion
+func (m *topicMatcher) Match(topic, username, clientID string) bool {
+	if m.hasPlaceholders && (!isPlainLevel(username) || !isPlainLevel(clientID)) {
+		// Values containing separators or wildcards change the pattern's
+		// structure, so defer to the reference implementation
+		return MatchTopic(replacePlaceholders(m.pattern, username, clientID), topic)
+	}
+
+	// Topics starting with $ are not matched by a leading wildcard [MQTT-4.7.2-1]
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(m.pattern, "#") || strings.HasPrefix(m.pattern, "+")) {
+		return false
+	}
+
+	rest := topic
+	exhausted := false // true once every topic level has been consumed
+
+	for i, level := range m.levels {
+		// Multi-level wildcard (#) must be last and matches everything
+		if level == "#" {
+			return i == len(m.levels)-1
+		}
+
+		// Check if we've run out of topic levels
+		if exhausted {
+			return false
+		}
+
+		// Take the next topic level
+		topicLevel := rest
+		if idx := strings.IndexByte(rest, '/'); idx >= 0 {
+			topicLevel, rest = rest[:idx], rest[idx+1:]
+		} else {
+			exhausted = true
+		}
+
+		// Single-level wildcard (+) matches any single level
+		if level == "+" {
+			continue
+		}
+
+		if m.hasPlaceholders && strings.Contains(level, "${") {
+			if !matchPlaceholderLevel(level, topicLevel, username, clientID) {
+				return false
+			}
+			continue
+		}
+
+		// Exact match required
+		if level != topicLevel {
+			return false
+		}
+	}
+
+	// If pattern has no wildcard at end, lengths must match
+	return exhausted
+}
+
+// matchPlaceholderLevel compares a pattern level containing placeholders with
+// a topic level. Whole-level placeholders (the common case) compare without
+// allocating; anything else expands the level first
+func matchPlaceholderLevel(level, topicLevel, username, clientID string) bool {
+	switch level {
+	case "${username}":
+		return topicLevel == username
+	case "${clientid}":
+		return topicLevel == clientID
+	}
+	return replacePlaceholders(level, username, clientID) == topicLevel
+}
+
+// isPlainLevel reports whether a placeholder value can be substituted
+// within a single topic level without changing the pattern's structure
+// (values that themselves contain placeholders are expanded again by
+// replacePlaceholders, so they take the reference path too)
+func isPlainLevel(value string) bool {
+	return !strings.ContainsAny(value, "/+#") && !strings.Contains(value, "${")
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// referenceMatch is the behaviour the compiled matcher must reproduce
+func referenceMatch(pattern, topic, username, clientID string) bool {
+	return MatchTopic(replacePlaceholders(pattern, username, clientID), topic)
+}
+
+func TestTopicMatcher(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		topic    string
+		username string
+		clientID string
+		want     bool
+	}{
+		{"sensor/+/temp", "sensor/1/temp", "", "", true},
+		{"sensor/+/temp", "sensor/1/2/temp", "", "", false},
+		{"sensor/#", "sensor", "", "", true},
+		{"#", "$SYS/broker", "", "", false},
+		{"$SYS/#", "$SYS/broker", "", "", true},
+		{"user/${username}/#", "user/alice/data", "alice", "c1", true},
+		{"user/${username}/#", "user/bob/data", "alice", "c1", false},
+		{"device/${clientid}", "device/c1", "alice", "c1", true},
+		{"home/${username}-${clientid}", "home/alice-c1", "alice", "c1", true},
+		// Values containing separators or wildcards fall back to expansion
+		{"user/${username}", "user/a/b", "a/b", "c1", true},
+		{"user/${username}/x", "user/any/x", "+", "c1", true},
+		// Chained expansion: a username containing ${clientid} is expanded again
+		{"user/${username}", "user/c1", "${clientid}", "c1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"|"+tt.topic, func(t *testing.T) {
+			got := compileTopicPattern(tt.pattern).Match(tt.topic, tt.username, tt.clientID)
+			if got != tt.want {
+				t.Errorf("Match(%q, %q, %q) = %v, want %v", tt.topic, tt.username, tt.clientID, got, tt.want)
+			}
+			if ref := referenceMatch(tt.pattern, tt.topic, tt.username, tt.clientID); got != ref {
+				t.Errorf("Match() = %v, MatchTopic() = %v", got, ref)
+			}
+		})
+	}
+}
+
+// TestTopicMatcherAgreesWithMatchTopic checks random patterns and topics built
+// from a small alphabet of levels so that matches are frequent
+func TestTopicMatcherAgreesWithMatchTopic(t *testing.T) {
+	patternLevels := []string{"a", "b", "+", "#", "$SYS", "", "${username}", "${clientid}", "x${username}"}
+	topicLevels := []string{"a", "b", "$SYS", "", "alice", "c1", "xalice"}
+	values := []string{"alice", "c1", "", "a/b", "+", "#", "${clientid}"}
+
+	rng := rand.New(rand.NewSource(1))
+	randomPath := func(levels []string) string {
+		parts := make([]string, 1+rng.Intn(4))
+		for i := range parts {
+			parts[i] = levels[rng.Intn(len(levels))]
+		}
+		return strings.Join(parts, "/")
+	}
+
+	for i := 0; i < 20000; i++ {
+		pattern := randomPath(patternLevels)
+		topic := randomPath(topicLevels)
+		username := values[rng.Intn(len(values))]
+		clientID := values[rng.Intn(len(values))]
+
+		got := compileTopicPattern(pattern).Match(topic, username, clientID)
+		if want := referenceMatch(pattern, topic, username, clientID); got != want {
+			t.Fatalf("Match(pattern=%q, topic=%q, username=%q, clientID=%q) = %v, MatchTopic() = %v",
+				pattern, topic, username, clientID, got, want)
+		}
+	}
+}
+
+func FuzzTopicMatcher(f *testing.F) {
+	f.Add("sensor/+/temp", "sensor/1/temp", "", "")
+	f.Add("#", "$SYS/broker", "", "")
+	f.Add("user/${username}/#", "user/alice/data", "alice", "c1")
+	f.Add("device/${clientid}", "device/a/b", "alice", "a/b")
+	f.Add("a/#/b", "a/x/b", "", "")
+
+	f.Fuzz(func(t *testing.T, pattern, topic, username, clientID string) {
+		got := compileTopicPattern(pattern).Match(topic, username, clientID)
+		if want := referenceMatch(pattern, topic, username, clientID); got != want {
+			t.Errorf("Match(pattern=%q, topic=%q, username=%q, clientID=%q) = %v, MatchTopic() = %v",
+				pattern, topic, username, clientID, got, want)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrTopicRouteNotFound is returned when no topic route has the given ID
+var ErrTopicRouteNotFound = errors.New("topic route not found")
+
+// ValidateTopicRoute checks that source is a valid topic filter and that target
+// can be filled from what it matches: target may use at most as many + as the
+// source, and may end with # only if the source does
+func ValidateTopicRoute(source, target string) error {
+	if source == "" || target == "" {
+		return fmt.Errorf("source and target are required")
+	}
+	if source == target {
+		return fmt.Errorf("source and target must differ")
+	}
+
+	sourcePlus, sourceHash, err := countWildcards(source)
+	if err != nil {
+		return fmt.Errorf("invalid source: %w", err)
+	}
+	targetPlus, targetHash, err := countWildcards(target)
+	if err != nil {
+		return fmt.Errorf("invalid target: %w", err)
+	}
+	if targetPlus > sourcePlus {
+		return fmt.Errorf("target has %d '+' wildcards but source only %d", targetPlus, sourcePlus)
+	}
+	if targetHash && !sourceHash {
+		return fmt.Errorf("target can only end with '#' if source does")
+	}
+	return nil
+}
+
+// countWildcards counts the + levels of filter and whether it ends with #
+func countWildcards(filter string) (plus int, hash bool, err error) {
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		switch {
+		case level == "+":
+			plus++
+		case level == "#":
+			if i != len(levels)-1 {
+				return 0, false, fmt.Errorf("'#' must be the last level")
+			}
+			hash = true
+		case strings.ContainsAny(level, "+#"):
+			return 0, false, fmt.Errorf("wildcards must fill a whole level")
+		}
+	}
+	return plus, hash, nil
+}
+
+// ListTopicRoutes returns all topic routes in creation order
+// Reads the primary so a reload right after a change sees it
+func (db *DB) ListTopicRoutes() ([]TopicRoute, error) {
+	var routes []TopicRoute
+	if err := db.primary().Order("id").Find(&routes).Error; err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// GetTopicRoute retrieves a topic route by ID
+func (db *DB) GetTopicRoute(id uint) (*TopicRoute, error) {
+	var route TopicRoute
+	if err := db.First(&route, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTopicRouteNotFound
+		}
+		return nil, err
+	}
+	return &route, nil
+}
+
+// CreateTopicRoute creates a topic route
+func (db *DB) CreateTopicRoute(source, target string, enabled bool) (*TopicRoute, error) {
+	if err := ValidateTopicRoute(source, target); err != nil {
+		return nil, err
+	}
+
+	route := &TopicRoute{Source: source, Target: target, Enabled: enabled}
+	if err := db.primary().Create(route).Error; err != nil {
+		return nil, fmt.Errorf("failed to create topic route: %w", err)
+	}
+	return route, nil
+}
+
+// UpdateTopicRoute replaces the source, target and enabled flag of a topic route
+func (db *DB) UpdateTopicRoute(id uint, source, target string, enabled bool) (*TopicRoute, error) {
+	if err := ValidateTopicRoute(source, target); err != nil {
+		return nil, err
+	}
+
+	route, err := db.GetTopicRoute(id)
+	if err != nil {
+		return nil, err
+	}
+	route.Source = source
+	route.Target = target
+	route.Enabled = enabled
+	if err := db.primary().Save(route).Error; err != nil {
+		return nil, fmt.Errorf("failed to update topic route: %w", err)
+	}
+	return route, nil
+}
+
+// DeleteTopicRoute deletes a topic route
+func (db *DB) DeleteTopicRoute(id uint) error {
+	result := db.primary().Delete(&TopicRoute{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTopicRouteNotFound
+	}
+	return nil
+}
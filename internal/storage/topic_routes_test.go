@@ -0,0 +1,55 @@
+package storage
+
+import "testing"
+
+func TestValidateTopicRoute(t *testing.T) {
+	tests := []struct {
+		source, target string
+		wantErr        bool
+	}{
+		{"legacy/#", "v2/#", false},
+		{"sensors/+/temp", "rooms/+/temperature", false},
+		{"a/+/b/+", "x/+", false}, // Dropping a captured level is fine
+		{"alerts/fire", "notify/all", false},
+		{"", "v2/#", true},
+		{"legacy/#", "", true},
+		{"legacy/#", "legacy/#", true},
+		{"legacy/#/x", "v2/#", true},
+		{"legacy/a+", "v2/+", true},
+		{"sensors/+", "rooms/+/+", true},
+		{"sensors/+", "rooms/#", true},
+	}
+	for _, tt := range tests {
+		err := ValidateTopicRoute(tt.source, tt.target)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateTopicRoute(%q, %q) error = %v, wantErr %v", tt.source, tt.target, err, tt.wantErr)
+		}
+	}
+}
+
+func TestTopicRouteCRUD(t *testing.T) {
+	db := setupTestDB(t)
+
+	route, err := db.CreateTopicRoute("legacy/#", "v2/#", false)
+	if err != nil {
+		t.Fatalf("CreateTopicRoute() error = %v", err)
+	}
+	if got, err := db.GetTopicRoute(route.ID); err != nil || got.Enabled {
+		t.Errorf("GetTopicRoute() = %+v, %v; want a disabled route", got, err)
+	}
+
+	if _, err := db.UpdateTopicRoute(route.ID, "legacy/+/temp", "v2/+/temperature", true); err != nil {
+		t.Fatalf("UpdateTopicRoute() error = %v", err)
+	}
+	routes, err := db.ListTopicRoutes()
+	if err != nil || len(routes) != 1 || routes[0].Source != "legacy/+/temp" || !routes[0].Enabled {
+		t.Errorf("ListTopicRoutes() = %+v, %v; want the updated route", routes, err)
+	}
+
+	if err := db.DeleteTopicRoute(route.ID); err != nil {
+		t.Fatalf("DeleteTopicRoute() error = %v", err)
+	}
+	if err := db.DeleteTopicRoute(route.ID); err == nil {
+		t.Error("DeleteTopicRoute() of a deleted route succeeded")
+	}
+}
@@ -74,6 +74,8 @@ func TestBridgeIntegration(t *testing.T) {
 		true,
 		30,
 		10,
+		nil, // No QoS cap
+		"",  // Default retain policy
 		nil,
 		bridgeTopics,
 	)
@@ -2,6 +2,7 @@ package test
 
 import (
 	"fmt"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -403,3 +404,86 @@ func TestMQTTIntegration_RetainedMessages(t *testing.T) {
 		t.Log("Note: Retained message not received (this may be expected in test environment)")
 	}
 }
+
+func TestMQTTIntegration_UnixSocket(t *testing.T) {
+	config := storage.DefaultSQLiteConfig(":memory:")
+	cache := storage.NewCacheWithRegistry(prometheus.NewRegistry())
+	db, err := storage.OpenWithCache(config, cache)
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	defer db.Close()
+
+	user, _ := db.CreateMQTTUser("localuser", "password123", "Local user", nil)
+	db.CreateACLRule(user.ID, "local/#", "pubsub")
+
+	socket := filepath.Join(t.TempDir(), "mqtt.sock")
+	server := mqttserver.New(&mqttserver.Config{UnixSocket: socket, RetainAvailable: true})
+	if err := server.AddAuthHook(auth.NewAuthHook(db, false)); err != nil {
+		t.Fatalf("failed to add auth hook: %v", err)
+	}
+	if err := server.AddACLHook(auth.NewACLHook(db)); err != nil {
+		t.Fatalf("failed to add ACL hook: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Close()
+
+	connect := func(clientID, username, password string) (mqtt.Client, error) {
+		opts := mqtt.NewClientOptions()
+		opts.AddBroker("unix://" + socket)
+		opts.SetClientID(clientID)
+		opts.SetUsername(username)
+		opts.SetPassword(password)
+		opts.SetConnectTimeout(2 * time.Second)
+		opts.SetAutoReconnect(false)
+
+		client := mqtt.NewClient(opts)
+		token := client.Connect()
+		token.Wait()
+		return client, token.Error()
+	}
+
+	t.Run("authentication applies", func(t *testing.T) {
+		if _, err := connect("unix-anon", "", ""); err == nil {
+			t.Error("anonymous connection over the unix socket succeeded, want rejection")
+		}
+		if _, err := connect("unix-bad", "localuser", "wrong"); err == nil {
+			t.Error("connection with a wrong password succeeded, want rejection")
+		}
+	})
+
+	t.Run("publish and subscribe", func(t *testing.T) {
+		client, err := connect("unix-client", "localuser", "password123")
+		if err != nil {
+			t.Fatalf("Connection failed: %v", err)
+		}
+		defer client.Disconnect(250)
+
+		details, err := server.GetClientDetails("unix-client")
+		if err != nil || details.Listener != mqttserver.ListenerUnix {
+			t.Errorf("client details = %+v, %v; want listener %s", details, err, mqttserver.ListenerUnix)
+		}
+
+		received := make(chan string, 2)
+		token := client.Subscribe("local/#", 0, func(_ mqtt.Client, msg mqtt.Message) {
+			received <- msg.Topic()
+		})
+		token.Wait()
+		if token.Error() != nil {
+			t.Fatalf("Subscribe failed: %v", token.Error())
+		}
+
+		client.Publish("local/topic", 0, false, "hello").Wait()
+
+		select {
+		case topic := <-received:
+			if topic != "local/topic" {
+				t.Errorf("received message on %q, want local/topic", topic)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timeout waiting for message")
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+
+	"github/bromq-dev/bromq/hooks/sessions"
+	"github/bromq-dev/bromq/internal/badgerstore"
+	mqttserver "github/bromq-dev/bromq/internal/mqtt"
+)
+
+// TestDurableSessions_RedeliverAfterRestart queues a QoS 1 message for an
+// offline persistent client, restarts the broker on the same store, and
+// checks the message is delivered when the client reconnects
+func TestDurableSessions_RedeliverAfterRestart(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	store := badgerstore.OpenInMemory(t)
+
+	start := func() *mqttserver.Server {
+		t.Helper()
+		server := mqttserver.New(&mqttserver.Config{TCPAddr: ":11894", DurableSessions: true})
+		if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+			t.Fatalf("failed to add auth hook: %v", err)
+		}
+		if err := server.AddHook(sessions.NewSessionHook(store), nil); err != nil {
+			t.Fatalf("failed to add session hook: %v", err)
+		}
+		if err := server.Start(); err != nil {
+			t.Fatalf("failed to start server: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		return server
+	}
+
+	received := make(chan mqtt.Message, 1)
+	connect := func() mqtt.Client {
+		t.Helper()
+		opts := mqtt.NewClientOptions()
+		opts.AddBroker("tcp://localhost:11894")
+		opts.SetClientID("durable-device")
+		opts.SetCleanSession(false)
+		opts.SetAutoReconnect(false)
+		opts.SetConnectTimeout(2 * time.Second)
+		// Messages of a resumed session arrive without a subscribe call
+		opts.SetDefaultPublishHandler(func(_ mqtt.Client, msg mqtt.Message) {
+			received <- msg
+		})
+		client := mqtt.NewClient(opts)
+		if token := client.Connect(); !token.WaitTimeout(2*time.Second) || token.Error() != nil {
+			t.Fatalf("failed to connect: %v", token.Error())
+		}
+		return client
+	}
+
+	// Subscribe with a persistent session, then go offline
+	server := start()
+	client := connect()
+	if token := client.Subscribe("alerts/#", 1, nil); !token.WaitTimeout(2*time.Second) || token.Error() != nil {
+		t.Fatalf("failed to subscribe: %v", token.Error())
+	}
+	client.Disconnect(100)
+	time.Sleep(100 * time.Millisecond)
+
+	// Queued for the offline client
+	if err := server.Publish("alerts/fire", []byte("evacuate"), false, 1); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	_ = server.Close()
+
+	// Restart and reconnect
+	server = start()
+	defer server.Close()
+	client = connect()
+	defer client.Disconnect(100)
+
+	select {
+	case msg := <-received:
+		if msg.Topic() != "alerts/fire" || string(msg.Payload()) != "evacuate" {
+			t.Errorf("received %s: %s, want alerts/fire: evacuate", msg.Topic(), msg.Payload())
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("queued message was not redelivered after restart")
+	}
+
+	// Acknowledged, so nothing is left to redeliver
+	time.Sleep(100 * time.Millisecond)
+	if inflight, err := store.GetInflightMessages(); err != nil || len(inflight) != 0 {
+		t.Errorf("stored inflight messages = %d, %v; want none after delivery", len(inflight), err)
+	}
+}
+
+// TestDurableSessions_CleanClientNotStored checks a clean session client
+// leaves nothing in the session store
+func TestDurableSessions_CleanClientNotStored(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	store := badgerstore.OpenInMemory(t)
+	server := mqttserver.New(&mqttserver.Config{TCPAddr: ":11895", DurableSessions: true})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatalf("failed to add auth hook: %v", err)
+	}
+	if err := server.AddHook(sessions.NewSessionHook(store), nil); err != nil {
+		t.Fatalf("failed to add session hook: %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("failed to start server: %v", err)
+	}
+	defer server.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker("tcp://localhost:11895")
+	opts.SetClientID("clean-device")
+	opts.SetCleanSession(true)
+	opts.SetAutoReconnect(false)
+	opts.SetConnectTimeout(2 * time.Second)
+	client := mqtt.NewClient(opts)
+	if token := client.Connect(); !token.WaitTimeout(2*time.Second) || token.Error() != nil {
+		t.Fatalf("failed to connect: %v", token.Error())
+	}
+	defer client.Disconnect(100)
+
+	received := make(chan mqtt.Message, 1)
+	if token := client.Subscribe("alerts/#", 1, func(_ mqtt.Client, msg mqtt.Message) {
+		received <- msg
+	}); !token.WaitTimeout(2*time.Second) || token.Error() != nil {
+		t.Fatalf("failed to subscribe: %v", token.Error())
+	}
+	if err := server.Publish("alerts/fire", []byte("evacuate"), false, 1); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+	select {
+	case <-received:
+	case <-time.After(3 * time.Second):
+		t.Fatal("message was not delivered")
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// Checked while still connected, before a disconnect could clean up
+	if clients, err := store.GetSessionClients(); err != nil || len(clients) != 0 {
+		t.Errorf("stored sessions = %d, %v; want none for a clean client", len(clients), err)
+	}
+	if subs, err := store.GetSessionSubscriptions(); err != nil || len(subs) != 0 {
+		t.Errorf("stored subscriptions = %d, %v; want none for a clean client", len(subs), err)
+	}
+	if inflight, err := store.GetInflightMessages(); err != nil || len(inflight) != 0 {
+		t.Errorf("stored inflight messages = %d, %v; want none for a clean client", len(inflight), err)
+	}
+}
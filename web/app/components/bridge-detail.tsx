@@ -210,6 +210,16 @@ export function BridgeDetail({ bridgeId }: BridgeDetailProps) {
               <p className="text-muted-foreground text-sm">Clean Session</p>
               <p className="text-sm">{bridge.clean_session ? 'Yes' : 'No'}</p>
             </div>
+            <div className="grid grid-cols-2 gap-4">
+              <div>
+                <p className="text-muted-foreground text-sm">Max QoS</p>
+                <p className="text-sm">{bridge.max_qos ?? 'No limit'}</p>
+              </div>
+              <div>
+                <p className="text-muted-foreground text-sm">Retain Flag</p>
+                <p className="text-sm capitalize">{bridge.retain_policy || 'preserve'}</p>
+              </div>
+            </div>
           </CardContent>
         </Card>
 
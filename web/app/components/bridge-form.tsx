@@ -5,7 +5,14 @@ import { Input } from '~/components/ui/input'
 import { Field, FieldLabel, FieldError } from '~/components/ui/field'
 import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from '~/components/ui/select'
 import { Card, CardContent, CardDescription, CardHeader, CardTitle } from '~/components/ui/card'
-import type { Bridge, BridgeTopicRequest, CreateBridgeRequest, UpdateBridgeRequest } from '~/lib/api'
+import {
+  api,
+  type Bridge,
+  type BridgeTestResult,
+  type BridgeTopicRequest,
+  type CreateBridgeRequest,
+  type UpdateBridgeRequest,
+} from '~/lib/api'
 
 interface BridgeFormProps {
   mode: 'create' | 'edit'
@@ -26,6 +33,8 @@ export function BridgeForm({ mode, initialData, onSubmit, isSubmitting, error }:
   const [cleanSession, setCleanSession] = useState(initialData?.clean_session ?? true)
   const [keepAlive, setKeepAlive] = useState(initialData?.keep_alive?.toString() || '60')
   const [connectionTimeout, setConnectionTimeout] = useState(initialData?.connection_timeout?.toString() || '30')
+  const [maxQoS, setMaxQoS] = useState(initialData?.max_qos?.toString() ?? 'none')
+  const [retainPolicy, setRetainPolicy] = useState<'preserve' | 'clear'>(initialData?.retain_policy ?? 'preserve')
 
   // Topic mappings
   const [topics, setTopics] = useState<BridgeTopicRequest[]>(
@@ -50,12 +59,40 @@ export function BridgeForm({ mode, initialData, onSubmit, isSubmitting, error }:
       clean_session: cleanSession,
       keep_alive: parseInt(keepAlive, 10),
       connection_timeout: parseInt(connectionTimeout, 10),
+      max_qos: maxQoS === 'none' ? undefined : parseInt(maxQoS, 10),
+      retain_policy: retainPolicy,
       topics,
     }
 
     onSubmit(data)
   }
 
+  const [testResult, setTestResult] = useState<BridgeTestResult | null>(null)
+  const [isTesting, setIsTesting] = useState(false)
+
+  const handleTestConnection = async () => {
+    setIsTesting(true)
+    setTestResult(null)
+    try {
+      const result = await api.testBridgeConnection({
+        name,
+        host: remoteHost,
+        port: parseInt(remotePort, 10),
+        username: remoteUsername || undefined,
+        password: remotePassword || undefined,
+        clean_session: true,
+        keep_alive: parseInt(keepAlive, 10),
+        connection_timeout: parseInt(connectionTimeout, 10),
+        topics: [],
+      })
+      setTestResult(result)
+    } catch (err) {
+      setTestResult({ success: false, error: err instanceof Error ? err.message : 'Connection test failed', duration_ms: 0 })
+    } finally {
+      setIsTesting(false)
+    }
+  }
+
   const addTopic = () => {
     setTopics([...topics, { local_pattern: '', remote_pattern: '', direction: 'both', qos: 0 }])
   }
@@ -184,6 +221,36 @@ export function BridgeForm({ mode, initialData, onSubmit, isSubmitting, error }:
               </Select>
             </Field>
           </div>
+
+          <div className="grid grid-cols-2 gap-4">
+            <Field>
+              <FieldLabel htmlFor="max-qos">Max QoS</FieldLabel>
+              <Select value={maxQoS} onValueChange={setMaxQoS}>
+                <SelectTrigger id="max-qos">
+                  <SelectValue />
+                </SelectTrigger>
+                <SelectContent>
+                  <SelectItem value="none">No limit</SelectItem>
+                  <SelectItem value="0">0 - At most once</SelectItem>
+                  <SelectItem value="1">1 - At least once</SelectItem>
+                  <SelectItem value="2">2 - Exactly once</SelectItem>
+                </SelectContent>
+              </Select>
+            </Field>
+
+            <Field>
+              <FieldLabel htmlFor="retain-policy">Retain Flag</FieldLabel>
+              <Select value={retainPolicy} onValueChange={(v) => setRetainPolicy(v as 'preserve' | 'clear')}>
+                <SelectTrigger id="retain-policy">
+                  <SelectValue />
+                </SelectTrigger>
+                <SelectContent>
+                  <SelectItem value="preserve">Preserve</SelectItem>
+                  <SelectItem value="clear">Clear</SelectItem>
+                </SelectContent>
+              </Select>
+            </Field>
+          </div>
         </CardContent>
       </Card>
 
@@ -286,7 +353,18 @@ export function BridgeForm({ mode, initialData, onSubmit, isSubmitting, error }:
 
       {error && <FieldError>{error}</FieldError>}
 
+      {testResult && (
+        <p className={testResult.success ? 'text-sm text-green-600' : 'text-destructive text-sm'}>
+          {testResult.success
+            ? `Connected to remote broker in ${testResult.duration_ms} ms`
+            : `Connection failed: ${testResult.error}`}
+        </p>
+      )}
+
       <div className="flex justify-end gap-2">
+        <Button type="button" variant="outline" onClick={handleTestConnection} disabled={isTesting || !remoteHost}>
+          {isTesting ? 'Testing...' : 'Test Connection'}
+        </Button>
         <Button type="submit" disabled={isSubmitting}>
           {isSubmitting ? 'Saving...' : mode === 'create' ? 'Create Bridge' : 'Update Bridge'}
         </Button>
@@ -7,6 +7,7 @@ export interface DashboardUser {
   id: number
   username: string
   role: 'viewer' | 'admin'
+  last_login_at?: string
   created_at: string
 }
 
@@ -16,11 +17,28 @@ export interface MQTTUser {
   username: string
   description?: string
   metadata?: Record<string, any>
+  allowed_cidrs?: string[]
+  denied_cidrs?: string[]
   provisioned_from_config: boolean
+  version: number
   created_at: string
   updated_at: string
 }
 
+// BulkACLResponse - Per-item outcome of a bulk ACL request
+export interface BulkACLResponse {
+  results: {
+    index: number
+    id?: number
+    status: 'created' | 'deleted' | 'skipped' | 'failed'
+    error?: string
+    rule?: ACLRule
+  }[]
+  succeeded: number
+  skipped: number
+  failed: number
+}
+
 // MQTTClient - Connected device tracking
 export interface MQTTClient {
   id: number
@@ -33,6 +51,25 @@ export interface MQTTClient {
   last_seen: string
   is_active: boolean
   metadata?: Record<string, any>
+  protocol_version?: number // From the last CONNECT packet: 3, 4 (v3.1.1) or 5
+  clean_start?: boolean
+  keepalive?: number
+  remote_ip?: string
+  queue_depth?: number // QoS 1/2 messages awaiting delivery (details endpoint only)
+  queue_limit?: number
+}
+
+export interface MQTTSession {
+  client_id: string
+  username?: string
+  connected: boolean
+  clean: boolean
+  protocol_version: number
+  disconnected_at?: number // Unix time, unset while connected
+  subscriptions: { topic: string; qos: number }[]
+  inflight_count: number
+  queued_count: number
+  persisted: boolean // Stored by the session persistence hook
 }
 
 export interface ACLRule {
@@ -40,7 +77,17 @@ export interface ACLRule {
   mqtt_user_id: number
   topic: string
   permission: 'pub' | 'sub' | 'pubsub'
+  retain: boolean // May publish retained messages (enforced with MQTT_RETAIN_REQUIRES_PERMISSION)
   provisioned_from_config: boolean
+  version: number
+}
+
+// ExpandedACLPattern - Topic pattern with placeholders resolved for one client
+export interface ExpandedACLPattern {
+  pattern: string
+  username: string
+  client_id: string
+  expanded: string
 }
 
 // BridgeTopic - Topic mapping for MQTT bridge
@@ -73,6 +120,8 @@ export interface Bridge {
   clean_session: boolean
   keep_alive: number
   connection_timeout: number
+  max_qos?: number // Caps forwarded QoS (unset = no cap)
+  retain_policy: 'preserve' | 'clear'
   provisioned_from_config: boolean
   metadata?: Record<string, any>
   created_at: string
@@ -80,6 +129,22 @@ export interface Bridge {
   topics: BridgeTopic[]
 }
 
+// TopicRoute - Local republish rule (e.g. legacy/# -> v2/#), no bridge involved
+export interface TopicRoute {
+  id: number
+  source: string
+  target: string
+  enabled: boolean
+  created_at: string
+  updated_at: string
+}
+
+export interface TopicRouteRequest {
+  source: string
+  target: string
+  enabled?: boolean // Defaults to true
+}
+
 // CreateBridgeRequest - Request to create a bridge
 export interface CreateBridgeRequest {
   name: string
@@ -91,6 +156,8 @@ export interface CreateBridgeRequest {
   clean_session: boolean
   keep_alive: number
   connection_timeout: number
+  max_qos?: number
+  retain_policy?: 'preserve' | 'clear'
   metadata?: Record<string, any>
   topics: BridgeTopicRequest[]
 }
@@ -106,10 +173,19 @@ export interface UpdateBridgeRequest {
   clean_session: boolean
   keep_alive: number
   connection_timeout: number
+  max_qos?: number
+  retain_policy?: 'preserve' | 'clear'
   metadata?: Record<string, any>
   topics: BridgeTopicRequest[]
 }
 
+// BridgeTestResult - Outcome of a bridge connection test
+export interface BridgeTestResult {
+  success: boolean
+  error?: string
+  duration_ms: number
+}
+
 // Script - JavaScript automation script
 export interface ScriptTrigger {
   id: number
@@ -134,6 +210,37 @@ export interface Script {
   triggers: ScriptTrigger[]
 }
 
+// ScriptSyntaxError - Problem found when validating a script (line/column start at 1, 0 if unknown)
+export interface ScriptSyntaxError {
+  line: number
+  column: number
+  message: string
+}
+
+// ScriptVersion - Snapshot of a script's content and triggers before an update
+export interface ScriptVersion {
+  id: number
+  script_id: number
+  version: number
+  description: string
+  content: string
+  triggers: Pick<ScriptTrigger, 'type' | 'topic' | 'priority' | 'enabled'>[]
+  created_at: string
+}
+
+// ScriptStats - Execution counters of a script since the server started
+export interface ScriptStats {
+  script_id: number
+  executions: number
+  failures: number
+  timeouts: number
+  total_duration_ms: number
+  avg_duration_ms: number
+  max_duration_ms: number
+  last_executed_at?: string
+  last_error?: string
+}
+
 export interface CreateScriptRequest {
   name: string
   description?: string
@@ -166,6 +273,7 @@ export interface ScriptLog {
   level: 'debug' | 'info' | 'warn' | 'error'
   message: string
   context?: Record<string, any>
+  fields?: Record<string, any>
   execution_time_ms: number
   created_at: string
 }
@@ -216,9 +324,39 @@ export interface SubscriptionInfo {
   qos: number
 }
 
+export interface ThroughputBucket {
+  start: string
+  messages_in: number
+  messages_out: number
+  bytes_in: number
+  bytes_out: number
+}
+
+export interface Throughput {
+  bucket_seconds: number
+  buckets: ThroughputBucket[] // Oldest first; the last one is still filling
+  messages_in_per_sec: number
+  messages_out_per_sec: number
+  bytes_in_per_sec: number
+  bytes_out_per_sec: number
+}
+
+export interface Summary {
+  mqtt_users: number
+  clients: number
+  active_clients: number
+  acl_rules: number
+  bridges: number
+  scripts: number
+  enabled_scripts: number
+  bridges_connected: number
+  retained_messages: number
+}
+
 export interface Metrics {
   uptime: number
   connected_clients: number
+  max_clients: number
   total_clients: number
   messages_received: number
   messages_sent: number
@@ -229,6 +367,17 @@ export interface Metrics {
   bytes_sent: number
   subscriptions_total: number
   retained_messages: number
+  scripts_disabled: boolean
+  retained_bytes: number
+}
+
+export interface LastValue {
+  topic: string
+  payload: string
+  qos: number
+  retain: boolean
+  client_id: string
+  received_at: string
 }
 
 export interface PrometheusMetric {
@@ -262,6 +411,13 @@ export interface PaginationMetadata {
   total_pages: number
 }
 
+// FilterSubscriptions - One subscribed topic filter and its subscribers
+export interface FilterSubscriptions {
+  filter: string
+  subscribers: number
+  clients: { client_id: string; username?: string; qos: number }[]
+}
+
 export interface PaginatedResponse<T> {
   data: T[]
   pagination: PaginationMetadata
@@ -323,6 +479,28 @@ class APIClient {
     return result
   }
 
+  async logout(): Promise<void> {
+    return this.request<void>('/auth/logout', { method: 'POST' })
+  }
+
+  async getMe(): Promise<DashboardUser> {
+    return this.request<DashboardUser>('/auth/me')
+  }
+
+  // Dashboard banner (public, so the login page can show it); empty when unset
+  async getMotd(): Promise<string> {
+    const { message } = await this.request<{ message: string }>('/motd')
+    return message
+  }
+
+  async updateMotd(message: string): Promise<string> {
+    const response = await this.request<{ message: string }>('/admin/settings/motd', {
+      method: 'PUT',
+      body: JSON.stringify({ message }),
+    })
+    return response.message
+  }
+
   async changePassword(currentPassword: string, newPassword: string): Promise<void> {
     return this.request<void>('/auth/change-password', {
       method: 'PUT',
@@ -389,20 +567,30 @@ class APIClient {
     return this.request<MQTTUser>(`/mqtt/users/${id}`)
   }
 
-  async createMQTTUser(username: string, password: string, description?: string, metadata?: Record<string, any>): Promise<MQTTUser> {
+  async createMQTTUser(username: string, password: string, description?: string, metadata?: Record<string, any>, applyDefaultAcl?: boolean): Promise<MQTTUser> {
     return this.request<MQTTUser>('/mqtt/users', {
       method: 'POST',
-      body: JSON.stringify({ username, password, description, metadata }),
+      body: JSON.stringify({ username, password, description, metadata, applyDefaultAcl }),
     })
   }
 
-  async updateMQTTUser(id: number, username: string, description?: string, metadata?: Record<string, any>): Promise<MQTTUser> {
+  // Pass the version the edit started from to fail with 412 if someone else saved first
+  async updateMQTTUser(id: number, username: string, description?: string, metadata?: Record<string, any>, version?: number): Promise<MQTTUser> {
     return this.request<MQTTUser>(`/mqtt/users/${id}`, {
       method: 'PUT',
+      headers: version ? { 'If-Match': `"${version}"` } : undefined,
       body: JSON.stringify({ username, description, metadata }),
     })
   }
 
+  // JSON Merge Patch: only the given fields change, and null metadata keys are removed
+  async patchMQTTUser(id: number, patch: { username?: string; description?: string; metadata?: Record<string, any> | null }): Promise<MQTTUser> {
+    return this.request<MQTTUser>(`/mqtt/users/${id}`, {
+      method: 'PATCH',
+      body: JSON.stringify(patch),
+    })
+  }
+
   async updateMQTTUserPassword(id: number, password: string): Promise<void> {
     return this.request<void>(`/mqtt/users/${id}/password`, {
       method: 'PUT',
@@ -416,6 +604,12 @@ class APIClient {
     })
   }
 
+  async copyACLRules(targetId: number, sourceId: number): Promise<{ copied: number; skipped: number }> {
+    return this.request<{ copied: number; skipped: number }>(`/mqtt/users/${targetId}/acl/copy-from/${sourceId}`, {
+      method: 'POST',
+    })
+  }
+
   // MQTT Clients (Connected device tracking)
   async getMQTTClients(params?: PaginationParams & { activeOnly?: boolean }): Promise<PaginatedResponse<MQTTClient>> {
     const queryString = this.buildQueryString(params)
@@ -429,6 +623,10 @@ class APIClient {
     return this.request<MQTTClient>(`/mqtt/clients/${clientId}`)
   }
 
+  async getMQTTSession(clientId: string): Promise<MQTTSession> {
+    return this.request<MQTTSession>(`/mqtt/sessions/${encodeURIComponent(clientId)}`)
+  }
+
   async updateMQTTClientMetadata(clientId: string, metadata: Record<string, any>): Promise<void> {
     return this.request<void>(`/mqtt/clients/${clientId}/metadata`, {
       method: 'PUT',
@@ -443,30 +641,46 @@ class APIClient {
   }
 
   // ACL
-  async getACLRules(params?: PaginationParams): Promise<PaginatedResponse<ACLRule>> {
-    const queryString = this.buildQueryString(params)
+  async getACLRules(params?: PaginationParams & { mqttUserId?: number; permission?: string }): Promise<PaginatedResponse<ACLRule>> {
+    const query = new URLSearchParams(this.buildQueryString(params))
+    if (params?.mqttUserId) query.append('mqttUserId', params.mqttUserId.toString())
+    if (params?.permission) query.append('permission', params.permission)
+    const queryString = query.toString() ? `?${query}` : ''
     return this.request<PaginatedResponse<ACLRule>>(`/acl${queryString}`)
   }
 
+  async expandACLPattern(pattern: string, username: string, clientId: string): Promise<ExpandedACLPattern> {
+    const query = new URLSearchParams({ pattern, username, clientId })
+    return this.request<ExpandedACLPattern>(`/acl/expand?${query}`)
+  }
+
+  async getACLRule(id: number): Promise<ACLRule & { mqtt_user: MQTTUser }> {
+    return this.request<ACLRule & { mqtt_user: MQTTUser }>(`/acl/${id}`)
+  }
+
   async createACLRule(
     mqtt_user_id: number,
     topic: string,
-    permission: 'pub' | 'sub' | 'pubsub'
+    permission: 'pub' | 'sub' | 'pubsub',
+    retain?: boolean
   ): Promise<ACLRule> {
     return this.request<ACLRule>('/acl', {
       method: 'POST',
-      body: JSON.stringify({ mqtt_user_id, topic, permission }),
+      body: JSON.stringify({ mqtt_user_id, topic, permission, retain }),
     })
   }
 
   async updateACLRule(
     id: number,
     topic: string,
-    permission: 'pub' | 'sub' | 'pubsub'
+    permission: 'pub' | 'sub' | 'pubsub',
+    version?: number,
+    retain?: boolean
   ): Promise<ACLRule> {
     return this.request<ACLRule>(`/acl/${id}`, {
       method: 'PUT',
-      body: JSON.stringify({ topic, permission }),
+      headers: version ? { 'If-Match': `"${version}"` } : undefined,
+      body: JSON.stringify({ topic, permission, retain }),
     })
   }
 
@@ -476,6 +690,22 @@ class APIClient {
     })
   }
 
+  async createACLRules(
+    rules: { mqtt_user_id: number; topic: string; permission: 'pub' | 'sub' | 'pubsub'; retain?: boolean }[]
+  ): Promise<BulkACLResponse> {
+    return this.request<BulkACLResponse>('/acl/bulk', {
+      method: 'POST',
+      body: JSON.stringify({ rules }),
+    })
+  }
+
+  async deleteACLRules(ids: number[]): Promise<BulkACLResponse> {
+    return this.request<BulkACLResponse>('/acl/bulk/delete', {
+      method: 'POST',
+      body: JSON.stringify({ ids }),
+    })
+  }
+
   // Bridges
   async getBridges(params?: PaginationParams): Promise<PaginatedResponse<Bridge>> {
     const queryString = this.buildQueryString(params)
@@ -500,12 +730,51 @@ class APIClient {
     })
   }
 
+  async cloneBridge(id: number, name: string, clientId?: string): Promise<Bridge> {
+    return this.request<Bridge>(`/bridges/${id}/clone`, {
+      method: 'POST',
+      body: JSON.stringify({ name, client_id: clientId }),
+    })
+  }
+
+  async testBridgeConnection(data: CreateBridgeRequest): Promise<BridgeTestResult> {
+    return this.request<BridgeTestResult>('/bridges/test', {
+      method: 'POST',
+      body: JSON.stringify(data),
+    })
+  }
+
   async deleteBridge(id: number): Promise<void> {
     return this.request<void>(`/bridges/${id}`, {
       method: 'DELETE',
     })
   }
 
+  // Topic routes
+  async getTopicRoutes(): Promise<TopicRoute[]> {
+    return this.request<TopicRoute[]>('/topic-routes')
+  }
+
+  async createTopicRoute(data: TopicRouteRequest): Promise<TopicRoute> {
+    return this.request<TopicRoute>('/topic-routes', {
+      method: 'POST',
+      body: JSON.stringify(data),
+    })
+  }
+
+  async updateTopicRoute(id: number, data: TopicRouteRequest): Promise<TopicRoute> {
+    return this.request<TopicRoute>(`/topic-routes/${id}`, {
+      method: 'PUT',
+      body: JSON.stringify(data),
+    })
+  }
+
+  async deleteTopicRoute(id: number): Promise<void> {
+    return this.request<void>(`/topic-routes/${id}`, {
+      method: 'DELETE',
+    })
+  }
+
   // Scripts
   async getScripts(params?: PaginationParams): Promise<PaginatedResponse<Script>> {
     const queryString = this.buildQueryString(params)
@@ -530,6 +799,66 @@ class APIClient {
     })
   }
 
+  async validateScript(content: string): Promise<{ valid: boolean; errors: ScriptSyntaxError[] }> {
+    return this.request<{ valid: boolean; errors: ScriptSyntaxError[] }>('/scripts/validate', {
+      method: 'POST',
+      body: JSON.stringify({ content }),
+    })
+  }
+
+  async getScriptVersions(id: number): Promise<ScriptVersion[]> {
+    return this.request<ScriptVersion[]>(`/scripts/${id}/versions`)
+  }
+
+  async repairProvisionedFlags(): Promise<{ users: number; acl_rules: number; bridges: number; scripts: number }> {
+    return this.request<{ users: number; acl_rules: number; bridges: number; scripts: number }>('/admin/repair', { method: 'POST' })
+  }
+
+  // Delete records of clients disconnected for longer than olderThan (e.g. 30d)
+  async reapInactiveClients(olderThan: string): Promise<{ deleted: number; last_seen_before: string }> {
+    return this.request<{ deleted: number; last_seen_before: string }>(
+      `/admin/clients/reap?olderThan=${encodeURIComponent(olderThan)}`,
+      { method: 'POST' }
+    )
+  }
+
+  // Resync the broker's retained messages with storage
+  async reloadRetained(): Promise<{ loaded: number; removed: number }> {
+    return this.request<{ loaded: number; removed: number }>('/admin/retained/reload', { method: 'POST' })
+  }
+
+  async disableAllScripts(): Promise<{ scripts_disabled: boolean }> {
+    return this.request<{ scripts_disabled: boolean }>('/admin/scripts/disable-all', { method: 'POST' })
+  }
+
+  async enableAllScripts(): Promise<{ scripts_disabled: boolean }> {
+    return this.request<{ scripts_disabled: boolean }>('/admin/scripts/enable-all', { method: 'POST' })
+  }
+
+  async getScriptStats(id: number): Promise<ScriptStats> {
+    return this.request<ScriptStats>(`/scripts/${id}/stats`)
+  }
+
+  async rollbackScript(id: number, version: number): Promise<Script> {
+    return this.request<Script>(`/scripts/${id}/rollback/${version}`, {
+      method: 'POST',
+    })
+  }
+
+  async cloneScript(id: number, name: string): Promise<Script> {
+    return this.request<Script>(`/scripts/${id}/clone`, {
+      method: 'POST',
+      body: JSON.stringify({ name }),
+    })
+  }
+
+  async setScriptTriggerEnabled(id: number, triggerId: number, enabled: boolean): Promise<ScriptTrigger> {
+    return this.request<ScriptTrigger>(`/scripts/${id}/triggers/${triggerId}/enable`, {
+      method: 'PUT',
+      body: JSON.stringify({ enabled }),
+    })
+  }
+
   async deleteScript(id: number): Promise<void> {
     return this.request<void>(`/scripts/${id}`, {
       method: 'DELETE',
@@ -545,17 +874,46 @@ class APIClient {
 
   async getScriptLogs(
     id: number,
-    params?: { page?: number; page_size?: number; level?: string }
+    params?: { page?: number; page_size?: number; level?: string; fields?: Record<string, string> }
   ): Promise<PaginatedResponse<ScriptLog>> {
     const queryParams = new URLSearchParams()
     if (params?.page) queryParams.append('page', params.page.toString())
     if (params?.page_size) queryParams.append('page_size', params.page_size.toString())
     if (params?.level) queryParams.append('level', params.level)
+    for (const [name, value] of Object.entries(params?.fields ?? {})) {
+      queryParams.append('field', `${name}:${value}`)
+    }
 
     const queryString = queryParams.toString() ? `?${queryParams.toString()}` : ''
     return this.request<PaginatedResponse<ScriptLog>>(`/scripts/${id}/logs${queryString}`)
   }
 
+  async searchScriptLogs(params?: {
+    page?: number
+    page_size?: number
+    script_ids?: number[]
+    level?: string
+    search?: string
+    since?: string
+    until?: string
+    fields?: Record<string, string>
+  }): Promise<PaginatedResponse<ScriptLog>> {
+    const queryParams = new URLSearchParams()
+    if (params?.page) queryParams.append('page', params.page.toString())
+    if (params?.page_size) queryParams.append('pageSize', params.page_size.toString())
+    if (params?.script_ids?.length) queryParams.append('scriptId', params.script_ids.join(','))
+    if (params?.level) queryParams.append('level', params.level)
+    if (params?.search) queryParams.append('search', params.search)
+    if (params?.since) queryParams.append('since', params.since)
+    if (params?.until) queryParams.append('until', params.until)
+    for (const [name, value] of Object.entries(params?.fields ?? {})) {
+      queryParams.append('field', `${name}:${value}`)
+    }
+
+    const queryString = queryParams.toString() ? `?${queryParams.toString()}` : ''
+    return this.request<PaginatedResponse<ScriptLog>>(`/scripts/logs${queryString}`)
+  }
+
   async clearScriptLogs(id: number): Promise<void> {
     return this.request<void>(`/scripts/${id}/logs`, {
       method: 'DELETE',
@@ -569,6 +927,17 @@ class APIClient {
     })
   }
 
+  // Subscriptions
+  async getSubscriptions(
+    params?: PaginationParams & { topic?: string }
+  ): Promise<PaginatedResponse<FilterSubscriptions>> {
+    let queryString = this.buildQueryString(params)
+    if (params?.topic) {
+      queryString += `${queryString ? '&' : '?'}topic=${encodeURIComponent(params.topic)}`
+    }
+    return this.request<PaginatedResponse<FilterSubscriptions>>(`/subscriptions${queryString}`)
+  }
+
   // Clients
   async getClients(): Promise<Client[]> {
     // Fetch both client list and Prometheus metrics
@@ -689,6 +1058,21 @@ class APIClient {
   async getMetrics(): Promise<Metrics> {
     return this.request<Metrics>('/metrics')
   }
+
+  // Message/byte rates in 10s buckets for sparklines (window up to 1h)
+  async getThroughput(window = '5m'): Promise<Throughput> {
+    return this.request<Throughput>(`/stats/throughput?window=${encodeURIComponent(window)}`)
+  }
+
+  // Resource counts for the dashboard overview in one call
+  async getSummary(): Promise<Summary> {
+    return this.request<Summary>('/summary')
+  }
+
+  // Latest message on a topic (requires LAST_VALUE_CACHE); payload is base64
+  async getLastValue(topic: string): Promise<LastValue> {
+    return this.request<LastValue>(`/topics/last?topic=${encodeURIComponent(topic)}`)
+  }
 }
 
 export const api = new APIClient()
@@ -56,6 +56,8 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
   }
 
   const logout = () => {
+    // Revoke the token server-side; the local session is cleared either way
+    api.logout().catch(() => {})
     api.removeToken()
     localStorage.removeItem('mqtt_user')
     setUser(null)
@@ -30,9 +30,26 @@ declare const msg: {
 
   /** Error message (for disconnect events with errors) */
   error?: string;
+
+  /** MQTT 5 user properties (for publish and connect events; empty for MQTT 3) */
+  userProperties: Record<string, string>;
+
+  /** MQTT 5 Content-Type (for publish events; empty if not set) */
+  contentType: string;
+
+  /** MQTT 5 Payload Format Indicator: 0 = unspecified bytes, 1 = UTF-8 (for publish events) */
+  payloadFormat: 0 | 1;
+
+  /**
+   * Parse the payload as JSON
+   * @throws If the content type is not JSON (application/json or +json) or the payload is invalid
+   */
+  json(): any;
 };
 
 // Logging API
+// A plain object after the message is stored as structured fields, filterable
+// in the logs view: log.info('Reading', {deviceId: 'sensor-1'})
 declare const log: {
   /**
    * Log a debug message
@@ -69,8 +86,39 @@ declare const mqtt: {
    * @param retain - Whether to retain the message on the broker
    */
   publish(topic: string, payload: string, qos: 0 | 1 | 2, retain: boolean): void;
+
+  /**
+   * Subscribe to a topic filter; handler runs for each matching publish until the
+   * script is disabled, updated or deleted. The handler can't use variables from
+   * the surrounding script - keep data in state/global instead
+   * @param topic - MQTT topic filter (wildcards allowed, limited by SCRIPT_SUBSCRIBE_TOPICS)
+   * @param handler - Called with the publish message
+   * @returns false when ignored (script test runs)
+   */
+  subscribe(topic: string, handler: (message: typeof msg) => void): boolean;
+
+  /**
+   * Remove a subscription made with mqtt.subscribe
+   * @param topic - The exact topic filter passed to mqtt.subscribe
+   * @returns Whether the subscription existed
+   */
+  unsubscribe(topic: string): boolean;
 };
 
+/**
+ * Run handler once after delay milliseconds, as its own execution of this script
+ * with the same msg. Like mqtt.subscribe handlers it can't use the script's
+ * variables. Max 100 pending timers per script, delay up to 24 hours
+ * @returns Timer ID for clearTimeout (0 when ignored in script test runs)
+ */
+declare function setTimeout(handler: (message: typeof msg) => void, delay?: number): number;
+
+/**
+ * Cancel a pending timer set by this script
+ * @returns Whether the timer was pending
+ */
+declare function clearTimeout(id: number): boolean;
+
 // Script-scoped state API
 declare const state: {
   /**
@@ -210,6 +210,14 @@ export default function ClientDetailPage() {
               <p className="text-muted-foreground mb-1 text-sm">Last Seen</p>
               <p className="text-sm">{new Date(dbClient.last_seen).toLocaleString()}</p>
             </div>
+            {dbClient.queue_depth !== undefined && (
+              <div>
+                <p className="text-muted-foreground mb-1 text-sm">Queued Messages</p>
+                <p className="font-mono text-sm">
+                  {dbClient.queue_depth} / {dbClient.queue_limit}
+                </p>
+              </div>
+            )}
             {client && (
               <>
                 <div>
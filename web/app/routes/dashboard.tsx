@@ -69,6 +69,7 @@ export default function DashboardPage() {
             <div className="text-2xl font-bold">{metrics.connected_clients}</div>
             <p className="text-muted-foreground text-xs">
               {metrics.total_clients} total connections
+              {metrics.max_clients > 0 && ` of ${metrics.max_clients} max`}
             </p>
           </CardContent>
         </Card>